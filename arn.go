@@ -0,0 +1,141 @@
+/*
+Package arn holds a process-wide default ARN client with package-level helpers to send with it, like
+slog.Default() and slog.Info(). Small services and agents that send from many places can set the client once
+at startup instead of passing it through every layer. Services that need more than one client, or want the
+client to be explicit, should keep using *client.ARN directly.
+
+Example:
+
+	c, err := client.New(ctx, args)
+	if err != nil {
+		panic(err)
+	}
+	defer c.Close()
+	arn.SetDefault(c)
+
+	// Anywhere in the process:
+	if err := arn.Notify(ctx, n); err != nil {
+		// Do something
+	}
+
+The default client is not closed by this package. Call SetDefault(nil) before closing it: SetDefault() waits
+for Notify() and Async() calls already using the old client to return, so nothing is sent on a closed client.
+A client taken with Default() is not covered by this, its callers must stop using it before it is closed.
+*/
+package arn
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/arn-sdk/client"
+	"github.com/Azure/arn-sdk/internal/conn"
+	"github.com/Azure/arn-sdk/models"
+)
+
+var current atomic.Pointer[holder]
+
+// holder is a default client with the Notify() and Async() calls using it. SetDefault() retires the holder
+// it replaces and waits for those calls, while calls that start after the swap use the new holder and
+// never wait.
+type holder struct {
+	c *client.ARN
+
+	mu      sync.Mutex
+	users   int
+	retired bool
+	done    chan struct{}
+}
+
+// acquire registers a call using h.c. It returns false if h was retired, the caller must load the new holder.
+func (h *holder) acquire() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.retired {
+		return false
+	}
+	h.users++
+	return true
+}
+
+// release is called when a call that acquired h is done with h.c.
+func (h *holder) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.users--
+	if h.retired && h.users == 0 {
+		close(h.done)
+	}
+}
+
+// retire stops new calls from acquiring h and waits for the calls that did to release it.
+func (h *holder) retire() {
+	h.mu.Lock()
+	h.retired = true
+	if h.users == 0 {
+		close(h.done)
+	}
+	h.mu.Unlock()
+	<-h.done
+}
+
+// acquire returns the holder of the default client, acquired, or nil if there is no default client.
+func acquire() *holder {
+	for {
+		h := current.Load()
+		if h == nil || h.acquire() {
+			return h
+		}
+	}
+}
+
+// SetDefault makes c the default client used by Notify() and Async(). nil removes the default client.
+// It returns once the Notify() and Async() calls using the previous default client have returned. Calls
+// that start meanwhile use c and do not wait. Thread-safe.
+func SetDefault(c *client.ARN) {
+	var h *holder
+	if c != nil {
+		h = &holder{c: c, done: make(chan struct{})}
+	}
+	if old := current.Swap(h); old != nil {
+		old.retire()
+	}
+}
+
+// Default returns the default client, or nil if SetDefault() has not been called. Thread-safe.
+func Default() *client.ARN {
+	if h := current.Load(); h != nil {
+		return h.c
+	}
+	return nil
+}
+
+// Notify sends n with the default client, see client.ARN.Notify(). It returns models.ErrNoDefaultClient
+// if there is no default client. Thread-safe.
+func Notify(ctx context.Context, n models.Notifications) error {
+	h := acquire()
+	if h == nil {
+		return models.ErrNoDefaultClient
+	}
+	defer h.release()
+	return h.c.Notify(ctx, n)
+}
+
+// Async sends n with the default client, see client.ARN.Async(). If there is no default client, the promise
+// of the returned Notification is fulfilled with models.ErrNoDefaultClient or, without a promise, the error
+// is logged with slog.Default(), as there is no client to send it on the Errors() channel of. Thread-safe.
+func Async(ctx context.Context, n models.Notifications, promise bool) models.Notifications {
+	if h := acquire(); h != nil {
+		defer h.release()
+		return h.c.Async(ctx, n, promise)
+	}
+	if promise {
+		n = n.SetPromise(conn.NewPromise())
+		n.SendPromise(models.ErrNoDefaultClient, nil)
+		return n
+	}
+	slog.Default().Error("could not send notification", "error", models.ErrNoDefaultClient.Error())
+	return n
+}
@@ -0,0 +1,144 @@
+package arn
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/client"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+type fakeSender struct{}
+
+func (fakeSender) Send(ctx context.Context, event []byte) error { return nil }
+
+type fakeUploader struct{}
+
+func (fakeUploader) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	return url.Parse("https://blob/" + id)
+}
+
+func notification(t *testing.T) msgs.Notifications {
+	t.Helper()
+
+	id, err := arm.ParseResourceID("/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+	if err != nil {
+		t.Fatalf("arm.ParseResourceID(): %v", err)
+	}
+	ar, err := types.NewArmResource(types.ActWrite, id, "2024-01-01", map[string]any{"a": 1})
+	if err != nil {
+		t.Fatalf("types.NewArmResource(): %v", err)
+	}
+	return msgs.Notifications{
+		ResourceLocation: "eastus",
+		PublisherInfo:    "Microsoft.ContainerService",
+		Data: []types.NotificationResource{
+			{
+				ResourceID:               id.String(),
+				APIVersion:               "2024-01-01",
+				ResourceEventTime:        time.Now().UTC(),
+				ArmResource:              ar,
+				ResourceSystemProperties: types.ResourceSystemProperties{ChangeAction: types.CAUpdate},
+			},
+		},
+	}
+}
+
+// TestDefault is not parallel, as the default client is global.
+func TestDefault(t *testing.T) {
+	ctx := context.Background()
+
+	SetDefault(nil)
+	if Default() != nil {
+		t.Errorf("TestDefault(no default): got Default() != nil, want nil")
+	}
+	if err := Notify(ctx, notification(t)); !errors.Is(err, models.ErrNoDefaultClient) {
+		t.Errorf("TestDefault(no default): Notify(): got err == %v, want models.ErrNoDefaultClient", err)
+	}
+	n := Async(ctx, notification(t), true)
+	if err := n.Promise(ctx); !errors.Is(err, models.ErrNoDefaultClient) {
+		t.Errorf("TestDefault(no default): Async(): got err == %v, want models.ErrNoDefaultClient", err)
+	}
+
+	c, err := client.New(ctx, client.Args{}, client.WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestDefault: client.New(): got err == %s, want err == nil", err)
+	}
+	defer c.Close()
+	SetDefault(c)
+	defer SetDefault(nil)
+
+	if Default() != c {
+		t.Errorf("TestDefault: got Default() != the client given to SetDefault()")
+	}
+	if err := Notify(ctx, notification(t)); err != nil {
+		t.Errorf("TestDefault: Notify(): got err == %s, want err == nil", err)
+	}
+	n = Async(ctx, notification(t), true)
+	if err := n.Promise(ctx); err != nil {
+		t.Errorf("TestDefault: Async(): got err == %s, want err == nil", err)
+	}
+}
+
+type blockingSender struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b blockingSender) Send(ctx context.Context, event []byte) error {
+	close(b.started)
+	<-b.release
+	return nil
+}
+
+// TestSetDefaultWaits is not parallel, as the default client is global.
+func TestSetDefaultWaits(t *testing.T) {
+	ctx := context.Background()
+
+	sender := blockingSender{started: make(chan struct{}), release: make(chan struct{})}
+	c, err := client.New(ctx, client.Args{}, client.WithFakeClients(sender, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestSetDefaultWaits: client.New(): got err == %s, want err == nil", err)
+	}
+	defer c.Close()
+	SetDefault(c)
+	defer SetDefault(nil)
+
+	notifyErr := make(chan error, 1)
+	go func() {
+		notifyErr <- Notify(ctx, notification(t))
+	}()
+	<-sender.started
+
+	swapped := make(chan struct{})
+	go func() {
+		SetDefault(nil)
+		close(swapped)
+	}()
+
+	select {
+	case <-swapped:
+		t.Fatalf("TestSetDefaultWaits: SetDefault() returned while Notify() was using the old client")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Calls that start while SetDefault() waits do not wait with it.
+	if err := Notify(ctx, notification(t)); !errors.Is(err, models.ErrNoDefaultClient) {
+		t.Errorf("TestSetDefaultWaits: Notify() during SetDefault(): got err == %v, want models.ErrNoDefaultClient", err)
+	}
+
+	close(sender.release)
+	<-swapped
+	if err := <-notifyErr; err != nil {
+		t.Errorf("TestSetDefaultWaits: Notify(): got err == %s, want err == nil", err)
+	}
+	if Default() != nil {
+		t.Errorf("TestSetDefaultWaits: got Default() != nil, want nil")
+	}
+}
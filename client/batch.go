@@ -0,0 +1,326 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn"
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/internal/conn/txn"
+	"github.com/Azure/arn-sdk/models"
+)
+
+// WithBatching coalesces the notifications given to Async() that can share an event, see models.Batchable,
+// into one event with up to maxItems resources and maxBytes of resource JSON. A batch is sent once it is full
+// or maxDelay after its first notification was added, whichever is first. This saves an HTTP call for each
+// notification that joins a batch, at the cost of up to maxDelay of latency.
+//
+// maxItems of 0 is the client's item limit (see WithMaxItems()). maxBytes of 0 is no limit, otherwise each
+// notification is serialized once more when it is added to work out its size. Notifications that cannot be
+// coalesced, such as a Stream or a notification with as many items as maxItems, are sent on their own after
+// the batch they would have joined. Notifications with the same batch key keep their order, but notifications
+// in different batches can be sent in a different order than they were given to Async().
+//
+// Each notification keeps its own promise, which gets the result of the event it was sent in. A notification
+// whose context ends before its batch is sent is dropped from the batch and gets its context's error. The
+// event is sent with the values of the context of the first notification in it, such as its span and
+// SendOptions, and the earliest deadline of the notifications in it. It is cancelled only once the contexts
+// of all of them are. Notify() is not batched. Close() and Drain() send any batches that are waiting.
+func WithBatching(maxItems, maxBytes int, maxDelay time.Duration) Option {
+	return func(a *ARN) error {
+		if maxItems < 0 {
+			return fmt.Errorf("WithBatching(): maxItems cannot be negative")
+		}
+		if maxBytes < 0 {
+			return fmt.Errorf("WithBatching(): maxBytes cannot be negative")
+		}
+		if maxDelay <= 0 {
+			return fmt.Errorf("WithBatching(): maxDelay must be greater than 0")
+		}
+		a.batcher = &batcher{maxItems: maxItems, maxBytes: maxBytes, maxDelay: maxDelay}
+		return nil
+	}
+}
+
+// WithBatchTimeBucket makes WithBatching() only coalesce notifications whose resources have a
+// ResourceEventTime in the same bucket of duration d, the time truncated to a multiple of d, such as a minute.
+// Use this for snapshot-style publishing, where ARG queries rely on the resources in an event having
+// consistent timestamps. A notification whose resources are in more than one bucket is sent on its own
+// after the batches with its batch key, use msgs.Notifications.PartitionByTime() to split it first. This
+// requires WithBatching(). By default, batches are not split by time.
+func WithBatchTimeBucket(d time.Duration) Option {
+	return func(a *ARN) error {
+		if d <= 0 {
+			return fmt.Errorf("WithBatchTimeBucket(): d must be greater than 0")
+		}
+		a.batchBucket = d
+		return nil
+	}
+}
+
+// batcher holds the notifications that are waiting to be sent in a batch. Thread-safe.
+type batcher struct {
+	maxItems int
+	maxBytes int
+	maxDelay time.Duration
+	// bucket is the event time bucket set by WithBatchTimeBucket(), 0 if batches are not split by time.
+	bucket time.Duration
+
+	// send hands a merged notification to the sender. It is set by init().
+	send func(models.Notifications)
+	// errs is where the results of notifications without a promise go.
+	errs chan error
+
+	mu      sync.Mutex
+	pending map[any]*batch
+	closed  bool
+	// flushing counts the batches that have been taken from pending but not yet handed to send.
+	flushing sync.WaitGroup
+}
+
+// batch is the notifications waiting to be sent together.
+type batch struct {
+	key     any
+	members []models.Notifications
+	items   int
+	bytes   int
+	timer   *time.Timer
+}
+
+// bucketKey is the key of a batch when batches are split by event time. bucket is the start of the bucket in
+// Unix nanoseconds, so that times in different locations are the same key.
+type bucketKey struct {
+	key    any
+	bucket int64
+}
+
+// bucketOf returns the event time bucket of the resources of n, and false if they are in more than one.
+func (b *batcher) bucketOf(n models.Notifications) (int64, bool) {
+	var bucket int64
+	first := true
+	for r := range n.Resources() {
+		t := r.EventTime.Truncate(b.bucket).UnixNano()
+		if !first && t != bucket {
+			return 0, false
+		}
+		bucket, first = t, false
+	}
+	return bucket, true
+}
+
+// init readies b to be used by a, whose item limit is limit.
+func (b *batcher) init(a *ARN, limit int) error {
+	if limit <= 0 {
+		limit = maxvals.NotificationItems
+	}
+	if b.maxItems == 0 {
+		b.maxItems = limit
+	}
+	if b.maxItems > limit {
+		return fmt.Errorf("WithBatching(): maxItems(%d) is more than the item limit of %d", b.maxItems, limit)
+	}
+	b.errs = a.errs
+	b.pending = map[any]*batch{}
+	b.send = func(n models.Notifications) {
+		a.in <- a.track(n)
+	}
+	return nil
+}
+
+// add adds n to the batch for its key. It returns false if n cannot be batched, in which case any batch with
+// the same key has been sent so n can follow it.
+func (b *batcher) add(n models.Notifications) bool {
+	// A notification in a group is stamped with the group's correlation ID, so it cannot share an event.
+	if _, ok := txn.FromCtx(n.Ctx()); ok {
+		return false
+	}
+	bn, ok := n.(models.Batchable)
+	if !ok {
+		return false
+	}
+	key, ok := bn.BatchKey()
+	if !ok {
+		return false
+	}
+	mixed := false
+	if b.bucket > 0 {
+		bucket, ok := b.bucketOf(n)
+		mixed = !ok
+		key = bucketKey{key: key, bucket: bucket}
+	}
+	items := n.DataCount()
+	size := 0
+	if b.maxBytes > 0 {
+		size = n.DataSizeHint()
+	}
+
+	var full []*batch
+	defer func() {
+		for _, bt := range full {
+			b.flush(bt)
+		}
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+	if mixed {
+		// n follows the batches of every bucket with its batch key.
+		base := key.(bucketKey).key
+		for _, bt := range b.pending {
+			if bt.key.(bucketKey).key == base {
+				full = append(full, b.take(bt))
+			}
+		}
+		return false
+	}
+
+	bt := b.pending[key]
+	if items >= b.maxItems || size < 0 || (b.maxBytes > 0 && size >= b.maxBytes) {
+		if bt != nil {
+			full = append(full, b.take(bt))
+		}
+		return false
+	}
+	if bt != nil && (bt.items+items > b.maxItems || (b.maxBytes > 0 && bt.bytes+size > b.maxBytes)) {
+		full = append(full, b.take(bt))
+		bt = nil
+	}
+	if bt == nil {
+		bt = &batch{key: key}
+		bt.timer = time.AfterFunc(b.maxDelay, func() { b.expire(bt) })
+		b.pending[key] = bt
+	}
+	bt.members = append(bt.members, n)
+	bt.items += items
+	bt.bytes += size
+	if bt.items == b.maxItems {
+		full = append(full, b.take(bt))
+	}
+	return true
+}
+
+// take removes bt from pending. The caller must hold b.mu and must call flush(bt).
+func (b *batcher) take(bt *batch) *batch {
+	bt.timer.Stop()
+	delete(b.pending, bt.key)
+	b.flushing.Add(1)
+	return bt
+}
+
+// expire sends bt when its maxDelay has passed, unless it has already been sent.
+func (b *batcher) expire(bt *batch) {
+	b.mu.Lock()
+	if b.pending[bt.key] != bt {
+		b.mu.Unlock()
+		return
+	}
+	b.take(bt)
+	b.mu.Unlock()
+	b.flush(bt)
+}
+
+// close sends the batches that are waiting and stops any more from being added. When it returns, every
+// batch has been handed to the sender.
+func (b *batcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	var all []*batch
+	for _, bt := range b.pending {
+		all = append(all, b.take(bt))
+	}
+	b.mu.Unlock()
+
+	for _, bt := range all {
+		b.flush(bt)
+	}
+	b.flushing.Wait()
+}
+
+// mergedCtx returns the context of the notification merged from members. It has the values of the first
+// member's context, such as its span, SendOptions and metrics registry, and the earliest deadline of the
+// members. It is cancelled once the context of every member is done. stop releases it.
+func mergedCtx(members []models.Notifications) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(members[0].Ctx()))
+	var deadline time.Time
+	for _, m := range members {
+		if d, ok := m.Ctx().Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	cancelDeadline := context.CancelFunc(func() {})
+	if !deadline.IsZero() {
+		ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+	}
+
+	var left atomic.Int64
+	left.Store(int64(len(members)))
+	stops := make([]func() bool, 0, len(members))
+	for _, m := range members {
+		mctx := m.Ctx()
+		stops = append(stops, context.AfterFunc(mctx, func() {
+			if left.Add(-1) == 0 {
+				cancel(context.Cause(mctx))
+			}
+		}))
+	}
+	return ctx, func() {
+		for _, s := range stops {
+			s()
+		}
+		cancelDeadline()
+		cancel(nil)
+	}
+}
+
+// flush merges the members of bt whose context has not ended into one notification and hands it to the
+// sender. The result of the merged notification is sent on the promise of each member.
+func (b *batcher) flush(bt *batch) {
+	defer b.flushing.Done()
+
+	live := make([]models.Notifications, 0, len(bt.members))
+	for _, m := range bt.members {
+		if err := m.Ctx().Err(); err != nil {
+			m.SendPromise(err, b.errs)
+			continue
+		}
+		live = append(live, m)
+	}
+	if len(live) == 0 {
+		return
+	}
+	if len(live) == 1 {
+		b.send(live[0])
+		return
+	}
+
+	merged, err := live[0].(models.Batchable).Merge(live[1:])
+	if err != nil {
+		// This only happens if a model's BatchKey() and Merge() disagree, so the members are sent on their own.
+		for _, m := range live {
+			b.send(m)
+		}
+		return
+	}
+	// A member that is cancelled after this point still gets the result of the event, unless every member is.
+	ctx, stop := mergedCtx(live)
+	merged = merged.SetCtx(ctx)
+	p := conn.NewPromise()
+	merged = merged.SetPromise(p)
+	b.send(merged)
+
+	go func() {
+		err := <-p
+		stop()
+		conn.RecyclePromise(p)
+		for _, m := range live {
+			m.SendPromise(err, b.errs)
+		}
+	}()
+}
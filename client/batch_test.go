@@ -0,0 +1,339 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+
+	"github.com/go-json-experiment/json"
+)
+
+// batchSender records the number of resources in each event it is sent.
+type batchSender struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (s *batchSender) Send(ctx context.Context, event []byte) error {
+	var e struct {
+		Data struct {
+			Resources []any `json:"resources"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(event, &e); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sizes = append(s.sizes, len(e.Data.Resources))
+	return nil
+}
+
+func (s *batchSender) got() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.sizes)
+}
+
+func TestWithBatching(t *testing.T) {
+	t.Parallel()
+
+	unbatchable := validNotification(t)
+	unbatchable.AdditionalBatchProperties.Others = map[string]any{"key": "value"}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+
+	tests := []struct {
+		name     string
+		maxItems int
+		maxDelay time.Duration
+		send     func(a *ARN) []models.Notifications
+		// wait is how long to wait before closing the client, to let batches expire.
+		wait      time.Duration
+		wantSizes []int
+		wantErrs  []bool
+	}{
+		{
+			name:     "Full batches are sent",
+			maxItems: 3,
+			maxDelay: time.Hour,
+			send: func(a *ARN) []models.Notifications {
+				var ns []models.Notifications
+				for i := 0; i < 6; i++ {
+					ns = append(ns, a.Async(context.Background(), validNotification(t), true))
+				}
+				return ns
+			},
+			wantSizes: []int{3, 3},
+			wantErrs:  []bool{false, false, false, false, false, false},
+		},
+		{
+			name:     "Batch is sent after maxDelay",
+			maxDelay: 10 * time.Millisecond,
+			send: func(a *ARN) []models.Notifications {
+				return []models.Notifications{
+					a.Async(context.Background(), validNotification(t), true),
+					a.Async(context.Background(), validNotification(t), true),
+				}
+			},
+			wait:      200 * time.Millisecond,
+			wantSizes: []int{2},
+			wantErrs:  []bool{false, false},
+		},
+		{
+			name:     "Close sends waiting batches",
+			maxDelay: time.Hour,
+			send: func(a *ARN) []models.Notifications {
+				return []models.Notifications{
+					a.Async(context.Background(), validNotification(t), true),
+					a.Async(context.Background(), validNotification(t), true),
+					a.Async(context.Background(), validNotification(t), true),
+				}
+			},
+			wantSizes: []int{3},
+			wantErrs:  []bool{false, false, false},
+		},
+		{
+			name:     "Notification that cannot be batched is sent on its own",
+			maxDelay: time.Hour,
+			send: func(a *ARN) []models.Notifications {
+				return []models.Notifications{
+					a.Async(context.Background(), validNotification(t), true),
+					a.Async(context.Background(), unbatchable, true),
+					a.Async(context.Background(), validNotification(t), true),
+				}
+			},
+			wantSizes: []int{1, 2},
+			wantErrs:  []bool{false, false, false},
+		},
+		{
+			name:     "Cancelled notification is dropped from its batch",
+			maxDelay: time.Hour,
+			send: func(a *ARN) []models.Notifications {
+				ns := []models.Notifications{
+					a.Async(cancelled, validNotification(t), true),
+					a.Async(context.Background(), validNotification(t), true),
+					a.Async(context.Background(), validNotification(t), true),
+				}
+				cancel()
+				return ns
+			},
+			wantSizes: []int{2},
+			wantErrs:  []bool{true, false, false},
+		},
+	}
+
+	for _, test := range tests {
+		s := &batchSender{}
+		a, err := New(context.Background(), Args{}, WithFakeClients(s, fakeUploader{}), WithBatching(test.maxItems, 0, test.maxDelay))
+		if err != nil {
+			t.Fatalf("TestWithBatching(%s): New(): got err == %s, want err == nil", test.name, err)
+		}
+
+		ns := test.send(a)
+		time.Sleep(test.wait)
+		a.Close()
+
+		ctx, cancelWait := context.WithTimeout(context.Background(), 10*time.Second)
+		for i, n := range ns {
+			err := n.Promise(ctx)
+			if (err != nil) != test.wantErrs[i] {
+				t.Errorf("TestWithBatching(%s): notification %d: got err == %v, want error: %v", test.name, i, err, test.wantErrs[i])
+			}
+		}
+		cancelWait()
+
+		if got := s.got(); !slices.Equal(got, test.wantSizes) {
+			t.Errorf("TestWithBatching(%s): got events with %v resources, want %v", test.name, got, test.wantSizes)
+		}
+	}
+}
+
+func TestWithBatchingOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		options  []Option
+		maxItems int
+		maxBytes int
+		maxDelay time.Duration
+		wantErr  bool
+	}{
+		{name: "Defaults", maxDelay: time.Second},
+		{name: "Within WithMaxItems()", options: []Option{WithMaxItems(10)}, maxItems: 10, maxDelay: time.Second},
+		{name: "Error: negative maxItems", maxItems: -1, maxDelay: time.Second, wantErr: true},
+		{name: "Error: negative maxBytes", maxBytes: -1, maxDelay: time.Second, wantErr: true},
+		{name: "Error: no maxDelay", wantErr: true},
+		{name: "Error: maxItems over the limit", maxItems: 1001, maxDelay: time.Second, wantErr: true},
+		{name: "Error: maxItems over WithMaxItems()", options: []Option{WithMaxItems(10)}, maxItems: 11, maxDelay: time.Second, wantErr: true},
+	}
+
+	for _, test := range tests {
+		options := append(test.options, WithFakeClients(fakeSender{}, fakeUploader{}), WithBatching(test.maxItems, test.maxBytes, test.maxDelay))
+		a, err := New(context.Background(), Args{}, options...)
+		switch {
+		case err == nil && test.wantErr:
+			a.Close()
+			t.Errorf("TestWithBatchingOptions(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestWithBatchingOptions(%s): got err == %s, want err == nil", test.name, err)
+		case err == nil:
+			a.Close()
+		}
+	}
+}
+
+func TestBatcherMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	n := validNotification(t)
+	size := n.DataSizeHint()
+
+	// Sizes vary by a few bytes between notifications (timestamps), so leave room for two but not three.
+	s := &batchSender{}
+	a, err := New(context.Background(), Args{}, WithFakeClients(s, fakeUploader{}), WithBatching(0, 2*size+size/2, time.Hour))
+	if err != nil {
+		t.Fatalf("TestBatcherMaxBytes: New(): got err == %s, want err == nil", err)
+	}
+	var ns []models.Notifications
+	for i := 0; i < 5; i++ {
+		ns = append(ns, a.Async(context.Background(), validNotification(t), true))
+	}
+	big := validNotification(t)
+	big.Data = append(big.Data, big.Data[0], big.Data[0])
+	ns = append(ns, a.Async(context.Background(), big, true))
+	a.Close()
+
+	for i, n := range ns {
+		if err := n.Promise(context.Background()); err != nil {
+			t.Errorf("TestBatcherMaxBytes: notification %d: got err == %s, want err == nil", i, err)
+		}
+	}
+	if got, want := s.got(), []int{2, 2, 1, 3}; !slices.Equal(got, want) {
+		t.Errorf("TestBatcherMaxBytes: got events with %v resources, want %v", got, want)
+	}
+}
+
+func TestBatcherTimeBucket(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}), WithBatchTimeBucket(time.Minute)); err == nil {
+		t.Errorf("TestBatcherTimeBucket(no WithBatching()): got err == nil, want err != nil")
+	}
+	if _, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}), WithBatching(0, 0, time.Hour), WithBatchTimeBucket(0)); err == nil {
+		t.Errorf("TestBatcherTimeBucket(0 bucket): got err == nil, want err != nil")
+	}
+
+	base := time.Now().UTC().Truncate(time.Hour)
+	at := func(ds ...time.Duration) models.Notifications {
+		n := validNotification(t)
+		r := n.Data[0]
+		n.Data = nil
+		for _, d := range ds {
+			r.ResourceEventTime = base.Add(d)
+			n.Data = append(n.Data, r)
+		}
+		return n
+	}
+
+	tests := []struct {
+		name  string
+		times [][]time.Duration
+		// sorted compares the sizes without their order, for batches that are sent together by Close().
+		sorted    bool
+		wantSizes []int
+	}{
+		{
+			name:      "Batches are split by bucket",
+			times:     [][]time.Duration{{10 * time.Second}, {70 * time.Second}, {20 * time.Second}, {80 * time.Second}, {30 * time.Second}},
+			sorted:    true,
+			wantSizes: []int{2, 3},
+		},
+		{
+			name:      "Notification in more than one bucket is sent on its own",
+			times:     [][]time.Duration{{10 * time.Second}, {10 * time.Second, 70 * time.Second}, {20 * time.Second}},
+			wantSizes: []int{1, 2, 1},
+		},
+	}
+
+	for _, test := range tests {
+		s := &batchSender{}
+		a, err := New(ctx, Args{}, WithFakeClients(s, fakeUploader{}), WithBatching(0, 0, time.Hour), WithBatchTimeBucket(time.Minute))
+		if err != nil {
+			t.Fatalf("TestBatcherTimeBucket(%s): New(): got err == %s, want err == nil", test.name, err)
+		}
+		var ns []models.Notifications
+		for _, ds := range test.times {
+			ns = append(ns, a.Async(ctx, at(ds...), true))
+		}
+		a.Close()
+
+		for i, n := range ns {
+			if err := n.Promise(ctx); err != nil {
+				t.Errorf("TestBatcherTimeBucket(%s): notification %d: got err == %s, want err == nil", test.name, i, err)
+			}
+		}
+		got := s.got()
+		if test.sorted {
+			slices.Sort(got)
+		}
+		if !slices.Equal(got, test.wantSizes) {
+			t.Errorf("TestBatcherTimeBucket(%s): got events with %v resources, want %v", test.name, got, test.wantSizes)
+		}
+	}
+}
+
+func TestMergedCtx(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+	first, cancelFirst := context.WithTimeout(context.WithValue(context.Background(), key{}, "first"), time.Hour)
+	defer cancelFirst()
+	second, cancelSecond := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelSecond()
+
+	members := []models.Notifications{validNotification(t).SetCtx(first), validNotification(t).SetCtx(second)}
+	ctx, stop := mergedCtx(members)
+	defer stop()
+
+	if got := ctx.Value(key{}); got != "first" {
+		t.Errorf("TestMergedCtx: got Value() == %v, want the value of the first member's context", got)
+	}
+	want, _ := second.Deadline()
+	if got, ok := ctx.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("TestMergedCtx: got Deadline() == %v, %v, want the earliest deadline %v", got, ok, want)
+	}
+
+	cancelFirst()
+	select {
+	case <-ctx.Done():
+		t.Fatalf("TestMergedCtx: the context is done after one of two members was cancelled, want it not done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancelSecond()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestMergedCtx: the context is not done after every member was cancelled")
+	}
+	if !errors.Is(context.Cause(ctx), context.Canceled) {
+		t.Errorf("TestMergedCtx: got Cause() == %v, want context.Canceled", context.Cause(ctx))
+	}
+
+	// stop releases a context whose members are still live.
+	live, cancelLive := context.WithCancel(context.Background())
+	defer cancelLive()
+	ctx, stop = mergedCtx([]models.Notifications{validNotification(t).SetCtx(live)})
+	stop()
+	if ctx.Err() == nil {
+		t.Errorf("TestMergedCtx: got Err() == nil after stop(), want err != nil")
+	}
+}
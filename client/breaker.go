@@ -0,0 +1,46 @@
+package client
+
+import (
+	"github.com/Azure/arn-sdk/internal/conn/breaker"
+)
+
+// CircuitBreakerOptions configures the circuit breaker, see WithCircuitBreaker().
+type CircuitBreakerOptions = breaker.Options
+
+// CircuitState is the state of the circuit breaker, see CircuitState().
+type CircuitState = breaker.State
+
+const (
+	// CircuitClosed sends every notification.
+	CircuitClosed = breaker.Closed
+	// CircuitOpen fails every notification with models.ErrCircuitOpen.
+	CircuitOpen = breaker.Open
+	// CircuitHalfOpen sends one notification at a time as a probe of the receiver.
+	CircuitHalfOpen = breaker.HalfOpen
+)
+
+// WithCircuitBreaker stops sending to the ARN receiver while it is failing. After o.Threshold notifications in
+// a row fail with a 5xx response or a network error, after any retries, the circuit opens and notifications
+// fail fast with models.ErrCircuitOpen instead of being queued, so callers can shed load or buffer them
+// locally. After o.Cooldown one notification at a time is sent as a probe, and o.Probes successful probes in
+// a row close the circuit. Failures of blob storage uploads count too, as they also stop notifications from
+// getting through. See CircuitState().
+func WithCircuitBreaker(o CircuitBreakerOptions) Option {
+	return func(c *ARN) error {
+		b, err := breaker.New(o)
+		if err != nil {
+			return err
+		}
+		c.breaker = b
+		return nil
+	}
+}
+
+// CircuitState returns the state of the circuit breaker. It is always CircuitClosed if WithCircuitBreaker()
+// is not used. Thread-safe.
+func (a *ARN) CircuitState() CircuitState {
+	if a.breaker == nil {
+		return CircuitClosed
+	}
+	return a.breaker.State()
+}
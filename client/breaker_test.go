@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithCircuitBreaker(CircuitBreakerOptions{Threshold: -1}), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithCircuitBreaker: invalid options: got err == nil, want err != nil")
+	}
+
+	s := &flakySender{fails: 100}
+	a, err := New(ctx, Args{}, WithCircuitBreaker(CircuitBreakerOptions{Threshold: 2, Cooldown: time.Hour}), WithFakeClients(s, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithCircuitBreaker: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	if got := a.CircuitState(); got != CircuitClosed {
+		t.Errorf("TestWithCircuitBreaker: got CircuitState() %s before any sends, want %s", got, CircuitClosed)
+	}
+	for i := 0; i < 2; i++ {
+		if err := a.Notify(ctx, validNotification(t)); err == nil {
+			t.Errorf("TestWithCircuitBreaker: Notify() %d: got err == nil, want the 503 error", i)
+		}
+	}
+	if got := a.CircuitState(); got != CircuitOpen {
+		t.Errorf("TestWithCircuitBreaker: got CircuitState() %s after the threshold, want %s", got, CircuitOpen)
+	}
+	if err := a.Notify(ctx, validNotification(t)); !errors.Is(err, models.ErrCircuitOpen) {
+		t.Errorf("TestWithCircuitBreaker: Notify() while open: got err == %v, want models.ErrCircuitOpen", err)
+	}
+	if got := s.sends.Load(); got != 2 {
+		t.Errorf("TestWithCircuitBreaker: got %d sends, want 2", got)
+	}
+
+	b, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithCircuitBreaker: New() without a breaker: got err == %s, want err == nil", err)
+	}
+	defer b.Close()
+	if got := b.CircuitState(); got != CircuitClosed {
+		t.Errorf("TestWithCircuitBreaker: got CircuitState() %s without a breaker, want %s", got, CircuitClosed)
+	}
+}
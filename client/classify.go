@@ -0,0 +1,44 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/classify"
+)
+
+// ClassificationPolicy restricts the data boundaries that classified fields of resource properties can be
+// sent in. See WithClassificationPolicy().
+type ClassificationPolicy = classify.Policy
+
+// ClassificationRule restricts the data boundaries that one classification can be sent in.
+type ClassificationRule = classify.Rule
+
+// ClassificationAction is what is done with a classified field in a notification for a data boundary it
+// is not allowed in.
+type ClassificationAction = classify.Action
+
+const (
+	// ClassificationBlock fails the notification with an error wrapping a *ClassificationViolation.
+	ClassificationBlock = classify.Block
+	// ClassificationRedact removes the field from the notification that is sent.
+	ClassificationRedact = classify.Redact
+)
+
+// ClassificationViolation is the error of a notification blocked by a ClassificationPolicy.
+type ClassificationViolation = classify.Violation
+
+// WithClassificationPolicy enforces p on every notification before it is sent. Fields of
+// ArmResource.Properties are classified with the types.ClassTag struct tag, such as `arnclass:"euii"`, or
+// with types.RegisterClassifications(). A classified field that is present in a notification whose
+// DataBoundary is not allowed by its rule blocks the notification or is redacted, so that data restricted to
+// the EU boundary cannot be sent in a global notification by mistake. The caller's notification is not
+// changed. A msgs.Stream fails, as its resources are not read before they are uploaded.
+func WithClassificationPolicy(p ClassificationPolicy) Option {
+	return func(c *ARN) error {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid classification policy: %w", err)
+		}
+		c.classify = &p
+		return nil
+	}
+}
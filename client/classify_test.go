@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+type euiiProps struct {
+	Email string `json:"email" arnclass:"euii"`
+}
+
+func TestWithClassificationPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithClassificationPolicy(ClassificationPolicy{}), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithClassificationPolicy: invalid policy: got err == nil, want err != nil")
+	}
+
+	p := ClassificationPolicy{
+		Rules: []ClassificationRule{{Classification: "euii", Allowed: []types.DataBoundary{types.DBEU}}},
+	}
+	a, err := New(ctx, Args{}, WithClassificationPolicy(p), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithClassificationPolicy: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	n := validNotification(t)
+	n.Data[0].ArmResource.Properties = euiiProps{Email: "a@example.com"}
+
+	n.DataBoundary = types.DBEU
+	if err := a.Notify(ctx, n); err != nil {
+		t.Errorf("TestWithClassificationPolicy: Notify() in an allowed boundary: got err == %s, want err == nil", err)
+	}
+
+	n.DataBoundary = types.DBGlobal
+	err = a.Notify(ctx, n)
+	var v *ClassificationViolation
+	if !errors.As(err, &v) {
+		t.Errorf("TestWithClassificationPolicy: Notify() in a blocked boundary: got err == %v, want a *ClassificationViolation", err)
+	}
+}
@@ -18,7 +18,28 @@ are not required to listen on this channel.
 These features allow you to make decisions for your service on how important the accuracy of information is where notifications are
 taking excess time, the ARN service is down, the network is congested, etc.
 
-Example - boilerplate that is needed on AKS to make ARN connections:
+The context passed to Notify() or Async() is used for every request made to send the notification, to the ARN
+receiver and to blob storage. The SDK respects these context values:
+  - Deadline and cancellation: these bound the send, including all retries, along with RetryPolicy.Budget.
+  - WithSendOptions(): headers, the number of attempts and a per-attempt timeout for this notification.
+  - policy.WithHTTPHeader() (or runtime.WithHTTPHeader()): headers added to every request.
+  - policy.WithRetryOptions(): only if Args.Retry is not set, as it replaces the azcore retries. With Args.Retry
+    it is ignored, use WithSendOptions() instead.
+
+Other values are passed to the azcore pipelines, so they reach any policies you add with HTTPArgs.Opts or
+BlobArgs.Opts, but are not otherwise supported.
+
+Example - creating a client with a managed identity or a workload identity, which is all most services need:
+
+	arnClient, err := client.NewWithManagedIdentity(ctx, *arnEndpoint, *storageAccount, *msid)
+	if err != nil {
+		panic(err)
+	}
+
+	// Or, in a pod with workload identity, where the webhook injects the client ID, tenant and token file:
+	arnClient, err := client.NewWithWorkloadIdentity(ctx, *arnEndpoint, *storageAccount, "")
+
+Example - boilerplate that is needed on AKS to make ARN connections when you need more control over credentials:
 
 	// You would need to customize these for yourself. You need an ARN endpoint from the ARN team along with
 	// associated credentials.
@@ -150,10 +171,15 @@ Example - sending a notification asynchronously using the v3 model using a AKS n
 	notification := arnClient.Async(ctx, notificiation, true)
 	... // Do stuff
 
-	if err := notification.Promise(); err != nil {
-		// Handle error
+	err := notification.Promise(ctx)
+	switch {
+	case errors.Is(err, models.ErrPromiseTimeout), errors.Is(err, models.ErrPromiseCanceled):
+		// We stopped waiting, the result is still pending.
+	case err != nil:
+		// Handle send error
 	}
-	notification.Recycle() // Reuses the promise for the next notification
+
+The SDK manages the lifetime of promises, there is no need to recycle them.
 
 Example - sending a notification asynchronously using the v3 model using a AKS node event and without a promise:
 
@@ -170,27 +196,43 @@ Example - sending a notification asynchronously using the v3 model using a AKS n
 package client
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/pprof"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Azure/arn-sdk/internal/build"
 	"github.com/Azure/arn-sdk/internal/conn"
+	"github.com/Azure/arn-sdk/internal/conn/breaker"
+	"github.com/Azure/arn-sdk/internal/conn/capture"
 	"github.com/Azure/arn-sdk/internal/conn/http"
-	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/internal/conn/ratecoord"
+	"github.com/Azure/arn-sdk/internal/conn/spool"
+	"github.com/Azure/arn-sdk/internal/conn/state"
 	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/internal/conn/timing"
+	"github.com/Azure/arn-sdk/internal/conn/watchdog"
 	"github.com/Azure/arn-sdk/models"
 	modelmetrics "github.com/Azure/arn-sdk/models/metrics"
+	"github.com/Azure/arn-sdk/transport"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ARN is a client for interacting with the ARN service.
 type ARN struct {
 	logger *slog.Logger
 	conn   *conn.Service
+	http   *http.Client
+	store  *storage.Client
 
 	in   chan models.Notifications
 	errs chan error
@@ -202,6 +244,63 @@ type ARN struct {
 	sigSenderClosed chan struct{}
 
 	meterProvider metric.MeterProvider
+	buckets       *MetricBuckets
+	// metrics is the registry the client records to, set by WithMetricsRegistry().
+	metrics *MetricsRegistry
+	// tracerProvider is set by WithTracerProvider(), nil if notifications are not traced.
+	tracerProvider trace.TracerProvider
+
+	watchdogAfter time.Duration
+	watchdog      *watchdog.Watchdog
+
+	extension *progress.Extension
+	state     *state.Cache
+	slo       *SLOOptions
+
+	maxItems   int
+	blobCanary int
+	faults     *FaultConfig
+	quotaOpts  *QuotaOptions
+	skew       *TimeSkew
+	provenance *Provenance
+	classify   *ClassificationPolicy
+	propLimits *PropertyLimits
+	preview    *PreviewVersions
+	encrypt    BlobKeyWrapper
+	leader     *LeaderOptions
+
+	// maxResourceBytes is set by WithMaxResourceBytes(), 0 if resource sizes are not checked.
+	maxResourceBytes int
+
+	// captureOpts are set by WithFailureCapture(), capture keeps the failures.
+	captureOpts *CaptureOptions
+	capture     *capture.Recorder
+	// slowOpts are set by WithSlowSendSampling(), slow keeps the slowest sends.
+	slowOpts *SlowSendOptions
+	slow     *timing.Sampler
+	// batcher coalesces Async() notifications, set by WithBatching().
+	batcher *batcher
+	// batchBucket is set by WithBatchTimeBucket().
+	batchBucket time.Duration
+	// resend is set by WithRetry().
+	resend *resendOpts
+	// breaker is set by WithCircuitBreaker().
+	breaker *breaker.Breaker
+	// concurrency is set by WithConcurrency().
+	concurrency *concurrencyOpts
+	// spoolDir is set by WithSpoolDir(). spool wraps the HTTP client if it is set.
+	spoolDir string
+	spool    *spool.Spool
+	// deadLetters is set by WithDeadLetter().
+	deadLetters chan models.Notifications
+	// journal is set by WithJournal().
+	journal *slog.Logger
+	// secretOpts are set by WithSecretResolution(), secrets resolves the Key Vault references in Args.
+	secretOpts *SecretResolution
+	secrets    *secrets
+	// rateOpts are set by WithRateCoordination(), rateCoord divides the rate with the other instances.
+	rateOpts  *RateCoordination
+	rateCoord *ratecoord.Coordinator
 
 	fakeSender   Sender
 	fakeUploader Uploader
@@ -239,6 +338,118 @@ func WithMeterProvider(m metric.MeterProvider) Option {
 	}
 }
 
+// WithTracerProvider sets the tracer provider with which to record OpenTelemetry spans. Each notification
+// given to Notify() or Async() gets an "arn.Notification" span, a child of the span in the context it was
+// given with, that starts when it is queued and records its item count, the ARN event's ID and subject and
+// the error it failed with. Its stages, "arn.marshal", "arn.seal", "arn.uploadBlob" and "arn.sendHTTP", are
+// child spans. A notification that fails before it is queued, such as one with too many items, has no span.
+// Defaults to nil, in which case notifications are not traced.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(r *ARN) error {
+		if tp == nil {
+			return fmt.Errorf("tracer provider cannot be nil")
+		}
+		r.tracerProvider = tp
+		return nil
+	}
+}
+
+// MetricBuckets are the bucket boundaries of the histograms registered with WithMeterProvider().
+type MetricBuckets = modelmetrics.Buckets
+
+// WithMetricBuckets sets the bucket boundaries of the event latency and size histograms, for metric
+// backends that need different boundaries than the defaults. A nil field uses the default boundaries.
+// This only has an effect with WithMeterProvider().
+func WithMetricBuckets(b MetricBuckets) Option {
+	return func(r *ARN) error {
+		if err := b.Validate(); err != nil {
+			return err
+		}
+		r.buckets = &b
+		return nil
+	}
+}
+
+// MetricsRegistry holds the instruments a client records its metrics to. Create one with
+// metrics.NewRegistry() from github.com/Azure/arn-sdk/models/metrics.
+type MetricsRegistry = modelmetrics.Registry
+
+// WithMetricsRegistry records the client's metrics to r instead of the process-global registry,
+// metrics.Default(). With WithMeterProvider(), r is initialized with the provider's meter. This lets tests
+// create clients with different meters without them racing on the global registry.
+func WithMetricsRegistry(r *MetricsRegistry) Option {
+	return func(c *ARN) error {
+		if r == nil {
+			return fmt.Errorf("metrics registry cannot be nil")
+		}
+		c.metrics = r
+		return nil
+	}
+}
+
+// ConnOptions are options for tuning the connections to the ARN receiver.
+type ConnOptions = http.ConnOptions
+
+// HedgeOptions configures hedged sends to the ARN receiver.
+type HedgeOptions = http.HedgeOptions
+
+// WithWatchdog turns on a watchdog that logs a warning and records a metric when a notification stays in the
+// send pipeline longer than d. The warning includes the stage the notification is in (queued, uploading blob
+// or awaiting HTTP), which helps diagnose stuck sends that otherwise look like promise timeouts.
+func WithWatchdog(d time.Duration) Option {
+	return func(c *ARN) error {
+		if d <= 0 {
+			return fmt.Errorf("watchdog duration must be greater than 0")
+		}
+		c.watchdogAfter = d
+		return nil
+	}
+}
+
+// WithPromiseExtension extends a wait on a notification's Promise() past its deadline for as long as the
+// notification makes progress in the send pipeline, such as bytes being uploaded to blob storage or a
+// request being retried. The wait ends when there has been no progress for idle, or max after the deadline.
+// This stops a large snapshot that is still uploading from failing with models.ErrPromiseTimeout. Only the
+// deadline of the Promise() context is extended, a cancelled context still ends the wait. Notify() is not
+// extended, as its context also bounds the send.
+func WithPromiseExtension(idle, max time.Duration) Option {
+	return func(c *ARN) error {
+		ext := progress.Extension{Idle: idle, Max: max}
+		if err := ext.Validate(); err != nil {
+			return err
+		}
+		c.extension = &ext
+		return nil
+	}
+}
+
+// WithMaxItems sets the maximum number of items (Notifications.DataCount()) in a notification.
+// Defaults to DefaultMaxItems (1000). This limit is enforced by every layer of the client.
+func WithMaxItems(n int) Option {
+	return func(c *ARN) error {
+		if n <= 0 {
+			return fmt.Errorf("max items must be greater than 0")
+		}
+		c.maxItems = n
+		return nil
+	}
+}
+
+// WithBlobCanary sends every nth notification through blob storage, even if it is small enough to send
+// inline. Small publishers rarely need blob storage, so a misconfigured storage account or a missing RBAC
+// assignment otherwise goes unnoticed until a large snapshot fails. If a canary cannot be uploaded, it is
+// sent inline and the failure is recorded. Results are reported in Stats() and by the
+// arn-sdk_blob_canary_total metric. Requires Args.Blob.
+func WithBlobCanary(n int) Option {
+	return func(c *ARN) error {
+		if n <= 0 {
+			return fmt.Errorf("blob canary interval must be greater than 0")
+		}
+		c.blobCanary = n
+		return nil
+	}
+}
+
 // Sender is a fake sender for testing.
 type Sender = http.Sender
 
@@ -266,16 +477,30 @@ func WithFakeClients(fs Sender, fu Uploader) Option {
 // Args are the arguments for creating a new ARN client.
 type Args struct {
 	// HTTP is used to configure the HTTP client to talk to ARN.
-	HTTP HTTPArgs
+	HTTP HTTPArgs `json:"http" yaml:"http"`
 
-	// Blob is the blob storage client used for large messages.
-	Blob BlobArgs
+	// Blob is the blob storage client used for large messages. This is optional. If not provided,
+	// the client runs in inline-only mode and any notification that exceeds the maximum inline
+	// size will fail with models.ErrNoBlobClient.
+	Blob BlobArgs `json:"blob,omitzero" yaml:"blob,omitempty"`
+
+	// Retry is the retry policy used by every layer that sends a notification. If set, it replaces the
+	// azcore retries of the HTTP and blob clients with the same backoff and jitter, and caps the total time
+	// spent sending each notification (including retries and hedged requests) at Retry.Budget. This keeps
+	// retries at different layers from multiplying. Zero fields use the defaults in RetryPolicy.
+	// If nil, each client uses its own azcore retry options.
+	Retry *RetryPolicy `json:"retry,omitzero" yaml:"retry,omitempty"`
+
+	// Preset is the Azure cloud environment that the client runs in. This is optional. If set, it configures
+	// the token scope and cloud authority for HTTP and Blob and validates that their endpoints belong to that
+	// environment. Use one of the values in Presets.
+	Preset *Preset `json:"-" yaml:"-"`
 
 	logger *slog.Logger
 }
 
 // toClients creates an http and storage client from the args. This also
-// validates the args.
+// validates the args. If no blob args are provided, the storage client is nil.
 func (a Args) toClients() (*http.Client, *storage.Client, error) {
 	if err := a.validate(); err != nil {
 		return nil, nil, err
@@ -285,12 +510,62 @@ func (a Args) toClients() (*http.Client, *storage.Client, error) {
 	if !a.HTTP.Compression {
 		httpOpts = append(httpOpts, http.WithoutCompression())
 	}
+	if a.HTTP.CompressionAlgorithm != "" || a.HTTP.CompressionLevel != 0 {
+		alg := cmp.Or(http.Compression(a.HTTP.CompressionAlgorithm), http.Deflate)
+		httpOpts = append(httpOpts, http.WithCompression(alg, a.HTTP.CompressionLevel))
+	}
+	if a.HTTP.CompressionThreshold > 0 {
+		httpOpts = append(httpOpts, http.WithCompressionThreshold(a.HTTP.CompressionThreshold))
+	}
+	if !a.HTTP.Conn.IsZero() {
+		httpOpts = append(httpOpts, http.WithConnOptions(a.HTTP.Conn))
+	}
+	if a.HTTP.Hedging != nil {
+		ho := *a.HTTP.Hedging
+		userHedge := ho.OnHedge
+		ho.OnHedge = func(ctx context.Context, won bool) {
+			modelmetrics.FromCtx(ctx).Hedge(ctx, won)
+			if userHedge != nil {
+				userHedge(ctx, won)
+			}
+		}
+		httpOpts = append(httpOpts, http.WithHedging(ho))
+	}
+	if a.HTTP.ReceiverPath != "" {
+		httpOpts = append(httpOpts, http.WithReceiverPath(a.HTTP.ReceiverPath))
+	}
+	if a.HTTP.Sender != nil {
+		httpOpts = append(httpOpts, http.WithSender(a.HTTP.Sender))
+	}
+	if a.Preset != nil {
+		httpOpts = append(httpOpts, http.WithScopeOverrides(map[string]string{a.Preset.Cloud.ActiveDirectoryAuthorityHost: a.Preset.Scope}))
+		a.HTTP.Opts = a.Preset.clientOptions(a.HTTP.Opts)
+		if !a.Blob.isZero() {
+			a.Blob.Opts = a.Preset.clientOptions(a.Blob.Opts)
+		}
+	}
+
+	if len(a.HTTP.ScopeOverrides) > 0 {
+		httpOpts = append(httpOpts, http.WithScopeOverrides(a.HTTP.ScopeOverrides))
+	}
+
+	if a.Retry != nil {
+		r := a.Retry.Defaults()
+		a.HTTP.Opts = retryOptions(r, a.HTTP.Opts)
+		if !a.Blob.isZero() {
+			a.Blob.Opts = retryOptions(r, a.Blob.Opts)
+		}
+	}
 
 	httpClient, err := http.New(a.HTTP.Endpoint, a.HTTP.Cred, a.HTTP.Opts, httpOpts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
+	if a.Blob.isZero() {
+		return httpClient, nil, nil
+	}
+
 	blobOpts := []storage.Option{
 		storage.WithLogger(a.logger),
 	}
@@ -301,6 +576,30 @@ func (a Args) toClients() (*http.Client, *storage.Client, error) {
 	if a.Blob.ContainerExt != "" {
 		blobOpts = append(blobOpts, storage.WithContainerExt(a.Blob.ContainerExt))
 	}
+	if a.Blob.ContainerShards > 0 {
+		blobOpts = append(blobOpts, storage.WithContainerShards(a.Blob.ContainerShards))
+	}
+	if a.Blob.LazyInit {
+		blobOpts = append(blobOpts, storage.WithLazyInit())
+	}
+	if a.Blob.SAS != nil {
+		blobOpts = append(blobOpts, storage.WithSAS(*a.Blob.SAS))
+	}
+	if a.Blob.UploadTimeout > 0 {
+		blobOpts = append(blobOpts, storage.WithUploadTimeout(a.Blob.UploadTimeout))
+	}
+	if a.Blob.ContentType != "" {
+		blobOpts = append(blobOpts, storage.WithBlobContentType(a.Blob.ContentType))
+	}
+	if a.Blob.Metadata != nil {
+		blobOpts = append(blobOpts, storage.WithBlobMetadata(a.Blob.Metadata))
+	}
+	if a.Blob.Extension != "" {
+		blobOpts = append(blobOpts, storage.WithBlobExt(a.Blob.Extension))
+	}
+	if a.Blob.NameFunc != nil {
+		blobOpts = append(blobOpts, storage.WithBlobNameFunc(a.Blob.NameFunc))
+	}
 
 	blobClient, err := storage.New(a.Blob.Endpoint, a.Blob.Cred, blobOpts...)
 	if err != nil {
@@ -315,8 +614,22 @@ func (a Args) validate() error {
 		return fmt.Errorf("invalid HTTP args: %w", err)
 	}
 
-	if err := a.Blob.validate(); err != nil {
-		return fmt.Errorf("invalid blob args: %w", err)
+	if !a.Blob.isZero() {
+		if err := a.Blob.validate(); err != nil {
+			return fmt.Errorf("invalid blob args: %w", err)
+		}
+	}
+
+	if a.Preset != nil {
+		if err := a.Preset.validate(a); err != nil {
+			return fmt.Errorf("invalid preset: %w", err)
+		}
+	}
+
+	if a.Retry != nil {
+		if err := validateRetry(*a.Retry, a.HTTP.Opts, a.Blob.Opts); err != nil {
+			return fmt.Errorf("invalid retry policy: %w", err)
+		}
 	}
 	return nil
 }
@@ -324,16 +637,75 @@ func (a Args) validate() error {
 // HTTPArgs are the arguments for creating a new ARN HTTP client.
 type HTTPArgs struct {
 	// Endpoint is the ARN endpoint.
-	Endpoint string
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
 	// Cred is the token credential to use for authentication to ARN.
-	Cred azcore.TokenCredential
+	Cred azcore.TokenCredential `json:"-" yaml:"-"`
 	// Opts are opttions for the azcore HTTP client.
-	Opts *policy.ClientOptions
-	// Compression is a flag to enable deflate compression on the HTTP client.
-	Compression bool
+	Opts *policy.ClientOptions `json:"-" yaml:"-"`
+	// Compression is a flag to enable compression on the HTTP client. Requests are compressed with deflate
+	// unless CompressionAlgorithm is set.
+	Compression bool `json:"compression,omitzero" yaml:"compression,omitempty"`
+	// CompressionAlgorithm is the Content-Encoding requests are compressed with: "deflate", "gzip" or "zstd".
+	// ARN must accept it, or it rejects every request, so only set this if the receiver supports it. Defaults
+	// to "deflate". Requires Compression.
+	CompressionAlgorithm string `json:"compressionAlgorithm,omitzero" yaml:"compressionAlgorithm,omitempty"`
+	// CompressionLevel is the compression level of CompressionAlgorithm. 0 is its default level, which is 5
+	// for deflate and gzip and 3 for zstd. deflate and gzip take -2 to 9 and zstd takes 1 to 22. Requires
+	// Compression.
+	CompressionLevel int `json:"compressionLevel,omitzero" yaml:"compressionLevel,omitempty"`
+	// CompressionThreshold is the size in bytes of the smallest request that is compressed. Smaller requests,
+	// such as small inline events, are sent as they are, as compressing them saves little and can make them
+	// larger. Defaults to 0, which compresses every request. Requires Compression.
+	CompressionThreshold int `json:"compressionThreshold,omitzero" yaml:"compressionThreshold,omitempty"`
+	// ReceiverPath is the path of the ARN receiver API that is added to Endpoint if Endpoint
+	// does not already end with it. Defaults to "/arnnotify".
+	ReceiverPath string `json:"receiverPath,omitzero" yaml:"receiverPath,omitempty"`
+	// Conn tunes the connections to ARN, such as disabling HTTP/2, TCP keep-alives and
+	// recycling connections. This cannot be used if Opts.Transport is set.
+	Conn ConnOptions `json:"conn,omitzero" yaml:"conn,omitempty"`
+	// ScopeOverrides maps the AAD authority host of a cloud, like "https://login.microsoftonline.com/", to the
+	// scope used to get tokens for ARN in that cloud. This is checked before the scopes the SDK knows and
+	// before the Preset scope, so a new or changed cloud can be configured without a new release.
+	ScopeOverrides map[string]string `json:"scopeOverrides,omitzero" yaml:"scopeOverrides,omitempty"`
+	// Hedging turns on hedged sends. If a request to ARN has not completed within a threshold
+	// (by default the P99 of recent sends), an identical request is sent and the first success is used.
+	// Hedged requests are capped and recorded in metrics. If nil, hedging is off.
+	Hedging *HedgeOptions `json:"hedging,omitzero" yaml:"hedging,omitempty"`
+	// Sender, if set, sends each request to ARN instead of the SDK's azcore pipeline, to route through a
+	// sidecar, add custom authentication or record traffic. It owns the connection and authentication, so
+	// Endpoint and Cred are not required and Opts, Compression, Conn and ScopeOverrides cannot be set.
+	// Headers, hedging and the client's retries, circuit breaker and metrics still apply, but options that
+	// add azcore policies, such as WithQuota() and WithFailureCapture(), cannot be used with it, and the
+	// receiver's clock is not measured for WithTimeSkew(). UpdateCredentials() cannot update its credential.
+	Sender transport.Sender `json:"-" yaml:"-"`
 }
 
 func (a HTTPArgs) validate() error {
+	if !a.Compression && (a.CompressionAlgorithm != "" || a.CompressionLevel != 0 || a.CompressionThreshold != 0) {
+		return fmt.Errorf("compression algorithm, level and threshold require compression")
+	}
+	if a.Compression {
+		alg := cmp.Or(http.Compression(a.CompressionAlgorithm), http.Deflate)
+		if err := http.ValidateCompression(alg, a.CompressionLevel); err != nil {
+			return err
+		}
+		if a.CompressionThreshold < 0 {
+			return fmt.Errorf("compression threshold cannot be negative")
+		}
+	}
+	if a.Sender != nil {
+		switch {
+		case a.Opts != nil:
+			return fmt.Errorf("opts cannot be set with a custom sender")
+		case a.Compression:
+			return fmt.Errorf("compression cannot be set with a custom sender")
+		case !a.Conn.IsZero():
+			return fmt.Errorf("conn cannot be set with a custom sender")
+		case len(a.ScopeOverrides) > 0:
+			return fmt.Errorf("scope overrides cannot be set with a custom sender")
+		}
+		return nil
+	}
 	if a.Endpoint == "" {
 		return fmt.Errorf("endpoint is required")
 	}
@@ -346,17 +718,70 @@ func (a HTTPArgs) validate() error {
 // BlobArgs are the arguments for creating a new ARN blob client used for large transfers.
 type BlobArgs struct {
 	// Endpoint is the blob storage endpoint.
-	Endpoint string
+	Endpoint string `json:"endpoint,omitzero" yaml:"endpoint,omitempty"`
 	// Cred is the token credential to use for authentication to blob storage.
-	Cred azcore.TokenCredential
+	Cred azcore.TokenCredential `json:"-" yaml:"-"`
 	// ContainerExt sets a name extension for a blob container. This can be useful for
 	// doing discovery of containers that are created by a particular client.
 	// Names are in the format "arm-ext-nt-YYYY-MM-DD". This will cause the client to create
 	// "arm-ext-nt-[ext]-YYYY-MM-DD". Note characters must be letters, numbers, or hyphens.
 	// Any letters will be automatically lowercased. The ext cannot be more than 41 characters.
-	ContainerExt string
+	ContainerExt string `json:"containerExt,omitzero" yaml:"containerExt,omitempty"`
+	// ContainerShards spreads the blobs uploaded each day across this many containers instead of one, for
+	// publishers that upload enough blobs to be throttled by a single container. The container of each blob
+	// is picked from its ID and named "arm-ext-nt-YYYY-MM-DD-N", with N the shard index. Consumers are not
+	// affected, as the SAS link of each blob names its container. Must be at most 100, and ContainerExt at
+	// most 38 characters with more than 10 shards. Defaults to one container a day.
+	ContainerShards int `json:"containerShards,omitzero" yaml:"containerShards,omitempty"`
 	// Opts are opttions for the azcore HTTP client.
-	Opts *policy.ClientOptions
+	Opts *policy.ClientOptions `json:"-" yaml:"-"`
+	// LazyInit delays getting the user delegation credential for blob storage until the first
+	// notification that is too large to send inline. This keeps startup from depending on storage
+	// availability or RBAC propagation right after an identity is assigned. Failures are returned
+	// on the notification that needed the blob and retried on the next one.
+	LazyInit bool `json:"lazyInit,omitzero" yaml:"lazyInit,omitempty"`
+	// SAS sets the permissions, IP range, protocol and lifetime of the SAS link ARN uses to read each blob.
+	// By default the SAS only grants Read over HTTPS from any address, for an hour. Security teams can make it
+	// shorter lived, down to MinSASExpiry.
+	SAS *SASOptions `json:"sas,omitzero" yaml:"sas,omitempty"`
+	// UploadTimeout bounds each upload to blob storage, separately from the notification's context. Use
+	// it to keep uploads short when notification contexts are long, such as with Async(). An upload that
+	// runs out of time fails the notification with an error wrapping models.ErrUploadTimeout. By default
+	// uploads are only bounded by the notification's context and Args.Retry.Budget.
+	UploadTimeout time.Duration `json:"uploadTimeout,omitzero" yaml:"uploadTimeout,omitempty"`
+	// ContentType is the Content-Type of each blob. Defaults to "application/json". Set it to
+	// "application/octet-stream", what blob storage assumes without one, for consumers that depend on the
+	// Content-Type of blobs from older versions of the SDK. Encrypted blobs are always
+	// "application/octet-stream".
+	ContentType string `json:"contentType,omitzero" yaml:"contentType,omitempty"`
+	// Metadata is set on each blob, such as to match lifecycle management rules or to find the blobs of a
+	// publisher. Names must be valid C# identifiers and values printable ASCII.
+	Metadata map[string]string `json:"metadata,omitzero" yaml:"metadata,omitempty"`
+	// Extension is the extension of each blob's name, after the ID it is uploaded with: ".txt", the legacy
+	// name that consumers that find blobs by name may depend on, or ".json". Defaults to ".txt".
+	Extension string `json:"extension,omitzero" yaml:"extension,omitempty"`
+	// NameFunc names each blob from the ID it is uploaded with, instead of the ID and Extension. It can put
+	// blobs in virtual directories with "/". Names should include the ID, as an upload replaces a blob of the
+	// same name.
+	NameFunc BlobNameFunc `json:"-" yaml:"-"`
+}
+
+// BlobNameFunc returns the name of a blob from the ID it is uploaded with. See BlobArgs.NameFunc.
+type BlobNameFunc = storage.BlobNameFunc
+
+// SASOptions configures the SAS link of each blob uploaded to blob storage. See BlobArgs.SAS.
+type SASOptions = storage.SASOptions
+
+const (
+	// MinSASExpiry is the shortest SASOptions.Expiry, so ARN can read a blob before its link expires.
+	MinSASExpiry = storage.MinSASExpiry
+	// MaxSASExpiry is the longest SASOptions.Expiry, as a link cannot outlive the key it is signed with.
+	MaxSASExpiry = storage.MaxSASExpiry
+)
+
+// isZero returns true if no blob args were provided, which indicates inline-only mode.
+func (a BlobArgs) isZero() bool {
+	return a.Endpoint == "" && a.Cred == nil && a.ContainerExt == "" && a.ContainerShards == 0 && a.Opts == nil && !a.LazyInit && a.SAS == nil && a.UploadTimeout == 0 && a.ContentType == "" && a.Metadata == nil && a.NameFunc == nil && a.Extension == ""
 }
 
 func (a BlobArgs) validate() error {
@@ -366,15 +791,45 @@ func (a BlobArgs) validate() error {
 	if a.Cred == nil {
 		return fmt.Errorf("cred is required")
 	}
+	if a.SAS != nil {
+		if err := a.SAS.Validate(); err != nil {
+			return fmt.Errorf("invalid SAS: %w", err)
+		}
+	}
+	if a.UploadTimeout < 0 {
+		return fmt.Errorf("upload timeout cannot be negative")
+	}
+	if a.ContainerShards < 0 || a.ContainerShards > storage.MaxContainerShards {
+		return fmt.Errorf("container shards must be from 0 to %d", storage.MaxContainerShards)
+	}
+	if a.ContentType != "" {
+		if err := storage.ValidateContentType(a.ContentType); err != nil {
+			return err
+		}
+	}
+	if err := storage.ValidateMetadata(a.Metadata); err != nil {
+		return err
+	}
+	if a.Extension != "" {
+		if err := storage.ValidateBlobExt(a.Extension); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // New creates a new ARN client.
-func New(ctx context.Context, args Args, options ...Option) (*ARN, error) {
+func New(ctx context.Context, args Args, options ...Option) (_ *ARN, err error) {
 	a := &ARN{
 		errs:            make(chan error, 1),
 		sigSenderClosed: make(chan struct{}),
 	}
+	// The rate coordinator, spool and conn.Service are started before the checks that follow them.
+	defer func() {
+		if err != nil {
+			a.closeStarted()
+		}
+	}()
 
 	for _, o := range options {
 		if err := o(a); err != nil {
@@ -384,8 +839,79 @@ func New(ctx context.Context, args Args, options ...Option) (*ARN, error) {
 	if a.in == nil {
 		a.in = make(chan models.Notifications, 1)
 	}
+	if a.metrics == nil {
+		a.metrics = modelmetrics.Default()
+	}
+	if a.batchBucket > 0 {
+		if a.batcher == nil {
+			return nil, fmt.Errorf("WithBatchTimeBucket() requires WithBatching()")
+		}
+		a.batcher.bucket = a.batchBucket
+	}
+	if a.batcher != nil {
+		if err := a.batcher.init(a, a.maxItems); err != nil {
+			return nil, err
+		}
+	}
 
 	args.logger = a.logger
+	log := a.logger
+	if log == nil {
+		log = slog.Default()
+	}
+	if a.secretOpts != nil {
+		var err error
+		args, err = a.resolveSecrets(ctx, args, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if name, ok := args.secretRef(); ok {
+		return nil, fmt.Errorf("Args.%s is a Key Vault reference, use WithSecretResolution() to resolve it", name)
+	}
+	// These add azcore policies to HTTP.Opts, which a custom Sender does not use.
+	if args.HTTP.Sender != nil && a.fakeSender == nil {
+		switch {
+		case a.quotaOpts != nil:
+			return nil, fmt.Errorf("WithQuota() cannot be used with a custom HTTPArgs.Sender")
+		case a.rateOpts != nil:
+			return nil, fmt.Errorf("WithRateCoordination() cannot be used with a custom HTTPArgs.Sender")
+		case a.faults != nil && !a.faults.HTTP.IsZero():
+			return nil, fmt.Errorf("WithFaultInjection() for HTTP cannot be used with a custom HTTPArgs.Sender")
+		case a.captureOpts != nil:
+			return nil, fmt.Errorf("WithFailureCapture() cannot be used with a custom HTTPArgs.Sender")
+		}
+	}
+	if a.quotaOpts != nil && a.fakeSender == nil {
+		var err error
+		args, err = args.withQuota(*a.quotaOpts, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.rateOpts != nil && a.fakeSender == nil {
+		var err error
+		args, a.rateCoord, err = args.withRateCoordination(*a.rateOpts, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.faults != nil && a.fakeSender == nil {
+		log.Warn("fault injection is on, requests to ARN and blob storage will fail on purpose")
+		var err error
+		args, err = args.withFaults(*a.faults)
+		if err != nil {
+			return nil, fmt.Errorf("problem with fault injection: %v", err)
+		}
+	}
+	// This is added after the faults, so injected faults that never reach the wire are not captured.
+	if a.captureOpts != nil && a.fakeSender == nil {
+		var err error
+		args, a.capture, err = args.withCapture(*a.captureOpts)
+		if err != nil {
+			return nil, fmt.Errorf("problem with failure capture: %v", err)
+		}
+	}
 
 	var h *http.Client
 	var s *storage.Client
@@ -407,18 +933,113 @@ func New(ctx context.Context, args Args, options ...Option) (*ARN, error) {
 		}
 	}
 
-	var err error
-	a.conn, err = conn.New(h, s, a.errs, conn.WithLogger(a.logger))
+	a.http = h
+	a.store = s
+
+	connOpts := []conn.Option{conn.WithLogger(a.logger)}
+	if a.maxItems > 0 {
+		connOpts = append(connOpts, conn.WithMaxItems(a.maxItems))
+	}
+	if a.blobCanary > 0 {
+		if s == nil {
+			return nil, fmt.Errorf("WithBlobCanary() requires blob storage, Args.Blob is not set")
+		}
+		connOpts = append(connOpts, conn.WithBlobCanary(a.blobCanary))
+	}
+	if a.skew != nil {
+		connOpts = append(connOpts, conn.WithTimeSkew(*a.skew))
+	}
+	if a.provenance != nil {
+		connOpts = append(connOpts, conn.WithProvenance(*a.provenance))
+	}
+	if a.classify != nil {
+		connOpts = append(connOpts, conn.WithClassification(*a.classify))
+	}
+	if a.propLimits != nil {
+		connOpts = append(connOpts, conn.WithPropertyLimits(*a.propLimits))
+	}
+	if a.preview != nil {
+		connOpts = append(connOpts, conn.WithPreviewVersions(*a.preview))
+	}
+	if a.maxResourceBytes > 0 {
+		connOpts = append(connOpts, conn.WithMaxResourceBytes(a.maxResourceBytes))
+	}
+	if a.tracerProvider != nil {
+		connOpts = append(connOpts, conn.WithTracerProvider(a.tracerProvider))
+	}
+	if a.deadLetters != nil {
+		connOpts = append(connOpts, conn.WithDeadLetter(a.deadLetters))
+	}
+	if a.journal != nil {
+		connOpts = append(connOpts, conn.WithJournal(a.journal))
+	}
+	if a.encrypt != nil {
+		connOpts = append(connOpts, conn.WithEncryption(a.encrypt))
+	}
+	if a.leader != nil {
+		connOpts = append(connOpts, conn.WithLeader(*a.leader))
+	}
+	if a.slo != nil {
+		connOpts = append(connOpts, conn.WithSLO(*a.slo))
+	}
+	if a.state != nil {
+		connOpts = append(connOpts, conn.WithStateCache(a.state))
+	}
+	if a.slowOpts != nil {
+		a.slow, err = timing.NewSampler(*a.slowOpts)
+		if err != nil {
+			return nil, fmt.Errorf("problem with slow send sampling: %v", err)
+		}
+		connOpts = append(connOpts, conn.WithSlowSends(a.slow))
+	}
+	if a.resend != nil {
+		connOpts = append(connOpts, conn.WithResend(a.resend.policy, a.resend.maxAttempts))
+	}
+	if a.breaker != nil {
+		connOpts = append(connOpts, conn.WithBreaker(a.breaker))
+	}
+	if a.concurrency != nil {
+		connOpts = append(connOpts, conn.WithConcurrency(a.concurrency.n, a.concurrency.order))
+	}
+	if args.Retry != nil {
+		connOpts = append(connOpts, conn.WithSendBudget(args.Retry.Defaults().Budget))
+	}
+	// A nil *storage.Client must be passed as a nil interface, which is how conn knows it is inline-only.
+	var store models.PayloadStore
+	if s != nil {
+		store = s
+	}
+	var sender models.EventSender = h
+	if a.spoolDir != "" {
+		a.spool, err = spool.New(a.spoolDir, h, spool.Options{Logger: a.logger})
+		if err != nil {
+			return nil, fmt.Errorf("problem with the spool: %v", err)
+		}
+		sender = a.spool
+	}
+	a.conn, err = conn.New(sender, store, a.errs, connOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("problem with conn client: %v", err)
 	}
 
 	if a.meterProvider != nil {
-		if err := modelmetrics.Init(a.meterProvider.Meter("arn")); err != nil {
+		var mopts []modelmetrics.Option
+		if a.buckets != nil {
+			mopts = append(mopts, modelmetrics.WithBuckets(*a.buckets))
+		}
+		if err := a.metrics.Init(a.meterProvider.Meter("arn"), mopts...); err != nil {
 			return nil, err
 		}
 	}
 
+	if a.watchdogAfter > 0 {
+		a.watchdog, err = watchdog.New(a.watchdogAfter, a.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a.startSecretRefresh()
 	go a.sender()
 
 	return a, nil
@@ -426,6 +1047,9 @@ func New(ctx context.Context, args Args, options ...Option) (*ARN, error) {
 
 // Close closes the client. This will close the In() channel.
 func (a *ARN) Close() {
+	if a.batcher != nil {
+		a.batcher.close()
+	}
 	close(a.in)
 
 	if a.sigSenderClosed != nil {
@@ -434,10 +1058,56 @@ func (a *ARN) Close() {
 			a.conn.Close()
 		}
 	}
+	a.closeComponents()
+}
+
+// closeStarted stops what New() started before it failed. The sender has not been started, so unlike
+// Close() it does not wait for it.
+func (a *ARN) closeStarted() {
+	if a.conn != nil {
+		a.conn.Close()
+	}
+	a.closeComponents()
+}
+
+// closeComponents stops what runs beside the sender and the conn. It is called by Close() and Drain() once
+// the conn is closed.
+func (a *ARN) closeComponents() {
+	if a.spool != nil {
+		a.spool.Close()
+	}
+	if a.watchdog != nil {
+		a.watchdog.Close()
+	}
+	a.closeRateCoord()
+	a.closeSecrets()
+}
+
+// UpdateCredentials replaces the credentials used to talk to ARN (httpCred) and blob storage (blobCred)
+// without restarting the client. This is useful for workloads that rotate certificates or switch identities.
+// Either credential can be nil to leave that credential unchanged. Requests that are in-flight finish with the
+// old credential, new requests use the replacement. Thread-safe.
+func (a *ARN) UpdateCredentials(httpCred, blobCred azcore.TokenCredential) error {
+	if httpCred != nil {
+		if err := a.http.UpdateCredential(httpCred); err != nil {
+			return fmt.Errorf("problem updating HTTP credential: %w", err)
+		}
+	}
+	if blobCred != nil {
+		if a.store == nil {
+			return fmt.Errorf("cannot update blob credential: client is in inline-only mode")
+		}
+		if err := a.store.UpdateCredential(blobCred); err != nil {
+			return fmt.Errorf("problem updating blob credential: %w", err)
+		}
+	}
+	return nil
 }
 
 // Errors returns a channel that will receive any errors that occur in the client where a
-// promise is not used. If using Notify(), this will not be used.
+// promise is not used. If using Notify(), this will not be used. If the channel is full, the
+// error is dropped. Dropped errors are counted with the error_dropped_total metric and logged,
+// at most once every 10 seconds, with the event ID and subject of the notification.
 func (a *ARN) Errors() <-chan error {
 	return a.errs
 }
@@ -445,33 +1115,48 @@ func (a *ARN) Errors() <-chan error {
 // Notify sends a notification to the ARN service. This is similar to sending via Async(),
 // however this will block until the notification is sent and returns any error. In reality, this
 // is a thin wrapper around Async() that uses a promise to send the results.
-// If the context is canceled, this will return the context error. Thread-safe (however, order usually matters
-// in ARN).
+// The context is used both for sending and for waiting on the result. If the context ends before the result
+// is available, this returns an error wrapping models.ErrPromiseTimeout (deadline passed) or
+// models.ErrPromiseCanceled (cancelled), which can be told apart from a send failure with errors.Is().
+// Thread-safe (however, order usually matters in ARN).
 func (a *ARN) Notify(ctx context.Context, n models.Notifications) error {
 	x := n.DataCount()
-	switch {
-	case x == 0:
+	if x == 0 {
 		return nil
-	case x > maxvals.NotificationItems:
-		return models.ErrBatchSize
 	}
-
-	n = n.SetCtx(ctx)
-	n = n.SetPromise(conn.PromisePool.Get().(chan error))
-	defer n.Recycle()
-	modelmetrics.ActivePromise(context.Background())
+	if err := conn.CheckItems(x, a.maxItems); err != nil {
+		return err
+	}
 
 	if ctx.Err() != nil {
-		return ctx.Err()
+		return models.WaitError(ctx)
 	}
 
+	// The promise never leaves Notify(), so it is recycled here once the sender is done with it.
+	p := conn.NewPromise()
+	n = n.SetCtx(modelmetrics.WithRegistry(ctx, a.metrics))
+	n = n.SetPromise(p)
+	a.metrics.ActivePromise(context.Background())
+
+	n = a.track(n)
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		watchdog.Finish(n.Ctx())
+		// The notification was never queued, so the promise can be reused.
+		conn.RecyclePromise(p)
+		err := models.WaitError(ctx)
+		a.metrics.Promise(context.Background(), err)
+		return err
 	case a.in <- n:
 	}
 
-	return n.Promise(context.Background())
+	err := n.Promise(ctx)
+	if errors.Is(err, models.ErrPromiseTimeout) || errors.Is(err, models.ErrPromiseCanceled) {
+		// The sender still owns the promise and will send the result on it later.
+		return err
+	}
+	conn.RecyclePromise(p)
+	return err
 }
 
 // Async sends a notification to the ARN service asynchronously. This will not block waiting for a response.
@@ -479,21 +1164,22 @@ func (a *ARN) Notify(ctx context.Context, n models.Notifications) error {
 // to the ARN.Errors() channel. The returned Notification will have the Promise set if promise == true.
 // NOTE: If you don't use the returned Notification for a Promise instead of the one you passed, you
 // will not get the results.
+// With WithBatching(), the notification may be held for a short time and sent in one event with others.
 // Thread-safe.
 func (a *ARN) Async(ctx context.Context, n models.Notifications, promise bool) models.Notifications {
-	n = n.SetCtx(ctx)
+	n = n.SetCtx(modelmetrics.WithRegistry(ctx, a.metrics))
 	if promise {
-		n = n.SetPromise(conn.PromisePool.Get().(chan error))
-		modelmetrics.ActivePromise(context.Background())
+		n = n.SetPromise(conn.NewPromise())
+		a.metrics.ActivePromise(context.Background())
 	}
 
 	x := n.DataCount()
-	switch {
-	case x == 0:
+	if x == 0 {
 		n.SendPromise(nil, a.errs)
 		return n
-	case x > maxvals.NotificationItems:
-		n.SendPromise(models.ErrBatchSize, a.errs)
+	}
+	if err := conn.CheckItems(x, a.maxItems); err != nil {
+		n.SendPromise(err, a.errs)
 		return n
 	}
 
@@ -501,10 +1187,15 @@ func (a *ARN) Async(ctx context.Context, n models.Notifications, promise bool) m
 		n.SendPromise(ctx.Err(), a.errs)
 		return n
 	}
+	if a.batcher != nil && a.batcher.add(n) {
+		return n
+	}
 
+	n = a.track(n)
 	select {
 	case <-ctx.Done():
 		n.SendPromise(ctx.Err(), a.errs)
+		watchdog.Finish(n.Ctx())
 		return n
 	case a.in <- n:
 	}
@@ -512,15 +1203,30 @@ func (a *ARN) Async(ctx context.Context, n models.Notifications, promise bool) m
 	return n
 }
 
+// track starts the timing of the notification, adds a progress tracker to it if promise extension is
+// enabled and starts watchdog tracking of it if the watchdog is enabled.
+func (a *ARN) track(n models.Notifications) models.Notifications {
+	n = n.SetCtx(timing.Start(n.Ctx()))
+	if a.extension != nil {
+		n = n.SetCtx(progress.WithTracker(n.Ctx(), progress.New(*a.extension)))
+	}
+	if a.watchdog == nil {
+		return n
+	}
+	return n.SetCtx(a.watchdog.Track(n.Ctx()))
+}
+
 // sender loops on our input channel and sends notifications to the ARN service.
 func (a *ARN) sender() {
 	defer close(a.sigSenderClosed)
 
-	for n := range a.in {
-		if a.testConn != nil {
-			a.testConn(n)
-			continue
+	pprof.Do(context.Background(), pprof.Labels(build.PprofLabel, "client.sender"), func(context.Context) {
+		for n := range a.in {
+			if a.testConn != nil {
+				a.testConn(n)
+				continue
+			}
+			a.conn.Send(n)
 		}
-		a.conn.Send(n)
-	}
+	})
 }
@@ -3,14 +3,24 @@ package client
 import (
 	"context"
 	"errors"
+	"net/url"
+	"runtime"
 	"testing"
+	"time"
 
-	"github.com/Azure/arn-sdk/internal/conn"
-	"github.com/Azure/arn-sdk/internal/conn/http"
 	"github.com/Azure/arn-sdk/internal/conn/maxvals"
-	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/internal/conn/tracing"
 	"github.com/Azure/arn-sdk/models"
+	modelmetrics "github.com/Azure/arn-sdk/models/metrics"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestHTTPArgsValidate(t *testing.T) {
@@ -44,6 +54,92 @@ func TestHTTPArgsValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Error: compression algorithm without compression",
+			args: func() HTTPArgs {
+				args := copyStruct(valid)
+				args.Compression = false
+				args.CompressionAlgorithm = "gzip"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: unsupported compression algorithm",
+			args: func() HTTPArgs {
+				args := copyStruct(valid)
+				args.Compression = true
+				args.CompressionAlgorithm = "br"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: compression level out of range",
+			args: func() HTTPArgs {
+				args := copyStruct(valid)
+				args.Compression = true
+				args.CompressionAlgorithm = "zstd"
+				args.CompressionLevel = 23
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: negative compression threshold",
+			args: func() HTTPArgs {
+				args := copyStruct(valid)
+				args.Compression = true
+				args.CompressionThreshold = -1
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "zstd compression with a threshold",
+			args: func() HTTPArgs {
+				args := copyStruct(valid)
+				args.Compression = true
+				args.CompressionAlgorithm = "zstd"
+				args.CompressionLevel = 6
+				args.CompressionThreshold = 1024
+				return args
+			},
+		},
+		{
+			name: "Error: custom sender with opts",
+			args: func() HTTPArgs {
+				return HTTPArgs{Sender: &recordSender{}, Opts: &policy.ClientOptions{}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: custom sender with compression",
+			args: func() HTTPArgs {
+				return HTTPArgs{Sender: &recordSender{}, Compression: true}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: custom sender with conn options",
+			args: func() HTTPArgs {
+				return HTTPArgs{Sender: &recordSender{}, Conn: ConnOptions{DisableHTTP2: true}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: custom sender with scope overrides",
+			args: func() HTTPArgs {
+				return HTTPArgs{Sender: &recordSender{}, ScopeOverrides: map[string]string{"a": "b"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom sender without endpoint or cred",
+			args: func() HTTPArgs {
+				return HTTPArgs{Sender: &recordSender{}}
+			},
+		},
 		{
 			name: "valid",
 			args: func() HTTPArgs {
@@ -98,12 +194,102 @@ func TestBlobArgs(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Error: invalid SAS",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.SAS = &SASOptions{Permissions: "rw"}
+				return args
+			},
+			wantErr: true,
+		},
 		{
 			name: "Valid",
 			args: func() BlobArgs {
 				return valid
 			},
 		},
+		{
+			name: "Error: negative upload timeout",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.UploadTimeout = -time.Second
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: SAS expiry below the minimum",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.SAS = &SASOptions{Expiry: time.Minute}
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid with SAS",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.SAS = &SASOptions{Permissions: "rl", IPRange: "203.0.113.0-203.0.113.255"}
+				return args
+			},
+		},
+		{
+			name: "Error: too many container shards",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.ContainerShards = 101
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid with container shards",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.ContainerShards = 8
+				return args
+			},
+		},
+		{
+			name: "Error: invalid content type",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.ContentType = "json;"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: invalid metadata name",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.Metadata = map[string]string{"tier-hint": "cool"}
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: invalid blob extension",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.Extension = "json"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid with blob content type, metadata and names",
+			args: func() BlobArgs {
+				args := copyStruct(valid)
+				args.ContentType = "application/json; charset=utf-8"
+				args.Metadata = map[string]string{"publisher": "aks"}
+				args.Extension = ".json"
+				args.NameFunc = func(id string) string { return "aks/" + id + ".json" }
+				return args
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -121,6 +307,92 @@ func TestBlobArgs(t *testing.T) {
 	}
 }
 
+func TestArgsValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := Args{
+		HTTP: HTTPArgs{
+			Endpoint: "http://localhost:8080",
+			Cred:     struct{ azcore.TokenCredential }{},
+		},
+		Blob: BlobArgs{
+			Endpoint: "http://localhost:8080",
+			Cred:     struct{ azcore.TokenCredential }{},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		args    func() Args
+		wantErr bool
+	}{
+		{
+			name: "Error: blob args partially set",
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Blob.Cred = nil
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Inline-only: no blob args",
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Blob = BlobArgs{}
+				return args
+			},
+		},
+		{
+			name: "Retry policy with defaults",
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Retry = &RetryPolicy{}
+				return args
+			},
+		},
+		{
+			name: "Error: invalid retry policy",
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Retry = &RetryPolicy{Jitter: 2}
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error: retry policy and azcore retry options both set",
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Retry = &RetryPolicy{}
+				args.HTTP.Opts = &policy.ClientOptions{Retry: policy.RetryOptions{MaxRetries: 5}}
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid",
+			args: func() Args {
+				return valid
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.args().validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestArgsValidate(%s): got nil, want error", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestArgsValidate(%s): got %s, want nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+	}
+}
+
 type fakeNotify struct {
 	ctx context.Context
 	models.Notifications
@@ -137,10 +409,6 @@ func newFakeNotify(ctx context.Context, count int, eventErr bool) fakeNotify {
 	}
 }
 
-func (f fakeNotify) Recycle() {
-	conn.PromisePool.Put(f.ch)
-}
-
 func (f fakeNotify) SetCtx(ctx context.Context) models.Notifications {
 	f.ctx = ctx
 	return f
@@ -175,10 +443,15 @@ func (f fakeNotify) SendPromise(e error, backupCh chan error) {
 }
 
 func (f fakeNotify) Promise(ctx context.Context) error {
-	return <-f.ch
+	select {
+	case <-ctx.Done():
+		return models.WaitError(ctx)
+	case e := <-f.ch:
+		return e
+	}
 }
 
-func (f fakeNotify) SendEvent(h *http.Client, s *storage.Client) error {
+func (f fakeNotify) SendEvent(h models.EventSender, s models.PayloadStore) error {
 	if f.eventErr {
 		return errors.New("event error")
 	}
@@ -195,12 +468,17 @@ func TestNotify(t *testing.T) {
 	cancelCtx, cancel := context.WithCancel(context.Background())
 	cancel()
 
+	waitCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
 	tests := []struct {
 		name     string
 		ctx      context.Context
 		n        models.Notifications
+		maxItems int
 		connSend func(n models.Notifications)
 		wantErr  bool
+		wantIs   error
 	}{
 		{
 			name: "Datacount is zero",
@@ -212,12 +490,40 @@ func TestNotify(t *testing.T) {
 			ctx:     context.Background(),
 			n:       newFakeNotify(nil, maxvals.NotificationItems+1, false),
 			wantErr: true,
+			wantIs:  models.ErrBatchSize,
+		},
+		{
+			name:     "Datacount is > maxvals.NotificationItems, but limit raised",
+			ctx:      context.Background(),
+			n:        newFakeNotify(nil, maxvals.NotificationItems+1, false),
+			maxItems: 2 * maxvals.NotificationItems,
+			connSend: func(n models.Notifications) {
+				n.SendPromise(nil, nil)
+			},
 		},
 		{
 			name:    "Error: context is cancelled",
 			ctx:     cancelCtx,
 			n:       newFakeNotify(nil, 1, false),
 			wantErr: true,
+			wantIs:  models.ErrPromiseCanceled,
+		},
+		{
+			name:     "Error: context deadline passes while waiting on the result",
+			ctx:      waitCtx,
+			n:        newFakeNotify(nil, 1, false),
+			connSend: func(n models.Notifications) {},
+			wantErr:  true,
+			wantIs:   models.ErrPromiseTimeout,
+		},
+		{
+			name: "Error: send failure",
+			ctx:  context.Background(),
+			n:    newFakeNotify(nil, 1, false),
+			connSend: func(n models.Notifications) {
+				n.SendPromise(errors.New("send failure"), nil)
+			},
+			wantErr: true,
 		},
 		{
 			name: "Success",
@@ -234,6 +540,7 @@ func TestNotify(t *testing.T) {
 			testConn:        test.connSend,
 			in:              make(chan models.Notifications, 1),
 			sigSenderClosed: make(chan struct{}),
+			maxItems:        test.maxItems,
 		}
 		go a.sender()
 		defer a.Close()
@@ -247,6 +554,12 @@ func TestNotify(t *testing.T) {
 			t.Errorf("TestNotify(%s): got %s, want nil", test.name, err)
 			continue
 		case err != nil:
+			if test.wantIs != nil && !errors.Is(err, test.wantIs) {
+				t.Errorf("TestNotify(%s): got %s, want errors.Is(err, %s)", test.name, err, test.wantIs)
+			}
+			if test.wantIs == nil && (errors.Is(err, models.ErrPromiseTimeout) || errors.Is(err, models.ErrPromiseCanceled)) {
+				t.Errorf("TestNotify(%s): got wait error %s, want send error", test.name, err)
+			}
 			continue
 		}
 	}
@@ -358,3 +671,335 @@ func TestAsync(t *testing.T) {
 func copyStruct[T any](a T) T {
 	return a
 }
+
+type fakeSender struct{}
+
+func (fakeSender) Send(ctx context.Context, event []byte) error { return nil }
+
+type fakeUploader struct{}
+
+func (fakeUploader) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	return url.Parse("https://blob/" + id)
+}
+
+func TestWithBlobCanary(t *testing.T) {
+	t.Parallel()
+
+	inlineOnly := Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: struct{ azcore.TokenCredential }{}}}
+
+	tests := []struct {
+		name    string
+		n       int
+		args    Args
+		fake    bool
+		wantErr bool
+	}{
+		{name: "With blob storage", n: 100, fake: true},
+		{name: "Error: interval is 0", n: 0, fake: true, wantErr: true},
+		{name: "Error: inline-only", n: 100, args: inlineOnly, wantErr: true},
+	}
+
+	for _, test := range tests {
+		options := []Option{WithBlobCanary(test.n)}
+		if test.fake {
+			options = append(options, WithFakeClients(fakeSender{}, fakeUploader{}))
+		}
+		a, err := New(context.Background(), test.args, options...)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithBlobCanary(%s): got err == nil, want err != nil", test.name)
+			a.Close()
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithBlobCanary(%s): got err == %s, want err == nil", test.name, err)
+		case err == nil:
+			a.Close()
+		}
+	}
+}
+
+// failingMeterProvider provides a meter that cannot create instruments.
+type failingMeterProvider struct {
+	noop.MeterProvider
+}
+
+func (failingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return failingMeter{}
+}
+
+type failingMeter struct {
+	noop.Meter
+}
+
+func (failingMeter) Int64Counter(string, ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return nil, errors.New("no counters")
+}
+
+// TestNewCloseOnError is not parallel, so the goroutines it counts are its own.
+func TestNewCloseOnError(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// The metrics fail after the spool and conn.Service are started.
+	_, err := New(
+		context.Background(),
+		Args{},
+		WithFakeClients(fakeSender{}, fakeUploader{}),
+		WithSpoolDir(t.TempDir()),
+		WithMeterProvider(failingMeterProvider{}),
+	)
+	if err == nil {
+		t.Fatalf("TestNewCloseOnError: got err == nil, want err != nil")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("TestNewCloseOnError: got %d goroutines after New() failed, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWithPromiseExtension(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		idle    time.Duration
+		max     time.Duration
+		wantErr bool
+	}{
+		{name: "Valid", idle: time.Second, max: time.Minute},
+		{name: "Error: idle is 0", max: time.Minute, wantErr: true},
+		{name: "Error: max less than idle", idle: time.Minute, max: time.Second, wantErr: true},
+	}
+
+	for _, test := range tests {
+		a, err := New(context.Background(), Args{}, WithPromiseExtension(test.idle, test.max), WithFakeClients(fakeSender{}, fakeUploader{}))
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithPromiseExtension(%s): got err == nil, want err != nil", test.name)
+			a.Close()
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithPromiseExtension(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		n := a.Async(context.Background(), validNotification(t), true)
+		if progress.FromCtx(n.Ctx()) == nil {
+			t.Errorf("TestWithPromiseExtension(%s): notification has no progress tracker", test.name)
+		}
+		if err := n.Promise(context.Background()); err != nil {
+			t.Errorf("TestWithPromiseExtension(%s): got err == %s, want err == nil", test.name, err)
+		}
+		a.Close()
+	}
+}
+
+func TestWithStateCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	a, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithStateCache: New() error: %v", err)
+	}
+	if _, err := a.Seen(ctx, validNotification(t)); err == nil {
+		t.Errorf("TestWithStateCache: Seen() without WithStateCache(): got err == nil, want err != nil")
+	}
+	a.Close()
+
+	a, err = New(ctx, Args{}, WithStateCache(nil), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithStateCache: New() error: %v", err)
+	}
+	defer a.Close()
+
+	n := validNotification(t)
+	if seen, err := a.Seen(ctx, n); seen || err != nil {
+		t.Errorf("TestWithStateCache: Seen() before Notify(): got %v, %v, want false, nil", seen, err)
+	}
+	if err := a.Notify(ctx, n); err != nil {
+		t.Fatalf("TestWithStateCache: Notify() error: %v", err)
+	}
+	if seen, err := a.Seen(ctx, n); !seen || err != nil {
+		t.Errorf("TestWithStateCache: Seen() after Notify(): got %v, %v, want true, nil", seen, err)
+	}
+	for r := range n.Resources() {
+		if _, ok, err := a.LastDelivered(ctx, r.ID); !ok || err != nil {
+			t.Errorf("TestWithStateCache: LastDelivered(%s): got ok == %v, err == %v, want true, nil", r.ID, ok, err)
+		}
+	}
+}
+
+func TestWithSLO(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithSLO(SLOOptions{Objective: 1}), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithSLO: New() with Objective 1: got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, Args{}, WithSLO(SLOOptions{Objective: 0.999}), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithSLO: New() error: %v", err)
+	}
+	defer a.Close()
+
+	if err := a.Notify(ctx, validNotification(t)); err != nil {
+		t.Fatalf("TestWithSLO: Notify() error: %v", err)
+	}
+	st := a.Stats().SLO
+	if st == nil {
+		t.Fatalf("TestWithSLO: got Stats().SLO == nil, want SLO status")
+	}
+	if st.Total != 1 || st.Failed != 0 || st.Burning {
+		t.Errorf("TestWithSLO: got Total %d, Failed %d, Burning %v, want 1, 0, false", st.Total, st.Failed, st.Burning)
+	}
+}
+
+func TestWithMetricBuckets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		buckets MetricBuckets
+		wantErr bool
+	}{
+		{name: "Valid", buckets: MetricBuckets{Latency: []float64{10, 100}, Size: []float64{1024}}},
+		{name: "Error: boundaries not increasing", buckets: MetricBuckets{Size: []float64{1024, 1}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		a, err := New(
+			context.Background(),
+			Args{},
+			WithMeterProvider(noop.NewMeterProvider()),
+			WithMetricBuckets(test.buckets),
+			WithFakeClients(fakeSender{}, fakeUploader{}),
+		)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithMetricBuckets(%s): got err == nil, want err != nil", test.name)
+			a.Close()
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithMetricBuckets(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		a.Close()
+	}
+}
+
+func TestWithMetricsRegistry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithMetricsRegistry(nil), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithMetricsRegistry: nil registry: got err == nil, want err != nil")
+	}
+
+	// Each client records to its own registry and meter, not to the other's.
+	var readers []*sdkmetric.ManualReader
+	for range 2 {
+		reader := sdkmetric.NewManualReader()
+		a, err := New(
+			ctx,
+			Args{},
+			WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+			WithMetricsRegistry(modelmetrics.NewRegistry()),
+			WithFakeClients(fakeSender{}, fakeUploader{}),
+		)
+		if err != nil {
+			t.Fatalf("TestWithMetricsRegistry: New(): got err == %s, want err == nil", err)
+		}
+		if err := a.Notify(ctx, validNotification(t)); err != nil {
+			t.Fatalf("TestWithMetricsRegistry: Notify(): got err == %s, want err == nil", err)
+		}
+		a.Close()
+		readers = append(readers, reader)
+	}
+
+	for i, reader := range readers {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatalf("TestWithMetricsRegistry: Collect(): got err == %s, want err == nil", err)
+		}
+		var sent int64
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "arn-sdk_event_sent_total" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					sent += dp.Value
+				}
+			}
+		}
+		if sent != 1 {
+			t.Errorf("TestWithMetricsRegistry: client %d: got %d events sent, want 1", i, sent)
+		}
+	}
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithTracerProvider(nil), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithTracerProvider(nil provider): got err == nil, want err != nil")
+	}
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	a, err := New(ctx, Args{}, WithTracerProvider(tp), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithTracerProvider: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	ctx, parent := tp.Tracer("test").Start(ctx, "caller")
+	n := validNotification(t)
+	if err := a.Notify(ctx, n); err != nil {
+		t.Fatalf("TestWithTracerProvider: Notify(): got err == %s, want err == nil", err)
+	}
+	parent.End()
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		byName[s.Name()] = s
+	}
+	span, ok := byName[tracing.SpanName]
+	if !ok {
+		t.Fatalf("TestWithTracerProvider: got no %s span", tracing.SpanName)
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("TestWithTracerProvider: the notification span is not a child of the caller's span")
+	}
+	for _, name := range []string{"arn.marshal", "arn.sendHTTP"} {
+		s, ok := byName[name]
+		switch {
+		case !ok:
+			t.Errorf("TestWithTracerProvider: got no %s span", name)
+		case s.Parent().SpanID() != span.SpanContext().SpanID():
+			t.Errorf("TestWithTracerProvider: the %s span is not a child of the notification span", name)
+		}
+	}
+	var id string
+	for _, kv := range span.Attributes() {
+		if kv.Key == tracing.AttrEventID {
+			id = kv.Value.AsString()
+		}
+	}
+	if id == "" {
+		t.Errorf("TestWithTracerProvider: the notification span has no %s attribute", tracing.AttrEventID)
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/Azure/arn-sdk/internal/conn"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+// OrderKey returns the key of the subject, such as a resource or subscription, whose notifications must be
+// sent in order, and false if a notification can be sent in any order. See WithConcurrency().
+type OrderKey = conn.OrderKey
+
+// WithConcurrency sends up to n notifications at once instead of one at a time, so that a slow send, such as
+// a large blob upload, does not hold up the notifications behind it. n must be from 1 to 256, and 1 is the
+// default.
+//
+// ARN applies notifications for a resource in the order it receives them, so concurrent sends can reorder
+// changes. If order is set, notifications with the same key are sent one at a time in the order they were
+// given to the client, and notifications with different keys are sent concurrently. A notification whose
+// subject is being sent waits for it, holding up the notifications behind it. Use OrderByResource or
+// OrderBySubscription, or your own OrderKey. If order is nil, notifications are sent in any order.
+func WithConcurrency(n int, order OrderKey) Option {
+	return func(c *ARN) error {
+		c.concurrency = &concurrencyOpts{n: n, order: order}
+		return nil
+	}
+}
+
+// concurrencyOpts are the options set with WithConcurrency().
+type concurrencyOpts struct {
+	n     int
+	order OrderKey
+}
+
+// OrderByResource is an OrderKey that keeps the notifications for each resource in order. The key is the ID
+// of the first resource in the notification, so notifications with several resources should hold the
+// changes of one resource.
+func OrderByResource(n models.Notifications) (string, bool) {
+	for r := range n.Resources() {
+		return strings.ToLower(r.ID), r.ID != ""
+	}
+	return "", false
+}
+
+// OrderBySubscription is an OrderKey that keeps the notifications for each subscription in order, by the
+// subscription of the first resource in the notification.
+func OrderBySubscription(n models.Notifications) (string, bool) {
+	for r := range n.Resources() {
+		id, err := arm.ParseResourceID(r.ID)
+		if err != nil || id.SubscriptionID == "" {
+			return "", false
+		}
+		return strings.ToLower(id.SubscriptionID), true
+	}
+	return "", false
+}
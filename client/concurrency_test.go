@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithConcurrency(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithConcurrency(0, nil), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithConcurrency(0): got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, Args{}, WithConcurrency(4, OrderByResource), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithConcurrency: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	for i := 0; i < 8; i++ {
+		if err := a.Notify(ctx, validNotification(t)); err != nil {
+			t.Errorf("TestWithConcurrency: Notify() %d: got err == %s, want err == nil", i, err)
+		}
+	}
+}
+
+func TestOrderKeys(t *testing.T) {
+	t.Parallel()
+
+	n := validNotification(t)
+	if got, ok := OrderByResource(n); !ok || got != "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourcegroups/rg/providers/microsoft.containerservice/managedclusters/cluster" {
+		t.Errorf("TestOrderKeys: OrderByResource(): got %q, %v, want the lowercased resource ID", got, ok)
+	}
+	if got, ok := OrderBySubscription(n); !ok || got != "26fe00f8-9173-4872-9134-bb1d2e00343a" {
+		t.Errorf("TestOrderKeys: OrderBySubscription(): got %q, %v, want the subscription ID", got, ok)
+	}
+
+	n.Data = nil
+	if _, ok := OrderByResource(n); ok {
+		t.Errorf("TestOrderKeys: OrderByResource() without resources: got ok, want !ok")
+	}
+	if _, ok := OrderBySubscription(n); ok {
+		t.Errorf("TestOrderKeys: OrderBySubscription() without resources: got ok, want !ok")
+	}
+}
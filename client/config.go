@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"gopkg.in/yaml.v3"
+)
+
+// CredentialType is the type of credential named in a config file read by ArgsFromFile().
+type CredentialType string
+
+const (
+	// CredManagedIdentity is a managed identity. Set one of ResourceID or ClientID for a user-assigned
+	// identity, or neither for the system-assigned identity.
+	CredManagedIdentity CredentialType = "managedIdentity"
+	// CredWorkloadIdentity is a Kubernetes workload identity. ClientID, TenantID and TokenFilePath
+	// default to the AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE environment variables.
+	CredWorkloadIdentity CredentialType = "workloadIdentity"
+	// CredAzureCLI uses the identity logged into the Azure CLI. This is useful for local development.
+	CredAzureCLI CredentialType = "azureCLI"
+	// CredDefault is the azidentity default credential chain.
+	CredDefault CredentialType = "default"
+)
+
+// CredentialConfig names the credential used to authenticate to ARN and blob storage in a config file.
+// Secrets are never stored in the file.
+type CredentialConfig struct {
+	// Type is the type of credential.
+	Type CredentialType `json:"type" yaml:"type"`
+	// ResourceID is the resource ID of a user-assigned managed identity.
+	ResourceID string `json:"resourceID,omitzero" yaml:"resourceID,omitempty"`
+	// ClientID is the client ID of a user-assigned managed identity or workload identity.
+	ClientID string `json:"clientID,omitzero" yaml:"clientID,omitempty"`
+	// TenantID is the tenant ID of a workload identity or of the Azure CLI login. For the Azure CLI,
+	// this defaults to the tenant of the preset, if it has one.
+	TenantID string `json:"tenantID,omitzero" yaml:"tenantID,omitempty"`
+	// TokenFilePath is the path of the federated token file of a workload identity.
+	TokenFilePath string `json:"tokenFilePath,omitzero" yaml:"tokenFilePath,omitempty"`
+}
+
+// resolve creates the credential.
+func (c CredentialConfig) resolve() (azcore.TokenCredential, error) {
+	switch c.Type {
+	case CredManagedIdentity:
+		if c.ResourceID != "" && c.ClientID != "" {
+			return nil, fmt.Errorf("cannot set both resourceID and clientID for a managed identity")
+		}
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		switch {
+		case c.ResourceID != "":
+			opts.ID = azidentity.ResourceID(c.ResourceID)
+		case c.ClientID != "":
+			opts.ID = azidentity.ClientID(c.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(opts)
+	case CredWorkloadIdentity:
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientID:      c.ClientID,
+			TenantID:      c.TenantID,
+			TokenFilePath: c.TokenFilePath,
+		})
+	case CredAzureCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: c.TenantID})
+	case CredDefault:
+		return azidentity.NewDefaultAzureCredential(nil)
+	case "":
+		return nil, fmt.Errorf("credential.type is required")
+	}
+	return nil, fmt.Errorf("unknown credential type %q", c.Type)
+}
+
+// fileArgs is the format of a config file read by ArgsFromFile().
+type fileArgs struct {
+	Args `json:",inline" yaml:",inline"`
+
+	// Preset is the name of a preset in Presets, like "Public".
+	Preset string `json:"preset,omitzero" yaml:"preset,omitempty"`
+	// Credential is the credential used for both ARN and blob storage.
+	Credential CredentialConfig `json:"credential" yaml:"credential"`
+}
+
+// ArgsFromFile reads Args from a YAML or JSON file at path, so that a deployment can configure the client
+// without wiring flags into code. Credentials are not stored in the file, instead they are named in
+// "credential" and created with azidentity. Unknown fields are an error. Options that take Go values,
+// like HTTPArgs.Opts, must be set on the returned Args. Values can be Key Vault references that are resolved by
+// WithSecretResolution(), such as the blob endpoint below. An example file:
+//
+//	preset: Public
+//	credential:
+//	  type: managedIdentity
+//	  resourceID: /subscriptions/.../userAssignedIdentities/arn
+//	http:
+//	  endpoint: https://receiver.arn.core.windows.net
+//	  compression: true
+//	  # Only needed for clouds the SDK does not know yet, keyed by AAD authority host.
+//	  scopeOverrides:
+//	    https://login.example.net/: api://41fc9deb-1ccc-4fcc-871d-12bf54ad8986//.default
+//	blob:
+//	  endpoint: "@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/arn-blob-endpoint)"
+//	retry:
+//	  maxAttempts: 3
+//	  budget: 30s
+func ArgsFromFile(path string) (Args, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Args{}, err
+	}
+
+	var fa fileArgs
+	// JSON is valid YAML, so the YAML decoder reads both.
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fa); err != nil {
+		return Args{}, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+
+	a := fa.Args
+	if fa.Preset != "" {
+		p, err := presetByName(fa.Preset)
+		if err != nil {
+			return Args{}, err
+		}
+		a.Preset = &p
+	}
+
+	cc := fa.Credential
+	if cc.Type == CredAzureCLI && cc.TenantID == "" && a.Preset != nil {
+		cc.TenantID = a.Preset.TenantID
+	}
+	cred, err := cc.resolve()
+	if err != nil {
+		return Args{}, fmt.Errorf("could not create credential: %w", err)
+	}
+	a.HTTP.Cred = cred
+	if a.Blob.Endpoint != "" {
+		a.Blob.Cred = cred
+	}
+
+	if err := a.validate(); err != nil {
+		return Args{}, err
+	}
+	return a, nil
+}
+
+// presetByName returns the preset in Presets with name, which is case-insensitive.
+func presetByName(name string) (Preset, error) {
+	for _, p := range []Preset{Presets.Public, Presets.Fairfax, Presets.Mooncake, Presets.Dogfood, Presets.DogfoodProd, Presets.DogfoodPPE} {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return Preset{}, fmt.Errorf("unknown preset %q", name)
+}
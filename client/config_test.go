@@ -0,0 +1,152 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArgsFromFile(t *testing.T) {
+	t.Parallel()
+
+	const msid = "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourcegroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/arn"
+
+	tests := []struct {
+		name          string
+		file          string
+		content       string
+		wantErr       bool
+		wantPreset    string
+		wantBlob      bool
+		wantRetry     time.Duration
+		wantKeepAlive time.Duration
+	}{
+		{
+			name: "YAML",
+			file: "args.yaml",
+			content: `
+preset: public
+credential:
+  type: managedIdentity
+  resourceID: ` + msid + `
+http:
+  endpoint: https://receiver.arn.core.windows.net
+  compression: true
+  conn:
+    keepAlive: 15s
+blob:
+  endpoint: https://account.blob.core.windows.net
+retry:
+  maxAttempts: 3
+  budget: 20s
+`,
+			wantPreset:    "Public",
+			wantBlob:      true,
+			wantRetry:     20 * time.Second,
+			wantKeepAlive: 15 * time.Second,
+		},
+		{
+			name:    "JSON inline-only",
+			file:    "args.json",
+			content: `{"credential": {"type": "managedIdentity"}, "http": {"endpoint": "https://receiver.arn.core.windows.net"}}`,
+		},
+		{
+			name:       "YAML DogfoodPPE with scope overrides",
+			file:       "args.yaml",
+			content:    "preset: dogfoodppe\ncredential:\n  type: azureCLI\nhttp:\n  endpoint: https://receiver.arn-df.core.windows.net\n  scopeOverrides:\n    https://login.example.net/: api://custom//.default\n",
+			wantPreset: "DogfoodPPE",
+		},
+		{
+			name:       "YAML with a Key Vault reference",
+			file:       "args.yaml",
+			content:    "preset: public\ncredential:\n  type: managedIdentity\nhttp:\n  endpoint: \"@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/arn-endpoint)\"\n",
+			wantPreset: "Public",
+		},
+		{
+			name:    "Error: unknown field",
+			file:    "args.yaml",
+			content: "credential:\n  type: default\nhttp:\n  endpoint: https://receiver.arn.core.windows.net\n  endpont: typo\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error: unknown preset",
+			file:    "args.yaml",
+			content: "preset: mars\ncredential:\n  type: default\nhttp:\n  endpoint: https://receiver.arn.core.windows.net\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error: no credential",
+			file:    "args.yaml",
+			content: "http:\n  endpoint: https://receiver.arn.core.windows.net\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error: unknown credential",
+			file:    "args.yaml",
+			content: "credential:\n  type: password\nhttp:\n  endpoint: https://receiver.arn.core.windows.net\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error: resourceID and clientID",
+			file:    "args.yaml",
+			content: "credential:\n  type: managedIdentity\n  resourceID: " + msid + "\n  clientID: id\nhttp:\n  endpoint: https://receiver.arn.core.windows.net\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error: no endpoint",
+			file:    "args.yaml",
+			content: "credential:\n  type: managedIdentity\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error: endpoint does not match preset",
+			file:    "args.yaml",
+			content: "preset: Fairfax\ncredential:\n  type: managedIdentity\nhttp:\n  endpoint: https://receiver.arn.core.windows.net\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), test.file)
+		if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+			t.Fatalf("TestArgsFromFile(%s): could not write file: %s", test.name, err)
+		}
+
+		a, err := ArgsFromFile(path)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestArgsFromFile(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestArgsFromFile(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if a.HTTP.Cred == nil {
+			t.Errorf("TestArgsFromFile(%s): HTTP.Cred was not set", test.name)
+		}
+		if got := a.Blob.Cred != nil; got != test.wantBlob {
+			t.Errorf("TestArgsFromFile(%s): got Blob.Cred set == %v, want %v", test.name, got, test.wantBlob)
+		}
+		gotPreset := ""
+		if a.Preset != nil {
+			gotPreset = a.Preset.Name
+		}
+		if gotPreset != test.wantPreset {
+			t.Errorf("TestArgsFromFile(%s): got preset %q, want %q", test.name, gotPreset, test.wantPreset)
+		}
+		var gotRetry time.Duration
+		if a.Retry != nil {
+			gotRetry = a.Retry.Budget
+		}
+		if gotRetry != test.wantRetry {
+			t.Errorf("TestArgsFromFile(%s): got Retry.Budget %v, want %v", test.name, gotRetry, test.wantRetry)
+		}
+		if a.HTTP.Conn.KeepAlive != test.wantKeepAlive {
+			t.Errorf("TestArgsFromFile(%s): got HTTP.Conn.KeepAlive %v, want %v", test.name, a.HTTP.Conn.KeepAlive, test.wantKeepAlive)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+// WithDeadLetter sends each notification that fails, after any retries, on ch, so it can be stored, sent
+// again or alerted on instead of only being seen as an error on its promise or Errors(). models.DeadLetterErr()
+// returns the error it failed with. A notification is sent on ch before its promise or Errors() receives the
+// error, and whether or not it has a promise. Notifications that fail with models.ErrSpooled are not sent on
+// ch, as they are sent from the spool, see WithSpoolDir().
+//
+// The notification sent on ch has no promise and its context has no deadline, so set both before sending it
+// again. ch must be read: if it is full, the notification is logged and dropped rather than holding up the
+// notifications behind it. With WithBatching(), the notification is the merged one that holds the data
+// items of each notification in the batch. Notifications that fail before they are queued, such as a
+// notification whose context is done when Async() is called, are not sent on ch.
+func WithDeadLetter(ch chan models.Notifications) Option {
+	return func(c *ARN) error {
+		if ch == nil {
+			return fmt.Errorf("WithDeadLetter(): ch cannot be nil")
+		}
+		c.deadLetters = ch
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/retry/exponential"
+)
+
+func TestWithDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithDeadLetter(nil), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithDeadLetter(nil channel): got err == nil, want err != nil")
+	}
+
+	p := exponential.Policy{InitialInterval: time.Millisecond, Multiplier: 2, MaxInterval: 5 * time.Millisecond}
+	s := &flakySender{fails: 2}
+	dead := make(chan models.Notifications, 1)
+	a, err := New(ctx, Args{}, WithFakeClients(s, fakeUploader{}), WithRetry(p, 2), WithDeadLetter(dead))
+	if err != nil {
+		t.Fatalf("TestWithDeadLetter: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	n := validNotification(t)
+	sendErr := a.Notify(ctx, n)
+	if sendErr == nil {
+		t.Fatalf("TestWithDeadLetter: Notify(): got err == nil, want the 503 error")
+	}
+
+	var got models.Notifications
+	select {
+	case got = <-dead:
+	default:
+		t.Fatalf("TestWithDeadLetter: got no dead lettered notification after retries were exhausted")
+	}
+	err, ok := models.DeadLetterErr(got)
+	var re *azcore.ResponseError
+	if !ok || !errors.As(err, &re) || re.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("TestWithDeadLetter: got DeadLetterErr() == %v, %v, want the 503 error, true", err, ok)
+	}
+	if got.(msgs.Notifications).Data[0].ResourceID != n.Data[0].ResourceID {
+		t.Errorf("TestWithDeadLetter: got a dead lettered notification for %s, want %s", got.(msgs.Notifications).Data[0].ResourceID, n.Data[0].ResourceID)
+	}
+
+	// The dead lettered notification can be sent again once the receiver recovers.
+	if err := a.Notify(ctx, got); err != nil {
+		t.Errorf("TestWithDeadLetter: Notify() of the dead lettered notification: got err == %s, want err == nil", err)
+	}
+	if got := s.sends.Load(); got != 3 {
+		t.Errorf("TestWithDeadLetter: got %d sends, want 3", got)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/capture"
+	"github.com/Azure/arn-sdk/internal/conn/timing"
+)
+
+// CaptureOptions configures the failed requests kept by WithFailureCapture().
+type CaptureOptions = capture.Options
+
+// FailedRequest is a failed request to ARN and its response, see Debug.LastFailures().
+type FailedRequest = capture.Failure
+
+// WithFailureCapture keeps the last o.Size failed requests to the ARN receiver in memory, with their
+// responses, so they can be attached to ARN support tickets. Each attempt, including retries, is kept as it
+// was sent. Authorization and other sensitive headers and SAS signatures are redacted, and bodies are
+// truncated to o.MaxBody and optionally compressed while kept. Get them with ARN.Debug().LastFailures().
+// This has no effect with WithFakeClients().
+func WithFailureCapture(o CaptureOptions) Option {
+	return func(c *ARN) error {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid capture options: %w", err)
+		}
+		c.captureOpts = &o
+		return nil
+	}
+}
+
+// SlowSendOptions configures the slow sends kept by WithSlowSendSampling().
+type SlowSendOptions = timing.SamplerOptions
+
+// SlowSend is the timeline of one of the slowest sends, see Debug.SlowSends().
+type SlowSend = timing.SlowSend
+
+// WithSlowSendSampling keeps the timeline of the slowest o.Percent of sends, by the time from Async() or
+// Notify() to the send finishing, so that the stage that used up the latency budget of an outlier can be
+// found. The last o.Size are kept in memory, get them with ARN.Debug().SlowSends(). With execution tracing
+// on, each slow send is also logged in its runtime/trace task. The time spent in each stage is recorded as
+// the arn-sdk_event_stage_ms and arn-sdk_event_deadline_used_percent metrics whether or not this is set.
+func WithSlowSendSampling(o SlowSendOptions) Option {
+	return func(c *ARN) error {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid slow send options: %w", err)
+		}
+		c.slowOpts = &o
+		return nil
+	}
+}
+
+// withCapture returns a copy of a with a capture.Recorder added last to the HTTP client's per-retry
+// policies, so that it sees requests as they go on the wire.
+func (a Args) withCapture(o CaptureOptions) (Args, *capture.Recorder, error) {
+	r, err := capture.New(o)
+	if err != nil {
+		return Args{}, nil, err
+	}
+	a.HTTP.Opts = addPolicy(a.HTTP.Opts, r)
+	return a, r, nil
+}
+
+// Debug gives access to debugging information about the client, see ARN.Debug().
+type Debug struct {
+	capture *capture.Recorder
+	slow    *timing.Sampler
+}
+
+// Debug returns debugging information about the client.
+func (a *ARN) Debug() Debug {
+	return Debug{capture: a.capture, slow: a.slow}
+}
+
+// LastFailures returns the failed requests to the ARN receiver kept by WithFailureCapture(), from oldest to
+// newest. It returns nil if WithFailureCapture() is not set. Thread-safe.
+func (d Debug) LastFailures() []FailedRequest {
+	return d.capture.Failures()
+}
+
+// SlowSends returns the slow sends kept by WithSlowSendSampling(), from oldest to newest. It returns nil if
+// WithSlowSendSampling() is not set. Thread-safe.
+func (d Debug) SlowSends() []SlowSend {
+	return d.slow.SlowSends()
+}
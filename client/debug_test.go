@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/timing"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestWithFailureCapture(t *testing.T) {
+	t.Parallel()
+
+	cred := struct{ azcore.TokenCredential }{}
+	args := Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: cred}}
+
+	got, r, err := args.withCapture(CaptureOptions{})
+	if err != nil {
+		t.Fatalf("TestWithFailureCapture: withCapture(): got err == %s, want err == nil", err)
+	}
+	if r == nil {
+		t.Errorf("TestWithFailureCapture: withCapture(): got nil Recorder")
+	}
+	if n := numPolicies(got.HTTP.Opts); n != 1 {
+		t.Errorf("TestWithFailureCapture: got %d HTTP policies, want 1", n)
+	}
+	if args.HTTP.Opts != nil {
+		t.Errorf("TestWithFailureCapture: the original Args were changed")
+	}
+
+	a, err := New(context.Background(), args, WithFailureCapture(CaptureOptions{Size: 5, Compress: true}))
+	if err != nil {
+		t.Fatalf("TestWithFailureCapture: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+	if a.capture == nil {
+		t.Errorf("TestWithFailureCapture: client has no Recorder")
+	}
+	if f := a.Debug().LastFailures(); len(f) != 0 {
+		t.Errorf("TestWithFailureCapture: got %d failures before any request, want 0", len(f))
+	}
+
+	b, err := New(context.Background(), Args{}, WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithFailureCapture: New(): got err == %s, want err == nil", err)
+	}
+	defer b.Close()
+	if f := b.Debug().LastFailures(); f != nil {
+		t.Errorf("TestWithFailureCapture: without WithFailureCapture(): got %v, want nil", f)
+	}
+
+	if err := WithFailureCapture(CaptureOptions{Size: -1})(&ARN{}); err == nil {
+		t.Errorf("TestWithFailureCapture(invalid options): got err == nil, want err != nil")
+	}
+}
+
+func TestWithSlowSendSampling(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(
+		context.Background(),
+		Args{},
+		WithFakeClients(fakeSender{}, fakeUploader{}),
+		WithSlowSendSampling(SlowSendOptions{Percent: 100, Size: 3}),
+	)
+	if err != nil {
+		t.Fatalf("TestWithSlowSendSampling: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := 0; i < 25; i++ {
+		if err := a.Notify(ctx, validNotification(t)); err != nil {
+			t.Fatalf("TestWithSlowSendSampling: Notify(): got err == %s, want err == nil", err)
+		}
+	}
+
+	got := a.Debug().SlowSends()
+	if len(got) != 3 {
+		t.Fatalf("TestWithSlowSendSampling: got %d slow sends, want 3", len(got))
+	}
+	ss := got[len(got)-1]
+	if ss.Items != 1 || ss.Budget <= 0 {
+		t.Errorf("TestWithSlowSendSampling: got Items == %d, Budget == %s, want 1 item and a budget", ss.Items, ss.Budget)
+	}
+	stages := map[string]bool{}
+	for _, st := range ss.Stages {
+		stages[st.Stage] = true
+	}
+	for _, want := range []string{timing.StageQueue, timing.StageMarshal, timing.StageHTTP, timing.StageTotal} {
+		if !stages[want] {
+			t.Errorf("TestWithSlowSendSampling: slow send has no %q stage: %v", want, ss.Stages)
+		}
+	}
+
+	b, err := New(context.Background(), Args{}, WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithSlowSendSampling: New(): got err == %s, want err == nil", err)
+	}
+	defer b.Close()
+	if got := b.Debug().SlowSends(); got != nil {
+		t.Errorf("TestWithSlowSendSampling: without WithSlowSendSampling(): got %v, want nil", got)
+	}
+
+	if err := WithSlowSendSampling(SlowSendOptions{Percent: 101})(&ARN{}); err == nil {
+		t.Errorf("TestWithSlowSendSampling(invalid options): got err == nil, want err != nil")
+	}
+}
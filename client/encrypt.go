@@ -0,0 +1,39 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// BlobKeyWrapper wraps the content key that a blob payload is encrypted with. KeyVaultKey is a BlobKeyWrapper.
+type BlobKeyWrapper = encrypt.KeyWrapper
+
+// KeyVaultKey is an RSA key in Azure Key Vault or Managed HSM that wraps the content keys of blob payloads.
+type KeyVaultKey = encrypt.KeyVault
+
+// NewKeyVaultKey returns a KeyVaultKey for the key with keyID, like "https://myvault.vault.azure.net/keys/mykey".
+// cred needs the "wrapKey" permission on the key. opts can be nil.
+func NewKeyVaultKey(keyID string, cred azcore.TokenCredential, opts *policy.ClientOptions) (*KeyVaultKey, error) {
+	return encrypt.NewKeyVault(keyID, cred, opts)
+}
+
+// WithBlobEncryption encrypts each payload uploaded to blob storage with envelope encryption, for resources too
+// sensitive to be kept in blob storage in the clear. Each payload is encrypted with a new AES-256-GCM key that
+// is wrapped by w. The ID of the wrapping key and the wrapped key are recorded in the event's
+// AdditionalBatchProperties, so a consumer with the "unwrapKey" permission can decrypt the payload with
+// receiver.Downloader.DownloadData(). Every reader of the blob, including ARN, needs the key, so only use this
+// for notifications whose consumers are set up to decrypt them. Inline payloads are not encrypted, and a
+// msgs.Stream cannot be sent with this on.
+func WithBlobEncryption(w BlobKeyWrapper) Option {
+	return func(c *ARN) error {
+		if w == nil {
+			return fmt.Errorf("BlobKeyWrapper cannot be nil")
+		}
+		c.encrypt = w
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestWithBlobEncryption(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithBlobEncryption(nil), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithBlobEncryption: nil BlobKeyWrapper: got err == nil, want err != nil")
+	}
+	if _, err := NewKeyVaultKey("https://myvault.vault.azure.net/secrets/mykey", struct{ azcore.TokenCredential }{}, nil); err == nil {
+		t.Errorf("TestWithBlobEncryption: NewKeyVaultKey() with a secret ID: got err == nil, want err != nil")
+	}
+
+	kv, err := NewKeyVaultKey("https://myvault.vault.azure.net/keys/mykey", struct{ azcore.TokenCredential }{}, nil)
+	if err != nil {
+		t.Fatalf("TestWithBlobEncryption: NewKeyVaultKey(): got err == %s, want err == nil", err)
+	}
+	a, err := New(ctx, Args{}, WithBlobEncryption(kv), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithBlobEncryption: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+	// The notification is sent inline, so the key is not used.
+	if err := a.Notify(ctx, validNotification(t)); err != nil {
+		t.Errorf("TestWithBlobEncryption: Notify(): got err == %s, want err == nil", err)
+	}
+}
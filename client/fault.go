@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/fault"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// FaultConfig configures the faults injected by WithFaultInjection().
+type FaultConfig = fault.Config
+
+// Faults are the faults injected into one layer of the client.
+type Faults = fault.Faults
+
+// WithFaultInjection injects errors, latency and payload corruption into the requests the client makes to
+// ARN and blob storage, at the rates set in cfg. Faults are injected below the azcore retry policy, so the
+// client's retries, hedging, budgets and metrics see them as they would see a real outage. Fabricated error
+// responses carry the "x-arn-fault-injected" header. This is meant for tests and staging, where publishers
+// rehearse ARN outages, and logs a warning when the client is created. It has no effect with WithFakeClients().
+func WithFaultInjection(cfg FaultConfig) Option {
+	return func(c *ARN) error {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid fault injection config: %w", err)
+		}
+		c.faults = &cfg
+		return nil
+	}
+}
+
+// withFaults returns a copy of a with fault injection policies added to the HTTP and blob client options.
+func (a Args) withFaults(cfg FaultConfig) (Args, error) {
+	if !cfg.HTTP.IsZero() {
+		p, err := fault.New(cfg.HTTP, cfg.Seed)
+		if err != nil {
+			return Args{}, err
+		}
+		a.HTTP.Opts = addPolicy(a.HTTP.Opts, p)
+	}
+	// Setting Opts on zero BlobArgs would take the client out of inline-only mode.
+	if !cfg.Blob.IsZero() && !a.Blob.isZero() {
+		p, err := fault.New(cfg.Blob, cfg.Seed)
+		if err != nil {
+			return Args{}, err
+		}
+		a.Blob.Opts = addPolicy(a.Blob.Opts, p)
+	}
+	return a, nil
+}
+
+// addPolicy returns a copy of opts with p added to the per-retry policies.
+func addPolicy(opts *policy.ClientOptions, p policy.Policy) *policy.ClientOptions {
+	var o policy.ClientOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.PerRetryPolicies = append(append([]policy.Policy(nil), o.PerRetryPolicies...), p)
+	return &o
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+func TestWithFaults(t *testing.T) {
+	t.Parallel()
+
+	cred := struct{ azcore.TokenCredential }{}
+	blob := BlobArgs{Endpoint: "https://account.blob.core.windows.net", Cred: cred}
+
+	tests := []struct {
+		name         string
+		blob         BlobArgs
+		cfg          FaultConfig
+		wantHTTP     int
+		wantBlob     int
+		wantBlobZero bool
+	}{
+		{name: "HTTP only", blob: blob, cfg: FaultConfig{HTTP: Faults{ErrorRate: 0.1}}, wantHTTP: 1},
+		{name: "HTTP and blob", blob: blob, cfg: FaultConfig{HTTP: Faults{ErrorRate: 0.1}, Blob: Faults{CorruptRate: 0.1}}, wantHTTP: 1, wantBlob: 1},
+		{name: "Inline-only stays inline-only", cfg: FaultConfig{Blob: Faults{CorruptRate: 0.1}}, wantBlobZero: true},
+	}
+
+	for _, test := range tests {
+		args := Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: cred}, Blob: test.blob}
+		got, err := args.withFaults(test.cfg)
+		if err != nil {
+			t.Errorf("TestWithFaults(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if n := numPolicies(got.HTTP.Opts); n != test.wantHTTP {
+			t.Errorf("TestWithFaults(%s): got %d HTTP policies, want %d", test.name, n, test.wantHTTP)
+		}
+		if n := numPolicies(got.Blob.Opts); n != test.wantBlob {
+			t.Errorf("TestWithFaults(%s): got %d blob policies, want %d", test.name, n, test.wantBlob)
+		}
+		if got.Blob.isZero() != test.wantBlobZero {
+			t.Errorf("TestWithFaults(%s): got Blob.isZero() == %v, want %v", test.name, got.Blob.isZero(), test.wantBlobZero)
+		}
+		if args.HTTP.Opts != nil {
+			t.Errorf("TestWithFaults(%s): the original Args were changed", test.name)
+		}
+	}
+
+	if err := WithFaultInjection(FaultConfig{HTTP: Faults{ErrorRate: 2}})(&ARN{}); err == nil {
+		t.Errorf("TestWithFaults(invalid config): got err == nil, want err != nil")
+	}
+}
+
+func numPolicies(opts *policy.ClientOptions) int {
+	if opts == nil {
+		return 0
+	}
+	return len(opts.PerRetryPolicies)
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/models/version"
+)
+
+// FeatureSet are the capabilities of this version of the SDK that vary with how it was built, so frameworks
+// that embed the SDK can check them instead of parsing the SDK version. A value missing from a list is not
+// supported by this version. Options and types that exist are supported, so they are not listed here.
+type FeatureSet struct {
+	// SDKVersion is the version of the SDK, like "v0.1.0".
+	SDKVersion string
+	// Schemas are the schema versions of the models the SDK can send.
+	Schemas []version.Schema
+	// Compression are the Content-Encoding values requests to the ARN receiver can be compressed with.
+	// See HTTPArgs.Compression and HTTPArgs.CompressionAlgorithm.
+	Compression []string
+}
+
+// Features returns the features of this version of the SDK. The slices are new on each call.
+func Features() FeatureSet {
+	return FeatureSet{
+		SDKVersion:  version.SDK.String(),
+		Schemas:     []version.Schema{version.V3},
+		Compression: http.Compressions(),
+	}
+}
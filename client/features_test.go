@@ -0,0 +1,29 @@
+package client
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/version"
+)
+
+func TestFeatures(t *testing.T) {
+	t.Parallel()
+
+	f := Features()
+	if f.SDKVersion != version.SDK.String() {
+		t.Errorf("TestFeatures: got SDKVersion %q, want %q", f.SDKVersion, version.SDK.String())
+	}
+	if !slices.Contains(f.Schemas, version.V3) {
+		t.Errorf("TestFeatures: got Schemas %v, want it to contain %v", f.Schemas, version.V3)
+	}
+	if !slices.Contains(f.Compression, "deflate") {
+		t.Errorf("TestFeatures: got Compression %v, want it to contain deflate", f.Compression)
+	}
+
+	// Callers may change the slices they get.
+	f.Schemas[0] = "bad"
+	if got := Features().Schemas[0]; got != version.V3 {
+		t.Errorf("TestFeatures: got Schemas[0] %q after changing a previous result, want %q", got, version.V3)
+	}
+}
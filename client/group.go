@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/arn-sdk/internal/conn"
+	"github.com/Azure/arn-sdk/internal/conn/txn"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/google/uuid"
+)
+
+// GroupError is returned by NotifyGroup() if any notification of the group failed. Use errors.Is() and
+// errors.As() on it to look for an error of any of the notifications.
+type GroupError struct {
+	// CorrelationID is the correlation ID of the group.
+	CorrelationID string
+	// Errs are the results of the notifications, in the order they were given to NotifyGroup(). A nil error
+	// is a notification that was delivered.
+	Errs []error
+}
+
+// Error implements error.
+func (e *GroupError) Error() string {
+	var failed []string
+	for i, err := range e.Errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("[%d]: %s", i, err))
+		}
+	}
+	return fmt.Sprintf("group(%s): %d of %d notifications failed: %s", e.CorrelationID, len(failed), len(e.Errs), strings.Join(failed, "; "))
+}
+
+// Unwrap returns the errors of the notifications that failed.
+func (e *GroupError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// NotifyGroup sends ns as one logical transaction, such as the notifications for each resource type that one
+// change in a source system fans out into. Each notification is sent as its own ARN event, in the order
+// given, and every event has id as its AdditionalBatchProperties.BatchCorrelationID, so the events of the
+// group can be found together. id of "" is a new GUID. It returns id and blocks until every notification has
+// a result, like Notify().
+//
+// The group is reported as a unit: if any notification fails, the error is a *GroupError with the result of
+// each one. Notifications that break the client's item limit fail the group before any is sent. ARN cannot
+// take back events it has accepted, so the other notifications of a failed group may have been delivered; the
+// caller should send the whole group again with the same id, which consumers can use to tell the events of
+// the retry apart from new ones. A notification whose BatchCorrelationID is set to another ID fails with
+// models.ErrValidation. Notifications in a group are not coalesced by WithBatching(). Thread-safe.
+func (a *ARN) NotifyGroup(ctx context.Context, id string, ns ...models.Notifications) (string, error) {
+	if id == "" {
+		id = uuid.New().String()
+	}
+	for i, n := range ns {
+		if err := conn.CheckItems(n.DataCount(), a.maxItems); err != nil {
+			return id, fmt.Errorf("group(%s): notification[%d]: %w", id, i, err)
+		}
+	}
+
+	gctx := txn.WithID(ctx, id)
+	sent := make([]models.Notifications, len(ns))
+	for i, n := range ns {
+		sent[i] = a.Async(gctx, n, true)
+	}
+
+	gerr := &GroupError{CorrelationID: id, Errs: make([]error, len(ns))}
+	failed := false
+	for i, n := range sent {
+		if err := n.Promise(ctx); err != nil {
+			gerr.Errs[i] = err
+			failed = true
+		}
+	}
+	if failed {
+		return id, gerr
+	}
+	return id, nil
+}
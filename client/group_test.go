@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// groupSender records the events it sends and fails those for the resource group "bad".
+type groupSender struct {
+	mu     sync.Mutex
+	events [][]byte
+}
+
+func (g *groupSender) Send(ctx context.Context, event []byte) error {
+	if bytes.Contains(event, []byte("/resourceGroups/bad/")) {
+		return &azcore.ResponseError{StatusCode: http.StatusBadRequest}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.events = append(g.events, append([]byte(nil), event...))
+	return nil
+}
+
+func (g *groupSender) sent() [][]byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([][]byte(nil), g.events...)
+}
+
+func TestNotifyGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	gs := &groupSender{}
+	a, err := New(ctx, Args{}, WithFakeClients(gs, fakeUploader{}), WithBatching(0, 0, time.Hour), WithMaxItems(1))
+	if err != nil {
+		t.Fatalf("TestNotifyGroup: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	// Every event of a group carries its correlation ID, and the group is not held by the batcher.
+	id, err := a.NotifyGroup(ctx, "", validNotification(t), validNotification(t))
+	if err != nil {
+		t.Fatalf("TestNotifyGroup: NotifyGroup(): got err == %s, want err == nil", err)
+	}
+	if id == "" {
+		t.Fatalf("TestNotifyGroup: NotifyGroup(): got an empty correlation ID")
+	}
+	events := gs.sent()
+	if len(events) != 2 {
+		t.Fatalf("TestNotifyGroup: got %d events, want 2", len(events))
+	}
+	for i, e := range events {
+		if !bytes.Contains(e, []byte(`"batchCorrelationId":"`+id+`"`)) {
+			t.Errorf("TestNotifyGroup: event[%d] does not have the correlation ID %s: %s", i, id, e)
+		}
+	}
+
+	// A notification that fails fails the group, with the result of each notification.
+	bad := validNotification(t)
+	bad.Data[0].ResourceID = "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/bad/providers/Microsoft.ContainerService/managedClusters/cluster"
+	id, err = a.NotifyGroup(ctx, "my-id", validNotification(t), bad)
+	var ge *GroupError
+	switch {
+	case id != "my-id":
+		t.Errorf("TestNotifyGroup(failure): got correlation ID %q, want %q", id, "my-id")
+	case !errors.As(err, &ge):
+		t.Fatalf("TestNotifyGroup(failure): got err == %v, want a *GroupError", err)
+	case ge.Errs[0] != nil || ge.Errs[1] == nil:
+		t.Errorf("TestNotifyGroup(failure): got Errs == %v, want only the second to fail", ge.Errs)
+	case !models.IsValidation(err):
+		t.Errorf("TestNotifyGroup(failure): got err == %s, want models.IsValidation(err) through the GroupError", err)
+	}
+
+	// A notification over the item limit fails the group before any is sent.
+	before := len(gs.sent())
+	big := validNotification(t)
+	big.Data = append(big.Data, big.Data[0])
+	if _, err := a.NotifyGroup(ctx, "", validNotification(t), big); !errors.Is(err, models.ErrBatchSize) {
+		t.Errorf("TestNotifyGroup(too many items): got err == %v, want models.ErrBatchSize", err)
+	}
+	if got := len(gs.sent()); got != before {
+		t.Errorf("TestNotifyGroup(too many items): got %d events sent, want none", got-before)
+	}
+
+	// A notification with another correlation ID is not valid in the group.
+	other := validNotification(t)
+	other.AdditionalBatchProperties.BatchCorrelationID = "other"
+	if _, err := a.NotifyGroup(ctx, "", other); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("TestNotifyGroup(other correlation ID): got err == %v, want models.ErrValidation", err)
+	}
+}
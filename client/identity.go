@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// NewWithManagedIdentity creates a new ARN client that authenticates to ARN and blob storage with the
+// user-assigned managed identity with resource ID msiResourceID. If msiResourceID is empty, the
+// system-assigned identity is used. If storageEndpoint is empty, the client runs in inline-only mode.
+// Use New() for anything that needs more than the endpoints.
+func NewWithManagedIdentity(ctx context.Context, endpoint, storageEndpoint, msiResourceID string, options ...Option) (*ARN, error) {
+	cred, err := CredentialConfig{Type: CredManagedIdentity, ResourceID: msiResourceID}.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("could not create managed identity credential: %w", err)
+	}
+	return New(ctx, credArgs(endpoint, storageEndpoint, cred), options...)
+}
+
+// NewWithWorkloadIdentity creates a new ARN client that authenticates to ARN and blob storage with a
+// Kubernetes workload identity (a federated credential). clientID is the client ID of the identity. If it is
+// empty, the AZURE_CLIENT_ID environment variable that the workload identity webhook injects is used, as are
+// AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE. If storageEndpoint is empty, the client runs in
+// inline-only mode. Use New() for anything that needs more than the endpoints.
+func NewWithWorkloadIdentity(ctx context.Context, endpoint, storageEndpoint, clientID string, options ...Option) (*ARN, error) {
+	cred, err := CredentialConfig{Type: CredWorkloadIdentity, ClientID: clientID}.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("could not create workload identity credential: %w", err)
+	}
+	return New(ctx, credArgs(endpoint, storageEndpoint, cred), options...)
+}
+
+// credArgs returns Args that use cred for ARN at endpoint and for blob storage at storageEndpoint,
+// if it is set.
+func credArgs(endpoint, storageEndpoint string, cred azcore.TokenCredential) Args {
+	a := Args{
+		HTTP: HTTPArgs{Endpoint: endpoint, Cred: cred},
+	}
+	if storageEndpoint != "" {
+		a.Blob = BlobArgs{Endpoint: storageEndpoint, Cred: cred}
+	}
+	return a
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewWithManagedIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		msid     string
+		wantErr  bool
+	}{
+		{name: "System-assigned", endpoint: "https://receiver.arn.core.windows.net"},
+		{name: "User-assigned", endpoint: "https://receiver.arn.core.windows.net", msid: "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourcegroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/arn"},
+		{name: "Error: no endpoint", wantErr: true},
+	}
+
+	for _, test := range tests {
+		a, err := NewWithManagedIdentity(context.Background(), test.endpoint, "", test.msid)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNewWithManagedIdentity(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNewWithManagedIdentity(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if a != nil {
+			a.Close()
+		}
+	}
+}
+
+// This cannot be run in parallel as it sets environment variables.
+func TestNewWithWorkloadIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AZURE_TENANT_ID", "72f988bf-86f1-41af-91ab-2d7cd011db47")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+
+	a, err := NewWithWorkloadIdentity(context.Background(), "https://receiver.arn.core.windows.net", "", "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("TestNewWithWorkloadIdentity: got err == %s, want err == nil", err)
+	}
+	a.Close()
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/storage"
+)
+
+// BlobInfo describes a notification blob in blob storage. See ListBlobs().
+type BlobInfo = storage.BlobInfo
+
+// ListBlobs returns the notification blobs in blob storage that were written at or after since, from this
+// client or any client with the same BlobArgs.ContainerExt. Reconciliation jobs can use this to check that
+// every blob was referenced by a delivered event. Iteration stops after the first error. It returns an
+// error if the client is inline-only.
+func (a *ARN) ListBlobs(ctx context.Context, since time.Time) iter.Seq2[BlobInfo, error] {
+	if a.store == nil {
+		return func(yield func(BlobInfo, error) bool) {
+			yield(BlobInfo{}, fmt.Errorf("the client has no blob storage, Args.Blob is not set"))
+		}
+	}
+	return a.store.List(ctx, since)
+}
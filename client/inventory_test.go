@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestListBlobsInlineOnly(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(context.Background(), Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: struct{ azcore.TokenCredential }{}}})
+	if err != nil {
+		t.Fatalf("TestListBlobsInlineOnly: New() error: %v", err)
+	}
+	defer a.Close()
+
+	n := 0
+	for _, err := range a.ListBlobs(context.Background(), time.Now()) {
+		n++
+		if err == nil {
+			t.Errorf("TestListBlobsInlineOnly: got err == nil, want err != nil")
+		}
+	}
+	if n != 1 {
+		t.Errorf("TestListBlobsInlineOnly: got %d results, want 1", n)
+	}
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// WithJournal logs a compact record at slog.LevelInfo to log for each event the ARN receiver accepts, so
+// that delivery can be audited event by event without turning on debug logging. The record has the
+// message "ARN event delivered" and the attributes:
+//   - eventID: the ID of the event.
+//   - subject: the subject of the event.
+//   - items: the number of data items in the event.
+//   - bytes: the size of the resources of the event.
+//   - inline: true if the resources were sent in the event, false if they were sent through blob storage.
+//   - latency: the time from the notification being queued to the receiver accepting it.
+//
+// The record is logged with the context of the send, so a handler that bridges slog to OpenTelemetry logs,
+// such as go.opentelemetry.io/contrib/bridges/otelslog, exports it over OTLP with the trace of the send when
+// WithTracerProvider() is set. It is logged before the notification's promise is fulfilled. With
+// WithBatching(), one record is logged for the merged event. Events sent later from the spool, see
+// WithSpoolDir(), are not logged.
+func WithJournal(log *slog.Logger) Option {
+	return func(c *ARN) error {
+		if log == nil {
+			return fmt.Errorf("WithJournal(): log cannot be nil")
+		}
+		c.journal = log
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithJournal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithJournal(nil), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithJournal(nil logger): got err == nil, want err != nil")
+	}
+
+	buf := &bytes.Buffer{}
+	a, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}), WithJournal(slog.New(slog.NewTextHandler(buf, nil))))
+	if err != nil {
+		t.Fatalf("TestWithJournal: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	if err := a.Notify(ctx, validNotification(t)); err != nil {
+		t.Fatalf("TestWithJournal: Notify(): got err == %s, want err == nil", err)
+	}
+
+	record := strings.TrimSpace(buf.String())
+	if strings.Count(record, "\n") != 0 {
+		t.Fatalf("TestWithJournal: got more than one record:\n%s", record)
+	}
+	for _, want := range []string{`msg="ARN event delivered"`, "subject=/subscriptions/", "items=1", "inline=true", "latency="} {
+		if !strings.Contains(record, want) {
+			t.Errorf("TestWithJournal: got record %q, want it to contain %q", record, want)
+		}
+	}
+	for _, empty := range []string{"eventID= ", "bytes=0 "} {
+		if strings.Contains(record, empty) {
+			t.Errorf("TestWithJournal: got record %q, want it not to contain %q", record, empty)
+		}
+	}
+}
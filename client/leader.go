@@ -0,0 +1,61 @@
+package client
+
+import (
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/leader"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// LeaderOptions configures leader election, see WithLeaderElection().
+type LeaderOptions = leader.Options
+
+// LeaderLock is a lock that at most one replica holds at a time, such as a BlobLeaseLock. Implement it to use
+// another lock, such as a Kubernetes Lease.
+type LeaderLock = leader.Lock
+
+// Follower is what is done with the notifications of a replica that is not the leader.
+type Follower = leader.Follower
+
+const (
+	// FollowerDrop drops the notifications of a follower without sending them. Their promises get a nil
+	// error, as the leader is expected to send the same notifications.
+	FollowerDrop = leader.Drop
+	// FollowerQueue holds up to LeaderOptions.MaxQueue notifications of a follower and sends them if it
+	// becomes the leader. Notify() waits until then or until its context ends.
+	FollowerQueue = leader.Queue
+)
+
+// ErrNotLeader is the error of a queued notification that was not sent because the replica did not become
+// the leader, either because the queue was full or the client was closed.
+var ErrNotLeader = leader.ErrNotLeader
+
+// BlobLeaseLock is a LeaderLock on the lease of a blob in Azure Blob Storage.
+type BlobLeaseLock = leader.BlobLock
+
+// NewBlobLeaseLock returns a LeaderLock on the lease of the blob at blobURL, such as
+// "https://account.blob.core.windows.net/container/arn-leader". The container must exist and the blob is
+// created if it does not. leaseDuration is how long a dead leader keeps the lock, from 15 to 60 seconds. If 0,
+// it is 30 seconds. Each replica must create its own lock.
+func NewBlobLeaseLock(blobURL string, cred azcore.TokenCredential, leaseDuration time.Duration) (*BlobLeaseLock, error) {
+	return leader.NewBlobLock(blobURL, cred, leaseDuration)
+}
+
+// WithLeaderElection only sends notifications while this replica holds o.Lock, for notifications that must
+// be published by exactly one replica of an HA deployment. The lock is renewed every o.RenewInterval, and
+// released when the client is closed, after its last notification. The notifications of the other replicas
+// are dropped or queued by o.Follower. See IsLeader().
+func WithLeaderElection(o LeaderOptions) Option {
+	return func(c *ARN) error {
+		if err := o.Validate(); err != nil {
+			return err
+		}
+		c.leader = &o
+		return nil
+	}
+}
+
+// IsLeader returns true if this replica is the leader, or if WithLeaderElection() is not used. Thread-safe.
+func (a *ARN) IsLeader() bool {
+	return a.conn.Leader()
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+// heldLock is a LeaderLock that this replica always holds.
+type heldLock struct{}
+
+func (heldLock) Acquire(context.Context) (bool, error) { return true, nil }
+func (heldLock) Release(context.Context) error         { return nil }
+
+func TestWithLeaderElection(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithLeaderElection(LeaderOptions{}), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithLeaderElection: New() without a lock: got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithLeaderElection: New() error: %v", err)
+	}
+	if !a.IsLeader() {
+		t.Errorf("TestWithLeaderElection: without WithLeaderElection(): got IsLeader() == false, want true")
+	}
+	a.Close()
+
+	a, err = New(ctx, Args{}, WithLeaderElection(LeaderOptions{Lock: heldLock{}}), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithLeaderElection: New() error: %v", err)
+	}
+	defer a.Close()
+	if !a.IsLeader() {
+		t.Errorf("TestWithLeaderElection: got IsLeader() == false, want true")
+	}
+	if err := a.Notify(ctx, validNotification(t)); err != nil {
+		t.Errorf("TestWithLeaderElection: Notify(): got err == %s, want err == nil", err)
+	}
+	if got := a.Stats().Sent; got != 1 {
+		t.Errorf("TestWithLeaderElection: got %d sent, want 1", got)
+	}
+}
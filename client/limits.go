@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/itemsize"
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+const (
+	// MaxInlineSize is the size in bytes of a notification's resources, as JSON, at which they are sent
+	// through blob storage instead of inline. Resources must be smaller than this to be sent inline. A client
+	// without blob storage (see InlineOnly()) fails notifications at or above this size with
+	// models.ErrNoBlobClient.
+	MaxInlineSize = maxvals.InlineSize
+	// DefaultMaxItems is the maximum number of items in a notification unless changed with WithMaxItems().
+	DefaultMaxItems = maxvals.NotificationItems
+	// DefaultMaxResourceBytes is the size of a resource, as JSON, that WithMaxResourceBytes(0) allows.
+	DefaultMaxResourceBytes = itemsize.DefaultMax
+)
+
+// MaxItems returns the maximum number of items (Notifications.DataCount()) in a notification for this
+// client. This is DefaultMaxItems unless changed with WithMaxItems(). Notifications with more items fail
+// with models.ErrBatchSize.
+func (a *ARN) MaxItems() int {
+	if a.maxItems > 0 {
+		return a.maxItems
+	}
+	return DefaultMaxItems
+}
+
+// InlineOnly returns true if the client has no blob storage, so notifications with resources of
+// MaxInlineSize or more cannot be sent.
+func (a *ARN) InlineOnly() bool {
+	return a.store == nil
+}
+
+// PropertyLimits are the limits that the JSON of each resource's ArmResource.Properties must be within. See
+// WithPropertyLimits().
+type PropertyLimits = types.PropertyLimits
+
+// WithPropertyLimits checks the ArmResource.Properties of every resource before a notification is sent. A
+// notification fails, with an error that names the resource, if its properties hold a NaN or infinite float or
+// a string that is not valid UTF-8, or marshal to JSON nested deeper than l.MaxDepth or larger than
+// l.MaxBytes. The JSON encoder would otherwise send a NaN as the string "NaN" and replace invalid UTF-8. Zero
+// values of l are the defaults. Each resource's properties are marshaled once more to check them. The
+// resources of a msgs.Stream are not checked.
+func WithPropertyLimits(l PropertyLimits) Option {
+	return func(c *ARN) error {
+		l = l.Defaults()
+		if err := l.Validate(); err != nil {
+			return fmt.Errorf("invalid property limits: %w", err)
+		}
+		c.propLimits = &l
+		return nil
+	}
+}
+
+// WithMaxResourceBytes fails a notification, before it is sent, if one of its resources is larger than n bytes
+// as JSON. A single very large resource, such as a node with megabytes of labels, would otherwise push a small
+// notification to blob storage or be rejected downstream. The error names the resource and
+// models.IsValidation() is true for it. n of 0 is DefaultMaxResourceBytes. The sizes are read from the JSON
+// the notification is sent with, so resources are not marshaled again. The resources of a msgs.Stream are not
+// checked.
+func WithMaxResourceBytes(n int) Option {
+	return func(c *ARN) error {
+		if n < 0 {
+			return fmt.Errorf("WithMaxResourceBytes(%d): cannot be negative", n)
+		}
+		if n == 0 {
+			n = DefaultMaxResourceBytes
+		}
+		c.maxResourceBytes = n
+		return nil
+	}
+}
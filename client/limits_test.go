@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/models"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		a              *ARN
+		wantMaxItems   int
+		wantInlineOnly bool
+	}{
+		{name: "Defaults", a: &ARN{store: &storage.Client{}}, wantMaxItems: DefaultMaxItems},
+		{name: "WithMaxItems", a: &ARN{maxItems: 10, store: &storage.Client{}}, wantMaxItems: 10},
+		{name: "Inline-only", a: &ARN{}, wantMaxItems: DefaultMaxItems, wantInlineOnly: true},
+	}
+
+	for _, test := range tests {
+		if got := test.a.MaxItems(); got != test.wantMaxItems {
+			t.Errorf("TestLimits(%s): got MaxItems() %d, want %d", test.name, got, test.wantMaxItems)
+		}
+		if got := test.a.InlineOnly(); got != test.wantInlineOnly {
+			t.Errorf("TestLimits(%s): got InlineOnly() %v, want %v", test.name, got, test.wantInlineOnly)
+		}
+	}
+}
+
+func TestWithPropertyLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithPropertyLimits(PropertyLimits{MaxBytes: -1}), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithPropertyLimits: invalid limits: got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, Args{}, WithPropertyLimits(PropertyLimits{}), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithPropertyLimits: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	n := validNotification(t)
+	if err := a.Notify(ctx, n); err != nil {
+		t.Errorf("TestWithPropertyLimits: Notify() within the limits: got err == %s, want err == nil", err)
+	}
+
+	n.Data[0].ArmResource.Properties = map[string]any{"load": math.Inf(1)}
+	err = a.Notify(ctx, n)
+	if err == nil || !strings.Contains(err.Error(), n.Data[0].ResourceID) {
+		t.Errorf("TestWithPropertyLimits: Notify() with an infinite float: got err == %v, want an error naming %s", err, n.Data[0].ResourceID)
+	}
+}
+
+func TestWithMaxResourceBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithMaxResourceBytes(-1), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithMaxResourceBytes: negative limit: got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, Args{}, WithMaxResourceBytes(1000), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithMaxResourceBytes: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	n := validNotification(t)
+	if err := a.Notify(ctx, n); err != nil {
+		t.Errorf("TestWithMaxResourceBytes: Notify() within the limit: got err == %s, want err == nil", err)
+	}
+
+	n.Data[0].ArmResource.Properties = map[string]any{"labels": strings.Repeat("x", 1000)}
+	err = a.Notify(ctx, n)
+	switch {
+	case err == nil || !strings.Contains(err.Error(), n.Data[0].ResourceID):
+		t.Errorf("TestWithMaxResourceBytes: Notify() over the limit: got err == %v, want an error naming %s", err, n.Data[0].ResourceID)
+	case !models.IsValidation(err):
+		t.Errorf("TestWithMaxResourceBytes: Notify() over the limit: got err == %s, want models.IsValidation(err)", err)
+	}
+}
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/storage"
+)
+
+// StorageAccessError is returned when blob storage refuses a request because of the storage account's
+// firewall, a private endpoint or missing role assignments. Its Guidance says how to fix it. Use
+// errors.As() to find it in a notification's error.
+type StorageAccessError = storage.AccessError
+
+// PreflightStorage checks that the client can upload to blob storage and that the host of the SAS link sent
+// to ARN resolves. Call it at startup to find storage firewall, Private Link and RBAC problems before a
+// large notification needs blob storage. It uploads a small blob. It returns an error if the client is
+// inline-only.
+func (a *ARN) PreflightStorage(ctx context.Context) error {
+	if a.store == nil {
+		return fmt.Errorf("the client has no blob storage, Args.Blob is not set")
+	}
+	return a.store.Preflight(ctx)
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestPreflightStorageInlineOnly(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(context.Background(), Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: struct{ azcore.TokenCredential }{}}})
+	if err != nil {
+		t.Fatalf("TestPreflightStorageInlineOnly: New() error: %v", err)
+	}
+	defer a.Close()
+
+	if err := a.PreflightStorage(context.Background()); err == nil {
+		t.Errorf("TestPreflightStorageInlineOnly: got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/internal/secretref"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// Preset holds the settings for an Azure cloud environment. Set Args.Preset to one of the values in
+// Presets to configure the token scope and cloud authority for that environment and to validate that
+// the endpoints you provide belong to it. This catches misconfigurations like using a Fairfax identity
+// against a Public endpoint.
+type Preset struct {
+	// Name is the name of the environment, like "Public".
+	Name string
+	// Cloud is the cloud configuration used for authentication.
+	Cloud cloud.Configuration
+	// Scope is the scope used to get tokens for the ARN receiver.
+	Scope string
+	// TenantID is the AAD tenant that identities in this environment get tokens from, if the environment
+	// has a single one. ArgsFromFile() uses it for Azure CLI credentials that do not set a tenant.
+	TenantID string
+	// ARNSuffixes are the host suffixes that ARN receiver endpoints have in this environment.
+	ARNSuffixes []string
+	// BlobSuffixes are the host suffixes that blob storage endpoints have in this environment.
+	BlobSuffixes []string
+}
+
+// Presets are the known Azure cloud environments.
+var Presets = struct {
+	// Public is the Azure public cloud (including canary).
+	Public Preset
+	// Fairfax is the Azure US Government cloud.
+	Fairfax Preset
+	// Mooncake is the Azure China cloud.
+	Mooncake Preset
+	// Dogfood is the ARN dogfood environment, which uses the public cloud authority.
+	// This is the same as DogfoodProd.
+	Dogfood Preset
+	// DogfoodProd is the ARN dogfood environment with identities in the production AAD cloud.
+	DogfoodProd Preset
+	// DogfoodPPE is the ARN dogfood environment with identities in the PPE AAD cloud.
+	DogfoodPPE Preset
+}{
+	Public: Preset{
+		Name:         "Public",
+		Cloud:        cloud.AzurePublic,
+		Scope:        http.ReceiverScope,
+		ARNSuffixes:  []string{".arn.core.windows.net"},
+		BlobSuffixes: []string{".blob.core.windows.net"},
+	},
+	Fairfax: Preset{
+		Name:         "Fairfax",
+		Cloud:        cloud.AzureGovernment,
+		Scope:        http.ReceiverScope,
+		ARNSuffixes:  []string{".arn.core.usgovcloudapi.net"},
+		BlobSuffixes: []string{".blob.core.usgovcloudapi.net"},
+	},
+	Mooncake: Preset{
+		Name:         "Mooncake",
+		Cloud:        cloud.AzureChina,
+		Scope:        http.ReceiverScope,
+		ARNSuffixes:  []string{".arn.core.chinacloudapi.cn"},
+		BlobSuffixes: []string{".blob.core.chinacloudapi.cn"},
+	},
+	Dogfood: Preset{
+		Name:         "Dogfood",
+		Cloud:        cloud.AzurePublic,
+		Scope:        http.ReceiverScope,
+		TenantID:     dogfoodProdTenant,
+		ARNSuffixes:  []string{".arn-df.core.windows.net"},
+		BlobSuffixes: []string{".blob.core.windows.net"},
+	},
+	DogfoodProd: Preset{
+		Name:         "DogfoodProd",
+		Cloud:        cloud.AzurePublic,
+		Scope:        http.ReceiverScope,
+		TenantID:     dogfoodProdTenant,
+		ARNSuffixes:  []string{".arn-df.core.windows.net"},
+		BlobSuffixes: []string{".blob.core.windows.net"},
+	},
+	DogfoodPPE: Preset{
+		Name:         "DogfoodPPE",
+		Cloud:        cloud.Configuration{ActiveDirectoryAuthorityHost: http.PPEAuthorityHost},
+		Scope:        http.ReceiverScope,
+		TenantID:     dogfoodPPETenant,
+		ARNSuffixes:  []string{".arn-df.core.windows.net"},
+		BlobSuffixes: []string{".blob.core.windows.net"},
+	},
+}
+
+// These are the tenants of the dogfood environments listed in internal/conn/http.
+const (
+	dogfoodProdTenant = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	dogfoodPPETenant  = "ea8a4392-515e-481f-879e-6571ff2a8a36"
+)
+
+// validate validates that the endpoints and cloud configurations in args match the preset.
+func (p *Preset) validate(args Args) error {
+	if p.Name == "" {
+		return fmt.Errorf("preset must have a name")
+	}
+	// A custom Sender does not need an endpoint, but one that is set must still belong to the cloud.
+	if args.HTTP.Sender == nil || args.HTTP.Endpoint != "" {
+		if err := matchSuffix(args.HTTP.Endpoint, p.ARNSuffixes); err != nil {
+			return fmt.Errorf("HTTP endpoint does not belong to the %s cloud: %w", p.Name, err)
+		}
+	}
+	if err := p.matchCloud(args.HTTP.Opts); err != nil {
+		return fmt.Errorf("HTTP options: %w", err)
+	}
+
+	if args.Blob.isZero() {
+		return nil
+	}
+	if err := matchSuffix(args.Blob.Endpoint, p.BlobSuffixes); err != nil {
+		return fmt.Errorf("blob endpoint does not belong to the %s cloud: %w", p.Name, err)
+	}
+	if err := p.matchCloud(args.Blob.Opts); err != nil {
+		return fmt.Errorf("blob options: %w", err)
+	}
+	return nil
+}
+
+// matchCloud validates that if a cloud is set in opts, it uses the same authority as the preset.
+func (p *Preset) matchCloud(opts *policy.ClientOptions) error {
+	if opts == nil || opts.Cloud.ActiveDirectoryAuthorityHost == "" {
+		return nil
+	}
+	if opts.Cloud.ActiveDirectoryAuthorityHost != p.Cloud.ActiveDirectoryAuthorityHost {
+		return fmt.Errorf("cloud authority %q does not match the %s cloud authority %q", opts.Cloud.ActiveDirectoryAuthorityHost, p.Name, p.Cloud.ActiveDirectoryAuthorityHost)
+	}
+	return nil
+}
+
+// clientOptions returns a copy of opts with the preset cloud set.
+func (p *Preset) clientOptions(opts *policy.ClientOptions) *policy.ClientOptions {
+	var o policy.ClientOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.Cloud = p.Cloud
+	return &o
+}
+
+// matchSuffix validates that the host of endpoint ends with one of the suffixes.
+func matchSuffix(endpoint string, suffixes []string) error {
+	// A Key Vault reference is checked once it is resolved.
+	if len(suffixes) == 0 || secretref.IsRef(endpoint) {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("endpoint(%s) is not a valid URL: %w", endpoint, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("endpoint host(%s) must end with one of %v", host, suffixes)
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+func TestPresetValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := Args{
+		HTTP: HTTPArgs{
+			Endpoint: "https://ms-containerservice.receiver.arn.core.windows.net",
+			Cred:     struct{ azcore.TokenCredential }{},
+		},
+		Blob: BlobArgs{
+			Endpoint: "https://account.blob.core.windows.net",
+			Cred:     struct{ azcore.TokenCredential }{},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		preset  Preset
+		args    func() Args
+		wantErr bool
+	}{
+		{
+			name:   "Error: Public preset with Fairfax ARN endpoint",
+			preset: Presets.Public,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.HTTP.Endpoint = "https://ms-containerservice.receiver.arn.core.usgovcloudapi.net"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Error: Public preset with Dogfood ARN endpoint",
+			preset: Presets.Public,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.HTTP.Endpoint = "https://ms-containerservice-df.receiver.arn-df.core.windows.net"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Error: Public preset with Mooncake blob endpoint",
+			preset: Presets.Public,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Blob.Endpoint = "https://account.blob.core.chinacloudapi.cn"
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Error: Public preset with Fairfax identity",
+			preset: Presets.Public,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.HTTP.Opts = &policy.ClientOptions{Cloud: cloud.AzureGovernment}
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Success: Dogfood",
+			preset: Presets.Dogfood,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.HTTP.Endpoint = "https://ms-containerservice-df.receiver.arn-df.core.windows.net"
+				return args
+			},
+		},
+		{
+			name:   "Error: DogfoodPPE preset with prod identity",
+			preset: Presets.DogfoodPPE,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.HTTP.Endpoint = "https://ms-containerservice-df.receiver.arn-df.core.windows.net"
+				args.HTTP.Opts = &policy.ClientOptions{Cloud: cloud.AzurePublic}
+				return args
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Success: DogfoodPPE",
+			preset: Presets.DogfoodPPE,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.HTTP.Endpoint = "https://ms-containerservice-df.receiver.arn-df.core.windows.net"
+				return args
+			},
+		},
+		{
+			name:   "Success: Public inline-only",
+			preset: Presets.Public,
+			args: func() Args {
+				args := copyStruct(valid)
+				args.Blob = BlobArgs{}
+				return args
+			},
+		},
+		{
+			name:   "Success: Public",
+			preset: Presets.Public,
+			args: func() Args {
+				return valid
+			},
+		},
+	}
+
+	for _, test := range tests {
+		args := test.args()
+		args.Preset = &test.preset
+		err := args.validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestPresetValidate(%s): got nil, want error", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestPresetValidate(%s): got %s, want nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+	}
+}
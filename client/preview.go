@@ -0,0 +1,25 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+)
+
+// PreviewVersions are the receiver preview versions that the events of a client may use. See
+// WithPreviewVersions().
+type PreviewVersions = envelope.PreviewVersions
+
+// WithPreviewVersions allows the events of this client to use the versions in p, which is how a publisher
+// trials a receiver preview that ARN has asked it to. Set the versions to send with the MetadataVersion and
+// DataVersion of msgs.Notifications. Only the versions listed are allowed, any other version still fails
+// validation. Other clients in the process are not affected.
+func WithPreviewVersions(p PreviewVersions) Option {
+	return func(c *ARN) error {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid preview versions: %w", err)
+		}
+		c.preview = &p
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithPreviewVersions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithPreviewVersions(PreviewVersions{MetadataVersions: []string{""}}), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithPreviewVersions: empty version: got err == nil, want err != nil")
+	}
+
+	preview, err := New(ctx, Args{}, WithPreviewVersions(PreviewVersions{MetadataVersions: []string{"1.1-preview"}}), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithPreviewVersions: New(): got err == %s, want err == nil", err)
+	}
+	defer preview.Close()
+	other, err := New(ctx, Args{}, WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithPreviewVersions: New() without previews: got err == %s, want err == nil", err)
+	}
+	defer other.Close()
+
+	n := validNotification(t)
+	n.MetadataVersion = "1.1-preview"
+	if err := preview.Notify(ctx, n); err != nil {
+		t.Errorf("TestWithPreviewVersions: Notify() with an allowed preview: got err == %s, want err == nil", err)
+	}
+	// The previews of one client do not change the validation of another.
+	if err := other.Notify(ctx, n); err == nil {
+		t.Errorf("TestWithPreviewVersions: Notify() on a client without previews: got err == nil, want err != nil")
+	}
+
+	n.MetadataVersion = "1.2-preview"
+	if err := preview.Notify(ctx, n); err == nil {
+		t.Errorf("TestWithPreviewVersions: Notify() with a preview that is not allowed: got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/provenance"
+)
+
+// Provenance is the identity of the process that sends notifications. See WithProvenance().
+type Provenance = provenance.Provenance
+
+const (
+	// ProvenancePodKey is the AdditionalBatchProperties key of Provenance.Pod.
+	ProvenancePodKey = provenance.KeyPod
+	// ProvenanceNodeKey is the AdditionalBatchProperties key of Provenance.Node.
+	ProvenanceNodeKey = provenance.KeyNode
+	// ProvenanceBuildKey is the AdditionalBatchProperties key of Provenance.Build.
+	ProvenanceBuildKey = provenance.KeyBuild
+)
+
+// WithProvenance stamps the AdditionalBatchProperties of every notification with the identity of the process,
+// so that ARN-side investigations can find the exact emitter of an event among thousands of agents. Fields of
+// p that are empty are detected when the client is created: the pod from $POD_NAME or the host name, the node
+// from $NODE_NAME and the build from the VCS revision in the binary's build info. Fields that are still empty
+// are not stamped. A key the notification already has in AdditionalBatchProperties.Others is not replaced, and
+// the caller's notification is not changed.
+func WithProvenance(p Provenance) Option {
+	return func(c *ARN) error {
+		p = p.Detect()
+		if p.IsZero() {
+			return fmt.Errorf("WithProvenance(): no process identity could be detected, set it in Provenance")
+		}
+		c.provenance = &p
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithProvenance(t *testing.T) {
+	t.Setenv("POD_NAME", "env-pod")
+	t.Setenv("NODE_NAME", "")
+
+	a := &ARN{}
+	if err := WithProvenance(Provenance{Build: "abc"})(a); err != nil {
+		t.Fatalf("TestWithProvenance: got err == %s, want err == nil", err)
+	}
+	want := Provenance{Pod: "env-pod", Build: "abc"}
+	if *a.provenance != want {
+		t.Errorf("TestWithProvenance: got %+v, want %+v", *a.provenance, want)
+	}
+
+	ctx := context.Background()
+	c, err := New(ctx, Args{}, WithProvenance(Provenance{Pod: "pod"}), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithProvenance: New() error: %v", err)
+	}
+	defer c.Close()
+	if err := c.Notify(ctx, validNotification(t)); err != nil {
+		t.Errorf("TestWithProvenance: Notify(): got err == %s, want err == nil", err)
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Azure/arn-sdk/internal/conn/quota"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// QuotaOptions are the ARN-assigned quota of the publisher and the thresholds to alert at.
+type QuotaOptions = quota.Options
+
+// QuotaAlert is passed to QuotaOptions.OnAlert when usage of a quota rises above a threshold.
+type QuotaAlert = quota.Alert
+
+// WithQuota tracks the events and bytes sent to ARN over a rolling minute against the quota ARN assigned to
+// the publisher. When usage rises above one of q.Thresholds, q.OnAlert is called and the
+// arn-sdk_quota_alert_total metric is incremented, so capacity can be planned before ARN throttles. If
+// q.OnAlert is nil, a warning is logged instead. Each event is counted once, retries and hedged requests
+// are not counted. This has no effect with WithFakeClients().
+func WithQuota(q QuotaOptions) Option {
+	return func(c *ARN) error {
+		c.quotaOpts = &q
+		return nil
+	}
+}
+
+// withQuota returns a copy of a with a quota tracker added to the HTTP client options.
+func (a Args) withQuota(q QuotaOptions, log *slog.Logger) (Args, error) {
+	if q.OnAlert == nil {
+		q.OnAlert = func(ctx context.Context, alert QuotaAlert) {
+			log.Warn(
+				"ARN quota usage is above threshold",
+				"kind", string(alert.Kind),
+				"threshold", alert.Threshold,
+				"used", alert.Used,
+				"limit", alert.Limit,
+			)
+		}
+	}
+	t, err := quota.New(q)
+	if err != nil {
+		return Args{}, fmt.Errorf("invalid quota: %w", err)
+	}
+
+	var o policy.ClientOptions
+	if a.HTTP.Opts != nil {
+		o = *a.HTTP.Opts
+	}
+	o.PerCallPolicies = append(append([]policy.Policy(nil), o.PerCallPolicies...), t)
+	a.HTTP.Opts = &o
+	return a, nil
+}
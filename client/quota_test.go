@@ -0,0 +1,34 @@
+package client
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestWithQuota(t *testing.T) {
+	t.Parallel()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	args := Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: struct{ azcore.TokenCredential }{}}}
+
+	got, err := args.withQuota(QuotaOptions{EventsPerMinute: 1000}, log)
+	if err != nil {
+		t.Fatalf("TestWithQuota: got err == %s, want err == nil", err)
+	}
+	if got.HTTP.Opts == nil || len(got.HTTP.Opts.PerCallPolicies) != 1 {
+		t.Errorf("TestWithQuota: quota tracker was not added to the HTTP per-call policies")
+	}
+	if args.HTTP.Opts != nil {
+		t.Errorf("TestWithQuota: the original Args were changed")
+	}
+	if !got.Blob.isZero() {
+		t.Errorf("TestWithQuota: blob args were set")
+	}
+
+	if _, err := args.withQuota(QuotaOptions{}, log); err == nil {
+		t.Errorf("TestWithQuota(no quota): got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/ratecoord"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// RateCoordination configures how a global rate limit is divided among the instances of a publisher, see
+// WithRateCoordination().
+type RateCoordination = ratecoord.Options
+
+// RateStore holds the state shared by the instances that coordinate their rate, such as a BlobRateStore.
+type RateStore = ratecoord.Store
+
+// RateShare is an instance's share of the global rate, see ARN.RateShare().
+type RateShare = ratecoord.Share
+
+// BlobRateStore is a RateStore in a blob in Azure Blob Storage.
+type BlobRateStore = ratecoord.BlobStore
+
+// NewBlobRateStore returns a RateStore in the blob at blobURL, such as
+// "https://account.blob.core.windows.net/container/arn-rate". The container must exist. All the instances that
+// share the rate must use the same blob.
+func NewBlobRateStore(blobURL string, cred azcore.TokenCredential) (*BlobRateStore, error) {
+	return ratecoord.NewBlobStore(blobURL, cred)
+}
+
+// WithRateCoordination divides the global rate of requests to ARN in r.EventsPerMinute among the instances
+// of a publisher that register in r.Store, so that hundreds of agents sharing one ARN endpoint are not
+// throttled together. Each instance gets half of an equal split plus a part of the other half by its demand,
+// which is recomputed every r.Interval. The instance first registers when the client is created, which waits
+// up to r.Interval for r.Store, and is removed when the client is closed. Requests wait until they fit in the instance's share, so set a
+// timeout on the notification's context. This has no effect with WithFakeClients().
+func WithRateCoordination(r RateCoordination) Option {
+	return func(c *ARN) error {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("invalid rate coordination: %w", err)
+		}
+		c.rateOpts = &r
+		return nil
+	}
+}
+
+// RateShare returns this instance's share of the global rate and true, or false if WithRateCoordination() is
+// not used. Thread-safe.
+func (a *ARN) RateShare() (RateShare, bool) {
+	if a.rateCoord == nil {
+		return RateShare{}, false
+	}
+	return a.rateCoord.Share(), true
+}
+
+// withRateCoordination returns a copy of a with a rate coordinator added to the HTTP client options.
+func (a Args) withRateCoordination(r RateCoordination, log *slog.Logger) (Args, *ratecoord.Coordinator, error) {
+	c, err := ratecoord.New(r, log)
+	if err != nil {
+		return Args{}, nil, fmt.Errorf("invalid rate coordination: %w", err)
+	}
+
+	var o policy.ClientOptions
+	if a.HTTP.Opts != nil {
+		o = *a.HTTP.Opts
+	}
+	o.PerCallPolicies = append(append([]policy.Policy(nil), o.PerCallPolicies...), c)
+	a.HTTP.Opts = &o
+	return a, c, nil
+}
+
+// closeRateCoord removes this instance from the rate coordination, so its share goes to the others.
+func (a *ARN) closeRateCoord() {
+	if a.rateCoord == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.rateCoord.Close(ctx); err != nil {
+		log := a.logger
+		if log == nil {
+			log = slog.Default()
+		}
+		log.Warn("ARN rate coordination could not remove this instance", "error", err.Error())
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// memRateStore is a RateStore in memory that never conflicts.
+type memRateStore struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (m *memRateStore) Read(context.Context) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data, "", nil
+}
+
+func (m *memRateStore) Write(_ context.Context, b []byte, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = b
+	return nil
+}
+
+func TestWithRateCoordination(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	args := Args{HTTP: HTTPArgs{Endpoint: "https://receiver.arn.core.windows.net", Cred: struct{ azcore.TokenCredential }{}}}
+
+	if err := WithRateCoordination(RateCoordination{Store: &memRateStore{}})(&ARN{}); err == nil {
+		t.Errorf("TestWithRateCoordination(no rate): got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, args)
+	if err != nil {
+		t.Fatalf("TestWithRateCoordination: New() error: %v", err)
+	}
+	if _, ok := a.RateShare(); ok {
+		t.Errorf("TestWithRateCoordination: without WithRateCoordination(): got ok == true, want false")
+	}
+	a.Close()
+
+	store := &memRateStore{}
+	a, err = New(ctx, args, WithRateCoordination(RateCoordination{EventsPerMinute: 600, Store: store}))
+	if err != nil {
+		t.Fatalf("TestWithRateCoordination: New() error: %v", err)
+	}
+	share, ok := a.RateShare()
+	if !ok || share != (RateShare{EventsPerMinute: 600, Instances: 1}) {
+		t.Errorf("TestWithRateCoordination: got %+v, %v, want all of the rate for a single instance", share, ok)
+	}
+	if len(store.data) == 0 {
+		t.Errorf("TestWithRateCoordination: the instance did not register in the store")
+	}
+	a.Close()
+
+	got, c, err := args.withRateCoordination(RateCoordination{EventsPerMinute: 600, Store: &memRateStore{}}, nil)
+	if err != nil {
+		t.Fatalf("TestWithRateCoordination: withRateCoordination() error: %v", err)
+	}
+	defer c.Close(ctx)
+	if n := len(got.HTTP.Opts.PerCallPolicies); n != 1 {
+		t.Errorf("TestWithRateCoordination: got %d per-call policies, want 1", n)
+	}
+	if args.HTTP.Opts != nil {
+		t.Errorf("TestWithRateCoordination: the original Args were changed")
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/retry/exponential"
+)
+
+// RetryPolicy is the retry policy used by every layer that sends a notification. See Args.Retry.
+type RetryPolicy = retry.Policy
+
+// resendOpts are set by WithRetry().
+type resendOpts struct {
+	policy      exponential.Policy
+	maxAttempts int
+}
+
+// WithRetry sends a notification again, with the exponential backoff of p, when sending it fails with a
+// transient error: a 408, 429 or 5xx response from the ARN receiver or blob storage, or a network error.
+// maxAttempts is the most times a notification is sent, including the first, and cannot be more than 10.
+// A Retry-After header on a response lengthens the backoff. The error of the last attempt is sent to the
+// notification's promise.
+//
+// Each attempt marshals the notification again and, if it is sent through blob storage, uploads a new blob.
+// This sits above the request retries of Args.Retry or the azcore clients, which retry a single request,
+// so a notification can be sent up to maxAttempts times the attempts of each request. Keep both small, and
+// bound the send with the notification's context or Args.Retry.Budget.
+func WithRetry(p exponential.Policy, maxAttempts int) Option {
+	return func(a *ARN) error {
+		if maxAttempts < 1 || maxAttempts > 10 {
+			return fmt.Errorf("WithRetry(): maxAttempts must be between 1 and 10")
+		}
+		if _, err := exponential.New(exponential.WithPolicy(p)); err != nil {
+			return fmt.Errorf("WithRetry(): %w", err)
+		}
+		a.resend = &resendOpts{policy: p, maxAttempts: maxAttempts}
+		return nil
+	}
+}
+
+// validateRetry validates the retry policy and that it does not conflict with the retry options of the
+// azcore clients.
+func validateRetry(r RetryPolicy, httpOpts, blobOpts *policy.ClientOptions) error {
+	if err := r.Defaults().Validate(); err != nil {
+		return err
+	}
+	if hasRetry(httpOpts) {
+		return fmt.Errorf("cannot set both Retry and HTTP.Opts.Retry")
+	}
+	if hasRetry(blobOpts) {
+		return fmt.Errorf("cannot set both Retry and Blob.Opts.Retry")
+	}
+	return nil
+}
+
+// hasRetry returns true if opts has any retry options set.
+func hasRetry(opts *policy.ClientOptions) bool {
+	if opts == nil {
+		return false
+	}
+	r := opts.Retry
+	return r.MaxRetries != 0 || r.TryTimeout != 0 || r.RetryDelay != 0 || r.MaxRetryDelay != 0 ||
+		r.StatusCodes != nil || r.ShouldRetry != nil
+}
+
+// retryOptions returns a copy of opts that uses r for retries.
+func retryOptions(r RetryPolicy, opts *policy.ClientOptions) *policy.ClientOptions {
+	var o policy.ClientOptions
+	if opts != nil {
+		o = *opts
+	}
+	o = r.ClientOptions(o)
+	return &o
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/retry/exponential"
+)
+
+// flakySender fails the first fails sends with a 503.
+type flakySender struct {
+	fails int32
+	sends atomic.Int32
+}
+
+func (s *flakySender) Send(ctx context.Context, event []byte) error {
+	if s.sends.Add(1) <= s.fails {
+		return &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}
+	}
+	return nil
+}
+
+func TestWithRetry(t *testing.T) {
+	t.Parallel()
+
+	p := exponential.Policy{InitialInterval: time.Millisecond, Multiplier: 2, MaxInterval: 5 * time.Millisecond}
+
+	tests := []struct {
+		name        string
+		p           exponential.Policy
+		maxAttempts int
+		fails       int32
+		wantSends   int32
+		wantErr     bool
+		wantSendErr bool
+	}{
+		{name: "Success after two 503s", p: p, maxAttempts: 3, fails: 2, wantSends: 3},
+		{name: "Error: out of attempts", p: p, maxAttempts: 2, fails: 2, wantSends: 2, wantSendErr: true},
+		{name: "Error: maxAttempts is 0", p: p, maxAttempts: 0, wantErr: true},
+		{name: "Error: maxAttempts over 10", p: p, maxAttempts: 11, wantErr: true},
+		{name: "Error: invalid policy", p: exponential.Policy{}, maxAttempts: 3, wantErr: true},
+	}
+
+	for _, test := range tests {
+		s := &flakySender{fails: test.fails}
+		a, err := New(context.Background(), Args{}, WithFakeClients(s, fakeUploader{}), WithRetry(test.p, test.maxAttempts))
+		switch {
+		case test.wantErr && err == nil:
+			a.Close()
+			t.Errorf("TestWithRetry(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithRetry(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		err = a.Notify(context.Background(), validNotification(t))
+		a.Close()
+		switch {
+		case test.wantSendErr && err == nil:
+			t.Errorf("TestWithRetry(%s): got Notify() err == nil, want err != nil", test.name)
+		case !test.wantSendErr && err != nil:
+			t.Errorf("TestWithRetry(%s): got Notify() err == %s, want err == nil", test.name, err)
+		}
+		if got := s.sends.Load(); got != test.wantSends {
+			t.Errorf("TestWithRetry(%s): got %d sends, want %d", test.name, got, test.wantSends)
+		}
+	}
+}
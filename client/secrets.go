@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/secretref"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// DefaultSecretTimeout is the default time SecretResolution allows to resolve all the references once.
+const DefaultSecretTimeout = 30 * time.Second
+
+// SecretResolution resolves Key Vault references in Args. See WithSecretResolution().
+type SecretResolution struct {
+	// Cred reads the secrets. It needs the "Key Vault Secrets User" role, or the "get" secret permission, on
+	// each vault that is referenced.
+	Cred azcore.TokenCredential
+	// Opts are options for the azcore HTTP client that talks to Key Vault. Can be nil.
+	Opts *policy.ClientOptions
+	// Refresh is how often the references are resolved again after New(). A changed HTTP.Endpoint or
+	// Blob.Endpoint is used for the requests that start after it is resolved. Other fields cannot change
+	// while the client runs, so a change to them is logged and applied by the next client that is created.
+	// If 0, the references are only resolved in New().
+	Refresh time.Duration
+	// Timeout bounds each resolution of all the references. Defaults to DefaultSecretTimeout.
+	Timeout time.Duration
+	// OnRefresh, if set, is called after each refresh with the Args fields whose values changed, like
+	// "HTTP.Endpoint", and the error of the refresh, if any. Fields that changed but could not be applied are
+	// not in changed and are retried on the next refresh.
+	OnRefresh func(changed []string, err error)
+}
+
+// validate validates the SecretResolution.
+func (s SecretResolution) validate() error {
+	if s.Cred == nil {
+		return fmt.Errorf("cred cannot be nil")
+	}
+	if s.Refresh < 0 {
+		return fmt.Errorf("refresh cannot be negative")
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+// WithSecretResolution resolves Key Vault references in Args when the client is created, and again every
+// r.Refresh, so that deployment manifests can name secrets instead of embedding environment-specific values.
+// These Args fields can be references: HTTP.Endpoint, HTTP.ReceiverPath, Blob.Endpoint and Blob.ContainerExt.
+// A reference uses the App Service syntax, with an optional secret version:
+//
+//	@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/arn-endpoint)
+//
+// New() fails if a reference cannot be resolved. A refresh that fails keeps the values that are in use.
+// Without this option, New() fails if Args has a reference.
+func WithSecretResolution(r SecretResolution) Option {
+	return func(c *ARN) error {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("invalid secret resolution: %w", err)
+		}
+		if r.Timeout == 0 {
+			r.Timeout = DefaultSecretTimeout
+		}
+		c.secretOpts = &r
+		return nil
+	}
+}
+
+// secretField is a field of Args that can be a Key Vault reference.
+type secretField struct {
+	name  string
+	field func(a *Args) *string
+}
+
+// secretFields are the fields of Args that can be Key Vault references.
+var secretFields = []secretField{
+	{name: "HTTP.Endpoint", field: func(a *Args) *string { return &a.HTTP.Endpoint }},
+	{name: "HTTP.ReceiverPath", field: func(a *Args) *string { return &a.HTTP.ReceiverPath }},
+	{name: "Blob.Endpoint", field: func(a *Args) *string { return &a.Blob.Endpoint }},
+	{name: "Blob.ContainerExt", field: func(a *Args) *string { return &a.Blob.ContainerExt }},
+}
+
+// secretRef returns the name of the first field of a that is a Key Vault reference.
+func (a Args) secretRef() (string, bool) {
+	for _, f := range secretFields {
+		if secretref.IsRef(*f.field(&a)) {
+			return f.name, true
+		}
+	}
+	return "", false
+}
+
+// secrets resolves the Key Vault references in Args, see WithSecretResolution().
+type secrets struct {
+	opts     SecretResolution
+	resolver *secretref.Resolver
+	preset   *Preset
+	log      *slog.Logger
+
+	// refs are the references by field name. values are the values in use by field name.
+	refs   map[string]string
+	values map[string]string
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// resolveSecrets returns args with its Key Vault references resolved. The references are kept in a.secrets
+// for refreshes.
+func (a *ARN) resolveSecrets(ctx context.Context, args Args, log *slog.Logger) (Args, error) {
+	r, err := secretref.New(a.secretOpts.Cred, a.secretOpts.Opts)
+	if err != nil {
+		return Args{}, err
+	}
+	s := &secrets{
+		opts:     *a.secretOpts,
+		resolver: r,
+		preset:   args.Preset,
+		log:      log,
+		refs:     map[string]string{},
+	}
+	for _, f := range secretFields {
+		if v := *f.field(&args); secretref.IsRef(v) {
+			s.refs[f.name] = v
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	s.values, err = s.resolve(ctx)
+	if err != nil {
+		return Args{}, err
+	}
+	for _, f := range secretFields {
+		if v, ok := s.values[f.name]; ok {
+			*f.field(&args) = v
+		}
+	}
+	a.secrets = s
+	return args, nil
+}
+
+// resolve returns the values of the references by field name.
+func (s *secrets) resolve(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string, len(s.refs))
+	// A secret that is referenced by more than one field is only read once.
+	byRef := map[string]string{}
+	for name, ref := range s.refs {
+		v, ok := byRef[ref]
+		if !ok {
+			var err error
+			v, err = s.resolver.Resolve(ctx, ref)
+			if err != nil {
+				return nil, fmt.Errorf("could not resolve Args.%s: %w", name, err)
+			}
+			byRef[ref] = v
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// startSecretRefresh refreshes the references every SecretResolution.Refresh until closeSecrets().
+func (a *ARN) startSecretRefresh() {
+	s := a.secrets
+	if s == nil || s.opts.Refresh == 0 || len(s.refs) == 0 {
+		return
+	}
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go func() {
+		defer close(s.stopped)
+		t := time.NewTicker(s.opts.Refresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-t.C:
+			}
+			changed, err := a.refreshSecrets()
+			if err != nil {
+				s.log.Warn("ARN could not refresh Key Vault references in Args", "error", err.Error())
+			}
+			if s.opts.OnRefresh != nil {
+				s.opts.OnRefresh(changed, err)
+			}
+		}
+	}()
+}
+
+// refreshSecrets resolves the references again and applies the values that changed. It returns the fields
+// whose new values are in use.
+func (a *ARN) refreshSecrets() ([]string, error) {
+	s := a.secrets
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	values, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, f := range secretFields {
+		v, ok := values[f.name]
+		if !ok || v == s.values[f.name] {
+			continue
+		}
+		if err := a.applySecret(f.name, v); err != nil {
+			return changed, fmt.Errorf("could not use the new value of Args.%s: %w", f.name, err)
+		}
+		s.values[f.name] = v
+		changed = append(changed, f.name)
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// applySecret uses v as the value of the field name from now on.
+func (a *ARN) applySecret(name, v string) error {
+	s := a.secrets
+	switch name {
+	case "HTTP.Endpoint":
+		if s.preset != nil {
+			if err := matchSuffix(v, s.preset.ARNSuffixes); err != nil {
+				return err
+			}
+		}
+		return a.http.SetEndpoint(v)
+	case "Blob.Endpoint":
+		if a.store == nil {
+			return fmt.Errorf("client is in inline-only mode")
+		}
+		if s.preset != nil {
+			if err := matchSuffix(v, s.preset.BlobSuffixes); err != nil {
+				return err
+			}
+		}
+		return a.store.UpdateEndpoint(v)
+	}
+	s.log.Warn("Key Vault reference in Args changed, create a new client to use the new value", "field", name)
+	return nil
+}
+
+// closeSecrets stops refreshing the references.
+func (a *ARN) closeSecrets() {
+	if a.secrets == nil || a.secrets.done == nil {
+		return
+	}
+	close(a.secrets.done)
+	<-a.secrets.stopped
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+type secretCred struct{}
+
+func (secretCred) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestWithSecretResolution(t *testing.T) {
+	t.Parallel()
+
+	// The endpoint secret changes after it is first read.
+	var reads atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/secrets/endpoint":
+			host := "receiver"
+			if reads.Add(1) > 1 {
+				host = "other"
+			}
+			fmt.Fprintf(w, `{"value": "https://%s.arn.core.windows.net"}`, host)
+		case "/secrets/ext":
+			w.Write([]byte(`{"value": "team"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ref := func(name string) string {
+		return "@Microsoft.KeyVault(SecretUri=" + srv.URL + "/secrets/" + name + ")"
+	}
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{HTTP: HTTPArgs{Endpoint: ref("endpoint")}}, WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithSecretResolution: reference without WithSecretResolution(): got err == nil, want err != nil")
+	}
+	if _, err := New(ctx, Args{}, WithSecretResolution(SecretResolution{})); err == nil {
+		t.Errorf("TestWithSecretResolution: no cred: got err == nil, want err != nil")
+	}
+
+	opts := &policy.ClientOptions{Transport: srv.Client()}
+	_, err := New(
+		ctx,
+		Args{HTTP: HTTPArgs{Endpoint: ref("missing")}},
+		WithSecretResolution(SecretResolution{Cred: secretCred{}, Opts: opts}),
+		WithFakeClients(fakeSender{}, fakeUploader{}),
+	)
+	if err == nil {
+		t.Errorf("TestWithSecretResolution: missing secret: got err == nil, want err != nil")
+	}
+
+	args := Args{HTTP: HTTPArgs{Endpoint: ref("endpoint")}, Blob: BlobArgs{ContainerExt: ref("ext")}}
+	a, err := New(ctx, args, WithSecretResolution(SecretResolution{Cred: secretCred{}, Opts: opts}), WithFakeClients(fakeSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithSecretResolution: New(): got err == %s, want err == nil", err)
+	}
+	if got := a.secrets.values["HTTP.Endpoint"]; got != "https://receiver.arn.core.windows.net" {
+		t.Errorf("TestWithSecretResolution: got HTTP.Endpoint %q, want the resolved secret", got)
+	}
+	if got := a.secrets.values["Blob.ContainerExt"]; got != "team" {
+		t.Errorf("TestWithSecretResolution: got Blob.ContainerExt %q, want the resolved secret", got)
+	}
+	a.Close()
+
+	reads.Store(0)
+	refreshed := make(chan []string, 10)
+	a, err = New(
+		ctx,
+		args,
+		WithSecretResolution(SecretResolution{
+			Cred:    secretCred{},
+			Opts:    opts,
+			Refresh: 10 * time.Millisecond,
+			OnRefresh: func(changed []string, err error) {
+				if err != nil {
+					t.Errorf("TestWithSecretResolution: refresh: got err == %s, want err == nil", err)
+				}
+				select {
+				case refreshed <- changed:
+				default:
+				}
+			},
+		}),
+		WithFakeClients(fakeSender{}, fakeUploader{}),
+	)
+	if err != nil {
+		t.Fatalf("TestWithSecretResolution: New() with refresh: got err == %s, want err == nil", err)
+	}
+
+	select {
+	case changed := <-refreshed:
+		if !slices.Equal(changed, []string{"HTTP.Endpoint"}) {
+			t.Errorf("TestWithSecretResolution: got changed fields %v, want [HTTP.Endpoint]", changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestWithSecretResolution: the references were not refreshed")
+	}
+	if err := a.Notify(ctx, validNotification(t)); err != nil {
+		t.Errorf("TestWithSecretResolution: Notify(): got err == %s, want err == nil", err)
+	}
+
+	a.Drain(ctx)
+	select {
+	case <-a.secrets.stopped:
+	default:
+		t.Errorf("TestWithSecretResolution: the references are still refreshed after Drain()")
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// recordSender is a transport.Sender that records the events it is given.
+type recordSender struct {
+	mu     sync.Mutex
+	events [][]byte
+}
+
+func (r *recordSender) Send(ctx context.Context, event []byte, headers []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, append([]byte(nil), event...))
+	return nil
+}
+
+func TestCustomSender(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rs := &recordSender{}
+	a, err := New(ctx, Args{HTTP: HTTPArgs{Sender: rs}})
+	if err != nil {
+		t.Fatalf("TestCustomSender: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	if err := a.Notify(ctx, validNotification(t)); err != nil {
+		t.Fatalf("TestCustomSender: Notify(): got err == %s, want err == nil", err)
+	}
+	rs.mu.Lock()
+	got := len(rs.events)
+	rs.mu.Unlock()
+	if got != 1 {
+		t.Errorf("TestCustomSender: custom sender got %d events, want 1", got)
+	}
+
+	if err := a.UpdateCredentials(struct{ azcore.TokenCredential }{}, nil); err == nil {
+		t.Errorf("TestCustomSender: UpdateCredentials(): got err == nil, want err != nil")
+	}
+
+	// WithQuota() adds an azcore policy, which the custom sender would not run.
+	if _, err := New(ctx, Args{HTTP: HTTPArgs{Sender: rs}}, WithQuota(QuotaOptions{EventsPerMinute: 1000})); err == nil {
+		t.Errorf("TestCustomSender(WithQuota): got err == nil, want err != nil")
+	}
+}
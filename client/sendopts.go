@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// SendOptions tune the requests made to send a single notification, to the ARN receiver and to blob storage.
+// Use WithSendOptions() to attach them to the context passed to Notify() or Async().
+type SendOptions struct {
+	// Header is added to every request made to send the notification. This uses policy.WithHTTPHeader(), so
+	// it replaces any header set on the context with it.
+	Header http.Header
+	// MaxAttempts is the maximum number of attempts for each request, including the first. This replaces
+	// RetryPolicy.MaxAttempts. Cannot be more than 10.
+	MaxAttempts int
+	// TryTimeout is the timeout of each attempt. An attempt that times out is retried. By default attempts
+	// are only limited by the context and RetryPolicy.Budget.
+	TryTimeout time.Duration
+}
+
+// WithSendOptions returns a context that applies o to the notification that is sent with it.
+// If Args.Retry is not set, the azcore retries are in use and setting MaxAttempts or TryTimeout replaces the
+// azcore RetryOptions of the clients with the azcore defaults for the other fields.
+func WithSendOptions(ctx context.Context, o SendOptions) (context.Context, error) {
+	ro := retry.Override{MaxAttempts: o.MaxAttempts, TryTimeout: o.TryTimeout}
+	if err := ro.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid SendOptions: %w", err)
+	}
+
+	if o.Header != nil {
+		ctx = policy.WithHTTPHeader(ctx, o.Header.Clone())
+	}
+	if ro == (retry.Override{}) {
+		return ctx, nil
+	}
+	// Used by the SDK retry policy (Args.Retry), which overrides the azcore retry options below.
+	ctx = retry.WithOverride(ctx, ro)
+
+	azOpts := policy.RetryOptions{TryTimeout: o.TryTimeout}
+	switch o.MaxAttempts {
+	case 0:
+	case 1:
+		azOpts.MaxRetries = -1
+	default:
+		azOpts.MaxRetries = int32(o.MaxAttempts - 1)
+	}
+	return policy.WithRetryOptions(ctx, azOpts), nil
+}
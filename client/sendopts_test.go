@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// countTransport returns status to every request and records the requests it receives.
+type countTransport struct {
+	status int
+	reqs   []*http.Request
+}
+
+func (c *countTransport) Do(req *http.Request) (*http.Response, error) {
+	c.reqs = append(c.reqs, req)
+	return &http.Response{StatusCode: c.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestWithSendOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		o          SendOptions
+		retry      *RetryPolicy
+		wantCalls  int
+		wantHeader string
+		wantErr    bool
+	}{
+		{name: "Error: negative MaxAttempts", o: SendOptions{MaxAttempts: -1}, wantErr: true},
+		{name: "Error: too many attempts", o: SendOptions{MaxAttempts: 11}, wantErr: true},
+		{name: "Error: negative TryTimeout", o: SendOptions{TryTimeout: -time.Second}, wantErr: true},
+		{
+			name:       "Header",
+			o:          SendOptions{Header: http.Header{"X-Test": []string{"value"}}, MaxAttempts: 1},
+			wantCalls:  1,
+			wantHeader: "value",
+		},
+		{name: "MaxAttempts with azcore retries", o: SendOptions{MaxAttempts: 2}, wantCalls: 2},
+		{name: "No retries with azcore retries", o: SendOptions{MaxAttempts: 1}, wantCalls: 1},
+		{name: "MaxAttempts with Args.Retry", o: SendOptions{MaxAttempts: 2}, retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, wantCalls: 2},
+		{name: "Args.Retry without MaxAttempts", o: SendOptions{TryTimeout: time.Second}, retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, wantCalls: 3},
+	}
+
+	for _, test := range tests {
+		ctx, err := WithSendOptions(context.Background(), test.o)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithSendOptions(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithSendOptions(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		ct := &countTransport{status: http.StatusServiceUnavailable}
+		opts := &policy.ClientOptions{Transport: ct, Retry: policy.RetryOptions{RetryDelay: time.Millisecond}}
+		if test.retry != nil {
+			opts = retryOptions(*test.retry, &policy.ClientOptions{Transport: ct})
+		}
+		pl := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, opts)
+		req, err := runtime.NewRequest(ctx, http.MethodPost, "https://localhost/arnnotify")
+		if err != nil {
+			panic(err)
+		}
+		if _, err := pl.Do(req); err != nil {
+			t.Errorf("TestWithSendOptions(%s): pipeline error: %s", test.name, err)
+			continue
+		}
+
+		if len(ct.reqs) != test.wantCalls {
+			t.Errorf("TestWithSendOptions(%s): got %d calls, want %d", test.name, len(ct.reqs), test.wantCalls)
+		}
+		if got := ct.reqs[0].Header.Get("X-Test"); got != test.wantHeader {
+			t.Errorf("TestWithSendOptions(%s): got X-Test header %q, want %q", test.name, got, test.wantHeader)
+		}
+	}
+}
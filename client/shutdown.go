@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShutdownReport is what happened to the notifications that were waiting when Drain() was called. Log it
+// when a publisher shuts down, so notifications that were lost can be found after an incident.
+type ShutdownReport struct {
+	// Duration is how long the drain took.
+	Duration time.Duration
+	// Complete is true if every notification was handled before the drain's context ended. If false,
+	// the notifications that were left were dropped.
+	Complete bool
+	// Delivered is the number of notifications sent during the drain.
+	Delivered int64
+	// Dropped is the number of notifications that were not sent because the drain ran out of time. These
+	// failed with models.ErrShutdown.
+	Dropped int64
+	// Failed is the number of notifications that failed during the drain for other reasons.
+	Failed int64
+	// BlobUploadsAborted is the number of blob uploads that were cut short during the drain.
+	BlobUploadsAborted int64
+	// LastErrors are the most recent errors of notifications that failed during the drain, oldest first.
+	// At most MaxRecentErrors are kept.
+	LastErrors []ErrorRecord
+}
+
+// String implements fmt.Stringer.
+func (r ShutdownReport) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "drain complete %v after %v: %d delivered, %d dropped, %d failed, %d blob uploads aborted",
+		r.Complete, r.Duration, r.Delivered, r.Dropped, r.Failed, r.BlobUploadsAborted)
+	if len(r.LastErrors) > 0 {
+		fmt.Fprintf(&b, ", last error: %v", r.LastErrors[len(r.LastErrors)-1].Err)
+	}
+	return b.String()
+}
+
+// Drain closes the client like Close() and waits for the notifications that are waiting to be sent, until
+// ctx ends. Notifications that are left when ctx ends, including the one being sent, fail with
+// models.ErrShutdown. Call either Drain() or Close(), not both.
+func (a *ARN) Drain(ctx context.Context) ShutdownReport {
+	if a.conn == nil {
+		a.Close()
+		return ShutdownReport{Complete: true}
+	}
+
+	start := time.Now()
+	// Only the notifications handled once the drain starts are reported, not those of earlier sends.
+	tally := a.conn.TeeStats()
+
+	// If ctx ends while the sender is blocked handing a notification to the conn, the notification fails
+	// instead of waiting.
+	stop := context.AfterFunc(ctx, a.conn.Abort)
+	defer stop()
+
+	if a.batcher != nil {
+		a.batcher.close()
+	}
+	close(a.in)
+	if a.sigSenderClosed != nil {
+		<-a.sigSenderClosed
+	}
+	drained := a.conn.Drain(ctx)
+	a.closeComponents()
+
+	st := tally.Stats()
+	r := ShutdownReport{
+		Duration:           time.Since(start),
+		Delivered:          st.Sent,
+		Dropped:            st.Failures[FailShutdown],
+		Failed:             st.Failed - st.Failures[FailShutdown],
+		BlobUploadsAborted: st.BlobUploadsAborted,
+		LastErrors:         st.RecentErrors,
+	}
+	r.Complete = drained && r.Dropped == 0
+	return r
+}
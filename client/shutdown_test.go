@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+// blockingSender blocks each send until the send's context ends.
+type blockingSender struct{}
+
+func (blockingSender) Send(ctx context.Context, event []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// gatedSender blocks each send until release is closed. started is closed by the first send.
+type gatedSender struct {
+	once    *sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedSender() gatedSender {
+	return gatedSender{once: &sync.Once{}, started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (g gatedSender) Send(ctx context.Context, event []byte) error {
+	g.once.Do(func() { close(g.started) })
+	<-g.release
+	return nil
+}
+
+// validNotification returns a notification that passes validation.
+func validNotification(t *testing.T) msgs.Notifications {
+	t.Helper()
+
+	id, err := arm.ParseResourceID("/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+	if err != nil {
+		t.Fatalf("arm.ParseResourceID(): %v", err)
+	}
+	ar, err := types.NewArmResource(types.ActWrite, id, "2024-01-01", map[string]any{"a": 1})
+	if err != nil {
+		t.Fatalf("types.NewArmResource(): %v", err)
+	}
+	return msgs.Notifications{
+		ResourceLocation: "eastus",
+		PublisherInfo:    "Microsoft.ContainerService",
+		Data: []types.NotificationResource{
+			{
+				ResourceID:               id.String(),
+				APIVersion:               "2024-01-01",
+				ResourceEventTime:        time.Now().UTC(),
+				ArmResource:              ar,
+				ResourceSystemProperties: types.ResourceSystemProperties{ChangeAction: types.CAUpdate},
+			},
+		},
+	}
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	sender := newGatedSender()
+	a, err := New(context.Background(), Args{}, WithFakeClients(sender, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestDrain: New() error: %v", err)
+	}
+	ns := []models.Notifications{
+		a.Async(context.Background(), validNotification(t), true),
+		a.Async(context.Background(), validNotification(t), true),
+	}
+	<-sender.started
+
+	// The sends finish only once the drain has closed the client's input, so both are in the report.
+	reports := make(chan ShutdownReport, 1)
+	go func() { reports <- a.Drain(context.Background()) }()
+	<-a.sigSenderClosed
+	close(sender.release)
+
+	r := <-reports
+	if !r.Complete || r.Delivered != 2 || r.Dropped != 0 || r.Failed != 0 || len(r.LastErrors) != 0 {
+		t.Errorf("TestDrain: got report %s with %d errors, want complete with 2 delivered", r, len(r.LastErrors))
+	}
+	for i, n := range ns {
+		if err := n.Promise(context.Background()); err != nil {
+			t.Errorf("TestDrain: notification %d: got err == %s, want err == nil", i, err)
+		}
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(context.Background(), Args{}, WithFakeClients(blockingSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestDrainTimeout: New() error: %v", err)
+	}
+	ns := []models.Notifications{
+		a.Async(context.Background(), validNotification(t), true),
+		a.Async(context.Background(), validNotification(t), true),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := a.Drain(ctx)
+	if r.Complete || r.Delivered != 0 || r.Dropped != 2 || r.Failed != 0 {
+		t.Errorf("TestDrainTimeout: got report %s, want incomplete with 2 dropped", r)
+	}
+	if len(r.LastErrors) != 2 {
+		t.Errorf("TestDrainTimeout: got %d LastErrors, want 2", len(r.LastErrors))
+	}
+	for i, n := range ns {
+		if err := n.Promise(context.Background()); !errors.Is(err, models.ErrShutdown) {
+			t.Errorf("TestDrainTimeout: notification %d: got err == %v, want models.ErrShutdown", i, err)
+		}
+	}
+}
+
+// TestDrainStops is not parallel, so the goroutines it counts are its own.
+func TestDrainStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	a, err := New(context.Background(), Args{}, WithFakeClients(fakeSender{}, fakeUploader{}), WithSpoolDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("TestDrainStops: New() error: %v", err)
+	}
+	a.Drain(context.Background())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("TestDrainStops: got %d goroutines after Drain(), want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/skew"
+)
+
+// TimeSkew are how far the times in a notification can be from the current time. See WithTimeSkew().
+type TimeSkew = skew.Bounds
+
+// SkewAction is what is done with a time that is outside the TimeSkew.
+type SkewAction = skew.Action
+
+const (
+	// SkewReject fails the notification.
+	SkewReject = skew.Reject
+	// SkewClamp moves the time to the nearest bound. A local clock that is off is corrected to the
+	// receiver's clock for the EventTime.
+	SkewClamp = skew.Clamp
+	// SkewWarn logs a warning and sends the time as is.
+	SkewWarn = skew.Warn
+)
+
+// WithTimeSkew checks the EventTime and each resource's ResourceEventTime of every notification against s
+// before it is sent. Some ARN receivers drop events with times too far in the past or future without an
+// error, which a node with a drifting clock runs into. The current time is the ARN receiver's clock, from the
+// Date header of its responses, once one has been received. Until then it is the local clock. The EventTime
+// is set from the local clock, so checking it catches a drifting local clock.
+func WithTimeSkew(s TimeSkew) Option {
+	return func(c *ARN) error {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("invalid time skew: %w", err)
+		}
+		c.skew = &s
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithTimeSkew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		s       TimeSkew
+		wantErr bool
+	}{
+		{name: "Reject", s: TimeSkew{MaxPast: time.Hour, MaxFuture: time.Minute}},
+		{name: "Clamp", s: TimeSkew{MaxPast: time.Hour, Action: SkewClamp}},
+		{name: "Error: no bounds", s: TimeSkew{Action: SkewWarn}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		a, err := New(context.Background(), Args{}, WithTimeSkew(test.s), WithFakeClients(fakeSender{}, fakeUploader{}))
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithTimeSkew(%s): got err == nil, want err != nil", test.name)
+			a.Close()
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithTimeSkew(%s): got err == %s, want err == nil", test.name, err)
+		case err == nil:
+			a.Close()
+		}
+	}
+}
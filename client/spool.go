@@ -0,0 +1,36 @@
+package client
+
+import (
+	"fmt"
+)
+
+// WithSpoolDir spools events to files in dir while the ARN receiver is unreachable, instead of failing them
+// or holding them in memory. An event whose send fails with a network error or a 502, 503 or 504 response,
+// after any retries, is written to dir and its notification fails with an error wrapping models.ErrSpooled.
+// While events are spooled, new events are written after them, and a background goroutine sends the spooled
+// events in order once the receiver is reachable again. Do not send a spooled notification again, it will
+// be sent from the spool.
+//
+// dir is created if it does not exist and must not be shared with another client. Events left in dir when
+// the client is closed are sent by the next client that uses it. The spool holds up to 1 GiB of events, after
+// which notifications fail as they would without it. Only the event sent to the receiver is spooled: blob
+// storage uploads that fail are not, and the SAS links of events whose resources were uploaded can expire in
+// a long outage. Spooled notifications do not count as failures for WithCircuitBreaker(). See Spooled().
+func WithSpoolDir(dir string) Option {
+	return func(c *ARN) error {
+		if dir == "" {
+			return fmt.Errorf("WithSpoolDir(): dir cannot be empty")
+		}
+		c.spoolDir = dir
+		return nil
+	}
+}
+
+// Spooled returns the number of events in the spool waiting to be sent. It is always 0 if WithSpoolDir() is
+// not used. Thread-safe.
+func (a *ARN) Spooled() int {
+	if a.spool == nil {
+		return 0
+	}
+	return a.spool.Len()
+}
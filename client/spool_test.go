@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+// downSender fails every send with a network error.
+type downSender struct{}
+
+func (downSender) Send(ctx context.Context, event []byte) error {
+	return &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+}
+
+func TestWithSpoolDir(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if _, err := New(ctx, Args{}, WithSpoolDir(""), WithFakeClients(fakeSender{}, fakeUploader{})); err == nil {
+		t.Errorf("TestWithSpoolDir(empty dir): got err == nil, want err != nil")
+	}
+
+	a, err := New(ctx, Args{}, WithSpoolDir(t.TempDir()), WithFakeClients(downSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestWithSpoolDir: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close()
+
+	err = a.Notify(ctx, validNotification(t))
+	if !errors.Is(err, models.ErrSpooled) {
+		t.Fatalf("TestWithSpoolDir: Notify() while ARN is unreachable: got err == %v, want models.ErrSpooled", err)
+	}
+	if models.IsRetryable(err) {
+		t.Errorf("TestWithSpoolDir: a spooled notification is models.IsRetryable(), want it not to be")
+	}
+	if got := a.Spooled(); got != 1 {
+		t.Errorf("TestWithSpoolDir: got Spooled() == %d, want 1", got)
+	}
+	if got := a.Stats().Failures[FailSpooled]; got != 1 {
+		t.Errorf("TestWithSpoolDir: got %d spooled failures in Stats(), want 1", got)
+	}
+}
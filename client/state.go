@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/state"
+	"github.com/Azure/arn-sdk/models"
+)
+
+// StateStore persists the payload hash of the last delivered version of each resource. Implement it to keep
+// the state cache across restarts, such as in a file or a database. See WithStateCache().
+type StateStore = state.Store
+
+// NewMemStateStore returns a StateStore that keeps the hashes in memory. It is not persisted.
+func NewMemStateStore() StateStore {
+	return state.NewMemStore()
+}
+
+// WithStateCache records the payload hash of every resource in each delivered notification in store, so
+// LastDelivered() and Seen() can tell whether ARN has already received a version of a resource. If store
+// is nil, the hashes are kept in memory. Hashing serializes each resource again after it is delivered. A
+// failure to record a hash is logged and does not fail the notification.
+func WithStateCache(store StateStore) Option {
+	return func(c *ARN) error {
+		c.state = state.New(store)
+		return nil
+	}
+}
+
+// LastDelivered returns the payload hash of the last version of the resource with id that was delivered. ok
+// is false if no version of the resource was recorded. The hash can be compared with the ones from
+// Notifications.PayloadHashes(). Requires WithStateCache().
+func (a *ARN) LastDelivered(ctx context.Context, id string) (h models.PayloadHash, ok bool, err error) {
+	if a.state == nil {
+		return models.PayloadHash{}, false, fmt.Errorf("the client was created without WithStateCache()")
+	}
+	return a.state.Last(ctx, id)
+}
+
+// Seen returns true if every resource in n is the version that was last delivered for it, that is ARN has
+// already seen this version of each resource. Requires WithStateCache().
+func (a *ARN) Seen(ctx context.Context, n models.Notifications) (bool, error) {
+	if a.state == nil {
+		return false, fmt.Errorf("the client was created without WithStateCache()")
+	}
+	return a.state.Seen(ctx, n)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"github.com/Azure/arn-sdk/internal/conn/stats"
+)
+
+// Stats are statistics about the notifications sent by a client since it was created.
+type Stats = stats.Stats
+
+// Failure is the category of a failed notification in Stats.Failures.
+type Failure = stats.Failure
+
+// MaxRecentErrors is the number of errors kept in Stats.RecentErrors and ShutdownReport.LastErrors.
+const MaxRecentErrors = stats.MaxRecentErrors
+
+// ErrorRecord is the error of a failed notification and when it happened, see Stats.RecentErrors.
+type ErrorRecord = stats.ErrorRecord
+
+const (
+	// FailBatchSize is a notification with more items than allowed.
+	FailBatchSize = stats.FailBatchSize
+	// FailNoBlobClient is a notification that was too large to send inline by a client without blob storage.
+	FailNoBlobClient = stats.FailNoBlobClient
+	// FailShutdown is a notification that was dropped because the client shut down before it was sent.
+	FailShutdown = stats.FailShutdown
+	// FailUploadTimeout is a notification whose blob upload took longer than BlobArgs.UploadTimeout.
+	FailUploadTimeout = stats.FailUploadTimeout
+	// FailTimeout is a notification whose context deadline or send budget passed.
+	FailTimeout = stats.FailTimeout
+	// FailCanceled is a notification whose context was canceled.
+	FailCanceled = stats.FailCanceled
+	// FailCircuitOpen is a notification that was not sent because the circuit breaker was open.
+	FailCircuitOpen = stats.FailCircuitOpen
+	// FailSpooled is a notification whose event was spooled to disk to be sent once ARN is reachable, see
+	// WithSpoolDir().
+	FailSpooled = stats.FailSpooled
+	// FailThrottled is a notification that ARN or blob storage rejected with 429 Too Many Requests.
+	FailThrottled = stats.FailThrottled
+	// FailServerError is a notification that ARN or blob storage rejected with a 5xx status code.
+	FailServerError = stats.FailServerError
+	// FailClientError is a notification that ARN or blob storage rejected with any other status code.
+	FailClientError = stats.FailClientError
+	// FailOther is any other failure, such as a connection error.
+	FailOther = stats.FailOther
+)
+
+// SLOOptions are the objective for the success rate of the client's notifications and when to alert on how
+// fast its error budget is used. See WithSLO().
+type SLOOptions = stats.SLOOptions
+
+// SLOStatus is the success ratio and error budget burn rate over the SLO's window, see Stats.SLO.
+type SLOStatus = stats.SLOStatus
+
+// WithSLO tracks the success rate of notifications against the SLO in o, computed from the client's own
+// results. The status is in Stats().SLO, and o.OnAlert is called when the error budget starts and stops
+// burning, so a publisher can hold deployments while ARN is failing without querying its metrics.
+func WithSLO(o SLOOptions) Option {
+	return func(c *ARN) error {
+		if err := o.Validate(); err != nil {
+			return err
+		}
+		c.slo = &o
+		return nil
+	}
+}
+
+// Stats returns statistics about the notifications sent by the client since it was created: the number
+// sent and failed, failures by category, bytes sent inline and through blob storage, the average batch size
+// (Stats.AvgBatchSize()), the number of notifications waiting to be sent, the last error and, with WithSLO(),
+// the SLO status. This is meant for services that want to include the health of ARN publishing in their own
+// status endpoints. Thread-safe.
+func (a *ARN) Stats() Stats {
+	var s Stats
+	if a.conn != nil {
+		s = a.conn.Stats()
+	}
+	s.QueueDepth += len(a.in)
+	return s
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// probe is an event that is sent to the receiver along with whether the SDK expects ARN to accept it.
+type probe struct {
+	name string
+	// mutate changes the canary event before it is sent. nil sends the canary as is.
+	mutate func(ev map[string]any)
+	// wantAccept is true if the SDK's local validation accepts the event.
+	wantAccept bool
+}
+
+// probes are sent in order. The first is the canary event the SDK builds, which ARN must accept. The rest
+// break one rule that the SDK's validation enforces, which ARN is expected to enforce as well.
+var probes = []probe{
+	{name: "canary", wantAccept: true},
+	{name: "noSubject", mutate: func(ev map[string]any) { delete(ev, "subject") }},
+	{name: "noEventType", mutate: func(ev map[string]any) { delete(ev, "eventType") }},
+	{name: "badDataVersion", mutate: func(ev map[string]any) { ev["dataVersion"] = "2.0" }},
+	{name: "badMetadataVersion", mutate: func(ev map[string]any) { ev["metadataVersion"] = "2.0" }},
+	{name: "badResourcesContainer", mutate: func(ev map[string]any) { data(ev)["resourcesContainer"] = "Unknown" }},
+	{name: "noResourceID", mutate: func(ev map[string]any) { resource(ev)["resourceId"] = "" }},
+	{name: "badStatusCode", mutate: func(ev map[string]any) { resource(ev)["statusCode"] = "NotFound" }},
+}
+
+func data(ev map[string]any) map[string]any {
+	d, _ := ev["data"].(map[string]any)
+	if d == nil {
+		d = map[string]any{}
+		ev["data"] = d
+	}
+	return d
+}
+
+// resource returns the first resource of the event.
+func resource(ev map[string]any) map[string]any {
+	rscs, _ := data(ev)["resources"].([]any)
+	if len(rscs) == 0 {
+		return map[string]any{}
+	}
+	r, _ := rscs[0].(map[string]any)
+	if r == nil {
+		return map[string]any{}
+	}
+	return r
+}
+
+// Result is the response of the receiver to a probe.
+type Result struct {
+	// Probe is the name of the probe.
+	Probe string `json:"probe"`
+	// WantAccept is true if the SDK expects the receiver to accept the probe.
+	WantAccept bool `json:"wantAccept"`
+	// Accepted is true if the receiver accepted the probe.
+	Accepted bool `json:"accepted"`
+	// StatusCode is the status code the receiver rejected the probe with.
+	StatusCode int `json:"statusCode,omitzero"`
+	// ErrorCode is the error code the receiver rejected the probe with, if it sent one.
+	ErrorCode string `json:"errorCode,omitzero"`
+	// Message is the error text of a rejection. This is not compared, as it can hold request IDs.
+	Message string `json:"message,omitzero"`
+}
+
+// DriftKind is the kind of schema drift.
+type DriftKind string
+
+const (
+	// RejectsValid is an event the SDK considers valid that the receiver rejected. Publishing is broken.
+	RejectsValid DriftKind = "rejectsValid"
+	// AcceptsInvalid is an event the SDK rejects that the receiver accepted. The SDK is stricter than ARN.
+	AcceptsInvalid DriftKind = "acceptsInvalid"
+	// RejectionChanged is an invalid event that the receiver rejects differently than in the baseline.
+	RejectionChanged DriftKind = "rejectionChanged"
+)
+
+// Drift is a difference between the receiver and the SDK's expectations.
+type Drift struct {
+	Kind   DriftKind `json:"kind"`
+	Probe  string    `json:"probe"`
+	Detail string    `json:"detail"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s(%s): %s", d.Kind, d.Probe, d.Detail)
+}
+
+// canaryArgs are what is needed to build the canary event.
+type canaryArgs struct {
+	rscID      string
+	apiVersion string
+	location   string
+	publisher  string
+}
+
+// canary returns the JSON of a valid event for a write of the canary resource.
+func (c canaryArgs) canary(now time.Time) ([]byte, error) {
+	id, err := arm.ParseResourceID(c.rscID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canary resource ID: %w", err)
+	}
+	ar, err := types.NewArmResource(types.ActWrite, id, c.apiVersion, map[string]any{"arnDriftCanary": true})
+	if err != nil {
+		return nil, err
+	}
+	n := msgs.Notifications{
+		ResourceLocation: c.location,
+		PublisherInfo:    c.publisher,
+		Data: []types.NotificationResource{
+			{
+				ResourceID:               id.String(),
+				APIVersion:               c.apiVersion,
+				ResourceEventTime:        now,
+				ArmResource:              ar,
+				ResourceSystemProperties: types.ResourceSystemProperties{ChangeAction: types.CAUpdate, ModifiedTime: now},
+			},
+		},
+	}
+	return n.EventJSON()
+}
+
+// run sends each probe, built from canary, and returns the receiver's responses.
+func run(ctx context.Context, s models.EventSender, canary []byte, publisher string, probes []probe) ([]Result, error) {
+	results := make([]Result, 0, len(probes))
+	for _, p := range probes {
+		b := canary
+		if p.mutate != nil {
+			var ev map[string]any
+			if err := json.Unmarshal(canary, &ev); err != nil {
+				return nil, fmt.Errorf("could not decode canary: %w", err)
+			}
+			p.mutate(ev)
+			var err error
+			b, err = json.Marshal(ev)
+			if err != nil {
+				return nil, fmt.Errorf("could not encode probe(%s): %w", p.name, err)
+			}
+		}
+
+		r := Result{Probe: p.name, WantAccept: p.wantAccept}
+		err := s.Send(ctx, b, []string{"publisherinfo", publisher})
+		var re *azcore.ResponseError
+		switch {
+		case err == nil:
+			r.Accepted = true
+		case errors.As(err, &re):
+			r.StatusCode = re.StatusCode
+			r.ErrorCode = re.ErrorCode
+			r.Message = responseText(re)
+		default:
+			// Not a response from the receiver, so nothing can be learned about its schema.
+			return nil, fmt.Errorf("probe(%s): %w", p.name, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// responseText returns the body of the response in re, truncated to 1KiB.
+func responseText(re *azcore.ResponseError) string {
+	if re.RawResponse == nil || re.RawResponse.Body == nil {
+		return re.Error()
+	}
+	b, err := io.ReadAll(io.LimitReader(re.RawResponse.Body, 1024))
+	if err != nil || len(b) == 0 {
+		return re.Error()
+	}
+	return string(b)
+}
+
+// baseline is how the receiver rejected each invalid probe in a known good run, by probe name.
+type baseline map[string]Result
+
+// compare returns the drift between results and the SDK's expectations and, if base is not nil, between
+// the rejections in results and base.
+func compare(results []Result, base baseline) []Drift {
+	var drifts []Drift
+	for _, r := range results {
+		switch {
+		case r.WantAccept && !r.Accepted:
+			drifts = append(drifts, Drift{Kind: RejectsValid, Probe: r.Probe, Detail: fmt.Sprintf("status %d %s: %s", r.StatusCode, r.ErrorCode, r.Message)})
+			continue
+		case !r.WantAccept && r.Accepted:
+			drifts = append(drifts, Drift{Kind: AcceptsInvalid, Probe: r.Probe, Detail: "the receiver accepted an event the SDK rejects"})
+			continue
+		case r.Accepted:
+			continue
+		}
+
+		b, ok := base[r.Probe]
+		if base == nil || !ok {
+			continue
+		}
+		if b.StatusCode != r.StatusCode || b.ErrorCode != r.ErrorCode {
+			drifts = append(drifts, Drift{
+				Kind:   RejectionChanged,
+				Probe:  r.Probe,
+				Detail: fmt.Sprintf("was status %d %s, now status %d %s: %s", b.StatusCode, b.ErrorCode, r.StatusCode, r.ErrorCode, r.Message),
+			})
+		}
+	}
+	return drifts
+}
+
+// readBaseline reads a baseline written by writeBaseline. A missing file returns a nil baseline.
+func readBaseline(path string) (baseline, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var base baseline
+	if err := json.Unmarshal(b, &base); err != nil {
+		return nil, fmt.Errorf("could not decode baseline %s: %w", path, err)
+	}
+	return base, nil
+}
+
+// writeBaseline writes the rejections in results to path.
+func writeBaseline(path string, results []Result) error {
+	base := baseline{}
+	for _, r := range results {
+		if r.Accepted {
+			continue
+		}
+		r.Message = ""
+		base[r.Probe] = r
+	}
+	b, err := json.Marshal(base, json.Deterministic(true), jsontext.WithIndent("  "))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// probeNames returns the names of the probes, for the usage message.
+func probeNames() []string {
+	var names []string
+	for _, p := range probes {
+		names = append(names, p.name)
+	}
+	return slices.Clip(names)
+}
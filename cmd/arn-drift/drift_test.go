@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/go-json-experiment/json"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+const canaryID = "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/canary"
+
+// fakeReceiver rejects events that are missing a subject, unless acceptAll is set.
+type fakeReceiver struct {
+	acceptAll bool
+	rejectAll bool
+	code      string
+	err       error
+}
+
+func (f *fakeReceiver) Send(ctx context.Context, event []byte, headers []string) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.acceptAll {
+		return nil
+	}
+	var ev map[string]any
+	if err := json.Unmarshal(event, &ev); err != nil {
+		return err
+	}
+	if _, ok := ev["subject"]; ok && !f.rejectAll {
+		return nil
+	}
+	return &azcore.ResponseError{StatusCode: 400, ErrorCode: f.code}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	c := canaryArgs{rscID: canaryID, apiVersion: "2024-01-01", location: "eastus", publisher: "Microsoft.ContainerService"}
+	canary, err := c.canary(time.Now().UTC())
+	if err != nil {
+		t.Fatalf("TestRun: canary() error: %v", err)
+	}
+	probes := []probe{
+		{name: "canary", wantAccept: true},
+		{name: "noSubject", mutate: func(ev map[string]any) { delete(ev, "subject") }},
+	}
+
+	tests := []struct {
+		name      string
+		recv      *fakeReceiver
+		base      baseline
+		want      []Result
+		wantDrift []DriftKind
+		wantErr   bool
+	}{
+		{
+			name: "No drift",
+			recv: &fakeReceiver{code: "InvalidSubject"},
+			want: []Result{
+				{Probe: "canary", WantAccept: true, Accepted: true},
+				{Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject"},
+			},
+		},
+		{
+			name: "No drift against baseline",
+			recv: &fakeReceiver{code: "InvalidSubject"},
+			base: baseline{"noSubject": {Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject"}},
+			want: []Result{
+				{Probe: "canary", WantAccept: true, Accepted: true},
+				{Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject"},
+			},
+		},
+		{
+			name: "Rejection changed",
+			recv: &fakeReceiver{code: "BadRequest"},
+			base: baseline{"noSubject": {Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject"}},
+			want: []Result{
+				{Probe: "canary", WantAccept: true, Accepted: true},
+				{Probe: "noSubject", StatusCode: 400, ErrorCode: "BadRequest"},
+			},
+			wantDrift: []DriftKind{RejectionChanged},
+		},
+		{
+			name: "Receiver accepts invalid",
+			recv: &fakeReceiver{acceptAll: true},
+			want: []Result{
+				{Probe: "canary", WantAccept: true, Accepted: true},
+				{Probe: "noSubject", Accepted: true},
+			},
+			wantDrift: []DriftKind{AcceptsInvalid},
+		},
+		{
+			name: "Receiver rejects valid",
+			recv: &fakeReceiver{rejectAll: true, code: "InvalidSubject"},
+			want: []Result{
+				{Probe: "canary", WantAccept: true, StatusCode: 400, ErrorCode: "InvalidSubject"},
+				{Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject"},
+			},
+			wantDrift: []DriftKind{RejectsValid},
+		},
+		{
+			name:    "Error: no response",
+			recv:    &fakeReceiver{err: errors.New("connection refused")},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := run(context.Background(), test.recv, canary, c.publisher, probes)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestRun(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestRun(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		// Messages hold the error text, which is not under test.
+		for i := range results {
+			results[i].Message = ""
+		}
+		if diff := pretty.Compare(test.want, results); diff != "" {
+			t.Errorf("TestRun(%s): results -want/+got:\n%s", test.name, diff)
+		}
+
+		var kinds []DriftKind
+		for _, d := range compare(results, test.base) {
+			kinds = append(kinds, d.Kind)
+		}
+		if diff := pretty.Compare(test.wantDrift, kinds); diff != "" {
+			t.Errorf("TestRun(%s): drift -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestProbesRejectedBySDK(t *testing.T) {
+	t.Parallel()
+
+	// Every probe but the canary must break a rule, so the canary must not be mutated and the rest must be.
+	for i, p := range probes {
+		if (i == 0) != p.wantAccept || (p.mutate == nil) != p.wantAccept {
+			t.Errorf("TestProbesRejectedBySDK(%s): got wantAccept == %v, mutate == nil is %v", p.name, p.wantAccept, p.mutate == nil)
+		}
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "drift.json")
+
+	base, err := readBaseline(path)
+	if err != nil {
+		t.Fatalf("TestBaseline: readBaseline(missing) error: %v", err)
+	}
+	if base != nil {
+		t.Errorf("TestBaseline: readBaseline(missing): got %v, want nil", base)
+	}
+
+	results := []Result{
+		{Probe: "canary", WantAccept: true, Accepted: true},
+		{Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject", Message: "request 1234"},
+	}
+	if err := writeBaseline(path, results); err != nil {
+		t.Fatalf("TestBaseline: writeBaseline() error: %v", err)
+	}
+	base, err = readBaseline(path)
+	if err != nil {
+		t.Fatalf("TestBaseline: readBaseline() error: %v", err)
+	}
+	want := baseline{"noSubject": {Probe: "noSubject", StatusCode: 400, ErrorCode: "InvalidSubject"}}
+	if diff := pretty.Compare(want, base); diff != "" {
+		t.Errorf("TestBaseline: -want/+got:\n%s", diff)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+arn-drift detects when the schema the ARN receiver enforces drifts from what the SDK expects, before the drift
+breaks production publishing.
+
+It sends a canary event that the SDK builds and validates, followed by probes that each break one rule of the
+SDK's validation. It then reports:
+  - rejectsValid: the receiver rejected the canary. Publishing with this SDK version is broken.
+  - acceptsInvalid: the receiver accepted a probe the SDK rejects. The SDK is stricter than ARN, which is
+    safe, but may be worth relaxing.
+  - rejectionChanged: the receiver rejected a probe with a different status or error code than in the
+    baseline (-baseline), which usually means its validation changed.
+
+The canary is a real write notification for -rscID and will be delivered to ARN consumers, so use a resource
+that exists only for this purpose. The probes are rejected by the receiver and are not delivered.
+
+With -update, the rejections are written to -baseline after the run. With -interval, it runs until stopped and
+logs drift as errors, for running as a long-lived canary. Otherwise it runs once, prints the results as JSON
+and exits with status 1 if there is drift.
+
+Usage:
+
+	arn-drift -endpoint=https://... -rscID=/subscriptions/.../providers/Microsoft.ContainerService/managedClusters/canary -baseline=drift.json
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/models"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+var (
+	endpoint   = flag.String("endpoint", "", "The ARN endpoint to probe")
+	msid       = flag.String("msid", "", "The resource ID of the managed identity to use. If not set, the azidentity default credential chain is used")
+	scope      = flag.String("scope", "", "The token scope for the ARN receiver. If not set, the SDK default is used")
+	rscID      = flag.String("rscID", "", "The resource ID of the canary resource")
+	apiVersion = flag.String("apiVersion", "2024-01-01", "The API version of the canary resource")
+	location   = flag.String("location", "eastus", "The location of the canary resource")
+	publisher  = flag.String("publisher", "", "The publisher namespace, like Microsoft.ContainerService. Defaults to the namespace of -rscID")
+	basePath   = flag.String("baseline", "", "The file holding the rejections of a known good run. If not set, rejections are not compared")
+	update     = flag.Bool("update", false, "Write the rejections of this run to -baseline")
+	interval   = flag.Duration("interval", 0, "If set, probe at this interval until stopped")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of arn-drift (probes: %s):\n", strings.Join(probeNames(), ", "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	drift, err := runMain(log)
+	if err != nil {
+		log.Error("arn-drift failed", "error", err.Error())
+		os.Exit(2)
+	}
+	if drift {
+		os.Exit(1)
+	}
+}
+
+// runMain runs the tool and returns true if drift was found.
+func runMain(log *slog.Logger) (bool, error) {
+	if *endpoint == "" {
+		return false, errors.New("-endpoint is required")
+	}
+	if *rscID == "" {
+		return false, errors.New("-rscID is required")
+	}
+	if *update && *basePath == "" {
+		return false, errors.New("-update requires -baseline")
+	}
+	if *publisher == "" {
+		id, err := arm.ParseResourceID(*rscID)
+		if err != nil {
+			return false, fmt.Errorf("invalid -rscID: %w", err)
+		}
+		*publisher = id.ResourceType.Namespace
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cred, err := credential()
+	if err != nil {
+		return false, err
+	}
+	var opts []http.Option
+	if *scope != "" {
+		opts = append(opts, http.WithScope(*scope))
+	}
+	// Retries would only repeat the same rejection.
+	hc, err := http.New(*endpoint, cred, &policy.ClientOptions{Retry: policy.RetryOptions{MaxRetries: -1}}, opts...)
+	if err != nil {
+		return false, err
+	}
+
+	base, err := readBaseline(*basePath)
+	if err != nil {
+		return false, err
+	}
+	c := canaryArgs{rscID: *rscID, apiVersion: *apiVersion, location: *location, publisher: *publisher}
+
+	if *interval <= 0 {
+		results, drifts, err := probeOnce(ctx, hc, c, base)
+		if err != nil {
+			return false, err
+		}
+		out, err := json.Marshal(struct {
+			Results []Result `json:"results"`
+			Drift   []Drift  `json:"drift"`
+		}{results, drifts}, jsontext.WithIndent("  "))
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(out))
+		if *update {
+			if err := writeBaseline(*basePath, results); err != nil {
+				return false, err
+			}
+		}
+		return len(drifts) > 0, nil
+	}
+
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+	for {
+		_, drifts, err := probeOnce(ctx, hc, c, base)
+		switch {
+		case err != nil:
+			log.Error("could not probe the receiver", "error", err.Error())
+		case len(drifts) == 0:
+			log.Info("no schema drift")
+		}
+		for _, d := range drifts {
+			log.Error("schema drift", "kind", string(d.Kind), "probe", d.Probe, "detail", d.Detail)
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-t.C:
+		}
+	}
+}
+
+// probeOnce sends the probes and compares the results.
+func probeOnce(ctx context.Context, s models.EventSender, c canaryArgs, base baseline) ([]Result, []Drift, error) {
+	canary, err := c.canary(time.Now().UTC())
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not build the canary event: %w", err)
+	}
+	results, err := run(ctx, s, canary, c.publisher, probes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, compare(results, base), nil
+}
+
+// credential returns the credential used for ARN.
+func credential() (azcore.TokenCredential, error) {
+	if *msid != "" {
+		return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ResourceID(*msid)})
+	}
+	return azidentity.NewDefaultAzureCredential(nil)
+}
@@ -0,0 +1,154 @@
+/*
+arn-proxy is a local REST service that publishes notifications to ARN with the Go client, so that sidecars
+written in other languages can publish without implementing batching, retries and blob uploads themselves.
+
+The proxy has no authentication of its own and should only listen on localhost or a pod-local address. It
+authenticates to ARN and blob storage with a managed identity (-msid) or, if that is not set, with the
+azidentity default credential chain (environment, workload identity, managed identity, Azure CLI).
+
+Only REST is provided. A gRPC API would require generated stubs in every caller's language for a single call,
+which a JSON POST does not.
+
+API:
+
+POST /notify sends a notification and blocks until it is sent. Add ?async=true to return once it is queued;
+errors are then only logged by the proxy. The body is:
+
+	{
+		"resourceLocation": "eastus",
+		"publisherInfo": "Microsoft.ContainerService",
+		"resources": [
+			{
+				"activity": "write",
+				"resourceId": "/subscriptions/.../resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/c",
+				"apiVersion": "2024-01-01",
+				"resourceEventTime": "2024-01-01T00:00:00Z",
+				"armResource": {"id": "/subscriptions/...", "properties": {"key": "value"}},
+				"resourceSystemProperties": {"changeAction": "Update"}
+			}
+		]
+	}
+
+Each resource is a v3 NotificationResource with an "activity" of "write", "delete" or "snapshot". It responds with:
+  - 200 when the notification was sent.
+  - 202 when an async notification was queued.
+  - 400 when the request is invalid, with a JSON body of {"error": "..."}.
+  - 413 when the body is larger than -maxBody.
+  - 502 when the notification could not be sent, or 504 if it timed out.
+
+GET /healthz returns 200.
+
+Usage:
+
+	arn-proxy -endpoint=https://... -storage=https://account.blob.core.windows.net -msid=/subscriptions/.../userAssignedIdentities/id
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Azure/arn-sdk/client"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/google/uuid"
+)
+
+var (
+	listen      = flag.String("listen", "127.0.0.1:8080", "The address to listen on")
+	endpoint    = flag.String("endpoint", "", "The ARN endpoint to publish to")
+	storage     = flag.String("storage", "", "The blob storage account for large notifications, like https://account.blob.core.windows.net. If not set, only inline notifications can be sent")
+	msid        = flag.String("msid", "", "The resource ID of the managed identity to use. If not set, the azidentity default credential chain is used")
+	compression = flag.Bool("compression", false, "Compress requests to ARN")
+	maxBody     = flag.Int64("maxBody", 32*1024*1024, "The maximum size of a request body in bytes")
+	timeout     = flag.Duration("timeout", 30*time.Second, "The maximum time to wait for a synchronous notification to be sent")
+)
+
+func main() {
+	flag.Parse()
+	log := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	if err := run(log); err != nil {
+		log.Error("arn-proxy failed", "error", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(log *slog.Logger) error {
+	if *endpoint == "" {
+		return errors.New("-endpoint is required")
+	}
+
+	// ARN client uses UUIDs, this greatly improves the performance of UUID generation.
+	uuid.EnableRandPool()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cred, err := credential()
+	if err != nil {
+		return err
+	}
+
+	args := client.Args{
+		HTTP: client.HTTPArgs{
+			Endpoint:    *endpoint,
+			Cred:        cred,
+			Compression: *compression,
+		},
+	}
+	if *storage != "" {
+		args.Blob = client.BlobArgs{Endpoint: *storage, Cred: cred}
+	}
+
+	arn, err := client.New(ctx, args, client.WithLogger(log))
+	if err != nil {
+		return err
+	}
+	defer arn.Close()
+
+	go func() {
+		for err := range arn.Errors() {
+			log.Error("async notification failed", "error", err.Error())
+		}
+	}()
+
+	s := &server{arn: arn, maxBody: *maxBody, timeout: *timeout, log: log, asyncCtx: context.Background()}
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info("arn-proxy listening", "addr", *listen)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutCtx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return srv.Shutdown(shutCtx)
+}
+
+// credential returns the credential used for ARN and blob storage.
+func credential() (azcore.TokenCredential, error) {
+	if *msid != "" {
+		return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ResourceID(*msid)})
+	}
+	return azidentity.NewDefaultAzureCredential(nil)
+}
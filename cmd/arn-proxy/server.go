@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/go-json-experiment/json"
+)
+
+// notifier is the part of *client.ARN that the server uses.
+type notifier interface {
+	Notify(ctx context.Context, n models.Notifications) error
+	Async(ctx context.Context, n models.Notifications, promise bool) models.Notifications
+}
+
+// notifyRequest is the body of POST /notify.
+type notifyRequest struct {
+	// ResourceLocation is the location of the resources, like "eastus".
+	ResourceLocation string `json:"resourceLocation"`
+	// FrontdoorLocation is the ARM region that emitted the notification, if any.
+	FrontdoorLocation string `json:"frontdoorLocation,omitzero"`
+	// PublisherInfo is the namespace of the publisher, like "Microsoft.ContainerService".
+	PublisherInfo string `json:"publisherInfo"`
+	// Resources are the resources to send.
+	Resources []resource `json:"resources"`
+}
+
+// resource is a resource in a notifyRequest. This is a types.NotificationResource with the activity
+// that is being performed on it, which is not part of the wire format.
+type resource struct {
+	// Activity is "write", "delete" or "snapshot".
+	Activity string `json:"activity"`
+
+	types.NotificationResource `json:",inline"`
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// server is the HTTP API of the proxy.
+type server struct {
+	arn     notifier
+	maxBody int64
+	// timeout is the maximum time to wait for a synchronous send.
+	timeout time.Duration
+	log     *slog.Logger
+	// asyncCtx is the context used for async sends, which outlive the request.
+	asyncCtx context.Context
+}
+
+// handler returns the http.Handler for the server.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /notify", s.notify)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+// notify handles POST /notify. By default it blocks until the notification is sent. With ?async=true
+// it returns 202 once the notification is queued and errors are only logged.
+func (s *server) notify(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBody))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			s.writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("body is larger than %d bytes", s.maxBody))
+			return
+		}
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req notifyRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	n, err := req.toNotifications()
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if r.URL.Query().Get("async") == "true" {
+		s.arn.Async(s.asyncCtx, n, false)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	if err := s.arn.Notify(ctx, n); err != nil {
+		s.writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// statusFor returns the HTTP status code for an error from Notify().
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, models.ErrBatchSize):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrPromiseTimeout), errors.Is(err, models.ErrUploadTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, models.ErrPromiseCanceled):
+		// The caller went away, the status code is not read.
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+func (s *server) writeError(w http.ResponseWriter, code int, err error) {
+	if code >= 500 {
+		s.log.Error("could not send notification", "error", err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.MarshalWrite(w, errorResponse{Error: err.Error()})
+}
+
+// toNotifications converts the request to msgs.Notifications.
+func (req notifyRequest) toNotifications() (msgs.Notifications, error) {
+	if len(req.Resources) == 0 {
+		return msgs.Notifications{}, errors.New("resources is required")
+	}
+
+	data := make([]types.NotificationResource, 0, len(req.Resources))
+	for i, r := range req.Resources {
+		nr, err := r.toResource()
+		if err != nil {
+			return msgs.Notifications{}, fmt.Errorf("resources[%d]: %w", i, err)
+		}
+		data = append(data, nr)
+	}
+
+	return msgs.Notifications{
+		ResourceLocation:  req.ResourceLocation,
+		FrontdoorLocation: req.FrontdoorLocation,
+		PublisherInfo:     req.PublisherInfo,
+		Data:              data,
+	}, nil
+}
+
+// toResource converts r to a types.NotificationResource. The ArmResource is rebuilt with
+// types.NewArmResource() so that it carries the activity and the parsed resource ID.
+func (r resource) toResource() (types.NotificationResource, error) {
+	act, err := parseActivity(r.Activity)
+	if err != nil {
+		return types.NotificationResource{}, err
+	}
+
+	idStr := r.ArmResource.ID
+	if idStr == "" {
+		idStr = r.ResourceID
+	}
+	id, err := arm.ParseResourceID(idStr)
+	if err != nil {
+		return types.NotificationResource{}, fmt.Errorf("invalid resource ID %q: %w", idStr, err)
+	}
+
+	apiVersion := r.ArmResource.APIVersion
+	if apiVersion == "" {
+		apiVersion = r.APIVersion
+	}
+	ar, err := types.NewArmResource(act, id, apiVersion, r.ArmResource.Properties)
+	if err != nil {
+		return types.NotificationResource{}, fmt.Errorf(".ArmResource: %w", err)
+	}
+	if r.ArmResource.Location != "" {
+		ar.Location = r.ArmResource.Location
+	}
+
+	nr := r.NotificationResource
+	nr.ArmResource = ar
+	if nr.ResourceID == "" {
+		nr.ResourceID = ar.ID
+	}
+	nr.StatusCode = types.StatusCode
+	if err := nr.Validate(); err != nil {
+		return types.NotificationResource{}, err
+	}
+	return nr, nil
+}
+
+// parseActivity parses the activity of a resource.
+func parseActivity(s string) (types.Activity, error) {
+	for _, act := range []types.Activity{types.ActWrite, types.ActDelete, types.ActSnapshot} {
+		if strings.EqualFold(s, act.String()) {
+			return act, nil
+		}
+	}
+	return types.ActUnknown, fmt.Errorf("activity %q must be write, delete or snapshot", s)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+type fakeNotifier struct {
+	err   error
+	got   models.Notifications
+	async bool
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n models.Notifications) error {
+	f.got = n
+	return f.err
+}
+
+func (f *fakeNotifier) Async(ctx context.Context, n models.Notifications, promise bool) models.Notifications {
+	f.got = n
+	f.async = true
+	return n
+}
+
+func TestNotify(t *testing.T) {
+	t.Parallel()
+
+	const rscID = "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/c"
+	body := func(act string) string {
+		return fmt.Sprintf(`{"resourceLocation": "eastus", "publisherInfo": "Microsoft.ContainerService", "resources": [{"activity": %q, "resourceId": %q, "apiVersion": "2024-01-01", "armResource": {"id": %q, "properties": {"key": "value"}}, "resourceSystemProperties": {"changeAction": "Update"}}]}`, act, rscID, rscID)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		body      string
+		notifyErr error
+		wantCode  int
+		wantAsync bool
+	}{
+		{name: "Success", body: body("write"), wantCode: http.StatusOK},
+		{name: "Async", query: "?async=true", body: body("snapshot"), wantCode: http.StatusAccepted, wantAsync: true},
+		{name: "Delete", body: body("delete"), wantCode: http.StatusOK},
+		{name: "Error: bad JSON", body: `{`, wantCode: http.StatusBadRequest},
+		{name: "Error: bad activity", body: body("move"), wantCode: http.StatusBadRequest},
+		{name: "Error: no resources", body: `{"resources": []}`, wantCode: http.StatusBadRequest},
+		{name: "Error: body too large", body: body("write") + strings.Repeat(" ", 4096), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "Error: send failed", body: body("write"), notifyErr: errors.New("error"), wantCode: http.StatusBadGateway},
+		{name: "Error: send timed out", body: body("write"), notifyErr: models.ErrPromiseTimeout, wantCode: http.StatusGatewayTimeout},
+		{name: "Error: upload timed out", body: body("write"), notifyErr: fmt.Errorf("%w: %w", models.ErrUploadTimeout, context.DeadlineExceeded), wantCode: http.StatusGatewayTimeout},
+	}
+
+	for _, test := range tests {
+		f := &fakeNotifier{err: test.notifyErr}
+		s := &server{arn: f, maxBody: 4096, timeout: time.Second, log: slog.New(slog.NewTextHandler(io.Discard, nil)), asyncCtx: context.Background()}
+
+		req := httptest.NewRequest(http.MethodPost, "/notify"+test.query, strings.NewReader(test.body))
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, req)
+
+		if rec.Code != test.wantCode {
+			t.Errorf("TestNotify(%s): got code %d, want %d: %s", test.name, rec.Code, test.wantCode, rec.Body.String())
+			continue
+		}
+		if f.async != test.wantAsync {
+			t.Errorf("TestNotify(%s): got async %v, want %v", test.name, f.async, test.wantAsync)
+		}
+		if test.wantCode >= 300 || f.got == nil {
+			continue
+		}
+
+		n := f.got.(msgs.Notifications)
+		if len(n.Data) != 1 {
+			t.Fatalf("TestNotify(%s): got %d resources, want 1", test.name, len(n.Data))
+		}
+		r := n.Data[0]
+		if r.ArmResource.ResourceID() == nil {
+			t.Errorf("TestNotify(%s): ArmResource was not built with a parsed resource ID", test.name)
+		}
+		if r.ArmResource.Type != "Microsoft.ContainerService/managedClusters" {
+			t.Errorf("TestNotify(%s): got ArmResource.Type %q", test.name, r.ArmResource.Type)
+		}
+		if r.StatusCode != types.StatusCode {
+			t.Errorf("TestNotify(%s): got StatusCode %q, want %q", test.name, r.StatusCode, types.StatusCode)
+		}
+		if n.PublisherInfo != "Microsoft.ContainerService" || n.ResourceLocation != "eastus" {
+			t.Errorf("TestNotify(%s): got PublisherInfo %q and ResourceLocation %q", test.name, n.PublisherInfo, n.ResourceLocation)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+/*
+Package consumer provides a runtime for services that consume ARN notifications from a queue.
+
+ARN notifications are usually consumed through an Event Grid subscription that delivers them to a Storage Queue
+or a Service Bus subscription. A Runner pulls messages from a Source, removes the Event Grid wrapper, downloads
+blob payloads and dispatches the resources to your handler, with a fixed number of concurrent workers.
+
+A message is completed (checkpointed) only after the Handler has succeeded for every event in it, so each
+notification is handled at least once. If the Handler fails, it is retried as set by WithRetry() and then the
+message is abandoned so that the queue delivers it again. Messages that cannot be decoded, have a blob that can
+no longer be read, fail with a Permanent() error or have been delivered more than the maximum number of times
+are poison messages and are dead-lettered.
+
+Because delivery is at least once and queues do not keep order, a Handler may see a notification again or see
+an older notification after a newer one. WithOrderTracking() flags these in Notification.Ordering, so the Handler
+can decide whether to apply or skip each resource.
+
+This package provides a Source for Storage Queues (see NewStorageQueue()). For Service Bus, wrap a receiver from
+the azservicebus package in a type that implements Source:
+
+	type sbSource struct {
+		r *azservicebus.Receiver
+	}
+
+	func (s sbSource) Receive(ctx context.Context, max int) ([]consumer.Message, error) {
+		msgs, err := s.r.ReceiveMessages(ctx, max, nil)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]consumer.Message, 0, len(msgs))
+		for _, m := range msgs {
+			out = append(out, consumer.Message{ID: m.MessageID, Body: m.Body, DeliveryCount: int(m.DeliveryCount), Token: m})
+		}
+		return out, nil
+	}
+
+	func (s sbSource) Complete(ctx context.Context, m consumer.Message) error {
+		return s.r.CompleteMessage(ctx, m.Token.(*azservicebus.ReceivedMessage), nil)
+	}
+
+	func (s sbSource) Abandon(ctx context.Context, m consumer.Message) error {
+		return s.r.AbandonMessage(ctx, m.Token.(*azservicebus.ReceivedMessage), nil)
+	}
+
+	func (s sbSource) DeadLetter(ctx context.Context, m consumer.Message, reason string) error {
+		return s.r.DeadLetterMessage(ctx, m.Token.(*azservicebus.ReceivedMessage), &azservicebus.DeadLetterOptions{Reason: &reason})
+	}
+
+An Event Hubs consumer can be adapted the same way, with each event's body as the Message Body and a no-op
+Abandon() that leaves the checkpoint where it is.
+
+If the Event Grid subscription pushes notifications to a webhook instead of a queue, serve a Webhook (see
+NewWebhook()). It runs the same decoding and Handler, and uses the HTTP response to tell Event Grid whether to
+retry or dead-letter the request:
+
+	wh, err := consumer.NewWebhook(h)
+	if err != nil {
+		return err
+	}
+	http.Handle("/arn", authMiddleware(wh))
+
+Usage:
+
+	src, err := consumer.NewStorageQueue("https://account.queue.core.windows.net/arn", cred)
+	if err != nil {
+		return err
+	}
+
+	h := consumer.HandlerFunc(func(ctx context.Context, resources []types.NotificationResource) error {
+		for _, rsc := range resources {
+			// Do something with the resource.
+		}
+		return nil
+	})
+
+	r, err := consumer.New(src, h, consumer.WithMeterProvider(mp))
+	if err != nil {
+		return err
+	}
+
+	// Blocks until ctx is cancelled.
+	return r.Run(ctx)
+*/
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/metrics"
+	"github.com/Azure/arn-sdk/models/v3/receiver"
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Message is a message received from a Source.
+type Message struct {
+	// ID is the ID of the message in the Source.
+	ID string
+	// Body is the body of the message, which holds one or more Event Grid events.
+	Body []byte
+	// DeliveryCount is the number of times the message has been delivered, including this one.
+	DeliveryCount int
+	// Token is state the Source needs to complete, abandon or dead-letter the message.
+	Token any
+}
+
+// Source is a queue that messages are received from.
+type Source interface {
+	// Receive receives up to max messages. It should wait a short time for messages to arrive
+	// and may return no messages.
+	Receive(ctx context.Context, max int) ([]Message, error)
+	// Complete removes the message from the queue once it has been handled.
+	Complete(ctx context.Context, m Message) error
+	// Abandon returns the message to the queue so that it is delivered again.
+	Abandon(ctx context.Context, m Message) error
+	// DeadLetter moves the message out of the queue so that it can be inspected later.
+	DeadLetter(ctx context.Context, m Message, reason string) error
+}
+
+// Notification is an ARN notification that was received.
+type Notification struct {
+	// Event is the ARN event. For blob payloads, Event.Data.ResourcesBlobInfo holds the blob location.
+	Event envelope.Event
+	// Resources are the valid resources in the notification.
+	Resources []types.NotificationResource
+	// Invalid are the resources that could not be decoded or are invalid. These are
+	// not in Resources, so you can quarantine them.
+	Invalid []receiver.ItemError
+	// Warnings are the resources in Resources that have fields the SDK does not know. This is only set
+	// with WithUnknownFields(receiver.WarnUnknown).
+	Warnings []receiver.ItemError
+	// MessageID is the ID of the message that the notification was received in.
+	MessageID string
+	// Ordering is the ordering of each entry in Resources, relative to the last notification that was
+	// handled for the same resource ID. This is only set when WithOrderTracking() is used. Handlers
+	// can use it to skip duplicate or stale notifications.
+	Ordering []Ordering
+}
+
+// Runner receives messages from a Source and dispatches the notifications in them to a Handler.
+type Runner struct {
+	src Source
+	h   Handler
+
+	dl            *receiver.Downloader
+	dec           receiver.Decoder
+	concurrency   int
+	maxDeliveries int
+	pollInterval  time.Duration
+	retry         RetryPolicy
+	deadLetterFn  DeadLetterFunc
+	order         *OrderTracker
+	meterProvider metric.MeterProvider
+	buckets       *metrics.Buckets
+	metrics       *metrics.Registry
+	log           *slog.Logger
+
+	rnd func() float64
+}
+
+// Option is an option for New().
+type Option func(*Runner) error
+
+// WithConcurrency sets the number of messages handled at the same time. Defaults to 4.
+func WithConcurrency(n int) Option {
+	return func(r *Runner) error {
+		if n < 1 {
+			return fmt.Errorf("concurrency must be at least 1")
+		}
+		r.concurrency = n
+		return nil
+	}
+}
+
+// WithMaxDeliveries sets the number of times a message can be delivered before it is dead-lettered
+// as a poison message. Defaults to 5.
+func WithMaxDeliveries(n int) Option {
+	return func(r *Runner) error {
+		if n < 1 {
+			return fmt.Errorf("max deliveries must be at least 1")
+		}
+		r.maxDeliveries = n
+		return nil
+	}
+}
+
+// WithPollInterval sets how long to wait before receiving again when the Source has no messages
+// or returns an error. Defaults to 1 second.
+func WithPollInterval(d time.Duration) Option {
+	return func(r *Runner) error {
+		if d <= 0 {
+			return fmt.Errorf("poll interval must be greater than 0")
+		}
+		r.pollInterval = d
+		return nil
+	}
+}
+
+// WithDownloader sets the Downloader used to download blob payloads. Defaults to a Downloader with default options.
+func WithDownloader(d *receiver.Downloader) Option {
+	return func(r *Runner) error {
+		if d == nil {
+			return fmt.Errorf("downloader cannot be nil")
+		}
+		r.dl = d
+		return nil
+	}
+}
+
+// WithUnknownFields sets what is done with JSON fields in event data and resources that the SDK's types
+// do not have, which shows that ARN is sending fields the Handler may not account for. With
+// receiver.WarnUnknown, a warning is logged and the resources are in Notification.Warnings. With
+// receiver.RejectUnknown, an event with unknown fields in its data is dead-lettered and resources with
+// unknown fields are in Notification.Invalid. Defaults to receiver.IgnoreUnknown.
+func WithUnknownFields(u receiver.Unknown) Option {
+	return func(r *Runner) error {
+		d := receiver.Decoder{Unknown: u}
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		r.dec = d
+		return nil
+	}
+}
+
+// WithRetry sets the policy for retrying the Handler before the message is abandoned. By default the
+// Handler is not retried and the message is delivered again by the queue.
+func WithRetry(p RetryPolicy) Option {
+	return func(r *Runner) error {
+		p = p.Defaults()
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		r.retry = p
+		return nil
+	}
+}
+
+// WithDeadLetter sets a function that is called before a message is dead-lettered.
+func WithDeadLetter(fn DeadLetterFunc) Option {
+	return func(r *Runner) error {
+		if fn == nil {
+			return fmt.Errorf("dead letter func cannot be nil")
+		}
+		r.deadLetterFn = fn
+		return nil
+	}
+}
+
+// WithOrderTracking sets Notification.Ordering using t. Resources are recorded in t once the Handler has
+// succeeded, so a notification that is delivered again after a failure is not flagged as a duplicate.
+func WithOrderTracking(t *OrderTracker) Option {
+	return func(r *Runner) error {
+		if t == nil {
+			return fmt.Errorf("order tracker cannot be nil")
+		}
+		r.order = t
+		return nil
+	}
+}
+
+// WithMeterProvider sets the meter provider with which to register metrics for messages handled,
+// resources handled and the lag between a notification's EventTime and it being handled.
+// Defaults to nil, in which case metrics won't be registered.
+func WithMeterProvider(m metric.MeterProvider) Option {
+	return func(r *Runner) error {
+		if m == nil {
+			return fmt.Errorf("meter cannot be nil")
+		}
+		r.meterProvider = m
+		return nil
+	}
+}
+
+// WithMetricBuckets sets the bucket boundaries of the lag histogram, for metric backends that need
+// different boundaries than the defaults. Only Buckets.ConsumerLag is used. This only has an effect with
+// WithMeterProvider().
+func WithMetricBuckets(b metrics.Buckets) Option {
+	return func(r *Runner) error {
+		if err := b.Validate(); err != nil {
+			return err
+		}
+		r.buckets = &b
+		return nil
+	}
+}
+
+// WithMetricsRegistry records the consumer's metrics to m instead of the process-global registry,
+// metrics.Default(). With WithMeterProvider(), m is initialized with the provider's meter.
+func WithMetricsRegistry(m *metrics.Registry) Option {
+	return func(r *Runner) error {
+		if m == nil {
+			return fmt.Errorf("metrics registry cannot be nil")
+		}
+		r.metrics = m
+		return nil
+	}
+}
+
+// WithLogger sets the logger. By default it uses slog.Default().
+func WithLogger(log *slog.Logger) Option {
+	return func(r *Runner) error {
+		r.log = log
+		return nil
+	}
+}
+
+// New creates a new Runner.
+func New(src Source, h Handler, options ...Option) (*Runner, error) {
+	if src == nil {
+		return nil, fmt.Errorf("source is required")
+	}
+	return newRunner(src, h, options...)
+}
+
+// newRunner creates a new Runner. src can be nil for a Runner that is only used by a Webhook.
+func newRunner(src Source, h Handler, options ...Option) (*Runner, error) {
+	if h == nil {
+		return nil, fmt.Errorf("handler is required")
+	}
+
+	r := &Runner{
+		src:           src,
+		h:             h,
+		concurrency:   4,
+		maxDeliveries: 5,
+		pollInterval:  time.Second,
+		retry:         RetryPolicy{MaxAttempts: 1}.Defaults(),
+		log:           slog.Default(),
+		rnd:           rand.Float64,
+	}
+	for _, o := range options {
+		if err := o(r); err != nil {
+			return nil, err
+		}
+	}
+	r.dec.Log = r.log
+	if r.dl == nil {
+		dl, err := receiver.NewDownloader()
+		if err != nil {
+			return nil, err
+		}
+		r.dl = dl
+	}
+	if r.metrics == nil {
+		r.metrics = metrics.Default()
+	}
+	if r.meterProvider != nil {
+		var mopts []metrics.Option
+		if r.buckets != nil {
+			mopts = append(mopts, metrics.WithBuckets(*r.buckets))
+		}
+		if err := r.metrics.InitConsumer(r.meterProvider.Meter("arn"), mopts...); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Run receives and handles messages until ctx is cancelled. Messages being handled when ctx is cancelled
+// are not completed, so they will be delivered again. Run returns nil when ctx is cancelled.
+func (r *Runner) Run(ctx context.Context) error {
+	msgs := make(chan Message)
+
+	wg := sync.WaitGroup{}
+	for range r.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range msgs {
+				r.process(ctx, m)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(msgs)
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		batch, err := r.src.Receive(ctx, r.concurrency)
+		if err != nil && ctx.Err() == nil {
+			r.log.Error("could not receive messages", "error", err.Error())
+		}
+		if len(batch) == 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(r.pollInterval):
+			}
+			continue
+		}
+
+		for _, m := range batch {
+			select {
+			case <-ctx.Done():
+				return nil
+			case msgs <- m:
+			}
+		}
+	}
+}
+
+// process handles a single message and then completes, abandons or dead-letters it.
+func (r *Runner) process(ctx context.Context, m Message) {
+	if m.DeliveryCount > r.maxDeliveries {
+		r.deadLetter(ctx, m, fmt.Sprintf("message was delivered %d times, which is more than the maximum of %d", m.DeliveryCount, r.maxDeliveries), nil)
+		return
+	}
+
+	err := r.handleMessage(ctx, m)
+	if err == nil {
+		if err := r.src.Complete(ctx, m); err != nil {
+			r.log.Error("could not complete message", "id", m.ID, "error", err.Error())
+		}
+		r.metrics.ConsumeMessage(ctx, metrics.ConsumeCompleted)
+		return
+	}
+
+	if isPermanent(err) {
+		r.deadLetter(ctx, m, err.Error(), err)
+		return
+	}
+	r.metrics.ConsumeMessage(ctx, metrics.ConsumeAbandoned)
+
+	r.log.Warn("could not handle message, it will be delivered again", "id", m.ID, "error", err.Error())
+	if ctx.Err() != nil {
+		// The message becomes visible again when its lock or visibility timeout expires.
+		return
+	}
+	if err := r.src.Abandon(ctx, m); err != nil {
+		r.log.Error("could not abandon message", "id", m.ID, "error", err.Error())
+	}
+}
+
+// handleMessage decodes the events in the message and calls the handler for each of them.
+func (r *Runner) handleMessage(ctx context.Context, m Message) error {
+	events, err := r.dec.ParseEvents(m.Body)
+	if err != nil {
+		return permanentError{err: fmt.Errorf("could not decode message: %w", err)}
+	}
+
+	for i, e := range events {
+		n, err := r.notification(ctx, e)
+		if err != nil {
+			return fmt.Errorf("events[%d]: %w", i, err)
+		}
+		n.MessageID = m.ID
+		if r.order != nil {
+			n.Ordering = r.order.checkOrder(n)
+		}
+		if err := r.callHandler(ctx, n); err != nil {
+			r.metrics.ConsumeResources(ctx, len(n.Resources), false)
+			return fmt.Errorf("events[%d]: %w", i, err)
+		}
+		if r.order != nil {
+			r.order.recordOrder(n)
+		}
+		r.metrics.ConsumeResources(ctx, len(n.Resources), true)
+		if t := e.EventMeta.EventTime; !t.IsZero() {
+			r.metrics.ConsumeLag(ctx, time.Since(t))
+		}
+	}
+	return nil
+}
+
+// notification decodes the resources of the event, downloading them if they are in a blob.
+func (r *Runner) notification(ctx context.Context, e envelope.Event) (Notification, error) {
+	var (
+		res receiver.Result
+		err error
+	)
+	if e.Data.ResourcesContainer == types.RCBlob {
+		var b []byte
+		b, err = r.dl.DownloadPayload(ctx, e.Data)
+		// Neither is fixed by delivering the message again.
+		if errors.Is(err, receiver.ErrSASExpired) || errors.Is(err, receiver.ErrEncrypted) {
+			return Notification{}, permanentError{err: err}
+		}
+		if err == nil {
+			res, err = r.dec.DecodeResources(b)
+		}
+	} else {
+		res, err = r.dec.DecodeResources(e.Data.Data)
+		if err != nil {
+			err = permanentError{err: err}
+		}
+	}
+	if err != nil {
+		return Notification{}, err
+	}
+
+	return Notification{Event: e, Resources: res.Resources, Invalid: res.Errors, Warnings: res.Warnings}, nil
+}
+
+// deadLetter calls the DeadLetterFunc, if set, and dead-letters the message.
+func (r *Runner) deadLetter(ctx context.Context, m Message, reason string, err error) {
+	r.log.Error("dead-lettering poison message", "id", m.ID, "reason", reason)
+	r.metrics.ConsumeMessage(ctx, metrics.ConsumeDeadLettered)
+	if r.deadLetterFn != nil {
+		r.deadLetterFn(ctx, m, reason, err)
+	}
+	if err := r.src.DeadLetter(ctx, m, reason); err != nil {
+		r.log.Error("could not dead-letter message", "id", m.ID, "error", err.Error())
+	}
+}
@@ -0,0 +1,284 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/metrics"
+	"github.com/Azure/arn-sdk/models/v3/receiver"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+const inlineEvent = `{"id": "1", "topic": "t", "subject": "/subscriptions/s", "eventType": "Microsoft.ContainerService/managedClusters/write", "eventTime": "2024-01-01T00:00:00Z", "dataVersion": "3.0", "metadataVersion": "1", "data": {"resources": [{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}}, {"resourceSystemProperties": {"changeAction": "Create"}}], "resourcesContainer": "inline", "resourceLocation": "eastus", "publisherInfo": "Microsoft.ContainerService", "additionalBatchProperties": {"sdkVersion": "golang@0.1.0", "batchSize": 2}}}`
+
+// fakeSource is a Source that returns msgs and records what happened to each message.
+// done is closed once a result has been recorded for every message.
+type fakeSource struct {
+	mu      sync.Mutex
+	msgs    []Message
+	want    int
+	results map[string]string
+	done    chan struct{}
+}
+
+func newFakeSource(msgs ...Message) *fakeSource {
+	return &fakeSource{msgs: msgs, want: len(msgs), results: map[string]string{}, done: make(chan struct{})}
+}
+
+func (f *fakeSource) Receive(ctx context.Context, max int) ([]Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := min(max, len(f.msgs))
+	out := f.msgs[:n]
+	f.msgs = f.msgs[n:]
+	return out, nil
+}
+
+func (f *fakeSource) record(m Message, result string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[m.ID] = result
+	if len(f.results) == f.want {
+		close(f.done)
+	}
+}
+
+func (f *fakeSource) Complete(ctx context.Context, m Message) error {
+	f.record(m, "complete")
+	return nil
+}
+
+func (f *fakeSource) Abandon(ctx context.Context, m Message) error {
+	f.record(m, "abandon")
+	return nil
+}
+
+func (f *fakeSource) DeadLetter(ctx context.Context, m Message, reason string) error {
+	f.record(m, "deadletter")
+	return nil
+}
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	src := newFakeSource(
+		Message{ID: "ok", Body: []byte(inlineEvent), DeliveryCount: 1},
+		Message{ID: "handlerErr", Body: []byte(`[` + inlineEvent + `]`), DeliveryCount: 1},
+		Message{ID: "garbage", Body: []byte(`not json`), DeliveryCount: 1},
+		Message{ID: "poison", Body: []byte(inlineEvent), DeliveryCount: 6},
+		Message{ID: "retried", Body: []byte(inlineEvent), DeliveryCount: 1},
+		Message{ID: "permanent", Body: []byte(inlineEvent), DeliveryCount: 1},
+	)
+
+	var (
+		mu          sync.Mutex
+		seen        = map[string]Notification{}
+		attempts    = map[string]int{}
+		deadLetters = map[string]error{}
+	)
+	h := HandlerFunc(func(ctx context.Context, resources []types.NotificationResource) error {
+		n, ok := NotificationFromContext(ctx)
+		if !ok {
+			return errors.New("no notification in context")
+		}
+		mu.Lock()
+		seen[n.MessageID] = n
+		attempts[n.MessageID]++
+		a := attempts[n.MessageID]
+		mu.Unlock()
+
+		switch n.MessageID {
+		case "handlerErr":
+			return errors.New("error")
+		case "retried":
+			if a == 1 {
+				return errors.New("error")
+			}
+		case "permanent":
+			return Permanent(errors.New("error"))
+		}
+		return nil
+	})
+	dlFn := func(ctx context.Context, m Message, reason string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		deadLetters[m.ID] = err
+	}
+
+	r, err := New(
+		src,
+		h,
+		WithConcurrency(2),
+		WithPollInterval(time.Millisecond),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		WithDeadLetter(dlFn),
+	)
+	if err != nil {
+		t.Fatalf("TestRunner: New(): got err == %s, want err == nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+
+	select {
+	case <-src.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("TestRunner: timed out waiting for messages to be handled")
+	}
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("TestRunner: Run(): got err == %s, want err == nil", err)
+	}
+
+	want := map[string]string{
+		"ok":         "complete",
+		"handlerErr": "abandon",
+		"garbage":    "deadletter",
+		"poison":     "deadletter",
+		"retried":    "complete",
+		"permanent":  "deadletter",
+	}
+	for id, w := range want {
+		if got := src.results[id]; got != w {
+			t.Errorf("TestRunner(%s): got %q, want %q", id, got, w)
+		}
+	}
+
+	wantAttempts := map[string]int{"ok": 1, "handlerErr": 2, "retried": 2, "permanent": 1}
+	for id, w := range wantAttempts {
+		if got := attempts[id]; got != w {
+			t.Errorf("TestRunner(%s): got %d handler attempts, want %d", id, got, w)
+		}
+	}
+
+	for _, id := range []string{"garbage", "poison", "permanent"} {
+		if _, ok := deadLetters[id]; !ok {
+			t.Errorf("TestRunner(%s): DeadLetterFunc was not called", id)
+		}
+	}
+	if deadLetters["poison"] != nil {
+		t.Errorf("TestRunner(poison): got DeadLetterFunc err == %s, want err == nil", deadLetters["poison"])
+	}
+
+	n, ok := seen["ok"]
+	if !ok {
+		t.Fatalf("TestRunner: handler was not called for message ok")
+	}
+	if len(n.Resources) != 1 || n.Resources[0].ResourceID != "a" {
+		t.Errorf("TestRunner: got Resources %+v, want a single resource with ID a", n.Resources)
+	}
+	if len(n.Invalid) != 1 || n.Invalid[0].Index != 1 {
+		t.Errorf("TestRunner: got Invalid %+v, want a single error at index 1", n.Invalid)
+	}
+	if _, ok := seen["poison"]; ok {
+		t.Errorf("TestRunner: handler was called for a poison message")
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	handle := HandlerFunc(func(ctx context.Context, resources []types.NotificationResource) error { return nil })
+
+	tests := []struct {
+		name    string
+		src     Source
+		handle  Handler
+		options []Option
+		wantErr bool
+	}{
+		{name: "Success", src: newFakeSource(), handle: handle},
+		{name: "Error: nil source", handle: handle, wantErr: true},
+		{name: "Error: nil handle", src: newFakeSource(), wantErr: true},
+		{name: "Error: bad concurrency", src: newFakeSource(), handle: handle, options: []Option{WithConcurrency(0)}, wantErr: true},
+		{name: "Error: bad max deliveries", src: newFakeSource(), handle: handle, options: []Option{WithMaxDeliveries(0)}, wantErr: true},
+		{name: "Error: bad poll interval", src: newFakeSource(), handle: handle, options: []Option{WithPollInterval(0)}, wantErr: true},
+		{name: "Error: bad retry", src: newFakeSource(), handle: handle, options: []Option{WithRetry(RetryPolicy{MaxAttempts: 11})}, wantErr: true},
+		{name: "Error: nil dead letter func", src: newFakeSource(), handle: handle, options: []Option{WithDeadLetter(nil)}, wantErr: true},
+		{name: "Error: bad metric buckets", src: newFakeSource(), handle: handle, options: []Option{WithMetricBuckets(metrics.Buckets{ConsumerLag: []float64{2, 1}})}, wantErr: true},
+		{name: "Error: bad unknown fields", src: newFakeSource(), handle: handle, options: []Option{WithUnknownFields(receiver.RejectUnknown + 1)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.src, test.handle, test.options...)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNew(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNew(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestWithUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	const event = `{"id": "1", "data": {"resources": [{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}}, {"resourceId": "b", "newField": 1, "resourceSystemProperties": {"changeAction": "Create"}}], "resourcesContainer": "inline"%s}}`
+	known := fmt.Sprintf(event, "")
+	unknownData := fmt.Sprintf(event, `, "newField": 1`)
+
+	tests := []struct {
+		name         string
+		unknown      receiver.Unknown
+		body         string
+		wantErr      bool
+		wantIDs      []string
+		wantInvalid  int
+		wantWarnings int
+	}{
+		{name: "Ignore", unknown: receiver.IgnoreUnknown, body: unknownData, wantIDs: []string{"a", "b"}},
+		{name: "Warn", unknown: receiver.WarnUnknown, body: known, wantIDs: []string{"a", "b"}, wantWarnings: 1},
+		{name: "Reject", unknown: receiver.RejectUnknown, body: known, wantIDs: []string{"a"}, wantInvalid: 1},
+		{name: "Error: Reject unknown event data", unknown: receiver.RejectUnknown, body: unknownData, wantErr: true},
+	}
+
+	for _, test := range tests {
+		var got Notification
+		h := HandlerFunc(func(ctx context.Context, resources []types.NotificationResource) error {
+			got, _ = NotificationFromContext(ctx)
+			return nil
+		})
+		r, err := New(newFakeSource(), h, WithUnknownFields(test.unknown), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+		if err != nil {
+			t.Fatalf("TestWithUnknownFields(%s): New(): got err == %s, want err == nil", test.name, err)
+		}
+
+		err = r.handleMessage(context.Background(), Message{ID: "1", Body: []byte(test.body)})
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestWithUnknownFields(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestWithUnknownFields(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if !isPermanent(err) {
+				t.Errorf("TestWithUnknownFields(%s): got err == %s, want a permanent error", test.name, err)
+			}
+			continue
+		}
+
+		var ids []string
+		for _, r := range got.Resources {
+			ids = append(ids, r.ResourceID)
+		}
+		if !slices.Equal(ids, test.wantIDs) {
+			t.Errorf("TestWithUnknownFields(%s): got resources %v, want %v", test.name, ids, test.wantIDs)
+		}
+		if len(got.Invalid) != test.wantInvalid {
+			t.Errorf("TestWithUnknownFields(%s): got %d invalid, want %d", test.name, len(got.Invalid), test.wantInvalid)
+		}
+		if len(got.Warnings) != test.wantWarnings {
+			t.Errorf("TestWithUnknownFields(%s): got %d warnings, want %d", test.name, len(got.Warnings), test.wantWarnings)
+		}
+	}
+}
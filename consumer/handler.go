@@ -0,0 +1,101 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+// Handler handles the resources in a notification. If Handle returns an error, it is retried as set by
+// WithRetry(). If it still fails, the message is delivered again, so Handle must be idempotent: a
+// notification is delivered at least once. Return an error wrapped with Permanent() to dead-letter the
+// message instead. The rest of the notification can be read with NotificationFromContext().
+type Handler interface {
+	Handle(ctx context.Context, resources []types.NotificationResource) error
+}
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as a Handler.
+type HandlerFunc func(ctx context.Context, resources []types.NotificationResource) error
+
+// Handle implements Handler.Handle().
+func (f HandlerFunc) Handle(ctx context.Context, resources []types.NotificationResource) error {
+	return f(ctx, resources)
+}
+
+// RetryPolicy is the policy for retrying a Handler before a message is abandoned. MaxAttempts, BaseDelay,
+// MaxDelay and Jitter are used as they are for publishing. Budget is the total time that can be spent
+// retrying a notification, which should be less than the visibility timeout or lock duration of the queue.
+type RetryPolicy = retry.Policy
+
+// DeadLetterFunc is called before a message is dead-lettered. err is the error that caused it, which is
+// nil if the message was delivered too many times. This can be used to save the message or alert on it.
+type DeadLetterFunc func(ctx context.Context, m Message, reason string, err error)
+
+type notificationKey struct{}
+
+// NotificationFromContext returns the Notification being handled. This can be used inside Handler.Handle()
+// to get the event metadata or resources that could not be decoded.
+func NotificationFromContext(ctx context.Context) (Notification, bool) {
+	n, ok := ctx.Value(notificationKey{}).(Notification)
+	return n, ok
+}
+
+// Permanent wraps err to signal that handling the notification will never succeed, so the message is
+// dead-lettered instead of being delivered again.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// permanentError is an error that will not be fixed by delivering the message again.
+type permanentError struct {
+	err error
+}
+
+func (p permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p permanentError) Unwrap() error {
+	return p.err
+}
+
+// isPermanent returns true if err was wrapped with Permanent().
+func isPermanent(err error) bool {
+	var perm permanentError
+	return errors.As(err, &perm)
+}
+
+// callHandler calls the handler with n, retrying as set by the retry policy. Permanent errors are not retried.
+func (r *Runner) callHandler(ctx context.Context, n Notification) error {
+	ctx = context.WithValue(ctx, notificationKey{}, n)
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := r.h.Handle(ctx, n.Resources)
+		if err == nil {
+			return nil
+		}
+		if attempt >= r.retry.MaxAttempts || isPermanent(err) || ctx.Err() != nil {
+			return fmt.Errorf("handler failed after %d attempt(s): %w", attempt, err)
+		}
+
+		delay := r.retry.Delay(attempt, r.rnd)
+		if time.Since(start)+delay > r.retry.Budget {
+			return fmt.Errorf("handler failed after %d attempt(s), retry budget exhausted: %w", attempt, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("handler failed after %d attempt(s): %w", attempt, err)
+		case <-timer.C:
+		}
+	}
+}
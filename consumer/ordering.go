@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+//go:generate stringer -type=Order -linecomment
+
+// Order is how a resource's notification is ordered relative to the last one that was handled for the
+// same resource ID.
+type Order uint8
+
+const (
+	// OrderUnknown indicates that ordering was not checked.
+	OrderUnknown Order = 0 // Unknown
+	// OrderFirst indicates that no notification was handled before for the resource ID, or that it was
+	// evicted from the OrderTracker.
+	OrderFirst Order = 1 // First
+	// OrderInOrder indicates that the notification is newer than the last one that was handled.
+	OrderInOrder Order = 2 // InOrder
+	// OrderDuplicate indicates that the notification has the same time as the last one that was handled.
+	// This is usually a redelivery of a notification that was already applied.
+	OrderDuplicate Order = 3 // Duplicate
+	// OrderStale indicates that the notification is older than the last one that was handled. Applying it
+	// would roll the resource back to an older state.
+	OrderStale Order = 4 // Stale
+)
+
+// Ordering is the result of checking the order of a resource's notification.
+type Ordering struct {
+	// Order is how the notification is ordered.
+	Order Order
+	// Time is the time of the notification. This is the resource's ResourceEventTime, or the event's
+	// EventTime if that is not set.
+	Time time.Time
+	// Last is the time of the last notification that was handled for the resource ID. It is the zero
+	// time for OrderFirst.
+	Last time.Time
+}
+
+// DefaultOrderTrackerSize is the default number of resource IDs an OrderTracker remembers.
+const DefaultOrderTrackerSize = 100_000
+
+// OrderTracker remembers the time of the last notification that was handled for each resource ID, so that
+// duplicate and out-of-order notifications can be flagged. The least recently used resource IDs are evicted
+// once it holds its maximum size. This is in memory only: when several consumers share a queue, each only
+// knows of the notifications it handled. Thread-safe.
+type OrderTracker struct {
+	size int
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // of *orderEntry, most recently used at the front
+}
+
+type orderEntry struct {
+	id   string
+	last time.Time
+}
+
+// NewOrderTracker creates an OrderTracker that remembers up to size resource IDs. If size is 0,
+// DefaultOrderTrackerSize is used.
+func NewOrderTracker(size int) (*OrderTracker, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("size cannot be negative")
+	}
+	if size == 0 {
+		size = DefaultOrderTrackerSize
+	}
+	return &OrderTracker{
+		size:  size,
+		items: map[string]*list.Element{},
+		lru:   list.New(),
+	}, nil
+}
+
+// Check returns the ordering of a notification for resourceID at time t. It does not change what is
+// remembered, call Record() once the notification has been handled.
+func (o *OrderTracker) Check(resourceID string, t time.Time) Ordering {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e, ok := o.items[resourceID]
+	if !ok {
+		return Ordering{Order: OrderFirst, Time: t}
+	}
+	last := e.Value.(*orderEntry).last
+	ord := Ordering{Time: t, Last: last}
+	switch {
+	case t.After(last):
+		ord.Order = OrderInOrder
+	case t.Equal(last):
+		ord.Order = OrderDuplicate
+	default:
+		ord.Order = OrderStale
+	}
+	return ord
+}
+
+// Record remembers that a notification for resourceID at time t was handled. An older t than the one
+// remembered is ignored.
+func (o *OrderTracker) Record(resourceID string, t time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if e, ok := o.items[resourceID]; ok {
+		entry := e.Value.(*orderEntry)
+		if t.After(entry.last) {
+			entry.last = t
+		}
+		o.lru.MoveToFront(e)
+		return
+	}
+
+	o.items[resourceID] = o.lru.PushFront(&orderEntry{id: resourceID, last: t})
+	for o.lru.Len() > o.size {
+		oldest := o.lru.Back()
+		o.lru.Remove(oldest)
+		delete(o.items, oldest.Value.(*orderEntry).id)
+	}
+}
+
+// Len returns the number of resource IDs remembered.
+func (o *OrderTracker) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.lru.Len()
+}
+
+// resourceTime returns the time of a resource's notification, which is its ResourceEventTime or
+// eventTime if that is not set.
+func resourceTime(r types.NotificationResource, eventTime time.Time) time.Time {
+	if !r.ResourceEventTime.IsZero() {
+		return r.ResourceEventTime
+	}
+	return eventTime
+}
+
+// checkOrder returns the ordering of each resource in n.
+func (o *OrderTracker) checkOrder(n Notification) []Ordering {
+	ords := make([]Ordering, len(n.Resources))
+	for i, r := range n.Resources {
+		ords[i] = o.Check(r.ResourceID, resourceTime(r, n.Event.EventMeta.EventTime))
+	}
+	return ords
+}
+
+// recordOrder records each resource in n as handled.
+func (o *OrderTracker) recordOrder(n Notification) {
+	for i, r := range n.Resources {
+		if i < len(n.Ordering) {
+			o.Record(r.ResourceID, n.Ordering[i].Time)
+			continue
+		}
+		o.Record(r.ResourceID, resourceTime(r, n.Event.EventMeta.EventTime))
+	}
+}
@@ -0,0 +1,113 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestOrderTracker(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+
+	o, err := NewOrderTracker(2)
+	if err != nil {
+		t.Fatalf("TestOrderTracker: NewOrderTracker(): got err == %s, want err == nil", err)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		time     time.Time
+		record   bool
+		want     Order
+		wantLast time.Time
+	}{
+		{name: "First", id: "a", time: t0, record: true, want: OrderFirst},
+		{name: "Duplicate", id: "a", time: t0, want: OrderDuplicate, wantLast: t0},
+		{name: "In order", id: "a", time: t1, record: true, want: OrderInOrder, wantLast: t0},
+		{name: "Stale", id: "a", time: t0, record: true, want: OrderStale, wantLast: t1},
+		{name: "Stale record is ignored", id: "a", time: t1, want: OrderDuplicate, wantLast: t1},
+		{name: "Check does not record", id: "b", time: t0, want: OrderFirst},
+		{name: "Second ID", id: "b", time: t0, record: true, want: OrderFirst},
+		{name: "Third ID evicts the oldest", id: "c", time: t0, record: true, want: OrderFirst},
+		{name: "Evicted", id: "a", time: t1, want: OrderFirst},
+	}
+
+	for _, test := range tests {
+		got := o.Check(test.id, test.time)
+		if got.Order != test.want {
+			t.Errorf("TestOrderTracker(%s): got Order %s, want %s", test.name, got.Order, test.want)
+		}
+		if !got.Last.Equal(test.wantLast) {
+			t.Errorf("TestOrderTracker(%s): got Last %v, want %v", test.name, got.Last, test.wantLast)
+		}
+		if test.record {
+			o.Record(test.id, test.time)
+		}
+	}
+	if o.Len() != 2 {
+		t.Errorf("TestOrderTracker: got Len() == %d, want 2", o.Len())
+	}
+}
+
+func TestRunnerOrdering(t *testing.T) {
+	t.Parallel()
+
+	// The same message twice, as when a message is completed but delivered again.
+	src := newFakeSource(
+		Message{ID: "1", Body: []byte(inlineEvent), DeliveryCount: 1},
+		Message{ID: "2", Body: []byte(inlineEvent), DeliveryCount: 1},
+	)
+
+	var (
+		mu    sync.Mutex
+		order []Order
+	)
+	h := HandlerFunc(func(ctx context.Context, resources []types.NotificationResource) error {
+		n, _ := NotificationFromContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, o := range n.Ordering {
+			order = append(order, o.Order)
+		}
+		return nil
+	})
+
+	o, err := NewOrderTracker(0)
+	if err != nil {
+		t.Fatalf("TestRunnerOrdering: NewOrderTracker(): got err == %s, want err == nil", err)
+	}
+	r, err := New(src, h, WithConcurrency(1), WithPollInterval(time.Millisecond), WithOrderTracking(o))
+	if err != nil {
+		t.Fatalf("TestRunnerOrdering: New(): got err == %s, want err == nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	select {
+	case <-src.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("TestRunnerOrdering: timed out waiting for messages to be handled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []Order{OrderFirst, OrderDuplicate}
+	if len(order) != len(want) {
+		t.Fatalf("TestRunnerOrdering: got orders %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("TestRunnerOrdering: got orders %v, want %v", order, want)
+			break
+		}
+	}
+}
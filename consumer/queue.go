@@ -0,0 +1,285 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/build"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+const (
+	// storageScope is the scope used to authenticate to Azure Storage.
+	storageScope = "https://storage.azure.com/.default"
+	// queueAPIVersion is the version of the Storage Queue REST API that is used.
+	queueAPIVersion = "2021-12-02"
+	// maxQueueMessages is the maximum number of messages Storage Queues return in one request.
+	maxQueueMessages = 32
+)
+
+// StorageQueue is a Source that receives messages from an Azure Storage Queue. Messages that are
+// dead-lettered are moved to a poison queue, which is named after the queue with a "-poison" suffix.
+type StorageQueue struct {
+	client     *azcore.Client
+	queueURL   string
+	poisonURL  string
+	visibility time.Duration
+	opts       *policy.ClientOptions
+}
+
+// QueueOption is an option for NewStorageQueue().
+type QueueOption func(*StorageQueue) error
+
+// WithVisibilityTimeout sets how long a received message is hidden from other consumers. If the message
+// is not completed in this time, it is delivered again. Defaults to 5 minutes.
+func WithVisibilityTimeout(d time.Duration) QueueOption {
+	return func(q *StorageQueue) error {
+		if d < time.Second || d > 7*24*time.Hour {
+			return fmt.Errorf("visibility timeout must be between 1 second and 7 days")
+		}
+		q.visibility = d
+		return nil
+	}
+}
+
+// WithPoisonQueue sets the name of the queue that dead-lettered messages are moved to. The queue is
+// created if it does not exist. Defaults to the queue name with a "-poison" suffix.
+func WithPoisonQueue(name string) QueueOption {
+	return func(q *StorageQueue) error {
+		if name == "" {
+			return fmt.Errorf("poison queue name cannot be empty")
+		}
+		u, err := url.Parse(q.queueURL)
+		if err != nil {
+			return err
+		}
+		u.Path = "/" + name
+		q.poisonURL = u.String()
+		return nil
+	}
+}
+
+// WithQueueClientOptions sets the azcore client options used to talk to the queue.
+func WithQueueClientOptions(opts *policy.ClientOptions) QueueOption {
+	return func(q *StorageQueue) error {
+		q.opts = opts
+		return nil
+	}
+}
+
+// NewStorageQueue creates a Source for the Storage Queue at queueURL, like
+// "https://account.queue.core.windows.net/queue". cred must have the "Storage Queue Data Message Processor"
+// role on the queue, and permission to create and add messages to the poison queue.
+func NewStorageQueue(queueURL string, cred azcore.TokenCredential, options ...QueueOption) (*StorageQueue, error) {
+	if cred == nil {
+		return nil, fmt.Errorf("cred cannot be nil")
+	}
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return nil, fmt.Errorf("queueURL(%s) is not a valid URL: %w", queueURL, err)
+	}
+	name := strings.Trim(u.Path, "/")
+	if u.Scheme == "" || u.Host == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("queueURL(%s) must be like https://account.queue.core.windows.net/queue", queueURL)
+	}
+	u.Path = "/" + name
+	u.RawQuery = ""
+
+	q := &StorageQueue{
+		queueURL:   u.String(),
+		poisonURL:  u.String() + "-poison",
+		visibility: 5 * time.Minute,
+	}
+	for _, o := range options {
+		if err := o(q); err != nil {
+			return nil, err
+		}
+	}
+
+	plOpts := runtime.PipelineOptions{
+		PerRetry: []policy.Policy{
+			runtime.NewBearerTokenPolicy(cred, []string{storageScope}, nil),
+		},
+	}
+	q.client, err = azcore.NewClient("arn.StorageQueue", build.Version, plOpts, q.opts)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// queueToken is the Message.Token of a message received from a StorageQueue.
+type queueToken struct {
+	popReceipt string
+	// text is the MessageText as it was received, which is sent to the poison queue unchanged.
+	text string
+}
+
+// queueMessage is a message in a Storage Queue "Get Messages" response.
+type queueMessage struct {
+	MessageID    string `xml:"MessageId"`
+	PopReceipt   string `xml:"PopReceipt"`
+	DequeueCount int    `xml:"DequeueCount"`
+	MessageText  string `xml:"MessageText"`
+}
+
+// queueMessagesList is the body of a Storage Queue "Get Messages" response.
+type queueMessagesList struct {
+	XMLName  xml.Name       `xml:"QueueMessagesList"`
+	Messages []queueMessage `xml:"QueueMessage"`
+}
+
+// putMessage is the body of a Storage Queue "Put Message" request.
+type putMessage struct {
+	XMLName     xml.Name `xml:"QueueMessage"`
+	MessageText string   `xml:"MessageText"`
+}
+
+// Receive implements Source.Receive(). Storage Queues do not wait for messages to arrive.
+func (q *StorageQueue) Receive(ctx context.Context, n int) ([]Message, error) {
+	n = min(max(n, 1), maxQueueMessages)
+
+	query := url.Values{}
+	query.Set("numofmessages", strconv.Itoa(n))
+	query.Set("visibilitytimeout", strconv.Itoa(int(q.visibility/time.Second)))
+
+	resp, err := q.do(ctx, http.MethodGet, q.queueURL+"/messages?"+query.Encode(), nil, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("could not receive messages: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list queueMessagesList
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("could not decode messages: %w", err)
+	}
+
+	msgs := make([]Message, 0, len(list.Messages))
+	for _, m := range list.Messages {
+		msgs = append(msgs, Message{
+			ID:            m.MessageID,
+			Body:          messageBody(m.MessageText),
+			DeliveryCount: m.DequeueCount,
+			Token:         queueToken{popReceipt: m.PopReceipt, text: m.MessageText},
+		})
+	}
+	return msgs, nil
+}
+
+// Complete implements Source.Complete() by deleting the message.
+func (q *StorageQueue) Complete(ctx context.Context, m Message) error {
+	t, ok := m.Token.(queueToken)
+	if !ok {
+		return fmt.Errorf("message %s was not received from a StorageQueue", m.ID)
+	}
+	query := url.Values{}
+	query.Set("popreceipt", t.popReceipt)
+
+	resp, err := q.do(ctx, http.MethodDelete, q.messageURL(m.ID)+"?"+query.Encode(), nil, http.StatusNoContent)
+	if err != nil {
+		return fmt.Errorf("could not delete message %s: %w", m.ID, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Abandon implements Source.Abandon() by making the message visible again.
+func (q *StorageQueue) Abandon(ctx context.Context, m Message) error {
+	t, ok := m.Token.(queueToken)
+	if !ok {
+		return fmt.Errorf("message %s was not received from a StorageQueue", m.ID)
+	}
+	query := url.Values{}
+	query.Set("popreceipt", t.popReceipt)
+	query.Set("visibilitytimeout", "0")
+
+	resp, err := q.do(ctx, http.MethodPut, q.messageURL(m.ID)+"?"+query.Encode(), nil, http.StatusNoContent)
+	if err != nil {
+		return fmt.Errorf("could not abandon message %s: %w", m.ID, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeadLetter implements Source.DeadLetter() by adding the message to the poison queue and deleting it from
+// the queue. Storage Queues have no place to store the reason, so it is not kept.
+func (q *StorageQueue) DeadLetter(ctx context.Context, m Message, reason string) error {
+	t, ok := m.Token.(queueToken)
+	if !ok {
+		return fmt.Errorf("message %s was not received from a StorageQueue", m.ID)
+	}
+
+	// Creating a queue that already exists with no metadata succeeds with a 204.
+	resp, err := q.do(ctx, http.MethodPut, q.poisonURL, nil, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return fmt.Errorf("could not create poison queue: %w", err)
+	}
+	resp.Body.Close()
+
+	body, err := xml.Marshal(putMessage{MessageText: t.text})
+	if err != nil {
+		return err
+	}
+	resp, err = q.do(ctx, http.MethodPost, q.poisonURL+"/messages?messagettl=-1", body, http.StatusCreated)
+	if err != nil {
+		return fmt.Errorf("could not add message %s to poison queue: %w", m.ID, err)
+	}
+	resp.Body.Close()
+
+	return q.Complete(ctx, m)
+}
+
+// messageURL returns the URL of the message with id.
+func (q *StorageQueue) messageURL(id string) string {
+	return q.queueURL + "/messages/" + url.PathEscape(id)
+}
+
+// do sends a request to the queue service and returns an error if the status code is not one of want.
+func (q *StorageQueue) do(ctx context.Context, method, u string, body []byte, want ...int) (*http.Response, error) {
+	req, err := runtime.NewRequest(ctx, method, u)
+	if err != nil {
+		return nil, err
+	}
+	req.Raw().Header.Set("x-ms-version", queueAPIVersion)
+	if body != nil {
+		if err := req.SetBody(streaming.NopCloser(bytes.NewReader(body)), "application/xml"); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := q.client.Pipeline().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range want {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
+
+// messageBody returns the body of a message. Event Grid base64 encodes the events it sends to Storage
+// Queues, but other writers may not, so the text is only decoded if it is base64.
+func messageBody(text string) []byte {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil || len(b) == 0 {
+		return []byte(text)
+	}
+	return b
+}
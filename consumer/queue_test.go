@@ -0,0 +1,142 @@
+package consumer
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+type fakeCred struct{}
+
+func (fakeCred) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestStorageQueue(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		requests []string
+		poison   string
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		if r.Header.Get("x-ms-version") == "" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/arn/messages":
+			if r.URL.Query().Get("numofmessages") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			text := base64.StdEncoding.EncodeToString([]byte(inlineEvent))
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><QueueMessagesList><QueueMessage><MessageId>id1</MessageId><PopReceipt>pop1</PopReceipt><DequeueCount>2</DequeueCount><MessageText>`+text+`</MessageText></QueueMessage></QueueMessagesList>`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/arn/messages/id1":
+			if r.URL.Query().Get("popreceipt") != "pop1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut && r.URL.Path == "/arn/messages/id1":
+			if r.URL.Query().Get("visibilitytimeout") != "0" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut && r.URL.Path == "/arn-poison":
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPost && r.URL.Path == "/arn-poison/messages":
+			b, _ := io.ReadAll(r.Body)
+			poison = string(b)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	q, err := NewStorageQueue(srv.URL+"/arn", fakeCred{}, WithQueueClientOptions(&policy.ClientOptions{Transport: srv.Client()}))
+	if err != nil {
+		t.Fatalf("TestStorageQueue: NewStorageQueue(): got err == %s, want err == nil", err)
+	}
+
+	ctx := context.Background()
+	msgs, err := q.Receive(ctx, 2)
+	if err != nil {
+		t.Fatalf("TestStorageQueue: Receive(): got err == %s, want err == nil", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("TestStorageQueue: Receive(): got %d messages, want 1", len(msgs))
+	}
+	m := msgs[0]
+	if m.ID != "id1" || m.DeliveryCount != 2 || string(m.Body) != inlineEvent {
+		t.Errorf("TestStorageQueue: Receive(): got message %+v, want base64 decoded message id1 with DeliveryCount 2", m)
+	}
+
+	if err := q.Complete(ctx, m); err != nil {
+		t.Errorf("TestStorageQueue: Complete(): got err == %s, want err == nil", err)
+	}
+	if err := q.Abandon(ctx, m); err != nil {
+		t.Errorf("TestStorageQueue: Abandon(): got err == %s, want err == nil", err)
+	}
+	if err := q.DeadLetter(ctx, m, "reason"); err != nil {
+		t.Errorf("TestStorageQueue: DeadLetter(): got err == %s, want err == nil", err)
+	}
+	if !strings.Contains(poison, m.Token.(queueToken).text) {
+		t.Errorf("TestStorageQueue: DeadLetter(): got poison message %q, want it to hold the original message text", poison)
+	}
+	if err := q.Complete(ctx, Message{ID: "id1"}); err == nil {
+		t.Errorf("TestStorageQueue: Complete(message without token): got err == nil, want err != nil")
+	}
+}
+
+func TestNewStorageQueue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		url        string
+		options    []QueueOption
+		wantPoison string
+		wantErr    bool
+	}{
+		{name: "Success", url: "https://account.queue.core.windows.net/arn/", wantPoison: "https://account.queue.core.windows.net/arn-poison"},
+		{name: "Poison queue", url: "https://account.queue.core.windows.net/arn", options: []QueueOption{WithPoisonQueue("bad")}, wantPoison: "https://account.queue.core.windows.net/bad"},
+		{name: "Error: no queue", url: "https://account.queue.core.windows.net", wantErr: true},
+		{name: "Error: no host", url: "/arn", wantErr: true},
+		{name: "Error: bad visibility", url: "https://account.queue.core.windows.net/arn", options: []QueueOption{WithVisibilityTimeout(0)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		q, err := NewStorageQueue(test.url, fakeCred{}, test.options...)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNewStorageQueue(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNewStorageQueue(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if q.poisonURL != test.wantPoison {
+			t.Errorf("TestNewStorageQueue(%s): got poison URL %q, want %q", test.name, q.poisonURL, test.wantPoison)
+		}
+	}
+}
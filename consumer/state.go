@@ -0,0 +1,245 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+// DefaultTombstoneTTL is the default time a deleted resource is remembered for.
+const DefaultTombstoneTTL = time.Hour
+
+// Activity returns the ARN activity of an event from its EventType, which is like
+// "Microsoft.ContainerService/managedClusters/write". It returns types.ActUnknown for other actions.
+func Activity(eventType string) types.Activity {
+	act := eventType[strings.LastIndexByte(eventType, '/')+1:]
+	switch {
+	case strings.EqualFold(act, types.ActWrite.String()):
+		return types.ActWrite
+	case strings.EqualFold(act, types.ActDelete.String()):
+		return types.ActDelete
+	case strings.EqualFold(act, types.ActSnapshot.String()):
+		return types.ActSnapshot
+	}
+	return types.ActUnknown
+}
+
+// State folds a stream of notifications into the current state of each resource, which is useful for
+// building a cache of resource state. Writes and snapshots replace the resource if they are newer than what
+// is held, deletes remove it. Deleted resources are remembered as tombstones for the TombstoneTTL so that an
+// older write that arrives after the delete does not bring the resource back. Resource IDs are compared
+// case-insensitively, as they are in ARM. Thread-safe.
+//
+// To remove resources that were deleted without a notification, wrap a full snapshot in BeginSnapshot()
+// and EndSnapshot(). Any resource that was not in a notification in between is removed.
+type State struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*stateEntry
+	gen       uint64
+	inSnap    bool
+	snapScope string
+}
+
+// stateEntry is a resource held by State.
+type stateEntry struct {
+	rsc     types.NotificationResource
+	time    time.Time
+	deleted bool
+	// deletedAt is when the tombstone was created, which is used for the TombstoneTTL.
+	deletedAt time.Time
+	// gen is the snapshot generation that last saw the resource.
+	gen uint64
+}
+
+// StateOption is an option for NewState().
+type StateOption func(*State) error
+
+// WithTombstoneTTL sets how long deleted resources are remembered for. Defaults to DefaultTombstoneTTL.
+func WithTombstoneTTL(d time.Duration) StateOption {
+	return func(s *State) error {
+		if d <= 0 {
+			return fmt.Errorf("tombstone TTL must be greater than 0")
+		}
+		s.ttl = d
+		return nil
+	}
+}
+
+// NewState creates a new State.
+func NewState(options ...StateOption) (*State, error) {
+	s := &State{
+		ttl:     DefaultTombstoneTTL,
+		now:     time.Now,
+		entries: map[string]*stateEntry{},
+	}
+	for _, o := range options {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Apply folds the resources in n into the state. It returns the number of resources that changed the state.
+// Resources that are older than what is held are skipped.
+func (s *State) Apply(n Notification) int {
+	act := Activity(n.Event.EventMeta.EventType)
+	eventTime := n.Event.EventMeta.EventTime
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := 0
+	for _, r := range n.Resources {
+		t := resourceTime(r, eventTime)
+		switch {
+		case act == types.ActDelete || r.ResourceSystemProperties.ChangeAction == types.CADelete:
+			if s.delete(r.ResourceID, t) {
+				changed++
+			}
+		default:
+			if r.ResourceSystemProperties.ChangeAction == types.CAMove && r.SourceResourceID != "" {
+				s.delete(r.SourceResourceID, t)
+			}
+			if s.write(r, t) {
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+// write stores r if it is newer than what is held. The caller must hold s.mu.
+func (s *State) write(r types.NotificationResource, t time.Time) bool {
+	key := strings.ToLower(r.ResourceID)
+	e, ok := s.entries[key]
+	if ok {
+		// A resource that is seen again during a snapshot is still present, even if it is not newer.
+		e.gen = s.gen
+		if t.Before(e.time) {
+			return false
+		}
+		if e.deleted && !t.After(e.time) {
+			return false
+		}
+	}
+	s.entries[key] = &stateEntry{rsc: r, time: t, gen: s.gen}
+	return true
+}
+
+// delete replaces the resource with a tombstone if the delete is not older than what is held.
+// The caller must hold s.mu.
+func (s *State) delete(id string, t time.Time) bool {
+	key := strings.ToLower(id)
+	if e, ok := s.entries[key]; ok {
+		if t.Before(e.time) || e.deleted {
+			return false
+		}
+	}
+	s.entries[key] = &stateEntry{time: t, deleted: true, deletedAt: s.now(), gen: s.gen}
+	return true
+}
+
+// Get returns the current state of the resource with id. It returns false if the resource is
+// not held or was deleted.
+func (s *State) Get(id string) (types.NotificationResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[strings.ToLower(id)]
+	if !ok || e.deleted {
+		return types.NotificationResource{}, false
+	}
+	return e.rsc, true
+}
+
+// Resources returns a copy of the current state, keyed by the lower case resource ID. Deleted
+// resources are not included.
+func (s *State) Resources() map[string]types.NotificationResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := make(map[string]types.NotificationResource, len(s.entries))
+	for k, e := range s.entries {
+		if !e.deleted {
+			m[k] = e.rsc
+		}
+	}
+	return m
+}
+
+// Len returns the number of resources in the current state, not counting deleted resources.
+func (s *State) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, e := range s.entries {
+		if !e.deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// BeginSnapshot starts a full snapshot of the resources whose ID starts with scope, like
+// "/subscriptions/{subID}". An empty scope covers every resource. Call EndSnapshot() once every
+// notification of the snapshot has been applied.
+func (s *State) BeginSnapshot(scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inSnap {
+		return fmt.Errorf("a snapshot of scope %q is already in progress", s.snapScope)
+	}
+	s.gen++
+	s.inSnap = true
+	s.snapScope = strings.ToLower(scope)
+	return nil
+}
+
+// EndSnapshot ends the snapshot started by BeginSnapshot(). Every resource in the scope that was not in a
+// notification applied since BeginSnapshot() is deleted. It returns the IDs of the deleted resources.
+func (s *State) EndSnapshot() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.inSnap {
+		return nil, fmt.Errorf("no snapshot is in progress")
+	}
+	s.inSnap = false
+
+	var removed []string
+	now := s.now()
+	for k, e := range s.entries {
+		if e.deleted || e.gen == s.gen || !strings.HasPrefix(k, s.snapScope) {
+			continue
+		}
+		removed = append(removed, e.rsc.ResourceID)
+		s.entries[k] = &stateEntry{time: e.time, deleted: true, deletedAt: now, gen: s.gen}
+	}
+	return removed, nil
+}
+
+// Compact removes tombstones that are older than the TombstoneTTL. Call this periodically to bound the
+// memory used by deleted resources. It returns the number of tombstones removed.
+func (s *State) Compact() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	cutoff := s.now().Add(-s.ttl)
+	for k, e := range s.entries {
+		if e.deleted && e.deletedAt.Before(cutoff) {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n
+}
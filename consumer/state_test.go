@@ -0,0 +1,161 @@
+package consumer
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestActivity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		eventType string
+		want      types.Activity
+	}{
+		{eventType: "Microsoft.ContainerService/managedClusters/write", want: types.ActWrite},
+		{eventType: "Microsoft.ContainerService/managedClusters/DELETE", want: types.ActDelete},
+		{eventType: "Microsoft.ContainerService/managedClusters/snapshot", want: types.ActSnapshot},
+		{eventType: "Microsoft.ContainerService/managedClusters/start/action", want: types.ActUnknown},
+		{eventType: "", want: types.ActUnknown},
+	}
+
+	for _, test := range tests {
+		if got := Activity(test.eventType); got != test.want {
+			t.Errorf("TestActivity(%s): got %v, want %v", test.eventType, got, test.want)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+	t2 := t0.Add(2 * time.Second)
+
+	notif := func(act string, eventTime time.Time, rscs ...types.NotificationResource) Notification {
+		return Notification{
+			Event:     envelope.Event{EventMeta: envelope.EventMeta{EventType: "Microsoft.Compute/virtualMachines/" + act, EventTime: eventTime}},
+			Resources: rscs,
+		}
+	}
+	rsc := func(id, name string) types.NotificationResource {
+		return types.NotificationResource{ResourceID: id, ArmResource: types.ArmResource{ID: id, Name: name}}
+	}
+
+	now := t0
+	s, err := NewState(WithTombstoneTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("TestState: NewState(): got err == %s, want err == nil", err)
+	}
+	s.now = func() time.Time { return now }
+
+	tests := []struct {
+		name        string
+		apply       Notification
+		wantChanged int
+		wantNames   map[string]string
+	}{
+		{
+			name:        "Write",
+			apply:       notif("write", t1, rsc("/a", "a1"), rsc("/b", "b1")),
+			wantChanged: 2,
+			wantNames:   map[string]string{"/a": "a1", "/b": "b1"},
+		},
+		{
+			name:      "Older write is skipped",
+			apply:     notif("write", t0, rsc("/A", "a0")),
+			wantNames: map[string]string{"/a": "a1", "/b": "b1"},
+		},
+		{
+			name:        "Delete",
+			apply:       notif("delete", t2, rsc("/b", "")),
+			wantChanged: 1,
+			wantNames:   map[string]string{"/a": "a1"},
+		},
+		{
+			name:      "Older write after a delete is skipped",
+			apply:     notif("write", t1, rsc("/b", "b1")),
+			wantNames: map[string]string{"/a": "a1"},
+		},
+		{
+			name: "Move",
+			apply: func() Notification {
+				r := rsc("/c", "c2")
+				r.SourceResourceID = "/a"
+				r.ResourceSystemProperties.ChangeAction = types.CAMove
+				return notif("write", t2, r)
+			}(),
+			wantChanged: 1,
+			wantNames:   map[string]string{"/c": "c2"},
+		},
+		{
+			name: "ResourceEventTime is used over EventTime",
+			apply: func() Notification {
+				r := rsc("/b", "b3")
+				r.ResourceEventTime = t2.Add(time.Second)
+				return notif("snapshot", t0, r)
+			}(),
+			wantChanged: 1,
+			wantNames:   map[string]string{"/b": "b3", "/c": "c2"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := s.Apply(test.apply); got != test.wantChanged {
+			t.Errorf("TestState(%s): got %d changed, want %d", test.name, got, test.wantChanged)
+		}
+		got := s.Resources()
+		if len(got) != len(test.wantNames) || s.Len() != len(test.wantNames) {
+			t.Errorf("TestState(%s): got %d resources, want %d", test.name, len(got), len(test.wantNames))
+			continue
+		}
+		for id, name := range test.wantNames {
+			r, ok := s.Get(id)
+			if !ok || r.ArmResource.Name != name {
+				t.Errorf("TestState(%s): Get(%s): got %q, want %q", test.name, id, r.ArmResource.Name, name)
+			}
+		}
+	}
+
+	// A snapshot of /b only removes /c.
+	if err := s.BeginSnapshot(""); err != nil {
+		t.Fatalf("TestState: BeginSnapshot(): got err == %s, want err == nil", err)
+	}
+	if err := s.BeginSnapshot(""); err == nil {
+		t.Errorf("TestState: BeginSnapshot() twice: got err == nil, want err != nil")
+	}
+	s.Apply(notif("snapshot", t0, rsc("/b", "old")))
+	removed, err := s.EndSnapshot()
+	if err != nil {
+		t.Fatalf("TestState: EndSnapshot(): got err == %s, want err == nil", err)
+	}
+	if !slices.Equal(removed, []string{"/c"}) {
+		t.Errorf("TestState: EndSnapshot(): got removed %v, want [/c]", removed)
+	}
+	if r, ok := s.Get("/b"); !ok || r.ArmResource.Name != "b3" {
+		t.Errorf("TestState: after snapshot: got /b %q, want b3", r.ArmResource.Name)
+	}
+	if _, err := s.EndSnapshot(); err == nil {
+		t.Errorf("TestState: EndSnapshot() twice: got err == nil, want err != nil")
+	}
+
+	// A scoped snapshot does not remove resources outside the scope.
+	s.BeginSnapshot("/other")
+	if removed, _ := s.EndSnapshot(); len(removed) != 0 {
+		t.Errorf("TestState: scoped EndSnapshot(): got removed %v, want none", removed)
+	}
+
+	// Tombstones for /a, /c are removed once they are older than the TTL.
+	if n := s.Compact(); n != 0 {
+		t.Errorf("TestState: Compact(): got %d removed, want 0", n)
+	}
+	now = now.Add(2 * time.Minute)
+	if n := s.Compact(); n != 2 {
+		t.Errorf("TestState: Compact() after TTL: got %d removed, want 2", n)
+	}
+}
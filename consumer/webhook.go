@@ -0,0 +1,141 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Azure/arn-sdk/models/metrics"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// MaxWebhookBody is the largest request body a Webhook reads. Event Grid delivers at most 1 MiB in a request.
+const MaxWebhookBody = 4 * 1024 * 1024
+
+// validationEventType is the type of the event Event Grid sends to validate a webhook endpoint.
+const validationEventType = "Microsoft.EventGrid.SubscriptionValidationEvent"
+
+// Webhook is an http.Handler for an Event Grid subscription that pushes ARN notifications to a webhook,
+// instead of delivering them to a queue that a Runner reads from. It answers the Event Grid endpoint
+// validation handshake, decodes the events, downloads blob payloads and calls the Handler, like a Runner.
+//
+// The response tells Event Grid what to do with the request. A request whose notifications were all handled
+// is answered with 200. A request that cannot succeed, such as one that cannot be decoded or whose Handler
+// returned a Permanent() error, is answered with 400 so that Event Grid dead-letters it instead of retrying. Any
+// other failure is answered with 503 so that Event Grid delivers it again under the subscription's retry
+// policy. The Handler must finish within the subscription's delivery timeout.
+//
+// Webhook does not authenticate requests. Wrap it in middleware that checks the Microsoft Entra token or the
+// secret in the URL that the subscription is set up with.
+type Webhook struct {
+	r *Runner
+}
+
+// NewWebhook creates a Webhook that calls h. Options that tune receiving from a Source, such as
+// WithConcurrency(), WithMaxDeliveries() and WithPollInterval(), have no effect.
+func NewWebhook(h Handler, options ...Option) (*Webhook, error) {
+	r, err := newRunner(nil, h, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{r: r}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodOptions:
+		// The CloudEvents abuse protection handshake.
+		origin := req.Header.Get("WebHook-Request-Origin")
+		if origin == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("WebHook-Allowed-Origin", origin)
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "OPTIONS, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxWebhookBody))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, code := peekEvents(body)
+	if code != "" {
+		w.Header().Set("Content-Type", "application/json")
+		out, _ := json.Marshal(struct {
+			ValidationResponse string `json:"validationResponse"`
+		}{code})
+		w.Write(out)
+		return
+	}
+
+	// Event Grid counts the deliveries before this one.
+	delivered, _ := strconv.Atoi(req.Header.Get("aeg-delivery-count"))
+	m := Message{ID: id, Body: body, DeliveryCount: delivered + 1}
+
+	ctx := req.Context()
+	r := wh.r
+	err = r.handleMessage(ctx, m)
+	switch {
+	case err == nil:
+		r.metrics.ConsumeMessage(ctx, metrics.ConsumeCompleted)
+		w.WriteHeader(http.StatusOK)
+	case isPermanent(err):
+		r.log.Error("rejecting poison webhook request, Event Grid will dead-letter it", "id", m.ID, "error", err.Error())
+		r.metrics.ConsumeMessage(ctx, metrics.ConsumeDeadLettered)
+		if r.deadLetterFn != nil {
+			r.deadLetterFn(ctx, m, err.Error(), err)
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		r.log.Warn("could not handle webhook request, Event Grid will deliver it again", "id", m.ID, "error", err.Error())
+		r.metrics.ConsumeMessage(ctx, metrics.ConsumeAbandoned)
+		http.Error(w, fmt.Sprintf("could not handle the events: %s", err), http.StatusServiceUnavailable)
+	}
+}
+
+// peekEvents returns the ID of the first event in body and, if it is an Event Grid validation event, its
+// validation code. body is one event or a list of them.
+func peekEvents(body []byte) (id string, code string) {
+	type event struct {
+		ID        string `json:"id"`
+		EventType string `json:"eventType"`
+		Data      struct {
+			ValidationCode string `json:"validationCode"`
+		} `json:"data"`
+	}
+	var e event
+	switch jsontext.Value(body).Kind() {
+	case '[':
+		var events []event
+		if json.Unmarshal(body, &events) != nil || len(events) == 0 {
+			return "", ""
+		}
+		e = events[0]
+	case '{':
+		if json.Unmarshal(body, &e) != nil {
+			return "", ""
+		}
+	}
+	if e.EventType == validationEventType {
+		return e.ID, e.Data.ValidationCode
+	}
+	return e.ID, ""
+}
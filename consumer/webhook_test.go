@@ -0,0 +1,166 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	const validation = `[{"id": "v1", "topic": "t", "subject": "", "eventType": "Microsoft.EventGrid.SubscriptionValidationEvent", "eventTime": "2024-01-01T00:00:00Z", "dataVersion": "2", "metadataVersion": "1", "data": {"validationCode": "code", "validationUrl": "https://example.com"}}]`
+
+	var (
+		mu          sync.Mutex
+		seen        = map[string]Notification{}
+		deadLetters = map[string]Message{}
+	)
+	h := HandlerFunc(func(ctx context.Context, resources []types.NotificationResource) error {
+		n, _ := NotificationFromContext(ctx)
+		mu.Lock()
+		seen[n.MessageID] = n
+		mu.Unlock()
+		switch n.MessageID {
+		case "err":
+			return errors.New("error")
+		case "permanent":
+			return Permanent(errors.New("error"))
+		}
+		return nil
+	})
+	dlFn := func(ctx context.Context, m Message, reason string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		deadLetters[m.ID] = m
+	}
+
+	wh, err := NewWebhook(h, WithRetry(RetryPolicy{MaxAttempts: 1, BaseDelay: time.Millisecond}), WithDeadLetter(dlFn))
+	if err != nil {
+		t.Fatalf("TestWebhook: NewWebhook(): got err == %s, want err == nil", err)
+	}
+
+	withID := func(id string) string {
+		return strings.Replace(inlineEvent, `"id": "1"`, `"id": "`+id+`"`, 1)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		body       string
+		wantCode   int
+		wantBody   string
+		wantHeader map[string]string
+		wantSeen   string
+		wantDL     string
+	}{
+		{
+			name:       "Abuse protection handshake",
+			method:     http.MethodOptions,
+			header:     map[string]string{"WebHook-Request-Origin": "eventgrid.azure.net"},
+			wantCode:   http.StatusOK,
+			wantHeader: map[string]string{"WebHook-Allowed-Origin": "eventgrid.azure.net"},
+		},
+		{
+			name:     "Error: handshake without origin",
+			method:   http.MethodOptions,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Subscription validation",
+			method:   http.MethodPost,
+			body:     validation,
+			wantCode: http.StatusOK,
+			wantBody: `{"validationResponse":"code"}`,
+		},
+		{
+			name:     "Success",
+			method:   http.MethodPost,
+			header:   map[string]string{"aeg-delivery-count": "0"},
+			body:     `[` + withID("ok") + `]`,
+			wantCode: http.StatusOK,
+			wantSeen: "ok",
+		},
+		{
+			name:     "Error: handler error is retried",
+			method:   http.MethodPost,
+			body:     withID("err"),
+			wantCode: http.StatusServiceUnavailable,
+			wantSeen: "err",
+		},
+		{
+			name:     "Error: permanent handler error is dead-lettered",
+			method:   http.MethodPost,
+			header:   map[string]string{"aeg-delivery-count": "2"},
+			body:     withID("permanent"),
+			wantCode: http.StatusBadRequest,
+			wantSeen: "permanent",
+			wantDL:   "permanent",
+		},
+		{
+			name:     "Error: garbage is dead-lettered",
+			method:   http.MethodPost,
+			body:     `not json`,
+			wantCode: http.StatusBadRequest,
+			wantDL:   "",
+		},
+		{
+			name:     "Error: wrong method",
+			method:   http.MethodGet,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/arn", strings.NewReader(test.body))
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		wh.ServeHTTP(rec, req)
+
+		if rec.Code != test.wantCode {
+			t.Errorf("TestWebhook(%s): got code %d, want %d", test.name, rec.Code, test.wantCode)
+			continue
+		}
+		if test.wantBody != "" && rec.Body.String() != test.wantBody {
+			t.Errorf("TestWebhook(%s): got body %q, want %q", test.name, rec.Body.String(), test.wantBody)
+		}
+		for k, v := range test.wantHeader {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("TestWebhook(%s): got header %s == %q, want %q", test.name, k, got, v)
+			}
+		}
+
+		mu.Lock()
+		if test.wantSeen != "" {
+			n, ok := seen[test.wantSeen]
+			if !ok {
+				t.Errorf("TestWebhook(%s): handler was not called for %q", test.name, test.wantSeen)
+			} else if len(n.Resources) != 1 {
+				t.Errorf("TestWebhook(%s): got %d resources, want 1", test.name, len(n.Resources))
+			}
+		}
+		if test.wantDL != "" {
+			if _, ok := deadLetters[test.wantDL]; !ok {
+				t.Errorf("TestWebhook(%s): %q was not dead-lettered", test.name, test.wantDL)
+			}
+		}
+		mu.Unlock()
+	}
+
+	if m := deadLetters["permanent"]; m.DeliveryCount != 3 {
+		t.Errorf("TestWebhook: got DeliveryCount %d, want 3", m.DeliveryCount)
+	}
+	if _, ok := seen["v1"]; ok {
+		t.Errorf("TestWebhook: handler was called for the validation event")
+	}
+}
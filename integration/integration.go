@@ -0,0 +1,172 @@
+/*
+Package integration is the supported contract between the ARN SDK and batch producers, such as tattler, that
+read resource changes in bulk and publish them as notifications. It holds what such a producer needs from the
+client without depending on internal packages or copying glue code between projects:
+
+  - Sink, the interface a producer sends to. *client.ARN implements it, and tests can fake it.
+  - BatchSink, which sends a batch of notifications and returns the result of each one.
+  - WatchErrors(), which reads the stream of errors for notifications sent without a promise.
+  - Limits, the size hints a producer uses to cut its batches so they are not rejected.
+  - Transient(), which tells a producer whether a failed notification is worth sending again.
+
+The types and functions in this package are stable.
+
+Example - publishing a batch:
+
+	arn, err := client.New(ctx, args)
+	if err != nil {
+		panic(err)
+	}
+	defer arn.Close()
+
+	// Cut the changes into notifications, starting a new one when the next resource
+	// does not fit: integration.LimitsOf(arn).Fits(items, bytes).
+	batch := cut(changes, integration.LimitsOf(arn))
+
+	bs, err := integration.NewBatchSink(arn)
+	if err != nil {
+		panic(err)
+	}
+
+	for i, err := range bs.Send(ctx, batch) {
+		if err != nil && integration.Transient(err) {
+			// Send batch[i] again later.
+		}
+	}
+*/
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/arn-sdk/client"
+	"github.com/Azure/arn-sdk/models"
+)
+
+// Sink is where a producer sends notifications. The methods are those of *client.ARN, see it for details.
+type Sink interface {
+	// Notify sends n and waits for the result.
+	Notify(ctx context.Context, n models.Notifications) error
+	// Async queues n to be sent. If promise is true, the result is sent to the promise of the returned
+	// notification, otherwise a failure goes to Errors().
+	Async(ctx context.Context, n models.Notifications, promise bool) models.Notifications
+	// Errors returns the stream of failures of notifications sent with Async() without a promise.
+	Errors() <-chan error
+	// MaxItems returns the maximum number of items in a notification.
+	MaxItems() int
+	// InlineOnly returns true if notifications with resources of MaxInlineBytes or more cannot be sent.
+	InlineOnly() bool
+}
+
+var _ Sink = (*client.ARN)(nil)
+
+// MaxInlineBytes is the size in bytes of a notification's resources, as JSON, at which they are sent through
+// blob storage instead of inline. See client.MaxInlineSize.
+const MaxInlineBytes = client.MaxInlineSize
+
+// Limits are the size hints a producer uses to cut its batches into notifications that a Sink accepts.
+type Limits struct {
+	// MaxItems is the maximum number of items (Notifications.DataCount()) in a notification.
+	MaxItems int
+	// MaxInlineBytes is the size of a notification's resources, as JSON, at which they are sent through blob
+	// storage. Notifications at or above it are slower to send, and fail if InlineOnly is set.
+	MaxInlineBytes int
+	// InlineOnly is true if the Sink has no blob storage.
+	InlineOnly bool
+}
+
+// LimitsOf returns the Limits of s.
+func LimitsOf(s Sink) Limits {
+	return Limits{
+		MaxItems:       s.MaxItems(),
+		MaxInlineBytes: MaxInlineBytes,
+		InlineOnly:     s.InlineOnly(),
+	}
+}
+
+// Fits returns true if a notification with items items and bytes bytes of resource JSON can be sent inline
+// within l. A producer can use this to decide when to start a new notification as it adds resources.
+func (l Limits) Fits(items, bytes int) bool {
+	return items <= l.MaxItems && bytes < l.MaxInlineBytes
+}
+
+// Check returns an error wrapping models.ErrBatchSize if n has more than l.MaxItems items, or wrapping
+// models.ErrNoBlobClient if l.InlineOnly is set and the resources of n are too large to send inline. It uses
+// Notifications.DataSizeHint(), so it serializes the resources of n.
+func (l Limits) Check(n models.Notifications) error {
+	if x := n.DataCount(); x > l.MaxItems {
+		return fmt.Errorf("%w: notification has %d items, the limit is %d", models.ErrBatchSize, x, l.MaxItems)
+	}
+	if !l.InlineOnly {
+		return nil
+	}
+	size := n.DataSizeHint()
+	if size < 0 {
+		return errors.New("notification resources cannot be serialized")
+	}
+	if size >= l.MaxInlineBytes {
+		return fmt.Errorf("%w: resources are %d bytes, the inline limit is %d", models.ErrNoBlobClient, size, l.MaxInlineBytes)
+	}
+	return nil
+}
+
+// Transient returns true if err, the result of a notification, is worth sending the notification again
+// later. This is models.IsRetryable(), see it for details. Use models.IsThrottled() to tell when to slow
+// down and models.IsValidation() for notifications that fail again unless they are changed.
+func Transient(err error) bool {
+	return models.IsRetryable(err)
+}
+
+// BatchSink sends batches of notifications to a Sink and returns the result of each. Thread-safe.
+type BatchSink struct {
+	sink Sink
+}
+
+// NewBatchSink returns a BatchSink that sends to s.
+func NewBatchSink(s Sink) (*BatchSink, error) {
+	if s == nil {
+		return nil, errors.New("sink is required")
+	}
+	return &BatchSink{sink: s}, nil
+}
+
+// Send sends each notification in batch with Async(), in order, and waits for their results. The error at
+// index i is the result of batch[i]. Notifications are queued in order, but like Async() they are not
+// guaranteed to be sent in order. If ctx ends, the notifications that have no result yet get an error
+// wrapping models.ErrPromiseTimeout or models.ErrPromiseCanceled and may still be sent.
+func (b *BatchSink) Send(ctx context.Context, batch []models.Notifications) []error {
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, n := range batch {
+		n = b.sink.Async(ctx, n, true)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.Promise(ctx)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// WatchErrors calls fn with each error from the Errors() stream of s until ctx ends. Errors are from
+// notifications sent with Async() without a promise. The stream has a small buffer and the client drops
+// errors while it is full, so a producer that uses Async() without promises must read it to see its failures.
+// fn is called from one goroutine at a time.
+func WatchErrors(ctx context.Context, s Sink, fn func(err error)) {
+	errs := s.Errors()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			fn(err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/client"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+// fakeSink is a Sink for the tests that do not send.
+type fakeSink struct {
+	Sink
+	maxItems   int
+	inlineOnly bool
+	errs       chan error
+}
+
+func (f *fakeSink) MaxItems() int        { return f.maxItems }
+func (f *fakeSink) InlineOnly() bool     { return f.inlineOnly }
+func (f *fakeSink) Errors() <-chan error { return f.errs }
+
+// badSender fails the events that hold the string "bad".
+type badSender struct{}
+
+func (badSender) Send(ctx context.Context, event []byte) error {
+	if bytes.Contains(event, []byte(`"bad"`)) {
+		return &azcore.ResponseError{StatusCode: http.StatusBadRequest}
+	}
+	return nil
+}
+
+type fakeUploader struct{}
+
+func (fakeUploader) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	return url.Parse("https://blob/" + id)
+}
+
+func notification(t *testing.T, props any) msgs.Notifications {
+	t.Helper()
+
+	id, err := arm.ParseResourceID("/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+	if err != nil {
+		t.Fatalf("arm.ParseResourceID(): %v", err)
+	}
+	ar, err := types.NewArmResource(types.ActWrite, id, "2024-01-01", props)
+	if err != nil {
+		t.Fatalf("types.NewArmResource(): %v", err)
+	}
+	return msgs.Notifications{
+		ResourceLocation: "eastus",
+		PublisherInfo:    "Microsoft.ContainerService",
+		Data: []types.NotificationResource{
+			{
+				ResourceID:               id.String(),
+				APIVersion:               "2024-01-01",
+				ResourceEventTime:        time.Now().UTC(),
+				ArmResource:              ar,
+				ResourceSystemProperties: types.ResourceSystemProperties{ChangeAction: types.CAUpdate},
+			},
+		},
+	}
+}
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	small := notification(t, map[string]any{"a": 1})
+	big := notification(t, map[string]any{"a": strings.Repeat("x", MaxInlineBytes)})
+	two := notification(t, map[string]any{"a": 1})
+	two.Data = append(two.Data, two.Data[0])
+
+	tests := []struct {
+		name       string
+		inlineOnly bool
+		n          models.Notifications
+		wantIs     error
+	}{
+		{name: "small", n: small},
+		{name: "big with blob storage", n: big},
+		{name: "Error: too many items", n: two, wantIs: models.ErrBatchSize},
+		{name: "Error: big and inline only", inlineOnly: true, n: big, wantIs: models.ErrNoBlobClient},
+		{name: "small and inline only", inlineOnly: true, n: small},
+	}
+
+	for _, test := range tests {
+		l := LimitsOf(&fakeSink{maxItems: 1, inlineOnly: test.inlineOnly})
+		if l.MaxItems != 1 || l.MaxInlineBytes != MaxInlineBytes || l.InlineOnly != test.inlineOnly {
+			t.Errorf("TestLimits(%s): got LimitsOf() %+v, want the limits of the sink", test.name, l)
+		}
+		err := l.Check(test.n)
+		switch {
+		case test.wantIs == nil && err != nil:
+			t.Errorf("TestLimits(%s): got err == %s, want err == nil", test.name, err)
+		case !errors.Is(err, test.wantIs):
+			t.Errorf("TestLimits(%s): got err == %v, want errors.Is(err, %v)", test.name, err, test.wantIs)
+		}
+	}
+
+	l := Limits{MaxItems: 2, MaxInlineBytes: 10}
+	if !l.Fits(2, 9) {
+		t.Errorf("TestLimits: Fits(2, 9): got false, want true")
+	}
+	if l.Fits(3, 9) {
+		t.Errorf("TestLimits: Fits(3, 9): got true, want false")
+	}
+	if l.Fits(2, 10) {
+		t.Errorf("TestLimits: Fits(2, 10): got true, want false")
+	}
+}
+
+func TestTransient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil},
+		{name: "503", err: &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "400", err: &azcore.ResponseError{StatusCode: http.StatusBadRequest}},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{name: "circuit open", err: fmt.Errorf("%w: waiting on a probe", models.ErrCircuitOpen), want: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "invalid", err: models.ErrBatchSize},
+	}
+
+	for _, test := range tests {
+		if got := Transient(test.err); got != test.want {
+			t.Errorf("TestTransient(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBatchSink(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewBatchSink(nil); err == nil {
+		t.Errorf("TestBatchSink(nil sink): got err == nil, want err != nil")
+	}
+
+	ctx := context.Background()
+	arn, err := client.New(ctx, client.Args{}, client.WithFakeClients(badSender{}, fakeUploader{}))
+	if err != nil {
+		t.Fatalf("TestBatchSink: client.New(): got err == %s, want err == nil", err)
+	}
+	defer arn.Close()
+
+	bs, err := NewBatchSink(arn)
+	if err != nil {
+		t.Fatalf("TestBatchSink: NewBatchSink(): got err == %s, want err == nil", err)
+	}
+	batch := []models.Notifications{
+		notification(t, map[string]any{"a": "good"}),
+		notification(t, map[string]any{"a": "bad"}),
+		notification(t, map[string]any{"a": "good"}),
+	}
+	errs := bs.Send(ctx, batch)
+	if len(errs) != len(batch) {
+		t.Fatalf("TestBatchSink: got %d results, want %d", len(errs), len(batch))
+	}
+	for i, want := range []bool{false, true, false} {
+		if got := errs[i] != nil; got != want {
+			t.Errorf("TestBatchSink: batch[%d]: got err == %v, want failure == %v", i, errs[i], want)
+		}
+	}
+	if Transient(errs[1]) {
+		t.Errorf("TestBatchSink: a 400 from the receiver is Transient(), want it not to be")
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	t.Parallel()
+
+	s := &fakeSink{errs: make(chan error, 2)}
+	s.errs <- errors.New("a")
+	s.errs <- errors.New("b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchErrors(ctx, s, func(err error) {
+			got = append(got, err.Error())
+			if len(got) == 2 {
+				cancel()
+			}
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestWatchErrors: WatchErrors() did not return after ctx was cancelled")
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Errorf("TestWatchErrors: got errors %v, want [a b]", got)
+	}
+}
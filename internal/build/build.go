@@ -7,3 +7,7 @@ var (
 	Date    = "unknown"
 	BuiltBy = "unknown"
 )
+
+// PprofLabel is the pprof label key used to mark goroutines and stages of the SDK.
+// This allows profiles of busy publishers to attribute CPU and block time to the SDK.
+const PprofLabel = "arn-sdk"
@@ -0,0 +1,55 @@
+package build
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// oldModule is the module path the SDK was published under before github.com/Azure/arn-sdk. Nothing in the
+// tree may import it, as the two paths do not build together.
+const oldModule = "github.com/Azure/arn/"
+
+func TestNoOldModuleImports(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("..", "..")
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		t.Fatalf("TestNoOldModuleImports: could not find the module root: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "testdata" {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, imp := range f.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(p, oldModule) {
+				t.Errorf("TestNoOldModuleImports(%s): imports %s, use github.com/Azure/arn-sdk/ instead", path, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TestNoOldModuleImports: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Package breaker provides a circuit breaker for the ARN receiver. When ARN returns sustained 5xx responses,
+sending each notification only adds load to a receiver that is already failing and leaves promises waiting
+for retries that will not succeed.
+
+After Options.Threshold sends in a row fail with a receiver failure (see Failure()), the Breaker opens and
+notifications fail fast with models.ErrCircuitOpen, so callers can shed load or buffer locally. After
+Options.Cooldown it is half-open and lets one send at a time through as a probe. Options.Probes successful
+probes in a row close it, a failed probe opens it again.
+
+The conn package calls Allow() before each send and Record() with its result.
+*/
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+const (
+	// DefaultThreshold is the default number of failed sends in a row that open the circuit.
+	DefaultThreshold = 5
+	// DefaultCooldown is the default time the circuit stays open before a probe is let through.
+	DefaultCooldown = 30 * time.Second
+	// DefaultProbes is the default number of successful probes in a row that close the circuit.
+	DefaultProbes = 1
+)
+
+// State is the state of a Breaker.
+type State uint8
+
+const (
+	// Closed lets every send through.
+	Closed State = 0
+	// Open fails every send with models.ErrCircuitOpen.
+	Open State = 1
+	// HalfOpen lets one send at a time through as a probe.
+	HalfOpen State = 2
+)
+
+// String implements fmt.Stringer.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "halfOpen"
+	}
+	return fmt.Sprintf("State(%d)", s)
+}
+
+// Options are the options for a Breaker. Zero values are replaced by the defaults.
+type Options struct {
+	// Threshold is the number of sends in a row that fail with a receiver failure to open the circuit.
+	// Defaults to DefaultThreshold.
+	Threshold int
+	// Cooldown is how long the circuit stays open before a probe is let through. Defaults to DefaultCooldown.
+	Cooldown time.Duration
+	// Probes is the number of successful probes in a row that close the circuit. Defaults to DefaultProbes.
+	Probes int
+}
+
+// Defaults returns a copy of o with the zero values replaced by the defaults.
+func (o Options) Defaults() Options {
+	if o.Threshold == 0 {
+		o.Threshold = DefaultThreshold
+	}
+	if o.Cooldown == 0 {
+		o.Cooldown = DefaultCooldown
+	}
+	if o.Probes == 0 {
+		o.Probes = DefaultProbes
+	}
+	return o
+}
+
+// Validate validates the options. This should be called after Defaults().
+func (o Options) Validate() error {
+	switch {
+	case o.Threshold < 1:
+		return errors.New("Threshold must be at least 1")
+	case o.Cooldown < 0:
+		return errors.New("Cooldown cannot be negative")
+	case o.Probes < 1:
+		return errors.New("Probes must be at least 1")
+	}
+	return nil
+}
+
+// Failure returns true if err is a receiver failure that counts towards opening the circuit: a 5xx response
+// or a network error. Other errors, such as a 4xx response or an invalid notification, say nothing about the
+// health of the receiver.
+func Failure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var re *azcore.ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode >= 500
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// Breaker is a circuit breaker. Thread-safe.
+type Breaker struct {
+	opts Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	probes   int
+	// probing is true while a probe let through in HalfOpen has not been recorded.
+	probing  bool
+	openedAt time.Time
+}
+
+// New creates a Breaker.
+func New(o Options) (*Breaker, error) {
+	o = o.Defaults()
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &Breaker{opts: o, now: time.Now}, nil
+}
+
+// State returns the state of the circuit. An open circuit whose cooldown has passed is HalfOpen.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cooled()
+	return b.state
+}
+
+// Allow returns nil if a send can go ahead, or an error wrapping models.ErrCircuitOpen if it cannot. Every
+// send that is allowed must have its result given to Record().
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.cooled()
+	switch b.state {
+	case Closed:
+		return nil
+	case HalfOpen:
+		if !b.probing {
+			b.probing = true
+			return nil
+		}
+		return fmt.Errorf("%w: waiting on a probe", models.ErrCircuitOpen)
+	}
+	return fmt.Errorf("%w: retry after %s", models.ErrCircuitOpen, b.openedAt.Add(b.opts.Cooldown).Format(time.RFC3339))
+}
+
+// Record records the result of a send that Allow() let through.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := Failure(err)
+	switch b.state {
+	case Closed:
+		if !failed {
+			if err == nil {
+				b.failures = 0
+			}
+			return
+		}
+		b.failures++
+		if b.failures >= b.opts.Threshold {
+			b.open()
+		}
+	case HalfOpen:
+		b.probing = false
+		switch {
+		case failed:
+			b.open()
+		case err == nil:
+			b.probes++
+			if b.probes >= b.opts.Probes {
+				b.state = Closed
+				b.failures = 0
+			}
+		}
+	}
+	// A send that was let through before the circuit opened says nothing about the receiver now.
+}
+
+// open opens the circuit. The caller must hold b.mu.
+func (b *Breaker) open() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.probes = 0
+	b.probing = false
+}
+
+// cooled moves an open circuit whose cooldown has passed to HalfOpen. The caller must hold b.mu.
+func (b *Breaker) cooled() {
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.opts.Cooldown)) {
+		b.state = HalfOpen
+	}
+}
@@ -0,0 +1,143 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+var (
+	errServer = &azcore.ResponseError{StatusCode: 503}
+	errClient = &azcore.ResponseError{StatusCode: 400}
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		o       Options
+		wantErr bool
+	}{
+		{name: "Defaults"},
+		{name: "Set", o: Options{Threshold: 2, Cooldown: time.Second, Probes: 3}},
+		{name: "Error: negative threshold", o: Options{Threshold: -1}, wantErr: true},
+		{name: "Error: negative cooldown", o: Options{Cooldown: -1}, wantErr: true},
+		{name: "Error: negative probes", o: Options{Probes: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.o)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestOptions(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestOptions(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil"},
+		{name: "503", err: errServer, want: true},
+		{name: "Wrapped 500", err: fmt.Errorf("send: %w", &azcore.ResponseError{StatusCode: 500}), want: true},
+		{name: "Network error", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{name: "400", err: errClient},
+		{name: "429", err: &azcore.ResponseError{StatusCode: 429}},
+		{name: "Deadline", err: context.DeadlineExceeded},
+		{name: "Other", err: errors.New("invalid notification")},
+	}
+
+	for _, test := range tests {
+		if got := Failure(test.err); got != test.want {
+			t.Errorf("TestFailure(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b, err := New(Options{Threshold: 3, Cooldown: time.Minute, Probes: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.now = func() time.Time { return now }
+
+	send := func(err error) error {
+		if aerr := b.Allow(); aerr != nil {
+			return aerr
+		}
+		b.Record(err)
+		return nil
+	}
+	wantState := func(step string, want State) {
+		t.Helper()
+		if got := b.State(); got != want {
+			t.Errorf("TestBreaker(%s): got state %s, want %s", step, got, want)
+		}
+	}
+
+	// A success and errors that are not receiver failures break up a run of failures.
+	send(errServer)
+	send(errServer)
+	send(nil)
+	send(errServer)
+	send(errClient)
+	send(errServer)
+	wantState("below threshold", Closed)
+
+	send(errServer)
+	wantState("at threshold", Open)
+	if err := send(nil); !errors.Is(err, models.ErrCircuitOpen) {
+		t.Errorf("TestBreaker(open): got Allow() == %v, want models.ErrCircuitOpen", err)
+	}
+
+	now = now.Add(time.Minute)
+	wantState("after cooldown", HalfOpen)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("TestBreaker(first probe): got Allow() == %s, want nil", err)
+	}
+	if err := b.Allow(); !errors.Is(err, models.ErrCircuitOpen) {
+		t.Errorf("TestBreaker(second probe while probing): got Allow() == %v, want models.ErrCircuitOpen", err)
+	}
+	b.Record(errServer)
+	wantState("failed probe", Open)
+
+	now = now.Add(time.Minute)
+	send(nil)
+	wantState("one good probe", HalfOpen)
+	send(errClient)
+	wantState("probe that is not a receiver failure", HalfOpen)
+	send(nil)
+	wantState("two good probes", Closed)
+
+	// The run of failures starts over once closed.
+	send(errServer)
+	send(errServer)
+	wantState("closed again", Closed)
+}
+
+func TestStateString(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[State]string{Closed: "closed", Open: "open", HalfOpen: "halfOpen", 9: "State(9)"} {
+		if got := s.String(); got != want {
+			t.Errorf("TestStateString(%d): got %q, want %q", s, got, want)
+		}
+	}
+}
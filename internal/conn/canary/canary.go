@@ -0,0 +1,28 @@
+/*
+Package canary marks notifications that the model's SendEvent() should send through blob storage even
+though they are small enough to send inline. Small publishers rarely need blob storage, so a
+misconfigured storage account or missing RBAC assignment otherwise goes unnoticed until a large snapshot
+fails. Sending every Nth notification through blob storage keeps that path exercised.
+
+The conn package marks the context of a notification with WithBlob(). SendEvent() checks Blob() and
+reports the result with stats.BlobCanary() and metrics.BlobCanary().
+*/
+package canary
+
+import "context"
+
+type ctxKey struct{}
+
+// WithBlob returns a context that marks its notification as a blob canary.
+func WithBlob(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, true)
+}
+
+// Blob returns true if the notification with ctx is a blob canary.
+func Blob(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	b, _ := ctx.Value(ctxKey{}).(bool)
+	return b
+}
@@ -0,0 +1,26 @@
+package canary
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBlob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{name: "nil context", ctx: nil},
+		{name: "not marked", ctx: context.Background()},
+		{name: "marked", ctx: WithBlob(context.Background()), want: true},
+	}
+
+	for _, test := range tests {
+		if got := Blob(test.ctx); got != test.want {
+			t.Errorf("TestBlob(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
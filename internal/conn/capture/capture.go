@@ -0,0 +1,257 @@
+// Package capture provides an azcore policy that keeps the last failed requests, with their responses, in
+// memory. This gives concrete request and response evidence to attach to ARN support tickets.
+package capture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const (
+	// DefaultSize is the default Options.Size.
+	DefaultSize = 20
+	// DefaultMaxBody is the default Options.MaxBody.
+	DefaultMaxBody = 64 * 1024
+)
+
+// Redacted replaces the values of sensitive headers and query parameters in a Failure.
+const Redacted = "REDACTED"
+
+// Options configures the failures that are kept.
+type Options struct {
+	// Size is the number of failures kept. Once full, the oldest failure is dropped for each new one.
+	// Defaults to DefaultSize.
+	Size int `json:"size,omitzero" yaml:"size,omitempty"`
+	// MaxBody is the number of bytes of each request and response body that are kept. Longer bodies are
+	// truncated. Defaults to DefaultMaxBody. If negative, bodies are not kept.
+	MaxBody int `json:"maxBody,omitzero" yaml:"maxBody,omitempty"`
+	// Compress gzips the bodies while they are kept, which uses less memory for large notifications at the
+	// cost of CPU on each failure. Failures are returned uncompressed.
+	Compress bool `json:"compress,omitzero" yaml:"compress,omitempty"`
+}
+
+// Validate validates the options.
+func (o Options) Validate() error {
+	if o.Size < 0 {
+		return fmt.Errorf("Size cannot be negative")
+	}
+	return nil
+}
+
+// Failure is a failed request and its response. Sensitive headers, such as Authorization, and signatures
+// in the URL have their values replaced with Redacted.
+type Failure struct {
+	// Time is when the request was sent.
+	Time time.Time
+	// Duration is how long the request took to fail.
+	Duration time.Duration
+	// Method is the HTTP method of the request.
+	Method string
+	// URL is the URL of the request.
+	URL string
+	// RequestHeader are the headers of the request.
+	RequestHeader http.Header
+	// RequestBody is the body of the request, before it was compressed.
+	RequestBody []byte
+	// RequestTruncated is true if RequestBody was longer than Options.MaxBody.
+	RequestTruncated bool
+	// StatusCode is the status code of the response, 0 if there was no response.
+	StatusCode int
+	// ResponseHeader are the headers of the response, nil if there was no response.
+	ResponseHeader http.Header
+	// ResponseBody is the body of the response.
+	ResponseBody []byte
+	// ResponseTruncated is true if ResponseBody was longer than Options.MaxBody.
+	ResponseTruncated bool
+	// Err is the error of a request that got no response, such as a reset connection.
+	Err string
+}
+
+// Recorder is a policy.Policy that keeps the last failed requests. A request fails if it gets an error or
+// a response that is not a 2xx. It should be the last of the policy.ClientOptions.PerRetryPolicies, so that
+// it sees each attempt as it is sent. Thread-safe.
+type Recorder struct {
+	opts Options
+
+	mu   sync.Mutex
+	ring []Failure
+	// next is the index in ring of the next failure, full is true once ring has wrapped.
+	next int
+	full bool
+}
+
+// New creates a new Recorder.
+func New(o Options) (*Recorder, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	if o.MaxBody == 0 {
+		o.MaxBody = DefaultMaxBody
+	}
+	return &Recorder{opts: o, ring: make([]Failure, o.Size)}, nil
+}
+
+// Do implements policy.Policy.
+func (r *Recorder) Do(req *policy.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := req.Next()
+	if err == nil && resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	f := Failure{
+		Time:          start,
+		Duration:      time.Since(start),
+		Method:        req.Raw().Method,
+		URL:           sanitizeURL(req.Raw().URL),
+		RequestHeader: sanitizeHeader(req.Raw().Header),
+	}
+	if body := req.Body(); body != nil && r.opts.MaxBody > 0 {
+		if _, serr := body.Seek(0, io.SeekStart); serr == nil {
+			f.RequestBody, f.RequestTruncated = r.read(body)
+			body.Seek(0, io.SeekStart)
+		}
+	}
+	if err != nil {
+		f.Err = err.Error()
+		r.add(f)
+		return resp, err
+	}
+
+	f.StatusCode = resp.StatusCode
+	f.ResponseHeader = sanitizeHeader(resp.Header)
+	if resp.Body != nil && r.opts.MaxBody > 0 {
+		// The policies above read the whole body, such as to build an *azcore.ResponseError, so what is
+		// read here is put back in front of the rest.
+		var buf bytes.Buffer
+		f.ResponseBody, f.ResponseTruncated = r.read(io.TeeReader(resp.Body, &buf))
+		resp.Body = readCloser{io.MultiReader(&buf, resp.Body), resp.Body}
+	}
+	r.add(f)
+	return resp, nil
+}
+
+// read returns up to Options.MaxBody bytes from rd and true if there was more.
+func (r *Recorder) read(rd io.Reader) ([]byte, bool) {
+	b, _ := io.ReadAll(io.LimitReader(rd, int64(r.opts.MaxBody)+1))
+	if len(b) > r.opts.MaxBody {
+		return b[:r.opts.MaxBody], true
+	}
+	return b, false
+}
+
+// add adds f to the ring, compressing its bodies if Options.Compress is set.
+func (r *Recorder) add(f Failure) {
+	if r.opts.Compress {
+		f.RequestBody = compress(f.RequestBody)
+		f.ResponseBody = compress(f.ResponseBody)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring[r.next] = f
+	r.next++
+	if r.next == len(r.ring) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Failures returns the failures that are kept, from oldest to newest.
+func (r *Recorder) Failures() []Failure {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	var out []Failure
+	if r.full {
+		out = append(out, r.ring[r.next:]...)
+	}
+	out = append(out, r.ring[:r.next]...)
+	r.mu.Unlock()
+
+	if r.opts.Compress {
+		for i := range out {
+			out[i].RequestBody = decompress(out[i].RequestBody)
+			out[i].ResponseBody = decompress(out[i].ResponseBody)
+		}
+	}
+	return out
+}
+
+// sensitive are parts of header names whose values are redacted.
+var sensitive = []string{"authorization", "cookie", "token", "secret", "signature"}
+
+// sanitizeHeader returns a copy of h with the values of sensitive headers redacted.
+func sanitizeHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for k := range out {
+		lk := strings.ToLower(k)
+		for _, s := range sensitive {
+			if strings.Contains(lk, s) {
+				out[k] = []string{Redacted}
+				break
+			}
+		}
+	}
+	return out
+}
+
+// sanitizeURL returns u as a string with the signature of a SAS redacted.
+func sanitizeURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	q := u.Query()
+	if !q.Has("sig") {
+		return u.String()
+	}
+	q.Set("sig", Redacted)
+	c := *u
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+// compress returns b gzipped, or nil if b is empty.
+func compress(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+// decompress returns the gzipped b uncompressed, or nil if b is empty.
+func decompress(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	rd, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	out, _ := io.ReadAll(rd)
+	return out
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,198 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+// fakeTransport returns a response with code and body, or err if set.
+type fakeTransport struct {
+	code int
+	body string
+	err  error
+}
+
+func (f fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+	}
+	h := http.Header{}
+	h.Set("Set-Cookie", "session=secret")
+	h.Set("x-ms-request-id", "id")
+	return &http.Response{StatusCode: f.code, Header: h, Body: io.NopCloser(strings.NewReader(f.body)), Request: req}, nil
+}
+
+func send(t *testing.T, r *Recorder, ft fakeTransport, body string) (*http.Response, error) {
+	t.Helper()
+
+	pl := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        ft,
+		PerRetryPolicies: []policy.Policy{r},
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+	})
+	req, err := runtime.NewRequest(context.Background(), http.MethodPost, "https://localhost/arnnotify?sig=abc&v=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Raw().Header.Set("Authorization", "Bearer token")
+	if err := req.SetBody(streaming.NopCloser(strings.NewReader(body)), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	return pl.Do(req)
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"key": "value"}`
+	connErr := errors.New("connection reset")
+
+	tests := []struct {
+		name          string
+		opts          Options
+		transport     fakeTransport
+		wantFailures  int
+		wantReqBody   string
+		wantRespBody  string
+		wantTruncated bool
+		wantErr       string
+	}{
+		{name: "Success is not kept", transport: fakeTransport{code: http.StatusOK}},
+		{
+			name:         "Error response",
+			transport:    fakeTransport{code: http.StatusBadRequest, body: `{"error": "bad"}`},
+			wantFailures: 1,
+			wantReqBody:  body,
+			wantRespBody: `{"error": "bad"}`,
+		},
+		{
+			name:         "Error response compressed",
+			opts:         Options{Compress: true},
+			transport:    fakeTransport{code: http.StatusInternalServerError, body: `{"error": "bad"}`},
+			wantFailures: 1,
+			wantReqBody:  body,
+			wantRespBody: `{"error": "bad"}`,
+		},
+		{
+			name:          "Bodies truncated",
+			opts:          Options{MaxBody: 4},
+			transport:     fakeTransport{code: http.StatusBadRequest, body: `{"error": "bad"}`},
+			wantFailures:  1,
+			wantReqBody:   body[:4],
+			wantRespBody:  `{"er`,
+			wantTruncated: true,
+		},
+		{
+			name:         "Bodies not kept",
+			opts:         Options{MaxBody: -1},
+			transport:    fakeTransport{code: http.StatusBadRequest, body: `{"error": "bad"}`},
+			wantFailures: 1,
+		},
+		{
+			name:         "Transport error",
+			transport:    fakeTransport{err: connErr},
+			wantFailures: 1,
+			wantReqBody:  body,
+			wantErr:      connErr.Error(),
+		},
+	}
+
+	for _, test := range tests {
+		r, err := New(test.opts)
+		if err != nil {
+			t.Fatalf("TestRecorder(%s): New(): got err == %s, want err == nil", test.name, err)
+		}
+		resp, err := send(t, r, test.transport, body)
+		if resp != nil {
+			// The response body must still be readable in full by the caller.
+			b, _ := io.ReadAll(resp.Body)
+			if string(b) != test.transport.body {
+				t.Errorf("TestRecorder(%s): caller read body %q, want %q", test.name, b, test.transport.body)
+			}
+		}
+		if (err != nil) != (test.wantErr != "") {
+			t.Errorf("TestRecorder(%s): got err == %v, want err == %q", test.name, err, test.wantErr)
+		}
+
+		got := r.Failures()
+		if len(got) != test.wantFailures {
+			t.Errorf("TestRecorder(%s): got %d failures, want %d", test.name, len(got), test.wantFailures)
+			continue
+		}
+		if len(got) == 0 {
+			continue
+		}
+		f := got[0]
+		if string(f.RequestBody) != test.wantReqBody {
+			t.Errorf("TestRecorder(%s): got RequestBody %q, want %q", test.name, f.RequestBody, test.wantReqBody)
+		}
+		if string(f.ResponseBody) != test.wantRespBody {
+			t.Errorf("TestRecorder(%s): got ResponseBody %q, want %q", test.name, f.ResponseBody, test.wantRespBody)
+		}
+		if f.RequestTruncated != test.wantTruncated || f.ResponseTruncated != test.wantTruncated {
+			t.Errorf("TestRecorder(%s): got truncated %v/%v, want %v", test.name, f.RequestTruncated, f.ResponseTruncated, test.wantTruncated)
+		}
+		if f.Err != test.wantErr {
+			t.Errorf("TestRecorder(%s): got Err %q, want %q", test.name, f.Err, test.wantErr)
+		}
+		if f.StatusCode != test.transport.code {
+			t.Errorf("TestRecorder(%s): got StatusCode %d, want %d", test.name, f.StatusCode, test.transport.code)
+		}
+		if got := f.RequestHeader.Get("Authorization"); got != Redacted {
+			t.Errorf("TestRecorder(%s): got Authorization header %q, want %q", test.name, got, Redacted)
+		}
+		if strings.Contains(f.URL, "abc") || !strings.Contains(f.URL, "v=1") {
+			t.Errorf("TestRecorder(%s): got URL %q, want the signature redacted and the rest kept", test.name, f.URL)
+		}
+		if f.ResponseHeader != nil {
+			if got := f.ResponseHeader.Get("Set-Cookie"); got != Redacted {
+				t.Errorf("TestRecorder(%s): got Set-Cookie header %q, want %q", test.name, got, Redacted)
+			}
+			if got := f.ResponseHeader.Get("x-ms-request-id"); got != "id" {
+				t.Errorf("TestRecorder(%s): got x-ms-request-id header %q, want %q", test.name, got, "id")
+			}
+		}
+	}
+}
+
+func TestRecorderRing(t *testing.T) {
+	t.Parallel()
+
+	r, err := New(Options{Size: 3})
+	if err != nil {
+		t.Fatalf("TestRecorderRing: New(): got err == %s, want err == nil", err)
+	}
+	for i := range 5 {
+		send(t, r, fakeTransport{code: http.StatusBadRequest, body: fmt.Sprint(i)}, "body")
+	}
+
+	got := r.Failures()
+	if len(got) != 3 {
+		t.Fatalf("TestRecorderRing: got %d failures, want 3", len(got))
+	}
+	for i, f := range got {
+		if want := fmt.Sprint(i + 2); string(f.ResponseBody) != want {
+			t.Errorf("TestRecorderRing: failure %d: got ResponseBody %q, want %q", i, f.ResponseBody, want)
+		}
+	}
+
+	var nilRecorder *Recorder
+	if nilRecorder.Failures() != nil {
+		t.Errorf("TestRecorderRing: nil Recorder: got failures, want nil")
+	}
+	if _, err := New(Options{Size: -1}); err == nil {
+		t.Errorf("TestRecorderRing: New() with negative Size: got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Package classify enforces a Policy on the classified fields of the resources in a notification, so that
+data classified as restricted to a data boundary, such as end user identifiable information that must stay
+in the EU, is not sent in a notification for another boundary.
+
+Fields are classified with the types.ClassTag struct tag or types.RegisterClassifications(). The conn
+package adds the Policy to the context of each notification with WithPolicy(). The model's SendEvent()
+applies it with Policy.Apply() to the properties of each resource before they are serialized.
+*/
+package classify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+// Action is what is done with a classified field in a notification for a boundary it is not allowed in.
+type Action uint8
+
+const (
+	// Block fails the notification.
+	Block Action = 0
+	// Redact removes the field from the notification.
+	Redact Action = 1
+)
+
+// String implements fmt.Stringer.
+func (a Action) String() string {
+	switch a {
+	case Block:
+		return "block"
+	case Redact:
+		return "redact"
+	}
+	return fmt.Sprintf("Action(%d)", a)
+}
+
+// Rule restricts the data boundaries a classification can be sent in.
+type Rule struct {
+	// Classification is the classification the rule applies to.
+	Classification types.Classification
+	// Allowed are the data boundaries that fields with the classification can be sent in. A notification
+	// with no data boundary, types.DBUnknown, is only allowed if it is listed.
+	Allowed []types.DataBoundary
+	// Action is what is done with a field in a notification for another boundary. Defaults to Block.
+	Action Action
+}
+
+// Policy is the rules enforced on the classified fields of each notification.
+type Policy struct {
+	// Rules are the rules, at most one for each classification. Classifications without a rule can be sent
+	// in any boundary.
+	Rules []Rule
+}
+
+// Validate validates the Policy.
+func (p Policy) Validate() error {
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("Rules cannot be empty")
+	}
+	seen := map[types.Classification]bool{}
+	for i, r := range p.Rules {
+		switch {
+		case r.Classification == "":
+			return fmt.Errorf("Rules[%d].Classification cannot be empty", i)
+		case seen[r.Classification]:
+			return fmt.Errorf("Rules[%d]: there is more than one rule for classification %q", i, r.Classification)
+		case r.Action > Redact:
+			return fmt.Errorf("Rules[%d].Action(%d) is invalid", i, r.Action)
+		}
+		seen[r.Classification] = true
+	}
+	return nil
+}
+
+// rule returns the rule for c, if there is one.
+func (p Policy) rule(c types.Classification) (Rule, bool) {
+	for _, r := range p.Rules {
+		if r.Classification == c {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Violation is the error of a notification that has a classified field that cannot be sent in its
+// data boundary with the Block action.
+type Violation struct {
+	// Field is the field.
+	Field types.ClassifiedField
+	// Boundary is the data boundary of the notification.
+	Boundary types.DataBoundary
+}
+
+// Error implements error.
+func (v *Violation) Error() string {
+	return fmt.Sprintf("field %s cannot be sent in data boundary %s", v.Field, v.Boundary)
+}
+
+// Apply enforces the policy on props, an ArmResource.Properties value, for a notification in boundary b. If
+// fields were redacted, it returns props serialized, with the fields removed, as a jsontext.Value and true.
+// Otherwise it returns props as is and false. A *Violation is returned for a field that blocks the
+// notification. Only fields that are present are enforced, a null or missing field is not a violation.
+func (p Policy) Apply(props any, b types.DataBoundary) (any, bool, error) {
+	var redact []types.ClassifiedField
+	var block []types.ClassifiedField
+	for _, f := range types.ClassifiedFields(props) {
+		r, ok := p.rule(f.Classification)
+		if !ok || slices.Contains(r.Allowed, b) {
+			continue
+		}
+		if r.Action == Redact {
+			redact = append(redact, f)
+		} else {
+			block = append(block, f)
+		}
+	}
+	if len(redact) == 0 && len(block) == 0 {
+		return props, false, nil
+	}
+
+	raw, err := types.MarshalProperties(props)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, f := range block {
+		if present(raw, f.Path) {
+			return nil, false, &Violation{Field: f, Boundary: b}
+		}
+	}
+	if len(redact) == 0 {
+		return props, false, nil
+	}
+	v := jsontext.Value(raw)
+	for _, f := range redact {
+		if v, err = remove(v, f.Path); err != nil {
+			return nil, false, err
+		}
+	}
+	return v, true, nil
+}
+
+// present returns true if v has a value that is not null at path. The values are kept as JSON, so that
+// numbers are not changed by decoding them.
+func present(v jsontext.Value, path []string) bool {
+	if len(path) == 0 {
+		return v.Kind() != 'n'
+	}
+	switch v.Kind() {
+	case '{':
+		var m map[string]jsontext.Value
+		if json.Unmarshal(v, &m) != nil {
+			return false
+		}
+		if path[0] != "*" {
+			e, ok := m[path[0]]
+			return ok && present(e, path[1:])
+		}
+		for _, e := range m {
+			if present(e, path[1:]) {
+				return true
+			}
+		}
+	case '[':
+		var a []jsontext.Value
+		if path[0] != "*" || json.Unmarshal(v, &a) != nil {
+			return false
+		}
+		for _, e := range a {
+			if present(e, path[1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remove returns v with the values at path removed. Objects that are changed are written with their
+// members sorted by name.
+func remove(v jsontext.Value, path []string) (jsontext.Value, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	var err error
+	switch v.Kind() {
+	case '{':
+		var m map[string]jsontext.Value
+		if err := json.Unmarshal(v, &m); err != nil {
+			return nil, err
+		}
+		for k, e := range m {
+			if path[0] != "*" && path[0] != k {
+				continue
+			}
+			if len(path) == 1 {
+				delete(m, k)
+				continue
+			}
+			if m[k], err = remove(e, path[1:]); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(m, json.Deterministic(true))
+	case '[':
+		if path[0] != "*" {
+			return v, nil
+		}
+		var a []jsontext.Value
+		if err := json.Unmarshal(v, &a); err != nil {
+			return nil, err
+		}
+		if len(path) == 1 {
+			return jsontext.Value("[]"), nil
+		}
+		for i, e := range a {
+			if a[i], err = remove(e, path[1:]); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(a)
+	}
+	return v, nil
+}
+
+type ctxKey struct{}
+
+// WithPolicy returns a context that holds p.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromCtx returns the Policy in ctx. ok is false if there is none.
+func FromCtx(ctx context.Context) (p Policy, ok bool) {
+	if ctx == nil {
+		return Policy{}, false
+	}
+	p, ok = ctx.Value(ctxKey{}).(Policy)
+	return p, ok
+}
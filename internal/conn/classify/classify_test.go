@@ -0,0 +1,120 @@
+package classify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+type contact struct {
+	Email string `json:"email,omitzero" arnclass:"euii"`
+	Team  string `json:"team"`
+}
+
+type props struct {
+	Owner    *contact  `json:"owner"`
+	Contacts []contact `json:"contacts"`
+	Note     string    `json:"note,omitzero" arnclass:"customer"`
+	Count    int64     `json:"count"`
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	full := props{
+		Owner:    &contact{Email: "a@example.com", Team: "x"},
+		Contacts: []contact{{Email: "b@example.com", Team: "y"}, {Team: "z"}},
+		Note:     "note",
+		Count:    9007199254740993, // Not exact as a float64.
+	}
+
+	euOnly := Rule{Classification: "euii", Allowed: []types.DataBoundary{types.DBEU}}
+	redactEUOnly := euOnly
+	redactEUOnly.Action = Redact
+
+	tests := []struct {
+		name         string
+		policy       Policy
+		props        any
+		boundary     types.DataBoundary
+		wantRedacted string
+		wantErr      bool
+	}{
+		{name: "Allowed boundary", policy: Policy{Rules: []Rule{euOnly}}, props: full, boundary: types.DBEU},
+		{name: "No rule for the classification", policy: Policy{Rules: []Rule{{Classification: "other"}}}, props: full, boundary: types.DBGlobal},
+		{name: "Classified fields not present", policy: Policy{Rules: []Rule{euOnly}}, props: props{Owner: &contact{Team: "x"}}, boundary: types.DBGlobal},
+		{name: "Unclassified properties", policy: Policy{Rules: []Rule{euOnly}}, props: map[string]any{"email": "a@example.com"}, boundary: types.DBGlobal},
+		{name: "Error: blocked", policy: Policy{Rules: []Rule{euOnly}}, props: full, boundary: types.DBGlobal, wantErr: true},
+		{name: "Error: blocked without a boundary", policy: Policy{Rules: []Rule{euOnly}}, props: full, boundary: types.DBUnknown, wantErr: true},
+		{
+			name:         "Redacted",
+			policy:       Policy{Rules: []Rule{redactEUOnly, {Classification: "customer", Action: Redact}}},
+			props:        &full,
+			boundary:     types.DBGlobal,
+			wantRedacted: `{"contacts":[{"team":"y"},{"team":"z"}],"count":9007199254740993,"owner":{"team":"x"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		got, redacted, err := test.policy.Apply(test.props, test.boundary)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestApply(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestApply(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			var v *Violation
+			if !errors.As(err, &v) || v.Boundary != test.boundary {
+				t.Errorf("TestApply(%s): got err == %v, want a *Violation for the boundary", test.name, err)
+			}
+			continue
+		}
+
+		if redacted != (test.wantRedacted != "") {
+			t.Errorf("TestApply(%s): got redacted == %v, want %v", test.name, redacted, test.wantRedacted != "")
+			continue
+		}
+		if !redacted {
+			continue
+		}
+		v, ok := got.(jsontext.Value)
+		if !ok {
+			t.Errorf("TestApply(%s): got %T, want jsontext.Value", test.name, got)
+			continue
+		}
+		if string(v) != test.wantRedacted {
+			t.Errorf("TestApply(%s): got %s, want %s", test.name, v, test.wantRedacted)
+		}
+	}
+	if full.Owner.Email == "" || full.Contacts[0].Email == "" {
+		t.Errorf("TestApply: the caller's properties were changed")
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "Valid", policy: Policy{Rules: []Rule{{Classification: "euii", Allowed: []types.DataBoundary{types.DBEU}}, {Classification: "customer", Action: Redact}}}},
+		{name: "Error: no rules", wantErr: true},
+		{name: "Error: empty classification", policy: Policy{Rules: []Rule{{}}}, wantErr: true},
+		{name: "Error: two rules for a classification", policy: Policy{Rules: []Rule{{Classification: "a"}, {Classification: "a"}}}, wantErr: true},
+		{name: "Error: bad action", policy: Policy{Rules: []Rule{{Classification: "a", Action: 9}}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.policy.Validate()
+		if (err != nil) != test.wantErr {
+			t.Errorf("TestPolicyValidate(%s): got err == %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+}
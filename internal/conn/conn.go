@@ -2,33 +2,153 @@
 package conn
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/pprof"
+	"runtime/trace"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/Azure/arn-sdk/internal/build"
+	"github.com/Azure/arn-sdk/internal/conn/breaker"
+	"github.com/Azure/arn-sdk/internal/conn/canary"
+	"github.com/Azure/arn-sdk/internal/conn/classify"
+	"github.com/Azure/arn-sdk/internal/conn/deadletter"
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/internal/conn/eventinfo"
 	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/internal/conn/itemsize"
+	"github.com/Azure/arn-sdk/internal/conn/leader"
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/internal/conn/preview"
+	"github.com/Azure/arn-sdk/internal/conn/proplimits"
+	"github.com/Azure/arn-sdk/internal/conn/provenance"
+	"github.com/Azure/arn-sdk/internal/conn/skew"
+	"github.com/Azure/arn-sdk/internal/conn/state"
+	"github.com/Azure/arn-sdk/internal/conn/stats"
 	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/internal/conn/timing"
+	"github.com/Azure/arn-sdk/internal/conn/tracing"
+	"github.com/Azure/arn-sdk/internal/conn/watchdog"
 	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/arn-sdk/models/version"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
-// PromisePool is a pool of promises to use for notifications.
-var PromisePool = sync.Pool{
+// promisePool is a pool of promises to use for notifications. Use NewPromise() to get a promise from the pool
+// and RecyclePromise() to return it.
+var promisePool = sync.Pool{
 	New: func() any {
 		return make(chan error, 1)
 	},
 }
 
+// NewPromise returns an empty promise from the pool. Any stale result left in a recycled promise is
+// discarded, so that it cannot be mistaken for the result of the new notification.
+func NewPromise() chan error {
+	p := promisePool.Get().(chan error)
+	select {
+	case <-p:
+	default:
+	}
+	return p
+}
+
+// RecyclePromise returns p to the pool. This must only be called by the code that got p from NewPromise(),
+// once it has received the result from p or knows the result will never be sent, and only if p was never
+// given to the user. Promises handed to the user are left to the garbage collector, as there is no way to
+// know when the user stops reading them.
+func RecyclePromise(p chan error) {
+	if p == nil {
+		return
+	}
+	select {
+	case <-p:
+	default:
+	}
+	promisePool.Put(p)
+}
+
+// Compile time checks that the clients implement the interfaces a model's SendEvent() uses.
+var (
+	_ models.EventSender = (*http.Client)(nil)
+	_ models.StreamStore = (*storage.Client)(nil)
+)
+
 // Reset provides a REST connection to the ARN service.
 type Service struct {
 	endpoint   string
-	http       *http.Client
-	store      *storage.Client
+	http       models.EventSender
+	store      models.PayloadStore
 	clientErrs chan error
 	in         chan models.Notifications
 
+	// stopCtx is cancelled with models.ErrShutdown by Abort(), which fails queued notifications and cuts
+	// short the one being sent. done is closed when sender() returns. closeOnce guards closing in.
+	stopCtx   context.Context
+	stop      context.CancelCauseFunc
+	done      chan struct{}
+	closeOnce sync.Once
+
 	id atomic.Uint64
 
+	budget   time.Duration
+	maxItems int
+	stats    *stats.Collector
+
+	// blobCanary is how often a notification is sent through blob storage as a canary, 0 for never.
+	// untilCanary counts down the notifications until the next canary and is guarded by canaryMu, as
+	// notifications are sent from several goroutines with WithConcurrency().
+	blobCanary  int
+	canaryMu    sync.Mutex
+	untilCanary int
+
+	// pool sends notifications concurrently, nil if they are sent one at a time by sender().
+	pool *pool
+
+	skew *skew.Bounds
+
+	// provenance is stamped on each notification, nil if notifications are not stamped.
+	provenance *provenance.Provenance
+	// classify is enforced on the classified fields of each notification, nil if it is not.
+	classify *classify.Policy
+	// propLimits are checked against the properties of each resource, nil if they are not checked.
+	propLimits *types.PropertyLimits
+	// preview are the receiver preview versions events may use, nil if none are allowed.
+	preview *envelope.PreviewVersions
+	// maxResourceBytes is the maximum size of each resource as JSON, 0 if it is not checked.
+	maxResourceBytes int
+	// tracer records a span for each notification, nil if they are not traced.
+	tracer oteltrace.Tracer
+	// deadLetters receives each notification that fails, nil if failed notifications are not dead lettered.
+	deadLetters chan models.Notifications
+	// encrypt wraps the content key of each blob payload, nil if blob payloads are not encrypted.
+	encrypt encrypt.KeyWrapper
+
+	// leaderOpts are set by WithLeader(). leader is created from them by New(), nil if every notification is
+	// sent. queue holds the notifications of a follower with the leader.Queue policy, only used by sender().
+	leaderOpts *leader.Options
+	leader     *leader.Elector
+	queue      []models.Notifications
+
+	// state records the payload hashes of delivered notifications, nil if they are not recorded.
+	state *state.Cache
+	// slow keeps the timelines of the slowest sends, nil if they are not kept.
+	slow *timing.Sampler
+	// resend sends a notification again after a transient error, nil if it is not.
+	resend *resender
+	// breaker fails notifications fast while the receiver is failing, nil if it does not.
+	breaker *breaker.Breaker
+	// journal logs a record for each delivered event, nil if they are not logged.
+	journal *slog.Logger
+
 	log *slog.Logger
 }
 
@@ -42,24 +162,248 @@ func WithLogger(log *slog.Logger) Option {
 	}
 }
 
-// New creates a new connection to the ARN service.
-func New(httpClient *http.Client, store *storage.Client, clientErrs chan error, options ...Option) (*Service, error) {
+// WithSendBudget sets the total time that can be spent sending a notification, which includes the blob
+// upload, the HTTP send and all of their retries. By default there is no limit other than the
+// notification's context.
+func WithSendBudget(d time.Duration) Option {
+	return func(c *Service) error {
+		if d < 0 {
+			return fmt.Errorf("send budget cannot be negative")
+		}
+		c.budget = d
+		return nil
+	}
+}
+
+// WithMaxItems sets the maximum number of items in a notification. Defaults to maxvals.NotificationItems.
+func WithMaxItems(n int) Option {
+	return func(c *Service) error {
+		if n <= 0 {
+			return fmt.Errorf("max items must be greater than 0")
+		}
+		c.maxItems = n
+		return nil
+	}
+}
+
+// WithBlobCanary sends every nth notification through blob storage, even if it is small enough to send
+// inline, to verify that the blob path works. If the upload fails, the notification is sent inline instead.
+// This has no effect if the Service has no blob storage client.
+func WithBlobCanary(n int) Option {
+	return func(c *Service) error {
+		if n <= 0 {
+			return fmt.Errorf("blob canary interval must be greater than 0")
+		}
+		c.blobCanary = n
+		c.untilCanary = n
+		return nil
+	}
+}
+
+// WithTimeSkew checks the times of each notification against b. See skew.Bounds.
+func WithTimeSkew(b skew.Bounds) Option {
+	return func(c *Service) error {
+		if err := b.Validate(); err != nil {
+			return fmt.Errorf("invalid time skew bounds: %w", err)
+		}
+		c.skew = &b
+		return nil
+	}
+}
+
+// WithStateCache records the payload hash of each resource in a delivered notification in c.
+func WithStateCache(c *state.Cache) Option {
+	return func(s *Service) error {
+		if c == nil {
+			return fmt.Errorf("state cache cannot be nil")
+		}
+		s.state = c
+		return nil
+	}
+}
+
+// WithProvenance stamps the AdditionalBatchProperties of each notification with p, see provenance.Provenance.
+func WithProvenance(p provenance.Provenance) Option {
+	return func(s *Service) error {
+		if p.IsZero() {
+			return fmt.Errorf("provenance cannot be empty")
+		}
+		s.provenance = &p
+		return nil
+	}
+}
+
+// WithEncryption encrypts each blob payload with a content key wrapped by w, see the encrypt package.
+func WithEncryption(w encrypt.KeyWrapper) Option {
+	return func(s *Service) error {
+		if w == nil {
+			return fmt.Errorf("KeyWrapper cannot be nil")
+		}
+		s.encrypt = w
+		return nil
+	}
+}
+
+// WithClassification enforces p on the classified fields of each notification, see classify.Policy.
+func WithClassification(p classify.Policy) Option {
+	return func(s *Service) error {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid classification policy: %w", err)
+		}
+		s.classify = &p
+		return nil
+	}
+}
+
+// WithPropertyLimits checks the properties of each resource in a notification against l, see
+// types.CheckProperties().
+func WithPropertyLimits(l types.PropertyLimits) Option {
+	return func(s *Service) error {
+		l = l.Defaults()
+		if err := l.Validate(); err != nil {
+			return fmt.Errorf("invalid property limits: %w", err)
+		}
+		s.propLimits = &l
+		return nil
+	}
+}
+
+// WithPreviewVersions allows the events of this Service to use the versions in p, see envelope.PreviewVersions.
+func WithPreviewVersions(p envelope.PreviewVersions) Option {
+	return func(s *Service) error {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid preview versions: %w", err)
+		}
+		p = envelope.PreviewVersions{
+			MetadataVersions: slices.Clone(p.MetadataVersions),
+			DataVersions:     slices.Clone(p.DataVersions),
+		}
+		s.preview = &p
+		return nil
+	}
+}
+
+// WithMaxResourceBytes fails notifications with a resource larger than n bytes as JSON, see itemsize. n of 0
+// is itemsize.DefaultMax.
+func WithMaxResourceBytes(n int) Option {
+	return func(s *Service) error {
+		if n < 0 {
+			return fmt.Errorf("max resource bytes cannot be negative")
+		}
+		if n == 0 {
+			n = itemsize.DefaultMax
+		}
+		s.maxResourceBytes = n
+		return nil
+	}
+}
+
+// WithTracerProvider records an OpenTelemetry span for each notification with a tracer from tp, see tracing.
+func WithTracerProvider(tp oteltrace.TracerProvider) Option {
+	return func(s *Service) error {
+		if tp == nil {
+			return fmt.Errorf("tracer provider cannot be nil")
+		}
+		s.tracer = tp.Tracer(tracing.Name, oteltrace.WithInstrumentationVersion(version.SDK.String()))
+		return nil
+	}
+}
+
+// WithDeadLetter sends each notification that fails in the Service on ch, with the error attached to its
+// context, see deadletter. A notification is sent on ch before its promise receives the error. If ch is
+// full, the notification is logged and dropped, as waiting would hold up the notifications behind it.
+func WithDeadLetter(ch chan models.Notifications) Option {
+	return func(s *Service) error {
+		if ch == nil {
+			return fmt.Errorf("dead letter channel cannot be nil")
+		}
+		s.deadLetters = ch
+		return nil
+	}
+}
+
+// WithBreaker fails notifications fast with models.ErrCircuitOpen while the ARN receiver is failing, see
+// breaker.Breaker.
+func WithBreaker(b *breaker.Breaker) Option {
+	return func(s *Service) error {
+		s.breaker = b
+		return nil
+	}
+}
+
+// WithLeader only sends notifications while this instance holds the lock in o, see leader.Options. The
+// lock is released once the Service has handled its last notification.
+func WithLeader(o leader.Options) Option {
+	return func(s *Service) error {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid leader election options: %w", err)
+		}
+		s.leaderOpts = &o
+		return nil
+	}
+}
+
+// WithSlowSends offers the timeline of each send to sp, which keeps the slowest, see timing.Sampler.
+func WithSlowSends(sp *timing.Sampler) Option {
+	return func(s *Service) error {
+		if sp == nil {
+			return fmt.Errorf("Sampler cannot be nil")
+		}
+		s.slow = sp
+		return nil
+	}
+}
+
+// WithJournal logs a record at slog.LevelInfo to log for each event the receiver accepts, see journal().
+func WithJournal(log *slog.Logger) Option {
+	return func(s *Service) error {
+		if log == nil {
+			return fmt.Errorf("journal Logger cannot be nil")
+		}
+		s.journal = log
+		return nil
+	}
+}
+
+// WithSLO tracks the success rate of notifications against the SLO in o, see stats.SLOOptions.
+func WithSLO(o stats.SLOOptions) Option {
+	return func(s *Service) error {
+		return s.stats.SetSLO(o)
+	}
+}
+
+// CheckItems returns an error wrapping models.ErrBatchSize if count is more than limit. If limit is 0,
+// maxvals.NotificationItems is used. This is the single place the item limit is enforced, so that
+// every layer agrees on it.
+func CheckItems(count, limit int) error {
+	if limit <= 0 {
+		limit = maxvals.NotificationItems
+	}
+	if count > limit {
+		return fmt.Errorf("%w: %d items is more than the limit of %d", models.ErrBatchSize, count, limit)
+	}
+	return nil
+}
+
+// New creates a new connection to the ARN service. httpClient is usually an *http.Client and store an
+// *storage.Client. store may be nil, in which case the connection is inline-only and any notification too
+// large to inline will fail with models.ErrNoBlobClient. A nil pointer must be passed as a nil interface.
+func New(httpClient models.EventSender, store models.PayloadStore, clientErrs chan error, options ...Option) (*Service, error) {
 	if httpClient == nil {
 		return nil, fmt.Errorf("httpClient is required")
 	}
-	if store == nil {
-		return nil, fmt.Errorf("store is required")
-	}
 	if clientErrs == nil {
 		return nil, fmt.Errorf("clientErrs is required")
 	}
 
 	conn := &Service{
-		in: make(chan models.Notifications, 1),
+		in:   make(chan models.Notifications, 1),
+		done: make(chan struct{}),
 
 		http:       httpClient,
 		store:      store,
 		clientErrs: clientErrs,
+		stats:      stats.New(),
 	}
 
 	for _, o := range options {
@@ -67,47 +411,339 @@ func New(httpClient *http.Client, store *storage.Client, clientErrs chan error,
 			return nil, err
 		}
 	}
+	if conn.leaderOpts != nil {
+		var err error
+		conn.leader, err = leader.New(*conn.leaderOpts, conn.logger())
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn.stopCtx, conn.stop = context.WithCancelCause(context.Background())
 
+	if conn.pool != nil {
+		conn.pool.start(conn.send)
+	}
 	go conn.sender()
 
 	return conn, nil
 }
 
-// Close closes the connection to the ARN service.
+// Close closes the connection to the ARN service. Notifications that are queued are still sent, Close()
+// does not wait for them. Use Drain() to wait.
 func (r *Service) Close() error {
-	close(r.in)
+	r.closeOnce.Do(func() { close(r.in) })
 	return nil
 }
 
+// Drain closes the connection and waits for the queued notifications to be sent. If ctx ends first, it
+// calls Abort() and waits for the remaining notifications to fail. It returns true if every notification
+// was handled before ctx ended. Nothing may be sent after Drain() is called.
+func (r *Service) Drain(ctx context.Context) bool {
+	r.Close()
+	select {
+	case <-r.done:
+		return true
+	case <-ctx.Done():
+	}
+	r.Abort()
+	<-r.done
+	return false
+}
+
+// Abort fails the queued notifications, and any blocked in Send(), with models.ErrShutdown and cuts short the
+// notification being sent. The Service cannot send after this is called.
+func (r *Service) Abort() {
+	r.stop(models.ErrShutdown)
+}
+
 // Send sends a notification to the ARN service. This will block if the internal channel is full.
-// notify.DataCount() must indicate no more than 1000 items. Not thread safe.
+// notify.DataCount() must not be more than the limit set with WithMaxItems(). Not thread safe.
 func (s *Service) Send(notify models.Notifications) {
-	if notify.DataCount() > 1000 {
-		notify.SendPromise(models.ErrBatchSize, s.clientErrs)
+	if err := CheckItems(notify.DataCount(), s.maxItems); err != nil {
+		s.sendPromise(notify, err)
+		return
+	}
+	// Notifications are not queued behind an open circuit, so promises do not pile up.
+	if s.breaker != nil && s.breaker.State() == breaker.Open {
+		s.sendPromise(notify, fmt.Errorf("%w: not queued", models.ErrCircuitOpen))
 		return
 	}
 
 	// Makes this predictable for testing, as select is non-deterministic.
 	if notify.Ctx().Err() != nil {
-		notify.SendPromise(notify.Ctx().Err(), s.clientErrs)
+		s.sendPromise(notify, notify.Ctx().Err())
 		return
 	}
 
 	select {
 	case <-notify.Ctx().Done():
-		notify.SendPromise(notify.Ctx().Err(), s.clientErrs)
+		s.sendPromise(notify, notify.Ctx().Err())
+	case <-s.stopCtx.Done():
+		s.sendPromise(notify, context.Cause(s.stopCtx))
 	case s.in <- notify:
 	}
 	return
 }
 
+// Stats returns statistics about the notifications sent by the Service. QueueDepth is the number of
+// notifications waiting in the Service.
+func (s *Service) Stats() stats.Stats {
+	st := s.stats.Stats()
+	st.QueueDepth = len(s.in)
+	return st
+}
+
+// TeeStats returns a Collector that records the notifications handled by the Service from now on, in addition
+// to Stats().
+func (s *Service) TeeStats() *stats.Collector {
+	return s.stats.Tee()
+}
+
+// sendPromise sends the result of the notification, records it in the stats and stops any watchdog
+// tracking of it. A failed notification is dead lettered first.
+func (s *Service) sendPromise(n models.Notifications, err error) {
+	s.stats.Result(n.DataCount(), err)
+	s.deadLetter(n, err)
+	n.SendPromise(err, s.clientErrs)
+	watchdog.Finish(n.Ctx())
+}
+
+// deadLetter sends n, which failed with err, on the channel set with WithDeadLetter(). Its context keeps
+// the values of the send's context, without its deadline or cancellation, and holds err. Its promise is
+// removed, as the promise receives err and may be reused after that. A notification that was spooled is not
+// dead lettered, as the spool sends it.
+func (s *Service) deadLetter(n models.Notifications, err error) {
+	if s.deadLetters == nil || err == nil || errors.Is(err, models.ErrSpooled) {
+		return
+	}
+	dead := n.SetCtx(deadletter.WithErr(context.WithoutCancel(n.Ctx()), err)).SetPromise(nil)
+	select {
+	case s.deadLetters <- dead:
+	default:
+		s.logger().Error("ARN dead letter channel is full, dropping failed notification", "items", n.DataCount(), "error", err.Error())
+	}
+}
+
+// Leader returns true if this instance is the leader, or if WithLeader() was not used. Thread-safe.
+func (s *Service) Leader() bool {
+	return s.leader == nil || s.leader.Leader()
+}
+
 // sender sends notifications to the ARN service.
 func (s *Service) sender() {
-	for n := range s.in {
-		if err := n.SendEvent(s.http, s.store); err != nil {
-			n.SendPromise(err, s.clientErrs)
-			continue
+	pprof.Do(context.Background(), pprof.Labels(build.PprofLabel, "conn.sender"), func(context.Context) {
+		defer close(s.done)
+		if s.leader == nil {
+			for n := range s.in {
+				s.handle(n)
+			}
+			s.pool.close()
+			return
 		}
+
+		// The notifications being sent finish before the lock is released.
+		defer s.closeLeader()
+		defer s.pool.close()
+		for {
+			select {
+			case n, ok := <-s.in:
+				if !ok {
+					s.flush()
+					return
+				}
+				s.handle(n)
+			case <-s.leader.Elected():
+				s.flush()
+			}
+		}
+	})
+}
+
+// handle sends n, unless the Service is stopped or this instance is a follower.
+func (s *Service) handle(n models.Notifications) {
+	if s.stopCtx.Err() != nil {
+		s.sendPromise(n, context.Cause(s.stopCtx))
+		return
+	}
+	if !s.Leader() {
+		s.follow(n)
+		return
+	}
+	if s.pool != nil {
+		s.pool.do(n)
+		return
+	}
+	s.send(n)
+}
+
+// follow handles n for a follower by the leader.Options.Follower policy.
+func (s *Service) follow(n models.Notifications) {
+	o := s.leader.Options()
+	if o.Follower == leader.Drop {
+		// Not recorded in the stats, as the notification was neither sent nor failed.
 		n.SendPromise(nil, s.clientErrs)
+		watchdog.Finish(n.Ctx())
+		return
+	}
+	if len(s.queue) == o.MaxQueue {
+		s.sendPromise(s.queue[0], fmt.Errorf("%w: the follower queue is full", leader.ErrNotLeader))
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, n)
+}
+
+// flush sends the queued notifications if this instance is the leader. When the Service is closing, the
+// ones that cannot be sent fail with leader.ErrNotLeader.
+func (s *Service) flush() {
+	q := s.queue
+	s.queue = nil
+	for _, n := range q {
+		if !s.Leader() && s.stopCtx.Err() == nil {
+			s.queue = append(s.queue, n)
+			continue
+		}
+		s.handle(n)
+	}
+}
+
+// closeLeader fails the notifications still queued and releases the lock.
+func (s *Service) closeLeader() {
+	for _, n := range s.queue {
+		s.sendPromise(n, fmt.Errorf("%w: the client was closed", leader.ErrNotLeader))
+	}
+	s.queue = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.leader.Close(ctx); err != nil {
+		s.logger().Warn("ARN leader election could not release the lock", "error", err.Error())
+	}
+}
+
+// isCanary returns true if the next notification should be sent through blob storage as a canary.
+func (s *Service) isCanary() bool {
+	if s.blobCanary == 0 || s.store == nil {
+		return false
+	}
+	s.canaryMu.Lock()
+	defer s.canaryMu.Unlock()
+	s.untilCanary--
+	if s.untilCanary > 0 {
+		return false
+	}
+	s.untilCanary = s.blobCanary
+	return true
+}
+
+// send sends a single notification inside a runtime/trace task so the stages of the send
+// can be seen in execution traces. The time spent in each stage is recorded with the timing package.
+func (s *Service) send(n models.Notifications) {
+	ctx, task := trace.NewTask(timing.Start(n.Ctx()), "arn.Notification")
+	defer task.End()
+	tl := timing.FromCtx(ctx)
+	tl.Record(timing.StageQueue, time.Since(tl.Started()))
+	if s.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.budget)
+		defer cancel()
+	}
+	// Abort() cuts the send short.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopSend := context.AfterFunc(s.stopCtx, func() { cancel(context.Cause(s.stopCtx)) })
+	defer stopSend()
+	ctx = stats.WithCollector(ctx, s.stats)
+	if s.isCanary() {
+		ctx = canary.WithBlob(ctx)
+	}
+	if s.skew != nil {
+		ctx = skew.WithBounds(ctx, *s.skew)
+	}
+	if s.provenance != nil {
+		ctx = provenance.WithProvenance(ctx, *s.provenance)
+	}
+	if s.classify != nil {
+		ctx = classify.WithPolicy(ctx, *s.classify)
+	}
+	if s.encrypt != nil {
+		ctx = encrypt.WithWrapper(ctx, s.encrypt)
+	}
+	if s.propLimits != nil {
+		ctx = proplimits.WithLimits(ctx, *s.propLimits)
+	}
+	if s.preview != nil {
+		ctx = preview.WithVersions(ctx, *s.preview)
+	}
+	if s.maxResourceBytes > 0 {
+		ctx = itemsize.WithMax(ctx, s.maxResourceBytes)
+	}
+	ctx = eventinfo.With(ctx)
+	// The span ends before the promise is fulfilled, so a caller that waited on it sees the span ended.
+	endSpan := func(error) {}
+	if s.tracer != nil {
+		ctx, endSpan = tracing.Start(ctx, s.tracer, tl.Started(), n.DataCount())
+	}
+	n = n.SetCtx(ctx)
+
+	if s.breaker != nil {
+		if err := s.breaker.Allow(); err != nil {
+			endSpan(err)
+			s.sendPromise(n, err)
+			return
+		}
+	}
+	err := s.sendEvent(ctx, n)
+	if s.breaker != nil {
+		s.breaker.Record(err)
+	}
+	tl.Finish(ctx, s.slow, n.DataCount(), err)
+	if err != nil {
+		if errors.Is(context.Cause(ctx), models.ErrShutdown) && !errors.Is(err, models.ErrShutdown) {
+			err = fmt.Errorf("%w: %w", models.ErrShutdown, err)
+		}
+		endSpan(err)
+		s.sendPromise(n, err)
+		return
+	}
+	// The state is recorded before the promise is fulfilled, so a caller that waited on it sees the
+	// notification as delivered.
+	if s.state != nil {
+		if err := s.state.Record(context.WithoutCancel(ctx), n); err != nil {
+			s.logger().Warn("ARN state cache could not record a delivered notification", "error", err.Error())
+		}
+	}
+	s.journalEvent(ctx, n, time.Since(tl.Started()))
+	endSpan(nil)
+	s.sendPromise(n, nil)
+}
+
+// journalEvent logs a record of the delivered event n to the journal, if one is set. It is logged with ctx
+// so a handler that bridges to OpenTelemetry logs can correlate it with the span of the send.
+func (s *Service) journalEvent(ctx context.Context, n models.Notifications, latency time.Duration) {
+	if s.journal == nil {
+		return
+	}
+	info := eventinfo.FromCtx(ctx)
+	id, subject := info.Get()
+	bytes, inline := info.Payload()
+	s.journal.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"ARN event delivered",
+		slog.String("eventID", id),
+		slog.String("subject", subject),
+		slog.Int("items", n.DataCount()),
+		slog.Int64("bytes", bytes),
+		slog.Bool("inline", inline),
+		slog.Duration("latency", latency),
+	)
+}
+
+// logger returns the logger set with WithLogger(), or slog.Default().
+func (s *Service) logger() *slog.Logger {
+	if s.log == nil {
+		return slog.Default()
 	}
+	return s.log
 }
@@ -1,11 +1,22 @@
 package conn
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/internal/conn/breaker"
+	"github.com/Azure/arn-sdk/internal/conn/eventinfo"
+	"github.com/Azure/arn-sdk/internal/conn/leader"
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/internal/conn/prepared"
+	"github.com/Azure/arn-sdk/internal/conn/stats"
 	"github.com/Azure/arn-sdk/internal/conn/storage"
 	"github.com/Azure/arn-sdk/models"
 )
@@ -16,8 +27,23 @@ type fakeNotify struct {
 	count    int
 	ch       chan error
 	eventErr bool
+	// block makes SendEvent() wait for the send's context to end.
+	block bool
+	// sendErr, if set, returns the error of each SendEvent().
+	sendErr func() error
+	// key is the OrderKey of the notification, none if empty.
+	key string
+	// payload, if set, is recorded by SendEvent() as an inline payload of event "id" with subject "/subject".
+	payload int64
+	// prepared, if set, records what prepared.Get() returns on each SendEvent(). SendEvent() then stores
+	// the number of the attempt with prepared.Set(), as a model stores its event.
+	prepared *[]any
 }
 
+type fakeSender struct{}
+
+func (fakeSender) Send(ctx context.Context, event []byte, headers []string) error { return nil }
+
 func newFakeNotify(ctx context.Context, count int, eventErr bool) fakeNotify {
 	return fakeNotify{
 		ctx:      ctx,
@@ -31,6 +57,16 @@ func (f fakeNotify) Ctx() context.Context {
 	return f.ctx
 }
 
+func (f fakeNotify) SetCtx(ctx context.Context) models.Notifications {
+	f.ctx = ctx
+	return f
+}
+
+func (f fakeNotify) SetPromise(ch chan error) models.Notifications {
+	f.ch = ch
+	return f
+}
+
 func (f fakeNotify) SendPromise(e error, backupCh chan error) {
 	select {
 	case f.ch <- e:
@@ -43,10 +79,25 @@ func (f fakeNotify) Promise(ctx context.Context) error {
 	return <-f.ch
 }
 
-func (f fakeNotify) SendEvent(h *http.Client, s *storage.Client) error {
+func (f fakeNotify) SendEvent(h models.EventSender, s models.PayloadStore) error {
+	if f.prepared != nil {
+		*f.prepared = append(*f.prepared, prepared.Get(f.ctx))
+		prepared.Set(f.ctx, len(*f.prepared))
+	}
+	if f.payload > 0 {
+		eventinfo.FromCtx(f.ctx).Set("id", "/subject")
+		eventinfo.FromCtx(f.ctx).SetPayload(f.payload, true)
+	}
+	if f.sendErr != nil {
+		return f.sendErr()
+	}
 	if f.eventErr {
 		return errors.New("event error")
 	}
+	if f.block {
+		<-f.ctx.Done()
+		return f.ctx.Err()
+	}
 	return nil
 }
 
@@ -87,8 +138,10 @@ func TestSend(t *testing.T) {
 		},
 	}
 
-	s := &Service{in: make(chan models.Notifications, 1)}
-	go s.sender()
+	s, err := New(fakeSender{}, nil, make(chan error, 1))
+	if err != nil {
+		t.Fatalf("TestSend: New() error: %v", err)
+	}
 	defer s.Close()
 
 	for _, test := range tests {
@@ -103,4 +156,377 @@ func TestSend(t *testing.T) {
 			continue
 		}
 	}
+
+	st := s.Stats()
+	if st.Sent != 1 || st.Items != 1000 || st.Failed != 3 {
+		t.Errorf("TestSend: got Stats Sent %d, Items %d, Failed %d, want 1, 1000, 3", st.Sent, st.Items, st.Failed)
+	}
+	if st.Failures[stats.FailBatchSize] != 1 || st.Failures[stats.FailCanceled] != 1 || st.Failures[stats.FailOther] != 1 {
+		t.Errorf("TestSend: got Stats.Failures %v", st.Failures)
+	}
+}
+
+func TestCheckItems(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		count   int
+		limit   int
+		wantErr bool
+	}{
+		{name: "Default limit", count: maxvals.NotificationItems},
+		{name: "Error: over default limit", count: maxvals.NotificationItems + 1, wantErr: true},
+		{name: "Raised limit", count: maxvals.NotificationItems + 1, limit: 2000},
+		{name: "Error: over raised limit", count: 2001, limit: 2000, wantErr: true},
+		{name: "Error: over lowered limit", count: 11, limit: 10, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := CheckItems(test.count, test.limit)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestCheckItems(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestCheckItems(%s): got err == %s, want err == nil", test.name, err)
+		case err != nil && !errors.Is(err, models.ErrBatchSize):
+			t.Errorf("TestCheckItems(%s): got err == %s, want errors.Is(err, models.ErrBatchSize)", test.name, err)
+		}
+	}
+}
+
+func TestIsCanary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		every   int
+		noStore bool
+		want    []bool
+	}{
+		{name: "Off", want: []bool{false, false, false}},
+		{name: "Every notification", every: 1, want: []bool{true, true, true}},
+		{name: "Every third notification", every: 3, want: []bool{false, false, true, false, false, true}},
+		{name: "No blob storage", every: 1, noStore: true, want: []bool{false, false}},
+	}
+
+	for _, test := range tests {
+		s := &Service{store: &storage.Client{}}
+		if test.noStore {
+			s.store = nil
+		}
+		if test.every > 0 {
+			if err := WithBlobCanary(test.every)(s); err != nil {
+				t.Fatalf("TestIsCanary(%s): WithBlobCanary() error: %v", test.name, err)
+			}
+		}
+		for i, want := range test.want {
+			if got := s.isCanary(); got != want {
+				t.Errorf("TestIsCanary(%s): notification %d: got %v, want %v", test.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestRecyclePromise(t *testing.T) {
+	t.Parallel()
+
+	// A result that is never read must not be seen by the next user of the promise.
+	p := NewPromise()
+	p <- errors.New("stale")
+	RecyclePromise(p)
+	RecyclePromise(nil)
+
+	for i := 0; i < 10; i++ {
+		p := NewPromise()
+		select {
+		case err := <-p:
+			t.Fatalf("TestRecyclePromise: got %v from a new promise, want an empty promise", err)
+		default:
+		}
+		RecyclePromise(p)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(fakeSender{}, nil, make(chan error, 1))
+	if err != nil {
+		t.Fatalf("TestDrain: New() error: %v", err)
+	}
+	sent := newFakeNotify(context.Background(), 1, false)
+	s.Send(sent)
+	if !s.Drain(context.Background()) {
+		t.Errorf("TestDrain: got Drain() == false, want true")
+	}
+	if err := sent.Promise(context.Background()); err != nil {
+		t.Errorf("TestDrain: got err == %s, want err == nil", err)
+	}
+	// Close() after Drain() must not panic.
+	s.Close()
+}
+
+func TestDrainAbort(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(fakeSender{}, nil, make(chan error, 1))
+	if err != nil {
+		t.Fatalf("TestDrainAbort: New() error: %v", err)
+	}
+
+	// The first notification blocks the sender until the drain is aborted, the second is queued behind it.
+	inFlight := newFakeNotify(context.Background(), 1, false)
+	inFlight.block = true
+	queued := newFakeNotify(context.Background(), 1, false)
+	s.Send(inFlight)
+	s.Send(queued)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.Drain(ctx) {
+		t.Errorf("TestDrainAbort: got Drain() == true, want false")
+	}
+
+	for name, n := range map[string]fakeNotify{"in flight": inFlight, "queued": queued} {
+		if err := n.Promise(context.Background()); !errors.Is(err, models.ErrShutdown) {
+			t.Errorf("TestDrainAbort(%s): got err == %v, want models.ErrShutdown", name, err)
+		}
+	}
+	if got := s.Stats().Failures[stats.FailShutdown]; got != 2 {
+		t.Errorf("TestDrainAbort: got %d shutdown failures, want 2", got)
+	}
+}
+
+// switchLock is a leader.Lock that is held while held is true.
+type switchLock struct {
+	held atomic.Bool
+}
+
+func (l *switchLock) Acquire(context.Context) (bool, error) { return l.held.Load(), nil }
+func (l *switchLock) Release(context.Context) error         { return nil }
+
+func TestLeader(t *testing.T) {
+	t.Parallel()
+
+	wait := func(ctx context.Context, n fakeNotify) (error, bool) {
+		select {
+		case err := <-n.ch:
+			return err, true
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+
+	// A follower with the Drop policy succeeds without sending.
+	lock := &switchLock{}
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithLeader(leader.Options{Lock: lock}))
+	if err != nil {
+		t.Fatalf("TestLeader: New() error: %v", err)
+	}
+	n := newFakeNotify(context.Background(), 1, false)
+	s.Send(n)
+	if err := n.Promise(context.Background()); err != nil {
+		t.Errorf("TestLeader(drop): got err == %s, want err == nil", err)
+	}
+	if s.Leader() {
+		t.Errorf("TestLeader(drop): got Leader() == true, want false")
+	}
+	s.Drain(context.Background())
+	if got := s.Stats().Sent; got != 0 {
+		t.Errorf("TestLeader(drop): got %d sent, want 0", got)
+	}
+
+	// A follower with the Queue policy sends once it becomes the leader. The oldest notification fails when
+	// the queue is full.
+	lock = &switchLock{}
+	s, err = New(
+		fakeSender{},
+		nil,
+		make(chan error, 1),
+		WithLeader(leader.Options{Lock: lock, Follower: leader.Queue, MaxQueue: 1, RenewInterval: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("TestLeader: New() error: %v", err)
+	}
+	first := newFakeNotify(context.Background(), 1, false)
+	second := newFakeNotify(context.Background(), 1, false)
+	s.Send(first)
+	s.Send(second)
+	if err := first.Promise(context.Background()); !errors.Is(err, leader.ErrNotLeader) {
+		t.Errorf("TestLeader(queue full): got err == %v, want leader.ErrNotLeader", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	if _, done := wait(ctx, second); done {
+		t.Errorf("TestLeader(queue): queued notification finished before this instance was the leader")
+	}
+	cancel()
+
+	lock.held.Store(true)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err, done := wait(ctx, second); !done || err != nil {
+		t.Errorf("TestLeader(queue): after becoming the leader got err == %v, done == %v, want nil, true", err, done)
+	}
+	s.Drain(context.Background())
+	if got := s.Stats().Sent; got != 1 {
+		t.Errorf("TestLeader(queue): got %d sent, want 1", got)
+	}
+
+	// Notifications still queued when the Service closes fail.
+	lock = &switchLock{}
+	s, err = New(fakeSender{}, nil, make(chan error, 1), WithLeader(leader.Options{Lock: lock, Follower: leader.Queue}))
+	if err != nil {
+		t.Fatalf("TestLeader: New() error: %v", err)
+	}
+	n = newFakeNotify(context.Background(), 1, false)
+	s.Send(n)
+	s.Drain(context.Background())
+	if err := n.Promise(context.Background()); !errors.Is(err, leader.ErrNotLeader) {
+		t.Errorf("TestLeader(closed): got err == %v, want leader.ErrNotLeader", err)
+	}
+}
+
+func TestBreakerSend(t *testing.T) {
+	t.Parallel()
+
+	b, err := breaker.New(breaker.Options{Threshold: 2, Cooldown: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithBreaker(b))
+	if err != nil {
+		t.Fatalf("TestBreakerSend: New(): %v", err)
+	}
+	defer s.Close()
+
+	var attempts atomic.Int32
+	for i := 0; i < 3; i++ {
+		n := newFakeNotify(context.Background(), 1, false)
+		n.sendErr = func() error {
+			attempts.Add(1)
+			return statusErr(503)
+		}
+		s.Send(n)
+		err := n.Promise(context.Background())
+		if i < 2 && (err == nil || err.Error() != statusErr(503).Error()) {
+			t.Errorf("TestBreakerSend(send %d): got err == %v, want the 503 error", i, err)
+		}
+		if i == 2 && !errors.Is(err, models.ErrCircuitOpen) {
+			t.Errorf("TestBreakerSend(send %d): got err == %v, want models.ErrCircuitOpen", i, err)
+		}
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("TestBreakerSend: got %d sends, want 2", got)
+	}
+	if got := s.Stats().Failures[stats.FailCircuitOpen]; got != 1 {
+		t.Errorf("TestBreakerSend: got %d circuitOpen failures, want 1", got)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(fakeSender{}, nil, make(chan error, 1), WithDeadLetter(nil)); err == nil {
+		t.Errorf("TestDeadLetter(nil channel): got err == nil, want err != nil")
+	}
+
+	dead := make(chan models.Notifications, 1)
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithDeadLetter(dead))
+	if err != nil {
+		t.Fatalf("TestDeadLetter: New(): %v", err)
+	}
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		sendErr  error
+		wantDead bool
+	}{
+		{name: "success"},
+		{name: "failure", sendErr: statusErr(400), wantDead: true},
+		{name: "spooled", sendErr: fmt.Errorf("%w: events ahead of it are spooled", models.ErrSpooled)},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		n := newFakeNotify(ctx, 1, false)
+		n.sendErr = func() error { return test.sendErr }
+		s.Send(n)
+		if err := n.Promise(context.Background()); err != test.sendErr {
+			t.Errorf("TestDeadLetter(%s): got err == %v, want %v", test.name, err, test.sendErr)
+		}
+		cancel()
+
+		var got models.Notifications
+		select {
+		case got = <-dead:
+		default:
+		}
+		switch {
+		case !test.wantDead && got != nil:
+			t.Errorf("TestDeadLetter(%s): got a dead lettered notification, want none", test.name)
+			continue
+		case test.wantDead && got == nil:
+			t.Errorf("TestDeadLetter(%s): got no dead lettered notification, want one", test.name)
+			continue
+		case !test.wantDead:
+			continue
+		}
+		if err, ok := models.DeadLetterErr(got); !ok || err != test.sendErr {
+			t.Errorf("TestDeadLetter(%s): got DeadLetterErr() == %v, %v, want %v, true", test.name, err, ok, test.sendErr)
+		}
+		if err := got.Ctx().Err(); err != nil {
+			t.Errorf("TestDeadLetter(%s): got the notification's ctx.Err() == %v, want nil", test.name, err)
+		}
+		if ch := got.(fakeNotify).ch; ch != nil {
+			t.Errorf("TestDeadLetter(%s): got a promise on the dead lettered notification, want none", test.name)
+		}
+	}
+
+	// A full channel drops the notification instead of blocking the sender.
+	dead <- newFakeNotify(context.Background(), 1, false)
+	n := newFakeNotify(context.Background(), 1, true)
+	s.Send(n)
+	if err := n.Promise(context.Background()); err == nil {
+		t.Errorf("TestDeadLetter(full channel): got err == nil, want err != nil")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(fakeSender{}, nil, make(chan error, 1), WithJournal(nil)); err == nil {
+		t.Errorf("TestJournal(nil logger): got err == nil, want err != nil")
+	}
+
+	buf := &bytes.Buffer{}
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithJournal(slog.New(slog.NewTextHandler(buf, nil))))
+	if err != nil {
+		t.Fatalf("TestJournal: New(): %v", err)
+	}
+	defer s.Close()
+
+	n := newFakeNotify(context.Background(), 2, false)
+	n.payload = 100
+	s.Send(n)
+	if err := n.Promise(context.Background()); err != nil {
+		t.Fatalf("TestJournal: got err == %v, want nil", err)
+	}
+	failed := newFakeNotify(context.Background(), 1, true)
+	s.Send(failed)
+	if err := failed.Promise(context.Background()); err == nil {
+		t.Fatalf("TestJournal(failed): got err == nil, want err != nil")
+	}
+
+	// The journal is written before the promise is fulfilled.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("TestJournal: got %d records, want 1 for the delivered event:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"level=INFO", "eventID=id", "subject=/subject", "items=2", "bytes=100", "inline=true", "latency="} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("TestJournal: got record %q, want it to contain %q", lines[0], want)
+		}
+	}
 }
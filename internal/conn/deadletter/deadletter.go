@@ -0,0 +1,20 @@
+/*
+Package deadletter carries the error a notification failed with on the notification that is sent on the
+dead letter channel set with client.WithDeadLetter().
+
+Service.sendPromise() adds the error to the context of a failed notification with WithErr() before it sends
+the notification on the channel. The error is held under models.DeadLetterKey, so models.DeadLetterErr()
+reads it back and callers do not need this package.
+*/
+package deadletter
+
+import (
+	"context"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+// WithErr returns a context that holds err, the error the notification failed with.
+func WithErr(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, models.DeadLetterKey{}, err)
+}
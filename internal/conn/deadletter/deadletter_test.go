@@ -0,0 +1,19 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+func TestWithErr(t *testing.T) {
+	t.Parallel()
+
+	want := errors.New("failed")
+	ctx := WithErr(context.Background(), want)
+	if got, ok := ctx.Value(models.DeadLetterKey{}).(error); !ok || got != want {
+		t.Errorf("TestWithErr: got %v, %v under models.DeadLetterKey, want %v, true", got, ok, want)
+	}
+}
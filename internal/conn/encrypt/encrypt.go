@@ -0,0 +1,214 @@
+/*
+Package encrypt encrypts the blob payloads of notifications with envelope encryption, for resources that are
+too sensitive to be kept in blob storage in the clear.
+
+Each payload is encrypted with a new AES-256-GCM content key. The content key is wrapped with a key
+encryption key, such as a Key Vault key, and the wrapped key and the ID of the key encryption key are
+recorded in the AdditionalBatchProperties of the event. Only the ID is recorded, the key encryption key never
+leaves Key Vault. A consumer with permission to unwrap with the key decrypts the payload with Open().
+
+The conn package adds the KeyWrapper to the context of each notification with WithWrapper(). The model's
+SendEvent() encrypts each payload it uploads to blob storage with Seal(). Inline payloads are not encrypted.
+*/
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// The keys the encryption is recorded with in AdditionalBatchProperties.Others.
+const (
+	// KeyContent is the algorithm the payload is encrypted with, ContentAlg.
+	KeyContent = "arnEncContent"
+	// KeyKeyID is the ID of the key encryption key that wrapped the content key.
+	KeyKeyID = "arnEncKeyId"
+	// KeyAlg is the algorithm the content key was wrapped with.
+	KeyAlg = "arnEncKeyAlg"
+	// KeyWrapped is the wrapped content key, base64 encoded.
+	KeyWrapped = "arnEncKey"
+)
+
+// ContentAlg is the algorithm payloads are encrypted with.
+const ContentAlg = "A256GCM"
+
+// header starts every encrypted payload. It cannot be the start of JSON or gzip, so an encrypted payload is
+// never mistaken for a plain one.
+var header = []byte("arnenc1:")
+
+// ErrNotEncrypted is returned by Open() for a payload that is not encrypted.
+var ErrNotEncrypted = errors.New("payload is not encrypted")
+
+// Wrapped is a content key wrapped by a key encryption key.
+type Wrapped struct {
+	// KeyID is the ID of the key encryption key, including its version.
+	KeyID string
+	// Alg is the algorithm the key was wrapped with.
+	Alg string
+	// Key is the wrapped key.
+	Key []byte
+}
+
+// KeyWrapper wraps content keys with a key encryption key. KeyVault is a KeyWrapper.
+type KeyWrapper interface {
+	// WrapKey wraps key.
+	WrapKey(ctx context.Context, key []byte) (Wrapped, error)
+}
+
+// KeyUnwrapper unwraps content keys wrapped by a KeyWrapper. KeyVault is a KeyUnwrapper.
+type KeyUnwrapper interface {
+	// UnwrapKey returns the key in w.
+	UnwrapKey(ctx context.Context, w Wrapped) ([]byte, error)
+}
+
+// Seal encrypts payload with a new content key that is wrapped by w. It returns the encrypted payload and
+// others with the encryption recorded in it. others is not changed.
+func Seal(ctx context.Context, w KeyWrapper, payload []byte, others map[string]any) ([]byte, map[string]any, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("could not create a content key: %w", err)
+	}
+	wrapped, err := w.WrapKey(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not wrap the content key: %w", err)
+	}
+	if wrapped.KeyID == "" || wrapped.Alg == "" || len(wrapped.Key) == 0 {
+		return nil, nil, fmt.Errorf("KeyWrapper returned an incomplete wrapped key")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("could not create a nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(header)+len(nonce)+len(payload)+gcm.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, payload, header)
+
+	others = maps.Clone(others)
+	if others == nil {
+		others = map[string]any{}
+	}
+	others[KeyContent] = ContentAlg
+	others[KeyKeyID] = wrapped.KeyID
+	others[KeyAlg] = wrapped.Alg
+	others[KeyWrapped] = base64.StdEncoding.EncodeToString(wrapped.Key)
+	return out, others, nil
+}
+
+// Encrypted returns true if the AdditionalBatchProperties.Others of an event record that its payload is
+// encrypted.
+func Encrypted(others map[string]any) bool {
+	_, ok := others[KeyContent]
+	return ok
+}
+
+// IsSealed returns true if payload was encrypted by Seal().
+func IsSealed(payload []byte) bool {
+	return bytes.HasPrefix(payload, header)
+}
+
+// Open decrypts payload, which was encrypted by Seal(), with the content key recorded in others, the
+// AdditionalBatchProperties.Others of its event. The content key is unwrapped with u. It returns
+// ErrNotEncrypted if payload is not encrypted.
+func Open(ctx context.Context, u KeyUnwrapper, others map[string]any, payload []byte) ([]byte, error) {
+	if !IsSealed(payload) {
+		return nil, ErrNotEncrypted
+	}
+	wrapped, err := wrappedFrom(others)
+	if err != nil {
+		return nil, err
+	}
+	key, err := u.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap the content key with %s: %w", wrapped.KeyID, err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b := payload[len(header):]
+	if len(b) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted payload is truncated")
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt the payload: %w", err)
+	}
+	return plain, nil
+}
+
+// wrappedFrom returns the wrapped content key recorded in others by Seal().
+func wrappedFrom(others map[string]any) (Wrapped, error) {
+	get := func(k string) (string, error) {
+		s, ok := others[k].(string)
+		if !ok || s == "" {
+			return "", fmt.Errorf("AdditionalBatchProperties[%s] must be a non-empty string", k)
+		}
+		return s, nil
+	}
+
+	alg, err := get(KeyContent)
+	if err != nil {
+		return Wrapped{}, err
+	}
+	if alg != ContentAlg {
+		return Wrapped{}, fmt.Errorf("payload is encrypted with unsupported algorithm %q", alg)
+	}
+	var w Wrapped
+	if w.KeyID, err = get(KeyKeyID); err != nil {
+		return Wrapped{}, err
+	}
+	if w.Alg, err = get(KeyAlg); err != nil {
+		return Wrapped{}, err
+	}
+	key, err := get(KeyWrapped)
+	if err != nil {
+		return Wrapped{}, err
+	}
+	if w.Key, err = base64.StdEncoding.DecodeString(key); err != nil {
+		return Wrapped{}, fmt.Errorf("AdditionalBatchProperties[%s] is not base64: %w", KeyWrapped, err)
+	}
+	return w, nil
+}
+
+// newGCM returns AES-GCM for key, which must be 32 bytes.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("content key must be 32 bytes, was %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type ctxKey struct{}
+
+// WithWrapper returns a context that holds w.
+func WithWrapper(ctx context.Context, w KeyWrapper) context.Context {
+	return context.WithValue(ctx, ctxKey{}, w)
+}
+
+// FromCtx returns the KeyWrapper in ctx. ok is false if there is none.
+func FromCtx(ctx context.Context) (w KeyWrapper, ok bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	w, ok = ctx.Value(ctxKey{}).(KeyWrapper)
+	return w, ok
+}
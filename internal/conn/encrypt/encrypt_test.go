@@ -0,0 +1,105 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"testing"
+)
+
+// xorWrapper is a KeyWrapper and KeyUnwrapper that "wraps" with a fixed XOR, for tests.
+type xorWrapper struct {
+	id  string
+	err error
+}
+
+func (x xorWrapper) WrapKey(ctx context.Context, key []byte) (Wrapped, error) {
+	if x.err != nil {
+		return Wrapped{}, x.err
+	}
+	return Wrapped{KeyID: x.id, Alg: "xor", Key: xor(key)}, nil
+}
+
+func (x xorWrapper) UnwrapKey(ctx context.Context, w Wrapped) ([]byte, error) {
+	if w.KeyID != x.id {
+		return nil, errors.New("unknown key")
+	}
+	return xor(w.Key), nil
+}
+
+func xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ 0x5a
+	}
+	return out
+}
+
+func TestSealOpen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	w := xorWrapper{id: "key/1"}
+	payload := []byte(`[{"resourceId":"a"}]`)
+	others := map[string]any{"mine": "value"}
+
+	sealed, got, err := Seal(ctx, w, payload, others)
+	if err != nil {
+		t.Fatalf("TestSealOpen: Seal(): got err == %s, want err == nil", err)
+	}
+	if len(others) != 1 {
+		t.Errorf("TestSealOpen: Seal() changed the caller's others to %v", others)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, payload) {
+		t.Errorf("TestSealOpen: Seal() did not encrypt the payload")
+	}
+	if !Encrypted(got) || got["mine"] != "value" || got[KeyKeyID] != "key/1" {
+		t.Errorf("TestSealOpen: got others %v, want the encryption recorded with the caller's values", got)
+	}
+
+	plain, err := Open(ctx, w, got, sealed)
+	if err != nil {
+		t.Fatalf("TestSealOpen: Open(): got err == %s, want err == nil", err)
+	}
+	if !bytes.Equal(plain, payload) {
+		t.Errorf("TestSealOpen: Open(): got %s, want %s", plain, payload)
+	}
+
+	if _, _, err := Seal(ctx, xorWrapper{err: errors.New("denied")}, payload, nil); err == nil {
+		t.Errorf("TestSealOpen: Seal() with a failing wrapper: got err == nil, want err != nil")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	otherKey := maps.Clone(got)
+	otherKey[KeyKeyID] = "key/2"
+	badAlg := maps.Clone(got)
+	badAlg[KeyContent] = "A128CBC"
+	noKey := maps.Clone(got)
+	delete(noKey, KeyWrapped)
+
+	tests := []struct {
+		name    string
+		others  map[string]any
+		payload []byte
+		want    error
+	}{
+		{name: "Not encrypted", others: got, payload: payload, want: ErrNotEncrypted},
+		{name: "Tampered", others: got, payload: tampered},
+		{name: "Truncated", others: got, payload: sealed[:len(header)+2]},
+		{name: "Unknown key", others: otherKey, payload: sealed},
+		{name: "Unsupported algorithm", others: badAlg, payload: sealed},
+		{name: "No wrapped key", others: noKey, payload: sealed},
+	}
+	for _, test := range tests {
+		_, err := Open(ctx, w, test.others, test.payload)
+		if err == nil {
+			t.Errorf("TestSealOpen(%s): Open(): got err == nil, want err != nil", test.name)
+			continue
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("TestSealOpen(%s): Open(): got err == %s, want %s", test.name, err, test.want)
+		}
+	}
+}
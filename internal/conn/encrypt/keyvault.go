@@ -0,0 +1,169 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/arn-sdk/internal/build"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/go-json-experiment/json"
+)
+
+const (
+	// keyVaultAPIVersion is the Key Vault REST API version used for wrapkey and unwrapkey.
+	keyVaultAPIVersion = "7.4"
+	// WrapAlg is the algorithm KeyVault wraps content keys with.
+	WrapAlg = "RSA-OAEP-256"
+)
+
+// KeyVault wraps and unwraps content keys with an RSA key in Azure Key Vault or Managed HSM, using the
+// wrapkey and unwrapkey operations. The key never leaves the vault.
+type KeyVault struct {
+	// keyID is the ID of the key, without a version.
+	keyID  string
+	client *azcore.Client
+}
+
+// NewKeyVault returns a KeyVault for the key with keyID, like "https://myvault.vault.azure.net/keys/mykey".
+// If keyID has a version, that version is used to wrap, otherwise the current version is used. Keys are
+// unwrapped with the version they were wrapped with. To wrap, cred needs the "wrapKey" permission on the key
+// and to unwrap, the "unwrapKey" permission. opts can be nil.
+func NewKeyVault(keyID string, cred azcore.TokenCredential, opts *policy.ClientOptions) (*KeyVault, error) {
+	if cred == nil {
+		return nil, fmt.Errorf("cred cannot be nil")
+	}
+	u, err := url.Parse(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("keyID(%s) is not a valid URL: %w", keyID, err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Scheme != "https" || u.Host == "" || len(parts) < 2 || len(parts) > 3 || parts[0] != "keys" || parts[1] == "" {
+		return nil, fmt.Errorf("keyID(%s) must be like https://myvault.vault.azure.net/keys/mykey[/version]", keyID)
+	}
+	u.Path = "/" + strings.Join(parts, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	plOpts := runtime.PipelineOptions{
+		PerRetry: []policy.Policy{
+			runtime.NewBearerTokenPolicy(cred, []string{vaultScope(u.Hostname())}, nil),
+		},
+	}
+	client, err := azcore.NewClient("arn.KeyVault", build.Version, plOpts, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyVault{keyID: u.String(), client: client}, nil
+}
+
+// vaultScope returns the token scope for a vault at host, which is the vault's DNS suffix, such as
+// "https://vault.azure.net/.default" for "myvault.vault.azure.net". This covers sovereign clouds and
+// Managed HSM without a table of them.
+func vaultScope(host string) string {
+	if _, suffix, ok := strings.Cut(host, "."); ok {
+		host = suffix
+	}
+	return "https://" + host + "/.default"
+}
+
+// keyOp is the body of a wrapkey or unwrapkey request and response.
+type keyOp struct {
+	Alg   string `json:"alg,omitzero"`
+	KID   string `json:"kid,omitzero"`
+	Value string `json:"value"`
+}
+
+// WrapKey implements KeyWrapper.
+func (k *KeyVault) WrapKey(ctx context.Context, key []byte) (Wrapped, error) {
+	resp, err := k.do(ctx, k.keyID+"/wrapkey", keyOp{Alg: WrapAlg, Value: base64.RawURLEncoding.EncodeToString(key)})
+	if err != nil {
+		return Wrapped{}, err
+	}
+	if resp.KID == "" {
+		return Wrapped{}, fmt.Errorf("Key Vault wrapkey response has no kid")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(resp.Value)
+	if err != nil {
+		return Wrapped{}, fmt.Errorf("Key Vault wrapkey response value is not base64url: %w", err)
+	}
+	return Wrapped{KeyID: resp.KID, Alg: WrapAlg, Key: b}, nil
+}
+
+// UnwrapKey implements KeyUnwrapper. w.KeyID must be a version of the KeyVault's key, so that an event cannot
+// send the credential's token to another host.
+func (k *KeyVault) UnwrapKey(ctx context.Context, w Wrapped) ([]byte, error) {
+	if !k.owns(w.KeyID) {
+		return nil, fmt.Errorf("key %s is not a version of %s", w.KeyID, k.keyID)
+	}
+	if w.Alg != WrapAlg {
+		return nil, fmt.Errorf("unsupported key wrap algorithm %q", w.Alg)
+	}
+	resp, err := k.do(ctx, w.KeyID+"/unwrapkey", keyOp{Alg: w.Alg, Value: base64.RawURLEncoding.EncodeToString(w.Key)})
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(resp.Value)
+	if err != nil {
+		return nil, fmt.Errorf("Key Vault unwrapkey response value is not base64url: %w", err)
+	}
+	return b, nil
+}
+
+// owns returns true if id is k's key or a version of it.
+func (k *KeyVault) owns(id string) bool {
+	u, err := url.Parse(id)
+	if err != nil || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	base := k.keyID
+	// A KeyVault created with a version only unwraps that version.
+	if strings.Count(strings.TrimPrefix(base, "https://"), "/") == 3 {
+		return u.String() == base
+	}
+	v, ok := strings.CutPrefix(u.String(), base+"/")
+	return ok && v != "" && !strings.Contains(v, "/")
+}
+
+// do posts op to u and returns the response.
+func (k *KeyVault) do(ctx context.Context, u string, op keyOp) (keyOp, error) {
+	body, err := json.Marshal(op)
+	if err != nil {
+		return keyOp{}, err
+	}
+	req, err := runtime.NewRequest(ctx, http.MethodPost, u)
+	if err != nil {
+		return keyOp{}, err
+	}
+	req.Raw().URL.RawQuery = "api-version=" + keyVaultAPIVersion
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader(body)), "application/json"); err != nil {
+		return keyOp{}, err
+	}
+
+	resp, err := k.client.Pipeline().Do(req)
+	if err != nil {
+		return keyOp{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return keyOp{}, runtime.NewResponseError(resp)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return keyOp{}, err
+	}
+	var out keyOp
+	if err := json.Unmarshal(b, &out); err != nil {
+		return keyOp{}, fmt.Errorf("Key Vault response is not valid: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package encrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/go-json-experiment/json"
+)
+
+type fakeCred struct{}
+
+func (fakeCred) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestKeyVault(t *testing.T) {
+	t.Parallel()
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("api-version") != keyVaultAPIVersion {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		var op keyOp
+		if err := json.Unmarshal(b, &op); err != nil || op.Alg != WrapAlg {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v, err := base64.RawURLEncoding.DecodeString(op.Value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/keys/arn/wrapkey":
+			out, _ := json.Marshal(keyOp{KID: srv.URL + "/keys/arn/v1", Value: base64.RawURLEncoding.EncodeToString(xor(v))})
+			w.Write(out)
+		case "/keys/arn/v1/unwrapkey":
+			out, _ := json.Marshal(keyOp{Value: base64.RawURLEncoding.EncodeToString(xor(v))})
+			w.Write(out)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	kv, err := NewKeyVault(srv.URL+"/keys/arn/", fakeCred{}, &policy.ClientOptions{Transport: srv.Client()})
+	if err != nil {
+		t.Fatalf("TestKeyVault: NewKeyVault(): got err == %s, want err == nil", err)
+	}
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	w, err := kv.WrapKey(ctx, key)
+	if err != nil {
+		t.Fatalf("TestKeyVault: WrapKey(): got err == %s, want err == nil", err)
+	}
+	if w.KeyID != srv.URL+"/keys/arn/v1" || w.Alg != WrapAlg {
+		t.Errorf("TestKeyVault: WrapKey(): got KeyID %q, Alg %q, want the versioned key and %q", w.KeyID, w.Alg, WrapAlg)
+	}
+	got, err := kv.UnwrapKey(ctx, w)
+	if err != nil {
+		t.Fatalf("TestKeyVault: UnwrapKey(): got err == %s, want err == nil", err)
+	}
+	if string(got) != string(key) {
+		t.Errorf("TestKeyVault: UnwrapKey(): got %q, want %q", got, key)
+	}
+
+	for _, id := range []string{
+		"https://attacker.example.com/keys/arn/v1",
+		srv.URL + "/keys/other/v1",
+		srv.URL + "/keys/arn/v1/extra",
+		srv.URL + "/keys/arn/v1?x=y",
+	} {
+		bad := w
+		bad.KeyID = id
+		if _, err := kv.UnwrapKey(ctx, bad); err == nil || !strings.Contains(err.Error(), "not a version of") {
+			t.Errorf("TestKeyVault: UnwrapKey(%s): got err == %v, want the key refused", id, err)
+		}
+	}
+
+	pinned, err := NewKeyVault(srv.URL+"/keys/arn/v2", fakeCred{}, &policy.ClientOptions{Transport: srv.Client()})
+	if err != nil {
+		t.Fatalf("TestKeyVault: NewKeyVault() with a version: got err == %s, want err == nil", err)
+	}
+	if _, err := pinned.UnwrapKey(ctx, w); err == nil {
+		t.Errorf("TestKeyVault: UnwrapKey() of another version with a pinned version: got err == nil, want err != nil")
+	}
+}
+
+func TestNewKeyVault(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		keyID     string
+		wantScope string
+		wantErr   bool
+	}{
+		{name: "Key", keyID: "https://myvault.vault.azure.net/keys/mykey", wantScope: "https://vault.azure.net/.default"},
+		{name: "Versioned key in another cloud", keyID: "https://myvault.vault.azure.cn/keys/mykey/abc", wantScope: "https://vault.azure.cn/.default"},
+		{name: "Managed HSM", keyID: "https://myhsm.managedhsm.azure.net/keys/mykey", wantScope: "https://managedhsm.azure.net/.default"},
+		{name: "Error: http", keyID: "http://myvault.vault.azure.net/keys/mykey", wantErr: true},
+		{name: "Error: secret", keyID: "https://myvault.vault.azure.net/secrets/mykey", wantErr: true},
+		{name: "Error: no key name", keyID: "https://myvault.vault.azure.net/keys", wantErr: true},
+		{name: "Error: too long", keyID: "https://myvault.vault.azure.net/keys/mykey/abc/def", wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewKeyVault(test.keyID, fakeCred{}, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("TestNewKeyVault(%s): got err == %v, want error: %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		host := strings.Split(strings.TrimPrefix(test.keyID, "https://"), "/")[0]
+		if got := vaultScope(host); got != test.wantScope {
+			t.Errorf("TestNewKeyVault(%s): got scope %q, want %q", test.name, got, test.wantScope)
+		}
+	}
+}
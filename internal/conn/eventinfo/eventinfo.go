@@ -0,0 +1,81 @@
+/*
+Package eventinfo records the ID, subject and payload of the event a notification is sent as, so that code
+that only has the notification, such as the code that reports its result, can name the event in logs.
+
+Service.send() adds an Info to the context of each notification with With(). The model's SendEvent() calls
+Set() once the event is marshaled and SetPayload() when the send ends. Get() returns empty strings if the
+notification failed before it was marshaled or its context has no Info.
+*/
+package eventinfo
+
+import (
+	"context"
+	"sync"
+)
+
+type ctxKey struct{}
+
+// Info holds the ID, subject and payload of the event a notification is sent as. A nil *Info is valid and
+// holds nothing.
+type Info struct {
+	mu      sync.Mutex
+	id      string
+	subject string
+	bytes   int64
+	inline  bool
+}
+
+// With returns a context that holds a new Info.
+func With(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &Info{})
+}
+
+// FromCtx returns the Info in ctx, or nil if there is none.
+func FromCtx(ctx context.Context) *Info {
+	if ctx == nil {
+		return nil
+	}
+	i, _ := ctx.Value(ctxKey{}).(*Info)
+	return i
+}
+
+// Set records the ID and subject of the event.
+func (i *Info) Set(id, subject string) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.id, i.subject = id, subject
+}
+
+// Get returns the ID and subject of the event.
+func (i *Info) Get() (id, subject string) {
+	if i == nil {
+		return "", ""
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.id, i.subject
+}
+
+// SetPayload records the size in bytes of the resources of the event and whether they were sent inline, rather
+// than through blob storage.
+func (i *Info) SetPayload(bytes int64, inline bool) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.bytes, i.inline = bytes, inline
+}
+
+// Payload returns the size in bytes of the resources of the event and whether they were sent inline.
+func (i *Info) Payload() (bytes int64, inline bool) {
+	if i == nil {
+		return 0, false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.bytes, i.inline
+}
@@ -0,0 +1,37 @@
+package eventinfo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	t.Parallel()
+
+	if i := FromCtx(nil); i != nil {
+		t.Errorf("TestInfo(nil ctx): got %v, want nil", i)
+	}
+	// A context without an Info is a no-op.
+	none := FromCtx(context.Background())
+	none.Set("id", "subject")
+	none.SetPayload(100, true)
+	if bytes, inline := none.Payload(); bytes != 0 || inline {
+		t.Errorf("TestInfo(no Info): got payload %d, %v, want 0, false", bytes, inline)
+	}
+	if id, subject := none.Get(); id != "" || subject != "" {
+		t.Errorf("TestInfo(no Info): got %q, %q, want empty strings", id, subject)
+	}
+
+	ctx := With(context.Background())
+	if id, subject := FromCtx(ctx).Get(); id != "" || subject != "" {
+		t.Errorf("TestInfo(before Set): got %q, %q, want empty strings", id, subject)
+	}
+	FromCtx(ctx).Set("id", "subject")
+	if id, subject := FromCtx(ctx).Get(); id != "id" || subject != "subject" {
+		t.Errorf("TestInfo(after Set): got %q, %q, want \"id\", \"subject\"", id, subject)
+	}
+	FromCtx(ctx).SetPayload(100, true)
+	if bytes, inline := FromCtx(ctx).Payload(); bytes != 100 || !inline {
+		t.Errorf("TestInfo(after SetPayload): got %d, %v, want 100, true", bytes, inline)
+	}
+}
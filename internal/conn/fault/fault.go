@@ -0,0 +1,218 @@
+// Package fault provides an azcore policy that injects errors, latency and payload corruption into
+// requests. This is used to rehearse ARN and blob storage outages against the real client code paths.
+package fault
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+// Header is set on every response the policy fabricates, so that injected failures can be told apart
+// from real ones in logs.
+const Header = "x-arn-fault-injected"
+
+// Faults configures the faults injected into one layer. Rates are the fraction of requests, from 0 to 1,
+// that get the fault. The zero value injects nothing.
+type Faults struct {
+	// ErrorRate is the fraction of requests that fail with StatusCode without being sent.
+	ErrorRate float64 `json:"errorRate,omitzero" yaml:"errorRate,omitempty"`
+	// StatusCode is the status code of injected errors. Defaults to 503 (http.StatusServiceUnavailable).
+	StatusCode int `json:"statusCode,omitzero" yaml:"statusCode,omitempty"`
+	// ConnErrorRate is the fraction of requests that fail with a transport error, like a reset connection,
+	// without being sent.
+	ConnErrorRate float64 `json:"connErrorRate,omitzero" yaml:"connErrorRate,omitempty"`
+	// LatencyRate is the fraction of requests that are delayed by Latency before being sent.
+	LatencyRate float64 `json:"latencyRate,omitzero" yaml:"latencyRate,omitempty"`
+	// Latency is the delay added to requests picked by LatencyRate.
+	Latency time.Duration `json:"latency,omitzero" yaml:"latency,omitempty"`
+	// CorruptRate is the fraction of requests whose body has a byte flipped before being sent.
+	CorruptRate float64 `json:"corruptRate,omitzero" yaml:"corruptRate,omitempty"`
+}
+
+// IsZero returns true if no faults are set.
+func (f Faults) IsZero() bool {
+	return f.ErrorRate == 0 && f.ConnErrorRate == 0 && f.LatencyRate == 0 && f.CorruptRate == 0
+}
+
+// Validate validates the faults.
+func (f Faults) Validate() error {
+	rates := []struct {
+		name string
+		v    float64
+	}{
+		{"ErrorRate", f.ErrorRate},
+		{"ConnErrorRate", f.ConnErrorRate},
+		{"LatencyRate", f.LatencyRate},
+		{"CorruptRate", f.CorruptRate},
+	}
+	for _, r := range rates {
+		if r.v < 0 || r.v > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, was %v", r.name, r.v)
+		}
+	}
+	if f.ErrorRate+f.ConnErrorRate > 1 {
+		return fmt.Errorf("ErrorRate + ConnErrorRate cannot be greater than 1")
+	}
+	if f.StatusCode != 0 && (f.StatusCode < 400 || f.StatusCode > 599) {
+		return fmt.Errorf("StatusCode must be a 4xx or 5xx code, was %d", f.StatusCode)
+	}
+	if f.LatencyRate > 0 && f.Latency <= 0 {
+		return fmt.Errorf("Latency must be greater than 0 when LatencyRate is set")
+	}
+	return nil
+}
+
+// Config configures the faults injected into each layer of the client.
+type Config struct {
+	// HTTP are the faults injected into requests to the ARN receiver.
+	HTTP Faults `json:"http,omitzero" yaml:"http,omitempty"`
+	// Blob are the faults injected into requests to blob storage.
+	Blob Faults `json:"blob,omitzero" yaml:"blob,omitempty"`
+	// Seed makes the faults that are picked repeatable between runs. If 0, a random seed is used.
+	Seed uint64 `json:"seed,omitzero" yaml:"seed,omitempty"`
+}
+
+// Validate validates the config.
+func (c Config) Validate() error {
+	if err := c.HTTP.Validate(); err != nil {
+		return fmt.Errorf("HTTP faults: %w", err)
+	}
+	if err := c.Blob.Validate(); err != nil {
+		return fmt.Errorf("blob faults: %w", err)
+	}
+	return nil
+}
+
+// ErrConn is the error returned for requests picked by Faults.ConnErrorRate.
+var ErrConn = fmt.Errorf("fault injection: connection reset by peer")
+
+// Policy is a policy.Policy that injects Faults. It should be added to policy.ClientOptions.PerRetryPolicies
+// so that the azcore retry policy sees each injected failure.
+type Policy struct {
+	faults Faults
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// New creates a new Policy. If seed is not 0, the faults are picked in the same order on every run.
+func New(f Faults, seed uint64) (*Policy, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.StatusCode == 0 {
+		f.StatusCode = http.StatusServiceUnavailable
+	}
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Policy{faults: f, rnd: rand.New(rand.NewPCG(seed, seed))}, nil
+}
+
+// roll returns true with probability rate.
+func (p *Policy) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rnd.Float64() < rate
+}
+
+// intN returns a random int in [0, n).
+func (p *Policy) intN(n int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rnd.IntN(n)
+}
+
+// Do implements policy.Policy.
+func (p *Policy) Do(req *policy.Request) (*http.Response, error) {
+	f := p.faults
+
+	if p.roll(f.LatencyRate) {
+		t := time.NewTimer(f.Latency)
+		select {
+		case <-req.Raw().Context().Done():
+			t.Stop()
+			return nil, req.Raw().Context().Err()
+		case <-t.C:
+		}
+	}
+
+	// A single roll picks between the two failures so that their rates add up.
+	if f.ErrorRate > 0 || f.ConnErrorRate > 0 {
+		p.mu.Lock()
+		v := p.rnd.Float64()
+		p.mu.Unlock()
+		switch {
+		case v < f.ErrorRate:
+			return p.errResponse(req), nil
+		case v < f.ErrorRate+f.ConnErrorRate:
+			return nil, ErrConn
+		}
+	}
+
+	if p.roll(f.CorruptRate) {
+		r, err := p.corrupt(req)
+		if err != nil {
+			return nil, err
+		}
+		return r.Next()
+	}
+	return req.Next()
+}
+
+// errResponse returns a fabricated error response for req.
+func (p *Policy) errResponse(req *policy.Request) *http.Response {
+	body := fmt.Sprintf(`{"error": {"code": "FaultInjected", "message": "fault injection: %d"}}`, p.faults.StatusCode)
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Set(Header, "true")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", p.faults.StatusCode, http.StatusText(p.faults.StatusCode)),
+		StatusCode:    p.faults.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req.Raw(),
+	}
+}
+
+// corrupt returns a clone of req with a random byte in the body flipped. The clone is used so that a
+// retry sends the original body. Requests without a body are returned as is.
+func (p *Policy) corrupt(req *policy.Request) (*policy.Request, error) {
+	body := req.Body()
+	if body == nil {
+		return req, nil
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("fault injection: could not read body: %w", err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("fault injection: could not rewind body: %w", err)
+	}
+	if len(b) == 0 {
+		return req, nil
+	}
+	b[p.intN(len(b))] ^= 0xff
+
+	r := req.Clone(req.Raw().Context())
+	if err := r.SetBody(streaming.NopCloser(bytes.NewReader(b)), req.Raw().Header.Get("Content-Type")); err != nil {
+		return nil, fmt.Errorf("fault injection: could not set body: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,143 @@
+package fault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+// fakeTransport records the body it was sent and returns 200.
+type fakeTransport struct {
+	calls int
+	body  []byte
+}
+
+func (f *fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	f.calls++
+	if req.Body != nil {
+		f.body, _ = io.ReadAll(req.Body)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"key": "value"}`
+
+	tests := []struct {
+		name        string
+		faults      Faults
+		wantErr     error
+		wantCode    int
+		wantCalls   int
+		wantCorrupt bool
+		wantLatency time.Duration
+	}{
+		{name: "No faults", wantCode: http.StatusOK, wantCalls: 1},
+		{name: "Error", faults: Faults{ErrorRate: 1}, wantCode: http.StatusServiceUnavailable},
+		{name: "Error with status code", faults: Faults{ErrorRate: 1, StatusCode: http.StatusTooManyRequests}, wantCode: http.StatusTooManyRequests},
+		{name: "Conn error", faults: Faults{ConnErrorRate: 1}, wantErr: ErrConn},
+		{name: "Corrupt", faults: Faults{CorruptRate: 1}, wantCode: http.StatusOK, wantCalls: 1, wantCorrupt: true},
+		{name: "Latency", faults: Faults{LatencyRate: 1, Latency: 20 * time.Millisecond}, wantCode: http.StatusOK, wantCalls: 1, wantLatency: 20 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		p, err := New(test.faults, 1)
+		if err != nil {
+			t.Fatalf("TestPolicy(%s): New(): got err == %s, want err == nil", test.name, err)
+		}
+		ft := &fakeTransport{}
+		pl := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+			Transport:        ft,
+			PerRetryPolicies: []policy.Policy{p},
+			Retry:            policy.RetryOptions{MaxRetries: -1},
+		})
+
+		req, err := runtime.NewRequest(context.Background(), http.MethodPost, "https://localhost/arnnotify")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := req.SetBody(streaming.NopCloser(strings.NewReader(body)), "application/json"); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		resp, err := pl.Do(req)
+		switch {
+		case test.wantErr != nil:
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("TestPolicy(%s): got err == %v, want %v", test.name, err, test.wantErr)
+			}
+			continue
+		case err != nil:
+			t.Errorf("TestPolicy(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+
+		if resp.StatusCode != test.wantCode {
+			t.Errorf("TestPolicy(%s): got status %d, want %d", test.name, resp.StatusCode, test.wantCode)
+		}
+		if got := resp.Header.Get(Header) != ""; got != (test.wantCode != http.StatusOK) {
+			t.Errorf("TestPolicy(%s): got %s header set == %v", test.name, Header, got)
+		}
+		if ft.calls != test.wantCalls {
+			t.Errorf("TestPolicy(%s): got %d calls to the transport, want %d", test.name, ft.calls, test.wantCalls)
+		}
+		if time.Since(start) < test.wantLatency {
+			t.Errorf("TestPolicy(%s): got latency %v, want at least %v", test.name, time.Since(start), test.wantLatency)
+		}
+		if test.wantCalls == 0 {
+			continue
+		}
+		diff := 0
+		for i := range min(len(ft.body), len(body)) {
+			if ft.body[i] != body[i] {
+				diff++
+			}
+		}
+		if len(ft.body) != len(body) || (diff == 1) != test.wantCorrupt || diff > 1 {
+			t.Errorf("TestPolicy(%s): got body %q, corrupt: want %v", test.name, ft.body, test.wantCorrupt)
+		}
+		if test.wantCorrupt && bytes.Equal(ft.body, []byte(body)) {
+			t.Errorf("TestPolicy(%s): body was not corrupted", test.name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		faults  Faults
+		wantErr bool
+	}{
+		{name: "Zero"},
+		{name: "Valid", faults: Faults{ErrorRate: 0.5, ConnErrorRate: 0.5, LatencyRate: 1, Latency: time.Second, CorruptRate: 0.1}},
+		{name: "Error: rate above 1", faults: Faults{CorruptRate: 1.5}, wantErr: true},
+		{name: "Error: negative rate", faults: Faults{ErrorRate: -0.1}, wantErr: true},
+		{name: "Error: error rates above 1", faults: Faults{ErrorRate: 0.6, ConnErrorRate: 0.6}, wantErr: true},
+		{name: "Error: status code not an error", faults: Faults{ErrorRate: 1, StatusCode: http.StatusOK}, wantErr: true},
+		{name: "Error: latency rate without latency", faults: Faults{LatencyRate: 1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.faults.Validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestValidate(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is an algorithm that request bodies to the ARN receiver are compressed with. Its value is the
+// Content-Encoding of the requests.
+type Compression string
+
+const (
+	// Deflate compresses with zlib, which is the default.
+	Deflate Compression = ContentEncoding
+	// Gzip compresses with gzip.
+	Gzip Compression = "gzip"
+	// Zstd compresses with Zstandard.
+	Zstd Compression = "zstd"
+)
+
+// ContentEncoding is the Content-Encoding of requests to the ARN receiver when compression is on and no
+// other algorithm is set with WithCompression().
+const ContentEncoding = "deflate"
+
+// Compressions returns the Content-Encoding of each algorithm request bodies can be compressed with.
+func Compressions() []string {
+	return []string{string(Deflate), string(Gzip), string(Zstd)}
+}
+
+const (
+	// deflateLevel is the default compression level of Deflate and Gzip.
+	deflateLevel = 5
+	// zstdLevel is the default compression level of Zstd.
+	zstdLevel = 3
+	// maxZstdLevel is the highest compression level of Zstd.
+	maxZstdLevel = 22
+)
+
+// encoder is a compressing writer that can be reused by resetting it onto a new destination.
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// compressor compresses request bodies with one algorithm at one level.
+type compressor struct {
+	alg Compression
+	// pool holds the encoders. An encoder is Reset() onto the request's buffer before it is used and only
+	// returned to the pool once it has been closed, so an encoder is never shared by two requests.
+	pool sync.Pool
+}
+
+// defaultCompressor compresses with Deflate at deflateLevel, for clients that do not use WithCompression().
+var defaultCompressor = mustCompressor(Deflate, 0)
+
+// ValidateCompression returns an error if alg is not an algorithm request bodies can be compressed with or
+// level is not one of its levels. A level of 0 is the default level of alg. Deflate and Gzip take the levels
+// of compress/flate, from flate.HuffmanOnly to flate.BestCompression. Zstd takes the levels of the zstd
+// command, 1 to 22, which are mapped to the nearest level the encoder supports.
+func ValidateCompression(alg Compression, level int) error {
+	switch alg {
+	case Deflate, Gzip:
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("compression level(%d) of %s must be between %d and %d", level, alg, flate.HuffmanOnly, flate.BestCompression)
+		}
+	case Zstd:
+		if level < 0 || level > maxZstdLevel {
+			return fmt.Errorf("compression level(%d) of %s must be between 1 and %d", level, alg, maxZstdLevel)
+		}
+	default:
+		return fmt.Errorf("compression algorithm(%s) is not supported, must be one of %v", alg, Compressions())
+	}
+	return nil
+}
+
+// newCompressor returns a compressor for alg at level, see ValidateCompression().
+func newCompressor(alg Compression, level int) (*compressor, error) {
+	if err := ValidateCompression(alg, level); err != nil {
+		return nil, err
+	}
+
+	var newEnc func() (encoder, error)
+	switch alg {
+	case Deflate, Gzip:
+		if level == 0 {
+			level = deflateLevel
+		}
+		newEnc = func() (encoder, error) {
+			if alg == Gzip {
+				return gzip.NewWriterLevel(io.Discard, level)
+			}
+			return zlib.NewWriterLevel(io.Discard, level)
+		}
+	case Zstd:
+		if level == 0 {
+			level = zstdLevel
+		}
+		// Request bodies are small enough that encoding them on one goroutine is fastest.
+		newEnc = func() (encoder, error) {
+			return zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+		}
+	}
+
+	c := &compressor{alg: alg}
+	c.pool.New = func() any {
+		enc, err := newEnc()
+		if err != nil {
+			// The level was validated, so this cannot happen.
+			panic(err)
+		}
+		return enc
+	}
+	return c, nil
+}
+
+// mustCompressor is newCompressor() for arguments that are known to be valid.
+func mustCompressor(alg Compression, level int) *compressor {
+	c, err := newCompressor(alg, level)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// compress returns the compressed content of body, which is about size bytes long.
+func (c *compressor) compress(body io.Reader, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	// JSON usually compresses to well under a quarter of its size.
+	buf.Grow(int(size/4) + 64)
+
+	w := c.pool.Get().(encoder)
+	w.Reset(&buf)
+	if _, err := io.Copy(w, body); err != nil {
+		// The encoder is dropped, it may hold part of this body.
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	// Point the encoder away from buf, so the pool does not keep buf alive.
+	w.Reset(io.Discard)
+	c.pool.Put(w)
+	return buf.Bytes(), nil
+}
+
+// compressTransport is a policy that compresses request bodies.
+type compressTransport struct {
+	c *compressor
+	// minSize is the smallest body that is compressed. Smaller bodies are sent as they are, as compressing
+	// them saves little and can make them larger.
+	minSize int64
+}
+
+func newCompressTransport(c *compressor, minSize int64) *compressTransport {
+	return &compressTransport{c: c, minSize: minSize}
+}
+
+// Do compresses the body of the request, if it is at least minSize bytes, and sends it.
+func (t *compressTransport) Do(req *policy.Request) (*http.Response, error) {
+	// Get the underlying http.Request
+	httpReq := req.Raw()
+
+	if httpReq.Body != nil && httpReq.ContentLength > 0 && httpReq.ContentLength >= t.minSize {
+		compressed, err := t.c.compress(httpReq.Body, httpReq.ContentLength)
+		if err != nil {
+			return nil, err
+		}
+
+		// Update the request with the compressed body. The buffer is not pooled, as net/http can still
+		// read the body, or get it again with GetBody, after the request has returned.
+		httpReq.Body = io.NopCloser(bytes.NewReader(compressed))
+		httpReq.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(compressed)), nil
+		}
+		httpReq.ContentLength = int64(len(compressed))
+		httpReq.Header.Set("Content-Encoding", string(t.c.alg))
+	}
+
+	// Use the base RoundTripper to perform the actual request.
+	return req.Next()
+}
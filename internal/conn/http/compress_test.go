@@ -0,0 +1,207 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/arn-sdk/internal/build"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompress returns the content of b, which was compressed with the Content-Encoding enc.
+func decompress(enc string, b []byte) ([]byte, error) {
+	var r io.Reader
+	var err error
+	switch Compression(enc) {
+	case Deflate:
+		r, err = zlib.NewReader(bytes.NewReader(b))
+	case Gzip:
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	case Zstd:
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(b))
+		if err == nil {
+			defer d.Close()
+		}
+		r = d
+	default:
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestNewCompressor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		alg     Compression
+		level   int
+		wantErr bool
+	}{
+		{name: "Deflate default level", alg: Deflate},
+		{name: "Deflate best", alg: Deflate, level: 9},
+		{name: "Deflate huffman only", alg: Deflate, level: -2},
+		{name: "Gzip default level", alg: Gzip},
+		{name: "Gzip fastest", alg: Gzip, level: 1},
+		{name: "Zstd default level", alg: Zstd},
+		{name: "Zstd best", alg: Zstd, level: 22},
+		{name: "Error: Deflate level too high", alg: Deflate, level: 10, wantErr: true},
+		{name: "Error: Gzip level too low", alg: Gzip, level: -3, wantErr: true},
+		{name: "Error: Zstd level too low", alg: Zstd, level: -1, wantErr: true},
+		{name: "Error: Zstd level too high", alg: Zstd, level: 23, wantErr: true},
+		{name: "Error: unknown algorithm", alg: "br", wantErr: true},
+	}
+
+	big := bytes.Repeat([]byte(`{"resourceId": "/subscriptions/sub/resourceGroups/rg"}`), 10000)
+	small := []byte(`{"id": 1}`)
+
+	for _, test := range tests {
+		c, err := newCompressor(test.alg, test.level)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestNewCompressor(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestNewCompressor(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		// The encoders are reused, so each body must come back without anything from the one before it.
+		for i, want := range [][]byte{big, small, big, small} {
+			b, err := c.compress(bytes.NewReader(want), int64(len(want)))
+			if err != nil {
+				t.Fatalf("TestNewCompressor(%s): compress(%d): got err == %s, want err == nil", test.name, i, err)
+			}
+			got, err := decompress(string(test.alg), b)
+			if err != nil {
+				t.Fatalf("TestNewCompressor(%s): decompress(%d): got err == %s, want err == nil", test.name, i, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("TestNewCompressor(%s): body %d: got %d bytes back, want %d", test.name, i, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestCompressTransport(t *testing.T) {
+	t.Parallel()
+
+	var gotEnc string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEnc = r.Header.Get("Content-Encoding")
+		b, err := io.ReadAll(r.Body)
+		if err == nil {
+			b, err = decompress(gotEnc, b)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gotBody = b
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		alg     Compression
+		minSize int64
+		body    []byte
+		wantEnc string
+	}{
+		{name: "Deflate", alg: Deflate, body: []byte(`{"id": 1}`), wantEnc: "deflate"},
+		{name: "Gzip", alg: Gzip, body: []byte(`{"id": 1}`), wantEnc: "gzip"},
+		{name: "Zstd", alg: Zstd, body: []byte(`{"id": 1}`), wantEnc: "zstd"},
+		{name: "Below the threshold", alg: Zstd, minSize: 10, body: []byte(`{"id": 1}`)},
+		{name: "At the threshold", alg: Gzip, minSize: 9, body: []byte(`{"id": 1}`), wantEnc: "gzip"},
+	}
+
+	for _, test := range tests {
+		plOpts := runtime.PipelineOptions{PerRetry: []policy.Policy{newCompressTransport(mustCompressor(test.alg, 0), test.minSize)}}
+		azclient, err := azcore.NewClient("arn.Client", build.Version, plOpts, &policy.ClientOptions{Transport: srv.Client()})
+		if err != nil {
+			t.Fatalf("TestCompressTransport(%s): azcore.NewClient(): %v", test.name, err)
+		}
+		req, err := runtime.NewRequest(context.Background(), http.MethodPost, srv.URL)
+		if err != nil {
+			t.Fatalf("TestCompressTransport(%s): runtime.NewRequest(): %v", test.name, err)
+		}
+		if err := req.SetBody(rsc{bytes.NewReader(test.body)}, "application/json"); err != nil {
+			t.Fatalf("TestCompressTransport(%s): SetBody(): %v", test.name, err)
+		}
+		resp, err := azclient.Pipeline().Do(req)
+		if err != nil {
+			t.Fatalf("TestCompressTransport(%s): got err == %s, want err == nil", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("TestCompressTransport(%s): got status %d, want 200", test.name, resp.StatusCode)
+			continue
+		}
+		if gotEnc != test.wantEnc {
+			t.Errorf("TestCompressTransport(%s): got Content-Encoding %q, want %q", test.name, gotEnc, test.wantEnc)
+		}
+		if !bytes.Equal(gotBody, test.body) {
+			t.Errorf("TestCompressTransport(%s): got body %q, want %q", test.name, gotBody, test.body)
+		}
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	t.Parallel()
+
+	cred := struct{ azcore.TokenCredential }{}
+
+	tests := []struct {
+		name         string
+		options      []Option
+		wantCompress bool
+		wantAlg      Compression
+		wantMin      int64
+		wantErr      bool
+	}{
+		{name: "Defaults", wantCompress: true, wantAlg: Deflate},
+		{name: "Zstd", options: []Option{WithCompression(Zstd, 0)}, wantCompress: true, wantAlg: Zstd},
+		{name: "Threshold", options: []Option{WithCompressionThreshold(1024)}, wantCompress: true, wantAlg: Deflate, wantMin: 1024},
+		{
+			name:         "WithCompression after WithoutCompression",
+			options:      []Option{WithoutCompression(), WithCompression(Gzip, 9)},
+			wantCompress: true,
+			wantAlg:      Gzip,
+		},
+		{name: "Error: invalid level", options: []Option{WithCompression(Gzip, 10)}, wantErr: true},
+		{name: "Error: negative threshold", options: []Option{WithCompressionThreshold(-1)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		c, err := New("http://localhost:8080", cred, nil, test.options...)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithCompression(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithCompression(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if c.compress != test.wantCompress || c.compressor.alg != test.wantAlg || c.minCompress != test.wantMin {
+			t.Errorf("TestWithCompression(%s): got compress %v, alg %s, threshold %d, want %v, %s, %d", test.name, c.compress, c.compressor.alg, c.minCompress, test.wantCompress, test.wantAlg, test.wantMin)
+		}
+	}
+}
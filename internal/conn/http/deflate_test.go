@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"compress/zlib"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -105,7 +110,7 @@ func TestDeflate(t *testing.T) {
 
 	plOpts := runtime.PipelineOptions{
 		PerRetry: []policy.Policy{
-			newFlateTransport(),
+			newCompressTransport(defaultCompressor, 0),
 		},
 	}
 	azclient, err := azcore.NewClient("arn.Client", build.Version, plOpts, &policy.ClientOptions{})
@@ -169,3 +174,151 @@ func TestDeflate(t *testing.T) {
 		}
 	}
 }
+
+// errReader fails after returning part of a body.
+type errReader struct {
+	sent bool
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, errors.New("read error")
+	}
+	r.sent = true
+	return copy(p, "partial body"), nil
+}
+
+func inflate(t *testing.T, b []byte) []byte {
+	t.Helper()
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("zlib.NewReader(): %v", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("inflate: %v", err)
+	}
+	return out
+}
+
+func TestDeflateWriterReuse(t *testing.T) {
+	t.Parallel()
+
+	big := bytes.Repeat([]byte(`{"resourceId": "/subscriptions/sub/resourceGroups/rg"}`), 10000)
+	small := []byte(`{"id": 1}`)
+
+	// A failed body must not leave anything behind for the next request.
+	if _, err := defaultCompressor.compress(&errReader{}, 100); err == nil {
+		t.Errorf("TestDeflateWriterReuse: compress(errReader): got err == nil, want err != nil")
+	}
+
+	for i, want := range [][]byte{big, small, big, small} {
+		got, err := defaultCompressor.compress(bytes.NewReader(want), int64(len(want)))
+		if err != nil {
+			t.Fatalf("TestDeflateWriterReuse(%d): got err == %s, want err == nil", i, err)
+		}
+		if out := inflate(t, got); !bytes.Equal(out, want) {
+			t.Errorf("TestDeflateWriterReuse(%d): got %d bytes back, want %d", i, len(out), len(want))
+		}
+	}
+}
+
+// checkGetBody fails a request if GetBody does not give back the compressed body, as net/http uses it to resend
+// a request.
+type checkGetBody struct{}
+
+func (checkGetBody) Do(req *policy.Request) (*http.Response, error) {
+	body, err := req.Raw().GetBody()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("GetBody(): %w", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("GetBody(): %w", err)
+	}
+	sum := sha256.Sum256(b)
+	if hex.EncodeToString(sum[:]) != req.Raw().Header.Get("X-Sum") {
+		return nil, errors.New("GetBody() did not return the compressed body")
+	}
+	return req.Next()
+}
+
+func TestDeflateConcurrent(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != ContentEncoding {
+			http.Error(w, "not deflated", http.StatusBadRequest)
+			return
+		}
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256(b)
+		if hex.EncodeToString(sum[:]) != r.Header.Get("X-Sum") {
+			http.Error(w, "body does not match", http.StatusBadRequest)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	plOpts := runtime.PipelineOptions{PerRetry: []policy.Policy{newCompressTransport(defaultCompressor, 0), checkGetBody{}}}
+	azclient, err := azcore.NewClient("arn.Client", build.Version, plOpts, &policy.ClientOptions{Transport: srv.Client()})
+	if err != nil {
+		t.Fatalf("TestDeflateConcurrent: azcore.NewClient(): %v", err)
+	}
+
+	const requests = 200
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each body has its own size and content, so a writer or buffer shared by two requests shows up
+			// as a body that does not match its sum.
+			b := bytes.Repeat([]byte(fmt.Sprintf(`{"num": %d}`, i)), 1+(i*37)%500)
+			sum := sha256.Sum256(b)
+
+			req, err := runtime.NewRequest(context.Background(), http.MethodPost, srv.URL)
+			if err != nil {
+				errs <- err
+				return
+			}
+			req.Raw().Header.Set("X-Sum", hex.EncodeToString(sum[:]))
+			if err := req.SetBody(rsc{bytes.NewReader(b)}, "application/json"); err != nil {
+				errs <- err
+				return
+			}
+			resp, err := azclient.Pipeline().Do(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				msg, _ := io.ReadAll(resp.Body)
+				errs <- fmt.Errorf("request %d: got status %d: %s", i, resp.StatusCode, msg)
+				return
+			}
+
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("TestDeflateConcurrent: %s", err)
+	}
+}
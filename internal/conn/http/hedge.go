@@ -0,0 +1,238 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgeOptions configures hedged sends. When a request to the ARN receiver has not completed within
+// a threshold, a second identical request is sent and the first success is used. The event sent is
+// identical in both requests (including the event ID), which makes the send idempotent on the receiver.
+type HedgeOptions struct {
+	// Delay is a fixed time to wait before sending the hedged request. If zero, the delay is the
+	// P99 latency of recent successful sends.
+	Delay time.Duration `json:"delay,omitzero" yaml:"delay,omitempty"`
+	// MinDelay is the smallest delay that will be used when it is computed from the P99 latency.
+	// Defaults to 50ms.
+	MinDelay time.Duration `json:"minDelay,omitzero" yaml:"minDelay,omitempty"`
+	// MinSamples is the number of successful sends required before a P99 delay is computed. No hedging
+	// is done before then. Defaults to 100.
+	MinSamples int `json:"minSamples,omitzero" yaml:"minSamples,omitempty"`
+	// MaxInflight is a strict cap on the number of hedged requests that can be in progress at one time.
+	// Once reached, sends wait on their original request. Defaults to 5.
+	MaxInflight int `json:"maxInflight,omitzero" yaml:"maxInflight,omitempty"`
+	// OnHedge is called whenever a hedged request is sent. won is true if the hedged request is
+	// the one that succeeded. This is used to record metrics.
+	OnHedge func(ctx context.Context, won bool) `json:"-" yaml:"-"`
+}
+
+func (h HedgeOptions) defaults() HedgeOptions {
+	if h.MinDelay == 0 {
+		h.MinDelay = 50 * time.Millisecond
+	}
+	if h.MinSamples == 0 {
+		h.MinSamples = 100
+	}
+	if h.MaxInflight == 0 {
+		h.MaxInflight = 5
+	}
+	return h
+}
+
+func (h HedgeOptions) validate() error {
+	if h.Delay < 0 {
+		return fmt.Errorf("Delay cannot be negative")
+	}
+	if h.MinDelay < 0 {
+		return fmt.Errorf("MinDelay cannot be negative")
+	}
+	if h.MinSamples < 0 {
+		return fmt.Errorf("MinSamples cannot be negative")
+	}
+	if h.MinSamples > sampleSize {
+		return fmt.Errorf("MinSamples cannot be more than %d", sampleSize)
+	}
+	if h.MaxInflight < 0 {
+		return fmt.Errorf("MaxInflight cannot be negative")
+	}
+	return nil
+}
+
+// WithHedging turns on hedged sends to the ARN receiver.
+func WithHedging(ho HedgeOptions) Option {
+	return func(c *Client) error {
+		ho = ho.defaults()
+		if err := ho.validate(); err != nil {
+			return err
+		}
+		c.hedge = newHedger(ho)
+		return nil
+	}
+}
+
+const (
+	// sampleSize is the number of latency samples kept to compute the P99.
+	sampleSize = 1000
+	// recomputeEvery is how many samples are recorded before the P99 is recomputed.
+	recomputeEvery = 50
+)
+
+// hedger sends hedged requests.
+type hedger struct {
+	opts     HedgeOptions
+	inflight atomic.Int32
+
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	recorded int
+
+	// p99 is the current P99 latency in nanoseconds. 0 means there aren't enough samples.
+	p99 atomic.Int64
+}
+
+func newHedger(opts HedgeOptions) *hedger {
+	return &hedger{
+		opts:    opts,
+		samples: make([]time.Duration, 0, sampleSize),
+	}
+}
+
+type hedgeResult struct {
+	err   error
+	hedge bool
+}
+
+// do calls fn and, if fn has not returned before the hedge delay, calls fn a second time. It returns
+// on the first success or once both calls have failed, in which case it returns the first error.
+// The ctx passed to fn is cancelled once do returns.
+func (h *hedger) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+
+	delay := h.delay()
+	if delay <= 0 {
+		err := fn(ctx)
+		if err == nil {
+			h.record(time.Since(start))
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so that the loser never blocks after we return.
+	results := make(chan hedgeResult, 2)
+	go func() {
+		results <- hedgeResult{err: fn(ctx)}
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		if r.err == nil {
+			h.record(time.Since(start))
+		}
+		return r.err
+	case <-timer.C:
+	}
+
+	if !h.acquire() {
+		r := <-results
+		if r.err == nil {
+			h.record(time.Since(start))
+		}
+		return r.err
+	}
+	defer h.release()
+
+	go func() {
+		results <- hedgeResult{err: fn(ctx), hedge: true}
+	}()
+
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err == nil {
+			h.record(time.Since(start))
+			h.onHedge(r.hedge)
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = r.err
+		}
+	}
+	h.onHedge(false)
+	return firstErr
+}
+
+func (h *hedger) onHedge(won bool) {
+	if h.opts.OnHedge != nil {
+		h.opts.OnHedge(context.Background(), won)
+	}
+}
+
+// acquire tries to reserve a hedged request slot. It returns false if MaxInflight has been reached.
+func (h *hedger) acquire() bool {
+	for {
+		cur := h.inflight.Load()
+		if int(cur) >= h.opts.MaxInflight {
+			return false
+		}
+		if h.inflight.CompareAndSwap(cur, cur+1) {
+			return true
+		}
+	}
+}
+
+// release releases a hedged request slot.
+func (h *hedger) release() {
+	h.inflight.Add(-1)
+}
+
+// delay returns how long to wait before sending a hedged request. 0 means no hedged request should be sent.
+func (h *hedger) delay() time.Duration {
+	if h.opts.Delay > 0 {
+		return h.opts.Delay
+	}
+	p99 := time.Duration(h.p99.Load())
+	if p99 == 0 {
+		return 0
+	}
+	if p99 < h.opts.MinDelay {
+		return h.opts.MinDelay
+	}
+	return p99
+}
+
+// record records the latency of a successful send and recomputes the P99 periodically.
+func (h *hedger) record(d time.Duration) {
+	if h.opts.Delay > 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < sampleSize {
+		h.samples = append(h.samples, d)
+	} else {
+		h.samples[h.next] = d
+	}
+	h.next = (h.next + 1) % sampleSize
+	h.recorded++
+
+	if len(h.samples) < h.opts.MinSamples || h.recorded%recomputeEvery != 0 {
+		return
+	}
+
+	sorted := slices.Clone(h.samples)
+	slices.Sort(sorted)
+	h.p99.Store(int64(sorted[len(sorted)*99/100]))
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgerDo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		opts        HedgeOptions
+		inflight    int32
+		fn          func(call int32) func(ctx context.Context) error
+		wantErr     bool
+		wantCalls   int32
+		wantHedges  int
+		wantHedgeOK bool
+	}{
+		{
+			name: "Original finishes before delay",
+			opts: HedgeOptions{Delay: time.Second},
+			fn: func(call int32) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return nil }
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "Hedge wins",
+			opts: HedgeOptions{Delay: 10 * time.Millisecond},
+			fn: func(call int32) func(ctx context.Context) error {
+				if call == 1 {
+					return func(ctx context.Context) error {
+						<-ctx.Done()
+						return ctx.Err()
+					}
+				}
+				return func(ctx context.Context) error { return nil }
+			},
+			wantCalls:   2,
+			wantHedges:  1,
+			wantHedgeOK: true,
+		},
+		{
+			name:     "MaxInflight reached, no hedge",
+			opts:     HedgeOptions{Delay: 10 * time.Millisecond},
+			inflight: 5,
+			fn: func(call int32) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					time.Sleep(30 * time.Millisecond)
+					return nil
+				}
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "Error: both fail",
+			opts: HedgeOptions{Delay: 10 * time.Millisecond},
+			fn: func(call int32) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					time.Sleep(20 * time.Millisecond)
+					return errors.New("error")
+				}
+			},
+			wantErr:    true,
+			wantCalls:  2,
+			wantHedges: 1,
+		},
+	}
+
+	for _, test := range tests {
+		var hedges int
+		var hedgeOK bool
+		opts := test.opts
+		opts.OnHedge = func(ctx context.Context, won bool) {
+			hedges++
+			hedgeOK = won
+		}
+		h := newHedger(opts.defaults())
+		h.inflight.Store(test.inflight)
+
+		var calls atomic.Int32
+		err := h.do(context.Background(), func(ctx context.Context) error {
+			return test.fn(calls.Add(1))(ctx)
+		})
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestHedgerDo(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestHedgerDo(%s): got err == %v, want err == nil", test.name, err)
+			continue
+		}
+
+		if calls.Load() != test.wantCalls {
+			t.Errorf("TestHedgerDo(%s): calls: got %d, want %d", test.name, calls.Load(), test.wantCalls)
+		}
+		if hedges != test.wantHedges {
+			t.Errorf("TestHedgerDo(%s): OnHedge calls: got %d, want %d", test.name, hedges, test.wantHedges)
+		}
+		if hedgeOK != test.wantHedgeOK {
+			t.Errorf("TestHedgerDo(%s): hedge won: got %v, want %v", test.name, hedgeOK, test.wantHedgeOK)
+		}
+		if h.inflight.Load() != test.inflight {
+			t.Errorf("TestHedgerDo(%s): inflight: got %d, want %d", test.name, h.inflight.Load(), test.inflight)
+		}
+	}
+}
+
+func TestHedgerDelay(t *testing.T) {
+	t.Parallel()
+
+	h := newHedger(HedgeOptions{}.defaults())
+
+	for i := 0; i < 99; i++ {
+		h.record(100 * time.Millisecond)
+	}
+	if h.delay() != 0 {
+		t.Fatalf("TestHedgerDelay(too few samples): got %v, want 0", h.delay())
+	}
+
+	h.record(time.Second)
+	if h.delay() != time.Second {
+		t.Errorf("TestHedgerDelay(P99): got %v, want %v", h.delay(), time.Second)
+	}
+
+	h = newHedger(HedgeOptions{}.defaults())
+	for i := 0; i < 100; i++ {
+		h.record(time.Millisecond)
+	}
+	if h.delay() != 50*time.Millisecond {
+		t.Errorf("TestHedgerDelay(MinDelay): got %v, want %v", h.delay(), 50*time.Millisecond)
+	}
+}
@@ -4,16 +4,20 @@ package http
 
 import (
 	"bytes"
-	"compress/zlib"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"path"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Azure/arn-sdk/internal/build"
+	"github.com/Azure/arn-sdk/models"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
@@ -36,11 +40,40 @@ const (
 	allOthers    = "api://41fc9deb-1ccc-4fcc-871d-12bf54ad8986//.default"
 )
 
-// Note: The SDK does not seem to have anything for DogfoodProd or DogfoodPPE.
-var changeScope = map[string]bool{
-	cloud.AzureChina.ActiveDirectoryAuthorityHost:      true,
-	cloud.AzureGovernment.ActiveDirectoryAuthorityHost: true,
-	cloud.AzurePublic.ActiveDirectoryAuthorityHost:     true,
+// ReceiverScope is the scope used to authenticate to the ARN receiver API in all the environments listed above.
+const ReceiverScope = allOthers
+
+// PPEAuthorityHost is the AAD authority of the PPE cloud used by DogfoodPPE. The SDK does not have a
+// cloud.Configuration for it. DogfoodProd uses the public cloud authority.
+const PPEAuthorityHost = "https://login.windows-ppe.net/"
+
+// cloudScopes maps the AAD authority host of each known cloud to the scope used for the ARN receiver.
+// Authorities that are not listed use scopeDefault. New or changed clouds can be handled without a
+// release with WithScopeOverrides().
+var cloudScopes = map[string]string{
+	cloud.AzureChina.ActiveDirectoryAuthorityHost:      allOthers,
+	cloud.AzureGovernment.ActiveDirectoryAuthorityHost: allOthers,
+	cloud.AzurePublic.ActiveDirectoryAuthorityHost:     allOthers,
+	PPEAuthorityHost: allOthers,
+}
+
+// normAuthority normalizes an AAD authority host so that "https://login.microsoftonline.com" and
+// "https://LOGIN.microsoftonline.com/" are the same key.
+func normAuthority(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), "/") + "/"
+}
+
+// scopeFor returns the scope for the AAD authority host. overrides, keyed by normalized authority,
+// are checked before cloudScopes.
+func scopeFor(host string, overrides map[string]string) string {
+	host = normAuthority(host)
+	if s, ok := overrides[host]; ok {
+		return s
+	}
+	if s, ok := cloudScopes[host]; ok {
+		return s
+	}
+	return scopeDefault
 }
 
 var readerPool = sync.Pool{
@@ -49,95 +82,135 @@ var readerPool = sync.Pool{
 	},
 }
 
-var flatePool = sync.Pool{
-	New: func() any {
-		return &bytes.Buffer{}
-	},
-}
+// Client is a client for interacting with the ARN receiver API.
+type Client struct {
+	// endpoint is the URL of the receiver API. It can be replaced by SetEndpoint().
+	endpoint atomic.Pointer[string]
+	client   atomic.Pointer[azcore.Client]
+	opts     *policy.ClientOptions
+	scope    string
+	// scopeOverrides are keyed by normalized authority host.
+	scopeOverrides map[string]string
+	rcvPath        string
+	connOpts       ConnOptions
+	hedge          *hedger
+	compress       bool
+	// compressor compresses request bodies when compress is set, defaultCompressor if WithCompression()
+	// is not used. minCompress is the smallest body that is compressed.
+	compressor  *compressor
+	minCompress int64
+	// clockOffset is how far the receiver's clock is ahead of ours in nanoseconds, from the Date header of
+	// its last response. hasClock is set once it is known.
+	clockOffset atomic.Int64
+	hasClock    atomic.Bool
+
+	// sender replaces the azcore pipeline when set, see WithSender().
+	sender models.EventSender
 
-// zlibTransport is a custom RoundTripper that applies Deflate compression at the desired level.
-type zlibTransport struct {
-	deflateLevel int
-	flatePool    chan *zlib.Writer
+	fakeSender Sender
 }
 
-func newFlateTransport() *zlibTransport {
-	return &zlibTransport{
-		flatePool: make(chan *zlib.Writer, 20),
+// Option is a function that configures the client.
+type Option func(*Client) error
+
+// WithoutCompression turns off compression for the client.
+func WithoutCompression() Option {
+	return func(c *Client) error {
+		c.compress = false
+		return nil
 	}
 }
 
-// Do performs the actual request and compresses the body using Deflate.
-func (t *zlibTransport) Do(req *policy.Request) (*http.Response, error) {
-	// Get the underlying http.Request
-	httpReq := req.Raw()
-
-	// If the request has a body, apply Deflate compression.
-	if httpReq.Body != nil && httpReq.ContentLength > 0 {
-		// Read the original body content.
-		var buf bytes.Buffer
-		_, err := io.Copy(&buf, httpReq.Body)
+// WithCompression compresses request bodies with alg at level instead of with Deflate at the default level.
+// A level of 0 is the default level of alg. Deflate and Gzip take the levels of compress/flate, from -2
+// (flate.HuffmanOnly) to 9. Zstd takes the levels of the zstd command, from 1 to 22. The ARN receiver must
+// accept alg as a Content-Encoding, or it rejects every request.
+func WithCompression(alg Compression, level int) Option {
+	return func(c *Client) error {
+		comp, err := newCompressor(alg, level)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		c.compress = true
+		c.compressor = comp
+		return nil
+	}
+}
 
-		// Compress the content using Deflate at the specified level.
-		compressedBuffer := flatePool.Get().(*bytes.Buffer)
-		defer func() {
-			compressedBuffer.Reset()
-			flatePool.Put(compressedBuffer)
-		}()
-
-		var writer *zlib.Writer
-		select {
-		case writer = <-t.flatePool:
-		default:
-			writer, err = zlib.NewWriterLevel(compressedBuffer, 5)
-			if err != nil {
-				return nil, err
-			}
-		}
-		writer.Reset(compressedBuffer)
-		_, err = writer.Write(buf.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		writer.Close()
-		select {
-		case t.flatePool <- writer:
-		default:
+// WithCompressionThreshold sends request bodies smaller than n bytes without compressing them. Compressing
+// a small body saves little and can make it larger. Defaults to 0, which compresses every body.
+func WithCompressionThreshold(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("compression threshold cannot be negative")
 		}
-
-		// Update the request with the compressed body.
-		httpReq.Body = io.NopCloser(compressedBuffer)
-		httpReq.ContentLength = int64(compressedBuffer.Len())
-		httpReq.Header.Set("Content-Encoding", "deflate")
+		c.minCompress = int64(n)
+		return nil
 	}
-
-	// Use the base RoundTripper to perform the actual request.
-	return req.Next()
 }
 
-// Client is a client for interacting with the ARN receiver API.
-type Client struct {
-	endpoint string
-	client   *azcore.Client
-	compress bool
+// WithScope sets the scope used to get tokens for the ARN receiver API. By default the scope is
+// chosen based on the cloud set in the policy.ClientOptions.
+func WithScope(scope string) Option {
+	return func(c *Client) error {
+		if scope == "" {
+			return fmt.Errorf("scope cannot be empty")
+		}
+		c.scope = scope
+		return nil
+	}
+}
 
-	fakeSender Sender
+// WithScopeOverrides sets the scope used for the ARN receiver API by the AAD authority host of the cloud set
+// in the policy.ClientOptions, like {"https://login.microsoftonline.com/": "api://.../.default"}. These are
+// checked before the built-in scopes for the known clouds, which lets a new or changed cloud be configured
+// without a new release. WithScope() takes precedence over this. If called more than once, the overrides
+// are merged and later calls win.
+func WithScopeOverrides(overrides map[string]string) Option {
+	return func(c *Client) error {
+		if c.scopeOverrides == nil {
+			c.scopeOverrides = make(map[string]string, len(overrides))
+		}
+		for host, scope := range overrides {
+			if host == "" {
+				return fmt.Errorf("scope override authority cannot be empty")
+			}
+			if scope == "" {
+				return fmt.Errorf("scope override for authority(%s) cannot be empty", host)
+			}
+			c.scopeOverrides[normAuthority(host)] = scope
+		}
+		return nil
+	}
 }
 
-// Option is a function that configures the client.
-type Option func(*Client) error
+// DefaultReceiverPath is the path of the ARN receiver API that is added to the endpoint.
+const DefaultReceiverPath = "/arnnotify"
 
-// WihtoutCompression turns off deflate compression for the client.
-func WithoutCompression() Option {
+// WithReceiverPath sets the path of the ARN receiver API that is added to the endpoint if the endpoint does
+// not already end with it. By default this is DefaultReceiverPath.
+func WithReceiverPath(p string) Option {
 	return func(c *Client) error {
-		c.compress = false
+		p = strings.Trim(p, "/")
+		if p == "" {
+			return fmt.Errorf("receiver path cannot be empty")
+		}
+		c.rcvPath = "/" + p
 		return nil
 	}
 }
 
+// SetEndpoint replaces the ARN endpoint. The receiver path is added as it is in New(). Requests that are
+// in-flight finish with the old endpoint. Thread-safe.
+func (c *Client) SetEndpoint(endpoint string) error {
+	u, err := receiverURL(endpoint, c.rcvPath)
+	if err != nil {
+		return err
+	}
+	c.endpoint.Store(&u)
+	return nil
+}
+
 // Sender is an interface to provide a fake sender for testing.
 type Sender interface {
 	Send(ctx context.Context, event []byte) error
@@ -155,16 +228,36 @@ func WithFake(s Sender) Option {
 	}
 }
 
+// WithSender sends each request with s instead of the azcore pipeline, for a transport the SDK does not
+// provide, such as a sidecar or a recorder. s owns the connection and authentication to the receiver, so the
+// endpoint, credential, policy.ClientOptions, scope, compression and connection options are not used and the
+// endpoint may be empty. Headers and hedging are still applied, and s should return an *azcore.ResponseError
+// for a response that is not 200 OK so the error is classified as it is for the SDK's pipeline.
+func WithSender(s models.EventSender) Option {
+	return func(c *Client) error {
+		if s == nil {
+			return fmt.Errorf("sender cannot be nil")
+		}
+		c.sender = s
+		return nil
+	}
+}
+
 // New returns a new Client for accessing the ARN receiver API.
 func New(endpoint string, cred azcore.TokenCredential, opts *policy.ClientOptions, options ...Option) (*Client, error) {
 	if opts == nil {
 		opts = &policy.ClientOptions{}
 	}
 
+	// All options are applied to this instance and it is the instance that is returned. Do not
+	// build a new Client at the end, it will drop any settings the options made.
 	c := &Client{
-		endpoint: endpoint,
-		compress: true,
+		opts:       opts,
+		rcvPath:    DefaultReceiverPath,
+		compress:   true,
+		compressor: defaultCompressor,
 	}
+	c.endpoint.Store(&endpoint)
 	for _, option := range options {
 		if err := option(c); err != nil {
 			return nil, err
@@ -174,34 +267,109 @@ func New(endpoint string, cred azcore.TokenCredential, opts *policy.ClientOption
 	if c.fakeSender != nil {
 		return c, nil
 	}
+	if c.sender != nil {
+		if endpoint == "" {
+			return c, nil
+		}
+		if err := c.SetEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	if !c.connOpts.IsZero() {
+		if opts.Transport != nil {
+			return nil, fmt.Errorf("cannot use WithConnOptions() when policy.ClientOptions.Transport is set")
+		}
+		o := *opts
+		o.Transport = c.connOpts.transport()
+		c.opts = &o
+	}
+
+	if c.scope == "" {
+		c.scope = scopeFor(opts.Cloud.ActiveDirectoryAuthorityHost, c.scopeOverrides)
+	}
+
+	if err := c.SetEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	azclient, err := newAzClient(cred, c.opts, c.scope, c.compression())
+	if err != nil {
+		return nil, err
+	}
+	c.client.Store(azclient)
+
+	return c, nil
+}
+
+// receiverURL returns the URL of the ARN receiver API for endpoint. If the endpoint path does not already
+// end with rcvPath, rcvPath is appended to it. Trailing slashes are removed and query strings are kept.
+func receiverURL(endpoint string, rcvPath string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("endpoint(%s) is not a valid URL: %w", endpoint, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("endpoint(%s) must have a scheme and host", endpoint)
+	}
+	if u.Fragment != "" {
+		return "", fmt.Errorf("endpoint(%s) cannot have a fragment", endpoint)
+	}
 
-	var scope = scopeDefault
-	if changeScope[opts.Cloud.ActiveDirectoryAuthorityHost] {
-		scope = allOthers
+	p := strings.TrimRight(u.Path, "/")
+	if !strings.HasSuffix(p, rcvPath) {
+		p += rcvPath
 	}
+	u.Path = p
+	u.RawPath = ""
 
+	return u.String(), nil
+}
+
+// compression returns the policy that compresses request bodies, or nil if compression is off.
+func (c *Client) compression() policy.Policy {
+	if !c.compress {
+		return nil
+	}
+	return newCompressTransport(c.compressor, c.minCompress)
+}
+
+// newAzClient creates the azcore.Client that is used to talk to the ARN receiver API. compress compresses
+// request bodies, nil if they are not compressed.
+func newAzClient(cred azcore.TokenCredential, opts *policy.ClientOptions, scope string, compress policy.Policy) (*azcore.Client, error) {
 	plOpts := runtime.PipelineOptions{
 		PerRetry: []policy.Policy{
 			runtime.NewBearerTokenPolicy(cred, []string{scope}, nil),
 		},
 	}
-	if c.compress {
-		plOpts.PerRetry = append(plOpts.PerRetry, newFlateTransport())
+	if compress != nil {
+		plOpts.PerRetry = append(plOpts.PerRetry, compress)
 	}
 
-	azclient, err := azcore.NewClient("arn.Client", build.Version, plOpts, opts)
-	if err != nil {
-		return nil, err
-	}
+	return azcore.NewClient("arn.Client", build.Version, plOpts, opts)
+}
 
-	if path.Dir(endpoint) != "arnnotify" {
-		endpoint = runtime.JoinPaths(endpoint, "/arnnotify")
+// UpdateCredential replaces the credential used to authenticate to the ARN receiver API.
+// Requests that are in-flight finish with the old credential, new requests use cred.
+// Thread-safe.
+func (c *Client) UpdateCredential(cred azcore.TokenCredential) error {
+	if c.fakeSender != nil {
+		return nil
+	}
+	if c.sender != nil {
+		return fmt.Errorf("cannot update the credential of a client with a custom Sender, it owns its authentication")
+	}
+	if cred == nil {
+		return fmt.Errorf("cred cannot be nil")
 	}
 
-	return &Client{
-		endpoint: endpoint,
-		client:   azclient,
-	}, nil
+	azclient, err := newAzClient(cred, c.opts, c.scope, c.compression())
+	if err != nil {
+		return err
+	}
+	c.client.Store(azclient)
+	return nil
 }
 
 // Send sends an event (converted to JSON bytes) to the ARN receiver API.
@@ -213,6 +381,22 @@ func (c *Client) Send(ctx context.Context, event []byte, headers []string) error
 		return fmt.Errorf("headers must be key-value pairs")
 	}
 
+	if c.hedge != nil {
+		// The losing request may still be running after we return, so it can't share
+		// the caller's headers, which may be reused.
+		headers = slices.Clone(headers)
+		return c.hedge.do(ctx, func(ctx context.Context) error {
+			return c.send(ctx, event, headers)
+		})
+	}
+	return c.send(ctx, event, headers)
+}
+
+// send sends a single request with the event to the ARN receiver API.
+func (c *Client) send(ctx context.Context, event []byte, headers []string) error {
+	if c.sender != nil {
+		return c.sender.Send(ctx, event, headers)
+	}
 	read := readerPool.Get().(*bytes.Reader)
 	read.Reset(event)
 	defer readerPool.Put(read)
@@ -223,16 +407,39 @@ func (c *Client) Send(ctx context.Context, event []byte, headers []string) error
 	}
 
 	// Send the event to the ARN service.
-	resp, err := c.client.Pipeline().Do(req)
+	resp, err := c.client.Load().Pipeline().Do(req)
 	if err != nil {
 		return err
 	}
+	c.recordClock(resp, time.Now())
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		// This is an *azcore.ResponseError, which holds the status code and the receiver's error text.
+		return runtime.NewResponseError(resp)
 	}
 	return nil
 }
 
+// recordClock records the offset of the receiver's clock from the Date header of resp, received at now.
+func (c *Client) recordClock(resp *http.Response, now time.Time) {
+	d, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// Date has a resolution of a second, so the receiver's time is on average half a second later.
+	c.clockOffset.Store(int64(d.Add(500 * time.Millisecond).Sub(now)))
+	c.hasClock.Store(true)
+}
+
+// ClockOffset returns how far the receiver's clock is ahead of the local clock, which is negative if it
+// is behind. This is measured from the Date header of the receiver's responses, so it is accurate to about
+// a second. ok is false if no response has been received yet. Thread-safe.
+func (c *Client) ClockOffset() (offset time.Duration, ok bool) {
+	if c == nil || !c.hasClock.Load() {
+		return 0, false
+	}
+	return time.Duration(c.clockOffset.Load()), true
+}
+
 // appJSON is the Accept header for application/json. Set as a package
 // variable to avoid allocations.
 var appJSON = []string{"application/json"}
@@ -245,7 +452,7 @@ func (c *Client) setup(ctx context.Context, event *bytes.Reader, headers []strin
 
 	r := rsc{event}
 
-	req, err := runtime.NewRequest(ctx, http.MethodPost, c.endpoint)
+	req, err := runtime.NewRequest(ctx, http.MethodPost, *c.endpoint.Load())
 	if err != nil {
 		return nil, err
 	}
@@ -4,12 +4,40 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/kylelemons/godebug/pretty"
 )
 
+func TestUpdateCredential(t *testing.T) {
+	t.Parallel()
+
+	c, err := New("http://localhost:8080", struct{ azcore.TokenCredential }{}, nil)
+	if err != nil {
+		t.Fatalf("TestUpdateCredential: New(): got err == %s, want err == nil", err)
+	}
+	old := c.client.Load()
+
+	if err := c.UpdateCredential(nil); err == nil {
+		t.Errorf("TestUpdateCredential(nil cred): got err == nil, want err != nil")
+	}
+	if c.client.Load() != old {
+		t.Errorf("TestUpdateCredential(nil cred): azcore.Client was replaced, want unchanged")
+	}
+
+	if err := c.UpdateCredential(struct{ azcore.TokenCredential }{}); err != nil {
+		t.Fatalf("TestUpdateCredential: got err == %s, want err == nil", err)
+	}
+	if c.client.Load() == old {
+		t.Errorf("TestUpdateCredential: azcore.Client was not replaced")
+	}
+}
+
 func TestSetup(t *testing.T) {
 	t.Parallel()
 
@@ -59,7 +87,7 @@ func TestSetup(t *testing.T) {
 
 	c := &Client{}
 	for _, test := range tests {
-		c.endpoint = test.endpoint
+		c.endpoint.Store(&test.endpoint)
 		req, err := c.setup(context.Background(), bytes.NewReader(test.body), test.headers)
 		switch {
 		case test.wantErr && err == nil:
@@ -98,3 +126,332 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestReceiverURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		rcvPath  string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Error: no scheme",
+			endpoint: "ms-containerservice.receiver.arn.core.windows.net",
+			rcvPath:  DefaultReceiverPath,
+			wantErr:  true,
+		},
+		{
+			name:    "Error: empty endpoint",
+			rcvPath: DefaultReceiverPath,
+			wantErr: true,
+		},
+		{
+			name:     "Error: has fragment",
+			endpoint: "https://ms-containerservice.receiver.arn.core.windows.net#frag",
+			rcvPath:  DefaultReceiverPath,
+			wantErr:  true,
+		},
+		{
+			name:     "Host only",
+			endpoint: "https://ms-containerservice.receiver.arn.core.windows.net",
+			rcvPath:  DefaultReceiverPath,
+			want:     "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify",
+		},
+		{
+			name:     "Host with trailing slash",
+			endpoint: "https://ms-containerservice.receiver.arn.core.windows.net/",
+			rcvPath:  DefaultReceiverPath,
+			want:     "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify",
+		},
+		{
+			name:     "Already has receiver path",
+			endpoint: "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify",
+			rcvPath:  DefaultReceiverPath,
+			want:     "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify",
+		},
+		{
+			name:     "Already has receiver path with trailing slash",
+			endpoint: "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify/",
+			rcvPath:  DefaultReceiverPath,
+			want:     "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify",
+		},
+		{
+			name:     "Query string is kept",
+			endpoint: "https://ms-containerservice.receiver.arn.core.windows.net/?api-version=2024-01-01",
+			rcvPath:  DefaultReceiverPath,
+			want:     "https://ms-containerservice.receiver.arn.core.windows.net/arnnotify?api-version=2024-01-01",
+		},
+		{
+			name:     "Pre-set path",
+			endpoint: "https://localhost:8080/proxy",
+			rcvPath:  DefaultReceiverPath,
+			want:     "https://localhost:8080/proxy/arnnotify",
+		},
+		{
+			name:     "Custom receiver path",
+			endpoint: "https://localhost:8080",
+			rcvPath:  "/custom/notify",
+			want:     "https://localhost:8080/custom/notify",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := receiverURL(test.endpoint, test.rcvPath)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestReceiverURL(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestReceiverURL(%s): got err == %v, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("TestReceiverURL(%s): got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+type fakeSender struct{}
+
+func (fakeSender) Send(ctx context.Context, event []byte) error {
+	return nil
+}
+
+func TestSetEndpoint(t *testing.T) {
+	t.Parallel()
+
+	c, err := New("https://receiver.arn.core.windows.net", struct{ azcore.TokenCredential }{}, nil)
+	if err != nil {
+		t.Fatalf("TestSetEndpoint: New(): got err == %s, want err == nil", err)
+	}
+
+	if err := c.SetEndpoint("https://other.arn.core.windows.net/"); err != nil {
+		t.Fatalf("TestSetEndpoint: got err == %s, want err == nil", err)
+	}
+	const want = "https://other.arn.core.windows.net" + DefaultReceiverPath
+	if got := *c.endpoint.Load(); got != want {
+		t.Errorf("TestSetEndpoint: got %s, want %s", got, want)
+	}
+
+	if err := c.SetEndpoint("not a url"); err == nil {
+		t.Errorf("TestSetEndpoint(invalid endpoint): got err == nil, want err != nil")
+	}
+	if got := *c.endpoint.Load(); got != want {
+		t.Errorf("TestSetEndpoint: an invalid endpoint replaced the endpoint with %s", got)
+	}
+}
+
+func TestNewKeepsOptions(t *testing.T) {
+	t.Parallel()
+
+	cred := struct{ azcore.TokenCredential }{}
+
+	tests := []struct {
+		name         string
+		options      []Option
+		wantCompress bool
+		wantFake     bool
+		wantScope    string
+		wantEndpoint string
+	}{
+		{
+			name:         "Defaults",
+			wantCompress: true,
+			wantScope:    scopeDefault,
+			wantEndpoint: "http://localhost:8080/arnnotify",
+		},
+		{
+			name:         "WithoutCompression",
+			options:      []Option{WithoutCompression()},
+			wantScope:    scopeDefault,
+			wantEndpoint: "http://localhost:8080/arnnotify",
+		},
+		{
+			name:         "WithScope and WithReceiverPath",
+			options:      []Option{WithScope(ReceiverScope), WithReceiverPath("custom")},
+			wantCompress: true,
+			wantScope:    ReceiverScope,
+			wantEndpoint: "http://localhost:8080/custom",
+		},
+		{
+			name:         "WithFake",
+			options:      []Option{WithFake(fakeSender{})},
+			wantCompress: true,
+			wantFake:     true,
+			wantEndpoint: "http://localhost:8080",
+		},
+	}
+
+	for _, test := range tests {
+		c, err := New("http://localhost:8080", cred, nil, test.options...)
+		if err != nil {
+			t.Errorf("TestNewKeepsOptions(%s): got err == %v, want err == nil", test.name, err)
+			continue
+		}
+
+		if c.compress != test.wantCompress {
+			t.Errorf("TestNewKeepsOptions(%s): compress: got %v, want %v", test.name, c.compress, test.wantCompress)
+		}
+		if (c.fakeSender != nil) != test.wantFake {
+			t.Errorf("TestNewKeepsOptions(%s): fakeSender set: got %v, want %v", test.name, c.fakeSender != nil, test.wantFake)
+		}
+		if c.scope != test.wantScope {
+			t.Errorf("TestNewKeepsOptions(%s): scope: got %s, want %s", test.name, c.scope, test.wantScope)
+		}
+		if got := *c.endpoint.Load(); got != test.wantEndpoint {
+			t.Errorf("TestNewKeepsOptions(%s): endpoint: got %s, want %s", test.name, got, test.wantEndpoint)
+		}
+		if !test.wantFake && c.client.Load() == nil {
+			t.Errorf("TestNewKeepsOptions(%s): azcore.Client was not set", test.name)
+		}
+	}
+}
+
+func TestScopeFor(t *testing.T) {
+	t.Parallel()
+
+	const custom = "api://custom//.default"
+
+	tests := []struct {
+		name      string
+		host      string
+		overrides []Option
+		want      string
+	}{
+		{name: "No cloud", want: scopeDefault},
+		{name: "Public", host: cloud.AzurePublic.ActiveDirectoryAuthorityHost, want: allOthers},
+		{name: "PPE without trailing slash", host: "https://login.windows-ppe.net", want: allOthers},
+		{name: "Unknown cloud", host: "https://login.example.com/", want: scopeDefault},
+		{
+			name:      "Override unknown cloud",
+			host:      "https://login.example.com/",
+			overrides: []Option{WithScopeOverrides(map[string]string{"https://LOGIN.example.com": custom})},
+			want:      custom,
+		},
+		{
+			name: "Later override wins",
+			host: cloud.AzurePublic.ActiveDirectoryAuthorityHost,
+			overrides: []Option{
+				WithScopeOverrides(map[string]string{cloud.AzurePublic.ActiveDirectoryAuthorityHost: scopeDefault}),
+				WithScopeOverrides(map[string]string{cloud.AzurePublic.ActiveDirectoryAuthorityHost: custom}),
+			},
+			want: custom,
+		},
+	}
+
+	for _, test := range tests {
+		c := &Client{}
+		for _, o := range test.overrides {
+			if err := o(c); err != nil {
+				t.Fatalf("TestScopeFor(%s): got err == %s, want err == nil", test.name, err)
+			}
+		}
+		if got := scopeFor(test.host, c.scopeOverrides); got != test.want {
+			t.Errorf("TestScopeFor(%s): got %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	if err := WithScopeOverrides(map[string]string{"https://login.example.com/": ""})(&Client{}); err == nil {
+		t.Errorf("TestScopeFor(empty scope): got err == nil, want err != nil")
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		date   string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "No Date header"},
+		{name: "Bad Date header", date: "yesterday"},
+		{name: "Receiver ahead", date: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute + 500*time.Millisecond, wantOK: true},
+		{name: "Receiver behind", date: now.Add(-time.Minute).Format(http.TimeFormat), want: -time.Minute + 500*time.Millisecond, wantOK: true},
+	}
+
+	for _, test := range tests {
+		c := &Client{}
+		resp := &http.Response{Header: http.Header{}}
+		if test.date != "" {
+			resp.Header.Set("Date", test.date)
+		}
+		c.recordClock(resp, now)
+
+		got, ok := c.ClockOffset()
+		if ok != test.wantOK || got != test.want {
+			t.Errorf("TestClockOffset(%s): got %v, %v, want %v, %v", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+
+	var nilClient *Client
+	if _, ok := nilClient.ClockOffset(); ok {
+		t.Errorf("TestClockOffset(nil client): got ok == true, want false")
+	}
+}
+
+type recordSender struct {
+	event   []byte
+	headers []string
+	err     error
+}
+
+func (r *recordSender) Send(ctx context.Context, event []byte, headers []string) error {
+	r.event = event
+	r.headers = headers
+	return r.err
+}
+
+func TestWithSender(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New("", nil, nil, WithSender(nil)); err == nil {
+		t.Errorf("TestWithSender(nil sender): got err == nil, want err != nil")
+	}
+
+	rs := &recordSender{}
+	// A custom Sender does not need an endpoint or a credential.
+	c, err := New("", nil, nil, WithSender(rs))
+	if err != nil {
+		t.Fatalf("TestWithSender: New(): got err == %s, want err == nil", err)
+	}
+	if c.client.Load() != nil {
+		t.Errorf("TestWithSender: New() created an azcore.Client, want none")
+	}
+
+	if err := c.Send(context.Background(), []byte("event"), []string{"k", "v"}); err != nil {
+		t.Fatalf("TestWithSender: Send(): got err == %s, want err == nil", err)
+	}
+	if string(rs.event) != "event" {
+		t.Errorf("TestWithSender: sender got event %q, want %q", rs.event, "event")
+	}
+	if diff := pretty.Compare([]string{"k", "v"}, rs.headers); diff != "" {
+		t.Errorf("TestWithSender: sender headers: -want/+got:\n%s", diff)
+	}
+
+	if err := c.Send(context.Background(), []byte("event"), []string{"k"}); err == nil {
+		t.Errorf("TestWithSender(odd headers): got err == nil, want err != nil")
+	}
+
+	rs.err = &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}
+	if err := c.Send(context.Background(), []byte("event"), nil); err != rs.err {
+		t.Errorf("TestWithSender(sender error): got err == %v, want %v", err, rs.err)
+	}
+
+	if err := c.UpdateCredential(struct{ azcore.TokenCredential }{}); err == nil {
+		t.Errorf("TestWithSender: UpdateCredential(): got err == nil, want err != nil")
+	}
+
+	if _, err := New("not a url", nil, nil, WithSender(rs)); err == nil {
+		t.Errorf("TestWithSender(invalid endpoint): got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// ConnOptions are options for tuning the connections to the ARN receiver. Some L4 load balancers in front
+// of ARN receivers silently drop long-lived idle connections, which shows up as a failure on the first send
+// after an idle period. The zero value uses the azcore defaults.
+type ConnOptions struct {
+	// DisableHTTP2 forces HTTP/1.1 to be used.
+	DisableHTTP2 bool `json:"disableHTTP2,omitzero" yaml:"disableHTTP2,omitempty"`
+	// KeepAlive is the interval between TCP keep-alive probes. If zero, the Go default is used.
+	// If negative, TCP keep-alives are disabled.
+	KeepAlive time.Duration `json:"keepAlive,omitzero" yaml:"keepAlive,omitempty"`
+	// IdleConnTimeout is the maximum amount of time an idle connection stays open. Set this lower than
+	// the idle timeout of any load balancer between you and the receiver. If zero, the Go default is used.
+	IdleConnTimeout time.Duration `json:"idleConnTimeout,omitzero" yaml:"idleConnTimeout,omitempty"`
+	// RecycleInterval causes the connections to be replaced at this interval: requests after it use new
+	// connections, and the old ones are closed once the requests still using them finish. This moves a busy
+	// connection, or an HTTP/2 connection that carries every request, off the backend it is pinned to. If
+	// zero, connections are not recycled.
+	RecycleInterval time.Duration `json:"recycleInterval,omitzero" yaml:"recycleInterval,omitempty"`
+}
+
+// IsZero returns true if no options are set.
+func (c ConnOptions) IsZero() bool {
+	return c == ConnOptions{}
+}
+
+func (c ConnOptions) validate() error {
+	if c.IdleConnTimeout < 0 {
+		return fmt.Errorf("IdleConnTimeout cannot be negative")
+	}
+	if c.RecycleInterval < 0 {
+		return fmt.Errorf("RecycleInterval cannot be negative")
+	}
+	return nil
+}
+
+// WithConnOptions sets options on the connections to the ARN receiver. This cannot be used if a
+// policy.ClientOptions.Transport is provided.
+func WithConnOptions(co ConnOptions) Option {
+	return func(c *Client) error {
+		if err := co.validate(); err != nil {
+			return err
+		}
+		c.connOpts = co
+		return nil
+	}
+}
+
+// transport returns a policy.Transporter that implements the ConnOptions.
+func (c ConnOptions) transport() *recycleTransport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: c.KeepAlive,
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dialer.DialContext
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		// A non-nil, empty map disables HTTP/2.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &recycleTransport{
+		now:      time.Now,
+		interval: c.RecycleInterval,
+		t:        t,
+		current:  newGeneration(t),
+	}
+}
+
+// Compile-time check to verify implements interface.
+var _ policy.Transporter = (*recycleTransport)(nil)
+
+// recycleTransport is a policy.Transporter that replaces its connections every interval. Each interval's
+// requests use a new http.Transport, a generation, cloned from t. A replaced generation's connections are
+// closed once the requests that were using it finish.
+type recycleTransport struct {
+	now      func() time.Time
+	interval time.Duration
+	// t is the template of each generation's http.Transport. It does not send requests.
+	t *http.Transport
+
+	mu       sync.Mutex
+	current  *generation
+	recycled time.Time
+}
+
+// generation is an http.Transport and the number of requests using it.
+type generation struct {
+	t      *http.Transport
+	client *http.Client
+
+	// inflight is the number of requests whose response bodies are not closed. retired is set when the
+	// generation is replaced. Both are protected by recycleTransport.mu.
+	inflight int
+	retired  bool
+}
+
+func newGeneration(t *http.Transport) *generation {
+	t = t.Clone()
+	return &generation{t: t, client: &http.Client{Transport: t}}
+}
+
+// Do implements policy.Transporter.Do().
+func (r *recycleTransport) Do(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.recycle()
+	g := r.current
+	g.inflight++
+	r.mu.Unlock()
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		r.done(g)
+		return nil, err
+	}
+	// The connection is in use until the body is closed.
+	resp.Body = &doneBody{ReadCloser: resp.Body, done: func() { r.done(g) }}
+	return resp, nil
+}
+
+// recycle replaces the current generation if interval has passed since it was created. Must be called with
+// r.mu held.
+func (r *recycleTransport) recycle() {
+	if r.interval <= 0 {
+		return
+	}
+
+	now := r.now()
+	if r.recycled.IsZero() {
+		r.recycled = now
+		return
+	}
+	if now.Sub(r.recycled) < r.interval {
+		return
+	}
+	r.recycled = now
+
+	old := r.current
+	r.current = newGeneration(r.t)
+	old.retired = true
+	if old.inflight == 0 {
+		old.t.CloseIdleConnections()
+	}
+}
+
+// done records that a request of g finished. The connections of a retired generation are closed once its
+// last request finishes.
+func (r *recycleTransport) done(g *generation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g.inflight--
+	if g.retired && g.inflight == 0 {
+		g.t.CloseIdleConnections()
+	}
+}
+
+// doneBody is a response body that calls done once when it is closed.
+type doneBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+// Close implements io.Closer.Close().
+func (b *doneBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
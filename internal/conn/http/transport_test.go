@@ -0,0 +1,205 @@
+package http
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnOptionsTransport(t *testing.T) {
+	t.Parallel()
+
+	co := ConnOptions{
+		DisableHTTP2:    true,
+		IdleConnTimeout: 30 * time.Second,
+	}
+	rt := co.transport()
+
+	if rt.t.ForceAttemptHTTP2 {
+		t.Errorf("TestConnOptionsTransport: ForceAttemptHTTP2: got true, want false")
+	}
+	if rt.t.TLSNextProto == nil {
+		t.Errorf("TestConnOptionsTransport: TLSNextProto: got nil, want empty map to disable HTTP/2")
+	}
+	if rt.t.IdleConnTimeout != 30*time.Second {
+		t.Errorf("TestConnOptionsTransport: IdleConnTimeout: got %v, want %v", rt.t.IdleConnTimeout, 30*time.Second)
+	}
+	if rt.current.t == rt.t {
+		t.Errorf("TestConnOptionsTransport: current generation uses the template transport, want a clone")
+	}
+	if rt.current.t.IdleConnTimeout != 30*time.Second {
+		t.Errorf("TestConnOptionsTransport: current IdleConnTimeout: got %v, want %v", rt.current.t.IdleConnTimeout, 30*time.Second)
+	}
+}
+
+func TestRecycle(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	rt := ConnOptions{RecycleInterval: time.Minute}.transport()
+	rt.now = func() time.Time { return now }
+
+	g := rt.current
+
+	rt.recycle()
+	if !rt.recycled.Equal(now) {
+		t.Fatalf("TestRecycle(first call): got recycled == %v, want %v", rt.recycled, now)
+	}
+	if rt.current != g {
+		t.Errorf("TestRecycle(first call): got a new generation, want the first one")
+	}
+
+	first := now
+	now = now.Add(30 * time.Second)
+	rt.recycle()
+	if !rt.recycled.Equal(first) {
+		t.Errorf("TestRecycle(before interval): got recycled == %v, want %v", rt.recycled, first)
+	}
+	if rt.current != g {
+		t.Errorf("TestRecycle(before interval): got a new generation, want the first one")
+	}
+
+	now = now.Add(31 * time.Second)
+	rt.recycle()
+	if !rt.recycled.Equal(now) {
+		t.Errorf("TestRecycle(after interval): got recycled == %v, want %v", rt.recycled, now)
+	}
+	if rt.current == g {
+		t.Errorf("TestRecycle(after interval): got the first generation, want a new one")
+	}
+	if !g.retired {
+		t.Errorf("TestRecycle(after interval): got retired == false for the first generation, want true")
+	}
+}
+
+// TestRecycleConns tests that a connection in use when the interval passes is closed once its request
+// finishes, and that requests after the interval use a new connection.
+func TestRecycleConns(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		opened int
+		closed int
+	)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch s {
+		case http.StateNew:
+			opened++
+		case http.StateClosed:
+			closed++
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	now := time.Now()
+	rt := ConnOptions{RecycleInterval: time.Minute}.transport()
+	rt.now = func() time.Time { return now }
+
+	get := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("TestRecycleConns: NewRequest: %v", err)
+		}
+		resp, err := rt.Do(req)
+		if err != nil {
+			t.Fatalf("TestRecycleConns: Do: %v", err)
+		}
+		return resp
+	}
+	conns := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return opened, closed
+	}
+	waitClosed := func(want int) int {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, c := conns()
+			if c >= want || time.Now().After(deadline) {
+				return c
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	resp := get()
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// The first connection is busy while the interval passes.
+	busy := get()
+	now = now.Add(2 * time.Minute)
+
+	resp = get()
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if o, c := conns(); o != 2 || c != 0 {
+		t.Errorf("TestRecycleConns(after interval): got opened == %d, closed == %d, want 2, 0", o, c)
+	}
+
+	io.ReadAll(busy.Body)
+	busy.Body.Close()
+	if c := waitClosed(1); c != 1 {
+		t.Errorf("TestRecycleConns(busy request finished): got closed == %d, want 1", c)
+	}
+	// Closing the body again must not count the request twice.
+	busy.Body.Close()
+	if g := rt.current; g.inflight != 0 {
+		t.Errorf("TestRecycleConns: got inflight == %d on the current generation, want 0", g.inflight)
+	}
+}
+
+func TestWithConnOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		co      ConnOptions
+		wantErr bool
+	}{
+		{
+			name:    "Error: negative IdleConnTimeout",
+			co:      ConnOptions{IdleConnTimeout: -1},
+			wantErr: true,
+		},
+		{
+			name:    "Error: negative RecycleInterval",
+			co:      ConnOptions{RecycleInterval: -1},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			co:   ConnOptions{DisableHTTP2: true, KeepAlive: 15 * time.Second, RecycleInterval: time.Minute},
+		},
+	}
+
+	for _, test := range tests {
+		c := &Client{}
+		err := WithConnOptions(test.co)(c)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithConnOptions(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithConnOptions(%s): got err == %v, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if c.connOpts != test.co {
+			t.Errorf("TestWithConnOptions(%s): got %+v, want %+v", test.name, c.connOpts, test.co)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Package itemsize carries the maximum size of each resource in a notification, as JSON. A single very large
+resource, such as a node with megabytes of labels, can push an otherwise small notification to blob storage
+or be rejected by consumers downstream.
+
+The conn package adds the limit to the context of each notification with WithMax(). The model's SendEvent()
+checks each resource against it once the resources are serialized, so they are not serialized again.
+*/
+package itemsize
+
+import "context"
+
+// DefaultMax is the default maximum size of a resource in bytes.
+const DefaultMax = 1 << 20
+
+type ctxKey struct{}
+
+// WithMax returns a context that holds max, the maximum size of a resource in bytes.
+func WithMax(ctx context.Context, max int) context.Context {
+	return context.WithValue(ctx, ctxKey{}, max)
+}
+
+// FromCtx returns the maximum size of a resource in ctx. ok is false if there is none.
+func FromCtx(ctx context.Context) (max int, ok bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	max, ok = ctx.Value(ctxKey{}).(int)
+	return max, ok
+}
@@ -0,0 +1,21 @@
+package itemsize
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromCtx(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := FromCtx(nil); ok {
+		t.Errorf("TestFromCtx(nil ctx): got ok == true, want false")
+	}
+	if _, ok := FromCtx(context.Background()); ok {
+		t.Errorf("TestFromCtx(no max): got ok == true, want false")
+	}
+	got, ok := FromCtx(WithMax(context.Background(), 100))
+	if !ok || got != 100 {
+		t.Errorf("TestFromCtx(max): got %d, %v, want 100, true", got, ok)
+	}
+}
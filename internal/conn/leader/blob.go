@@ -0,0 +1,116 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/lease"
+	"github.com/google/uuid"
+)
+
+// DefaultLeaseDuration is the default lease duration of a BlobLock.
+const DefaultLeaseDuration = 30 * time.Second
+
+// leaser is the part of *lease.BlobClient a BlobLock uses.
+type leaser interface {
+	AcquireLease(ctx context.Context, duration int32, o *lease.BlobAcquireOptions) (lease.BlobAcquireResponse, error)
+	ReleaseLease(ctx context.Context, o *lease.BlobReleaseOptions) (lease.BlobReleaseResponse, error)
+}
+
+// BlobLock is a Lock on the lease of a blob in Azure Blob Storage. The blob is created empty if it does not
+// exist. Each BlobLock has its own lease ID, so each replica must create its own.
+type BlobLock struct {
+	lease    leaser
+	create   func(ctx context.Context) error
+	duration int32
+
+	mu sync.Mutex
+}
+
+// NewBlobLock creates a BlobLock on the blob at blobURL, such as
+// "https://account.blob.core.windows.net/container/arn-leader". The container must exist. leaseDuration is
+// how long the lease lasts without being renewed, from 15 to 60 seconds. If 0, DefaultLeaseDuration is used.
+func NewBlobLock(blobURL string, cred azcore.TokenCredential, leaseDuration time.Duration) (*BlobLock, error) {
+	if cred == nil {
+		return nil, errors.New("cred cannot be nil")
+	}
+	bc, err := blockblob.NewClient(blobURL, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create a client for blob(%s): %w", blobURL, err)
+	}
+	return newBlobLock(bc, leaseDuration)
+}
+
+// newBlobLock creates a BlobLock on the blob of bc.
+func newBlobLock(bc *blockblob.Client, leaseDuration time.Duration) (*BlobLock, error) {
+	if leaseDuration == 0 {
+		leaseDuration = DefaultLeaseDuration
+	}
+	if leaseDuration < 15*time.Second || leaseDuration > 60*time.Second {
+		return nil, fmt.Errorf("lease duration(%v) must be from 15 to 60 seconds", leaseDuration)
+	}
+	lc, err := lease.NewBlobClient(bc, &lease.BlobClientOptions{LeaseID: to.Ptr(uuid.New().String())})
+	if err != nil {
+		return nil, err
+	}
+	return &BlobLock{
+		lease:    lc,
+		duration: int32(leaseDuration / time.Second),
+		create: func(ctx context.Context) error {
+			_, err := bc.Upload(ctx, streaming.NopCloser(bytes.NewReader(nil)), &blockblob.UploadOptions{
+				AccessConditions: &blob.AccessConditions{
+					ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+				},
+			})
+			// Another replica may have created it first.
+			if bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet) {
+				return nil
+			}
+			return err
+		},
+	}, nil
+}
+
+// Acquire implements Lock.Acquire(). Acquiring a lease with the ID of the active lease renews it, so the
+// same call both takes and renews the lock.
+func (b *BlobLock) Acquire(ctx context.Context) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err := b.lease.AcquireLease(ctx, b.duration, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		if err := b.create(ctx); err != nil {
+			return false, fmt.Errorf("could not create the lease blob: %w", err)
+		}
+		_, err = b.lease.AcquireLease(ctx, b.duration, nil)
+	}
+	switch {
+	case err == nil:
+		return true, nil
+	case bloberror.HasCode(err, bloberror.LeaseAlreadyPresent):
+		return false, nil
+	}
+	return false, err
+}
+
+// Release implements Lock.Release().
+func (b *BlobLock) Release(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err := b.lease.ReleaseLease(ctx, nil)
+	if bloberror.HasCode(err, bloberror.LeaseIDMismatchWithLeaseOperation, bloberror.LeaseNotPresentWithLeaseOperation) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/lease"
+)
+
+// fakeLeaser returns the errors in acquire in order, then nil.
+type fakeLeaser struct {
+	acquire    []error
+	releaseErr error
+}
+
+func (f *fakeLeaser) AcquireLease(ctx context.Context, duration int32, o *lease.BlobAcquireOptions) (lease.BlobAcquireResponse, error) {
+	if len(f.acquire) == 0 {
+		return lease.BlobAcquireResponse{}, nil
+	}
+	err := f.acquire[0]
+	f.acquire = f.acquire[1:]
+	return lease.BlobAcquireResponse{}, err
+}
+
+func (f *fakeLeaser) ReleaseLease(ctx context.Context, o *lease.BlobReleaseOptions) (lease.BlobReleaseResponse, error) {
+	return lease.BlobReleaseResponse{}, f.releaseErr
+}
+
+func codeErr(c bloberror.Code) error {
+	return &azcore.ResponseError{StatusCode: 409, ErrorCode: string(c)}
+}
+
+func TestBlobLock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		acquire     []error
+		createErr   error
+		wantHeld    bool
+		wantCreated bool
+		wantErr     bool
+	}{
+		{name: "Free", wantHeld: true},
+		{name: "Held by another instance", acquire: []error{codeErr(bloberror.LeaseAlreadyPresent)}},
+		{name: "Blob created", acquire: []error{codeErr(bloberror.BlobNotFound)}, wantHeld: true, wantCreated: true},
+		{
+			name:        "Error: blob cannot be created",
+			acquire:     []error{codeErr(bloberror.BlobNotFound)},
+			createErr:   errors.New("forbidden"),
+			wantCreated: true,
+			wantErr:     true,
+		},
+		{name: "Error: other error", acquire: []error{errors.New("connection reset")}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		created := false
+		b := &BlobLock{
+			lease:    &fakeLeaser{acquire: test.acquire},
+			duration: 30,
+			create: func(context.Context) error {
+				created = true
+				return test.createErr
+			},
+		}
+		held, err := b.Acquire(context.Background())
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestBlobLock(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestBlobLock(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if held != test.wantHeld {
+			t.Errorf("TestBlobLock(%s): got held == %v, want %v", test.name, held, test.wantHeld)
+		}
+		if created != test.wantCreated {
+			t.Errorf("TestBlobLock(%s): got created == %v, want %v", test.name, created, test.wantCreated)
+		}
+	}
+
+	b := &BlobLock{lease: &fakeLeaser{releaseErr: codeErr(bloberror.LeaseIDMismatchWithLeaseOperation)}}
+	if err := b.Release(context.Background()); err != nil {
+		t.Errorf("TestBlobLock(release a lease held by another instance): got err == %s, want err == nil", err)
+	}
+
+	if _, err := NewBlobLock("https://account.blob.core.windows.net/c/b", struct{ azcore.TokenCredential }{}, time.Second); err == nil {
+		t.Errorf("TestBlobLock(lease duration too short): got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Package leader elects a single instance among the replicas of a publisher to send notifications, for
+notifications that must be published by exactly one replica. Without it, every replica of an HA deployment
+sends the same events and ARN receives duplicates.
+
+An Elector holds a Lock, such as a lease on a blob (see BlobLock) or a Kubernetes Lease, and renews it on an
+interval. The conn package only sends notifications while its Elector is the leader. What happens to the
+notifications of a follower is set by Options.Follower.
+*/
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotLeader is the error of a queued notification that could not be sent because this instance did not
+// become the leader, either because the queue was full or the client was closed.
+var ErrNotLeader = errors.New("this instance is not the leader")
+
+// DefaultRenewInterval is the default Options.RenewInterval.
+const DefaultRenewInterval = 10 * time.Second
+
+// DefaultMaxQueue is the default Options.MaxQueue.
+const DefaultMaxQueue = 1000
+
+// Lock is a lock that at most one instance holds at a time. Implementations must expire the lock if its
+// holder stops renewing it, so that another instance takes over when the leader dies.
+type Lock interface {
+	// Acquire takes the lock if it is free, or renews it if this instance holds it. It returns true if this
+	// instance holds the lock. It is called every Options.RenewInterval.
+	Acquire(ctx context.Context) (bool, error)
+	// Release gives up the lock if this instance holds it, so another instance can take over without
+	// waiting for it to expire.
+	Release(ctx context.Context) error
+}
+
+// Follower is what is done with the notifications of an instance that is not the leader.
+type Follower uint8
+
+const (
+	// Drop drops the notifications of a follower without sending them. Their promises get a nil error,
+	// as the leader is expected to send the same notifications.
+	Drop Follower = 0
+	// Queue holds the notifications of a follower and sends them if it becomes the leader. When the queue is
+	// full, the oldest notification fails with ErrNotLeader.
+	Queue Follower = 1
+)
+
+// String implements fmt.Stringer.
+func (f Follower) String() string {
+	switch f {
+	case Drop:
+		return "drop"
+	case Queue:
+		return "queue"
+	}
+	return fmt.Sprintf("Follower(%d)", f)
+}
+
+// Options configures leader election.
+type Options struct {
+	// Lock is the lock the leader holds. Required.
+	Lock Lock
+	// RenewInterval is how often the Lock is acquired or renewed. It must be well under the time the Lock
+	// takes to expire, or the leader loses it between renewals. Defaults to DefaultRenewInterval.
+	RenewInterval time.Duration
+	// Follower is what is done with the notifications of a follower. Defaults to Drop.
+	Follower Follower
+	// MaxQueue is the number of notifications held by a follower with the Queue policy. Defaults to
+	// DefaultMaxQueue.
+	MaxQueue int
+	// OnChange is called when this instance becomes the leader or stops being it. It must not block.
+	OnChange func(leader bool)
+}
+
+// Validate validates the Options.
+func (o Options) Validate() error {
+	switch {
+	case o.Lock == nil:
+		return errors.New("Options.Lock is required")
+	case o.RenewInterval < 0:
+		return errors.New("Options.RenewInterval cannot be negative")
+	case o.Follower > Queue:
+		return fmt.Errorf("Options.Follower(%s) is not valid", o.Follower)
+	case o.MaxQueue < 0:
+		return errors.New("Options.MaxQueue cannot be negative")
+	}
+	return nil
+}
+
+// Defaults returns the Options with the defaults set for any field that is not set.
+func (o Options) Defaults() Options {
+	if o.RenewInterval == 0 {
+		o.RenewInterval = DefaultRenewInterval
+	}
+	if o.MaxQueue == 0 {
+		o.MaxQueue = DefaultMaxQueue
+	}
+	return o
+}
+
+// Elector keeps track of whether this instance is the leader by acquiring the Lock on an interval.
+type Elector struct {
+	opts Options
+	log  *slog.Logger
+
+	leader  atomic.Bool
+	elected chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates an Elector and tries to acquire the lock in the background until Close() is called. The first
+// try is made before New returns, so that the leader does not treat the first notifications as a follower.
+func New(o Options, log *slog.Logger) (*Elector, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Elector{
+		opts:    o.Defaults(),
+		log:     log,
+		elected: make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	e.acquire(ctx)
+	go e.run(ctx)
+	return e, nil
+}
+
+// Options returns the Options with the defaults set.
+func (e *Elector) Options() Options {
+	return e.opts
+}
+
+// Leader returns true if this instance is the leader. Thread-safe.
+func (e *Elector) Leader() bool {
+	return e.leader.Load()
+}
+
+// Elected receives when this instance becomes the leader.
+func (e *Elector) Elected() <-chan struct{} {
+	return e.elected
+}
+
+// Close stops renewing the lock and releases it if this instance holds it. ctx bounds the release.
+func (e *Elector) Close(ctx context.Context) error {
+	e.cancel()
+	<-e.done
+	if !e.leader.Load() {
+		return nil
+	}
+	e.set(false)
+	return e.opts.Lock.Release(ctx)
+}
+
+// run acquires the lock every RenewInterval until ctx is canceled.
+func (e *Elector) run(ctx context.Context) {
+	defer close(e.done)
+
+	t := time.NewTicker(e.opts.RenewInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		e.acquire(ctx)
+	}
+}
+
+// acquire acquires the lock once. If that fails, this instance stops being the leader, as it cannot know
+// that it still holds the lock.
+func (e *Elector) acquire(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.RenewInterval)
+	defer cancel()
+
+	held, err := e.opts.Lock.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() == nil || e.leader.Load() {
+			e.log.Warn("ARN leader election could not acquire the lock", "error", err.Error())
+		}
+		held = false
+	}
+	e.set(held)
+}
+
+// set records whether this instance is the leader and reports a change.
+func (e *Elector) set(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	e.log.Info("ARN leader election changed", "leader", leader)
+	if leader {
+		select {
+		case e.elected <- struct{}{}:
+		default:
+		}
+	}
+	if e.opts.OnChange != nil {
+		e.opts.OnChange(leader)
+	}
+}
@@ -0,0 +1,121 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLock is held by this instance when free is true. Acquire fails with err if it is set.
+type fakeLock struct {
+	mu       sync.Mutex
+	free     bool
+	err      error
+	released bool
+}
+
+func (f *fakeLock) set(free bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.free = free
+	f.err = err
+}
+
+func (f *fakeLock) Acquire(ctx context.Context) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false, f.err
+	}
+	return f.free, nil
+}
+
+func (f *fakeLock) Release(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.released = true
+	return nil
+}
+
+// waitFor waits for cond to be true, or fails the test.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("TestElector: timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestElector(t *testing.T) {
+	t.Parallel()
+
+	lock := &fakeLock{free: true}
+	changes := make(chan bool, 10)
+	e, err := New(Options{Lock: lock, RenewInterval: time.Millisecond, OnChange: func(l bool) { changes <- l }}, nil)
+	if err != nil {
+		t.Fatalf("TestElector: New(): got err == %s, want err == nil", err)
+	}
+
+	// The first acquire is made by New().
+	if !e.Leader() {
+		t.Errorf("TestElector: got Leader() == false after New() with a free lock, want true")
+	}
+	select {
+	case <-e.Elected():
+	default:
+		t.Errorf("TestElector: Elected() did not receive after New() with a free lock")
+	}
+	if got := <-changes; !got {
+		t.Errorf("TestElector: got OnChange(%v), want OnChange(true)", got)
+	}
+
+	lock.set(false, errors.New("storage is down"))
+	waitFor(t, "losing the lock on an error", func() bool { return !e.Leader() })
+	if got := <-changes; got {
+		t.Errorf("TestElector: got OnChange(%v), want OnChange(false)", got)
+	}
+
+	lock.set(true, nil)
+	waitFor(t, "taking the lock again", e.Leader)
+
+	if err := e.Close(context.Background()); err != nil {
+		t.Errorf("TestElector: Close(): got err == %s, want err == nil", err)
+	}
+	if e.Leader() {
+		t.Errorf("TestElector: got Leader() == true after Close(), want false")
+	}
+	if !lock.released {
+		t.Errorf("TestElector: Close() did not release the lock")
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Valid", opts: Options{Lock: &fakeLock{}, Follower: Queue, MaxQueue: 10}},
+		{name: "Error: no lock", wantErr: true},
+		{name: "Error: negative renew interval", opts: Options{Lock: &fakeLock{}, RenewInterval: -1}, wantErr: true},
+		{name: "Error: bad follower", opts: Options{Lock: &fakeLock{}, Follower: 5}, wantErr: true},
+		{name: "Error: negative queue", opts: Options{Lock: &fakeLock{}, MaxQueue: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.opts.Validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestOptionsValidate(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestOptionsValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
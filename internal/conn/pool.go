@@ -0,0 +1,112 @@
+package conn
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"runtime/pprof"
+	"sync"
+
+	"github.com/Azure/arn-sdk/internal/build"
+	"github.com/Azure/arn-sdk/models"
+)
+
+// maxConcurrency is the largest number of workers WithConcurrency() allows.
+const maxConcurrency = 256
+
+// OrderKey returns the key of the subject, such as a resource or subscription, whose notifications must be
+// sent in the order they were given to Send(), and false if n can be sent in any order.
+type OrderKey func(n models.Notifications) (key string, ok bool)
+
+// pool sends notifications on a fixed number of workers. Notifications with the same OrderKey go to the same
+// worker, so they are sent one at a time in order. Others go to whichever worker is free.
+type pool struct {
+	order OrderKey
+	seed  maphash.Seed
+
+	// keyed has a channel for each worker, for the notifications with an OrderKey.
+	keyed []chan models.Notifications
+	// free is read by every worker, for the notifications without an OrderKey.
+	free chan models.Notifications
+	wg   sync.WaitGroup
+}
+
+// WithConcurrency sends up to n notifications at once instead of one at a time, so a slow send, such as a
+// large blob upload, does not hold up the notifications behind it. If order is set, notifications with the
+// same key are sent one at a time in the order they were given to Send(). A notification with a key waits
+// for the worker of its key, which holds up the notifications behind it until that worker is free. Without
+// order, notifications can be sent in any order. n of 1 sends one at a time, which is the default.
+func WithConcurrency(n int, order OrderKey) Option {
+	return func(s *Service) error {
+		if n < 1 || n > maxConcurrency {
+			return fmt.Errorf("concurrency must be between 1 and %d", maxConcurrency)
+		}
+		if n == 1 {
+			s.pool = nil
+			return nil
+		}
+		p := &pool{order: order, seed: maphash.MakeSeed(), free: make(chan models.Notifications)}
+		p.keyed = make([]chan models.Notifications, n)
+		for i := range p.keyed {
+			p.keyed[i] = make(chan models.Notifications, 1)
+		}
+		s.pool = p
+		return nil
+	}
+}
+
+// start starts the workers, which call send for each notification.
+func (p *pool) start(send func(models.Notifications)) {
+	for _, keyed := range p.keyed {
+		p.wg.Add(1)
+		go pprof.Do(context.Background(), pprof.Labels(build.PprofLabel, "conn.poolWorker"), func(context.Context) {
+			defer p.wg.Done()
+			p.work(keyed, send)
+		})
+	}
+}
+
+// work calls send for the notifications on keyed and free until both are closed.
+func (p *pool) work(keyed chan models.Notifications, send func(models.Notifications)) {
+	free := p.free
+	for keyed != nil || free != nil {
+		select {
+		case n, ok := <-keyed:
+			if !ok {
+				keyed = nil
+				continue
+			}
+			send(n)
+		case n, ok := <-free:
+			if !ok {
+				free = nil
+				continue
+			}
+			send(n)
+		}
+	}
+}
+
+// do hands n to a worker, blocking until one takes it.
+func (p *pool) do(n models.Notifications) {
+	if p.order != nil {
+		if key, ok := p.order(n); ok {
+			p.keyed[maphash.String(p.seed, key)%uint64(len(p.keyed))] <- n
+			return
+		}
+	}
+	p.free <- n
+}
+
+// close stops the workers once they have sent the notifications they were handed and waits for them.
+// It is a no-op on a nil pool.
+func (p *pool) close() {
+	if p == nil {
+		return
+	}
+	for _, keyed := range p.keyed {
+		close(keyed)
+	}
+	close(p.free)
+	p.wg.Wait()
+}
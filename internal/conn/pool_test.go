@@ -0,0 +1,123 @@
+package conn
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+// fakeKey is the OrderKey of a fakeNotify.
+func fakeKey(n models.Notifications) (string, bool) {
+	f := n.(fakeNotify)
+	return f.key, f.key != ""
+}
+
+func TestWithConcurrency(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, maxConcurrency + 1} {
+		if _, err := New(fakeSender{}, nil, make(chan error, 1), WithConcurrency(n, nil)); err == nil {
+			t.Errorf("TestWithConcurrency(%d): got err == nil, want err != nil", n)
+		}
+	}
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithConcurrency(1, nil))
+	if err != nil {
+		t.Fatalf("TestWithConcurrency(1): got err == %s, want err == nil", err)
+	}
+	if s.pool != nil {
+		t.Errorf("TestWithConcurrency(1): got a pool, want notifications sent by sender()")
+	}
+	s.Close()
+}
+
+func TestPoolConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const workers = 4
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithConcurrency(workers, nil))
+	if err != nil {
+		t.Fatalf("TestPoolConcurrent: New(): %v", err)
+	}
+
+	var inflight atomic.Int32
+	release := make(chan struct{})
+	ns := make([]fakeNotify, workers)
+	for i := range ns {
+		ns[i] = newFakeNotify(context.Background(), 1, false)
+		ns[i].sendErr = func() error {
+			inflight.Add(1)
+			<-release
+			return nil
+		}
+		s.Send(ns[i])
+	}
+
+	// Every notification is being sent at once, none waits for another to finish.
+	deadline := time.Now().Add(5 * time.Second)
+	for inflight.Load() < workers {
+		if time.Now().After(deadline) {
+			t.Fatalf("TestPoolConcurrent: got %d sends at once, want %d", inflight.Load(), workers)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	for i, n := range ns {
+		if err := n.Promise(context.Background()); err != nil {
+			t.Errorf("TestPoolConcurrent: notification %d: got err == %s, want err == nil", i, err)
+		}
+	}
+	if !s.Drain(context.Background()) {
+		t.Errorf("TestPoolConcurrent: Drain(): got false, want true")
+	}
+}
+
+func TestPoolOrder(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithConcurrency(4, fakeKey))
+	if err != nil {
+		t.Fatalf("TestPoolOrder: New(): %v", err)
+	}
+
+	var mu sync.Mutex
+	got := map[string][]int{}
+	var ns []fakeNotify
+	for i := range 40 {
+		n := newFakeNotify(context.Background(), 1, false)
+		n.key = fmt.Sprintf("subject-%d", i%3)
+		n.sendErr = func() error {
+			// Later notifications finish faster, which would reorder them without the key.
+			time.Sleep(time.Duration(40-i) * 50 * time.Microsecond)
+			mu.Lock()
+			defer mu.Unlock()
+			got[n.key] = append(got[n.key], i)
+			return nil
+		}
+		s.Send(n)
+		ns = append(ns, n)
+	}
+	if !s.Drain(context.Background()) {
+		t.Fatalf("TestPoolOrder: Drain(): got false, want true")
+	}
+	for _, n := range ns {
+		if err := n.Promise(context.Background()); err != nil {
+			t.Errorf("TestPoolOrder: got err == %s, want err == nil", err)
+		}
+	}
+
+	for key, order := range got {
+		if len(order) == 0 || !slices.IsSorted(order) {
+			t.Errorf("TestPoolOrder(%s): got send order %v, want it sorted", key, order)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("TestPoolOrder: got %d subjects, want 3", len(got))
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Package prepared keeps the event that a model's SendEvent() prepared for a notification, so that a resend
+sends the same event, with the same event ID and blob, instead of preparing a new one. The receiver may have
+accepted an attempt that the client saw fail, so a new event would be a duplicate with a different ID and a
+new blob would be left behind.
+
+The conn package adds a holder to the context of a notification it may resend with With(). SendEvent() stores
+its event with Set() as it is prepared and, on a resend, sends what Get() returns. Package functions are no-ops
+if the context has no holder.
+*/
+package prepared
+
+import "context"
+
+type ctxKey struct{}
+
+// holder holds the prepared event. The attempts of a send are sequential, so it needs no lock.
+type holder struct {
+	v any
+}
+
+// With returns a context that holds the event prepared by the model's SendEvent().
+func With(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &holder{})
+}
+
+// Set stores v, the model's prepared event, in ctx's holder.
+func Set(ctx context.Context, v any) {
+	if ctx == nil {
+		return
+	}
+	if h, ok := ctx.Value(ctxKey{}).(*holder); ok {
+		h.v = v
+	}
+}
+
+// Get returns the event stored with Set(), nil if none was stored or ctx has no holder.
+func Get(ctx context.Context) any {
+	if ctx == nil {
+		return nil
+	}
+	if h, ok := ctx.Value(ctxKey{}).(*holder); ok {
+		return h.v
+	}
+	return nil
+}
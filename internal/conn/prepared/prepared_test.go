@@ -0,0 +1,27 @@
+package prepared
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrepared(t *testing.T) {
+	t.Parallel()
+
+	// A context without a holder is ignored.
+	Set(nil, 1)
+	Set(context.Background(), 1)
+	if got := Get(context.Background()); got != nil {
+		t.Errorf("TestPrepared(no holder): got %v, want nil", got)
+	}
+
+	ctx := With(context.Background())
+	if got := Get(ctx); got != nil {
+		t.Errorf("TestPrepared(empty holder): got %v, want nil", got)
+	}
+	Set(ctx, 1)
+	Set(ctx, 2)
+	if got := Get(ctx); got != 2 {
+		t.Errorf("TestPrepared: got %v, want 2", got)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Package preview carries the receiver preview versions that the events of a notification may use, see
+envelope.PreviewVersions.
+
+The conn package adds the versions to the context of each notification with WithVersions(). The model's
+SendEvent() validates its event with the versions from FromCtx(), so the versions a client allows do not
+change the validation of other clients in the process.
+*/
+package preview
+
+import (
+	"context"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+)
+
+type ctxKey struct{}
+
+// WithVersions returns a context that holds p.
+func WithVersions(ctx context.Context, p envelope.PreviewVersions) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromCtx returns the preview versions in ctx. ok is false if there are none.
+func FromCtx(ctx context.Context) (p envelope.PreviewVersions, ok bool) {
+	if ctx == nil {
+		return envelope.PreviewVersions{}, false
+	}
+	p, ok = ctx.Value(ctxKey{}).(envelope.PreviewVersions)
+	return p, ok
+}
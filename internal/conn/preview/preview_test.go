@@ -0,0 +1,26 @@
+package preview
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+)
+
+func TestFromCtx(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := FromCtx(nil); ok {
+		t.Errorf("TestFromCtx(nil context): got ok == true, want false")
+	}
+	if _, ok := FromCtx(context.Background()); ok {
+		t.Errorf("TestFromCtx(no versions): got ok == true, want false")
+	}
+
+	want := envelope.PreviewVersions{MetadataVersions: []string{"1.1-preview"}}
+	got, ok := FromCtx(WithVersions(context.Background(), want))
+	if !ok || !slices.Equal(got.MetadataVersions, want.MetadataVersions) {
+		t.Errorf("TestFromCtx: got %v, %v, want %v, true", got, ok, want)
+	}
+}
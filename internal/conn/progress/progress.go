@@ -0,0 +1,106 @@
+/*
+Package progress records when a notification last made progress in the send pipeline, so that a wait on
+its promise can be extended while the send is still moving. Without this, a large snapshot that is
+still uploading to blob storage fails with models.ErrPromiseTimeout and the caller handles a failure
+that did not happen.
+
+The client adds a Tracker to the context of a notification with WithTracker(). The send pipeline calls
+Report() when it makes progress: when bytes are uploaded to blob storage, when a request is retried and
+when the event is sent to the receiver. The model's Promise() uses Extend() to decide how long to keep
+waiting after its deadline passes. All package functions are no-ops if the context has no Tracker, so
+this is optional.
+*/
+package progress
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Extension is how a wait on a promise is extended while its notification makes progress.
+type Extension struct {
+	// Idle is how long the send pipeline can go without progress before the wait ends. The wait is
+	// extended until no progress has been reported for Idle.
+	Idle time.Duration
+	// Max is the most a wait is extended past its deadline, no matter the progress.
+	Max time.Duration
+}
+
+// Validate validates the extension.
+func (e Extension) Validate() error {
+	switch {
+	case e.Idle <= 0:
+		return fmt.Errorf("progress idle time must be greater than 0")
+	case e.Max <= 0:
+		return fmt.Errorf("progress max extension must be greater than 0")
+	case e.Max < e.Idle:
+		return fmt.Errorf("progress max extension(%v) cannot be less than the idle time(%v)", e.Max, e.Idle)
+	}
+	return nil
+}
+
+type ctxKey struct{}
+
+// Tracker records the last progress of a notification.
+type Tracker struct {
+	ext  Extension
+	last atomic.Int64
+
+	now func() time.Time
+}
+
+// New creates a Tracker that extends waits with ext. ext must be valid. The notification is considered
+// to have made progress when the Tracker is created.
+func New(ext Extension) *Tracker {
+	t := &Tracker{ext: ext, now: time.Now}
+	t.Report()
+	return t
+}
+
+// Report records that the notification made progress now.
+func (t *Tracker) Report() {
+	t.last.Store(t.now().UnixNano())
+}
+
+// Last returns when the notification last made progress.
+func (t *Tracker) Last() time.Time {
+	return time.Unix(0, t.last.Load())
+}
+
+// Extend returns how much longer to wait for the promise of a notification whose wait had deadline d.
+// It returns 0 when the wait should end, because the notification has made no progress for
+// Extension.Idle or the wait has been extended by Extension.Max.
+func (t *Tracker) Extend(d time.Time) time.Duration {
+	now := t.now()
+	until := t.Last().Add(t.ext.Idle)
+	if limit := d.Add(t.ext.Max); limit.Before(until) {
+		until = limit
+	}
+	if !now.Before(until) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// WithTracker returns a context that carries t.
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromCtx returns the Tracker in ctx, or nil if there is none.
+func FromCtx(ctx context.Context) *Tracker {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(ctxKey{}).(*Tracker)
+	return t
+}
+
+// Report records that the notification with ctx made progress now.
+func Report(ctx context.Context) {
+	if t := FromCtx(ctx); t != nil {
+		t.Report()
+	}
+}
@@ -0,0 +1,84 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExtend(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	deadline := start.Add(10 * time.Second)
+	ext := Extension{Idle: 5 * time.Second, Max: 30 * time.Second}
+
+	tests := []struct {
+		name string
+		// last is when progress was last reported.
+		last time.Time
+		now  time.Time
+		want time.Duration
+	}{
+		{name: "Recent progress", last: deadline, now: deadline.Add(time.Second), want: 4 * time.Second},
+		{name: "No progress for idle", last: deadline, now: deadline.Add(5 * time.Second), want: 0},
+		{name: "Progress before the deadline", last: start.Add(8 * time.Second), now: deadline, want: 3 * time.Second},
+		{name: "Capped by max", last: deadline.Add(29 * time.Second), now: deadline.Add(29 * time.Second), want: time.Second},
+		{name: "Past max", last: deadline.Add(30 * time.Second), now: deadline.Add(30 * time.Second), want: 0},
+	}
+
+	for _, test := range tests {
+		now := test.last
+		tr := &Tracker{ext: ext, now: func() time.Time { return now }}
+		tr.Report()
+		now = test.now
+
+		if got := tr.Extend(deadline); got != test.want {
+			t.Errorf("TestExtend(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ext     Extension
+		wantErr bool
+	}{
+		{name: "Valid", ext: Extension{Idle: time.Second, Max: time.Minute}},
+		{name: "Error: no idle", ext: Extension{Max: time.Minute}, wantErr: true},
+		{name: "Error: no max", ext: Extension{Idle: time.Second}, wantErr: true},
+		{name: "Error: max less than idle", ext: Extension{Idle: time.Minute, Max: time.Second}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.ext.Validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestValidate(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	// Report() on a context without a Tracker must not panic.
+	Report(nil)
+	Report(context.Background())
+
+	tr := New(Extension{Idle: time.Second, Max: time.Minute})
+	now := time.Now().Add(time.Hour)
+	tr.now = func() time.Time { return now }
+	Report(WithTracker(context.Background(), tr))
+	if got := tr.Last(); !got.Equal(now) {
+		t.Errorf("TestReport: got last progress %v, want %v", got, now)
+	}
+	if FromCtx(context.Background()) != nil {
+		t.Errorf("TestReport: FromCtx() on a context without a Tracker: got Tracker, want nil")
+	}
+}
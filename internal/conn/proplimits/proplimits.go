@@ -0,0 +1,30 @@
+/*
+Package proplimits carries the limits that the properties of each resource in a notification are checked
+against, see types.PropertyLimits.
+
+The conn package adds the limits to the context of each notification with WithLimits(). The model's
+SendEvent() checks the properties of each resource with types.CheckProperties() before they are serialized.
+*/
+package proplimits
+
+import (
+	"context"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+type ctxKey struct{}
+
+// WithLimits returns a context that holds l.
+func WithLimits(ctx context.Context, l types.PropertyLimits) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromCtx returns the limits in ctx. ok is false if there are none.
+func FromCtx(ctx context.Context) (l types.PropertyLimits, ok bool) {
+	if ctx == nil {
+		return types.PropertyLimits{}, false
+	}
+	l, ok = ctx.Value(ctxKey{}).(types.PropertyLimits)
+	return l, ok
+}
@@ -0,0 +1,24 @@
+package proplimits
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestFromCtx(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := FromCtx(nil); ok {
+		t.Errorf("TestFromCtx(nil ctx): got ok == true, want false")
+	}
+	if _, ok := FromCtx(context.Background()); ok {
+		t.Errorf("TestFromCtx(no limits): got ok == true, want false")
+	}
+	want := types.PropertyLimits{MaxDepth: 3, MaxBytes: 100}
+	got, ok := FromCtx(WithLimits(context.Background(), want))
+	if !ok || got != want {
+		t.Errorf("TestFromCtx(limits): got %+v, %v, want %+v, true", got, ok, want)
+	}
+}
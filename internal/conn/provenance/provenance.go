@@ -0,0 +1,123 @@
+/*
+Package provenance stamps notifications with the identity of the process that sent them. Among thousands of
+agents publishing the same resource types, ARN-side investigations otherwise cannot tell which one emitted a
+bad event.
+
+The conn package adds the Provenance to the context of each notification with WithProvenance(). The model's
+SendEvent() adds it to the AdditionalBatchProperties of the event with Stamp().
+*/
+package provenance
+
+import (
+	"context"
+	"maps"
+	"os"
+	"runtime/debug"
+)
+
+// The keys the Provenance is stamped with in AdditionalBatchProperties.Others.
+const (
+	KeyPod   = "publisherPod"
+	KeyNode  = "publisherNode"
+	KeyBuild = "publisherBuild"
+)
+
+// Environment variables Detect() reads. PodEnv and NodeEnv are the names commonly set from the Kubernetes
+// downward API (metadata.name and spec.nodeName).
+const (
+	PodEnv  = "POD_NAME"
+	NodeEnv = "NODE_NAME"
+)
+
+// Provenance is the identity of the process that sends notifications. Empty fields are not stamped.
+type Provenance struct {
+	// Pod is the name of the pod, or the host name outside Kubernetes.
+	Pod string `json:"pod,omitzero" yaml:"pod,omitempty"`
+	// Node is the name of the node or VM the process runs on.
+	Node string `json:"node,omitzero" yaml:"node,omitempty"`
+	// Build is the VCS revision the binary was built from.
+	Build string `json:"build,omitzero" yaml:"build,omitempty"`
+}
+
+// IsZero returns true if no fields are set.
+func (p Provenance) IsZero() bool {
+	return p == Provenance{}
+}
+
+// Detect returns p with its empty fields detected from the process: Pod from $POD_NAME or the host name,
+// Node from $NODE_NAME and Build from the vcs.revision in the binary's build info, with "-dirty" added if the
+// tree had local changes. Fields that cannot be detected are left empty.
+func (p Provenance) Detect() Provenance {
+	if p.Pod == "" {
+		p.Pod = os.Getenv(PodEnv)
+		if p.Pod == "" {
+			p.Pod, _ = os.Hostname()
+		}
+	}
+	if p.Node == "" {
+		p.Node = os.Getenv(NodeEnv)
+	}
+	if p.Build == "" {
+		p.Build = buildRevision()
+	}
+	return p
+}
+
+// buildRevision returns the VCS revision in the binary's build info, or "" if there is none.
+func buildRevision() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var rev string
+	var dirty bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if rev != "" && dirty {
+		rev += "-dirty"
+	}
+	return rev
+}
+
+// Stamp returns a copy of others with the Provenance added. Keys that are already in others are kept, so a
+// value the publisher set is not replaced. others is not changed.
+func (p Provenance) Stamp(others map[string]any) map[string]any {
+	if p.IsZero() {
+		return others
+	}
+	out := maps.Clone(others)
+	if out == nil {
+		out = make(map[string]any, 3)
+	}
+	for k, v := range map[string]string{KeyPod: p.Pod, KeyNode: p.Node, KeyBuild: p.Build} {
+		if v == "" {
+			continue
+		}
+		if _, ok := out[k]; !ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type ctxKey struct{}
+
+// WithProvenance returns a context that carries p for the notification it belongs to.
+func WithProvenance(ctx context.Context, p Provenance) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromCtx returns the Provenance in ctx and true, or false if there is none.
+func FromCtx(ctx context.Context) (Provenance, bool) {
+	if ctx == nil {
+		return Provenance{}, false
+	}
+	p, ok := ctx.Value(ctxKey{}).(Provenance)
+	return p, ok
+}
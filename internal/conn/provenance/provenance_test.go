@@ -0,0 +1,79 @@
+package provenance
+
+import (
+	"context"
+	"maps"
+	"testing"
+)
+
+func TestStamp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		p      Provenance
+		others map[string]any
+		want   map[string]any
+	}{
+		{name: "Zero", others: map[string]any{"a": 1}, want: map[string]any{"a": 1}},
+		{name: "Zero with nil others"},
+		{
+			name: "Nil others",
+			p:    Provenance{Pod: "pod", Node: "node", Build: "abc"},
+			want: map[string]any{KeyPod: "pod", KeyNode: "node", KeyBuild: "abc"},
+		},
+		{
+			name: "Empty fields are not stamped",
+			p:    Provenance{Pod: "pod"},
+			want: map[string]any{KeyPod: "pod"},
+		},
+		{
+			name:   "Publisher values are kept",
+			p:      Provenance{Pod: "pod", Build: "abc"},
+			others: map[string]any{KeyPod: "mine", "a": 1},
+			want:   map[string]any{KeyPod: "mine", KeyBuild: "abc", "a": 1},
+		},
+	}
+
+	for _, test := range tests {
+		orig := maps.Clone(test.others)
+		got := test.p.Stamp(test.others)
+		if !maps.Equal(got, test.want) {
+			t.Errorf("TestStamp(%s): got %v, want %v", test.name, got, test.want)
+		}
+		if !maps.Equal(test.others, orig) {
+			t.Errorf("TestStamp(%s): others was changed to %v", test.name, test.others)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv(PodEnv, "env-pod")
+	t.Setenv(NodeEnv, "env-node")
+
+	got := Provenance{Build: "abc"}.Detect()
+	want := Provenance{Pod: "env-pod", Node: "env-node", Build: "abc"}
+	if got != want {
+		t.Errorf("TestDetect: got %+v, want %+v", got, want)
+	}
+
+	got = Provenance{Pod: "pod", Node: "node"}.Detect()
+	if got.Pod != "pod" || got.Node != "node" {
+		t.Errorf("TestDetect: got %+v, want the set fields kept", got)
+	}
+}
+
+func TestFromCtx(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := FromCtx(nil); ok {
+		t.Errorf("TestFromCtx(nil context): got ok == true, want false")
+	}
+	if _, ok := FromCtx(context.Background()); ok {
+		t.Errorf("TestFromCtx(no provenance): got ok == true, want false")
+	}
+	p := Provenance{Pod: "pod"}
+	if got, ok := FromCtx(WithProvenance(context.Background(), p)); !ok || got != p {
+		t.Errorf("TestFromCtx(with provenance): got %+v, %v, want %+v, true", got, ok, p)
+	}
+}
@@ -0,0 +1,198 @@
+// Package quota tracks the rolling usage of a publisher against its ARN-assigned quota and raises alerts
+// when usage crosses thresholds, before ARN starts throttling.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/metrics"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// Window is the period usage is measured over. ARN quotas are per minute.
+const Window = time.Minute
+
+// Kind is the kind of quota.
+type Kind string
+
+const (
+	// Events is the number of events sent to ARN.
+	Events Kind = "events"
+	// Bytes is the number of bytes in the events sent to ARN, before compression.
+	Bytes Kind = "bytes"
+)
+
+// Alert is raised when usage of a quota crosses a threshold.
+type Alert struct {
+	// Kind is the quota that crossed the threshold.
+	Kind Kind
+	// Threshold is the threshold that was crossed, as a fraction of Limit.
+	Threshold float64
+	// Used is the usage in the last Window.
+	Used int64
+	// Limit is the quota.
+	Limit int64
+}
+
+// Options are the quota of a publisher and when to alert on it.
+type Options struct {
+	// EventsPerMinute is the number of events ARN allows the publisher to send per minute. If 0, events are not tracked.
+	EventsPerMinute int64 `json:"eventsPerMinute,omitzero" yaml:"eventsPerMinute,omitempty"`
+	// BytesPerMinute is the number of bytes ARN allows the publisher to send per minute. If 0, bytes are not tracked.
+	BytesPerMinute int64 `json:"bytesPerMinute,omitzero" yaml:"bytesPerMinute,omitempty"`
+	// Thresholds are the fractions of a quota, between 0 and 1, at which to alert. Defaults to 0.8 and 0.9.
+	Thresholds []float64 `json:"thresholds,omitzero" yaml:"thresholds,omitempty"`
+	// OnAlert is called when usage rises above a threshold. An alert is raised once per threshold until
+	// usage drops back below it. This is called synchronously with a send, so it must not block.
+	OnAlert func(ctx context.Context, a Alert) `json:"-" yaml:"-"`
+}
+
+func (o Options) validate() error {
+	if o.EventsPerMinute < 0 || o.BytesPerMinute < 0 {
+		return fmt.Errorf("quotas cannot be negative")
+	}
+	if o.EventsPerMinute == 0 && o.BytesPerMinute == 0 {
+		return fmt.Errorf("at least one of EventsPerMinute or BytesPerMinute must be set")
+	}
+	for _, th := range o.Thresholds {
+		if th <= 0 || th > 1 {
+			return fmt.Errorf("thresholds must be greater than 0 and at most 1, was %v", th)
+		}
+	}
+	return nil
+}
+
+// window is a rolling sum over Window in one second buckets.
+type window struct {
+	counts [60]int64
+	secs   [60]int64
+}
+
+func (w *window) add(now time.Time, n int64) {
+	sec := now.Unix()
+	i := sec % int64(len(w.counts))
+	if w.secs[i] != sec {
+		w.secs[i] = sec
+		w.counts[i] = 0
+	}
+	w.counts[i] += n
+}
+
+func (w *window) sum(now time.Time) int64 {
+	oldest := now.Unix() - int64(len(w.counts))
+	var total int64
+	for i, sec := range w.secs {
+		if sec > oldest {
+			total += w.counts[i]
+		}
+	}
+	return total
+}
+
+// tracked is the usage of one kind of quota.
+type tracked struct {
+	kind  Kind
+	limit int64
+	win   window
+	// level is the number of thresholds that usage is at or above.
+	level int
+}
+
+// Tracker tracks usage against quotas. It is a policy.Policy that should be added to
+// policy.ClientOptions.PerCallPolicies of the ARN HTTP client, so each event is counted once no
+// matter how many times it is retried.
+type Tracker struct {
+	thresholds []float64
+	onAlert    func(ctx context.Context, a Alert)
+
+	mu     sync.Mutex
+	events tracked
+	bytes  tracked
+
+	now func() time.Time
+}
+
+// New creates a new Tracker.
+func New(o Options) (*Tracker, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	th := slices.Clone(o.Thresholds)
+	if len(th) == 0 {
+		th = []float64{0.8, 0.9}
+	}
+	slices.Sort(th)
+	th = slices.Compact(th)
+
+	return &Tracker{
+		thresholds: th,
+		onAlert:    o.OnAlert,
+		events:     tracked{kind: Events, limit: o.EventsPerMinute},
+		bytes:      tracked{kind: Bytes, limit: o.BytesPerMinute},
+		now:        time.Now,
+	}, nil
+}
+
+// Do implements policy.Policy.
+func (t *Tracker) Do(req *policy.Request) (*http.Response, error) {
+	size := req.Raw().ContentLength
+	if size < 0 {
+		size = 0
+	}
+	t.Record(req.Raw().Context(), size)
+	return req.Next()
+}
+
+// Record records an event of size bytes and raises any alerts.
+func (t *Tracker) Record(ctx context.Context, size int64) {
+	now := t.now()
+
+	t.mu.Lock()
+	var alerts []Alert
+	alerts = t.add(&t.events, now, 1, alerts)
+	alerts = t.add(&t.bytes, now, size, alerts)
+	t.mu.Unlock()
+
+	for _, a := range alerts {
+		metrics.FromCtx(ctx).QuotaAlert(ctx, string(a.Kind), a.Threshold)
+		if t.onAlert != nil {
+			t.onAlert(ctx, a)
+		}
+	}
+}
+
+// add adds n to tr and appends an Alert to alerts if usage rose above a threshold. Must be called
+// with t.mu held.
+func (t *Tracker) add(tr *tracked, now time.Time, n int64, alerts []Alert) []Alert {
+	if tr.limit == 0 {
+		return alerts
+	}
+	tr.win.add(now, n)
+	used := tr.win.sum(now)
+
+	level := 0
+	for _, th := range t.thresholds {
+		if float64(used) >= th*float64(tr.limit) {
+			level++
+		}
+	}
+	// Only the highest threshold crossed is reported, a burst that crosses several does not need an alert for each.
+	if level > tr.level {
+		alerts = append(alerts, Alert{Kind: tr.kind, Threshold: t.thresholds[level-1], Used: used, Limit: tr.limit})
+	}
+	tr.level = level
+	return alerts
+}
+
+// Usage returns the number of events and bytes sent in the last Window.
+func (t *Tracker) Usage() (events, bytes int64) {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.events.win.sum(now), t.bytes.win.sum(now)
+}
@@ -0,0 +1,122 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type record struct {
+		at   time.Duration
+		size int64
+	}
+
+	tests := []struct {
+		name       string
+		opts       Options
+		records    []record
+		wantAlerts []Alert
+		wantEvents int64
+		wantBytes  int64
+	}{
+		{
+			name:       "Below thresholds",
+			opts:       Options{EventsPerMinute: 10},
+			records:    []record{{0, 1}, {time.Second, 1}},
+			wantEvents: 2,
+		},
+		{
+			name:    "Events cross both thresholds once",
+			opts:    Options{EventsPerMinute: 10},
+			records: []record{{0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}},
+			wantAlerts: []Alert{
+				{Kind: Events, Threshold: 0.8, Used: 8, Limit: 10},
+				{Kind: Events, Threshold: 0.9, Used: 9, Limit: 10},
+			},
+			wantEvents: 10,
+		},
+		{
+			name:       "Burst reports highest threshold",
+			opts:       Options{BytesPerMinute: 100},
+			records:    []record{{0, 95}},
+			wantAlerts: []Alert{{Kind: Bytes, Threshold: 0.9, Used: 95, Limit: 100}},
+			wantEvents: 0,
+			wantBytes:  95,
+		},
+		{
+			name:    "Alert again after usage drops",
+			opts:    Options{BytesPerMinute: 100, Thresholds: []float64{0.5}},
+			records: []record{{0, 60}, {10 * time.Second, 10}, {61 * time.Second, 10}, {62 * time.Second, 50}},
+			wantAlerts: []Alert{
+				{Kind: Bytes, Threshold: 0.5, Used: 60, Limit: 100},
+				{Kind: Bytes, Threshold: 0.5, Used: 70, Limit: 100},
+			},
+			wantBytes: 70,
+		},
+	}
+
+	for _, test := range tests {
+		var got []Alert
+		test.opts.OnAlert = func(ctx context.Context, a Alert) {
+			got = append(got, a)
+		}
+		tr, err := New(test.opts)
+		if err != nil {
+			t.Fatalf("TestTracker(%s): got err == %s, want err == nil", test.name, err)
+		}
+		var now time.Time
+		tr.now = func() time.Time { return now }
+
+		for _, r := range test.records {
+			now = start.Add(r.at)
+			tr.Record(context.Background(), r.size)
+		}
+
+		if len(got) != len(test.wantAlerts) {
+			t.Errorf("TestTracker(%s): got alerts %+v, want %+v", test.name, got, test.wantAlerts)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.wantAlerts[i] {
+				t.Errorf("TestTracker(%s): alert %d: got %+v, want %+v", test.name, i, got[i], test.wantAlerts[i])
+			}
+		}
+		events, bytes := tr.Usage()
+		if test.opts.EventsPerMinute > 0 && events != test.wantEvents {
+			t.Errorf("TestTracker(%s): got %d events, want %d", test.name, events, test.wantEvents)
+		}
+		if test.opts.BytesPerMinute > 0 && bytes != test.wantBytes {
+			t.Errorf("TestTracker(%s): got %d bytes, want %d", test.name, bytes, test.wantBytes)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Success", opts: Options{EventsPerMinute: 100, Thresholds: []float64{0.5, 1}}},
+		{name: "Error: no quota", wantErr: true},
+		{name: "Error: negative quota", opts: Options{BytesPerMinute: -1}, wantErr: true},
+		{name: "Error: threshold above 1", opts: Options{EventsPerMinute: 100, Thresholds: []float64{1.5}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.opts)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNew(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNew(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ratecoord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+)
+
+// BlobStore is a Store in a blob in Azure Blob Storage. Writes use the blob's ETag, so that instances
+// writing at the same time do not lose each other's registrations.
+type BlobStore struct {
+	client *blockblob.Client
+}
+
+// NewBlobStore creates a BlobStore in the blob at blobURL, such as
+// "https://account.blob.core.windows.net/container/arn-rate". The container must exist. The blob is created
+// by the first instance that registers.
+func NewBlobStore(blobURL string, cred azcore.TokenCredential) (*BlobStore, error) {
+	if cred == nil {
+		return nil, errors.New("cred cannot be nil")
+	}
+	c, err := blockblob.NewClient(blobURL, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create a client for blob(%s): %w", blobURL, err)
+	}
+	return &BlobStore{client: c}, nil
+}
+
+// Read implements Store.Read().
+func (b *BlobStore) Read(ctx context.Context) ([]byte, string, error) {
+	resp, err := b.client.DownloadStream(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	var version string
+	if resp.ETag != nil {
+		version = string(*resp.ETag)
+	}
+	return data, version, nil
+}
+
+// Write implements Store.Write().
+func (b *BlobStore) Write(ctx context.Context, data []byte, version string) error {
+	cond := &blob.ModifiedAccessConditions{}
+	if version == "" {
+		cond.IfNoneMatch = to.Ptr(azcore.ETagAny)
+	} else {
+		cond.IfMatch = to.Ptr(azcore.ETag(version))
+	}
+	_, err := b.client.Upload(ctx, streaming.NopCloser(bytes.NewReader(data)), &blockblob.UploadOptions{
+		AccessConditions: &blob.AccessConditions{ModifiedAccessConditions: cond},
+	})
+	if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+		return ErrConflict
+	}
+	return err
+}
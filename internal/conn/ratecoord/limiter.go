@@ -0,0 +1,71 @@
+package ratecoord
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket that allows a rate per minute, with bursts of up to a second of the rate.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
+}
+
+func newLimiter(perMinute int64) *limiter {
+	l := &limiter{now: time.Now}
+	l.last = l.now()
+	l.setRate(perMinute)
+	l.tokens = l.burst
+	return l
+}
+
+// setRate changes the rate to perMinute.
+func (l *limiter) setRate(perMinute int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.rate = float64(perMinute) / 60
+	l.burst = max(1, l.rate)
+	l.tokens = min(l.tokens, l.burst)
+}
+
+// refill adds the tokens earned since the last refill. Must be called with l.mu held.
+func (l *limiter) refill() {
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// wait waits until a token is available, or ctx ends.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	l.refill()
+	// The token is taken now, so that waiters are served in order. Waiting pays off the debt.
+	l.tokens--
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Package ratecoord divides a global ARN rate limit among the instances of a publisher that share one ARN
+endpoint. When hundreds of agents publish to the same endpoint, a rate limit in each one does not stop them
+from being throttled together.
+
+Each instance registers in shared state, such as a blob (see BlobStore), on an interval, with how many events
+it wanted to send since the last time. Instances that stop registering expire. Each instance's share is half
+of an equal split of the global rate plus its part of the other half by demand, so a busy instance gets more
+than an idle one and every instance can always send. The shares of all live instances add up to the global
+rate.
+
+A Coordinator is a policy.Policy that should be added to policy.ClientOptions.PerCallPolicies of the ARN HTTP
+client. It waits before each request until it fits in the instance's share.
+*/
+package ratecoord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/go-json-experiment/json"
+	"github.com/google/uuid"
+)
+
+// ErrConflict is returned by Store.Write when the state changed since it was read.
+var ErrConflict = errors.New("the shared state changed since it was read")
+
+// DefaultInterval is the default Options.Interval.
+const DefaultInterval = 15 * time.Second
+
+// maxConflicts is the number of times a sync retries after an ErrConflict.
+const maxConflicts = 5
+
+// Store holds the state shared by the instances.
+type Store interface {
+	// Read returns the state and its version, which is passed to Write. If there is no state yet, it
+	// returns nil and an empty version.
+	Read(ctx context.Context) (b []byte, version string, err error)
+	// Write replaces the state with b if it is still at version, or creates it if version is empty. If the
+	// state changed, it returns ErrConflict.
+	Write(ctx context.Context, b []byte, version string) error
+}
+
+// Options configures rate coordination.
+type Options struct {
+	// EventsPerMinute is the global rate, shared by all instances, of requests to ARN. Required.
+	EventsPerMinute int64
+	// Store is where the instances register. Required.
+	Store Store
+	// Interval is how often an instance registers and its share is recomputed. Defaults to DefaultInterval.
+	Interval time.Duration
+	// TTL is how long an instance that stopped registering counts toward the split. Defaults to three
+	// times Interval.
+	TTL time.Duration
+	// InstanceID identifies this instance in the Store. It must be unique among the instances. Defaults to
+	// the host name and a random suffix.
+	InstanceID string
+}
+
+// Validate validates the Options.
+func (o Options) Validate() error {
+	switch {
+	case o.EventsPerMinute <= 0:
+		return errors.New("Options.EventsPerMinute must be greater than 0")
+	case o.Store == nil:
+		return errors.New("Options.Store is required")
+	case o.Interval < 0:
+		return errors.New("Options.Interval cannot be negative")
+	case o.TTL < 0:
+		return errors.New("Options.TTL cannot be negative")
+	case o.TTL != 0 && o.TTL <= o.Interval:
+		return fmt.Errorf("Options.TTL(%v) must be longer than Options.Interval(%v)", o.TTL, o.Interval)
+	}
+	return nil
+}
+
+// Defaults returns the Options with the defaults set for any field that is not set.
+func (o Options) Defaults() Options {
+	if o.Interval == 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.TTL == 0 {
+		o.TTL = 3 * o.Interval
+	}
+	if o.InstanceID == "" {
+		host, _ := os.Hostname()
+		o.InstanceID = host + "-" + uuid.New().String()[:8]
+	}
+	return o
+}
+
+// state is the JSON in the Store.
+type state struct {
+	Instances map[string]instance `json:"instances"`
+}
+
+// instance is an instance's registration.
+type instance struct {
+	// LastSeen is when the instance last registered.
+	LastSeen time.Time `json:"lastSeen"`
+	// Demand is the rate of requests per minute the instance wanted to send since it last registered.
+	Demand int64 `json:"demand"`
+}
+
+// Share is an instance's share of the global rate.
+type Share struct {
+	// EventsPerMinute is the rate of requests this instance can send.
+	EventsPerMinute int64
+	// Instances is the number of live instances the global rate is split among.
+	Instances int
+}
+
+// Coordinator keeps this instance's share of the global rate up to date and limits its requests to it.
+type Coordinator struct {
+	opts Options
+	log  *slog.Logger
+	lim  *limiter
+	now  func() time.Time
+
+	mu        sync.Mutex
+	share     Share
+	requests  int64
+	lastCount time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Coordinator, registers this instance and keeps it registered in the background until
+// Close() is called. Until the first registration succeeds, this instance can send at the global rate. If
+// the Store cannot be reached later, the last share is kept.
+func New(o Options, log *slog.Logger) (*Coordinator, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	o = o.Defaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Coordinator{
+		opts:      o,
+		log:       log,
+		lim:       newLimiter(o.EventsPerMinute),
+		now:       time.Now,
+		share:     Share{EventsPerMinute: o.EventsPerMinute, Instances: 1},
+		lastCount: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	c.sync(ctx)
+	go c.run(ctx)
+	return c, nil
+}
+
+// Do implements policy.Policy.
+func (c *Coordinator) Do(req *policy.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+
+	if err := c.lim.wait(req.Raw().Context()); err != nil {
+		return nil, err
+	}
+	return req.Next()
+}
+
+// Share returns this instance's current share. Thread-safe.
+func (c *Coordinator) Share() Share {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.share
+}
+
+// Close stops registering and removes this instance from the Store, so its share goes to the others
+// without waiting for it to expire. ctx bounds the removal.
+func (c *Coordinator) Close(ctx context.Context) error {
+	c.cancel()
+	<-c.done
+	return c.update(ctx, func(s *state) { delete(s.Instances, c.opts.InstanceID) })
+}
+
+// run registers this instance every Interval until ctx is canceled.
+func (c *Coordinator) run(ctx context.Context) {
+	defer close(c.done)
+
+	t := time.NewTicker(c.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		c.sync(ctx)
+	}
+}
+
+// sync registers this instance with its demand and recomputes its share.
+func (c *Coordinator) sync(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Interval)
+	defer cancel()
+
+	now := c.now()
+	c.mu.Lock()
+	elapsed := now.Sub(c.lastCount)
+	var demand int64
+	if elapsed > 0 {
+		demand = int64(float64(c.requests) * float64(time.Minute) / float64(elapsed))
+	}
+	c.mu.Unlock()
+
+	var share Share
+	err := c.update(ctx, func(s *state) {
+		s.Instances[c.opts.InstanceID] = instance{LastSeen: now, Demand: demand}
+		for id, in := range s.Instances {
+			if now.Sub(in.LastSeen) > c.opts.TTL {
+				delete(s.Instances, id)
+			}
+		}
+		share = split(c.opts.EventsPerMinute, s.Instances, c.opts.InstanceID)
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			c.log.Warn("ARN rate coordination could not register, keeping the last share", "error", err.Error())
+		}
+		return
+	}
+
+	c.mu.Lock()
+	c.requests = 0
+	c.lastCount = now
+	changed := c.share != share
+	c.share = share
+	c.mu.Unlock()
+
+	c.lim.setRate(share.EventsPerMinute)
+	if changed {
+		c.log.Info("ARN rate coordination share changed", "eventsPerMinute", share.EventsPerMinute, "instances", share.Instances)
+	}
+}
+
+// update reads the state, changes it with f and writes it, trying again if another instance wrote it first.
+func (c *Coordinator) update(ctx context.Context, f func(s *state)) error {
+	for i := 0; ; i++ {
+		b, version, err := c.opts.Store.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("could not read the shared state: %w", err)
+		}
+		s := state{}
+		if len(b) > 0 {
+			if err := json.Unmarshal(b, &s); err != nil {
+				// The state is rebuilt as the instances register again.
+				c.log.Warn("ARN rate coordination shared state is corrupt, replacing it", "error", err.Error())
+				s = state{}
+			}
+		}
+		if s.Instances == nil {
+			s.Instances = map[string]instance{}
+		}
+		f(&s)
+
+		b, err = json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		err = c.opts.Store.Write(ctx, b, version)
+		if !errors.Is(err, ErrConflict) || i == maxConflicts {
+			return err
+		}
+		// Spread out the instances that conflicted.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(rand.Int64N(int64(100 * time.Millisecond)))):
+		}
+	}
+}
+
+// split returns the share of id of the global rate among the instances. Half of the rate is split equally
+// and half by demand. If no instance has demand, it is all split equally.
+func split(global int64, instances map[string]instance, id string) Share {
+	n := len(instances)
+	if n == 0 {
+		return Share{EventsPerMinute: global, Instances: 1}
+	}
+	var total int64
+	for _, in := range instances {
+		total += in.Demand
+	}
+	if total == 0 {
+		return Share{EventsPerMinute: max(1, global/int64(n)), Instances: n}
+	}
+	half := float64(global) / 2
+	share := half/float64(n) + half*float64(instances[id].Demand)/float64(total)
+	return Share{EventsPerMinute: max(1, int64(share)), Instances: n}
+}
@@ -0,0 +1,199 @@
+package ratecoord
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is a Store in memory. The version is a counter of the writes.
+type memStore struct {
+	mu      sync.Mutex
+	data    []byte
+	version int
+}
+
+func (m *memStore) Read(ctx context.Context) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.version == 0 {
+		return nil, "", nil
+	}
+	return m.data, strconv.Itoa(m.version), nil
+}
+
+func (m *memStore) Write(ctx context.Context, b []byte, version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := ""
+	if m.version > 0 {
+		cur = strconv.Itoa(m.version)
+	}
+	if version != cur {
+		return ErrConflict
+	}
+	m.data = b
+	m.version++
+	return nil
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		instances map[string]instance
+		want      Share
+	}{
+		{name: "No instances", want: Share{EventsPerMinute: 1200, Instances: 1}},
+		{name: "Equal without demand", instances: map[string]instance{"a": {}, "b": {}, "c": {}}, want: Share{EventsPerMinute: 400, Instances: 3}},
+		{
+			name:      "Busy instance",
+			instances: map[string]instance{"a": {Demand: 300}, "b": {Demand: 100}},
+			want:      Share{EventsPerMinute: 300 + 450, Instances: 2},
+		},
+		{
+			name:      "Idle instance",
+			instances: map[string]instance{"a": {Demand: 0}, "b": {Demand: 100}},
+			want:      Share{EventsPerMinute: 300, Instances: 2},
+		},
+	}
+
+	for _, test := range tests {
+		if got := split(1200, test.instances, "a"); got != test.want {
+			t.Errorf("TestSplit(%s): got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	t.Parallel()
+
+	store := &memStore{}
+	opts := Options{EventsPerMinute: 600, Store: store, Interval: time.Hour}
+
+	a, err := New(Options{EventsPerMinute: 600, Store: store, Interval: time.Hour, InstanceID: "a"}, nil)
+	if err != nil {
+		t.Fatalf("TestCoordinator: New(): got err == %s, want err == nil", err)
+	}
+	if got := a.Share(); got != (Share{EventsPerMinute: 600, Instances: 1}) {
+		t.Errorf("TestCoordinator: first instance: got %+v, want all of the rate", got)
+	}
+
+	opts.InstanceID = "b"
+	b, err := New(opts, nil)
+	if err != nil {
+		t.Fatalf("TestCoordinator: New(): got err == %s, want err == nil", err)
+	}
+	if got := b.Share(); got != (Share{EventsPerMinute: 300, Instances: 2}) {
+		t.Errorf("TestCoordinator: second instance: got %+v, want half of the rate", got)
+	}
+
+	// a learns about b on its next sync.
+	a.sync(context.Background())
+	if got := a.Share(); got != (Share{EventsPerMinute: 300, Instances: 2}) {
+		t.Errorf("TestCoordinator: first instance after sync: got %+v, want half of the rate", got)
+	}
+
+	// b leaving gives its share back to a.
+	if err := b.Close(context.Background()); err != nil {
+		t.Errorf("TestCoordinator: Close(): got err == %s, want err == nil", err)
+	}
+	a.sync(context.Background())
+	if got := a.Share(); got != (Share{EventsPerMinute: 600, Instances: 1}) {
+		t.Errorf("TestCoordinator: after the second instance closed: got %+v, want all of the rate", got)
+	}
+	a.Close(context.Background())
+}
+
+func TestCoordinatorExpires(t *testing.T) {
+	t.Parallel()
+
+	store := &memStore{}
+	now := time.Now()
+	a, err := New(Options{EventsPerMinute: 600, Store: store, Interval: time.Hour, InstanceID: "a"}, nil)
+	if err != nil {
+		t.Fatalf("TestCoordinatorExpires: New(): got err == %s, want err == nil", err)
+	}
+	defer a.Close(context.Background())
+	b, err := New(Options{EventsPerMinute: 600, Store: store, Interval: time.Hour, InstanceID: "b"}, nil)
+	if err != nil {
+		t.Fatalf("TestCoordinatorExpires: New(): got err == %s, want err == nil", err)
+	}
+	defer b.Close(context.Background())
+
+	// b stops registering, so it expires after the TTL.
+	a.now = func() time.Time { return now.Add(4 * time.Hour) }
+	a.sync(context.Background())
+	if got := a.Share(); got.Instances != 1 {
+		t.Errorf("TestCoordinatorExpires: got %d instances, want 1", got.Instances)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+
+	// 6000 per minute is 100 per second, with a burst of 100.
+	l := newLimiter(6000)
+	for i := range 100 {
+		start := time.Now()
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("TestLimiter: wait(%d): got err == %s, want err == nil", i, err)
+		}
+		if time.Since(start) > 50*time.Millisecond {
+			t.Fatalf("TestLimiter: wait(%d) in the burst took %v", i, time.Since(start))
+		}
+	}
+
+	start := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("TestLimiter: wait() after the burst: got err == %s, want err == nil", err)
+	}
+	if d := time.Since(start); d < 5*time.Millisecond {
+		t.Errorf("TestLimiter: wait() after the burst took %v, want about 10ms", d)
+	}
+
+	// The bucket is empty, so a wait at 1 per minute ends with its context and gives the token back.
+	l.setRate(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.mu.Lock()
+	before := l.tokens
+	l.mu.Unlock()
+	if err := l.wait(ctx); err == nil {
+		t.Errorf("TestLimiter: wait() with a canceled context: got err == nil, want err != nil")
+	}
+	l.mu.Lock()
+	after := l.tokens
+	l.mu.Unlock()
+	if after < before {
+		t.Errorf("TestLimiter: wait() with a canceled context used a token: %v tokens before, %v after", before, after)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Valid", opts: Options{EventsPerMinute: 100, Store: &memStore{}}},
+		{name: "Error: no rate", opts: Options{Store: &memStore{}}, wantErr: true},
+		{name: "Error: no store", opts: Options{EventsPerMinute: 100}, wantErr: true},
+		{name: "Error: TTL not longer than interval", opts: Options{EventsPerMinute: 100, Store: &memStore{}, Interval: time.Minute, TTL: time.Minute}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.opts.Validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestOptionsValidate(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestOptionsValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package conn
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/prepared"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/retry/exponential"
+)
+
+// maxResends is the largest number of attempts WithResend() allows, to stop a typo from causing a retry storm.
+const maxResends = 10
+
+// resender sends a notification again when sending it fails with a transient error.
+type resender struct {
+	boff        *exponential.Backoff
+	maxAttempts int
+}
+
+// WithResend sends a notification again, with exponential backoff, when sending it fails with a transient
+// error (see retry.Transient()). maxAttempts is the most times a notification is sent, including the first.
+// The attempts share the notification's context, so they are bounded by its deadline and the send budget.
+func WithResend(p exponential.Policy, maxAttempts int) Option {
+	return func(s *Service) error {
+		if maxAttempts < 1 || maxAttempts > maxResends {
+			return fmt.Errorf("resend maxAttempts must be between 1 and %d", maxResends)
+		}
+		boff, err := exponential.New(exponential.WithPolicy(p))
+		if err != nil {
+			return fmt.Errorf("resend policy: %w", err)
+		}
+		s.resend = &resender{boff: boff, maxAttempts: maxAttempts}
+		return nil
+	}
+}
+
+// sendEvent sends n with its SendEvent(), resending it if that is turned on. It returns the error of the last
+// attempt. A resend sends the event the first attempt prepared, with the same event ID and blob, see prepared.
+func (s *Service) sendEvent(ctx context.Context, n models.Notifications) error {
+	if s.resend == nil {
+		return n.SendEvent(s.http, s.store)
+	}
+	n = n.SetCtx(prepared.With(ctx))
+
+	var last error
+	err := s.resend.boff.Retry(
+		ctx,
+		func(ctx context.Context, r exponential.Record) error {
+			if r.Attempt > 1 {
+				// A resend is progress, so a wait on the notification's promise can be extended.
+				progress.Report(ctx)
+				s.logger().Debug("resending ARN notification", "attempt", r.Attempt, "error", r.Err.Error())
+			}
+			last = n.SendEvent(s.http, s.store)
+			switch {
+			case last == nil:
+				return nil
+			case r.Attempt >= s.resend.maxAttempts || !retry.Transient(last):
+				return fmt.Errorf("%w: %w", last, exponential.ErrPermanent)
+			}
+			if d := retry.RetryAfter(last); d > 0 {
+				return exponential.ErrRetryAfter{Time: time.Now().Add(d), Err: last}
+			}
+			return last
+		},
+	)
+	if err != nil {
+		// Retry() wraps the error, the caller gets the error from the model as it would without resends.
+		return last
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package conn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/retry/exponential"
+)
+
+var testResendPolicy = exponential.Policy{
+	InitialInterval:     time.Millisecond,
+	Multiplier:          2,
+	RandomizationFactor: 0,
+	MaxInterval:         5 * time.Millisecond,
+}
+
+func statusErr(code int) error {
+	return &azcore.ResponseError{StatusCode: code, RawResponse: &http.Response{StatusCode: code, Header: http.Header{}}}
+}
+
+func TestWithResend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		p           exponential.Policy
+		maxAttempts int
+		wantErr     bool
+	}{
+		{name: "Success", p: testResendPolicy, maxAttempts: 3},
+		{name: "Error: no attempts", p: testResendPolicy, maxAttempts: 0, wantErr: true},
+		{name: "Error: too many attempts", p: testResendPolicy, maxAttempts: maxResends + 1, wantErr: true},
+		{name: "Error: invalid policy", p: exponential.Policy{}, maxAttempts: 3, wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := New(fakeSender{}, nil, make(chan error, 1), WithResend(test.p, test.maxAttempts))
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithResend(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithResend(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestResend(t *testing.T) {
+	t.Parallel()
+
+	permanent := errors.New("invalid notification")
+
+	tests := []struct {
+		name string
+		// errs are the errors of each SendEvent() in order, nil after the last.
+		errs         []error
+		maxAttempts  int
+		wantAttempts int32
+		wantErr      error
+	}{
+		{name: "Success on first attempt", maxAttempts: 3, wantAttempts: 1},
+		{name: "Success after 503 and 429", errs: []error{statusErr(503), statusErr(429)}, maxAttempts: 3, wantAttempts: 3},
+		{name: "Success after network error", errs: []error{&net.OpError{Op: "dial", Err: errors.New("refused")}}, maxAttempts: 3, wantAttempts: 2},
+		{name: "Error: out of attempts", errs: []error{statusErr(500), statusErr(502), statusErr(503)}, maxAttempts: 3, wantAttempts: 3, wantErr: statusErr(503)},
+		{name: "Error: 400 is not resent", errs: []error{statusErr(400)}, maxAttempts: 3, wantAttempts: 1, wantErr: statusErr(400)},
+		{name: "Error: permanent error is not resent", errs: []error{permanent}, maxAttempts: 3, wantAttempts: 1, wantErr: permanent},
+		{name: "Error: resends off with 1 attempt", errs: []error{statusErr(503)}, maxAttempts: 1, wantAttempts: 1, wantErr: statusErr(503)},
+	}
+
+	for _, test := range tests {
+		s, err := New(fakeSender{}, nil, make(chan error, 1), WithResend(testResendPolicy, test.maxAttempts))
+		if err != nil {
+			t.Fatalf("TestResend(%s): New(): %v", test.name, err)
+		}
+
+		var attempts atomic.Int32
+		n := newFakeNotify(context.Background(), 1, false)
+		n.sendErr = func() error {
+			i := attempts.Add(1) - 1
+			if int(i) < len(test.errs) {
+				return test.errs[i]
+			}
+			return nil
+		}
+		s.Send(n)
+		err = n.Promise(context.Background())
+		s.Close()
+
+		if got := attempts.Load(); got != test.wantAttempts {
+			t.Errorf("TestResend(%s): got %d attempts, want %d", test.name, got, test.wantAttempts)
+		}
+		switch {
+		case test.wantErr == nil && err != nil:
+			t.Errorf("TestResend(%s): got err == %s, want err == nil", test.name, err)
+		case test.wantErr != nil && (err == nil || err.Error() != test.wantErr.Error()):
+			t.Errorf("TestResend(%s): got err == %v, want err == %s", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestResendBudget(t *testing.T) {
+	t.Parallel()
+
+	// The backoff is longer than the send budget, so the notification fails with the last error instead of
+	// waiting.
+	p := exponential.Policy{InitialInterval: time.Hour, Multiplier: 2, MaxInterval: time.Hour}
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithResend(p, 3), WithSendBudget(time.Second))
+	if err != nil {
+		t.Fatalf("TestResendBudget: New(): %v", err)
+	}
+	defer s.Close()
+
+	var attempts atomic.Int32
+	n := newFakeNotify(context.Background(), 1, false)
+	n.sendErr = func() error {
+		attempts.Add(1)
+		return statusErr(503)
+	}
+	start := time.Now()
+	s.Send(n)
+	err = n.Promise(context.Background())
+
+	if err == nil || err.Error() != statusErr(503).Error() {
+		t.Errorf("TestResendBudget: got err == %v, want the 503 error", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("TestResendBudget: got %d attempts, want 1", got)
+	}
+	if since := time.Since(start); since > 500*time.Millisecond {
+		t.Errorf("TestResendBudget: took %v, want it to fail without waiting", since)
+	}
+}
+
+func TestResendPrepared(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(fakeSender{}, nil, make(chan error, 1), WithResend(testResendPolicy, 3))
+	if err != nil {
+		t.Fatalf("TestResendPrepared: New(): %v", err)
+	}
+	defer s.Close()
+
+	var attempts atomic.Int32
+	var got []any
+	n := newFakeNotify(context.Background(), 1, false)
+	n.prepared = &got
+	n.sendErr = func() error {
+		if attempts.Add(1) < 3 {
+			return statusErr(503)
+		}
+		return nil
+	}
+	s.Send(n)
+	if err := n.Promise(context.Background()); err != nil {
+		t.Fatalf("TestResendPrepared: got err == %s, want err == nil", err)
+	}
+
+	// Each resend sees what the attempt before it prepared.
+	if want := []any{nil, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("TestResendPrepared: got prepared %v on each attempt, want %v", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Package retry provides the single retry policy that is used by every layer that sends a notification.
+
+The HTTP and blob storage clients are built on azcore pipelines, which each retry on their own. Layered on top
+of that are hedged sends. Without coordination, the retries in each layer multiply into retry storms against
+a receiver that is already struggling. A Policy replaces the azcore retries in each pipeline with the same
+backoff and jitter, and its Budget caps the total time spent sending a notification across all layers.
+*/
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of attempts for a request, including the first.
+	DefaultMaxAttempts = 3
+	// DefaultBaseDelay is the default delay before the first retry.
+	DefaultBaseDelay = 500 * time.Millisecond
+	// DefaultMaxDelay is the default maximum delay between retries.
+	DefaultMaxDelay = 5 * time.Second
+	// DefaultJitter is the default jitter applied to the delay between retries.
+	DefaultJitter = 0.2
+	// DefaultBudget is the default total time that can be spent sending a notification.
+	DefaultBudget = 30 * time.Second
+
+	// maxAttempts is the largest MaxAttempts allowed, to stop a typo from causing a retry storm.
+	maxAttempts = 10
+)
+
+// Policy is the retry policy for sending notifications. Zero values are replaced by the defaults.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts for a single request, including the first.
+	// Set to 1 to disable retries. Defaults to DefaultMaxAttempts. Cannot be more than 10.
+	MaxAttempts int `json:"maxAttempts,omitzero" yaml:"maxAttempts,omitempty"`
+	// BaseDelay is the delay before the first retry. The delay doubles on each retry. Defaults to DefaultBaseDelay.
+	BaseDelay time.Duration `json:"baseDelay,omitzero" yaml:"baseDelay,omitempty"`
+	// MaxDelay caps the delay between retries, including delays requested by a Retry-After header.
+	// Defaults to DefaultMaxDelay.
+	MaxDelay time.Duration `json:"maxDelay,omitzero" yaml:"maxDelay,omitempty"`
+	// Jitter is the fraction, between 0 and 1, by which each delay is randomly increased or decreased. This
+	// keeps many clients from retrying at the same time. Defaults to DefaultJitter. Set to a negative number
+	// to disable jitter.
+	Jitter float64 `json:"jitter,omitzero" yaml:"jitter,omitempty"`
+	// Budget is the total time that can be spent sending a notification, including the blob upload,
+	// the HTTP send, all retries and any hedged requests. Defaults to DefaultBudget.
+	Budget time.Duration `json:"budget,omitzero" yaml:"budget,omitempty"`
+}
+
+// Defaults returns a copy of p with the zero values replaced by the defaults.
+func (p Policy) Defaults() Policy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = DefaultBaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = max(DefaultMaxDelay, p.BaseDelay)
+	}
+	if p.Jitter == 0 {
+		p.Jitter = DefaultJitter
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Budget == 0 {
+		p.Budget = DefaultBudget
+	}
+	return p
+}
+
+// Validate validates the policy. This should be called after Defaults().
+func (p Policy) Validate() error {
+	switch {
+	case p.MaxAttempts < 1:
+		return fmt.Errorf("MaxAttempts must be at least 1")
+	case p.MaxAttempts > maxAttempts:
+		return fmt.Errorf("MaxAttempts cannot be more than %d", maxAttempts)
+	case p.BaseDelay < 0:
+		return fmt.Errorf("BaseDelay cannot be negative")
+	case p.MaxDelay < p.BaseDelay:
+		return fmt.Errorf("MaxDelay(%v) cannot be less than BaseDelay(%v)", p.MaxDelay, p.BaseDelay)
+	case p.Jitter > 1:
+		return fmt.Errorf("Jitter must be between 0 and 1")
+	case p.Budget < 0:
+		return fmt.Errorf("Budget cannot be negative")
+	}
+	return nil
+}
+
+// Delay returns the delay before retry number n (starting at 1). rnd returns a number in [0, 1).
+func (p Policy) Delay(n int, rnd func() float64) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rnd()-1)))
+	}
+	return min(d, p.MaxDelay)
+}
+
+// ClientOptions returns a copy of opts with the azcore retries disabled and replaced by p.
+func (p Policy) ClientOptions(opts policy.ClientOptions) policy.ClientOptions {
+	opts.Retry = policy.RetryOptions{MaxRetries: -1}
+	opts.PerCallPolicies = append([]policy.Policy{&pipelinePolicy{p: p, rnd: rand.Float64}}, opts.PerCallPolicies...)
+	return opts
+}
+
+// Override changes the Policy for the requests made to send a single notification. It is carried in the
+// notification's context, see WithOverride(). Zero values keep the values of the Policy.
+type Override struct {
+	// MaxAttempts replaces Policy.MaxAttempts. Cannot be more than 10.
+	MaxAttempts int
+	// TryTimeout is the timeout of each attempt. By default attempts are only limited by the budget.
+	TryTimeout time.Duration
+}
+
+// Validate validates the override.
+func (o Override) Validate() error {
+	switch {
+	case o.MaxAttempts < 0:
+		return fmt.Errorf("MaxAttempts cannot be negative")
+	case o.MaxAttempts > maxAttempts:
+		return fmt.Errorf("MaxAttempts cannot be more than %d", maxAttempts)
+	case o.TryTimeout < 0:
+		return fmt.Errorf("TryTimeout cannot be negative")
+	}
+	return nil
+}
+
+type overrideKey struct{}
+
+// WithOverride returns a context that applies o to the requests made with it. o must be valid.
+func WithOverride(ctx context.Context, o Override) context.Context {
+	return context.WithValue(ctx, overrideKey{}, o)
+}
+
+// retryStatus are the HTTP status codes that are retried.
+var retryStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// pipelinePolicy is an azcore policy.Policy that retries requests with a Policy.
+type pipelinePolicy struct {
+	p   Policy
+	rnd func() float64
+}
+
+// Do implements policy.Policy.Do().
+func (pp *pipelinePolicy) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+
+	attempts := pp.p.MaxAttempts
+	o, _ := ctx.Value(overrideKey{}).(Override)
+	if o.MaxAttempts > 0 {
+		attempts = o.MaxAttempts
+	}
+	// The azcore retries are turned off in ClientOptions(), but policy.WithRetryOptions() on the context
+	// would turn them back on underneath this policy. This replaces it, so azcore only applies the try timeout.
+	tryCtx := policy.WithRetryOptions(ctx, policy.RetryOptions{MaxRetries: -1, TryTimeout: o.TryTimeout})
+
+	for attempt := 1; ; attempt++ {
+		if err := req.RewindBody(); err != nil {
+			return nil, err
+		}
+		resp, err := req.Clone(tryCtx).Next()
+		if attempt >= attempts || !retriable(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := max(pp.p.Delay(attempt, pp.rnd), retryAfter(resp))
+		delay = min(delay, pp.p.MaxDelay)
+		if d, ok := ctx.Deadline(); ok && time.Until(d) < delay {
+			// Waiting would exceed the budget, return what we have.
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		// A retry is progress, so a wait on the notification's promise can be extended.
+		progress.Report(ctx)
+	}
+}
+
+// retriable returns true if the request should be retried.
+func retriable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		// ctx is not done, so a deadline error is from the try timeout and can be retried.
+		return !errors.Is(err, context.Canceled)
+	}
+	return retryStatus[resp.StatusCode]
+}
+
+// retryAfter returns the delay requested by the Retry-After header on resp, if any.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// Transient returns true if err, from sending a whole notification, is worth sending the notification again:
+// the receiver or blob storage answered with a status code that is retried, or a request failed on the
+// network. Context errors are not transient, as they are the end of the send's budget or its cancellation.
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var re *azcore.ResponseError
+	if errors.As(err, &re) {
+		return retryStatus[re.StatusCode]
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of the response that caused err, if any.
+func RetryAfter(err error) time.Duration {
+	var re *azcore.ResponseError
+	if errors.As(err, &re) {
+		return retryAfter(re.RawResponse)
+	}
+	return 0
+}
@@ -0,0 +1,273 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		p       Policy
+		wantErr bool
+	}{
+		{name: "Defaults", p: Policy{}},
+		{name: "Retries disabled", p: Policy{MaxAttempts: 1}},
+		{name: "Jitter disabled", p: Policy{Jitter: -1}},
+		{name: "Error: negative attempts", p: Policy{MaxAttempts: -1}, wantErr: true},
+		{name: "Error: too many attempts", p: Policy{MaxAttempts: maxAttempts + 1}, wantErr: true},
+		{name: "Error: MaxDelay less than BaseDelay", p: Policy{BaseDelay: time.Second, MaxDelay: time.Millisecond}, wantErr: true},
+		{name: "Error: jitter too large", p: Policy{Jitter: 1.5}, wantErr: true},
+		{name: "Error: negative budget", p: Policy{Budget: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.p.Defaults().Validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestValidate(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	t.Parallel()
+
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}
+
+	tests := []struct {
+		name string
+		n    int
+		rnd  float64
+		want time.Duration
+	}{
+		{name: "First retry, no jitter", n: 1, rnd: 0.5, want: 100 * time.Millisecond},
+		{name: "Second retry doubles", n: 2, rnd: 0.5, want: 200 * time.Millisecond},
+		{name: "Jitter down", n: 2, rnd: 0, want: 100 * time.Millisecond},
+		{name: "Jitter up", n: 1, rnd: 1, want: 150 * time.Millisecond},
+		{name: "Capped at MaxDelay", n: 10, rnd: 1, want: time.Second},
+	}
+
+	for _, test := range tests {
+		got := p.Delay(test.n, func() float64 { return test.rnd })
+		if got != test.want {
+			t.Errorf("TestDelay(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+type fakeTransport struct {
+	codes []int
+	calls int
+	// slow is the number of calls, from the first, that block until the request's context is done.
+	slow int
+}
+
+func (f *fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	code := f.codes[min(f.calls, len(f.codes)-1)]
+	f.calls++
+	if f.calls <= f.slow {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestPipelinePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		p         Policy
+		codes     []int
+		slow      int
+		budget    time.Duration
+		override  *Override
+		azRetry   bool
+		wantCode  int
+		wantCalls int
+	}{
+		{
+			name:      "Success on first try",
+			p:         Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusOK},
+			wantCode:  http.StatusOK,
+			wantCalls: 1,
+		},
+		{
+			name:      "Retries until success",
+			p:         Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			wantCode:  http.StatusOK,
+			wantCalls: 3,
+		},
+		{
+			name:      "Stops at MaxAttempts",
+			p:         Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusServiceUnavailable},
+			wantCode:  http.StatusServiceUnavailable,
+			wantCalls: 2,
+		},
+		{
+			name:      "Does not retry non-retriable status",
+			p:         Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusBadRequest},
+			wantCode:  http.StatusBadRequest,
+			wantCalls: 1,
+		},
+		{
+			name:      "Stops when the next delay exceeds the budget",
+			p:         Policy{MaxAttempts: 3, BaseDelay: time.Second},
+			codes:     []int{http.StatusServiceUnavailable},
+			budget:    100 * time.Millisecond,
+			wantCode:  http.StatusServiceUnavailable,
+			wantCalls: 1,
+		},
+		{
+			name:      "Override lowers MaxAttempts",
+			p:         Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusServiceUnavailable},
+			override:  &Override{MaxAttempts: 1},
+			wantCode:  http.StatusServiceUnavailable,
+			wantCalls: 1,
+		},
+		{
+			name:      "Override TryTimeout retries a slow attempt",
+			p:         Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusOK},
+			slow:      1,
+			override:  &Override{TryTimeout: 10 * time.Millisecond},
+			wantCode:  http.StatusOK,
+			wantCalls: 2,
+		},
+		{
+			name:      "azcore retry options on the context are ignored",
+			p:         Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			codes:     []int{http.StatusServiceUnavailable},
+			azRetry:   true,
+			wantCode:  http.StatusServiceUnavailable,
+			wantCalls: 2,
+		},
+	}
+
+	for _, test := range tests {
+		ft := &fakeTransport{codes: test.codes, slow: test.slow}
+		opts := test.p.Defaults().ClientOptions(policy.ClientOptions{Transport: ft})
+		pl := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &opts)
+
+		ctx := context.Background()
+		if test.override != nil {
+			ctx = WithOverride(ctx, *test.override)
+		}
+		if test.azRetry {
+			ctx = policy.WithRetryOptions(ctx, policy.RetryOptions{MaxRetries: 5, RetryDelay: time.Millisecond})
+		}
+		if test.budget > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.budget)
+			defer cancel()
+		}
+
+		req, err := runtime.NewRequest(ctx, http.MethodPost, "https://localhost/arnnotify")
+		if err != nil {
+			panic(err)
+		}
+		if err := req.SetBody(streaming.NopCloser(strings.NewReader("{}")), "application/json"); err != nil {
+			panic(err)
+		}
+
+		resp, err := pl.Do(req)
+		if err != nil {
+			t.Errorf("TestPipelinePolicy(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if resp.StatusCode != test.wantCode {
+			t.Errorf("TestPipelinePolicy(%s): got status %d, want %d", test.name, resp.StatusCode, test.wantCode)
+		}
+		if ft.calls != test.wantCalls {
+			t.Errorf("TestPipelinePolicy(%s): got %d calls, want %d", test.name, ft.calls, test.wantCalls)
+		}
+	}
+}
+
+func TestOverrideValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		o       Override
+		wantErr bool
+	}{
+		{name: "Zero value", o: Override{}},
+		{name: "Valid", o: Override{MaxAttempts: 1, TryTimeout: time.Second}},
+		{name: "Error: negative attempts", o: Override{MaxAttempts: -1}, wantErr: true},
+		{name: "Error: too many attempts", o: Override{MaxAttempts: 11}, wantErr: true},
+		{name: "Error: negative try timeout", o: Override{TryTimeout: -time.Second}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.o.Validate()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestOverrideValidate(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestOverrideValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestTransient(t *testing.T) {
+	t.Parallel()
+
+	status := func(code int, header http.Header) error {
+		return &azcore.ResponseError{StatusCode: code, RawResponse: &http.Response{StatusCode: code, Header: header}}
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		want           bool
+		wantRetryAfter time.Duration
+	}{
+		{name: "nil", err: nil},
+		{name: "503", err: status(503, http.Header{}), want: true},
+		{name: "429 with Retry-After", err: status(429, http.Header{"Retry-After": []string{"3"}}), want: true, wantRetryAfter: 3 * time.Second},
+		{name: "Wrapped 500", err: fmt.Errorf("upload: %w", status(500, http.Header{})), want: true},
+		{name: "400", err: status(400, http.Header{})},
+		{name: "Network error", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{name: "Unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "Deadline", err: fmt.Errorf("send: %w", context.DeadlineExceeded)},
+		{name: "Canceled", err: context.Canceled},
+		{name: "Other error", err: errors.New("invalid notification")},
+	}
+
+	for _, test := range tests {
+		if got := Transient(test.err); got != test.want {
+			t.Errorf("TestTransient(%s): got %v, want %v", test.name, got, test.want)
+		}
+		if got := RetryAfter(test.err); got != test.wantRetryAfter {
+			t.Errorf("TestTransient(%s): got RetryAfter() == %v, want %v", test.name, got, test.wantRetryAfter)
+		}
+	}
+}
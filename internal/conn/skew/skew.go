@@ -0,0 +1,106 @@
+/*
+Package skew checks the times in a notification against bounds around the current time. Some ARN receivers
+drop events whose times are too far in the past or future, and a node with a drifting clock produces such
+events without noticing.
+
+The conn package adds the Bounds to the context of each notification with WithBounds(). The model's
+SendEvent() checks its times with Bounds.Check(). The current time is the ARN receiver's clock when it is
+known, see http.Client.ClockOffset(), so that a drifting local clock is caught as well.
+*/
+package skew
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Action is what is done with a time that is outside the Bounds.
+type Action uint8
+
+const (
+	// Reject fails the notification.
+	Reject Action = 0
+	// Clamp moves the time to the nearest bound.
+	Clamp Action = 1
+	// Warn logs a warning and sends the time as is.
+	Warn Action = 2
+)
+
+// String implements fmt.Stringer.
+func (a Action) String() string {
+	switch a {
+	case Reject:
+		return "reject"
+	case Clamp:
+		return "clamp"
+	case Warn:
+		return "warn"
+	}
+	return fmt.Sprintf("Action(%d)", a)
+}
+
+// Bounds are how far times can be from the current time.
+type Bounds struct {
+	// MaxPast is how far before the current time a time can be. 0 means no limit.
+	MaxPast time.Duration
+	// MaxFuture is how far after the current time a time can be. 0 means no limit.
+	MaxFuture time.Duration
+	// Action is what is done with a time that is outside the bounds. Defaults to Reject.
+	Action Action
+}
+
+// Validate validates the Bounds.
+func (b Bounds) Validate() error {
+	switch {
+	case b.MaxPast < 0:
+		return fmt.Errorf("MaxPast cannot be negative")
+	case b.MaxFuture < 0:
+		return fmt.Errorf("MaxFuture cannot be negative")
+	case b.MaxPast == 0 && b.MaxFuture == 0:
+		return fmt.Errorf("one of MaxPast or MaxFuture must be set")
+	case b.Action > Warn:
+		return fmt.Errorf("unknown Action(%d)", b.Action)
+	}
+	return nil
+}
+
+// Check returns the time to send and an error if t is outside the bounds around now. The error is returned
+// for every Action, so that the caller can report it. For Clamp, the time to send is the nearest bound,
+// otherwise it is t. A zero t is not checked.
+func (b Bounds) Check(now, t time.Time) (time.Time, error) {
+	if t.IsZero() {
+		return t, nil
+	}
+	switch {
+	case b.MaxPast > 0 && t.Before(now.Add(-b.MaxPast)):
+		return b.out(t, now.Add(-b.MaxPast), fmt.Errorf("time %s is %v in the past, more than the limit of %v", t.Format(time.RFC3339), now.Sub(t), b.MaxPast))
+	case b.MaxFuture > 0 && t.After(now.Add(b.MaxFuture)):
+		return b.out(t, now.Add(b.MaxFuture), fmt.Errorf("time %s is %v in the future, more than the limit of %v", t.Format(time.RFC3339), t.Sub(now), b.MaxFuture))
+	}
+	return t, nil
+}
+
+// out returns the time to send and error for t, which is outside the bound.
+func (b Bounds) out(t, bound time.Time, err error) (time.Time, error) {
+	if b.Action == Clamp {
+		return bound, err
+	}
+	return t, err
+}
+
+type ctxKey struct{}
+
+// WithBounds returns a context that holds b.
+func WithBounds(ctx context.Context, b Bounds) context.Context {
+	return context.WithValue(ctx, ctxKey{}, b)
+}
+
+// FromCtx returns the Bounds in ctx. ok is false if there are none.
+func FromCtx(ctx context.Context) (b Bounds, ok bool) {
+	if ctx == nil {
+		return Bounds{}, false
+	}
+	b, ok = ctx.Value(ctxKey{}).(Bounds)
+	return b, ok
+}
@@ -0,0 +1,90 @@
+package skew
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       Bounds
+		wantErr bool
+	}{
+		{name: "Past only", b: Bounds{MaxPast: time.Hour}},
+		{name: "Future only", b: Bounds{MaxFuture: time.Minute, Action: Warn}},
+		{name: "Error: no bounds", b: Bounds{}, wantErr: true},
+		{name: "Error: negative MaxPast", b: Bounds{MaxPast: -time.Hour}, wantErr: true},
+		{name: "Error: negative MaxFuture", b: Bounds{MaxFuture: -time.Hour}, wantErr: true},
+		{name: "Error: unknown Action", b: Bounds{MaxPast: time.Hour, Action: 10}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.b.Validate()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestValidate(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bounds := Bounds{MaxPast: time.Hour, MaxFuture: time.Minute}
+	clamp := bounds
+	clamp.Action = Clamp
+	warn := bounds
+	warn.Action = Warn
+
+	tests := []struct {
+		name    string
+		b       Bounds
+		t       time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "Zero time", b: bounds},
+		{name: "Now", b: bounds, t: now, want: now},
+		{name: "Within past", b: bounds, t: now.Add(-59 * time.Minute), want: now.Add(-59 * time.Minute)},
+		{name: "Within future", b: bounds, t: now.Add(time.Minute), want: now.Add(time.Minute)},
+		{name: "No future limit", b: Bounds{MaxPast: time.Hour}, t: now.Add(24 * time.Hour), want: now.Add(24 * time.Hour)},
+		{name: "Error: too far in the past", b: bounds, t: now.Add(-2 * time.Hour), want: now.Add(-2 * time.Hour), wantErr: true},
+		{name: "Error: too far in the future", b: bounds, t: now.Add(2 * time.Minute), want: now.Add(2 * time.Minute), wantErr: true},
+		{name: "Error: clamp past", b: clamp, t: now.Add(-2 * time.Hour), want: now.Add(-time.Hour), wantErr: true},
+		{name: "Error: clamp future", b: clamp, t: now.Add(2 * time.Minute), want: now.Add(time.Minute), wantErr: true},
+		{name: "Error: warn", b: warn, t: now.Add(2 * time.Minute), want: now.Add(2 * time.Minute), wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := test.b.Check(now, test.t)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestCheck(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestCheck(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("TestCheck(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFromCtx(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := FromCtx(context.Background()); ok {
+		t.Errorf("TestFromCtx: got ok == true for a context without Bounds")
+	}
+	want := Bounds{MaxPast: time.Hour}
+	got, ok := FromCtx(WithBounds(context.Background(), want))
+	if !ok || got != want {
+		t.Errorf("TestFromCtx: got %v, %v, want %v, true", got, ok, want)
+	}
+}
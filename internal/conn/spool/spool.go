@@ -0,0 +1,367 @@
+/*
+Package spool provides a disk-backed queue for events that could not be sent because the ARN receiver was
+unreachable. Without it, an outage of the receiver means failed notifications or, for a service that keeps
+them in memory to send again, memory that grows for as long as the outage lasts.
+
+A Spool is a models.EventSender that wraps the one that talks to the receiver. An event that fails to send
+with an error that means the receiver is unreachable (see Unreachable()) is written to a file in the spool
+directory and the send fails with an error wrapping models.ErrSpooled. While the spool holds events, new
+events are written after them instead of being sent, so events reach the receiver in the order they were
+sent. A goroutine sends the spooled events in order once the receiver is reachable again. The files are kept
+across restarts, so a Spool opened on the same directory sends what an earlier process left behind.
+
+Events are spooled after they are marshaled, so an event whose resources are in blob storage points to a
+blob whose SAS link may expire if the outage is long.
+*/
+package spool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+const (
+	// DefaultMaxBytes is the default maximum size of the events in the spool.
+	DefaultMaxBytes = 1 << 30
+	// DefaultInterval is the default time between attempts to send the spooled events.
+	DefaultInterval = 10 * time.Second
+	// DefaultSendTimeout is the default timeout for sending each spooled event.
+	DefaultSendTimeout = 30 * time.Second
+)
+
+// ext is the file extension of a spooled event. Files are named by their sequence number, zero padded so
+// that they sort in the order they were written.
+const ext = ".evt"
+
+// Options are the options for a Spool. Zero values are replaced by the defaults.
+type Options struct {
+	// MaxBytes is the maximum size of the events in the spool. An event that would take the spool over
+	// it is not spooled and fails with the error it was sent with. Defaults to DefaultMaxBytes.
+	MaxBytes int64
+	// Interval is the time between attempts to send the spooled events. Defaults to DefaultInterval.
+	Interval time.Duration
+	// SendTimeout is the timeout for sending each spooled event. Defaults to DefaultSendTimeout.
+	SendTimeout time.Duration
+	// Logger logs events that are dropped from the spool. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Defaults returns a copy of o with the zero values replaced by the defaults.
+func (o Options) Defaults() Options {
+	if o.MaxBytes == 0 {
+		o.MaxBytes = DefaultMaxBytes
+	}
+	if o.Interval == 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.SendTimeout == 0 {
+		o.SendTimeout = DefaultSendTimeout
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return o
+}
+
+// Validate validates the options. This should be called after Defaults().
+func (o Options) Validate() error {
+	switch {
+	case o.MaxBytes < 0:
+		return errors.New("MaxBytes cannot be negative")
+	case o.Interval < 0:
+		return errors.New("Interval cannot be negative")
+	case o.SendTimeout < 0:
+		return errors.New("SendTimeout cannot be negative")
+	}
+	return nil
+}
+
+// Unreachable returns true if err, from sending an event, means the ARN receiver could not be reached: a
+// network error or a 502, 503 or 504 response from a gateway in front of it. Context errors are not, as they
+// are the end of the send's budget or its cancellation.
+func Unreachable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var re *azcore.ResponseError
+	if errors.As(err, &re) {
+		switch re.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// record is a spooled event as it is written to its file.
+type record struct {
+	Headers []string       `json:"headers,omitempty"`
+	Event   jsontext.Value `json:"event"`
+}
+
+// entry is a spooled event in the queue.
+type entry struct {
+	seq  uint64
+	size int64
+}
+
+// Spool is a models.EventSender that spools events while the ARN receiver is unreachable. Thread-safe.
+type Spool struct {
+	dir    string
+	sender models.EventSender
+	opts   Options
+
+	mu    sync.Mutex
+	queue []entry
+	bytes int64
+	next  uint64
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New opens the spool in dir, creating dir if it does not exist, and starts sending any events already in
+// it. sender sends events to the ARN receiver. Close() must be called to stop the Spool.
+func New(dir string, sender models.EventSender, o Options) (*Spool, error) {
+	if dir == "" {
+		return nil, errors.New("spool directory is required")
+	}
+	if sender == nil {
+		return nil, errors.New("sender is required")
+	}
+	o = o.Defaults()
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create spool directory(%s): %w", dir, err)
+	}
+
+	s := &Spool{dir: dir, sender: sender, opts: o, done: make(chan struct{})}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop = cancel
+	go s.replayer(ctx)
+	return s, nil
+}
+
+// load reads the events an earlier Spool left in the directory into the queue and removes files that were
+// not completely written.
+func (s *Spool) load() error {
+	des, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("could not read spool directory(%s): %w", s.dir, err)
+	}
+	for _, de := range des {
+		name := de.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		if !strings.HasSuffix(name, ext) || err != nil {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return fmt.Errorf("could not stat spooled event(%s): %w", name, err)
+		}
+		s.queue = append(s.queue, entry{seq: seq, size: fi.Size()})
+		s.bytes += fi.Size()
+		s.next = max(s.next, seq+1)
+	}
+	slices.SortFunc(s.queue, func(a, b entry) int { return cmp.Compare(a.seq, b.seq) })
+	return nil
+}
+
+// Len returns the number of events in the spool.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Send implements models.EventSender. The event is sent if the spool is empty. If the spool holds events,
+// or the send fails because the receiver is unreachable, the event is spooled and the error wraps
+// models.ErrSpooled. If the spool is full, the send error is returned as it is.
+func (s *Spool) Send(ctx context.Context, event []byte, headers []string) error {
+	var sendErr error
+	if s.Len() == 0 {
+		sendErr = s.sender.Send(ctx, event, headers)
+		if !Unreachable(sendErr) || ctx.Err() != nil {
+			return sendErr
+		}
+	}
+
+	if err := s.push(event, headers); err != nil {
+		if sendErr != nil {
+			return fmt.Errorf("%w (could not spool the event: %v)", sendErr, err)
+		}
+		return fmt.Errorf("could not spool the event behind the events already spooled: %w", err)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("%w: %v", models.ErrSpooled, sendErr)
+	}
+	return fmt.Errorf("%w: events ahead of it are spooled", models.ErrSpooled)
+}
+
+// ClockOffset returns the clock offset of the wrapped sender, if it measures one.
+func (s *Spool) ClockOffset() (time.Duration, bool) {
+	c, ok := s.sender.(interface{ ClockOffset() (time.Duration, bool) })
+	if !ok {
+		return 0, false
+	}
+	return c.ClockOffset()
+}
+
+// push writes the event to the end of the spool.
+func (s *Spool) push(event []byte, headers []string) error {
+	b, err := json.Marshal(record{Headers: headers, Event: event})
+	if err != nil {
+		return fmt.Errorf("could not marshal the event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bytes+int64(len(b)) > s.opts.MaxBytes {
+		return fmt.Errorf("spool is full (%d bytes)", s.bytes)
+	}
+	seq := s.next
+	// The file is written under a temporary name and renamed, so a crash never leaves a partial event
+	// that would be replayed.
+	tmp := filepath.Join(s.dir, fileName(seq)+".tmp")
+	if err := writeFile(tmp, b); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, fileName(seq))); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not write spooled event: %w", err)
+	}
+	s.next++
+	s.queue = append(s.queue, entry{seq: seq, size: int64(len(b))})
+	s.bytes += int64(len(b))
+	return nil
+}
+
+// writeFile writes b to a new file at path and syncs it to disk.
+func writeFile(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not create spooled event: %w", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("could not write spooled event: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("could not sync spooled event: %w", err)
+	}
+	return f.Close()
+}
+
+// fileName returns the name of the file of the event with sequence number seq.
+func fileName(seq uint64) string {
+	return fmt.Sprintf("%020d%s", seq, ext)
+}
+
+// replayer sends the spooled events every Interval until ctx is cancelled.
+func (s *Spool) replayer(ctx context.Context) {
+	defer close(s.done)
+
+	t := time.NewTicker(s.opts.Interval)
+	defer t.Stop()
+	for {
+		s.replay(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// replay sends the spooled events in order until the spool is empty, a send fails with an error that is worth
+// trying again later or ctx is cancelled. An event that fails for any other reason, such as the receiver
+// rejecting it with 400 Bad Request, is dropped, as it would fail again.
+func (s *Spool) replay(ctx context.Context) {
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		head := s.queue[0]
+		s.mu.Unlock()
+
+		path := filepath.Join(s.dir, fileName(head.seq))
+		err := s.sendFile(ctx, path)
+		if ctx.Err() != nil || Unreachable(err) || retry.Transient(err) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		if err != nil {
+			s.opts.Logger.Error("dropping spooled event the ARN receiver rejected", "file", path, "error", err.Error())
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.opts.Logger.Error("could not remove spooled event, it may be sent again", "file", path, "error", err.Error())
+		}
+
+		s.mu.Lock()
+		s.queue = s.queue[1:]
+		s.bytes -= head.size
+		s.mu.Unlock()
+	}
+}
+
+// sendFile sends the event in the file at path.
+func (s *Spool) sendFile(ctx context.Context, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read spooled event: %w", err)
+	}
+	var r record
+	if err := json.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("could not unmarshal spooled event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.SendTimeout)
+	defer cancel()
+	return s.sender.Send(ctx, r.Event, r.Headers)
+}
+
+// Close stops sending the spooled events. Events left in the spool stay on disk for the next Spool opened on
+// the directory.
+func (s *Spool) Close() {
+	s.stop()
+	<-s.done
+}
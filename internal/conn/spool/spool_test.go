@@ -0,0 +1,245 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// fakeSender records the events it sends, followed by their headers. While down is set, every send fails
+// with a network error. Events equal to reject fail with 400 Bad Request.
+type fakeSender struct {
+	down   atomic.Bool
+	reject string
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (f *fakeSender) Send(ctx context.Context, event []byte, headers []string) error {
+	if f.down.Load() {
+		return &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	}
+	if string(event) == f.reject {
+		return &azcore.ResponseError{StatusCode: http.StatusBadRequest}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, string(event)+strings.Join(headers, ","))
+	return nil
+}
+
+func (f *fakeSender) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.events...)
+}
+
+func testOpts() Options {
+	return Options{Interval: 10 * time.Millisecond, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+// waitEmpty waits for s to send all of its events.
+func waitEmpty(t *testing.T, s *Spool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("spool still holds %d events", s.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil"},
+		{name: "network", err: fmt.Errorf("send: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "502", err: &azcore.ResponseError{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "503", err: &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "504", err: &azcore.ResponseError{StatusCode: http.StatusGatewayTimeout}, want: true},
+		{name: "500", err: &azcore.ResponseError{StatusCode: http.StatusInternalServerError}},
+		{name: "429", err: &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}},
+		{name: "400", err: &azcore.ResponseError{StatusCode: http.StatusBadRequest}},
+		{name: "deadline", err: context.DeadlineExceeded},
+		{name: "canceled", err: context.Canceled},
+	}
+
+	for _, test := range tests {
+		if got := Unreachable(test.err); got != test.want {
+			t.Errorf("TestUnreachable(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSpool(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New("", &fakeSender{}, testOpts()); err == nil {
+		t.Errorf("TestSpool(no dir): got err == nil, want err != nil")
+	}
+	if _, err := New(t.TempDir(), nil, testOpts()); err == nil {
+		t.Errorf("TestSpool(no sender): got err == nil, want err != nil")
+	}
+	if _, err := New(t.TempDir(), &fakeSender{}, Options{MaxBytes: -1}); err == nil {
+		t.Errorf("TestSpool(invalid options): got err == nil, want err != nil")
+	}
+
+	ctx := context.Background()
+	f := &fakeSender{}
+	f.down.Store(true)
+	s, err := New(t.TempDir(), f, testOpts())
+	if err != nil {
+		t.Fatalf("TestSpool: New(): got err == %s, want err == nil", err)
+	}
+	defer s.Close()
+
+	if err := s.Send(ctx, []byte("1"), []string{"k", "v"}); !errors.Is(err, models.ErrSpooled) {
+		t.Fatalf("TestSpool: Send() while down: got err == %v, want models.ErrSpooled", err)
+	}
+	if err := s.Send(ctx, []byte("2"), nil); !errors.Is(err, models.ErrSpooled) {
+		t.Fatalf("TestSpool: Send() behind a spooled event: got err == %v, want models.ErrSpooled", err)
+	}
+	// The replayer may have tried the spooled event, but the new one must not have been sent ahead of it.
+	if got := s.Len(); got != 2 {
+		t.Errorf("TestSpool: got Len() == %d, want 2", got)
+	}
+
+	f.down.Store(false)
+	waitEmpty(t, s)
+	if err := s.Send(ctx, []byte("3"), nil); err != nil {
+		t.Errorf("TestSpool: Send() with an empty spool: got err == %s, want err == nil", err)
+	}
+
+	want := []string{"1k,v", "2", "3"}
+	if diff := pretty.Compare(want, f.sent()); diff != "" {
+		t.Errorf("TestSpool: sent events: -want/+got:\n%s", diff)
+	}
+}
+
+func TestSpoolReopen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	down := &fakeSender{}
+	down.down.Store(true)
+	s, err := New(dir, down, testOpts())
+	if err != nil {
+		t.Fatalf("TestSpoolReopen: New(): got err == %s, want err == nil", err)
+	}
+	for i := range 3 {
+		if err := s.Send(ctx, []byte(fmt.Sprint(i)), nil); !errors.Is(err, models.ErrSpooled) {
+			t.Fatalf("TestSpoolReopen: Send(%d): got err == %v, want models.ErrSpooled", i, err)
+		}
+	}
+	s.Close()
+
+	// A partly written event from a crash is removed, not sent.
+	if err := os.WriteFile(filepath.Join(dir, fileName(99)+".tmp"), []byte("{"), 0o600); err != nil {
+		t.Fatalf("TestSpoolReopen: could not write a temporary file: %s", err)
+	}
+
+	up := &fakeSender{}
+	s, err = New(dir, up, testOpts())
+	if err != nil {
+		t.Fatalf("TestSpoolReopen: New() on the same dir: got err == %s, want err == nil", err)
+	}
+	defer s.Close()
+	waitEmpty(t, s)
+
+	if diff := pretty.Compare([]string{"0", "1", "2"}, up.sent()); diff != "" {
+		t.Errorf("TestSpoolReopen: sent events: -want/+got:\n%s", diff)
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("TestSpoolReopen: could not read the dir: %s", err)
+	}
+	if len(des) != 0 {
+		t.Errorf("TestSpoolReopen: got %d files left in the spool, want 0", len(des))
+	}
+}
+
+func TestSpoolLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := &fakeSender{}
+	f.down.Store(true)
+	o := testOpts()
+	o.MaxBytes = 10
+	s, err := New(t.TempDir(), f, o)
+	if err != nil {
+		t.Fatalf("TestSpoolLimits: New(): got err == %s, want err == nil", err)
+	}
+	defer s.Close()
+
+	err = s.Send(ctx, []byte(`"an event that does not fit"`), nil)
+	var ne net.Error
+	switch {
+	case err == nil:
+		t.Fatalf("TestSpoolLimits: Send() to a full spool: got err == nil, want the send error")
+	case errors.Is(err, models.ErrSpooled):
+		t.Errorf("TestSpoolLimits: Send() to a full spool: got err == %s, want it not to be spooled", err)
+	case !errors.As(err, &ne):
+		t.Errorf("TestSpoolLimits: Send() to a full spool: got err == %s, want the network error", err)
+	}
+
+	// An error that does not mean the receiver is unreachable is not spooled.
+	f.down.Store(false)
+	f.reject = `"bad"`
+	if err := s.Send(ctx, []byte(`"bad"`), nil); err == nil || errors.Is(err, models.ErrSpooled) {
+		t.Errorf("TestSpoolLimits: Send() of a rejected event: got err == %v, want the 400 error", err)
+	}
+	if got := s.Len(); got != 0 {
+		t.Errorf("TestSpoolLimits: got Len() == %d, want 0", got)
+	}
+}
+
+func TestSpoolDropsRejected(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := &fakeSender{reject: `"bad"`}
+	f.down.Store(true)
+	s, err := New(t.TempDir(), f, testOpts())
+	if err != nil {
+		t.Fatalf("TestSpoolDropsRejected: New(): got err == %s, want err == nil", err)
+	}
+	defer s.Close()
+
+	for _, e := range []string{`"bad"`, `"good"`} {
+		if err := s.Send(ctx, []byte(e), nil); !errors.Is(err, models.ErrSpooled) {
+			t.Fatalf("TestSpoolDropsRejected: Send(%s): got err == %v, want models.ErrSpooled", e, err)
+		}
+	}
+	f.down.Store(false)
+	waitEmpty(t, s)
+
+	if diff := pretty.Compare([]string{`"good"`}, f.sent()); diff != "" {
+		t.Errorf("TestSpoolDropsRejected: sent events: -want/+got:\n%s", diff)
+	}
+}
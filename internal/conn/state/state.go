@@ -0,0 +1,101 @@
+/*
+Package state keeps the PayloadHash of the last notification that was delivered for each resource, so a
+publisher can ask whether ARN has already seen a version of a resource before sending it again.
+
+The hashes are kept in a Store, which can persist them so they survive a restart of the publisher. The
+conn package calls Cache.Record() after a notification is delivered.
+*/
+package state
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+// Store persists the PayloadHash of the last delivered version of each resource. Implementations must
+// be safe for concurrent use. Resource IDs are passed as they are in the notification, a Store that
+// needs case-insensitive IDs must fold them itself.
+type Store interface {
+	// Get returns the hash stored for the resource with id. ok is false if there is none.
+	Get(ctx context.Context, id string) (h models.PayloadHash, ok bool, err error)
+	// Put stores h for the resource with id, replacing any hash stored before.
+	Put(ctx context.Context, id string, h models.PayloadHash) error
+}
+
+// MemStore is a Store that keeps the hashes in memory. It is not persisted and grows with the number of
+// resources that are sent.
+type MemStore struct {
+	mu     sync.RWMutex
+	hashes map[string]models.PayloadHash
+}
+
+// NewMemStore creates a new MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{hashes: map[string]models.PayloadHash{}}
+}
+
+// Get implements Store.Get().
+func (m *MemStore) Get(ctx context.Context, id string) (models.PayloadHash, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.hashes[id]
+	return h, ok, nil
+}
+
+// Put implements Store.Put().
+func (m *MemStore) Put(ctx context.Context, id string, h models.PayloadHash) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes[id] = h
+	return nil
+}
+
+// Cache records the hashes of delivered notifications in a Store and answers queries about them.
+type Cache struct {
+	store Store
+}
+
+// New creates a Cache that keeps its hashes in store. If store is nil, a MemStore is used.
+func New(store Store) *Cache {
+	if store == nil {
+		store = NewMemStore()
+	}
+	return &Cache{store: store}
+}
+
+// Record stores the hash of every data item in n, which must have been delivered. It returns the first
+// error from the Store, after trying every data item.
+func (c *Cache) Record(ctx context.Context, n models.Notifications) error {
+	var first error
+	for id, h := range n.PayloadHashes() {
+		if err := c.store.Put(ctx, id, h); err != nil && first == nil {
+			first = fmt.Errorf("could not record the payload hash of %s: %w", id, err)
+		}
+	}
+	return first
+}
+
+// Last returns the hash of the last delivered version of the resource with id. ok is false if no
+// version of the resource was recorded.
+func (c *Cache) Last(ctx context.Context, id string) (h models.PayloadHash, ok bool, err error) {
+	return c.store.Get(ctx, id)
+}
+
+// Seen returns true if every data item in n is the version of its resource that was last delivered.
+func (c *Cache) Seen(ctx context.Context, n models.Notifications) (bool, error) {
+	found := false
+	for id, h := range n.PayloadHashes() {
+		found = true
+		last, ok, err := c.store.Get(ctx, id)
+		if err != nil {
+			return false, fmt.Errorf("could not get the payload hash of %s: %w", id, err)
+		}
+		if !ok || last != h {
+			return false, nil
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+const clusterID = "/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"
+
+func notification(t *testing.T, version string, eventTime time.Time) models.Notifications {
+	t.Helper()
+
+	id, err := arm.ParseResourceID(clusterID)
+	if err != nil {
+		t.Fatalf("arm.ParseResourceID(): %v", err)
+	}
+	ar, err := types.NewArmResource(types.ActWrite, id, "2024-01-01", map[string]any{"kubernetesVersion": version, "nodes": 3})
+	if err != nil {
+		t.Fatalf("types.NewArmResource(): %v", err)
+	}
+	return msgs.Notifications{
+		ResourceLocation: "eastus",
+		PublisherInfo:    "Microsoft.ContainerService",
+		Data: []types.NotificationResource{
+			{
+				ResourceID:               id.String(),
+				APIVersion:               "2024-01-01",
+				ResourceEventTime:        eventTime,
+				ArmResource:              ar,
+				ResourceSystemProperties: types.ResourceSystemProperties{ChangeAction: types.CAUpdate},
+			},
+		},
+	}
+}
+
+// errStore is a Store that always fails.
+type errStore struct{}
+
+func (errStore) Get(ctx context.Context, id string) (models.PayloadHash, bool, error) {
+	return models.PayloadHash{}, false, errors.New("get failed")
+}
+
+func (errStore) Put(ctx context.Context, id string, h models.PayloadHash) error {
+	return errors.New("put failed")
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	v1 := notification(t, "1.29.0", now)
+	v1Later := notification(t, "1.29.0", now.Add(time.Hour))
+	v2 := notification(t, "1.30.0", now)
+
+	c := New(nil)
+	if _, ok, err := c.Last(ctx, clusterID); ok || err != nil {
+		t.Errorf("TestCache: Last() before Record(): got ok == %v, err == %v, want false, nil", ok, err)
+	}
+	if seen, err := c.Seen(ctx, v1); seen || err != nil {
+		t.Errorf("TestCache: Seen() before Record(): got %v, %v, want false, nil", seen, err)
+	}
+
+	if err := c.Record(ctx, v1); err != nil {
+		t.Fatalf("TestCache: Record() error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		n    models.Notifications
+		want bool
+	}{
+		{name: "Same version", n: v1, want: true},
+		{name: "Same version sent later", n: v1Later, want: true},
+		{name: "New version", n: v2},
+		{name: "No data", n: msgs.Notifications{}},
+	}
+	for _, test := range tests {
+		seen, err := c.Seen(ctx, test.n)
+		if err != nil {
+			t.Errorf("TestCache(%s): Seen() error: %v", test.name, err)
+			continue
+		}
+		if seen != test.want {
+			t.Errorf("TestCache(%s): Seen(): got %v, want %v", test.name, seen, test.want)
+		}
+	}
+
+	h, ok, err := c.Last(ctx, clusterID)
+	if err != nil || !ok {
+		t.Fatalf("TestCache: Last(): got ok == %v, err == %v, want true, nil", ok, err)
+	}
+	for _, want := range v1.PayloadHashes() {
+		if h != want {
+			t.Errorf("TestCache: Last(): got %s, want %s", h, want)
+		}
+	}
+}
+
+func TestCacheStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := New(errStore{})
+	n := notification(t, "1.29.0", time.Now())
+
+	if err := c.Record(ctx, n); err == nil {
+		t.Errorf("TestCacheStoreErrors: Record(): got err == nil, want err != nil")
+	}
+	if _, err := c.Seen(ctx, n); err == nil {
+		t.Errorf("TestCacheStoreErrors: Seen(): got err == nil, want err != nil")
+	}
+	if _, _, err := c.Last(ctx, clusterID); err == nil {
+		t.Errorf("TestCacheStoreErrors: Last(): got err == nil, want err != nil")
+	}
+}
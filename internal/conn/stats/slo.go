@@ -0,0 +1,166 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultSLOWindow is the default SLOOptions.Window.
+	DefaultSLOWindow = time.Hour
+	// DefaultAlertBurnRate is the default SLOOptions.AlertBurnRate. At this rate, an hour uses 2% of a
+	// 30 day error budget.
+	DefaultAlertBurnRate = 14.4
+	// DefaultSLOMinEvents is the default SLOOptions.MinEvents.
+	DefaultSLOMinEvents = 100
+)
+
+// SLOOptions are the objective for the success rate of a publisher's notifications and when to alert on
+// how fast its error budget is used. Failures the publisher causes itself are not counted: notifications
+// that were canceled, dropped at shutdown, had too many items or needed blob storage the client does not have.
+type SLOOptions struct {
+	// Objective is the fraction of notifications that must succeed, such as 0.999. It must be greater than
+	// 0 and less than 1.
+	Objective float64
+	// Window is the rolling period the success ratio and burn rate are measured over. Defaults to
+	// DefaultSLOWindow and must be at least a minute.
+	Window time.Duration
+	// AlertBurnRate is the burn rate at or above which the error budget is burning. Defaults to
+	// DefaultAlertBurnRate.
+	AlertBurnRate float64
+	// MinEvents is the number of notifications that must be in the Window before the error budget can be
+	// burning, so that a few failures in a quiet period do not raise an alert. Defaults to DefaultSLOMinEvents.
+	MinEvents int64
+	// OnAlert is called when the error budget starts burning and again when it stops, with
+	// SLOStatus.Burning set to match. Both are noticed when a result is recorded, so an alert that it stopped
+	// waits for the next notification. This is called synchronously with the result of a notification, so it
+	// must not block.
+	OnAlert func(s SLOStatus)
+}
+
+// Validate validates the SLOOptions.
+func (o SLOOptions) Validate() error {
+	switch {
+	case o.Objective <= 0 || o.Objective >= 1:
+		return fmt.Errorf("SLOOptions.Objective(%v) must be greater than 0 and less than 1", o.Objective)
+	case o.Window != 0 && o.Window < time.Minute:
+		return fmt.Errorf("SLOOptions.Window(%v) must be at least a minute", o.Window)
+	case o.AlertBurnRate < 0:
+		return errors.New("SLOOptions.AlertBurnRate cannot be negative")
+	case o.MinEvents < 0:
+		return errors.New("SLOOptions.MinEvents cannot be negative")
+	}
+	return nil
+}
+
+// Defaults returns the SLOOptions with the defaults set for any field that is not set.
+func (o SLOOptions) Defaults() SLOOptions {
+	if o.Window == 0 {
+		o.Window = DefaultSLOWindow
+	}
+	if o.AlertBurnRate == 0 {
+		o.AlertBurnRate = DefaultAlertBurnRate
+	}
+	if o.MinEvents == 0 {
+		o.MinEvents = DefaultSLOMinEvents
+	}
+	return o
+}
+
+// SLOStatus is the success ratio and error budget burn rate over the SLO's window.
+type SLOStatus struct {
+	// Objective is SLOOptions.Objective.
+	Objective float64
+	// Window is SLOOptions.Window.
+	Window time.Duration
+	// Total is the number of notifications counted in the Window.
+	Total int64
+	// Failed is the number of counted notifications in the Window that failed.
+	Failed int64
+	// SuccessRatio is the fraction of the Total that succeeded. 1 if Total is 0.
+	SuccessRatio float64
+	// BurnRate is how fast the error budget is used: the failure ratio divided by the failure ratio the
+	// Objective allows. At 1, the budget is used up exactly at the end of the SLO period.
+	BurnRate float64
+	// Burning is true if BurnRate is at least SLOOptions.AlertBurnRate and Total is at least
+	// SLOOptions.MinEvents. Publishers can stop deployments while this is true.
+	Burning bool
+}
+
+// sloBuckets is the number of buckets the SLO window is split into.
+const sloBuckets = 60
+
+// slo tracks the SLO over a rolling window of buckets. It is not thread-safe, the Collector locks it.
+type slo struct {
+	opts   SLOOptions
+	bucket time.Duration
+
+	total  [sloBuckets]int64
+	failed [sloBuckets]int64
+	slots  [sloBuckets]int64
+
+	burning bool
+}
+
+func newSLO(o SLOOptions) *slo {
+	o = o.Defaults()
+	return &slo{opts: o, bucket: o.Window / sloBuckets}
+}
+
+// counts returns true if a notification that failed with err counts towards the SLO.
+func counts(err error) bool {
+	if err == nil {
+		return true
+	}
+	switch Categorize(err) {
+	case FailCanceled, FailShutdown, FailBatchSize, FailNoBlobClient:
+		return false
+	}
+	return true
+}
+
+// record records the result of a notification at now. It returns the status and true if the error budget
+// started or stopped burning.
+func (s *slo) record(now time.Time, err error) (SLOStatus, bool) {
+	if counts(err) {
+		slot := now.UnixNano() / int64(s.bucket)
+		i := slot % sloBuckets
+		if s.slots[i] != slot {
+			s.slots[i] = slot
+			s.total[i] = 0
+			s.failed[i] = 0
+		}
+		s.total[i]++
+		if err != nil {
+			s.failed[i]++
+		}
+	}
+
+	st := s.status(now)
+	if st.Burning == s.burning {
+		return st, false
+	}
+	s.burning = st.Burning
+	return st, true
+}
+
+// status returns the status at now.
+func (s *slo) status(now time.Time) SLOStatus {
+	oldest := now.UnixNano()/int64(s.bucket) - sloBuckets
+	st := SLOStatus{Objective: s.opts.Objective, Window: s.opts.Window, SuccessRatio: 1}
+	for i, slot := range s.slots {
+		if slot > oldest {
+			st.Total += s.total[i]
+			st.Failed += s.failed[i]
+		}
+	}
+	if st.Total == 0 {
+		return st
+	}
+	failRatio := float64(st.Failed) / float64(st.Total)
+	st.SuccessRatio = 1 - failRatio
+	st.BurnRate = failRatio / (1 - s.opts.Objective)
+	st.Burning = st.Total >= s.opts.MinEvents && st.BurnRate >= s.opts.AlertBurnRate
+	return st
+}
@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+func TestSLOOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    SLOOptions
+		wantErr bool
+	}{
+		{name: "Objective only", opts: SLOOptions{Objective: 0.999}},
+		{name: "All set", opts: SLOOptions{Objective: 0.99, Window: time.Minute, AlertBurnRate: 2, MinEvents: 10}},
+		{name: "Error: no objective", opts: SLOOptions{}, wantErr: true},
+		{name: "Error: objective of 1", opts: SLOOptions{Objective: 1}, wantErr: true},
+		{name: "Error: window under a minute", opts: SLOOptions{Objective: 0.99, Window: time.Second}, wantErr: true},
+		{name: "Error: negative burn rate", opts: SLOOptions{Objective: 0.99, AlertBurnRate: -1}, wantErr: true},
+		{name: "Error: negative min events", opts: SLOOptions{Objective: 0.99, MinEvents: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.opts.Validate()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSLOOptionsValidate(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSLOOptionsValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
+
+func TestSLO(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var alerts []SLOStatus
+
+	c := New()
+	c.now = func() time.Time { return now }
+	err := c.SetSLO(SLOOptions{
+		Objective:     0.99,
+		Window:        time.Hour,
+		AlertBurnRate: 5,
+		MinEvents:     100,
+		OnAlert:       func(s SLOStatus) { alerts = append(alerts, s) },
+	})
+	if err != nil {
+		t.Fatalf("TestSLO: SetSLO(): got err == %s, want err == nil", err)
+	}
+
+	if got := c.Stats().SLO; got == nil || got.Total != 0 || got.SuccessRatio != 1 || got.BurnRate != 0 {
+		t.Fatalf("TestSLO(no results): got %+v, want an empty status with SuccessRatio 1", got)
+	}
+
+	// 90 successes and 10 failures is a 10% failure ratio, 10 times the 1% the objective allows. Failures the
+	// publisher causes itself are not counted.
+	for range 90 {
+		c.Result(1, nil)
+	}
+	for range 10 {
+		c.Result(1, errors.New("connection reset"))
+		c.Result(1, context.Canceled)
+		c.Result(1, models.ErrBatchSize)
+	}
+	got := c.Stats().SLO
+	if got.Total != 100 || got.Failed != 10 || got.SuccessRatio != 0.9 || !got.Burning {
+		t.Errorf("TestSLO(burning): got %+v, want Total 100, Failed 10, SuccessRatio 0.9, Burning", got)
+	}
+	if got.BurnRate < 9.99 || got.BurnRate > 10.01 {
+		t.Errorf("TestSLO(burning): got BurnRate %v, want 10", got.BurnRate)
+	}
+	if len(alerts) != 1 || !alerts[0].Burning {
+		t.Fatalf("TestSLO(burning): got alerts %+v, want one alert that the budget is burning", alerts)
+	}
+
+	// The failures fall out of the window.
+	now = now.Add(time.Hour + time.Minute)
+	c.Result(1, nil)
+	got = c.Stats().SLO
+	if got.Total != 1 || got.Failed != 0 || got.Burning {
+		t.Errorf("TestSLO(recovered): got %+v, want Total 1, Failed 0, not Burning", got)
+	}
+	if len(alerts) != 2 || alerts[1].Burning {
+		t.Errorf("TestSLO(recovered): got alerts %+v, want a second alert that the budget stopped burning", alerts)
+	}
+}
+
+func TestSLOMinEvents(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	alerted := false
+	if err := c.SetSLO(SLOOptions{Objective: 0.999, OnAlert: func(SLOStatus) { alerted = true }}); err != nil {
+		t.Fatalf("TestSLOMinEvents: SetSLO(): got err == %s, want err == nil", err)
+	}
+	for range DefaultSLOMinEvents - 1 {
+		c.Result(1, errors.New("connection reset"))
+	}
+	if got := c.Stats().SLO; got.Burning || alerted {
+		t.Errorf("TestSLOMinEvents: got Burning %v, alerted %v with fewer than MinEvents, want false, false", got.Burning, alerted)
+	}
+
+	if New().Stats().SLO != nil {
+		t.Errorf("TestSLOMinEvents: got Stats().SLO != nil without SetSLO(), want nil")
+	}
+}
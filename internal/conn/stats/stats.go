@@ -0,0 +1,333 @@
+/*
+Package stats collects statistics about the notifications a client sends, so that a service can report the
+health of its ARN publishing without scraping metrics.
+
+The conn package creates a Collector, adds it to the context of each notification it sends with
+WithCollector() and records the result of each notification. The model's SendEvent() calls Payload() once
+it knows whether the data was sent inline or through blob storage. Package functions are no-ops if the
+context has no Collector.
+*/
+package stats
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Failure is the category of a failed notification.
+type Failure string
+
+const (
+	// FailBatchSize is a notification with more items than allowed.
+	FailBatchSize Failure = "batchSize"
+	// FailNoBlobClient is a notification that was too large to send inline by a client without blob storage.
+	FailNoBlobClient Failure = "noBlobClient"
+	// FailShutdown is a notification that was dropped because the client shut down before it was sent.
+	FailShutdown Failure = "shutdown"
+	// FailUploadTimeout is a notification whose blob upload took longer than the upload timeout.
+	FailUploadTimeout Failure = "uploadTimeout"
+	// FailTimeout is a notification whose context deadline or send budget passed.
+	FailTimeout Failure = "timeout"
+	// FailCanceled is a notification whose context was canceled.
+	FailCanceled Failure = "canceled"
+	// FailCircuitOpen is a notification that was not sent because the circuit breaker was open.
+	FailCircuitOpen Failure = "circuitOpen"
+	// FailSpooled is a notification whose event was spooled to disk to be sent once ARN is reachable.
+	FailSpooled Failure = "spooled"
+	// FailThrottled is a notification that ARN or blob storage rejected with 429 Too Many Requests.
+	FailThrottled Failure = "throttled"
+	// FailServerError is a notification that ARN or blob storage rejected with a 5xx status code.
+	FailServerError Failure = "serverError"
+	// FailClientError is a notification that ARN or blob storage rejected with any other status code.
+	FailClientError Failure = "clientError"
+	// FailOther is any other failure, such as a connection error.
+	FailOther Failure = "other"
+)
+
+// Categorize returns the Failure category of err.
+func Categorize(err error) Failure {
+	var re *azcore.ResponseError
+	switch {
+	case errors.Is(err, models.ErrBatchSize):
+		return FailBatchSize
+	case errors.Is(err, models.ErrNoBlobClient):
+		return FailNoBlobClient
+	case errors.Is(err, models.ErrShutdown):
+		return FailShutdown
+	case errors.Is(err, models.ErrUploadTimeout):
+		return FailUploadTimeout
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, models.ErrPromiseTimeout):
+		return FailTimeout
+	case errors.Is(err, context.Canceled), errors.Is(err, models.ErrPromiseCanceled):
+		return FailCanceled
+	case errors.Is(err, models.ErrCircuitOpen):
+		return FailCircuitOpen
+	case errors.Is(err, models.ErrSpooled):
+		return FailSpooled
+	case errors.As(err, &re):
+		switch {
+		case re.StatusCode == http.StatusTooManyRequests:
+			return FailThrottled
+		case re.StatusCode >= 500:
+			return FailServerError
+		}
+		return FailClientError
+	}
+	return FailOther
+}
+
+// Stats are statistics about the notifications sent by a client since it was created.
+type Stats struct {
+	// Since is when the client was created.
+	Since time.Time
+	// Sent is the number of notifications that were sent.
+	Sent int64
+	// Failed is the number of notifications that failed.
+	Failed int64
+	// Failures is the number of failed notifications by category.
+	Failures map[Failure]int64
+	// Items is the number of items (resources) in the notifications that were sent.
+	Items int64
+	// InlineEvents is the number of events sent with their data inline.
+	InlineEvents int64
+	// InlineBytes is the number of bytes of data sent inline.
+	InlineBytes int64
+	// BlobEvents is the number of events sent with their data in blob storage.
+	BlobEvents int64
+	// BlobBytes is the number of bytes of data sent through blob storage.
+	BlobBytes int64
+	// QueueDepth is the number of notifications waiting to be sent.
+	QueueDepth int
+	// LastError is the error of the most recent failed notification. nil if none have failed.
+	LastError error
+	// LastErrorTime is when LastError happened.
+	LastErrorTime time.Time
+	// RecentErrors are the errors of the most recent failed notifications, oldest first. At most
+	// MaxRecentErrors are kept.
+	RecentErrors []ErrorRecord
+	// BlobUploadsAborted is the number of blob uploads that were cut short because the notification's
+	// context ended, which includes a shutdown of the client.
+	BlobUploadsAborted int64
+	// BlobCanaries is the number of notifications sent through blob storage as a canary, see
+	// client.WithBlobCanary().
+	BlobCanaries int64
+	// BlobCanaryFailures is the number of blob canaries that failed.
+	BlobCanaryFailures int64
+	// LastBlobCanary is when the most recent blob canary was sent.
+	LastBlobCanary time.Time
+	// LastBlobCanaryError is the error of the most recent blob canary. nil if it succeeded.
+	LastBlobCanaryError error
+	// SLO is the success ratio and error budget burn rate over the SLO's window. nil if no SLO was set.
+	SLO *SLOStatus
+}
+
+// MaxRecentErrors is the number of errors kept in Stats.RecentErrors.
+const MaxRecentErrors = 10
+
+// ErrorRecord is the error of a failed notification and when it happened.
+type ErrorRecord struct {
+	// Time is when the notification failed.
+	Time time.Time
+	// Err is the error of the notification.
+	Err error
+}
+
+// AvgBatchSize returns the average number of items in the notifications that were sent.
+func (s Stats) AvgBatchSize() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Items) / float64(s.Sent)
+}
+
+// Collector collects Stats. Thread-safe.
+type Collector struct {
+	mu    sync.Mutex
+	stats Stats
+	slo   *slo
+	// tees also record what is recorded to the Collector, see Tee().
+	tees []*Collector
+
+	now func() time.Time
+}
+
+// New creates a new Collector.
+func New() *Collector {
+	c := &Collector{now: time.Now}
+	c.stats = Stats{Since: c.now(), Failures: map[Failure]int64{}}
+	return c
+}
+
+// Tee returns a new Collector that also records everything recorded to c from now on. It has no SLO.
+func (c *Collector) Tee() *Collector {
+	t := New()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tees = append(c.tees, t)
+	return t
+}
+
+// SetSLO tracks the SLO in o, which is reported in Stats.SLO. This must be called before any results are
+// recorded.
+func (c *Collector) SetSLO(o SLOOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slo = newSLO(o)
+	return nil
+}
+
+// Result records the result of a notification with items items. If an SLO is set and its error budget
+// started or stopped burning, SLOOptions.OnAlert is called before this returns.
+func (c *Collector) Result(items int, err error) {
+	c.mu.Lock()
+	st, changed := c.result(items, err)
+	var onAlert func(SLOStatus)
+	if changed {
+		onAlert = c.slo.opts.OnAlert
+	}
+	for _, t := range c.tees {
+		t.Result(items, err)
+	}
+	c.mu.Unlock()
+
+	if onAlert != nil {
+		onAlert(st)
+	}
+}
+
+// result records the result of a notification. It returns the SLO status and true if the error budget
+// started or stopped burning. Must be called with c.mu held.
+func (c *Collector) result(items int, err error) (SLOStatus, bool) {
+	var (
+		st      SLOStatus
+		changed bool
+	)
+	if c.slo != nil {
+		st, changed = c.slo.record(c.now(), err)
+	}
+
+	if err == nil {
+		c.stats.Sent++
+		c.stats.Items += int64(items)
+		return st, changed
+	}
+	now := c.now()
+	c.stats.Failed++
+	c.stats.Failures[Categorize(err)]++
+	c.stats.LastError = err
+	c.stats.LastErrorTime = now
+	if len(c.stats.RecentErrors) == MaxRecentErrors {
+		c.stats.RecentErrors = slices.Delete(c.stats.RecentErrors, 0, 1)
+	}
+	c.stats.RecentErrors = append(c.stats.RecentErrors, ErrorRecord{Time: now, Err: err})
+	return st, changed
+}
+
+// payload records the data of an event that was sent.
+func (c *Collector) payload(inline bool, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.tees {
+		t.payload(inline, bytes)
+	}
+	if inline {
+		c.stats.InlineEvents++
+		c.stats.InlineBytes += bytes
+		return
+	}
+	c.stats.BlobEvents++
+	c.stats.BlobBytes += bytes
+}
+
+// blobAborted records a blob upload that was cut short.
+func (c *Collector) blobAborted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.tees {
+		t.blobAborted()
+	}
+	c.stats.BlobUploadsAborted++
+}
+
+// blobCanary records the result of a blob canary.
+func (c *Collector) blobCanary(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.tees {
+		t.blobCanary(err)
+	}
+	c.stats.BlobCanaries++
+	c.stats.LastBlobCanary = c.now()
+	c.stats.LastBlobCanaryError = err
+	if err != nil {
+		c.stats.BlobCanaryFailures++
+	}
+}
+
+// Stats returns a copy of the Stats. QueueDepth is not set, as the Collector does not know it.
+func (c *Collector) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Failures = maps.Clone(c.stats.Failures)
+	s.RecentErrors = slices.Clone(c.stats.RecentErrors)
+	if c.slo != nil {
+		st := c.slo.status(c.now())
+		s.SLO = &st
+	}
+	return s
+}
+
+type ctxKey struct{}
+
+// WithCollector returns a context that Payload() records to c with.
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// Payload records that an event with bytes of data was sent, inline or through blob storage, to the
+// Collector in ctx. This should only be called once the event was sent.
+func Payload(ctx context.Context, inline bool, bytes int64) {
+	if ctx == nil {
+		return
+	}
+	if c, ok := ctx.Value(ctxKey{}).(*Collector); ok {
+		c.payload(inline, bytes)
+	}
+}
+
+// BlobCanary records the result of a blob canary to the Collector in ctx. err is nil if the canary was
+// uploaded to blob storage and accepted by the receiver.
+func BlobCanary(ctx context.Context, err error) {
+	if ctx == nil {
+		return
+	}
+	if c, ok := ctx.Value(ctxKey{}).(*Collector); ok {
+		c.blobCanary(err)
+	}
+}
+
+// BlobAborted records to the Collector in ctx that a blob upload was cut short because ctx ended.
+func BlobAborted(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if c, ok := ctx.Value(ctxKey{}).(*Collector); ok {
+		c.blobAborted()
+	}
+}
@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestCategorize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want Failure
+	}{
+		{name: "Batch size", err: fmt.Errorf("%w: 1001 items", models.ErrBatchSize), want: FailBatchSize},
+		{name: "No blob client", err: models.ErrNoBlobClient, want: FailNoBlobClient},
+		{name: "Shutdown", err: fmt.Errorf("%w: %w", models.ErrShutdown, context.Canceled), want: FailShutdown},
+		{name: "Upload timeout", err: fmt.Errorf("%w: %w", models.ErrUploadTimeout, context.DeadlineExceeded), want: FailUploadTimeout},
+		{name: "Deadline", err: context.DeadlineExceeded, want: FailTimeout},
+		{name: "Promise timeout", err: models.ErrPromiseTimeout, want: FailTimeout},
+		{name: "Canceled", err: fmt.Errorf("send: %w", context.Canceled), want: FailCanceled},
+		{name: "Circuit open", err: fmt.Errorf("%w: not queued", models.ErrCircuitOpen), want: FailCircuitOpen},
+		{name: "Spooled", err: fmt.Errorf("%w: connection refused", models.ErrSpooled), want: FailSpooled},
+		{name: "Throttled", err: &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}, want: FailThrottled},
+		{name: "Server error", err: fmt.Errorf("send: %w", &azcore.ResponseError{StatusCode: http.StatusBadGateway}), want: FailServerError},
+		{name: "Client error", err: &azcore.ResponseError{StatusCode: http.StatusForbidden}, want: FailClientError},
+		{name: "Other", err: errors.New("connection reset"), want: FailOther},
+	}
+
+	for _, test := range tests {
+		if got := Categorize(test.err); got != test.want {
+			t.Errorf("TestCategorize(%s): got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	ctx := WithCollector(context.Background(), c)
+
+	c.Result(10, nil)
+	Payload(ctx, true, 100)
+	c.Result(30, nil)
+	Payload(ctx, false, 5000)
+	lastErr := &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}
+	c.Result(5, lastErr)
+	canaryErr := errors.New("upload failed")
+	BlobCanary(ctx, canaryErr)
+	BlobCanary(ctx, nil)
+	// A context without a Collector is ignored.
+	Payload(context.Background(), true, 100)
+	BlobCanary(context.Background(), canaryErr)
+
+	s := c.Stats()
+	if s.Sent != 2 || s.Failed != 1 || s.Items != 40 {
+		t.Errorf("TestCollector: got Sent %d, Failed %d, Items %d, want 2, 1, 40", s.Sent, s.Failed, s.Items)
+	}
+	if s.InlineEvents != 1 || s.InlineBytes != 100 || s.BlobEvents != 1 || s.BlobBytes != 5000 {
+		t.Errorf("TestCollector: got inline %d/%d bytes, blob %d/%d bytes, want 1/100, 1/5000", s.InlineEvents, s.InlineBytes, s.BlobEvents, s.BlobBytes)
+	}
+	if s.Failures[FailThrottled] != 1 {
+		t.Errorf("TestCollector: got Failures %v, want throttled: 1", s.Failures)
+	}
+	if s.LastError != lastErr || s.LastErrorTime.IsZero() {
+		t.Errorf("TestCollector: got LastError %v at %v, want %v", s.LastError, s.LastErrorTime, lastErr)
+	}
+	if s.BlobCanaries != 2 || s.BlobCanaryFailures != 1 || s.LastBlobCanaryError != nil || s.LastBlobCanary.IsZero() {
+		t.Errorf("TestCollector: got BlobCanaries %d, BlobCanaryFailures %d, LastBlobCanaryError %v at %v, want 2, 1, nil", s.BlobCanaries, s.BlobCanaryFailures, s.LastBlobCanaryError, s.LastBlobCanary)
+	}
+	if got := s.AvgBatchSize(); got != 20 {
+		t.Errorf("TestCollector: got AvgBatchSize() %v, want 20", got)
+	}
+
+	// The returned Stats must not share the Failures map.
+	s.Failures[FailOther] = 10
+	if c.Stats().Failures[FailOther] != 0 {
+		t.Errorf("TestCollector: Stats() returned the Collector's Failures map")
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	ctx := WithCollector(context.Background(), c)
+	for i := 0; i < MaxRecentErrors+2; i++ {
+		c.Result(1, fmt.Errorf("error %d", i))
+	}
+	BlobAborted(ctx)
+	BlobAborted(context.Background())
+
+	s := c.Stats()
+	if len(s.RecentErrors) != MaxRecentErrors {
+		t.Fatalf("TestRecentErrors: got %d RecentErrors, want %d", len(s.RecentErrors), MaxRecentErrors)
+	}
+	if got := s.RecentErrors[0].Err.Error(); got != "error 2" {
+		t.Errorf("TestRecentErrors: got oldest error %q, want %q", got, "error 2")
+	}
+	if got := s.RecentErrors[MaxRecentErrors-1].Err; got != s.LastError {
+		t.Errorf("TestRecentErrors: got newest error %v, want LastError %v", got, s.LastError)
+	}
+	if s.BlobUploadsAborted != 1 {
+		t.Errorf("TestRecentErrors: got BlobUploadsAborted %d, want 1", s.BlobUploadsAborted)
+	}
+
+	// The returned Stats must not share RecentErrors.
+	s.RecentErrors[0].Err = nil
+	if c.Stats().RecentErrors[0].Err == nil {
+		t.Errorf("TestRecentErrors: Stats() returned the Collector's RecentErrors")
+	}
+}
+
+func TestTee(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	ctx := WithCollector(context.Background(), c)
+	c.Result(1, nil)
+	Payload(ctx, true, 100)
+
+	tee := c.Tee()
+	c.Result(2, nil)
+	c.Result(1, models.ErrShutdown)
+	Payload(ctx, false, 5000)
+	BlobAborted(ctx)
+	BlobCanary(ctx, nil)
+
+	s := tee.Stats()
+	if s.Sent != 1 || s.Items != 2 || s.Failed != 1 || s.Failures[FailShutdown] != 1 {
+		t.Errorf("TestTee: got Sent %d, Items %d, Failed %d, Failures %v, want 1, 2, 1, shutdown: 1", s.Sent, s.Items, s.Failed, s.Failures)
+	}
+	if s.InlineEvents != 0 || s.BlobEvents != 1 || s.BlobUploadsAborted != 1 || s.BlobCanaries != 1 {
+		t.Errorf("TestTee: got InlineEvents %d, BlobEvents %d, BlobUploadsAborted %d, BlobCanaries %d, want 0, 1, 1, 1", s.InlineEvents, s.BlobEvents, s.BlobUploadsAborted, s.BlobCanaries)
+	}
+	if got := c.Stats(); got.Sent != 2 || got.Failed != 1 {
+		t.Errorf("TestTee: got Sent %d, Failed %d in the Collector, want 2, 1", got.Sent, got.Failed)
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/pprof"
+	"runtime/trace"
 	"sync/atomic"
 	"time"
 
+	"github.com/Azure/arn-sdk/internal/build"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
 	"github.com/Azure/retry/exponential"
@@ -71,7 +75,7 @@ func newCredCache(client getCreder, options ...ccOption) (*credCache, error) {
 		if err := cc.refreshCred(context.Background(), cc.now().UTC()); err != nil {
 			return nil, fmt.Errorf("credCache: problem getting credential: %w", err)
 		}
-		go cc.refresher()
+		go pprof.Do(context.Background(), pprof.Labels(build.PprofLabel, "storage.credRefresher"), cc.refresher)
 	}
 
 	return cc, nil
@@ -101,7 +105,7 @@ func (c *credCache) get(ctx context.Context) (*service.UserDelegationCredential,
 }
 
 // refresher is a background goroutine that refreshes the user delegation credential.
-func (c *credCache) refresher() {
+func (c *credCache) refresher(ctx context.Context) {
 	const (
 		nextRefresh = 23 * time.Hour
 	)
@@ -112,7 +116,6 @@ func (c *credCache) refresher() {
 		// we should panic if it does.
 		panic(err)
 	}
-	ctx := context.Background()
 
 	for {
 		next := time.Now().Add(nextRefresh)
@@ -143,6 +146,7 @@ func (c *credCache) refresh(ctx context.Context, boff *exponential.Backoff, next
 			}
 			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 			defer cancel()
+			defer trace.StartRegion(ctx, "arn.refreshCred").End()
 			if err := c.refreshCred(ctx, c.now().UTC()); err != nil {
 				c.log.Error(fmt.Sprintf("credCache: problem refreshing credential: %s", err.Error()))
 				return err
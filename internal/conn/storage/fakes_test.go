@@ -3,8 +3,13 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
@@ -21,12 +26,22 @@ func (f fakeEncoder) Encode() string {
 
 type fakeUploader struct {
 	err error
+	// opts are the options of the last upload.
+	opts *blockblob.UploadBufferOptions
 }
 
 func (f *fakeUploader) UploadBuffer(ctx context.Context, buffer []byte, o *blockblob.UploadBufferOptions) (blockblob.UploadBufferResponse, error) {
+	f.opts = o
 	return blockblob.UploadBufferResponse{}, f.err
 }
 
+// fakeWrapper is an encrypt.KeyWrapper that returns the key as is.
+type fakeWrapper struct{}
+
+func (fakeWrapper) WrapKey(ctx context.Context, key []byte) (encrypt.Wrapped, error) {
+	return encrypt.Wrapped{KeyID: "https://vault/keys/k/1", Alg: "RSA-OAEP-256", Key: key}, nil
+}
+
 type fakeCreder struct {
 	err error
 }
@@ -61,3 +76,49 @@ func (f *fakeContClient) Create(ctx context.Context, options *container.CreateOp
 	}
 	return container.CreateResponse{}, nil
 }
+
+// fakeContainer is a container that holds blobs in memory. It implements uploadBuffer and createContainer.
+// Uploads fail with ContainerNotFound until Create() is called, unless exists is set.
+type fakeContainer struct {
+	exists  bool
+	creates int
+	blobs   [][]byte
+}
+
+func (f *fakeContainer) UploadBuffer(ctx context.Context, buffer []byte, o *blockblob.UploadBufferOptions) (blockblob.UploadBufferResponse, error) {
+	if !f.exists {
+		return blockblob.UploadBufferResponse{}, &azcore.ResponseError{ErrorCode: string(bloberror.ContainerNotFound)}
+	}
+	f.blobs = append(f.blobs, buffer)
+	return blockblob.UploadBufferResponse{}, nil
+}
+
+func (f *fakeContainer) Create(ctx context.Context, options *container.CreateOptions) (container.CreateResponse, error) {
+	f.creates++
+	f.exists = true
+	return container.CreateResponse{}, nil
+}
+
+// fakeStreamer is an uploadStream that returns the errors in errs in order, one per upload, and records
+// what was read from each stream.
+type fakeStreamer struct {
+	errs []error
+	read []string
+	// opts are the options of the last upload.
+	opts *blockblob.UploadStreamOptions
+}
+
+func (f *fakeStreamer) UploadStream(ctx context.Context, body io.Reader, o *blockblob.UploadStreamOptions) (blockblob.UploadStreamResponse, error) {
+	f.opts = o
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return blockblob.UploadStreamResponse{}, err
+	}
+	f.read = append(f.read, string(b))
+	if len(f.errs) == 0 {
+		return blockblob.UploadStreamResponse{}, nil
+	}
+	err = f.errs[0]
+	f.errs = f.errs[1:]
+	return blockblob.UploadStreamResponse{}, err
+}
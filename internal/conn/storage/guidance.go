@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/google/uuid"
+)
+
+// AccessError is returned when blob storage refuses a request because of the storage account's network
+// rules or the publisher's role assignments. These are returned by storage as a 403 with little detail, so
+// Guidance says what is likely wrong and how to fix it.
+type AccessError struct {
+	// Code is the error code returned by blob storage, such as AuthorizationFailure.
+	Code string
+	// Private is true if the storage account resolved only to private addresses, which means the request
+	// went through a private endpoint.
+	Private bool
+	// Guidance describes the likely cause and how to fix it.
+	Guidance string
+	// Err is the error returned by blob storage.
+	Err error
+}
+
+// Error implements error.Error().
+func (e *AccessError) Error() string {
+	return fmt.Sprintf("blob storage refused the request (%s): %s: %v", e.Code, e.Guidance, e.Err)
+}
+
+// Unwrap returns the error returned by blob storage.
+func (e *AccessError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	guideFirewall = "the storage account's firewall does not allow this publisher's IP address, or public network " +
+		"access is disabled. Allow the publisher's network in the storage account's networking rules, or add a " +
+		"private endpoint for the account in the publisher's virtual network"
+	guidePrivateEndpoint = "the request went through a private endpoint that the storage account did not accept. " +
+		"Check that the private endpoint connection is approved and that its private DNS zone " +
+		"(privatelink.blob.core.windows.net) is linked to the publisher's virtual network"
+	guideSourceIP = "the SAS or network rules do not allow this publisher's IP address. Check the SAS IP range " +
+		"and the storage account's networking rules"
+	guideRBAC = "the publisher's identity is missing a role on the storage account. It needs Storage Blob Data " +
+		"Contributor (to upload) and Storage Blob Delegator or Storage Blob Data Contributor (to get a user " +
+		"delegation key for the SAS). Role assignments can take several minutes to take effect"
+)
+
+// lookupFunc resolves a host to its addresses.
+type lookupFunc func(ctx context.Context, host string) ([]netip.Addr, error)
+
+// lookupHost resolves host with the default resolver.
+func lookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// resolve resolves host with the lookup set for tests or the default resolver.
+func (c *Client) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if c.lookup != nil {
+		return c.lookup(ctx, host)
+	}
+	return lookupHost(ctx, host)
+}
+
+// allPrivate returns true if addrs is not empty and every address is private or loopback.
+func allPrivate(addrs []netip.Addr) bool {
+	if len(addrs) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(addrs, func(a netip.Addr) bool {
+		a = a.Unmap()
+		return !a.IsPrivate() && !a.IsLoopback()
+	})
+}
+
+// explain returns err as an *AccessError with guidance if blob storage refused the request because of
+// network rules or role assignments. Otherwise err is returned as is.
+func (c *Client) explain(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ae *AccessError
+	if errors.As(err, &ae) {
+		return err
+	}
+
+	switch {
+	case bloberror.HasCode(err, bloberror.AuthorizationFailure):
+		ae = &AccessError{Code: string(bloberror.AuthorizationFailure), Guidance: guideFirewall, Err: err}
+		if c.resolvesPrivate(ctx) {
+			ae.Private = true
+			ae.Guidance = guidePrivateEndpoint
+		}
+	case bloberror.HasCode(err, bloberror.AuthorizationSourceIPMismatch):
+		ae = &AccessError{Code: string(bloberror.AuthorizationSourceIPMismatch), Guidance: guideSourceIP, Err: err}
+	case bloberror.HasCode(err, bloberror.AuthorizationPermissionMismatch):
+		ae = &AccessError{Code: string(bloberror.AuthorizationPermissionMismatch), Guidance: guideRBAC, Err: err}
+	default:
+		return err
+	}
+	return ae
+}
+
+// resolvesPrivate returns true if the storage endpoint resolves only to private addresses.
+func (c *Client) resolvesPrivate(ctx context.Context) bool {
+	c.mu.RLock()
+	endpoint := c.endpoint
+	c.mu.RUnlock()
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	addrs, err := c.resolve(ctx, u.Hostname())
+	if err != nil {
+		return false
+	}
+	return allPrivate(addrs)
+}
+
+// Preflight checks that the publisher can upload a blob and that the host in the blob's SAS link resolves,
+// which is needed for ARN to read it. It uploads a small blob named "preflight-<uuid>" to today's container.
+// If the host resolves only to private addresses, a warning is logged, as ARN reads the blob from outside
+// the publisher's network and cannot use a private endpoint. Upload errors caused by network rules or role
+// assignments are returned as an *AccessError.
+func (c *Client) Preflight(ctx context.Context) error {
+	u, err := c.Upload(ctx, "preflight-"+uuid.New().String(), []byte("{}"))
+	if err != nil {
+		return fmt.Errorf("preflight could not upload a blob: %w", err)
+	}
+
+	addrs, err := c.resolve(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("preflight: the SAS host %s does not resolve: %w", u.Hostname(), err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("preflight: the SAS host %s resolved to no addresses", u.Hostname())
+	}
+	if allPrivate(addrs) {
+		c.log.Warn(
+			"ARN blob storage resolves only to private addresses from the publisher, ARN can only read blobs if the storage account also allows public access",
+			"host", u.Hostname(),
+			"addresses", fmt.Sprint(addrs),
+		)
+	}
+	return nil
+}
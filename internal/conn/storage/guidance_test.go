@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// fakeLookup resolves every host to addrs, or fails with err.
+func fakeLookup(err error, addrs ...string) lookupFunc {
+	return func(ctx context.Context, host string) ([]netip.Addr, error) {
+		if err != nil {
+			return nil, err
+		}
+		var got []netip.Addr
+		for _, a := range addrs {
+			got = append(got, netip.MustParseAddr(a))
+		}
+		return got, nil
+	}
+}
+
+func respErr(code bloberror.Code) error {
+	return &azcore.ResponseError{ErrorCode: string(code), StatusCode: http.StatusForbidden}
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		err          error
+		lookup       lookupFunc
+		wantGuidance string
+		wantPrivate  bool
+	}{
+		{name: "Not an access error", err: errors.New("boom"), lookup: fakeLookup(nil, "20.60.1.1")},
+		{
+			name:         "Firewall",
+			err:          respErr(bloberror.AuthorizationFailure),
+			lookup:       fakeLookup(nil, "20.60.1.1"),
+			wantGuidance: guideFirewall,
+		},
+		{
+			name:         "Firewall when the host does not resolve",
+			err:          respErr(bloberror.AuthorizationFailure),
+			lookup:       fakeLookup(errors.New("no such host")),
+			wantGuidance: guideFirewall,
+		},
+		{
+			name:         "Private endpoint",
+			err:          respErr(bloberror.AuthorizationFailure),
+			lookup:       fakeLookup(nil, "10.1.2.3"),
+			wantGuidance: guidePrivateEndpoint,
+			wantPrivate:  true,
+		},
+		{
+			name:         "Source IP",
+			err:          respErr(bloberror.AuthorizationSourceIPMismatch),
+			lookup:       fakeLookup(nil, "20.60.1.1"),
+			wantGuidance: guideSourceIP,
+		},
+		{
+			name:         "RBAC",
+			err:          respErr(bloberror.AuthorizationPermissionMismatch),
+			lookup:       fakeLookup(nil, "20.60.1.1"),
+			wantGuidance: guideRBAC,
+		},
+	}
+
+	for _, test := range tests {
+		c := &Client{endpoint: "https://account.blob.core.windows.net", lookup: test.lookup}
+		err := c.explain(context.Background(), test.err)
+		if !errors.Is(err, test.err) {
+			t.Errorf("TestExplain(%s): got err == %v, want it to wrap %v", test.name, err, test.err)
+		}
+
+		var ae *AccessError
+		if !errors.As(err, &ae) {
+			if test.wantGuidance != "" {
+				t.Errorf("TestExplain(%s): got err == %v, want *AccessError", test.name, err)
+			}
+			continue
+		}
+		if test.wantGuidance == "" {
+			t.Errorf("TestExplain(%s): got *AccessError, want the error as is", test.name)
+			continue
+		}
+		if ae.Guidance != test.wantGuidance {
+			t.Errorf("TestExplain(%s): got guidance %q, want %q", test.name, ae.Guidance, test.wantGuidance)
+		}
+		if ae.Private != test.wantPrivate {
+			t.Errorf("TestExplain(%s): got Private == %v, want %v", test.name, ae.Private, test.wantPrivate)
+		}
+		if again := c.explain(context.Background(), err); again != err {
+			t.Errorf("TestExplain(%s): explaining an *AccessError again changed it to %v", test.name, again)
+		}
+	}
+}
+
+// urlUploader returns u for every upload, or fails with err.
+type urlUploader struct {
+	u   string
+	err error
+}
+
+func (f urlUploader) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return url.Parse(f.u)
+}
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		uploader urlUploader
+		lookup   lookupFunc
+		wantErr  bool
+	}{
+		{
+			name:     "Public",
+			uploader: urlUploader{u: "https://account.blob.core.windows.net/c/b?sig=x"},
+			lookup:   fakeLookup(nil, "20.60.1.1"),
+		},
+		{
+			name:     "Private only logs a warning",
+			uploader: urlUploader{u: "https://account.blob.core.windows.net/c/b?sig=x"},
+			lookup:   fakeLookup(nil, "10.1.2.3"),
+		},
+		{
+			name:     "Error: upload",
+			uploader: urlUploader{err: respErr(bloberror.AuthorizationFailure)},
+			lookup:   fakeLookup(nil, "20.60.1.1"),
+			wantErr:  true,
+		},
+		{
+			name:     "Error: does not resolve",
+			uploader: urlUploader{u: "https://account.blob.core.windows.net/c/b?sig=x"},
+			lookup:   fakeLookup(errors.New("no such host")),
+			wantErr:  true,
+		},
+		{
+			name:     "Error: no addresses",
+			uploader: urlUploader{u: "https://account.blob.core.windows.net/c/b?sig=x"},
+			lookup:   fakeLookup(nil),
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		c := &Client{log: slog.Default(), fakeUploader: test.uploader, lookup: test.lookup}
+		err := c.Preflight(context.Background())
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestPreflight(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestPreflight(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
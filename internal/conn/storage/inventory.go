@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+)
+
+// contPrefix is the prefix of the name of every container the client uploads to.
+const contPrefix = "arm-ext-nt"
+
+// BlobInfo describes a blob uploaded by the client.
+type BlobInfo struct {
+	// Container is the name of the container, which holds the blobs of one day, or of one shard of a day
+	// with WithContainerShards().
+	Container string
+	// Name is the name of the blob.
+	Name string
+	// ID is the id the blob was uploaded with, which is the Name without its LegacyBlobExt or JSONBlobExt
+	// extension.
+	ID string
+	// Size is the size of the blob in bytes.
+	Size int64
+	// Created is when the blob was created.
+	Created time.Time
+	// LastModified is when the blob was last written.
+	LastModified time.Time
+}
+
+// lister lists containers and blobs. This is implemented by serviceLister and faked in tests.
+type lister interface {
+	// containers returns the names of the containers that start with prefix.
+	containers(ctx context.Context, prefix string) iter.Seq2[string, error]
+	// blobs returns the blobs in the container.
+	blobs(ctx context.Context, container string) iter.Seq2[BlobInfo, error]
+}
+
+// containerPrefix returns the prefix of the containers of this client, including the trailing hyphen
+// before the date.
+func (c *Client) containerPrefix() string {
+	if c.contExt == "" {
+		return contPrefix + "-"
+	}
+	return contPrefix + "-" + c.contExt + "-"
+}
+
+// List returns the notification blobs uploaded by this client, or any client with the same ContainerExt,
+// that were last written at or after since. It can be used by reconciliation jobs to check that every
+// blob was referenced by a delivered event. Blobs are listed a container, which holds one day, at a time,
+// oldest day first. Iteration stops after the first error.
+func (c *Client) List(ctx context.Context, since time.Time) iter.Seq2[BlobInfo, error] {
+	return func(yield func(BlobInfo, error) bool) {
+		l := c.lister()
+		if l == nil {
+			yield(BlobInfo{}, errors.New("List() is not supported with a fake uploader"))
+			return
+		}
+
+		prefix := c.containerPrefix()
+		for name, err := range l.containers(ctx, prefix) {
+			if err != nil {
+				yield(BlobInfo{}, err)
+				return
+			}
+			// Without a ContainerExt, the prefix also matches the containers of clients with one. Those
+			// do not have a date after the prefix.
+			day, ok := containerDay(strings.TrimPrefix(name, prefix))
+			if !ok {
+				continue
+			}
+			if !day.Add(24 * time.Hour).After(since) {
+				continue
+			}
+
+			for b, err := range l.blobs(ctx, name) {
+				if err != nil {
+					yield(BlobInfo{}, err)
+					return
+				}
+				if b.LastModified.Before(since) {
+					continue
+				}
+				if !yield(b, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// containerDay returns the day of a container from its name after the prefix, which is the date and, for
+// a sharded container, a hyphen and the shard index. It returns false if the name is not of this form.
+func containerDay(s string) (time.Time, bool) {
+	if len(s) < len(time.DateOnly) {
+		return time.Time{}, false
+	}
+	day, err := time.Parse(time.DateOnly, s[:len(time.DateOnly)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	shard := s[len(time.DateOnly):]
+	if shard == "" {
+		return day, true
+	}
+	if shard[0] != '-' {
+		return time.Time{}, false
+	}
+	i, err := strconv.Atoi(shard[1:])
+	if err != nil || strconv.Itoa(i) != shard[1:] || i < 0 || i >= MaxContainerShards {
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// lister returns the lister for the client, nil if it has none.
+func (c *Client) lister() lister {
+	if c.fakeLister != nil {
+		return c.fakeLister
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cli == nil {
+		return nil
+	}
+	return serviceLister{cli: c.cli}
+}
+
+// serviceLister implements lister with a *service.Client.
+type serviceLister struct {
+	cli *service.Client
+}
+
+func (s serviceLister) containers(ctx context.Context, prefix string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		pager := s.cli.NewListContainersPager(&service.ListContainersOptions{Prefix: &prefix})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				yield("", err)
+				return
+			}
+			for _, item := range page.ContainerItems {
+				if item == nil || item.Name == nil {
+					continue
+				}
+				if !yield(*item.Name, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s serviceLister) blobs(ctx context.Context, cont string) iter.Seq2[BlobInfo, error] {
+	return func(yield func(BlobInfo, error) bool) {
+		pager := s.cli.NewContainerClient(cont).NewListBlobsFlatPager(&container.ListBlobsFlatOptions{})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				yield(BlobInfo{}, err)
+				return
+			}
+			if page.Segment == nil {
+				continue
+			}
+			for _, item := range page.Segment.BlobItems {
+				if item == nil || item.Name == nil {
+					continue
+				}
+				b := BlobInfo{Container: cont, Name: *item.Name, ID: blobID(*item.Name)}
+				if p := item.Properties; p != nil {
+					b.Size = deref(p.ContentLength)
+					b.Created = deref(p.CreationTime)
+					b.LastModified = deref(p.LastModified)
+				}
+				if !yield(b, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// deref returns the value p points to, or the zero value if p is nil.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// blobID returns the id a blob named name was uploaded with.
+func blobID(name string) string {
+	for _, ext := range []string{LegacyBlobExt, JSONBlobExt} {
+		if id, ok := strings.CutSuffix(name, ext); ok {
+			return id
+		}
+	}
+	return name
+}
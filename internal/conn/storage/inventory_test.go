@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// fakeInventory is a lister over a fixed set of containers and their blobs.
+type fakeInventory struct {
+	names []string
+	items map[string][]BlobInfo
+	err   error
+}
+
+func (f fakeInventory) containers(ctx context.Context, prefix string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for _, c := range f.names {
+			if !strings.HasPrefix(c, prefix) {
+				continue
+			}
+			if !yield(c, nil) {
+				return
+			}
+		}
+		if f.err != nil {
+			yield("", f.err)
+		}
+	}
+}
+
+func (f fakeInventory) blobs(ctx context.Context, cont string) iter.Seq2[BlobInfo, error] {
+	return func(yield func(BlobInfo, error) bool) {
+		for _, b := range f.items[cont] {
+			if !yield(b, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int, h int) time.Time {
+		return time.Date(2024, 5, d, h, 0, 0, 0, time.UTC)
+	}
+	blob := func(cont, id string, modified time.Time) BlobInfo {
+		return BlobInfo{Container: cont, Name: id + ".txt", ID: id, Size: 10, Created: modified, LastModified: modified}
+	}
+
+	inv := fakeInventory{
+		names: []string{
+			"arm-ext-nt-2024-05-01",
+			"arm-ext-nt-2024-05-02",
+			"arm-ext-nt-team-2024-05-02",
+			"arm-ext-nt-other",
+			"arm-ext-nt-sharded-2024-05-02-0",
+			"arm-ext-nt-sharded-2024-05-02-1",
+			"arm-ext-nt-sharded-2024-05-02-x",
+		},
+		items: map[string][]BlobInfo{
+			"arm-ext-nt-2024-05-01": {
+				blob("arm-ext-nt-2024-05-01", "a", day(1, 10)),
+			},
+			"arm-ext-nt-2024-05-02": {
+				blob("arm-ext-nt-2024-05-02", "b", day(2, 1)),
+				blob("arm-ext-nt-2024-05-02", "c", day(2, 12)),
+			},
+			"arm-ext-nt-team-2024-05-02": {
+				blob("arm-ext-nt-team-2024-05-02", "d", day(2, 12)),
+			},
+			"arm-ext-nt-sharded-2024-05-02-0": {
+				blob("arm-ext-nt-sharded-2024-05-02-0", "e", day(2, 12)),
+			},
+			"arm-ext-nt-sharded-2024-05-02-1": {
+				blob("arm-ext-nt-sharded-2024-05-02-1", "f", day(2, 12)),
+			},
+			"arm-ext-nt-sharded-2024-05-02-x": {
+				blob("arm-ext-nt-sharded-2024-05-02-x", "g", day(2, 12)),
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ext     string
+		since   time.Time
+		inv     fakeInventory
+		want    []string
+		wantErr bool
+	}{
+		{name: "All", since: day(1, 0), inv: inv, want: []string{"a", "b", "c"}},
+		{name: "Skips earlier days and blobs", since: day(2, 6), inv: inv, want: []string{"c"}},
+		{name: "Container ext", ext: "team", since: day(1, 0), inv: inv, want: []string{"d"}},
+		{name: "Sharded containers", ext: "sharded", since: day(1, 0), inv: inv, want: []string{"e", "f"}},
+		{name: "Nothing since", since: day(3, 0), inv: inv},
+		{
+			name:    "Error: listing fails",
+			since:   day(1, 0),
+			inv:     fakeInventory{names: inv.names, items: inv.items, err: errors.New("boom")},
+			want:    []string{"a", "b", "c"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		c := &Client{contExt: test.ext, fakeLister: test.inv}
+
+		var got []string
+		var err error
+		for b, e := range c.List(context.Background(), test.since) {
+			if e != nil {
+				err = e
+				break
+			}
+			got = append(got, b.ID)
+		}
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestList(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestList(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestList(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestListFake(t *testing.T) {
+	t.Parallel()
+
+	c := &Client{fakeUploader: urlUploader{}}
+	for _, err := range c.List(context.Background(), time.Time{}) {
+		if err == nil {
+			t.Errorf("TestListFake: got err == nil, want err != nil")
+		}
+	}
+}
+
+func TestBlobID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "id.txt", want: "id"},
+		{name: "id.json", want: "id"},
+		{name: "aks/id.json", want: "aks/id"},
+		{name: "id", want: "id"},
+	}
+
+	for _, test := range tests {
+		if got := blobID(test.name); got != test.want {
+			t.Errorf("TestBlobID(%s): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
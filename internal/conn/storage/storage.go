@@ -29,16 +29,30 @@ Changes from the original:
 package storage
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log/slog"
+	"mime"
+	"net/netip"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/models"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
@@ -49,11 +63,41 @@ import (
 // Client is a client for interacting with Azure Blob Storage for pushing and pulling data
 // used by the ARN service.
 type Client struct {
+	// endpoint and cred are what cli was created with. They are protected by mu.
+	endpoint      string
+	cred          azcore.TokenCredential
 	now           func() time.Time
 	cli           *service.Client
 	clientOptions policy.ClientOptions
 	creds         *credCache
 	contExt       string
+	// shards is the number of containers blobs are spread across each day, 0 or 1 for one. See WithContainerShards().
+	shards int
+	// perms, ipRange, protocol, expiry and startSkew are put in the SAS of each uploaded blob. See WithSAS().
+	perms     string
+	ipRange   sas.IPRange
+	protocol  sas.Protocol
+	expiry    time.Duration
+	startSkew time.Duration
+
+	// uploadTimeout bounds each Upload(), 0 for no bound other than its context.
+	uploadTimeout time.Duration
+
+	// contentType, metadata and blobName are applied to each uploaded blob. See WithBlobContentType(),
+	// WithBlobMetadata() and WithBlobNameFunc().
+	contentType string
+	metadata    map[string]*string
+	blobName    BlobNameFunc
+	// ext is the extension of blob names, see WithBlobExt().
+	ext string
+
+	// lazy indicates that creds is created on the first upload instead of in New().
+	lazy bool
+	// creder is used to create creds when lazy is set. This is normally cli.
+	creder getCreder
+	// mu protects endpoint, cred, cli, creds and creder, which can be replaced by UpdateCredential() and
+	// UpdateEndpoint().
+	mu sync.RWMutex
 
 	log *slog.Logger
 
@@ -61,6 +105,10 @@ type Client struct {
 	fakeUploader Uploader
 
 	fakeSignParams func(sigVals sas.BlobSignatureValues, cred *service.UserDelegationCredential) (encoder, error)
+	// lookup replaces DNS lookups in tests.
+	lookup lookupFunc
+	// fakeLister replaces listing containers and blobs in tests.
+	fakeLister lister
 }
 
 // Option is a function that sets an option on the client.
@@ -100,6 +148,333 @@ func WithContainerExt(ext string) Option {
 	}
 }
 
+// MaxContainerShards is the largest number of containers that WithContainerShards() can spread blobs across.
+const MaxContainerShards = 100
+
+// maxContainerName is the longest container name blob storage allows.
+const maxContainerName = 63
+
+// WithContainerShards spreads the blobs uploaded each day across n containers instead of one, for clients
+// that upload enough blobs to be throttled by a single container. The container of a blob is picked from
+// the blob's ID and has the shard index after the date, "arm-ext-nt-YYYY-MM-DD-N". Consumers are not
+// affected, as the SAS link of each blob names its container. n must be from 1 to MaxContainerShards, 1 is
+// the same as not sharding. As the index makes the names longer, WithContainerExt() can be at most 39
+// characters with up to 10 shards and 38 with more.
+func WithContainerShards(n int) Option {
+	return func(c *Client) error {
+		if n < 1 || n > MaxContainerShards {
+			return fmt.Errorf("container shards must be from 1 to %d", MaxContainerShards)
+		}
+		c.shards = n
+		return nil
+	}
+}
+
+// WithLazyInit delays fetching the user delegation credential until the first call to Upload().
+// This allows a client to be created before storage is reachable or RBAC assignments have propagated.
+// If the credential cannot be fetched, Upload() returns the error and the next Upload() will try again.
+func WithLazyInit() Option {
+	return func(c *Client) error {
+		c.lazy = true
+		return nil
+	}
+}
+
+const (
+	// DefaultSASExpiry is how long the SAS link of a blob is valid unless changed with SASOptions.Expiry.
+	DefaultSASExpiry = time.Hour
+	// MinSASExpiry is the shortest SASOptions.Expiry. ARN reads a blob some time after it accepts the event
+	// that links to it and retries reads that fail, so a shorter link can expire before the blob is read.
+	MinSASExpiry = 15 * time.Minute
+	// MaxSASExpiry is the longest SASOptions.Expiry. A SAS cannot outlive the user delegation key it is
+	// signed with, which is valid for 7 days and replaced after 23 hours.
+	MaxSASExpiry = 6 * 24 * time.Hour
+	// DefaultSASStartSkew is how long before the upload the SAS link of a blob is valid from unless changed
+	// with SASOptions.StartSkew, so a reader whose clock is behind can still use it.
+	DefaultSASStartSkew = 10 * time.Second
+	// MaxSASStartSkew is the longest SASOptions.StartSkew.
+	MaxSASStartSkew = 15 * time.Minute
+)
+
+// SASOptions configures the SAS link that is sent to ARN to read an uploaded blob. By default the SAS only
+// grants Read over HTTPS from any address, for DefaultSASExpiry from DefaultSASStartSkew before the upload.
+type SASOptions struct {
+	// Permissions are the SAS permissions, in the order and letters of the SAS "sp" parameter, such as "rl"
+	// to add List for receivers that read multi-blob manifests. Only the read permissions r (Read), l (List)
+	// and t (Tag) are allowed, as ARN never changes the blob. Read is required. Defaults to "r".
+	Permissions string `json:"permissions,omitzero" yaml:"permissions,omitempty"`
+	// IPRange restricts the SAS to an IPv4 address, such as "203.0.113.7", or an inclusive range of
+	// addresses, such as "203.0.113.0-203.0.113.255". Only set this if the range the ARN receiver reads
+	// from is known, as reads from outside it fail. Defaults to any address.
+	IPRange string `json:"ipRange,omitzero" yaml:"ipRange,omitempty"`
+	// Expiry is how long the SAS is valid after the upload. It must be from MinSASExpiry to MaxSASExpiry.
+	// Defaults to DefaultSASExpiry.
+	Expiry time.Duration `json:"expiry,omitzero" yaml:"expiry,omitempty"`
+	// StartSkew is how long before the upload the SAS is valid from, for readers whose clock is behind. It
+	// must be from 0 to MaxSASStartSkew, with 0 the default of DefaultSASStartSkew.
+	StartSkew time.Duration `json:"startSkew,omitzero" yaml:"startSkew,omitempty"`
+	// Protocol is the protocols the SAS can be used over, "https" or "https,http". Only allow HTTP if the ARN
+	// receiver cannot read over HTTPS, as the SAS can be read off the network. Defaults to "https".
+	Protocol string `json:"protocol,omitzero" yaml:"protocol,omitempty"`
+}
+
+// sasPermissions are the SAS permissions that can be granted, in the order the "sp" parameter requires.
+const sasPermissions = "rlt"
+
+// Validate validates the options.
+func (o SASOptions) Validate() error {
+	if _, err := o.permissions(); err != nil {
+		return err
+	}
+	if _, err := o.ipRange(); err != nil {
+		return err
+	}
+	if _, err := o.protocol(); err != nil {
+		return err
+	}
+	if _, _, err := o.times(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// protocol returns the validated SAS protocol.
+func (o SASOptions) protocol() (sas.Protocol, error) {
+	switch sas.Protocol(o.Protocol) {
+	case "", sas.ProtocolHTTPS:
+		return sas.ProtocolHTTPS, nil
+	case sas.ProtocolHTTPSandHTTP:
+		return sas.ProtocolHTTPSandHTTP, nil
+	}
+	return "", fmt.Errorf("SAS protocol %q must be %q or %q", o.Protocol, sas.ProtocolHTTPS, sas.ProtocolHTTPSandHTTP)
+}
+
+// times returns the validated SAS expiry and start skew.
+func (o SASOptions) times() (expiry, startSkew time.Duration, err error) {
+	expiry, startSkew = o.Expiry, o.StartSkew
+	if expiry == 0 {
+		expiry = DefaultSASExpiry
+	}
+	if startSkew == 0 {
+		startSkew = DefaultSASStartSkew
+	}
+	if expiry < MinSASExpiry || expiry > MaxSASExpiry {
+		return 0, 0, fmt.Errorf("SAS expiry %v must be from %v to %v", o.Expiry, MinSASExpiry, MaxSASExpiry)
+	}
+	if startSkew < 0 || startSkew > MaxSASStartSkew {
+		return 0, 0, fmt.Errorf("SAS start skew %v must be from 0 to %v", o.StartSkew, MaxSASStartSkew)
+	}
+	return expiry, startSkew, nil
+}
+
+// permissions returns the validated SAS permissions.
+func (o SASOptions) permissions() (string, error) {
+	if o.Permissions == "" {
+		return "r", nil
+	}
+	last := -1
+	for _, r := range o.Permissions {
+		i := strings.IndexRune(sasPermissions, r)
+		if i < 0 {
+			return "", fmt.Errorf("SAS permission %q is not allowed, the permissions must be from %q", r, sasPermissions)
+		}
+		if i <= last {
+			return "", fmt.Errorf("SAS permissions %q must be in the order %q without repeats", o.Permissions, sasPermissions)
+		}
+		last = i
+	}
+	if o.Permissions[0] != 'r' {
+		return "", fmt.Errorf("SAS permissions %q must include r (Read)", o.Permissions)
+	}
+	return o.Permissions, nil
+}
+
+// ipRange returns the validated SAS IP range.
+func (o SASOptions) ipRange() (sas.IPRange, error) {
+	if o.IPRange == "" {
+		return sas.IPRange{}, nil
+	}
+	start, end, isRange := strings.Cut(o.IPRange, "-")
+	first, err := netip.ParseAddr(start)
+	if err != nil || !first.Is4() {
+		return sas.IPRange{}, fmt.Errorf("SAS IP range %q must be an IPv4 address or two separated by -", o.IPRange)
+	}
+	r := sas.IPRange{Start: first.AsSlice()}
+	if !isRange {
+		return r, nil
+	}
+	last, err := netip.ParseAddr(end)
+	if err != nil || !last.Is4() {
+		return sas.IPRange{}, fmt.Errorf("SAS IP range %q must be an IPv4 address or two separated by -", o.IPRange)
+	}
+	if last.Less(first) {
+		return sas.IPRange{}, fmt.Errorf("SAS IP range %q ends before it starts", o.IPRange)
+	}
+	r.End = last.AsSlice()
+	return r, nil
+}
+
+// WithSAS sets the permissions, IP range, protocol and lifetime of the SAS link of each uploaded blob.
+func WithSAS(o SASOptions) Option {
+	return func(c *Client) error {
+		perms, err := o.permissions()
+		if err != nil {
+			return err
+		}
+		ipRange, err := o.ipRange()
+		if err != nil {
+			return err
+		}
+		protocol, err := o.protocol()
+		if err != nil {
+			return err
+		}
+		expiry, startSkew, err := o.times()
+		if err != nil {
+			return err
+		}
+		c.perms = perms
+		c.ipRange = ipRange
+		c.protocol = protocol
+		c.expiry = expiry
+		c.startSkew = startSkew
+		return nil
+	}
+}
+
+// WithUploadTimeout bounds each Upload() to d, separately from its context. This keeps an upload from
+// running for as long as a notification context that is hours long. An upload that runs out of time returns
+// an error wrapping models.ErrUploadTimeout.
+func WithUploadTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("upload timeout must be greater than 0")
+		}
+		c.uploadTimeout = d
+		return nil
+	}
+}
+
+const (
+	// DefaultBlobContentType is the Content-Type of uploaded blobs unless changed with WithBlobContentType().
+	DefaultBlobContentType = "application/json"
+	// SealedContentType is the Content-Type of blobs encrypted with client.WithBlobEncryption(), which are
+	// not JSON.
+	SealedContentType = "application/octet-stream"
+)
+
+// ValidateContentType returns an error if ct is not a valid Content-Type, a type/subtype with optional
+// parameters.
+func ValidateContentType(ct string) error {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("blob content type %q is not valid: %w", ct, err)
+	}
+	if !strings.Contains(mt, "/") {
+		return fmt.Errorf("blob content type %q is not valid: must be a type/subtype", ct)
+	}
+	return nil
+}
+
+// WithBlobContentType sets the Content-Type of uploaded blobs, DefaultBlobContentType by default. Encrypted
+// blobs are always SealedContentType.
+func WithBlobContentType(ct string) Option {
+	return func(c *Client) error {
+		if err := ValidateContentType(ct); err != nil {
+			return err
+		}
+		c.contentType = ct
+		return nil
+	}
+}
+
+// metaKeyRE matches a blob metadata name, which must be a valid C# identifier.
+var metaKeyRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateMetadata returns an error if md cannot be set as blob metadata. Names must be valid C# identifiers
+// and are case-insensitive. Values must be printable ASCII, as they are sent as HTTP headers.
+func ValidateMetadata(md map[string]string) error {
+	seen := make(map[string]bool, len(md))
+	for k, v := range md {
+		if !metaKeyRE.MatchString(k) {
+			return fmt.Errorf("blob metadata name %q must be a valid C# identifier", k)
+		}
+		if seen[strings.ToLower(k)] {
+			return fmt.Errorf("blob metadata name %q is repeated, names are case-insensitive", k)
+		}
+		seen[strings.ToLower(k)] = true
+		for _, r := range v {
+			if r < 0x20 || r > 0x7e {
+				return fmt.Errorf("blob metadata value of %q must be printable ASCII", k)
+			}
+		}
+	}
+	return nil
+}
+
+// WithBlobMetadata sets metadata on each uploaded blob, such as to match lifecycle management rules or to
+// find the blobs of a publisher. See ValidateMetadata() for the names and values that are allowed.
+func WithBlobMetadata(md map[string]string) Option {
+	return func(c *Client) error {
+		if err := ValidateMetadata(md); err != nil {
+			return err
+		}
+		c.metadata = make(map[string]*string, len(md))
+		for k, v := range md {
+			c.metadata[k] = toPtr(v)
+		}
+		return nil
+	}
+}
+
+const (
+	// LegacyBlobExt is the extension of blob names unless changed with WithBlobExt(). It is kept as the
+	// default for consumers that find blobs by name.
+	LegacyBlobExt = ".txt"
+	// JSONBlobExt is the extension of blob names that matches the JSON they hold.
+	JSONBlobExt = ".json"
+)
+
+// ValidateBlobExt returns an error if ext is not LegacyBlobExt or JSONBlobExt.
+func ValidateBlobExt(ext string) error {
+	if ext != LegacyBlobExt && ext != JSONBlobExt {
+		return fmt.Errorf("blob extension %q must be %q or %q", ext, LegacyBlobExt, JSONBlobExt)
+	}
+	return nil
+}
+
+// WithBlobExt names each uploaded blob id + ext, ext being LegacyBlobExt (the default) or JSONBlobExt.
+// WithBlobNameFunc() takes precedence over it.
+func WithBlobExt(ext string) Option {
+	return func(c *Client) error {
+		if err := ValidateBlobExt(ext); err != nil {
+			return err
+		}
+		c.ext = ext
+		return nil
+	}
+}
+
+// BlobNameFunc returns the name of the blob for the id a payload is uploaded with. The name can have "/" to
+// put the blob in a virtual directory. It must not be empty or longer than 1024 characters.
+type BlobNameFunc func(id string) string
+
+// maxBlobName is the longest blob name blob storage allows.
+const maxBlobName = 1024
+
+// WithBlobNameFunc names each uploaded blob with f instead of id and an extension (see WithBlobExt()). Names
+// should be unique, such as by including id, as an upload replaces a blob of the same name. Inventory()
+// reports the ID of a blob whose name does not end in LegacyBlobExt or JSONBlobExt as its name.
+func WithBlobNameFunc(f BlobNameFunc) Option {
+	return func(c *Client) error {
+		if f == nil {
+			return fmt.Errorf("blob name func cannot be nil")
+		}
+		c.blobName = f
+		return nil
+	}
+}
+
 // Uploader is an interface for testing purposes to simulate the Upload() method.
 type Uploader interface {
 	// Upload simulates the Upload() method.
@@ -122,7 +497,11 @@ func WithFake(f Uploader) Option {
 // Azure SDK TokenCredential, and opts are the policy options for the service.Client.
 func New(endpoint string, cred azcore.TokenCredential, options ...Option) (*Client, error) {
 	client := &Client{
-		now: time.Now,
+		endpoint:    endpoint,
+		cred:        cred,
+		now:         time.Now,
+		perms:       "r",
+		contentType: DefaultBlobContentType,
 	}
 
 	for _, o := range options {
@@ -134,6 +513,12 @@ func New(endpoint string, cred azcore.TokenCredential, options ...Option) (*Clie
 	if client.log == nil {
 		client.log = slog.Default()
 	}
+	if client.shards > 1 {
+		longest := client.containerName(time.Time{}, strconv.Itoa(client.shards-1))
+		if len(longest) > maxContainerName {
+			return nil, fmt.Errorf("container extension %q is too long for %d container shards, container names cannot be longer than %d characters", client.contExt, client.shards, maxContainerName)
+		}
+	}
 
 	if client.fakeUploader != nil {
 		return client, nil
@@ -144,13 +529,18 @@ func New(endpoint string, cred azcore.TokenCredential, options ...Option) (*Clie
 		return nil, err
 	}
 	client.cli = sClient
+	client.creder = sClient
 
 	// TODO: We need to check if the storage containers delete themselves after a certain period of time.
 	// If not fail.
 
+	if client.lazy {
+		return client, nil
+	}
+
 	client.creds, err = newCredCache(sClient, withLogger(client.log))
 	if err != nil {
-		return nil, err
+		return nil, client.explain(context.Background(), err)
 	}
 
 	return client, nil
@@ -161,26 +551,163 @@ func (c *Client) Close() {
 	if c.fakeUploader != nil {
 		return
 	}
-	c.creds.close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds != nil {
+		c.creds.close()
+	}
+}
+
+// UpdateCredential replaces the credential used to authenticate to blob storage. A new user delegation
+// credential is fetched with cred before it replaces the current one (or on the next Upload() if
+// WithLazyInit() was used). Uploads that are in-flight finish with the old credential.
+// Thread-safe.
+func (c *Client) UpdateCredential(cred azcore.TokenCredential) error {
+	if c.fakeUploader != nil {
+		return nil
+	}
+	if cred == nil {
+		return fmt.Errorf("cred cannot be nil")
+	}
+	c.mu.RLock()
+	endpoint := c.endpoint
+	c.mu.RUnlock()
+	return c.replace(endpoint, cred)
+}
+
+// UpdateEndpoint replaces the blob storage endpoint, such as when it is moved to another account. A new user
+// delegation credential is fetched from the new endpoint before it replaces the current one (or on the next
+// Upload() if WithLazyInit() was used). Uploads that are in-flight finish with the old endpoint. Blobs that
+// were uploaded to the old endpoint are not moved. Thread-safe.
+func (c *Client) UpdateEndpoint(endpoint string) error {
+	if c.fakeUploader != nil {
+		return nil
+	}
+	if endpoint == "" {
+		return fmt.Errorf("endpoint cannot be empty")
+	}
+	c.mu.RLock()
+	cred := c.cred
+	c.mu.RUnlock()
+	return c.replace(endpoint, cred)
+}
+
+// replace replaces the service client with one for endpoint and cred.
+func (c *Client) replace(endpoint string, cred azcore.TokenCredential) error {
+	sClient, err := service.NewClient(endpoint, cred, &service.ClientOptions{ClientOptions: c.clientOptions})
+	if err != nil {
+		return err
+	}
+
+	var cc *credCache
+	if !c.lazy {
+		cc, err = newCredCache(sClient, withLogger(c.log))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	old := c.creds
+	c.endpoint = endpoint
+	c.cred = cred
+	c.cli = sClient
+	c.creder = sClient
+	c.creds = cc
+	c.mu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+	return nil
+}
+
+// credCache returns the credential cache. If the client was created with WithLazyInit(),
+// the cache is created on the first call.
+func (c *Client) credCache() (*credCache, error) {
+	c.mu.RLock()
+	cc := c.creds
+	c.mu.RUnlock()
+
+	if cc != nil || !c.lazy {
+		return cc, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds != nil {
+		return c.creds, nil
+	}
+
+	cc, err := newCredCache(c.creder, withLogger(c.log))
+	if err != nil {
+		return nil, err
+	}
+	c.creds = cc
+	return cc, nil
 }
 
 // Upload uploads bytes to a blob named id in today's container.  It returns a SAS link enabling the blob to be read.
 func (c *Client) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
-	const contPrefix = "arm-ext-nt"
-	var cName string
+	return c.withTimeout(ctx, func(ctx context.Context) (*url.URL, error) {
+		if c.fakeUploader != nil {
+			return c.fakeUploader.Upload(ctx, id, b)
+		}
+		return c.uploadTo(ctx, id, uploadArgs{b: b})
+	})
+}
 
-	if c.fakeUploader != nil {
-		return c.fakeUploader.Upload(ctx, id, b)
+// UploadStream uploads what open returns to a blob named id in today's container, without holding it in
+// memory. It returns a SAS link enabling the blob to be read. open is called again if the upload has to
+// start over, so it must return the same content each time. The reader is closed after the upload.
+func (c *Client) UploadStream(ctx context.Context, id string, open func() (io.ReadCloser, error)) (*url.URL, error) {
+	return c.withTimeout(ctx, func(ctx context.Context) (*url.URL, error) {
+		if c.fakeUploader != nil {
+			r, err := open()
+			if err != nil {
+				return nil, fmt.Errorf("could not open the stream: %w", err)
+			}
+			defer r.Close()
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			return c.fakeUploader.Upload(ctx, id, b)
+		}
+		return c.uploadTo(ctx, id, uploadArgs{open: open})
+	})
+}
+
+// withTimeout calls upload with ctx bounded by the upload timeout, if one was set with WithUploadTimeout().
+func (c *Client) withTimeout(ctx context.Context, upload func(ctx context.Context) (*url.URL, error)) (*url.URL, error) {
+	if c.uploadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.uploadTimeout, models.ErrUploadTimeout)
+		defer cancel()
 	}
 
-	if c.contExt == "" {
-		cName = fmt.Sprintf("%s-%s", contPrefix, c.now().UTC().Format(time.DateOnly))
-	} else {
-		cName = fmt.Sprintf("%s-%s-%s", contPrefix, c.contExt, c.now().UTC().Format(time.DateOnly))
+	u, err := upload(ctx)
+	if err != nil && errors.Is(context.Cause(ctx), models.ErrUploadTimeout) && !errors.Is(err, models.ErrUploadTimeout) {
+		err = fmt.Errorf("%w after %v: %w", models.ErrUploadTimeout, c.uploadTimeout, err)
+	}
+	return u, err
+}
+
+// uploadTo uploads args.b, or the stream from args.open, to a blob named id in today's container.
+func (c *Client) uploadTo(ctx context.Context, id string, args uploadArgs) (*url.URL, error) {
+	cName := c.containerName(c.now(), c.shard(id))
+	bName, err := c.name(id)
+	if err != nil {
+		return nil, err
 	}
-	bName := id + ".txt"
 
-	cClient := c.cli.NewContainerClient(cName)
+	c.mu.RLock()
+	cli := c.cli
+	c.mu.RUnlock()
+
+	cClient := cli.NewContainerClient(cName)
 	bClient := cClient.NewBlockBlobClient(bName)
 
 	u, err := url.Parse(bClient.URL())
@@ -188,17 +715,66 @@ func (c *Client) Upload(ctx context.Context, id string, b []byte) (*url.URL, err
 		return nil, fmt.Errorf("URL returend by blob client is not a valid URL: %w", err)
 	}
 
-	args := uploadArgs{
-		id:     id,
-		b:      b,
-		cName:  cName,
-		bName:  bName,
-		upload: bClient,
-		create: cClient,
-		url:    u,
+	args.id = id
+	args.cName = cName
+	args.bName = bName
+	args.upload = bClient
+	args.stream = bClient
+	args.create = cClient
+	args.url = u
+
+	u, err = c.upload(ctx, args)
+	if err != nil {
+		return nil, c.explain(ctx, err)
+	}
+	return u, nil
+}
+
+// name returns the name of the blob for id.
+func (c *Client) name(id string) (string, error) {
+	if c.blobName == nil {
+		if c.ext == "" {
+			return id + LegacyBlobExt, nil
+		}
+		return id + c.ext, nil
+	}
+	name := c.blobName(id)
+	if name == "" || len(name) > maxBlobName {
+		return "", fmt.Errorf("BlobNameFunc returned a name for blob(%s) that is empty or longer than %d characters", id, maxBlobName)
+	}
+	return name, nil
+}
+
+// headers returns the HTTP headers of a blob with content b. b is nil for a stream.
+func (c *Client) headers(b []byte) *blob.HTTPHeaders {
+	ct := c.contentType
+	if ct == "" {
+		ct = DefaultBlobContentType
+	}
+	if encrypt.IsSealed(b) {
+		ct = SealedContentType
 	}
+	return &blob.HTTPHeaders{BlobContentType: &ct}
+}
 
-	return c.upload(ctx, args)
+// containerName returns the name of the container for the day of t and shard, the shard index or ""
+// without sharding.
+func (c *Client) containerName(t time.Time, shard string) string {
+	name := c.containerPrefix() + t.UTC().Format(time.DateOnly)
+	if shard != "" {
+		name += "-" + shard
+	}
+	return name
+}
+
+// shard returns the index of the container shard of the blob named id, or "" without sharding.
+func (c *Client) shard(id string) string {
+	if c.shards <= 1 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return strconv.Itoa(int(h.Sum32() % uint32(c.shards)))
 }
 
 // uploadBuffer is an interface for uploading a buffer. Implemented by *blockblob.BlockBlobClient.
@@ -206,6 +782,11 @@ type uploadBuffer interface {
 	UploadBuffer(ctx context.Context, buffer []byte, o *blockblob.UploadBufferOptions) (blockblob.UploadBufferResponse, error)
 }
 
+// uploadStream is an interface for uploading a stream. Implemented by *blockblob.BlockBlobClient.
+type uploadStream interface {
+	UploadStream(ctx context.Context, body io.Reader, o *blockblob.UploadStreamOptions) (blockblob.UploadStreamResponse, error)
+}
+
 // createContainer is an interface for creating a container.
 // Implemented by *container.Client.
 type createContainer interface {
@@ -216,32 +797,49 @@ type createContainer interface {
 // It is field-aligned.
 type uploadArgs struct {
 	upload uploadBuffer
+	stream uploadStream
 	create createContainer
 	url    *url.URL
-	id     string
-	cName  string
-	bName  string
-	b      []byte
+	// open is set instead of b to upload a stream.
+	open  func() (io.ReadCloser, error)
+	id    string
+	cName string
+	bName string
+	b     []byte
 }
 
 func (c *Client) upload(ctx context.Context, args uploadArgs) (*url.URL, error) {
-	cred, err := c.creds.get(ctx)
+	creds, err := c.credCache()
 	if err != nil {
 		return nil, err
 	}
-
-	// TODO: It would be better if we check for the existence of the container
-	// before trying to create it.  It wasn't immediately obvious how to do that.
-	_, err = args.upload.UploadBuffer(ctx, args.b, nil)
-	if err := handleUploadErr(ctx, err, args.create); err != nil {
+	cred, err := creds.get(ctx)
+	if err != nil {
 		return nil, err
 	}
 
+	if args.open != nil {
+		opts := &blockblob.UploadStreamOptions{HTTPHeaders: c.headers(nil), Metadata: c.metadata}
+		if err := streamBlob(ctx, args, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		opts := &blockblob.UploadBufferOptions{HTTPHeaders: c.headers(args.b), Metadata: c.metadata}
+		if progress.FromCtx(ctx) != nil {
+			opts.Progress = func(int64) { progress.Report(ctx) }
+		}
+		if err := uploadBlob(ctx, args, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	now := c.now().UTC()
 	sigVals := sas.BlobSignatureValues{
-		Protocol:      sas.ProtocolHTTPS,
-		StartTime:     time.Now().UTC().Add(time.Second * -10),
-		ExpiryTime:    c.now().UTC().Add(1 * time.Hour),
-		Permissions:   (&sas.BlobPermissions{Read: true}).String(),
+		Protocol:      cmp.Or(c.protocol, sas.ProtocolHTTPS),
+		StartTime:     now.Add(-cmp.Or(c.startSkew, DefaultSASStartSkew)),
+		ExpiryTime:    now.Add(cmp.Or(c.expiry, DefaultSASExpiry)),
+		Permissions:   c.perms,
+		IPRange:       c.ipRange,
 		ContainerName: args.cName,
 		BlobName:      args.bName,
 	}
@@ -255,6 +853,53 @@ func (c *Client) upload(ctx context.Context, args uploadArgs) (*url.URL, error)
 	return args.url, nil
 }
 
+// uploadBlob uploads args.b. If the container does not exist yet, such as the first upload of the day or to a
+// new shard, it is created and the upload is tried again.
+func uploadBlob(ctx context.Context, args uploadArgs, opts *blockblob.UploadBufferOptions) error {
+	for attempt := 1; ; attempt++ {
+		_, err := args.upload.UploadBuffer(ctx, args.b, opts)
+		if attempt > 1 || !bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return err
+		}
+		if err := handleUploadErr(ctx, err, args.create); err != nil {
+			return err
+		}
+	}
+}
+
+// streamBlob uploads the stream from args.open. A stream cannot be rewound, so if the container does not
+// exist yet, it is created and the stream is opened again.
+func streamBlob(ctx context.Context, args uploadArgs, opts *blockblob.UploadStreamOptions) error {
+	for attempt := 1; ; attempt++ {
+		r, err := args.open()
+		if err != nil {
+			return fmt.Errorf("could not open the stream: %w", err)
+		}
+		_, err = args.stream.UploadStream(ctx, progressReader{ctx: ctx, r: r}, opts)
+		r.Close()
+		if attempt > 1 || !bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return err
+		}
+		if err := handleUploadErr(ctx, err, args.create); err != nil {
+			return err
+		}
+	}
+}
+
+// progressReader reports progress on the context's tracker each time it is read from.
+type progressReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (p progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		progress.Report(p.ctx)
+	}
+	return n, err
+}
+
 type encoder interface {
 	Encode() string
 }
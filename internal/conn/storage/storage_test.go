@@ -1,17 +1,26 @@
 package storage
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/models"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/kylelemons/godebug/pretty"
 )
 
 // withTestCred sets the credCache to use the given credData and prevents
@@ -78,6 +87,153 @@ func TestWithContainerExt(t *testing.T) {
 	}
 }
 
+func TestWithContainerShards(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ext     string
+		shards  int
+		wantErr bool
+	}{
+		{name: "Error: zero shards", shards: 0, wantErr: true},
+		{name: "Error: too many shards", shards: MaxContainerShards + 1, wantErr: true},
+		{name: "Error: name too long with shards", ext: strings.Repeat("a", 39), shards: 11, wantErr: true},
+		{name: "Longest ext with 10 shards", ext: strings.Repeat("a", 39), shards: 10},
+		{name: "One shard with the longest ext", ext: strings.Repeat("a", 41), shards: 1},
+		{name: "Longest ext with shards", ext: strings.Repeat("a", 38), shards: MaxContainerShards},
+		{name: "Success", shards: 8},
+	}
+
+	for _, test := range tests {
+		opts := []Option{WithFake(slowUploader{}), WithContainerShards(test.shards)}
+		if test.ext != "" {
+			opts = append(opts, WithContainerExt(test.ext))
+		}
+		_, err := New("", nil, opts...)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithContainerShards(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithContainerShards(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+
+	day := time.Date(2024, 5, 2, 23, 0, 0, 0, time.UTC)
+	c := &Client{contExt: "team", shards: 4}
+	seen := map[string]bool{}
+	for i := range 100 {
+		id := fmt.Sprint("id-", i)
+		shard := c.shard(id)
+		if shard != c.shard(id) {
+			t.Fatalf("TestWithContainerShards: shard(%s) is not stable", id)
+		}
+		name := c.containerName(day, shard)
+		if !strings.HasPrefix(name, "arm-ext-nt-team-2024-05-02-") {
+			t.Errorf("TestWithContainerShards: got container %q, want it to start with the date", name)
+		}
+		seen[name] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("TestWithContainerShards: got %d containers used, want 4", len(seen))
+	}
+
+	c = &Client{}
+	if got, want := c.containerName(day, c.shard("id")), "arm-ext-nt-2024-05-02"; got != want {
+		t.Errorf("TestWithContainerShards: without shards: got container %q, want %q", got, want)
+	}
+}
+
+func TestCredCacheLazy(t *testing.T) {
+	t.Parallel()
+
+	creder := &fakeCreder{err: errors.New("error")}
+	c := &Client{
+		now:    time.Now,
+		log:    slog.Default(),
+		lazy:   true,
+		creder: creder,
+	}
+	defer c.Close()
+
+	if _, err := c.credCache(); err == nil {
+		t.Fatalf("TestCredCacheLazy(first call): got err == nil, want err != nil")
+	}
+	if c.creds != nil {
+		t.Fatalf("TestCredCacheLazy(first call): got c.creds != nil, want c.creds == nil")
+	}
+
+	creder.err = nil
+	cc, err := c.credCache()
+	if err != nil {
+		t.Fatalf("TestCredCacheLazy(second call): got err == %s, want err == nil", err)
+	}
+	again, err := c.credCache()
+	if err != nil {
+		t.Fatalf("TestCredCacheLazy(third call): got err == %s, want err == nil", err)
+	}
+	if cc != again {
+		t.Errorf("TestCredCacheLazy(third call): got a new credCache, want the cached one")
+	}
+}
+
+func TestUpdateCredential(t *testing.T) {
+	t.Parallel()
+
+	c, err := New("https://account.blob.core.windows.net", struct{ azcore.TokenCredential }{}, WithLazyInit())
+	if err != nil {
+		t.Fatalf("TestUpdateCredential: New(): got err == %s, want err == nil", err)
+	}
+	defer c.Close()
+
+	cc, err := newCredCache(&fakeCreder{})
+	if err != nil {
+		panic(err)
+	}
+	c.creds = cc
+	oldCli := c.cli
+
+	if err := c.UpdateCredential(nil); err == nil {
+		t.Errorf("TestUpdateCredential(nil cred): got err == nil, want err != nil")
+	}
+
+	if err := c.UpdateCredential(struct{ azcore.TokenCredential }{}); err != nil {
+		t.Fatalf("TestUpdateCredential: got err == %s, want err == nil", err)
+	}
+	if c.cli == oldCli {
+		t.Errorf("TestUpdateCredential: service client was not replaced")
+	}
+	if c.creds != nil {
+		t.Errorf("TestUpdateCredential: got c.creds != nil, want c.creds == nil so it is rebuilt on the next upload")
+	}
+}
+
+func TestUpdateEndpoint(t *testing.T) {
+	t.Parallel()
+
+	c, err := New("https://account.blob.core.windows.net", struct{ azcore.TokenCredential }{}, WithLazyInit())
+	if err != nil {
+		t.Fatalf("TestUpdateEndpoint: New(): got err == %s, want err == nil", err)
+	}
+	defer c.Close()
+	oldCli := c.cli
+
+	if err := c.UpdateEndpoint(""); err == nil {
+		t.Errorf("TestUpdateEndpoint(empty endpoint): got err == nil, want err != nil")
+	}
+
+	const endpoint = "https://other.blob.core.windows.net"
+	if err := c.UpdateEndpoint(endpoint); err != nil {
+		t.Fatalf("TestUpdateEndpoint: got err == %s, want err == nil", err)
+	}
+	if c.cli == oldCli || c.endpoint != endpoint {
+		t.Errorf("TestUpdateEndpoint: got endpoint %s, want the service client replaced for %s", c.endpoint, endpoint)
+	}
+	if u := c.cli.URL(); !strings.HasPrefix(u, endpoint) {
+		t.Errorf("TestUpdateEndpoint: got service client URL %s, want %s", u, endpoint)
+	}
+}
+
 func TestUploadPrivate(t *testing.T) {
 	t.Parallel()
 
@@ -173,6 +329,280 @@ func TestUploadPrivate(t *testing.T) {
 	}
 }
 
+func TestUploadMissingContainer(t *testing.T) {
+	t.Parallel()
+
+	baseURL, err := url.Parse("https://example.com")
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name        string
+		exists      bool
+		wantCreates int
+	}{
+		{name: "Container exists", exists: true},
+		{name: "Container is created and the blob uploaded again", wantCreates: 1},
+	}
+
+	for _, test := range tests {
+		cc, err := newCredCache(&fakeCreder{}, withTestCred(&credData{cred: &service.UserDelegationCredential{}, expires: time.Now().Add(time.Hour)}))
+		if err != nil {
+			panic(err)
+		}
+		c := &Client{
+			now:   time.Now,
+			log:   slog.Default(),
+			creds: cc,
+			fakeSignParams: func(sas.BlobSignatureValues, *service.UserDelegationCredential) (encoder, error) {
+				return fakeEncoder{qs: "qs=1"}, nil
+			},
+		}
+		cont := &fakeContainer{exists: test.exists}
+		args := uploadArgs{b: []byte("data"), upload: cont, create: cont, url: baseURL, id: "id", cName: "cName", bName: "bName"}
+
+		if _, err := c.upload(context.Background(), args); err != nil {
+			t.Errorf("TestUploadMissingContainer(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if cont.creates != test.wantCreates {
+			t.Errorf("TestUploadMissingContainer(%s): got %d creates, want %d", test.name, cont.creates, test.wantCreates)
+		}
+		if len(cont.blobs) != 1 || string(cont.blobs[0]) != "data" {
+			t.Errorf("TestUploadMissingContainer(%s): got blobs %q, want one blob of %q", test.name, cont.blobs, "data")
+		}
+	}
+
+	// A container that is still not found after it was created fails the upload.
+	up := &fakeUploader{err: &azcore.ResponseError{ErrorCode: string(bloberror.ContainerNotFound)}}
+	args := uploadArgs{b: []byte("data"), upload: up, create: &fakeContClient{}}
+	if err := uploadBlob(context.Background(), args, nil); err == nil {
+		t.Errorf("TestUploadMissingContainer(still not found): got err == nil, want err != nil")
+	}
+}
+
+func TestWithSAS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		opts      SASOptions
+		wantPerms string
+		wantIP    string
+		// wantProto defaults to HTTPS and wantLife, from the start to the expiry, to the default lifetime.
+		wantProto sas.Protocol
+		wantLife  time.Duration
+		wantErr   bool
+	}{
+		{name: "Defaults", wantPerms: "r"},
+		{name: "Short expiry", opts: SASOptions{Expiry: MinSASExpiry, StartSkew: time.Minute}, wantPerms: "r", wantLife: MinSASExpiry + time.Minute},
+		{name: "HTTP", opts: SASOptions{Protocol: "https,http"}, wantPerms: "r", wantProto: sas.ProtocolHTTPSandHTTP},
+		{name: "Error: expiry below the minimum", opts: SASOptions{Expiry: time.Minute}, wantErr: true},
+		{name: "Error: expiry above the maximum", opts: SASOptions{Expiry: 7 * 24 * time.Hour}, wantErr: true},
+		{name: "Error: negative start skew", opts: SASOptions{StartSkew: -time.Second}, wantErr: true},
+		{name: "Error: start skew above the maximum", opts: SASOptions{StartSkew: time.Hour}, wantErr: true},
+		{name: "Error: protocol", opts: SASOptions{Protocol: "http"}, wantErr: true},
+		{name: "List", opts: SASOptions{Permissions: "rl"}, wantPerms: "rl"},
+		{name: "All", opts: SASOptions{Permissions: "rlt"}, wantPerms: "rlt"},
+		{name: "Single address", opts: SASOptions{IPRange: "203.0.113.7"}, wantPerms: "r", wantIP: "203.0.113.7"},
+		{name: "Range", opts: SASOptions{IPRange: "203.0.113.0-203.0.113.255"}, wantPerms: "r", wantIP: "203.0.113.0-203.0.113.255"},
+		{name: "Error: write permission", opts: SASOptions{Permissions: "rw"}, wantErr: true},
+		{name: "Error: no read", opts: SASOptions{Permissions: "l"}, wantErr: true},
+		{name: "Error: out of order", opts: SASOptions{Permissions: "lr"}, wantErr: true},
+		{name: "Error: repeated", opts: SASOptions{Permissions: "rr"}, wantErr: true},
+		{name: "Error: IPv6", opts: SASOptions{IPRange: "2001:db8::1"}, wantErr: true},
+		{name: "Error: not an address", opts: SASOptions{IPRange: "10.0.0"}, wantErr: true},
+		{name: "Error: range ends before it starts", opts: SASOptions{IPRange: "10.0.0.9-10.0.0.1"}, wantErr: true},
+		{name: "Error: bad end", opts: SASOptions{IPRange: "10.0.0.1-"}, wantErr: true},
+	}
+
+	baseURL, err := url.Parse("https://example.com")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, test := range tests {
+		if err := test.opts.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("TestWithSAS(%s): Validate(): got err == %v, want error %v", test.name, err, test.wantErr)
+		}
+
+		c := &Client{perms: "r"}
+		err := WithSAS(test.opts)(c)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestWithSAS(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestWithSAS(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		cc, err := newCredCache(&fakeCreder{}, withTestCred(&credData{
+			cred:    &service.UserDelegationCredential{},
+			expires: time.Now().Add(1 * time.Hour),
+		}))
+		if err != nil {
+			panic(err)
+		}
+		var got sas.BlobSignatureValues
+		c.now = time.Now
+		c.log = slog.Default()
+		c.creds = cc
+		c.fakeSignParams = func(sigVals sas.BlobSignatureValues, cred *service.UserDelegationCredential) (encoder, error) {
+			got = sigVals
+			return fakeEncoder{qs: "qs=1"}, nil
+		}
+		args := uploadArgs{
+			b:      []byte("data"),
+			upload: &fakeUploader{},
+			create: &fakeContClient{},
+			url:    baseURL,
+			id:     "id",
+			cName:  "cName",
+			bName:  "bName",
+		}
+		if _, err := c.upload(context.Background(), args); err != nil {
+			t.Errorf("TestWithSAS(%s): upload() error: %v", test.name, err)
+			continue
+		}
+
+		if got.Permissions != test.wantPerms {
+			t.Errorf("TestWithSAS(%s): got permissions %q, want %q", test.name, got.Permissions, test.wantPerms)
+		}
+		wantProto := cmp.Or(test.wantProto, sas.ProtocolHTTPS)
+		if got.Protocol != wantProto {
+			t.Errorf("TestWithSAS(%s): got protocol %q, want %q", test.name, got.Protocol, wantProto)
+		}
+		wantLife := cmp.Or(test.wantLife, DefaultSASExpiry+DefaultSASStartSkew)
+		if life := got.ExpiryTime.Sub(got.StartTime); life != wantLife {
+			t.Errorf("TestWithSAS(%s): got a SAS valid for %v, want %v", test.name, life, wantLife)
+		}
+		if gotIP := got.IPRange.String(); gotIP != test.wantIP {
+			t.Errorf("TestWithSAS(%s): got IP range %q, want %q", test.name, gotIP, test.wantIP)
+		}
+	}
+}
+
+func TestBlobOptions(t *testing.T) {
+	t.Parallel()
+
+	sealed, _, err := encrypt.Seal(context.Background(), fakeWrapper{}, []byte(`{"a":1}`), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name       string
+		options    []Option
+		b          []byte
+		wantCT     string
+		wantMD     map[string]string
+		wantName   string
+		wantOptErr bool
+		wantErr    bool
+	}{
+		{name: "Defaults", b: []byte("{}"), wantCT: DefaultBlobContentType, wantName: "id.txt"},
+		{name: "Sealed", b: sealed, wantCT: SealedContentType, wantName: "id.txt"},
+		{name: "Stream", wantCT: DefaultBlobContentType, wantName: "id.txt"},
+		{name: "JSON extension", options: []Option{WithBlobExt(JSONBlobExt)}, b: []byte("{}"), wantCT: DefaultBlobContentType, wantName: "id.json"},
+		{name: "Error: extension", options: []Option{WithBlobExt(".xml")}, wantOptErr: true},
+		{
+			name:     "All options",
+			options:  []Option{WithBlobContentType("application/x-ndjson"), WithBlobMetadata(map[string]string{"publisher": "aks", "_tier": "cool"}), WithBlobNameFunc(func(id string) string { return "aks/" + id + ".json" })},
+			b:        []byte("{}"),
+			wantCT:   "application/x-ndjson",
+			wantMD:   map[string]string{"publisher": "aks", "_tier": "cool"},
+			wantName: "aks/id.json",
+		},
+		{name: "Error: content type", options: []Option{WithBlobContentType("")}, wantOptErr: true},
+		{name: "Error: metadata name", options: []Option{WithBlobMetadata(map[string]string{"1st": "v"})}, wantOptErr: true},
+		{name: "Error: repeated metadata name", options: []Option{WithBlobMetadata(map[string]string{"a": "v", "A": "v"})}, wantOptErr: true},
+		{name: "Error: metadata value", options: []Option{WithBlobMetadata(map[string]string{"a": "line\nbreak"})}, wantOptErr: true},
+		{name: "Error: nil name func", options: []Option{WithBlobNameFunc(nil)}, wantOptErr: true},
+		{name: "Error: empty name", options: []Option{WithBlobNameFunc(func(string) string { return "" })}, wantErr: true},
+	}
+
+	baseURL, err := url.Parse("https://example.com")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, test := range tests {
+		c, err := New("", nil, append(test.options, WithFake(urlUploader{}))...)
+		switch {
+		case test.wantOptErr && err == nil:
+			t.Errorf("TestBlobOptions(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantOptErr && err != nil:
+			t.Errorf("TestBlobOptions(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		name, err := c.name("id")
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestBlobOptions(%s): name(): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestBlobOptions(%s): name(): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if name != test.wantName {
+			t.Errorf("TestBlobOptions(%s): got name %q, want %q", test.name, name, test.wantName)
+		}
+
+		cc, err := newCredCache(&fakeCreder{}, withTestCred(&credData{
+			cred:    &service.UserDelegationCredential{},
+			expires: time.Now().Add(1 * time.Hour),
+		}))
+		if err != nil {
+			panic(err)
+		}
+		c.creds = cc
+		c.fakeSignParams = func(sas.BlobSignatureValues, *service.UserDelegationCredential) (encoder, error) {
+			return fakeEncoder{qs: "qs=1"}, nil
+		}
+		up, st := &fakeUploader{}, &fakeStreamer{}
+		args := uploadArgs{b: test.b, upload: up, stream: st, create: &fakeContClient{}, url: baseURL, bName: name}
+		if test.b == nil {
+			args.open = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("{}")), nil }
+		}
+		if _, err := c.upload(context.Background(), args); err != nil {
+			t.Errorf("TestBlobOptions(%s): upload(): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+
+		var headers *blob.HTTPHeaders
+		var md map[string]*string
+		if up.opts != nil {
+			headers, md = up.opts.HTTPHeaders, up.opts.Metadata
+		} else {
+			headers, md = st.opts.HTTPHeaders, st.opts.Metadata
+		}
+		if headers == nil || headers.BlobContentType == nil || *headers.BlobContentType != test.wantCT {
+			t.Errorf("TestBlobOptions(%s): got content type %v, want %q", test.name, headers, test.wantCT)
+		}
+		gotMD := map[string]string{}
+		for k, v := range md {
+			gotMD[k] = *v
+		}
+		wantMD := test.wantMD
+		if wantMD == nil {
+			wantMD = map[string]string{}
+		}
+		if diff := pretty.Compare(wantMD, gotMD); diff != "" {
+			t.Errorf("TestBlobOptions(%s): metadata: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
 func TestHandleUploadErr(t *testing.T) {
 	t.Parallel()
 
@@ -246,3 +676,174 @@ func TestHandleUploadErr(t *testing.T) {
 	}
 
 }
+
+// slowUploader blocks each upload until its context ends.
+type slowUploader struct{}
+
+func (slowUploader) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestUploadTimeout(t *testing.T) {
+	t.Parallel()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		timeout     time.Duration
+		wantTimeout bool
+	}{
+		{name: "Upload timeout", ctx: context.Background(), timeout: 10 * time.Millisecond, wantTimeout: true},
+		{name: "Context ends first", ctx: canceled, timeout: time.Hour},
+	}
+
+	for _, test := range tests {
+		c, err := New("", nil, WithFake(slowUploader{}), WithUploadTimeout(test.timeout))
+		if err != nil {
+			t.Fatalf("TestUploadTimeout(%s): New() error: %v", test.name, err)
+		}
+
+		_, err = c.Upload(test.ctx, "id", []byte("data"))
+		if err == nil {
+			t.Errorf("TestUploadTimeout(%s): got err == nil, want err != nil", test.name)
+			continue
+		}
+		if got := errors.Is(err, models.ErrUploadTimeout); got != test.wantTimeout {
+			t.Errorf("TestUploadTimeout(%s): got errors.Is(err, models.ErrUploadTimeout) == %v, want %v (err: %v)", test.name, got, test.wantTimeout, err)
+		}
+	}
+
+	if _, err := New("", nil, WithFake(slowUploader{}), WithUploadTimeout(0)); err == nil {
+		t.Errorf("TestUploadTimeout: WithUploadTimeout(0): got err == nil, want err != nil")
+	}
+}
+
+func TestStreamBlob(t *testing.T) {
+	t.Parallel()
+
+	cnfErr := &azcore.ResponseError{ErrorCode: string(bloberror.ContainerNotFound)}
+
+	tests := []struct {
+		name          string
+		openErr       error
+		errs          []error
+		createErr     error
+		wantErr       bool
+		wantTryCreate bool
+		wantReads     int
+	}{
+		{
+			name:    "Error: open fails",
+			openErr: errors.New("error"),
+			wantErr: true,
+		},
+		{
+			name:      "Error: upload fails",
+			errs:      []error{errors.New("error")},
+			wantErr:   true,
+			wantReads: 1,
+		},
+		{
+			name:          "Error: container not found and Create() fails",
+			errs:          []error{cnfErr},
+			createErr:     errors.New("error"),
+			wantErr:       true,
+			wantTryCreate: true,
+			wantReads:     1,
+		},
+		{
+			name:          "Error: container still not found after it was created",
+			errs:          []error{cnfErr, cnfErr},
+			wantErr:       true,
+			wantTryCreate: true,
+			wantReads:     2,
+		},
+		{
+			name:      "Success",
+			wantReads: 1,
+		},
+		{
+			name:          "Success: container created and the stream opened again",
+			errs:          []error{cnfErr},
+			wantTryCreate: true,
+			wantReads:     2,
+		},
+	}
+
+	for _, test := range tests {
+		streamer := &fakeStreamer{errs: test.errs}
+		create := &fakeContClient{err: test.createErr}
+		opened := 0
+		args := uploadArgs{
+			stream: streamer,
+			create: create,
+			open: func() (io.ReadCloser, error) {
+				if test.openErr != nil {
+					return nil, test.openErr
+				}
+				opened++
+				return io.NopCloser(strings.NewReader("data")), nil
+			},
+		}
+
+		err := streamBlob(context.Background(), args, nil)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestStreamBlob(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestStreamBlob(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+
+		if create.called != test.wantTryCreate {
+			t.Errorf("TestStreamBlob(%s): got Create.called == %v, want %v", test.name, create.called, test.wantTryCreate)
+		}
+		if len(streamer.read) != test.wantReads || opened != test.wantReads {
+			t.Errorf("TestStreamBlob(%s): got %d uploads of %d opened streams, want %d", test.name, len(streamer.read), opened, test.wantReads)
+		}
+		for _, got := range streamer.read {
+			if got != "data" {
+				t.Errorf("TestStreamBlob(%s): got upload of %q, want %q", test.name, got, "data")
+			}
+		}
+	}
+}
+
+func TestUploadStreamFake(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordUploader{}
+	c := &Client{fakeUploader: rec}
+
+	_, err := c.UploadStream(context.Background(), "id", func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("data")), nil
+	})
+	if err != nil {
+		t.Fatalf("TestUploadStreamFake: got err == %s, want err == nil", err)
+	}
+	if string(rec.b) != "data" {
+		t.Errorf("TestUploadStreamFake: got upload of %q, want %q", rec.b, "data")
+	}
+
+	_, err = c.UploadStream(context.Background(), "id", func() (io.ReadCloser, error) {
+		return nil, errors.New("error")
+	})
+	if err == nil {
+		t.Errorf("TestUploadStreamFake(open fails): got err == nil, want err != nil")
+	}
+}
+
+// recordUploader records the bytes of the last upload.
+type recordUploader struct {
+	b []byte
+}
+
+func (r *recordUploader) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	r.b = b
+	return url.Parse("https://example.com/" + id)
+}
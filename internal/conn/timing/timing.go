@@ -0,0 +1,321 @@
+/*
+Package timing records how long a notification spends in each stage of the send pipeline and how much of its
+deadline each stage used, so that a slow send can be attributed to queueing, marshaling, blob storage or the ARN
+receiver.
+
+The client calls Start() when a notification is queued, the model's SendEvent() calls Record() as each stage
+ends and the conn package calls Timeline.Finish() once the send is done. Finish() records the stage metrics
+and, if a Sampler is set, keeps a SlowSend for the slowest sends. All package functions are no-ops if the
+context has no Timeline.
+*/
+package timing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/trace"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/metrics"
+)
+
+// Stages of the send pipeline, used as the stage label of the metrics.
+const (
+	// StageQueue is the time between the notification being queued and the send starting.
+	StageQueue = "queue"
+	// StageMarshal is the time spent converting the notification to an event.
+	StageMarshal = "marshal"
+	// StageEncrypt is the time spent encrypting a blob payload, including wrapping its key.
+	StageEncrypt = "encrypt"
+	// StageBlob is the time spent uploading the payload to blob storage.
+	StageBlob = "blob"
+	// StageHTTP is the time spent sending the event to the ARN receiver, including retries.
+	StageHTTP = "http"
+	// StageTotal is the time from the notification being queued to the send finishing.
+	StageTotal = "total"
+)
+
+// StageTime is the time spent in a stage.
+type StageTime struct {
+	// Stage is the name of the stage, such as StageHTTP.
+	Stage string
+	// Elapsed is the time spent in the stage.
+	Elapsed time.Duration
+}
+
+type ctxKey struct{}
+
+// Timeline is the time spent in each stage by one notification. Thread-safe.
+type Timeline struct {
+	start time.Time
+
+	mu     sync.Mutex
+	stages []StageTime
+}
+
+// Start returns a copy of ctx with a new Timeline that starts now. If ctx already has a Timeline, ctx is
+// returned unchanged, so the start is when the notification was first queued.
+func Start(ctx context.Context) context.Context {
+	if FromCtx(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, &Timeline{start: time.Now()})
+}
+
+// FromCtx returns the Timeline in ctx, or nil if there is none.
+func FromCtx(ctx context.Context) *Timeline {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(ctxKey{}).(*Timeline)
+	return t
+}
+
+// Record adds elapsed to the stage of the Timeline in ctx. A stage that is recorded more than once, such as
+// StageHTTP for a blob canary that fell back to inline, is the sum of them.
+func Record(ctx context.Context, stage string, elapsed time.Duration) {
+	FromCtx(ctx).Record(stage, elapsed)
+}
+
+// Record adds elapsed to stage. A nil Timeline does nothing.
+func (t *Timeline) Record(stage string, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, s := range t.stages {
+		if s.Stage == stage {
+			t.stages[i].Elapsed += elapsed
+			return
+		}
+	}
+	t.stages = append(t.stages, StageTime{Stage: stage, Elapsed: elapsed})
+}
+
+// Started returns when the Timeline started.
+func (t *Timeline) Started() time.Time {
+	return t.start
+}
+
+// Stages returns the stages recorded so far, in the order they were first recorded.
+func (t *Timeline) Stages() []StageTime {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.stages)
+}
+
+// Finish records the time spent in each stage, and the share of ctx's deadline it used, to the metrics
+// Registry in ctx. The deadline is measured from the start of the Timeline, so it is the budget the
+// notification had when it was queued. If s is not nil, the send is offered to it. items is the number of
+// items in the notification and err is the result of the send. A nil Timeline does nothing.
+func (t *Timeline) Finish(ctx context.Context, s *Sampler, items int, err error) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	total := now.Sub(t.start)
+	var budget time.Duration
+	if d, ok := ctx.Deadline(); ok {
+		budget = d.Sub(t.start)
+	}
+
+	stages := append(t.Stages(), StageTime{Stage: StageTotal, Elapsed: total})
+	reg := metrics.FromCtx(ctx)
+	for _, st := range stages {
+		reg.SendStage(context.Background(), st.Stage, st.Elapsed)
+		if budget > 0 {
+			reg.DeadlineUsed(context.Background(), st.Stage, usedPercent(st.Elapsed, budget))
+		}
+	}
+
+	if s == nil {
+		return
+	}
+	ss := SlowSend{Time: t.start, Total: total, Budget: budget, Stages: stages, Items: items}
+	if err != nil {
+		ss.Err = err.Error()
+	}
+	if s.observe(ss) {
+		// With execution tracing on, this marks the notification's runtime/trace task as a slow send.
+		trace.Log(ctx, "arn.slowSend", ss.String())
+	}
+}
+
+// usedPercent returns the percent of budget that elapsed is.
+func usedPercent(elapsed, budget time.Duration) float64 {
+	return math.Round(float64(elapsed)/float64(budget)*10000) / 100
+}
+
+const (
+	// DefaultPercent is the default SamplerOptions.Percent.
+	DefaultPercent = 1.0
+	// DefaultSize is the default SamplerOptions.Size.
+	DefaultSize = 20
+	// DefaultWindow is the default SamplerOptions.Window.
+	DefaultWindow = 1000
+
+	// minSamples is the number of sends that must be seen before any is sampled, so the first sends after
+	// a start are not all judged slow.
+	minSamples = 20
+	// refreshEvery is how many sends are seen between updates of the threshold.
+	refreshEvery = 32
+)
+
+// SamplerOptions configures which sends a Sampler keeps.
+type SamplerOptions struct {
+	// Percent is the percent of sends, by total time, that are kept. 1 keeps the slowest 1% of sends.
+	// Defaults to DefaultPercent. Must be greater than 0 and at most 100.
+	Percent float64 `json:"percent,omitzero" yaml:"percent,omitempty"`
+	// Size is the number of slow sends kept. Once full, the oldest is dropped for each new one.
+	// Defaults to DefaultSize.
+	Size int `json:"size,omitzero" yaml:"size,omitempty"`
+	// Window is the number of recent sends the slowest Percent is taken from. Defaults to DefaultWindow.
+	Window int `json:"window,omitzero" yaml:"window,omitempty"`
+}
+
+// Validate validates the options.
+func (o SamplerOptions) Validate() error {
+	if o.Percent < 0 || o.Percent > 100 {
+		return fmt.Errorf("Percent(%v) must be between 0 and 100", o.Percent)
+	}
+	if o.Size < 0 {
+		return fmt.Errorf("Size cannot be negative")
+	}
+	if o.Window < 0 {
+		return fmt.Errorf("Window cannot be negative")
+	}
+	return nil
+}
+
+// SlowSend is the timeline of a send that was among the slowest.
+type SlowSend struct {
+	// Time is when the notification was queued.
+	Time time.Time
+	// Total is the time from the notification being queued to the send finishing.
+	Total time.Duration
+	// Threshold is the total time above which a send was among the slowest when this send finished.
+	Threshold time.Duration
+	// Budget is the time the notification had from being queued to its deadline. 0 if it had none.
+	Budget time.Duration
+	// Stages is the time spent in each stage, with StageTotal last.
+	Stages []StageTime
+	// Items is the number of items in the notification.
+	Items int
+	// Err is the error of the send, empty if it succeeded.
+	Err string
+}
+
+// String implements fmt.Stringer.
+func (s SlowSend) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "total=%s threshold=%s items=%d", s.Total, s.Threshold, s.Items)
+	if s.Budget > 0 {
+		fmt.Fprintf(&sb, " budget=%s", s.Budget)
+	}
+	for _, st := range s.Stages {
+		if st.Stage != StageTotal {
+			fmt.Fprintf(&sb, " %s=%s", st.Stage, st.Elapsed)
+		}
+	}
+	if s.Err != "" {
+		fmt.Fprintf(&sb, " err=%q", s.Err)
+	}
+	return sb.String()
+}
+
+// Sampler keeps the timelines of the slowest sends. Thread-safe.
+type Sampler struct {
+	opts SamplerOptions
+
+	mu sync.Mutex
+	// window holds the totals of the last sends, next is the index of the next one.
+	window    []time.Duration
+	wnext     int
+	seen      int
+	threshold time.Duration
+
+	ring []SlowSend
+	next int
+	full bool
+}
+
+// NewSampler creates a new Sampler.
+func NewSampler(o SamplerOptions) (*Sampler, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o.Percent == 0 {
+		o.Percent = DefaultPercent
+	}
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	if o.Window == 0 {
+		o.Window = DefaultWindow
+	}
+	return &Sampler{
+		opts:   o,
+		window: make([]time.Duration, 0, o.Window),
+		ring:   make([]SlowSend, o.Size),
+	}, nil
+}
+
+// observe adds the total of ss to the window and keeps ss if it is among the slowest. It returns true if ss
+// was kept.
+func (s *Sampler) observe(ss SlowSend) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.window) < s.opts.Window {
+		s.window = append(s.window, ss.Total)
+	} else {
+		s.window[s.wnext] = ss.Total
+		s.wnext = (s.wnext + 1) % s.opts.Window
+	}
+	s.seen++
+	if s.seen < minSamples {
+		return false
+	}
+	if s.seen == minSamples || s.seen%refreshEvery == 0 {
+		s.threshold = s.quantile()
+	}
+	if ss.Total < s.threshold {
+		return false
+	}
+
+	ss.Threshold = s.threshold
+	s.ring[s.next] = ss
+	s.next++
+	if s.next == len(s.ring) {
+		s.next = 0
+		s.full = true
+	}
+	return true
+}
+
+// quantile returns the total time that Percent of the sends in the window took at least.
+func (s *Sampler) quantile() time.Duration {
+	sorted := slices.Clone(s.window)
+	slices.Sort(sorted)
+	k := max(1, int(float64(len(sorted))*s.opts.Percent/100))
+	return sorted[len(sorted)-k]
+}
+
+// SlowSends returns the slow sends that are kept, from oldest to newest.
+func (s *Sampler) SlowSends() []SlowSend {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []SlowSend
+	if s.full {
+		out = append(out, s.ring[s.next:]...)
+	}
+	return append(out, s.ring[:s.next]...)
+}
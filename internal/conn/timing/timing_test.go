@@ -0,0 +1,165 @@
+package timing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	t.Parallel()
+
+	// No Timeline in the context is a no-op.
+	Record(context.Background(), StageHTTP, time.Second)
+	(*Timeline)(nil).Finish(context.Background(), nil, 0, nil)
+
+	ctx := Start(context.Background())
+	tl := FromCtx(ctx)
+	if tl == nil {
+		t.Fatalf("TestTimeline: Start() did not add a Timeline")
+	}
+	if FromCtx(Start(ctx)) != tl {
+		t.Errorf("TestTimeline: Start() on a context with a Timeline replaced it")
+	}
+
+	Record(ctx, StageMarshal, time.Millisecond)
+	Record(ctx, StageHTTP, 2*time.Millisecond)
+	Record(ctx, StageHTTP, 3*time.Millisecond)
+
+	want := []StageTime{{StageMarshal, time.Millisecond}, {StageHTTP, 5 * time.Millisecond}}
+	got := tl.Stages()
+	if len(got) != len(want) {
+		t.Fatalf("TestTimeline: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestTimeline: stage %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUsedPercent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		elapsed time.Duration
+		budget  time.Duration
+		want    float64
+	}{
+		{elapsed: time.Second, budget: 4 * time.Second, want: 25},
+		{elapsed: time.Second, budget: 3 * time.Second, want: 33.33},
+		{elapsed: 2 * time.Second, budget: time.Second, want: 200},
+	}
+
+	for _, test := range tests {
+		if got := usedPercent(test.elapsed, test.budget); got != test.want {
+			t.Errorf("TestUsedPercent(%s/%s): got %v, want %v", test.elapsed, test.budget, got, test.want)
+		}
+	}
+}
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSampler(SamplerOptions{Percent: 10, Size: 2, Window: 100})
+	if err != nil {
+		t.Fatalf("TestSampler: NewSampler(): got err == %s, want err == nil", err)
+	}
+
+	// The first sends are never kept.
+	for i := 1; i < minSamples; i++ {
+		if s.observe(SlowSend{Total: time.Hour}) {
+			t.Fatalf("TestSampler: send %d was kept before minSamples", i)
+		}
+	}
+	// 90 fast sends and 10 slow ones, so the threshold is the slow sends.
+	s, _ = NewSampler(SamplerOptions{Percent: 10, Size: 2, Window: 100})
+	for i := 0; i < 90; i++ {
+		s.observe(SlowSend{Total: time.Duration(i) * time.Millisecond})
+	}
+	for i := 0; i < 10; i++ {
+		s.observe(SlowSend{Total: time.Second, Items: i})
+	}
+	s.threshold = s.quantile()
+	if s.threshold != time.Second {
+		t.Errorf("TestSampler: got threshold %s, want 1s", s.threshold)
+	}
+	if s.observe(SlowSend{Total: 50 * time.Millisecond}) {
+		t.Errorf("TestSampler: a fast send was kept")
+	}
+	if !s.observe(SlowSend{Total: 2 * time.Second, Items: 100, Err: errors.New("error").Error()}) {
+		t.Errorf("TestSampler: a slow send was not kept")
+	}
+
+	got := s.SlowSends()
+	if len(got) != 2 {
+		t.Fatalf("TestSampler: got %d slow sends, want 2", len(got))
+	}
+	if got[1].Items != 100 || got[1].Threshold != time.Second {
+		t.Errorf("TestSampler: got newest %+v, want the last slow send with its threshold", got[1])
+	}
+
+	if (*Sampler)(nil).SlowSends() != nil {
+		t.Errorf("TestSampler: nil Sampler: got slow sends, want nil")
+	}
+}
+
+func TestFinish(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSampler(SamplerOptions{Percent: 100})
+	if err != nil {
+		t.Fatalf("TestFinish: NewSampler(): got err == %s, want err == nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(Start(context.Background()), time.Minute)
+	defer cancel()
+	for i := 0; i < minSamples; i++ {
+		tl := FromCtx(ctx)
+		tl.Record(StageHTTP, time.Millisecond)
+		tl.Finish(ctx, s, 3, errors.New("error"))
+	}
+
+	got := s.SlowSends()
+	if len(got) != 1 {
+		t.Fatalf("TestFinish: got %d slow sends, want 1", len(got))
+	}
+	ss := got[0]
+	if ss.Items != 3 || ss.Err != "error" {
+		t.Errorf("TestFinish: got Items == %d, Err == %q, want 3 and \"error\"", ss.Items, ss.Err)
+	}
+	if ss.Budget < time.Minute || ss.Budget > time.Minute+time.Second {
+		t.Errorf("TestFinish: got Budget == %s, want about 1m", ss.Budget)
+	}
+	if last := ss.Stages[len(ss.Stages)-1]; last.Stage != StageTotal || last.Elapsed != ss.Total {
+		t.Errorf("TestFinish: got last stage %v, want %s of %s", last, StageTotal, ss.Total)
+	}
+}
+
+func TestSamplerOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    SamplerOptions
+		wantErr bool
+	}{
+		{name: "Defaults", opts: SamplerOptions{}},
+		{name: "All", opts: SamplerOptions{Percent: 100, Size: 1, Window: 1}},
+		{name: "Error: negative percent", opts: SamplerOptions{Percent: -1}, wantErr: true},
+		{name: "Error: percent over 100", opts: SamplerOptions{Percent: 100.5}, wantErr: true},
+		{name: "Error: negative size", opts: SamplerOptions{Size: -1}, wantErr: true},
+		{name: "Error: negative window", opts: SamplerOptions{Window: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.opts.Validate()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestSamplerOptionsValidate(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestSamplerOptionsValidate(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
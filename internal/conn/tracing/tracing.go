@@ -0,0 +1,87 @@
+/*
+Package tracing records OpenTelemetry spans for the lifecycle of a notification.
+
+The conn package starts a span for each notification with Start(). The span starts when the notification was
+given to Notify() or Async(), so it includes the time spent in the queue, and is a child of the span in the
+context the notification was given with. Start() adds the tracer to the context, so the model's SendEvent()
+can add the event's ID and subject to the span with SetEvent() and start a child span for each stage of the
+send, such as marshaling, the blob upload and the HTTP send, with Stage().
+
+Without a tracer in the context, every function here is a no-op.
+*/
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name is the instrumentation name of the SDK's tracer.
+const Name = "github.com/Azure/arn-sdk"
+
+// SpanName is the name of the span of a notification.
+const SpanName = "arn.Notification"
+
+// The attributes of the span of a notification.
+const (
+	// AttrEventID is the ID of the ARN event the notification was sent as.
+	AttrEventID = attribute.Key("arn.event.id")
+	// AttrSubject is the subject of the ARN event the notification was sent as.
+	AttrSubject = attribute.Key("arn.event.subject")
+	// AttrItems is the number of items in the notification.
+	AttrItems = attribute.Key("arn.items")
+)
+
+type ctxKey struct{}
+
+// Start starts the span of a notification with items items that was queued at queued, and returns a context
+// that holds the span and t. end must be called with the result of the notification.
+func Start(ctx context.Context, t trace.Tracer, queued time.Time, items int) (spanCtx context.Context, end func(err error)) {
+	ctx, span := t.Start(
+		ctx,
+		SpanName,
+		trace.WithTimestamp(queued),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(AttrItems.Int(items)),
+	)
+	ctx = context.WithValue(ctx, ctxKey{}, t)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// FromCtx returns the tracer in ctx. ok is false if there is none.
+func FromCtx(ctx context.Context) (t trace.Tracer, ok bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	t, ok = ctx.Value(ctxKey{}).(trace.Tracer)
+	return t, ok
+}
+
+// SetEvent adds the ID and subject of the ARN event to the span of the notification in ctx.
+func SetEvent(ctx context.Context, id, subject string) {
+	if _, ok := FromCtx(ctx); !ok {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(AttrEventID.String(id), AttrSubject.String(subject))
+}
+
+// Stage starts a span named name for a stage of the send, as a child of the span of the notification in ctx.
+// The returned function ends it.
+func Stage(ctx context.Context, name string) (end func()) {
+	t, ok := FromCtx(ctx)
+	if !ok {
+		return func() {}
+	}
+	_, span := t.Start(ctx, name)
+	return func() { span.End() }
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	// Without a tracer, nothing is recorded and nothing panics.
+	ctx := context.Background()
+	if _, ok := FromCtx(nil); ok {
+		t.Errorf("TestTracing(nil ctx): got ok == true, want false")
+	}
+	SetEvent(ctx, "id", "subject")
+	Stage(ctx, "arn.marshal")()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	queued := time.Now().Add(-time.Second)
+
+	ctx, end := Start(ctx, tp.Tracer(Name), queued, 2)
+	SetEvent(ctx, "id", "/subscriptions/sub")
+	Stage(ctx, "arn.marshal")()
+	end(errors.New("failed"))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("TestTracing: got %d spans, want 2", len(spans))
+	}
+	stage, n := spans[0], spans[1]
+	if n.Name() != SpanName || stage.Name() != "arn.marshal" {
+		t.Errorf("TestTracing: got spans %q and %q, want %q and %q", n.Name(), stage.Name(), SpanName, "arn.marshal")
+	}
+	if stage.Parent().SpanID() != n.SpanContext().SpanID() {
+		t.Errorf("TestTracing: the stage span is not a child of the notification span")
+	}
+	if !n.StartTime().Equal(queued) {
+		t.Errorf("TestTracing: got start time %v, want the time it was queued %v", n.StartTime(), queued)
+	}
+	if n.Status().Code != codes.Error {
+		t.Errorf("TestTracing: got status %v, want %v", n.Status().Code, codes.Error)
+	}
+
+	want := map[string]string{string(AttrEventID): "id", string(AttrSubject): "/subscriptions/sub", string(AttrItems): "2"}
+	got := map[string]string{}
+	for _, kv := range n.Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("TestTracing: got attribute %s == %q, want %q", k, got[k], v)
+		}
+	}
+}
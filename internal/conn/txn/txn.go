@@ -0,0 +1,27 @@
+/*
+Package txn carries the correlation ID of a group of notifications that are sent as one logical transaction,
+such as the notifications for each resource type that one change in a source system fans out into.
+
+The client adds the ID to the context of each notification in the group with WithID(). The model's
+SendEvent() stamps it as the AdditionalBatchProperties.BatchCorrelationID of the event, so every event of the
+group can be found by it. Notifications in a group are not coalesced with others by client.WithBatching().
+*/
+package txn
+
+import "context"
+
+type ctxKey struct{}
+
+// WithID returns a context that holds id, the correlation ID of the group the notification belongs to.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromCtx returns the correlation ID in ctx. ok is false if the notification does not belong to a group.
+func FromCtx(ctx context.Context) (id string, ok bool) {
+	if ctx == nil {
+		return "", false
+	}
+	id, ok = ctx.Value(ctxKey{}).(string)
+	return id, ok
+}
@@ -0,0 +1,21 @@
+package txn
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromCtx(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := FromCtx(nil); ok {
+		t.Errorf("TestFromCtx(nil ctx): got ok == true, want false")
+	}
+	if _, ok := FromCtx(context.Background()); ok {
+		t.Errorf("TestFromCtx(no ID): got ok == true, want false")
+	}
+	got, ok := FromCtx(WithID(context.Background(), "id"))
+	if !ok || got != "id" {
+		t.Errorf("TestFromCtx(ID): got %q, %v, want %q, true", got, ok, "id")
+	}
+}
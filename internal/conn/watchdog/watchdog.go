@@ -0,0 +1,192 @@
+/*
+Package watchdog tracks notifications as they move through the send pipeline and reports any that
+stay in it longer than a threshold, along with the stage they are stuck in. Without this, a stuck
+send just looks like a promise timeout.
+
+The client calls Watchdog.Track() when a notification enters the pipeline, the model's SendEvent()
+calls SetStage() as the notification moves through the stages and the conn package calls Finish()
+when the result has been delivered. All package functions are no-ops if the context is not being
+tracked, so the watchdog is optional.
+*/
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/metrics"
+)
+
+// Stage is the stage of the send pipeline a notification is in.
+type Stage uint32
+
+const (
+	// Queued indicates the notification is waiting to be sent.
+	Queued Stage = 0
+	// UploadingBlob indicates the notification is too large to inline and is being uploaded to blob storage.
+	UploadingBlob Stage = 1
+	// AwaitingHTTP indicates the notification has been sent to the ARN receiver and is waiting for a response.
+	AwaitingHTTP Stage = 2
+)
+
+// String implements fmt.Stringer.
+func (s Stage) String() string {
+	switch s {
+	case Queued:
+		return "queued"
+	case UploadingBlob:
+		return "uploadingBlob"
+	case AwaitingHTTP:
+		return "awaitingHTTP"
+	}
+	return fmt.Sprintf("Stage(%d)", uint32(s))
+}
+
+type ctxKey struct{}
+
+// Entry is a notification being tracked by the Watchdog.
+type Entry struct {
+	start    time.Time
+	stage    atomic.Uint32
+	reported bool
+	// metrics is the registry of the client that sent the notification.
+	metrics *metrics.Registry
+
+	wd *Watchdog
+}
+
+// Stage returns the current stage of the notification.
+func (e *Entry) Stage() Stage {
+	return Stage(e.stage.Load())
+}
+
+// SetStage sets the stage of the notification being tracked in ctx.
+func SetStage(ctx context.Context, s Stage) {
+	if e := fromCtx(ctx); e != nil {
+		e.stage.Store(uint32(s))
+	}
+}
+
+// Finish stops tracking the notification in ctx. This should be called once the notification's
+// result has been delivered.
+func Finish(ctx context.Context) {
+	if e := fromCtx(ctx); e != nil {
+		e.wd.remove(e)
+	}
+}
+
+func fromCtx(ctx context.Context) *Entry {
+	if ctx == nil {
+		return nil
+	}
+	e, _ := ctx.Value(ctxKey{}).(*Entry)
+	return e
+}
+
+// Watchdog reports notifications that are in the send pipeline longer than a threshold.
+type Watchdog struct {
+	threshold time.Duration
+	log       *slog.Logger
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[*Entry]struct{}
+
+	closeCh chan struct{}
+	closed  chan struct{}
+}
+
+// New creates a new Watchdog that logs and records a metric for any notification that is in the
+// pipeline for longer than threshold. Each notification is reported only once. Close() must be called
+// to stop the Watchdog.
+func New(threshold time.Duration, log *slog.Logger) (*Watchdog, error) {
+	if threshold <= 0 {
+		return nil, fmt.Errorf("watchdog threshold must be greater than 0")
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+
+	w := &Watchdog{
+		threshold: threshold,
+		log:       log,
+		now:       time.Now,
+		entries:   map[*Entry]struct{}{},
+		closeCh:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Track starts tracking a notification. The returned context must be set on the notification so that
+// SetStage() and Finish() can find the Entry.
+func (w *Watchdog) Track(ctx context.Context) context.Context {
+	e := &Entry{start: w.now(), metrics: metrics.FromCtx(ctx), wd: w}
+
+	w.mu.Lock()
+	w.entries[e] = struct{}{}
+	w.mu.Unlock()
+
+	return context.WithValue(ctx, ctxKey{}, e)
+}
+
+// Close stops the Watchdog.
+func (w *Watchdog) Close() {
+	close(w.closeCh)
+	<-w.closed
+}
+
+func (w *Watchdog) remove(e *Entry) {
+	w.mu.Lock()
+	delete(w.entries, e)
+	w.mu.Unlock()
+}
+
+// run checks for stuck notifications at half the threshold until Close() is called.
+func (w *Watchdog) run() {
+	defer close(w.closed)
+
+	ticker := time.NewTicker(w.threshold / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check reports any entries that have been in the pipeline longer than the threshold.
+func (w *Watchdog) check() {
+	now := w.now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for e := range w.entries {
+		if e.reported {
+			continue
+		}
+		age := now.Sub(e.start)
+		if age < w.threshold {
+			continue
+		}
+		e.reported = true
+		stage := e.Stage()
+		w.log.Warn(
+			"ARN notification has been in the send pipeline longer than the watchdog threshold",
+			"stage", stage.String(),
+			"age", age.String(),
+			"threshold", w.threshold.String(),
+		)
+		e.metrics.StuckSend(context.Background(), stage.String())
+	}
+}
@@ -0,0 +1,66 @@
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	w := &Watchdog{
+		threshold: time.Minute,
+		log:       slog.New(slog.NewTextHandler(buf, nil)),
+		entries:   map[*Entry]struct{}{},
+	}
+
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	stuck := w.Track(context.Background())
+	finished := w.Track(context.Background())
+	SetStage(stuck, UploadingBlob)
+	Finish(finished)
+
+	if len(w.entries) != 1 {
+		t.Fatalf("TestWatchdog: got %d entries, want 1", len(w.entries))
+	}
+
+	now = now.Add(30 * time.Second)
+	w.check()
+	if buf.Len() != 0 {
+		t.Fatalf("TestWatchdog(before threshold): got log %q, want no log", buf.String())
+	}
+
+	now = now.Add(31 * time.Second)
+	w.check()
+	if !strings.Contains(buf.String(), "stage=uploadingBlob") {
+		t.Fatalf("TestWatchdog(after threshold): got log %q, want it to contain the stage", buf.String())
+	}
+
+	buf.Reset()
+	w.check()
+	if buf.Len() != 0 {
+		t.Errorf("TestWatchdog(already reported): got log %q, want no log", buf.String())
+	}
+
+	Finish(stuck)
+	if len(w.entries) != 0 {
+		t.Errorf("TestWatchdog(finished): got %d entries, want 0", len(w.entries))
+	}
+}
+
+func TestNoTracking(t *testing.T) {
+	t.Parallel()
+
+	// These must be no-ops when the context is not tracked.
+	SetStage(context.Background(), AwaitingHTTP)
+	Finish(context.Background())
+	SetStage(nil, AwaitingHTTP)
+	Finish(nil)
+}
@@ -0,0 +1,154 @@
+/*
+Package secretref resolves Key Vault references in configuration values, so that a deployment manifest can
+name a secret instead of embedding an environment-specific value.
+
+A reference uses the App Service syntax:
+
+	@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/arn-endpoint)
+
+The SecretUri can include a version. Without one, the current version is read each time the reference is
+resolved, so a Resolver picks up a new version when it is resolved again.
+*/
+package secretref
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Azure/arn-sdk/internal/build"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/go-json-experiment/json"
+)
+
+const (
+	prefix = "@Microsoft.KeyVault("
+	// keyVaultAPIVersion is the Key Vault REST API version used to get secrets.
+	keyVaultAPIVersion = "7.4"
+)
+
+// IsRef returns true if s is a Key Vault reference. It may still be malformed, see Parse().
+func IsRef(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), prefix)
+}
+
+// Parse returns the secret URL in the reference s.
+func Parse(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	inner, ok := strings.CutPrefix(s, prefix)
+	if !ok || !strings.HasSuffix(inner, ")") {
+		return "", fmt.Errorf("%q is not a Key Vault reference like @Microsoft.KeyVault(SecretUri=...)", s)
+	}
+	inner = strings.TrimSuffix(inner, ")")
+	k, v, ok := strings.Cut(inner, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(k), "SecretUri") {
+		return "", fmt.Errorf("Key Vault reference %q must have a SecretUri", s)
+	}
+
+	u, err := url.Parse(strings.TrimSpace(v))
+	if err != nil {
+		return "", fmt.Errorf("Key Vault reference %q has an invalid SecretUri: %w", s, err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Scheme != "https" || u.Host == "" || u.User != nil || len(parts) < 2 || len(parts) > 3 || parts[0] != "secrets" || parts[1] == "" {
+		return "", fmt.Errorf("Key Vault reference %q must have a SecretUri like https://myvault.vault.azure.net/secrets/name[/version]", s)
+	}
+	u.Path = "/" + strings.Join(parts, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// Resolver reads the secrets that references name from Key Vault.
+type Resolver struct {
+	cred azcore.TokenCredential
+	opts *policy.ClientOptions
+
+	mu sync.Mutex
+	// clients are keyed by token scope, as each needs its own bearer token policy.
+	clients map[string]*azcore.Client
+}
+
+// New returns a Resolver that reads secrets with cred, which needs the "get" secret permission or the
+// "Key Vault Secrets User" role on the vaults. opts can be nil.
+func New(cred azcore.TokenCredential, opts *policy.ClientOptions) (*Resolver, error) {
+	if cred == nil {
+		return nil, fmt.Errorf("cred cannot be nil")
+	}
+	return &Resolver{cred: cred, opts: opts, clients: map[string]*azcore.Client{}}, nil
+}
+
+// Resolve returns the value of the secret that the reference ref names.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	secretURL, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(secretURL)
+	client, err := r.client(vaultScope(u.Hostname()))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := runtime.NewRequest(ctx, http.MethodGet, secretURL)
+	if err != nil {
+		return "", err
+	}
+	req.Raw().URL.RawQuery = "api-version=" + keyVaultAPIVersion
+	resp, err := client.Pipeline().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %s: %w", secretURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get secret %s: %w", secretURL, runtime.NewResponseError(resp))
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %s: %w", secretURL, err)
+	}
+	var secret struct {
+		Value *string `json:"value"`
+	}
+	if err := json.Unmarshal(b, &secret); err != nil || secret.Value == nil {
+		return "", fmt.Errorf("secret %s response has no value", secretURL)
+	}
+	return *secret.Value, nil
+}
+
+// client returns the azcore.Client for scope.
+func (r *Resolver) client(scope string) (*azcore.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.clients[scope]; ok {
+		return c, nil
+	}
+	plOpts := runtime.PipelineOptions{
+		PerRetry: []policy.Policy{
+			runtime.NewBearerTokenPolicy(r.cred, []string{scope}, nil),
+		},
+	}
+	c, err := azcore.NewClient("arn.SecretResolver", build.Version, plOpts, r.opts)
+	if err != nil {
+		return nil, err
+	}
+	r.clients[scope] = c
+	return c, nil
+}
+
+// vaultScope returns the token scope for a vault at host, which is the vault's DNS suffix, such as
+// "https://vault.azure.net/.default" for "myvault.vault.azure.net".
+func vaultScope(host string) string {
+	if _, suffix, ok := strings.Cut(host, "."); ok {
+		host = suffix
+	}
+	return "https://" + host + "/.default"
+}
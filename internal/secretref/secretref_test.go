@@ -0,0 +1,122 @@
+package secretref
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+type fakeCred struct{}
+
+func (fakeCred) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Secret",
+			ref:  "@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/arn-endpoint)",
+			want: "https://myvault.vault.azure.net/secrets/arn-endpoint",
+		},
+		{
+			name: "Versioned secret with spaces",
+			ref:  " @Microsoft.KeyVault( secreturi = https://myvault.vault.azure.net/secrets/arn-endpoint/abc/) ",
+			want: "https://myvault.vault.azure.net/secrets/arn-endpoint/abc",
+		},
+		{name: "Error: not a reference", ref: "https://receiver.arn.core.windows.net", wantErr: true},
+		{name: "Error: no closing paren", ref: "@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/a", wantErr: true},
+		{name: "Error: vault name form", ref: "@Microsoft.KeyVault(VaultName=myvault;SecretName=a)", wantErr: true},
+		{name: "Error: http", ref: "@Microsoft.KeyVault(SecretUri=http://myvault.vault.azure.net/secrets/a)", wantErr: true},
+		{name: "Error: a key", ref: "@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/keys/a)", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.ref)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestParse(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestParse(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if !IsRef(test.ref) {
+			t.Errorf("TestParse(%s): IsRef(): got false, want true", test.name)
+		}
+		if got != test.want {
+			t.Errorf("TestParse(%s): got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("api-version") != keyVaultAPIVersion {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/secrets/endpoint":
+			w.Write([]byte(`{"value": "https://receiver.arn.core.windows.net", "id": "x"}`))
+		case "/secrets/novalue":
+			w.Write([]byte(`{"id": "x"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := New(fakeCred{}, &policy.ClientOptions{Transport: srv.Client()})
+	if err != nil {
+		t.Fatalf("TestResolve: New(): got err == %s, want err == nil", err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		want    string
+		wantErr bool
+	}{
+		{name: "Secret", secret: "endpoint", want: "https://receiver.arn.core.windows.net"},
+		{name: "Error: not found", secret: "missing", wantErr: true},
+		{name: "Error: no value", secret: "novalue", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := r.Resolve(context.Background(), "@Microsoft.KeyVault(SecretUri="+srv.URL+"/secrets/"+test.secret+")")
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestResolve(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestResolve(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if !strings.Contains(err.Error(), test.secret) {
+				t.Errorf("TestResolve(%s): got err == %s, want it to name the secret", test.name, err)
+			}
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestResolve(%s): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
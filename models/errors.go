@@ -0,0 +1,54 @@
+package models
+
+// This file classifies the errors a notification's promise can return, so callers that make their own retry
+// decisions do not match on error text.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// IsRetryable returns true if err, the result of a notification, is worth sending the notification again
+// after a backoff: ARN or blob storage answered with a status code the SDK retries (408, 429 or a 5xx), a
+// request failed on the network, the blob upload timed out or the circuit breaker is open. It returns false
+// for a nil error, for errors that will fail again (see IsValidation()) and for context and promise wait
+// errors, as those mean the caller stopped waiting and the notification may still be sent.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrPromiseTimeout), errors.Is(err, ErrPromiseCanceled):
+		return false
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrUploadTimeout):
+		return true
+	}
+	return retry.Transient(err)
+}
+
+// IsThrottled returns true if err, the result of a notification, is ARN or blob storage rejecting a request
+// with 429 Too Many Requests. A throttled notification is retryable, but the caller should slow down, such
+// as by backing off for longer than for other retryable errors.
+func IsThrottled(err error) bool {
+	var re *azcore.ResponseError
+	return errors.As(err, &re) && re.StatusCode == http.StatusTooManyRequests
+}
+
+// IsValidation returns true if err, the result of a notification, means the notification is not valid and
+// will fail again unless it is changed: it wraps ErrValidation or ErrBatchSize, or ARN rejected it with
+// 400 Bad Request or 413 Request Entity Too Large.
+func IsValidation(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrValidation), errors.Is(err, ErrBatchSize):
+		return true
+	}
+	var re *azcore.ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode == http.StatusBadRequest || re.StatusCode == http.StatusRequestEntityTooLarge
+	}
+	return false
+}
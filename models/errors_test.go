@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestErrorPredicates(t *testing.T) {
+	t.Parallel()
+
+	status := func(code int) error {
+		return fmt.Errorf("send failed: %w", &azcore.ResponseError{StatusCode: code})
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		wantRetryable  bool
+		wantThrottled  bool
+		wantValidation bool
+	}{
+		{name: "nil"},
+		{name: "429", err: status(http.StatusTooManyRequests), wantRetryable: true, wantThrottled: true},
+		{name: "503", err: status(http.StatusServiceUnavailable), wantRetryable: true},
+		{name: "408", err: status(http.StatusRequestTimeout), wantRetryable: true},
+		{name: "400", err: status(http.StatusBadRequest), wantValidation: true},
+		{name: "413", err: status(http.StatusRequestEntityTooLarge), wantValidation: true},
+		{name: "403", err: status(http.StatusForbidden)},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, wantRetryable: true},
+		{name: "circuit open", err: fmt.Errorf("%w: waiting on a probe", ErrCircuitOpen), wantRetryable: true},
+		{name: "upload timeout", err: ErrUploadTimeout, wantRetryable: true},
+		{name: "validation", err: fmt.Errorf("%w: Data[0].ResourceID is required", ErrValidation), wantValidation: true},
+		{name: "batch size", err: ErrBatchSize, wantValidation: true},
+		{name: "promise timeout", err: fmt.Errorf("%w: %w", ErrPromiseTimeout, context.DeadlineExceeded)},
+		{name: "canceled", err: context.Canceled},
+		{name: "shutdown", err: ErrShutdown},
+		{name: "no blob client", err: ErrNoBlobClient},
+	}
+
+	for _, test := range tests {
+		if got := IsRetryable(test.err); got != test.wantRetryable {
+			t.Errorf("TestErrorPredicates(%s): IsRetryable(): got %v, want %v", test.name, got, test.wantRetryable)
+		}
+		if got := IsThrottled(test.err); got != test.wantThrottled {
+			t.Errorf("TestErrorPredicates(%s): IsThrottled(): got %v, want %v", test.name, got, test.wantThrottled)
+		}
+		if got := IsValidation(test.err); got != test.wantValidation {
+			t.Errorf("TestErrorPredicates(%s): IsValidation(): got %v, want %v", test.name, got, test.wantValidation)
+		}
+	}
+}
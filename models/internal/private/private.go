@@ -3,18 +3,26 @@ package private
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"iter"
+	"net/url"
+	"time"
 
-	"github.com/Azure/arn-sdk/internal/conn/http"
-	"github.com/Azure/arn-sdk/internal/conn/storage"
 	"github.com/Azure/arn-sdk/models/version"
 )
 
 // Notifications is the interface that must be implemented by all notification types across models.
 type Notifications interface {
 	// Promise blocks until the promise for the notification is resolved or the context is done.
-	// Must send an models.ErrPromiseTimeout error if the context is done.
+	// If the context has no deadline or a later one than the notification's context, the deadline of the
+	// notification's context is used. Must return the error from models.WaitError() if the context is done.
 	Promise(context.Context) error
-	// Recycle is used to recycle the internal promise channel once the notification is not in use.
+	// Recycle does nothing. Promises are recycled by the SDK, which is the only code that knows when the
+	// send pipeline has stopped writing to one.
+	//
+	// Deprecated: There is no need to call Recycle(), it is kept so existing code compiles.
 	Recycle()
 	// Attrs provides methods to get the attributes of the notification.
 	Attrs
@@ -24,6 +32,17 @@ type Notifications interface {
 	Senders
 }
 
+// Batchable is implemented by notification types whose notifications can be coalesced into one event.
+type Batchable interface {
+	Notifications
+	// BatchKey returns a comparable key that is the same for notifications that can be sent in the same event,
+	// and false if the notification cannot be coalesced with others.
+	BatchKey() (any, bool)
+	// Merge returns a new notification with the data items of the notification followed by those of others,
+	// which must have the same BatchKey(). It has no context or promise.
+	Merge(others []Notifications) (Notifications, error)
+}
+
 // Attrs is an interface that must be implemented by all notification types across models.
 // It holds methods that return the attributes of the notification.
 type Attrs interface {
@@ -35,6 +54,33 @@ type Attrs interface {
 	Version() version.Schema
 	// GetPublisherInfo returns the publisher information for the notification.
 	GetPublisherInfo() string
+	// DataSizeHint returns the size in bytes of the data items serialized to JSON, or -1 if they cannot be
+	// serialized. This does not include the event envelope, so the event that is sent is somewhat larger.
+	DataSizeHint() int
+	// Resources returns the data items in a form that does not depend on the schema version.
+	Resources() iter.Seq[Resource]
+	// PayloadHashes returns the resource ID and PayloadHash of each data item. The data items are
+	// serialized on each call.
+	PayloadHashes() iter.Seq2[string, PayloadHash]
+}
+
+// PayloadHash is a SHA-256 of a data item serialized to JSON, without the time of the event. Two data items
+// with the same hash describe the same version of a resource, even if they were sent at different times.
+type PayloadHash [sha256.Size]byte
+
+// String returns the hash in hex.
+func (h PayloadHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Resource is a data item in a notification, in a form that does not depend on the schema version.
+type Resource struct {
+	// ID is the ARM resource ID.
+	ID string
+	// APIVersion is the version of the resource schema, which may be empty.
+	APIVersion string
+	// EventTime is the time of the resource event.
+	EventTime time.Time
 }
 
 // Senders is an interface that must be implemented by all notification types across models.
@@ -42,13 +88,34 @@ type Attrs interface {
 // or the ARN service.
 type Senders interface {
 	// SendEvent sends the event to the ARN service. It is also responsible for
-	// calling Event.Validate() before sending the event.
-	SendEvent(*http.Client, *storage.Client) error
+	// calling Event.Validate() before sending the event. store is nil if the client is inline-only.
+	SendEvent(hc EventSender, store PayloadStore) error
 	// SendPromise sends "e" on the promise to the notification. If the promise is nil on the notification,
 	// this call will send on the backup channel (the backup channel should be client.Errors()).
 	SendPromise(e error, backupCh chan error)
 }
 
+// EventSender sends an event to the ARN receiver.
+type EventSender interface {
+	// Send sends the JSON of an event with headers, which are key-value pairs.
+	Send(ctx context.Context, event []byte, headers []string) error
+}
+
+// PayloadStore stores the resources of events that are too large to send inline.
+type PayloadStore interface {
+	// Upload stores b under id and returns the URL the ARN service reads it from.
+	Upload(ctx context.Context, id string, b []byte) (*url.URL, error)
+}
+
+// StreamStore is a PayloadStore that can also store resources from a stream, for payloads too large to hold
+// in memory.
+type StreamStore interface {
+	PayloadStore
+	// UploadStream stores what open returns under id and returns the URL the ARN service reads it from.
+	// open is called again if the upload has to start over. The reader is closed after the upload.
+	UploadStream(ctx context.Context, id string, open func() (io.ReadCloser, error)) (*url.URL, error)
+}
+
 // Setters is an interface that must be implemented by all notification types across models.
 type Setters interface {
 	// SetCtx sets the context for the notification.
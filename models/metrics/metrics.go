@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -13,36 +16,259 @@ import (
 )
 
 const (
-	subsystem    = "arn-sdk"
-	successLabel = "success"
-	errorLabel   = "error"
-	inlineLabel  = "inline"
-	timeoutLabel = "timeout"
+	subsystem      = "arn-sdk"
+	successLabel   = "success"
+	errorLabel     = "error"
+	inlineLabel    = "inline"
+	timeoutLabel   = "timeout"
+	wonLabel       = "won"
+	stageLabel     = "stage"
+	resultLabel    = "result"
+	kindLabel      = "kind"
+	thresholdLabel = "threshold"
 )
 
+// ConsumeResult is the result of handling a consumed message.
+type ConsumeResult string
+
+const (
+	// ConsumeCompleted is a message that was handled and removed from the queue.
+	ConsumeCompleted ConsumeResult = "completed"
+	// ConsumeAbandoned is a message that failed and will be delivered again.
+	ConsumeAbandoned ConsumeResult = "abandoned"
+	// ConsumeDeadLettered is a poison message that was dead-lettered.
+	ConsumeDeadLettered ConsumeResult = "deadlettered"
+)
+
+var (
+	// DefaultLatencyBuckets are the default bucket boundaries, in milliseconds, of arn-sdk_event_sent_ms.
+	DefaultLatencyBuckets = []float64{50, 100, 200, 400, 600, 800, 1000, 1250, 1500, 2000, 3000, 4000, 5000, 10000, 60000, 300000, 600000}
+	// DefaultSizeBuckets are the default bucket boundaries, in bytes, of arn-sdk_event_sent_size_bytes.
+	// 42000 is the largest payload that is sent inline.
+	DefaultSizeBuckets = []float64{1024, 4096, 16384, 42000, 262144, 1048576, 4194304, 16777216, 67108864, 268435456, 1073741824}
+	// DefaultConsumerLagBuckets are the default bucket boundaries, in milliseconds, of arn-sdk_consumer_lag_ms.
+	DefaultConsumerLagBuckets = []float64{100, 500, 1000, 5000, 10000, 30000, 60000, 300000, 600000, 1800000, 3600000}
+	// DefaultDeadlineBuckets are the default bucket boundaries, in percent, of arn-sdk_event_deadline_used_percent.
+	DefaultDeadlineBuckets = []float64{1, 5, 10, 25, 50, 75, 90, 100}
+)
+
+// Buckets are the bucket boundaries of the histograms, for backends that need different boundaries than
+// the defaults. A nil field uses the default boundaries.
+type Buckets struct {
+	// Latency is for arn-sdk_event_sent_ms, in milliseconds. Defaults to DefaultLatencyBuckets.
+	Latency []float64
+	// Size is for arn-sdk_event_sent_size_bytes, in bytes. Defaults to DefaultSizeBuckets.
+	Size []float64
+	// ConsumerLag is for arn-sdk_consumer_lag_ms, in milliseconds. Defaults to DefaultConsumerLagBuckets.
+	ConsumerLag []float64
+	// Deadline is for arn-sdk_event_deadline_used_percent, in percent. Defaults to DefaultDeadlineBuckets.
+	Deadline []float64
+}
+
+// Validate validates the Buckets. Boundaries must be increasing and not negative.
+func (b Buckets) Validate() error {
+	check := func(name string, bounds []float64) error {
+		if bounds == nil {
+			return nil
+		}
+		if len(bounds) == 0 {
+			return fmt.Errorf("Buckets.%s must have at least one boundary", name)
+		}
+		for i, v := range bounds {
+			if v < 0 {
+				return fmt.Errorf("Buckets.%s[%d](%v) cannot be negative", name, i, v)
+			}
+			if i > 0 && v <= bounds[i-1] {
+				return fmt.Errorf("Buckets.%s[%d](%v) must be greater than the boundary before it", name, i, v)
+			}
+		}
+		return nil
+	}
+	if err := check("Latency", b.Latency); err != nil {
+		return err
+	}
+	if err := check("Size", b.Size); err != nil {
+		return err
+	}
+	if err := check("ConsumerLag", b.ConsumerLag); err != nil {
+		return err
+	}
+	return check("Deadline", b.Deadline)
+}
+
+// Defaults returns the Buckets with the default boundaries for any nil field.
+func (b Buckets) Defaults() Buckets {
+	if b.Latency == nil {
+		b.Latency = DefaultLatencyBuckets
+	}
+	if b.Size == nil {
+		b.Size = DefaultSizeBuckets
+	}
+	if b.ConsumerLag == nil {
+		b.ConsumerLag = DefaultConsumerLagBuckets
+	}
+	if b.Deadline == nil {
+		b.Deadline = DefaultDeadlineBuckets
+	}
+	return b
+}
+
+// Option is an option for Init() and InitConsumer().
+type Option func(*settings) error
+
+type settings struct {
+	buckets Buckets
+}
+
+// WithBuckets sets the bucket boundaries of the histograms.
+func WithBuckets(b Buckets) Option {
+	return func(s *settings) error {
+		if err := b.Validate(); err != nil {
+			return err
+		}
+		s.buckets = Buckets{
+			Latency:     slices.Clone(b.Latency),
+			Size:        slices.Clone(b.Size),
+			ConsumerLag: slices.Clone(b.ConsumerLag),
+			Deadline:    slices.Clone(b.Deadline),
+		}
+		return nil
+	}
+}
+
+func newSettings(options []Option) (settings, error) {
+	var s settings
+	for _, o := range options {
+		if err := o(&s); err != nil {
+			return settings{}, err
+		}
+	}
+	s.buckets = s.buckets.Defaults()
+	return s, nil
+}
+
+// labelValue normalizes a string label value, so that values that only differ in surrounding whitespace
+// or quotes, such as a quoted enum, are one label value. An empty value is "unknown".
+func labelValue(v string) string {
+	v = strings.Trim(strings.TrimSpace(v), `"'`)
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
+
 type eventMetrics struct {
 	sent    metric.Int64Counter
 	bytes   metric.Int64Counter
 	latency metric.Int64Histogram
+	size    metric.Int64Histogram
+	hedged  metric.Int64Counter
+	stuck   metric.Int64Counter
+	quota   metric.Int64Counter
+	canary  metric.Int64Counter
+	stage   metric.Int64Histogram
+	used    metric.Float64Histogram
 }
 
 type promiseMetrics struct {
 	current   metric.Int64UpDownCounter
 	completed metric.Int64Counter
+	dropped   metric.Int64Counter
 }
 
-var (
-	events   eventMetrics
-	promises promiseMetrics
-)
+type consumerMetrics struct {
+	messages  metric.Int64Counter
+	resources metric.Int64Counter
+	lag       metric.Int64Histogram
+}
+
+// Registry holds the instruments that the recorders record to. Each client or consumer records to the
+// Registry it was given, or to Default(), so tests that create clients with different meters do not share
+// instruments. Recorders on a Registry that has not been initialized, or on a nil Registry, do nothing.
+// Thread-safe, including Init() and InitConsumer() while recording.
+type Registry struct {
+	events   atomic.Pointer[eventMetrics]
+	promises atomic.Pointer[promiseMetrics]
+	consumer atomic.Pointer[consumerMetrics]
+}
+
+// NewRegistry creates a new Registry. Init() or InitConsumer() must be called for it to record anything.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process-global Registry, which is used by clients and consumers not given a Registry
+// and by the package-level functions.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+type ctxKey struct{}
+
+// WithRegistry returns a copy of ctx that carries r, for recorders called deeper in the send pipeline.
+func WithRegistry(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+// FromCtx returns the Registry in ctx, or Default() if there is none.
+func FromCtx(ctx context.Context) *Registry {
+	if ctx != nil {
+		if r, ok := ctx.Value(ctxKey{}).(*Registry); ok && r != nil {
+			return r
+		}
+	}
+	return Default()
+}
+
+func (r *Registry) loadEvents() *eventMetrics {
+	if r == nil {
+		return nil
+	}
+	return r.events.Load()
+}
+
+func (r *Registry) loadPromises() *promiseMetrics {
+	if r == nil {
+		return nil
+	}
+	return r.promises.Load()
+}
+
+func (r *Registry) loadConsumer() *consumerMetrics {
+	if r == nil {
+		return nil
+	}
+	return r.consumer.Load()
+}
 
 func metricName(name string) string {
 	return fmt.Sprintf("%s_%s", subsystem, name)
 }
 
-// Init initializes the arn sdk model metrics. This should only be called by the tattler constructor or tests.
-func Init(meter metric.Meter) error {
-	var err error
+// Init initializes the arn sdk model metrics of the Default() Registry. This should only be called by the
+// tattler constructor or tests.
+func Init(meter metric.Meter, options ...Option) error {
+	return Default().Init(meter, options...)
+}
+
+// InitConsumer initializes the arn sdk consumer metrics of the Default() Registry. This should only be called
+// by the consumer constructor or tests.
+func InitConsumer(meter metric.Meter, options ...Option) error {
+	return Default().InitConsumer(meter, options...)
+}
+
+// Init initializes the arn sdk model metrics of r with meter. The instruments replace any from an earlier
+// call only if all of them are created, so a failed Init leaves r as it was.
+func (r *Registry) Init(meter metric.Meter, options ...Option) error {
+	s, err := newSettings(options)
+	if err != nil {
+		return err
+	}
+
+	var events eventMetrics
+	var promises promiseMetrics
 	events.sent, err = meter.Int64Counter(metricName("event_sent_total"), metric.WithDescription("total number of events sent by the ARN client"))
 	if err != nil {
 		return err
@@ -53,11 +279,57 @@ func Init(meter metric.Meter) error {
 		return err
 	}
 
-	// TODO: adjust buckets
 	events.latency, err = meter.Int64Histogram(
 		metricName("event_sent_ms"),
 		metric.WithDescription("time spent to send ARN event"),
-		metric.WithExplicitBucketBoundaries(50, 100, 200, 400, 600, 800, 1000, 1250, 1500, 2000, 3000, 4000, 5000, 10000, 60000, 300000, 600000),
+		metric.WithExplicitBucketBoundaries(s.buckets.Latency...),
+	)
+	if err != nil {
+		return err
+	}
+
+	events.size, err = meter.Int64Histogram(
+		metricName("event_sent_size_bytes"),
+		metric.WithDescription("size in bytes of the event data sent by the ARN client"),
+		metric.WithExplicitBucketBoundaries(s.buckets.Size...),
+	)
+	if err != nil {
+		return err
+	}
+
+	events.hedged, err = meter.Int64Counter(metricName("hedged_request_total"), metric.WithDescription("total number of hedged requests sent by the ARN client"))
+	if err != nil {
+		return err
+	}
+
+	events.stuck, err = meter.Int64Counter(metricName("event_stuck_total"), metric.WithDescription("total number of events that stayed in the send pipeline longer than the watchdog threshold"))
+	if err != nil {
+		return err
+	}
+
+	events.quota, err = meter.Int64Counter(metricName("quota_alert_total"), metric.WithDescription("total number of times usage of an ARN quota rose above an alert threshold"))
+	if err != nil {
+		return err
+	}
+
+	events.canary, err = meter.Int64Counter(metricName("blob_canary_total"), metric.WithDescription("total number of notifications sent through blob storage to verify the blob path works"))
+	if err != nil {
+		return err
+	}
+
+	events.stage, err = meter.Int64Histogram(
+		metricName("event_stage_ms"),
+		metric.WithDescription("time an ARN event spent in each stage of the send pipeline"),
+		metric.WithExplicitBucketBoundaries(s.buckets.Latency...),
+	)
+	if err != nil {
+		return err
+	}
+
+	events.used, err = meter.Float64Histogram(
+		metricName("event_deadline_used_percent"),
+		metric.WithDescription("percent of an ARN event's deadline used by each stage of the send pipeline"),
+		metric.WithExplicitBucketBoundaries(s.buckets.Deadline...),
 	)
 	if err != nil {
 		return err
@@ -73,49 +345,132 @@ func Init(meter metric.Meter) error {
 		return err
 	}
 
+	promises.dropped, err = meter.Int64Counter(metricName("error_dropped_total"), metric.WithDescription("total number of notification errors dropped because the ARN client's errors channel was full"))
+	if err != nil {
+		return err
+	}
+
+	r.events.Store(&events)
+	r.promises.Store(&promises)
 	return nil
 }
 
-// SendEventSuccess increases the events.sent metric with success == true
-// and records the latency.
-func SendEventSuccess(ctx context.Context, elapsed time.Duration, inline bool, dataSize int64) {
-	opt := metric.WithAttributes(
-		attribute.Key(successLabel).Bool(true),
-		attribute.Key(inlineLabel).Bool(inline),
-	)
-	if events.sent != nil {
-		events.sent.Add(ctx, 1, opt)
+// InitConsumer initializes the arn sdk consumer metrics of r with meter. The instruments replace any from an
+// earlier call only if all of them are created.
+func (r *Registry) InitConsumer(meter metric.Meter, options ...Option) error {
+	s, err := newSettings(options)
+	if err != nil {
+		return err
 	}
-	if events.bytes != nil {
-		events.bytes.Add(ctx, dataSize, opt)
+
+	var consumer consumerMetrics
+	consumer.messages, err = meter.Int64Counter(metricName("consumer_message_total"), metric.WithDescription("total number of messages handled by the ARN consumer"))
+	if err != nil {
+		return err
 	}
-	if events.latency != nil {
-		events.latency.Record(ctx, elapsed.Milliseconds(), opt)
+
+	consumer.resources, err = meter.Int64Counter(metricName("consumer_resource_total"), metric.WithDescription("total number of resources handled by the ARN consumer"))
+	if err != nil {
+		return err
 	}
+
+	consumer.lag, err = meter.Int64Histogram(
+		metricName("consumer_lag_ms"),
+		metric.WithDescription("time between an ARN event being emitted and it being handled by the consumer"),
+		metric.WithExplicitBucketBoundaries(s.buckets.ConsumerLag...),
+	)
+	if err != nil {
+		return err
+	}
+
+	r.consumer.Store(&consumer)
+	return nil
+}
+
+// SendEventSuccess increases the events.sent metric with success == true
+// and records the latency and size.
+func (r *Registry) SendEventSuccess(ctx context.Context, elapsed time.Duration, inline bool, dataSize int64) {
+	r.sendEvent(ctx, true, elapsed, inline, dataSize)
 }
 
 // SendEventFailure increases the events.sent metric with success == false
-// and records the latency.
-func SendEventFailure(ctx context.Context, elapsed time.Duration, inline bool, dataSize int64) {
+// and records the latency and size.
+func (r *Registry) SendEventFailure(ctx context.Context, elapsed time.Duration, inline bool, dataSize int64) {
+	r.sendEvent(ctx, false, elapsed, inline, dataSize)
+}
+
+func (r *Registry) sendEvent(ctx context.Context, success bool, elapsed time.Duration, inline bool, dataSize int64) {
+	m := r.loadEvents()
+	if m == nil {
+		return
+	}
 	opt := metric.WithAttributes(
-		attribute.Key(successLabel).Bool(false),
+		attribute.Key(successLabel).Bool(success),
 		attribute.Key(inlineLabel).Bool(inline),
 	)
-	if events.sent != nil {
-		events.sent.Add(ctx, 1, opt)
+	m.sent.Add(ctx, 1, opt)
+	m.bytes.Add(ctx, dataSize, opt)
+	m.latency.Record(ctx, elapsed.Milliseconds(), opt)
+	m.size.Record(ctx, dataSize, opt)
+}
+
+// Hedge increases the events.hedged metric. won is true if the hedged request
+// was the one that succeeded.
+func (r *Registry) Hedge(ctx context.Context, won bool) {
+	if m := r.loadEvents(); m != nil {
+		m.hedged.Add(ctx, 1, metric.WithAttributes(attribute.Key(wonLabel).Bool(won)))
 	}
-	if events.bytes != nil {
-		events.bytes.Add(ctx, dataSize, opt)
+}
+
+// StuckSend increases the events.stuck metric. stage is the stage of the send
+// pipeline the event was in when it was reported.
+func (r *Registry) StuckSend(ctx context.Context, stage string) {
+	if m := r.loadEvents(); m != nil {
+		m.stuck.Add(ctx, 1, metric.WithAttributes(attribute.Key(stageLabel).String(labelValue(stage))))
+	}
+}
+
+// QuotaAlert increases the events.quota metric. kind is the quota ("events" or "bytes") and threshold is
+// the fraction of the quota that usage rose above.
+func (r *Registry) QuotaAlert(ctx context.Context, kind string, threshold float64) {
+	if m := r.loadEvents(); m != nil {
+		m.quota.Add(ctx, 1, metric.WithAttributes(
+			attribute.Key(kindLabel).String(labelValue(kind)),
+			attribute.Key(thresholdLabel).Float64(threshold),
+		))
+	}
+}
+
+// BlobCanary increases the events.canary metric. success is true if the canary was uploaded to blob storage
+// and accepted by the receiver.
+func (r *Registry) BlobCanary(ctx context.Context, success bool) {
+	if m := r.loadEvents(); m != nil {
+		m.canary.Add(ctx, 1, metric.WithAttributes(attribute.Key(successLabel).Bool(success)))
 	}
-	if events.latency != nil {
-		events.latency.Record(ctx, elapsed.Milliseconds(), opt)
+}
+
+// SendStage records the time an event spent in a stage of the send pipeline, such as "http".
+func (r *Registry) SendStage(ctx context.Context, stage string, elapsed time.Duration) {
+	if m := r.loadEvents(); m != nil {
+		m.stage.Record(ctx, elapsed.Milliseconds(), metric.WithAttributes(attribute.Key(stageLabel).String(labelValue(stage))))
+	}
+}
+
+// DeadlineUsed records the percent of an event's deadline that a stage of the send pipeline used.
+func (r *Registry) DeadlineUsed(ctx context.Context, stage string, percent float64) {
+	if m := r.loadEvents(); m != nil {
+		m.used.Record(ctx, percent, metric.WithAttributes(attribute.Key(stageLabel).String(labelValue(stage))))
 	}
 }
 
 // Promise increases the promises.completed metric with timeout label.
 // This also decrements the current promise count.
 // This should be called on promise completion.
-func Promise(ctx context.Context, err error) {
+func (r *Registry) Promise(ctx context.Context, err error) {
+	m := r.loadPromises()
+	if m == nil {
+		return
+	}
 	var isErr, isTimeout bool
 	if err != nil {
 		isErr = true
@@ -123,22 +478,116 @@ func Promise(ctx context.Context, err error) {
 			isTimeout = true
 		}
 	}
-	opt := metric.WithAttributes(
+	m.completed.Add(ctx, 1, metric.WithAttributes(
 		attribute.Key(errorLabel).Bool(isErr),
 		attribute.Key(timeoutLabel).Bool(isTimeout),
-	)
-	if promises.completed != nil {
-		promises.completed.Add(ctx, 1, opt)
-	}
-	if promises.current != nil {
-		promises.current.Add(ctx, -1)
+	))
+	m.current.Add(ctx, -1)
+}
+
+// ErrorDropped increases the promises.dropped metric. This should be called when the error of a notification
+// without a promise is dropped because the errors channel is full.
+func (r *Registry) ErrorDropped(ctx context.Context) {
+	if m := r.loadPromises(); m != nil {
+		m.dropped.Add(ctx, 1)
 	}
 }
 
 // ActivePromise increases the promises.current metric.
 // This should be called when a promise is created.
-func ActivePromise(ctx context.Context) {
-	if promises.current != nil {
-		promises.current.Add(ctx, 1)
+func (r *Registry) ActivePromise(ctx context.Context) {
+	if m := r.loadPromises(); m != nil {
+		m.current.Add(ctx, 1)
+	}
+}
+
+// ConsumeMessage increases the consumer.messages metric with the result label.
+func (r *Registry) ConsumeMessage(ctx context.Context, result ConsumeResult) {
+	if m := r.loadConsumer(); m != nil {
+		m.messages.Add(ctx, 1, metric.WithAttributes(attribute.Key(resultLabel).String(labelValue(string(result)))))
 	}
 }
+
+// ConsumeResources increases the consumer.resources metric by n with success label.
+func (r *Registry) ConsumeResources(ctx context.Context, n int, success bool) {
+	if m := r.loadConsumer(); m != nil {
+		m.resources.Add(ctx, int64(n), metric.WithAttributes(attribute.Key(successLabel).Bool(success)))
+	}
+}
+
+// ConsumeLag records the time between an event being emitted and it being handled.
+func (r *Registry) ConsumeLag(ctx context.Context, lag time.Duration) {
+	if m := r.loadConsumer(); m != nil {
+		m.lag.Record(ctx, lag.Milliseconds())
+	}
+}
+
+// SendEventSuccess is Default().SendEventSuccess().
+func SendEventSuccess(ctx context.Context, elapsed time.Duration, inline bool, dataSize int64) {
+	Default().SendEventSuccess(ctx, elapsed, inline, dataSize)
+}
+
+// SendEventFailure is Default().SendEventFailure().
+func SendEventFailure(ctx context.Context, elapsed time.Duration, inline bool, dataSize int64) {
+	Default().SendEventFailure(ctx, elapsed, inline, dataSize)
+}
+
+// Hedge is Default().Hedge().
+func Hedge(ctx context.Context, won bool) {
+	Default().Hedge(ctx, won)
+}
+
+// StuckSend is Default().StuckSend().
+func StuckSend(ctx context.Context, stage string) {
+	Default().StuckSend(ctx, stage)
+}
+
+// QuotaAlert is Default().QuotaAlert().
+func QuotaAlert(ctx context.Context, kind string, threshold float64) {
+	Default().QuotaAlert(ctx, kind, threshold)
+}
+
+// ErrorDropped is Default().ErrorDropped().
+func ErrorDropped(ctx context.Context) {
+	Default().ErrorDropped(ctx)
+}
+
+// BlobCanary is Default().BlobCanary().
+func BlobCanary(ctx context.Context, success bool) {
+	Default().BlobCanary(ctx, success)
+}
+
+// SendStage is Default().SendStage().
+func SendStage(ctx context.Context, stage string, elapsed time.Duration) {
+	Default().SendStage(ctx, stage, elapsed)
+}
+
+// DeadlineUsed is Default().DeadlineUsed().
+func DeadlineUsed(ctx context.Context, stage string, percent float64) {
+	Default().DeadlineUsed(ctx, stage, percent)
+}
+
+// Promise is Default().Promise().
+func Promise(ctx context.Context, err error) {
+	Default().Promise(ctx, err)
+}
+
+// ActivePromise is Default().ActivePromise().
+func ActivePromise(ctx context.Context) {
+	Default().ActivePromise(ctx)
+}
+
+// ConsumeMessage is Default().ConsumeMessage().
+func ConsumeMessage(ctx context.Context, result ConsumeResult) {
+	Default().ConsumeMessage(ctx, result)
+}
+
+// ConsumeResources is Default().ConsumeResources().
+func ConsumeResources(ctx context.Context, n int, success bool) {
+	Default().ConsumeResources(ctx, n, success)
+}
+
+// ConsumeLag is Default().ConsumeLag().
+func ConsumeLag(ctx context.Context, lag time.Duration) {
+	Default().ConsumeLag(ctx, lag)
+}
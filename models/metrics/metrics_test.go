@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	otelmetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
@@ -29,37 +31,81 @@ func TestModelsMetrics(t *testing.T) {
 		name               string
 		emptyResource      bool
 		customResouceAttrs []attribute.KeyValue
-		recordMetrics      func(ctx context.Context, meter otelmetric.Meter)
+		recordMetrics      func(ctx context.Context, r *Registry, meter otelmetric.Meter)
 		options            []otelprometheus.Option
 		expectedFile       string
 	}{
 		{
 			name:         "models metrics",
 			expectedFile: "testdata/models_happy.txt",
-			recordMetrics: func(ctx context.Context, meter otelmetric.Meter) {
-				Init(meter)
-				SendEventSuccess(ctx, 1*time.Second, true, 40000)
-				SendEventFailure(ctx, 1*time.Second, false, 0)
-				ActivePromise(ctx)
-				Promise(ctx, nil)
-				ActivePromise(ctx)
-				Promise(ctx, models.ErrPromiseTimeout)
-				ActivePromise(ctx)
-				Promise(ctx, models.ErrBatchSize)
+			recordMetrics: func(ctx context.Context, r *Registry, meter otelmetric.Meter) {
+				r.Init(meter)
+				r.SendEventSuccess(ctx, 1*time.Second, true, 40000)
+				r.SendEventFailure(ctx, 1*time.Second, false, 0)
+				r.Hedge(ctx, true)
+				r.Hedge(ctx, false)
+				r.Hedge(ctx, false)
+				r.StuckSend(ctx, "awaitingHTTP")
+				r.QuotaAlert(ctx, "events", 0.8)
+				r.BlobCanary(ctx, true)
+				r.BlobCanary(ctx, false)
+				r.SendStage(ctx, "http", 300*time.Millisecond)
+				r.DeadlineUsed(ctx, "http", 30)
+				r.ActivePromise(ctx)
+				r.Promise(ctx, nil)
+				r.ActivePromise(ctx)
+				r.Promise(ctx, models.ErrPromiseTimeout)
+				r.ActivePromise(ctx)
+				r.Promise(ctx, models.ErrBatchSize)
+				r.ErrorDropped(ctx)
 			},
 		},
 		{
 			name:         "models metrics not initialized",
 			expectedFile: "testdata/models_nometrics.txt",
-			recordMetrics: func(ctx context.Context, meter otelmetric.Meter) {
-				SendEventSuccess(ctx, 1*time.Second, true, 0)
-				SendEventFailure(ctx, 1*time.Second, false, 0)
-				ActivePromise(ctx)
-				Promise(ctx, nil)
-				ActivePromise(ctx)
-				Promise(ctx, models.ErrPromiseTimeout)
-				ActivePromise(ctx)
-				Promise(ctx, models.ErrBatchSize)
+			recordMetrics: func(ctx context.Context, r *Registry, meter otelmetric.Meter) {
+				r.SendEventSuccess(ctx, 1*time.Second, true, 0)
+				r.SendEventFailure(ctx, 1*time.Second, false, 0)
+				r.Hedge(ctx, true)
+				r.StuckSend(ctx, "awaitingHTTP")
+				r.QuotaAlert(ctx, "events", 0.8)
+				r.BlobCanary(ctx, true)
+				r.BlobCanary(ctx, false)
+				r.SendStage(ctx, "http", 300*time.Millisecond)
+				r.DeadlineUsed(ctx, "http", 30)
+				r.ActivePromise(ctx)
+				r.Promise(ctx, nil)
+				r.ActivePromise(ctx)
+				r.Promise(ctx, models.ErrPromiseTimeout)
+				r.ActivePromise(ctx)
+				r.Promise(ctx, models.ErrBatchSize)
+				r.ErrorDropped(ctx)
+			},
+		},
+		{
+			name:         "models metrics with custom buckets and a quoted label",
+			expectedFile: "testdata/models_buckets.txt",
+			recordMetrics: func(ctx context.Context, r *Registry, meter otelmetric.Meter) {
+				if err := r.Init(meter, WithBuckets(Buckets{Latency: []float64{100, 1000}, Size: []float64{1000, 50000}})); err != nil {
+					panic(err)
+				}
+				r.SendEventSuccess(ctx, 1*time.Second, true, 40000)
+				r.StuckSend(ctx, ` "awaitingHTTP" `)
+				r.SendStage(ctx, "http", 300*time.Millisecond)
+				r.DeadlineUsed(ctx, "http", 30)
+			},
+		},
+		{
+			name:         "consumer metrics",
+			expectedFile: "testdata/consumer_happy.txt",
+			recordMetrics: func(ctx context.Context, r *Registry, meter otelmetric.Meter) {
+				r.InitConsumer(meter)
+				r.ConsumeMessage(ctx, ConsumeCompleted)
+				r.ConsumeMessage(ctx, ConsumeAbandoned)
+				r.ConsumeMessage(ctx, ConsumeDeadLettered)
+				r.ConsumeResources(ctx, 10, true)
+				r.ConsumeResources(ctx, 2, false)
+				r.ConsumeLag(ctx, 2*time.Second)
 			},
 		},
 	}
@@ -103,7 +149,7 @@ func TestModelsMetrics(t *testing.T) {
 			otelmetric.WithInstrumentationVersion("v0.1.0"),
 		)
 
-		test.recordMetrics(ctx, meter)
+		test.recordMetrics(ctx, NewRegistry(), meter)
 
 		file, err := os.Open(test.expectedFile)
 		if err != nil {
@@ -121,3 +167,133 @@ func TestModelsMetrics(t *testing.T) {
 		}
 	}
 }
+
+func TestBuckets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		buckets Buckets
+		wantErr bool
+	}{
+		{name: "Defaults", buckets: Buckets{}},
+		{name: "Custom", buckets: Buckets{Latency: []float64{0, 10}, Size: []float64{1}, ConsumerLag: []float64{1, 2, 3}}},
+		{name: "Error: empty", buckets: Buckets{Size: []float64{}}, wantErr: true},
+		{name: "Error: negative", buckets: Buckets{Latency: []float64{-1, 10}}, wantErr: true},
+		{name: "Error: not increasing", buckets: Buckets{ConsumerLag: []float64{10, 10}}, wantErr: true},
+		{name: "Error: deadline not increasing", buckets: Buckets{Deadline: []float64{50, 10}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.buckets.Validate()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestBuckets(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestBuckets(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if _, err := newSettings([]Option{WithBuckets(test.buckets)}); (err != nil) != test.wantErr {
+			t.Errorf("TestBuckets(%s): WithBuckets(): got err == %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+
+	d := Buckets{Size: []float64{1}}.Defaults()
+	if len(d.Size) != 1 || len(d.Latency) != len(DefaultLatencyBuckets) || len(d.ConsumerLag) != len(DefaultConsumerLagBuckets) {
+		t.Errorf("TestBuckets: Defaults(): got %+v, want the set Size and default Latency and ConsumerLag", d)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "awaitingHTTP", want: "awaitingHTTP"},
+		{in: `"awaitingHTTP"`, want: "awaitingHTTP"},
+		{in: " 'events' ", want: "events"},
+		{in: `""`, want: "unknown"},
+		{in: "", want: "unknown"},
+	}
+
+	for _, test := range tests {
+		if got := labelValue(test.in); got != test.want {
+			t.Errorf("TestLabelValue(%q): got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newMeter := func() (otelmetric.Meter, *metric.ManualReader) {
+		reader := metric.NewManualReader()
+		return metric.NewMeterProvider(metric.WithReader(reader)).Meter("test"), reader
+	}
+	sent := func(reader *metric.ManualReader) int64 {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatalf("TestRegistry: Collect(): got err == %s, want err == nil", err)
+		}
+		var n int64
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != metricName("event_sent_total") {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					n += dp.Value
+				}
+			}
+		}
+		return n
+	}
+
+	// Two registries initialized and recorded to at the same time must not see each other's events.
+	meterA, readerA := newMeter()
+	meterB, readerB := newMeter()
+	a, b := NewRegistry(), NewRegistry()
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := a.Init(meterA); err != nil {
+				t.Errorf("TestRegistry: Init(): got err == %s, want err == nil", err)
+			}
+			a.SendEventSuccess(ctx, time.Second, true, 1)
+		}()
+		go func() {
+			defer wg.Done()
+			if err := b.Init(meterB); err != nil {
+				t.Errorf("TestRegistry: Init(): got err == %s, want err == nil", err)
+			}
+			if i%2 == 0 {
+				b.SendEventFailure(ctx, time.Second, true, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := sent(readerA); got != 10 {
+		t.Errorf("TestRegistry: registry a: got %d events, want 10", got)
+	}
+	if got := sent(readerB); got != 5 {
+		t.Errorf("TestRegistry: registry b: got %d events, want 5", got)
+	}
+
+	if got := FromCtx(WithRegistry(ctx, a)); got != a {
+		t.Errorf("TestRegistry: FromCtx(): got %p, want the registry in the ctx %p", got, a)
+	}
+	if got := FromCtx(ctx); got != Default() {
+		t.Errorf("TestRegistry: FromCtx() without a registry: got %p, want Default() %p", got, Default())
+	}
+
+	// A nil or uninitialized registry records nothing and does not panic.
+	var nilRegistry *Registry
+	nilRegistry.SendEventSuccess(ctx, time.Second, true, 1)
+	nilRegistry.Promise(ctx, nil)
+	NewRegistry().ConsumeLag(ctx, time.Second)
+}
@@ -4,20 +4,99 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Azure/arn-sdk/models/internal/private"
 )
 
 var (
-	// ErrPromiseTimeout is returned when the context on a Promise() call times out.
+	// ErrPromiseTimeout is returned when the deadline on a Promise() call passes before the notification's
+	// result is available. The notification may still be sent.
 	ErrPromiseTimeout = fmt.Errorf("promise timeout")
+	// ErrPromiseCanceled is returned when the context on a Promise() call is cancelled by the caller before
+	// the notification's result is available. The notification may still be sent.
+	ErrPromiseCanceled = fmt.Errorf("promise canceled")
+	// ErrPromiseOverflow is sent on the client's Errors() channel when a result could not be delivered on a
+	// promise because the promise already held a result. This happens when a notification or its promise is
+	// reused before the previous result was read. It wraps the result that could not be delivered.
+	ErrPromiseOverflow = fmt.Errorf("promise already held a result (notification or promise reused before its result was read)")
 	// ErrBatchSize is returned when the batch size is too large.
 	ErrBatchSize = fmt.Errorf("batch size too large")
+	// ErrNoBlobClient is returned when a notification exceeds the maximum inline size and the client
+	// was created without a blob storage client (inline-only mode).
+	ErrNoBlobClient = fmt.Errorf("event exceeds max inline size and no blob storage client was provided")
+	// ErrUploadTimeout is returned when uploading a notification to blob storage takes longer than the upload
+	// timeout set with BlobArgs.UploadTimeout, which is separate from the notification's context.
+	ErrUploadTimeout = fmt.Errorf("blob upload timed out")
+	// ErrShutdown is returned for a notification that was not sent because the client was shut down before
+	// it could be, such as when a drain runs out of time.
+	ErrShutdown = fmt.Errorf("client shut down before the notification was sent")
+	// ErrCircuitOpen is returned for a notification that was not sent because the circuit breaker on the ARN
+	// receiver is open after sustained failures. The notification can be buffered and sent again later.
+	ErrCircuitOpen = fmt.Errorf("circuit breaker is open, the ARN receiver is failing")
+	// ErrSpooled is returned for a notification whose event was written to the client's spool directory,
+	// because the ARN receiver was unreachable or events ahead of it were spooled. It is sent when the receiver
+	// can be reached again and should not be sent again by the caller. See client.WithSpoolDir().
+	ErrSpooled = fmt.Errorf("ARN receiver is unreachable, the event was spooled to disk to be sent later")
+	// ErrValidation is wrapped by the error for a notification that was not sent because it is not valid, such
+	// as a missing field, times outside the skew bounds or properties over their limits. It will fail again
+	// unless it is changed. See IsValidation().
+	ErrValidation = fmt.Errorf("notification is not valid")
+	// ErrNoDefaultClient is returned by the package-level Notify() and Async() of the arn package when
+	// SetDefault() has not been called.
+	ErrNoDefaultClient = fmt.Errorf("no default ARN client, call arn.SetDefault()")
 )
 
+// DeadLetterKey is the context key that the error of a notification sent on the dead letter channel set with
+// client.WithDeadLetter() is held under. Use DeadLetterErr() to read it.
+type DeadLetterKey struct{}
+
+// DeadLetterErr returns the error n failed with if n was received from the dead letter channel set with
+// client.WithDeadLetter(). ok is false for any other notification.
+func DeadLetterErr(n Notifications) (err error, ok bool) {
+	ctx := n.Ctx()
+	if ctx == nil {
+		return nil, false
+	}
+	err, ok = ctx.Value(DeadLetterKey{}).(error)
+	return err, ok
+}
+
+// WaitError returns the error for a wait on ctx that ended because ctx is done. It wraps ErrPromiseTimeout
+// if the deadline passed or ErrPromiseCanceled if ctx was cancelled, along with the cause of ctx ending.
+// This separates a caller giving up on a wait from a failure to send the notification, which is returned as is.
+func WaitError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrPromiseTimeout, context.Cause(ctx))
+	}
+	return fmt.Errorf("%w: %w", ErrPromiseCanceled, context.Cause(ctx))
+}
+
 // Event is the interface that is JSON encoded and sent over the wire. Notifications (which are wrappers) are converted to events.
 type Event = private.Event
 
 // Notifications is the interface that must be implemented by all notification types across models.
 type Notifications = private.Notifications
+
+// Batchable is a Notifications that can be coalesced with others into one event, see client.WithBatching().
+type Batchable = private.Batchable
+
+// Resource is a data item in a notification, in a form that does not depend on the schema version. This is
+// for middleware, such as rate limiters and loggers, that handles notifications of any model.
+type Resource = private.Resource
+
+// PayloadHash identifies a version of a resource in a notification. See Notifications.PayloadHashes().
+type PayloadHash = private.PayloadHash
+
+// EventSender sends an event to the ARN receiver. This is what a model's SendEvent() uses to reach the
+// receiver, so new models and tests do not depend on the SDK's HTTP client.
+type EventSender = private.EventSender
+
+// PayloadStore stores the resources of events that are too large to send inline, such as in blob storage.
+type PayloadStore = private.PayloadStore
+
+// StreamStore is a PayloadStore that can upload from a stream, which the SDK's blob storage client does. It
+// is needed to send notifications whose resources are too large to hold in memory, such as msgs.Stream.
+type StreamStore = private.StreamStore
@@ -0,0 +1,190 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/canary"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+var update = flag.Bool("update", false, "Write the events that are sent to the golden files in testdata")
+
+const (
+	sub     = "26fe00f8-9173-4872-9134-bb1d2e00343a"
+	tenant  = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	blobURL = "https://account.blob.core.windows.net/arn/payload.json?sig=redacted"
+)
+
+var (
+	created  = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	modified = time.Date(2024, 5, 2, 11, 30, 0, 0, time.UTC)
+)
+
+// volatile are the fields that change on every send and what they are replaced with before comparing.
+var volatile = []struct {
+	re   *regexp.Regexp
+	repl []byte
+}{
+	{regexp.MustCompile(`"id":"[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}"`), []byte(`"id":"00000000-0000-0000-0000-000000000000"`)},
+	{regexp.MustCompile(`"eventTime":"[^"]*"`), []byte(`"eventTime":"2024-05-02T12:00:00Z"`)},
+	{regexp.MustCompile(`"sdkVersion":"[^"]*"`), []byte(`"sdkVersion":"sdk-version"`)},
+}
+
+func normalize(b []byte) []byte {
+	for _, v := range volatile {
+		b = v.re.ReplaceAll(b, v.repl)
+	}
+	return b
+}
+
+// capture records what is sent to ARN and blob storage.
+type capture struct {
+	event   []byte
+	headers []string
+	blob    []byte
+}
+
+func (c *capture) Send(ctx context.Context, event []byte, headers []string) error {
+	c.event = slices.Clone(event)
+	c.headers = slices.Clone(headers)
+	return nil
+}
+
+func (c *capture) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	c.blob = slices.Clone(b)
+	return url.Parse(blobURL)
+}
+
+func mustID(t *testing.T, s string) *arm.ResourceID {
+	t.Helper()
+	id, err := arm.ParseResourceID(s)
+	if err != nil {
+		t.Fatalf("arm.ParseResourceID(%s): %v", s, err)
+	}
+	return id
+}
+
+// clusterProps are the properties of the resources. A struct is used, as the order of map keys is random.
+type clusterProps struct {
+	ProvisioningState string `json:"provisioningState"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+}
+
+// resource returns a NotificationResource for id. props nil is a delete.
+func resource(t *testing.T, id string, ca types.ChangeAction, props any) types.NotificationResource {
+	t.Helper()
+	rid := mustID(t, id)
+	act := types.ActWrite
+	if props == nil {
+		act = types.ActDelete
+	}
+	ar, err := types.NewArmResource(act, rid, "2024-01-01", props)
+	if err != nil {
+		t.Fatalf("types.NewArmResource(%s): %v", id, err)
+	}
+	return types.NotificationResource{
+		ResourceEventTime: modified,
+		ArmResource:       ar,
+		ResourceID:        rid.String(),
+		APIVersion:        "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			CreatedTime:  created,
+			ModifiedTime: modified,
+			CreatedBy:    "creator@example.com",
+			ModifiedBy:   "modifier@example.com",
+			ChangeAction: ca,
+		},
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	t.Parallel()
+
+	cluster := "/subscriptions/" + sub + "/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"
+	props := clusterProps{ProvisioningState: "Succeeded", KubernetesVersion: "1.30.0"}
+
+	providerScoped := resource(t, "/subscriptions/"+sub+"/providers/Microsoft.ContainerService/fleets/fleet", types.CAUpdate, props)
+	providerScoped.HomeTenantID = tenant
+
+	moved := resource(t, cluster, types.CAMove, props)
+	moved.SourceResourceID = "/subscriptions/" + sub + "/resourceGroups/oldrg/providers/Microsoft.ContainerService/managedClusters/cluster"
+
+	tests := []struct {
+		name string
+		// blob sends the resources through blob storage.
+		blob bool
+		data []types.NotificationResource
+	}{
+		{name: "inline", data: []types.NotificationResource{resource(t, cluster, types.CACreate, props)}},
+		{name: "blob", blob: true, data: []types.NotificationResource{resource(t, cluster, types.CAUpdate, props)}},
+		{name: "provider_scoped", data: []types.NotificationResource{providerScoped}},
+		{name: "delete", data: []types.NotificationResource{resource(t, cluster, types.CADelete, nil)}},
+		{name: "move", data: []types.NotificationResource{moved}},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.blob {
+			// The blob canary sends resources that fit inline through blob storage, which keeps the golden
+			// files small.
+			ctx = canary.WithBlob(ctx)
+		}
+		var n models.Notifications = msgs.Notifications{
+			ResourceLocation: "eastus",
+			PublisherInfo:    "Microsoft.ContainerService",
+			AdditionalBatchProperties: types.AdditionalBatchProperties{
+				BatchCorrelationID: "a2b5c1d4-0f3e-4b6a-9c8d-7e6f5a4b3c2d",
+			},
+			Data: test.data,
+		}
+		n = n.SetCtx(ctx)
+
+		c := &capture{}
+		if err := n.SendEvent(c, c); err != nil {
+			t.Errorf("TestWireFormat(%s): SendEvent() error: %v", test.name, err)
+			continue
+		}
+		if want := []string{"publisherinfo", "Microsoft.ContainerService"}; !slices.Equal(c.headers, want) {
+			t.Errorf("TestWireFormat(%s): got headers %v, want %v", test.name, c.headers, want)
+		}
+
+		golden(t, test.name, filepath.Join("testdata", test.name+".json"), normalize(c.event))
+		if test.blob {
+			golden(t, test.name, filepath.Join("testdata", test.name+".resources.json"), c.blob)
+		} else if c.blob != nil {
+			t.Errorf("TestWireFormat(%s): resources were uploaded to blob storage, want inline", test.name)
+		}
+	}
+}
+
+// golden compares got with the file at path, or writes got to it with -update.
+func golden(t *testing.T, name, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("TestWireFormat(%s): could not update %s: %v", name, path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("TestWireFormat(%s): could not read %s: %v", name, path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("TestWireFormat(%s): %s does not match the wire format:\ngot:  %s\nwant: %s", name, path, got, want)
+	}
+}
@@ -0,0 +1,14 @@
+/*
+Package conformance holds the wire format conformance tests for the v3 model. It has no code, only tests.
+
+Each test builds a notification for one kind of event (inline, blob, provider-scoped, delete and move), sends
+it through the model's SendEvent() and compares the bytes sent to ARN, and the bytes uploaded to blob storage,
+with the golden files in testdata. The fields that change on every send (the event id, the event time and the
+SDK version) are replaced with fixed values before the comparison.
+
+A change to a golden file is a change to what ARN receives. Check it against the ARN v3 schema documentation
+before updating the files with:
+
+	go test ./models/v3/conformance -update
+*/
+package conformance
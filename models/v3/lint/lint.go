@@ -0,0 +1,231 @@
+/*
+Package lint provides client-side checks of v3 notification payloads for common contract mistakes.
+
+ARN and ARG will accept some payloads that they then silently drop or mis-index. The Linter catches the
+common mistakes before a notification is sent, such as a missing "id" in the properties, timestamps that
+are not RFC3339 or string fields that are too large.
+
+Rules can be registered per resource type (the ArmResource.Type, like
+"Microsoft.ContainerService/managedClusters/nodes") to add checks for a specific contract.
+
+Usage:
+
+	diag := make(chan lint.Warning, 100)
+	go func() {
+		for w := range diag {
+			slog.Default().Warn(w.String())
+		}
+	}()
+
+	l, err := lint.New(diag)
+	if err != nil {
+		return err
+	}
+	l.Register("Microsoft.ContainerService/managedClusters/nodes", myNodeRule)
+
+	l.Lint(notification)
+	err := arnClient.Notify(ctx, notification)
+*/
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/go-json-experiment/json"
+)
+
+// DefaultMaxString is the default maximum size in bytes of a string field in the properties.
+const DefaultMaxString = 32 * 1024
+
+// Warning is a problem found in a resource.
+type Warning struct {
+	// ResourceID is the ResourceID of the resource with the problem.
+	ResourceID string
+	// Index is the index of the resource in Notifications.Data.
+	Index int
+	// Path is the JSON path in the properties of the field with the problem, like "status.conditions[0]".
+	// This is empty if the problem is not with a single field.
+	Path string
+	// Msg describes the problem.
+	Msg string
+}
+
+// String implements fmt.Stringer.
+func (w Warning) String() string {
+	if w.Path == "" {
+		return fmt.Sprintf("Data[%d](%s): %s", w.Index, w.ResourceID, w.Msg)
+	}
+	return fmt.Sprintf("Data[%d](%s).%s: %s", w.Index, w.ResourceID, w.Path, w.Msg)
+}
+
+// Rule checks a resource. props is ArmResource.Properties after it has been converted to JSON and back,
+// so it only holds the types from encoding/json (map[string]any, []any, string, float64, bool, nil).
+// props is nil if the resource has no properties or they are not a JSON object.
+// Rules do not need to set Warning.ResourceID or Warning.Index.
+type Rule func(r types.NotificationResource, props map[string]any) []Warning
+
+// Linter checks notifications for common contract mistakes.
+type Linter struct {
+	diag      chan<- Warning
+	maxString int
+
+	mu    sync.RWMutex
+	rules map[string][]Rule
+}
+
+// Option is an option for New().
+type Option func(*Linter) error
+
+// WithMaxString sets the maximum size in bytes of a string field in the properties. Defaults to DefaultMaxString.
+func WithMaxString(n int) Option {
+	return func(l *Linter) error {
+		if n <= 0 {
+			return fmt.Errorf("max string size must be greater than 0")
+		}
+		l.maxString = n
+		return nil
+	}
+}
+
+// New creates a new Linter. Warnings are sent to diag if it is not nil. If diag is full, warnings are dropped,
+// so you are not required to listen on it.
+func New(diag chan<- Warning, options ...Option) (*Linter, error) {
+	l := &Linter{
+		diag:      diag,
+		maxString: DefaultMaxString,
+		rules:     map[string][]Rule{},
+	}
+	for _, o := range options {
+		if err := o(l); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Register registers rules that run on every resource with the ArmResource.Type of rscType.
+// These run in addition to the built-in rules. Thread-safe.
+func (l *Linter) Register(rscType string, rules ...Rule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := strings.ToLower(rscType)
+	l.rules[key] = append(l.rules[key], rules...)
+}
+
+// Lint checks all the resources in n and returns the warnings. The warnings are also sent to the
+// diagnostics channel. Thread-safe.
+func (l *Linter) Lint(n msgs.Notifications) []Warning {
+	var warnings []Warning
+	for i, r := range n.Data {
+		for _, w := range l.lintResource(r) {
+			w.ResourceID = r.ResourceID
+			w.Index = i
+			warnings = append(warnings, w)
+			l.send(w)
+		}
+	}
+	return warnings
+}
+
+func (l *Linter) send(w Warning) {
+	if l.diag == nil {
+		return
+	}
+	select {
+	case l.diag <- w:
+	default:
+	}
+}
+
+// lintResource runs the built-in rules and any registered rules on r.
+func (l *Linter) lintResource(r types.NotificationResource) []Warning {
+	var warnings []Warning
+
+	props, w := toProps(r.ArmResource.Properties)
+	warnings = append(warnings, w...)
+
+	if props != nil {
+		warnings = append(warnings, missingID(props)...)
+		warnings = append(warnings, l.walk("", props)...)
+	}
+
+	l.mu.RLock()
+	rules := l.rules[strings.ToLower(r.ArmResource.Type)]
+	l.mu.RUnlock()
+
+	for _, rule := range rules {
+		warnings = append(warnings, rule(r, props)...)
+	}
+	return warnings
+}
+
+// toProps converts properties to a JSON object.
+func toProps(properties any) (map[string]any, []Warning) {
+	if properties == nil {
+		return nil, nil
+	}
+
+	b, err := types.MarshalProperties(properties)
+	if err != nil {
+		return nil, []Warning{{Msg: fmt.Sprintf("ArmResource.Properties cannot be marshaled to JSON: %s", err)}}
+	}
+
+	var props map[string]any
+	if err := json.Unmarshal(b, &props); err != nil {
+		return nil, []Warning{{Msg: "ArmResource.Properties must marshal to a JSON object"}}
+	}
+	return props, nil
+}
+
+// missingID checks that the properties have an "id" field.
+func missingID(props map[string]any) []Warning {
+	id, ok := props["id"]
+	if !ok {
+		return []Warning{{Msg: `ArmResource.Properties is missing "id"`}}
+	}
+	if s, ok := id.(string); !ok || s == "" {
+		return []Warning{{Path: "id", Msg: `"id" must be a non-empty string`}}
+	}
+	return nil
+}
+
+// timeRE matches strings that start like a date, which we then require to be RFC3339.
+var timeRE = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}`)
+
+// walk walks v and checks all strings for non-RFC3339 timestamps and oversized values.
+func (l *Linter) walk(path string, v any) []Warning {
+	var warnings []Warning
+
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			warnings = append(warnings, l.walk(p, val)...)
+		}
+	case []any:
+		for i, val := range x {
+			warnings = append(warnings, l.walk(fmt.Sprintf("%s[%d]", path, i), val)...)
+		}
+	case string:
+		if len(x) > l.maxString {
+			warnings = append(warnings, Warning{Path: path, Msg: fmt.Sprintf("string is %d bytes, which is larger than %d", len(x), l.maxString)})
+		}
+		if timeRE.MatchString(x) {
+			if _, err := time.Parse(time.RFC3339Nano, x); err != nil {
+				warnings = append(warnings, Warning{Path: path, Msg: fmt.Sprintf("timestamp %q is not RFC3339", x)})
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/v3/msgs"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+const (
+	prefix = `/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something/`
+	suffix = `nodes/aks-nodepool1-12345678-vmss000000`
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID(path.Join(prefix, suffix))
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name     string
+		props    any
+		rules    []Rule
+		wantMsgs []string
+	}{
+		{
+			name:  "No problems",
+			props: map[string]any{"id": rescID.String(), "created": "2024-01-01T00:00:00Z"},
+		},
+		{
+			name:     "Properties are not an object",
+			props:    []string{"a"},
+			wantMsgs: []string{"ArmResource.Properties must marshal to a JSON object"},
+		},
+		{
+			name:     "Missing id",
+			props:    map[string]any{"name": "node"},
+			wantMsgs: []string{`ArmResource.Properties is missing "id"`},
+		},
+		{
+			name:     "Timestamp is not RFC3339",
+			props:    map[string]any{"id": rescID.String(), "status": map[string]any{"times": []any{"2024-01-01 00:00:00"}}},
+			wantMsgs: []string{`status.times[0]: timestamp "2024-01-01 00:00:00" is not RFC3339`},
+		},
+		{
+			name:     "Oversized string",
+			props:    map[string]any{"id": rescID.String(), "big": strings.Repeat("a", 201)},
+			wantMsgs: []string{"big: string is 201 bytes, which is larger than 200"},
+		},
+		{
+			name:  "Registered rule",
+			props: map[string]any{"id": rescID.String()},
+			rules: []Rule{
+				func(r types.NotificationResource, props map[string]any) []Warning {
+					if _, ok := props["nodeInfo"]; !ok {
+						return []Warning{{Msg: "missing nodeInfo"}}
+					}
+					return nil
+				},
+			},
+			wantMsgs: []string{"missing nodeInfo"},
+		},
+	}
+
+	for _, test := range tests {
+		diag := make(chan Warning, 10)
+		l, err := New(diag, WithMaxString(200))
+		if err != nil {
+			panic(err)
+		}
+		l.Register("Microsoft.ContainerService/managedClusters/nodes", test.rules...)
+
+		armRsc, err := types.NewArmResource(types.ActWrite, rescID, "2024-01-01", test.props)
+		if err != nil {
+			panic(err)
+		}
+		n := msgs.Notifications{
+			Data: []types.NotificationResource{
+				{ResourceID: rescID.String(), ArmResource: armRsc},
+			},
+		}
+
+		got := l.Lint(n)
+		if len(diag) != len(got) {
+			t.Errorf("TestLint(%s): got %d warnings on the diag channel, want %d", test.name, len(diag), len(got))
+		}
+
+		var gotMsgs []string
+		for _, w := range got {
+			if w.ResourceID != rescID.String() || w.Index != 0 {
+				t.Errorf("TestLint(%s): warning did not have ResourceID or Index set: %+v", test.name, w)
+			}
+			msg := w.Msg
+			if w.Path != "" {
+				msg = w.Path + ": " + w.Msg
+			}
+			gotMsgs = append(gotMsgs, msg)
+		}
+		slices.Sort(gotMsgs)
+		if diff := pretty.Compare(test.wantMsgs, gotMsgs); diff != "" {
+			t.Errorf("TestLint(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package msgs
+
+import (
+	"fmt"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/arn-sdk/models/version"
+)
+
+// Compile time check to ensure Notifications implements models.Batchable.
+var _ models.Batchable = Notifications{}
+
+// batchKey is the part of a notification that must be the same for it to share an event with another.
+type batchKey struct {
+	resourceLocation  string
+	frontdoorLocation string
+	publisherInfo     string
+	apiVersion        string
+	dataBoundary      types.DataBoundary
+	subject           string
+	metadataVersion   string
+	dataVersion       version.Schema
+}
+
+// BatchKey implements models.Batchable.BatchKey(). Notifications can share an event if they have the same
+// ResourceLocation, FrontdoorLocation, PublisherInfo, DataBoundary, Subject, versions and a single APIVersion
+// across their Data. A Stream, or a notification with a BatchCorrelationID or Others in its
+// AdditionalBatchProperties, describes a batch of its own and is never coalesced.
+func (n Notifications) BatchKey() (any, bool) {
+	if n.Stream != nil || len(n.Data) == 0 || !n.eventTime.IsZero() {
+		return nil, false
+	}
+	if n.AdditionalBatchProperties.BatchCorrelationID != "" || len(n.AdditionalBatchProperties.Others) > 0 {
+		return nil, false
+	}
+	apiVersion := n.Data[0].APIVersion
+	for _, r := range n.Data[1:] {
+		if r.APIVersion != apiVersion {
+			return nil, false
+		}
+	}
+	return batchKey{
+		resourceLocation:  n.ResourceLocation,
+		frontdoorLocation: n.FrontdoorLocation,
+		publisherInfo:     n.PublisherInfo,
+		apiVersion:        apiVersion,
+		dataBoundary:      n.DataBoundary,
+		subject:           n.Subject,
+		metadataVersion:   n.MetadataVersion,
+		dataVersion:       n.DataVersion,
+	}, true
+}
+
+// Merge implements models.Batchable.Merge(). The data items are not copied, so the rules about changing
+// Data after handing a notification to the client apply to the merged notification.
+func (n Notifications) Merge(others []models.Notifications) (models.Notifications, error) {
+	key, ok := n.BatchKey()
+	if !ok {
+		return nil, fmt.Errorf("notification cannot be merged")
+	}
+
+	size := len(n.Data)
+	for _, o := range others {
+		size += o.DataCount()
+	}
+	out := Notifications{
+		ResourceLocation:  n.ResourceLocation,
+		FrontdoorLocation: n.FrontdoorLocation,
+		PublisherInfo:     n.PublisherInfo,
+		DataBoundary:      n.DataBoundary,
+		Subject:           n.Subject,
+		MetadataVersion:   n.MetadataVersion,
+		DataVersion:       n.DataVersion,
+		Data:              make([]types.NotificationResource, 0, size),
+	}
+	out.Data = append(out.Data, n.Data...)
+	for i, o := range others {
+		on, ok := o.(Notifications)
+		if !ok {
+			return nil, fmt.Errorf("others[%d] is a %T, not a msgs.Notifications", i, o)
+		}
+		if k, ok := on.BatchKey(); !ok || k != key {
+			return nil, fmt.Errorf("others[%d] cannot be sent in the same event", i)
+		}
+		out.Data = append(out.Data, on.Data...)
+	}
+	return out, nil
+}
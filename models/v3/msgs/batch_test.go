@@ -0,0 +1,96 @@
+package msgs
+
+import (
+	"testing"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestBatchKey(t *testing.T) {
+	t.Parallel()
+
+	base := func() Notifications {
+		return Notifications{
+			ResourceLocation: "eastus",
+			PublisherInfo:    "Microsoft.Compute",
+			Data:             []types.NotificationResource{{ResourceID: "a", APIVersion: "2024-01-01"}, {ResourceID: "b", APIVersion: "2024-01-01"}},
+		}
+	}
+	key, ok := base().BatchKey()
+	if !ok {
+		t.Fatalf("TestBatchKey: base notification cannot be batched")
+	}
+
+	tests := []struct {
+		name     string
+		change   func(n *Notifications)
+		wantOK   bool
+		wantSame bool
+	}{
+		{name: "Same", change: func(n *Notifications) {}, wantOK: true, wantSame: true},
+		{name: "Other resources", change: func(n *Notifications) { n.Data = n.Data[:1] }, wantOK: true, wantSame: true},
+		{name: "Other location", change: func(n *Notifications) { n.ResourceLocation = "westus" }, wantOK: true},
+		{name: "Other publisher", change: func(n *Notifications) { n.PublisherInfo = "Microsoft.Network" }, wantOK: true},
+		{name: "Other data boundary", change: func(n *Notifications) { n.DataBoundary = types.DBEU }, wantOK: true},
+		{name: "Other subject", change: func(n *Notifications) { n.Subject = "/" }, wantOK: true},
+		{name: "Other APIVersion", change: func(n *Notifications) {
+			n.Data = []types.NotificationResource{{ResourceID: "a", APIVersion: "2023-01-01"}}
+		}, wantOK: true},
+		{name: "Mixed APIVersions", change: func(n *Notifications) { n.Data[1].APIVersion = "2023-01-01" }},
+		{name: "No data", change: func(n *Notifications) { n.Data = nil }},
+		{name: "Stream", change: func(n *Notifications) { n.Data = nil; n.Stream = &Stream{} }},
+		{name: "BatchCorrelationID", change: func(n *Notifications) { n.AdditionalBatchProperties.BatchCorrelationID = "id" }},
+		{name: "Others", change: func(n *Notifications) { n.AdditionalBatchProperties.Others = map[string]any{"k": "v"} }},
+	}
+
+	for _, test := range tests {
+		n := base()
+		test.change(&n)
+		got, ok := n.BatchKey()
+		if ok != test.wantOK {
+			t.Errorf("TestBatchKey(%s): got ok == %v, want %v", test.name, ok, test.wantOK)
+			continue
+		}
+		if ok && (got == key) != test.wantSame {
+			t.Errorf("TestBatchKey(%s): got same key == %v, want %v", test.name, got == key, test.wantSame)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	n := func(ids ...string) Notifications {
+		out := Notifications{ResourceLocation: "eastus", PublisherInfo: "Microsoft.Compute", DataBoundary: types.DBGlobal, Subject: "/"}
+		for _, id := range ids {
+			out.Data = append(out.Data, types.NotificationResource{ResourceID: id, APIVersion: "2024-01-01"})
+		}
+		return out
+	}
+
+	got, err := n("a").Merge([]models.Notifications{n("b", "c"), n("d")})
+	if err != nil {
+		t.Fatalf("TestMerge: got err == %s, want err == nil", err)
+	}
+	m := got.(Notifications)
+	var ids []string
+	for _, r := range m.Data {
+		ids = append(ids, r.ResourceID)
+	}
+	if len(ids) != 4 || ids[0] != "a" || ids[1] != "b" || ids[2] != "c" || ids[3] != "d" {
+		t.Errorf("TestMerge: got resources %v, want [a b c d]", ids)
+	}
+	if m.ResourceLocation != "eastus" || m.PublisherInfo != "Microsoft.Compute" || m.DataBoundary != types.DBGlobal || m.Subject != "/" {
+		t.Errorf("TestMerge: envelope fields were not kept: %+v", m)
+	}
+
+	other := n("b")
+	other.ResourceLocation = "westus"
+	if _, err := n("a").Merge([]models.Notifications{other}); err == nil {
+		t.Errorf("TestMerge(other location): got err == nil, want err != nil")
+	}
+	if _, err := (Notifications{}).Merge(nil); err == nil {
+		t.Errorf("TestMerge(no data): got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,175 @@
+package msgs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// SizeReport is a breakdown of the serialized size of a Notifications. This is used to find out why a
+// payload is larger than expected so that it can be trimmed.
+type SizeReport struct {
+	// Total is the size in bytes of all the resources serialized to JSON.
+	Total int
+	// InlineLimit is the size that Total must be under for the resources to be sent inline.
+	InlineLimit int
+	// Resources is the serialized size of each resource, in the same order as Notifications.Data.
+	Resources []ResourceSize
+	// Largest are the largest properties found in ArmResource.Properties across all resources, largest first.
+	Largest []PropertySize
+}
+
+// Inline returns true if the resources can be sent inline.
+func (s SizeReport) Inline() bool {
+	return s.Total < s.InlineLimit
+}
+
+// String implements fmt.Stringer.
+func (s SizeReport) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "total size %d bytes (inline limit %d bytes) across %d resources", s.Total, s.InlineLimit, len(s.Resources))
+	if len(s.Largest) > 0 {
+		b.WriteString(", largest properties:")
+		for _, p := range s.Largest {
+			fmt.Fprintf(&b, " %s(%d)", p.Path, p.Size)
+		}
+	}
+	return b.String()
+}
+
+// ResourceSize is the serialized size of a single resource.
+type ResourceSize struct {
+	// Index is the index in Notifications.Data.
+	Index int
+	// ResourceID is the ResourceID of the resource.
+	ResourceID string
+	// Size is the size in bytes of the resource serialized to JSON.
+	Size int
+}
+
+// PropertySize is the serialized size of a single top-level field in ArmResource.Properties.
+type PropertySize struct {
+	// Path is the path to the property, like "Data[0].armResource.properties.status".
+	Path string
+	// Size is the size in bytes of the property value serialized to JSON.
+	Size int
+}
+
+// SizeError is returned when a notification is too large to be sent. It wraps the underlying
+// error and includes a SizeReport to help find what to trim.
+type SizeError struct {
+	// Report is the size breakdown of the notification.
+	Report SizeReport
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Report)
+}
+
+// Unwrap returns the underlying error.
+func (e *SizeError) Unwrap() error {
+	return e.Err
+}
+
+// explainTop is the number of properties reported in a SizeError.
+const explainTop = 5
+
+// Explain reports the serialized size of each resource and the top n largest properties in
+// ArmResource.Properties. Use this when a payload is unexpectedly larger than the inline size.
+func (n Notifications) Explain(top int) (SizeReport, error) {
+	total, err := n.dataToJSON()
+	if err != nil {
+		return SizeReport{}, err
+	}
+
+	report := SizeReport{
+		Total:       len(total),
+		InlineLimit: maxvals.InlineSize,
+		Resources:   make([]ResourceSize, 0, len(n.Data)),
+	}
+
+	for i, r := range n.Data {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return SizeReport{}, fmt.Errorf("Data[%d]: %w", i, err)
+		}
+		report.Resources = append(report.Resources, ResourceSize{Index: i, ResourceID: r.ResourceID, Size: len(b)})
+
+		if top <= 0 || r.ArmResource.Properties == nil {
+			continue
+		}
+
+		b, err = types.MarshalProperties(r.ArmResource.Properties)
+		if err != nil {
+			return SizeReport{}, fmt.Errorf("Data[%d].ArmResource.Properties: %w", i, err)
+		}
+		props := map[string]jsontext.Value{}
+		if err := json.Unmarshal(b, &props); err != nil {
+			// Properties that are not a JSON object have no fields to report.
+			continue
+		}
+		for k, v := range props {
+			report.Largest = append(report.Largest, PropertySize{
+				Path: fmt.Sprintf("Data[%d].armResource.properties.%s", i, k),
+				Size: len(v),
+			})
+		}
+	}
+
+	slices.SortFunc(report.Largest, func(a, b PropertySize) int {
+		if a.Size != b.Size {
+			return b.Size - a.Size
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	if len(report.Largest) > top {
+		report.Largest = report.Largest[:max(top, 0)]
+	}
+	return report, nil
+}
+
+// EstimateSize returns the size in bytes that resources serialize to when sent in a notification and
+// whether that is small enough to be sent inline, without uploading to blob storage. Producers that build a
+// batch one resource at a time can use this to stop adding resources just before the batch would need blob
+// storage. This serializes resources, so it costs about as much as the encoding done when the notification
+// is sent.
+func EstimateSize(resources []types.NotificationResource) (bytes int, inline bool, err error) {
+	if len(resources) == 0 {
+		return 0, false, errors.New("resources must not be empty")
+	}
+	return Notifications{Data: resources}.EstimateSize()
+}
+
+// EstimateSize returns the size in bytes of the resources of n as they are sent and whether SendEvent() will
+// send them inline rather than through blob storage, so a caller can split a batch before sending it instead
+// of finding out from a SizeError or the blob upload. For a Stream, this is Stream.Size and false, as a
+// Stream is always sent through blob storage. This serializes the resources, so it costs about as much as
+// the encoding done when the notification is sent.
+//
+// The estimate is of the resources as they are given. A classification policy, see
+// client.WithClassificationPolicy(), can redact properties when the notification is sent, which only makes
+// it smaller, and a blob canary, see client.WithBlobCanary(), sends a notification that fits inline through
+// blob storage.
+func (n Notifications) EstimateSize() (bytes int, inline bool, err error) {
+	if n.Stream != nil {
+		return int(n.Stream.Size), false, nil
+	}
+	if len(n.Data) == 0 {
+		return 0, false, errors.New("Data must not be empty")
+	}
+	b, inline, err := n.inline()
+	if err != nil {
+		return 0, false, err
+	}
+	return len(b), inline, nil
+}
@@ -0,0 +1,198 @@
+package msgs
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	prefix := `/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something/`
+	suffix := `nodes/aks-nodepool1-12345678-vmss000000`
+	rescID, err := arm.ParseResourceID(path.Join(prefix, suffix))
+	if err != nil {
+		panic(err)
+	}
+
+	props := map[string]any{
+		"small":  "a",
+		"medium": strings.Repeat("b", 100),
+		"large":  strings.Repeat("c", maxvals.InlineSize),
+	}
+
+	n := Notifications{
+		Data: []types.NotificationResource{
+			{
+				ResourceID:  rescID.String(),
+				ArmResource: mustNewArm(types.ActWrite, rescID, "2024-01-01", props),
+			},
+		},
+	}
+
+	report, err := n.Explain(2)
+	if err != nil {
+		t.Fatalf("TestExplain: got err == %s, want err == nil", err)
+	}
+
+	if report.Inline() {
+		t.Errorf("TestExplain: got Inline() == true, want false")
+	}
+	if len(report.Resources) != 1 || report.Resources[0].Size == 0 {
+		t.Errorf("TestExplain: got Resources == %+v, want a single resource with a size", report.Resources)
+	}
+
+	want := []PropertySize{
+		{Path: "Data[0].armResource.properties.large", Size: maxvals.InlineSize + 2},
+		{Path: "Data[0].armResource.properties.medium", Size: 102},
+	}
+	if diff := pretty.Compare(want, report.Largest); diff != "" {
+		t.Errorf("TestExplain: -want/+got:\n%s", diff)
+	}
+
+	_, err = n.sendBlob(nil, nil)
+	var sizeErr *SizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("TestExplain: sendBlob() with no store: got %T, want *SizeError", err)
+	}
+	if !errors.Is(err, models.ErrNoBlobClient) {
+		t.Errorf("TestExplain: sendBlob() with no store: got %v, want models.ErrNoBlobClient", err)
+	}
+	if len(sizeErr.Report.Largest) != min(explainTop, len(props)) {
+		t.Errorf("TestExplain: SizeError.Report.Largest: got %d entries, want %d", len(sizeErr.Report.Largest), min(explainTop, len(props)))
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID(`/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something`)
+	if err != nil {
+		panic(err)
+	}
+	resource := func(size int) types.NotificationResource {
+		return types.NotificationResource{
+			ResourceID:  rescID.String(),
+			ArmResource: mustNewArm(types.ActWrite, rescID, "2024-01-01", map[string]any{"data": strings.Repeat("a", size)}),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		resources  []types.NotificationResource
+		wantInline bool
+		wantErr    bool
+	}{
+		{name: "Error: no resources", wantErr: true},
+		{name: "Small", resources: []types.NotificationResource{resource(10)}, wantInline: true},
+		{name: "Many small", resources: []types.NotificationResource{resource(10), resource(100), resource(1000)}, wantInline: true},
+		{name: "Too large", resources: []types.NotificationResource{resource(maxvals.InlineSize)}},
+		{name: "Too large together", resources: []types.NotificationResource{resource(maxvals.InlineSize / 2), resource(maxvals.InlineSize / 2)}},
+	}
+
+	for _, test := range tests {
+		size, inline, err := EstimateSize(test.resources)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestEstimateSize(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestEstimateSize(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if inline != test.wantInline {
+			t.Errorf("TestEstimateSize(%s): got inline == %v, want %v", test.name, inline, test.wantInline)
+		}
+		// The estimate must match what is decided when the notification is sent.
+		b, sendInline, err := Notifications{Data: test.resources}.inline()
+		if err != nil {
+			t.Fatalf("TestEstimateSize(%s): inline(): got err == %s, want err == nil", test.name, err)
+		}
+		if size != len(b) || inline != sendInline {
+			t.Errorf("TestEstimateSize(%s): got (%d, %v), want (%d, %v) as when sent", test.name, size, inline, len(b), sendInline)
+		}
+	}
+}
+
+func TestNotificationsEstimateSize(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID(`/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something`)
+	if err != nil {
+		panic(err)
+	}
+	resource := func(size int) types.NotificationResource {
+		return types.NotificationResource{
+			ResourceID:  rescID.String(),
+			ArmResource: mustNewArm(types.ActWrite, rescID, "2024-01-01", map[string]any{"data": strings.Repeat("a", size)}),
+		}
+	}
+	small := []types.NotificationResource{resource(10)}
+	smallSize, _, err := EstimateSize(small)
+	if err != nil {
+		t.Fatalf("TestNotificationsEstimateSize: EstimateSize(): got err == %s, want err == nil", err)
+	}
+
+	tests := []struct {
+		name       string
+		n          Notifications
+		wantBytes  int
+		wantInline bool
+		wantErr    bool
+	}{
+		{name: "Error: no data", wantErr: true},
+		{name: "Inline", n: Notifications{Data: small}, wantBytes: smallSize, wantInline: true},
+		{name: "Stream", n: Notifications{Stream: &Stream{Size: 100}}, wantBytes: 100},
+	}
+
+	for _, test := range tests {
+		bytes, inline, err := test.n.EstimateSize()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestNotificationsEstimateSize(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestNotificationsEstimateSize(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if bytes != test.wantBytes || inline != test.wantInline {
+			t.Errorf("TestNotificationsEstimateSize(%s): got (%d, %v), want (%d, %v)", test.name, bytes, inline, test.wantBytes, test.wantInline)
+		}
+	}
+
+	// The estimate matches the size and container the event is sent with.
+	var sent envelope.Event
+	n := Notifications{Data: []types.NotificationResource{resource(maxvals.InlineSize)}}
+	n.testSendHTTP = func(hc models.EventSender, event envelope.Event) error {
+		sent = event
+		return nil
+	}
+	n.testSendBlob = func(store models.PayloadStore, b []byte) (*url.URL, error) {
+		return url.Parse("https://blob")
+	}
+	bytes, inline, err := n.EstimateSize()
+	if err != nil {
+		t.Fatalf("TestNotificationsEstimateSize(blob): got err == %s, want err == nil", err)
+	}
+	if err := n.SendEvent(nil, nil); err != nil {
+		t.Fatalf("TestNotificationsEstimateSize(blob): SendEvent(): got err == %s, want err == nil", err)
+	}
+	if inline || sent.Data.ResourcesContainer != types.RCBlob || int64(bytes) != sent.Data.ResourcesBlobInfo.BlobSize {
+		t.Errorf("TestNotificationsEstimateSize(blob): got (%d, %v), sent %d bytes in %s", bytes, inline, sent.Data.ResourcesBlobInfo.BlobSize, sent.Data.ResourcesContainer)
+	}
+}
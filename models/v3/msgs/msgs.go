@@ -1,19 +1,40 @@
 package msgs
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
 	"math"
 	"net/url"
+	"runtime/pprof"
+	"runtime/trace"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/Azure/arn-sdk/internal/conn"
-	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/internal/build"
+	"github.com/Azure/arn-sdk/internal/conn/canary"
+	"github.com/Azure/arn-sdk/internal/conn/classify"
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/internal/conn/eventinfo"
+	"github.com/Azure/arn-sdk/internal/conn/itemsize"
 	"github.com/Azure/arn-sdk/internal/conn/maxvals"
-	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/internal/conn/prepared"
+	"github.com/Azure/arn-sdk/internal/conn/preview"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/internal/conn/proplimits"
+	"github.com/Azure/arn-sdk/internal/conn/provenance"
+	"github.com/Azure/arn-sdk/internal/conn/skew"
+	"github.com/Azure/arn-sdk/internal/conn/stats"
+	"github.com/Azure/arn-sdk/internal/conn/timing"
+	"github.com/Azure/arn-sdk/internal/conn/tracing"
+	"github.com/Azure/arn-sdk/internal/conn/txn"
+	"github.com/Azure/arn-sdk/internal/conn/watchdog"
 	"github.com/Azure/arn-sdk/models"
 	"github.com/Azure/arn-sdk/models/metrics"
 	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
@@ -21,6 +42,7 @@ import (
 	"github.com/Azure/arn-sdk/models/version"
 
 	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
 	"github.com/google/uuid"
 )
 
@@ -49,58 +71,153 @@ type Notifications struct {
 	PublisherInfo string
 	// AdditionalBatchProperties can contain the sdkversion, batchsize, subscription partition tag etc.
 	AdditionalBatchProperties types.AdditionalBatchProperties
-
-	// Data is the data to send in the notification.
+	// DataBoundary is the data boundary of the resources in this notification. It is optional. With a
+	// classification policy set on the client, classified fields in ArmResource.Properties are checked against
+	// it, see types.ClassTag.
+	DataBoundary types.DataBoundary
+	// Subject overrides the Subject of the event, which is the longest scope shared by the resource IDs in
+	// Data, such as their resource group, or "/" if they share none. Some onboarding configurations require
+	// the subject to be a shallower scope, such as the subscription, even when the resources share a deeper
+	// one. It must be "/" or a scope of every resource ID in Data, such as "/subscriptions/{id}", or the
+	// notification fails with models.ErrValidation. With a Stream, it is checked against Stream.Sample.
+	Subject string
+
+	// Data is the data to send in the notification. Data is serialized when the notification is sent, which
+	// happens after Async() returns, and again if it is resent. Do not change Data, or anything it refers to,
+	// after handing the notification to the client. Use Clone() to keep a copy that can be changed.
 	Data []types.NotificationResource
-
-	testSendHTTP func(*http.Client, envelope.Event) error
-	testSendBlob func(*storage.Client, []byte) (*url.URL, error)
+	// Stream replaces Data for a notification too large to hold in memory, see Stream. Data must be empty
+	// when this is set. Resources() and PayloadHashes() do not include the resources of a Stream.
+	Stream *Stream
+
+	// MetadataVersion overrides the EventMeta.MetadataVersion of the event, which defaults to
+	// envelope.MetadataVersion. Only set this to trial a receiver preview, the version must be allowed with
+	// client.WithPreviewVersions().
+	MetadataVersion string
+	// DataVersion overrides the EventMeta.DataVersion of the event, which defaults to version.V3. Only set
+	// this to trial a receiver preview, the version must be allowed with client.WithPreviewVersions().
+	DataVersion version.Schema
+
+	// eventTime replaces the EventTime of the event when it is not zero. This is set when the EventTime is
+	// corrected for local clock skew.
+	eventTime time.Time
+
+	testSendHTTP func(models.EventSender, envelope.Event) error
+	testSendBlob func(models.PayloadStore, []byte) (*url.URL, error)
 }
 
-// Promise waits for the promise to be fulfilled. This will return an ErrPromiseTimeout if the context
-// passed times out to distiguish it from a context timeout on sending the notification.
+// Promise waits for the promise to be fulfilled. If ctx is done first, this returns an error wrapping
+// models.ErrPromiseTimeout (deadline passed) or models.ErrPromiseCanceled (cancelled) to distinguish it from
+// an error sending the notification. If the notification's context has an earlier deadline than ctx, the
+// wait inherits that deadline.
 func (n Notifications) Promise(ctx context.Context) error {
 	if n.promise == nil {
 		return nil
 	}
-	defer func() {
-		conn.PromisePool.Put(n.promise)
-	}()
 
-	if ctx.Err() != nil {
-		metrics.Promise(context.Background(), ctx.Err())
-		return ctx.Err()
+	ctx, cancel := n.waitCtx(ctx)
+	defer cancel()
+
+	if ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case e := <-n.promise:
+			metrics.FromCtx(n.ctx).Promise(context.Background(), e)
+			return e
+		}
 	}
 
-	select {
-	case <-ctx.Done():
-		metrics.Promise(context.Background(), models.ErrPromiseTimeout)
-		return models.ErrPromiseTimeout
-	case e := <-n.promise:
-		metrics.Promise(context.Background(), e)
+	if ok, e := n.extendWait(ctx); ok {
+		metrics.FromCtx(n.ctx).Promise(context.Background(), e)
 		return e
 	}
+	// The promise channel is still owned by the sender, so it must not be recycled.
+	err := models.WaitError(ctx)
+	metrics.FromCtx(n.ctx).Promise(context.Background(), err)
+	return err
 }
 
-// Recycle can be used to recycle the promise of a notification once it has been used.
-// This is for internal use and should not be called.
-// It is a terrible idea to use the promise after it has been recycled.
-func (n Notifications) Recycle() {
-	if n.promise != nil {
+// extendWait keeps waiting for the promise after the deadline of ctx passes, for as long as the notification
+// makes progress in the send pipeline. This only happens if the client was created with
+// client.WithPromiseExtension(). It returns false if the wait should end without a result.
+func (n Notifications) extendWait(ctx context.Context) (bool, error) {
+	t := progress.FromCtx(n.ctx)
+	if t == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false, nil
+	}
+	d, _ := ctx.Deadline()
+
+	for {
+		wait := t.Extend(d)
+		if wait <= 0 {
+			return false, nil
+		}
+		timer := time.NewTimer(wait)
 		select {
-		case <-n.promise:
-		default:
+		case e := <-n.promise:
+			timer.Stop()
+			return true, e
+		case <-timer.C:
 		}
-		conn.PromisePool.Put(n.promise)
 	}
 }
 
+// waitCtx returns ctx with the deadline of the notification's context if it is earlier.
+func (n Notifications) waitCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if n.ctx == nil {
+		return ctx, func() {}
+	}
+	d, ok := n.ctx.Deadline()
+	if !ok {
+		return ctx, func() {}
+	}
+	if cur, ok := ctx.Deadline(); ok && !d.Before(cur) {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, d)
+}
+
+// Recycle does nothing. The SDK recycles the promises it owns itself, so that a promise the send pipeline
+// may still write to is never reused.
+//
+// Deprecated: There is no need to call Recycle(), it is kept so existing code compiles.
+func (n Notifications) Recycle() {}
+
+// Clone returns a deep copy of the Notifications, which is needed to keep a notification after the caller
+// may change the structures it shares, such as ArmResource.Properties. See types.RegisterCopier() for how
+// properties are copied. The clone keeps the context but not the promise, so it is a new notification.
+func (n Notifications) Clone() (Notifications, error) {
+	n.promise = nil
+
+	abp, err := n.AdditionalBatchProperties.Clone()
+	if err != nil {
+		return Notifications{}, fmt.Errorf("AdditionalBatchProperties%w", err)
+	}
+	n.AdditionalBatchProperties = abp
+
+	if n.Data == nil {
+		return n, nil
+	}
+	data := make([]types.NotificationResource, len(n.Data))
+	for i, r := range n.Data {
+		data[i], err = r.Clone()
+		if err != nil {
+			return Notifications{}, fmt.Errorf("Data[%d]%w", i, err)
+		}
+	}
+	n.Data = data
+	return n, nil
+}
+
 func (n Notifications) Ctx() context.Context {
 	return n.ctx
 }
 
 // DataCount implements models.Notifications.DataCount().
 func (n Notifications) DataCount() int {
+	if n.Stream != nil {
+		return n.Stream.Count
+	}
 	return len(n.Data)
 }
 
@@ -109,6 +226,48 @@ func (n Notifications) Version() version.Schema {
 	return version.V3
 }
 
+// DataSizeHint implements models.Notifications.DataSizeHint(). This serializes the data, so it costs
+// about as much as the encoding done when the notification is sent. For a Stream, this is Stream.Size.
+func (n Notifications) DataSizeHint() int {
+	if n.Stream != nil {
+		return int(n.Stream.Size)
+	}
+	b, err := n.dataToJSON()
+	if err != nil {
+		return -1
+	}
+	return len(b)
+}
+
+// Resources implements models.Notifications.Resources().
+func (n Notifications) Resources() iter.Seq[models.Resource] {
+	return func(yield func(models.Resource) bool) {
+		for _, r := range n.Data {
+			if !yield(models.Resource{ID: r.ResourceID, APIVersion: r.APIVersion, EventTime: r.ResourceEventTime}) {
+				return
+			}
+		}
+	}
+}
+
+// PayloadHashes implements models.Notifications.PayloadHashes(). The hash covers the JSON of the data
+// item with ResourceEventTime cleared, so a resource that is sent again unchanged has the same hash. A data
+// item that cannot be serialized is skipped.
+func (n Notifications) PayloadHashes() iter.Seq2[string, models.PayloadHash] {
+	return func(yield func(string, models.PayloadHash) bool) {
+		for _, r := range n.Data {
+			r.ResourceEventTime = time.Time{}
+			b, err := json.Marshal(r, json.Deterministic(true))
+			if err != nil {
+				continue
+			}
+			if !yield(r.ResourceID, sha256.Sum256(b)) {
+				return
+			}
+		}
+	}
+}
+
 // GetPublisherInfo implements models.Notifications.GetPublisherInfo().
 func (n Notifications) GetPublisherInfo() string {
 	return n.PublisherInfo
@@ -126,7 +285,10 @@ func (n Notifications) SetPromise(promise chan error) models.Notifications {
 	return n
 }
 
-// SendPromise sends an error on the promise to the notification.
+// SendPromise sends an error on the promise to the notification. A promise holds a single result, so if it
+// already holds one (the notification or its promise was reused before the result was read), the result is
+// not dropped. Instead it is escalated: it is logged and sent on backupCh wrapped in models.ErrPromiseOverflow.
+// An error that cannot be sent on backupCh because it is full is dropped, which is counted and logged.
 func (n Notifications) SendPromise(e error, backupCh chan error) {
 	if n.promise == nil {
 		if e == nil {
@@ -136,19 +298,85 @@ func (n Notifications) SendPromise(e error, backupCh chan error) {
 			select {
 			case backupCh <- e:
 			default:
+				n.dropped(e)
 			}
 		}
 		return
 	}
 	select {
 	case n.promise <- e:
+		return
 	default:
-		slog.Default().Error("Bug: had a Notification promise, but it blocked")
 	}
+
+	err := models.ErrPromiseOverflow
+	if e != nil {
+		err = fmt.Errorf("%w: %w", models.ErrPromiseOverflow, e)
+	}
+	slog.Default().Error("could not deliver Notification result on its promise", "error", err.Error())
+	if backupCh != nil {
+		select {
+		case backupCh <- err:
+		default:
+			n.dropped(err)
+		}
+	}
+}
+
+// dropLogInterval is the least time between logs of errors dropped because the errors channel is full.
+const dropLogInterval = 10 * time.Second
+
+// drops limits the logs of dropped errors. A full errors channel usually means many errors are dropped at
+// once, and a log line for each would flood the logs of a service that is already failing.
+var drops = &dropLimiter{}
+
+// dropLimiter allows one log of a dropped error per dropLogInterval and counts the drops it does not log.
+type dropLimiter struct {
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// allow returns true if a drop at now should be logged, with the number of drops not logged since the last
+// log.
+func (d *dropLimiter) allow(now time.Time) (ok bool, suppressed int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.last.IsZero() && now.Sub(d.last) < dropLogInterval {
+		d.suppressed++
+		return false, 0
+	}
+	suppressed = d.suppressed
+	d.last, d.suppressed = now, 0
+	return true, suppressed
 }
 
-// dataToJSON returns the JSON representation of the data in the notification.
-// Once this is called, the data is cached. So new data added to the Notification will not be included in the JSON.
+// dropped records that e, the result of the notification, was dropped because the errors channel was full.
+// The log names the event, so the failure can be traced without the error.
+func (n Notifications) dropped(e error) {
+	metrics.FromCtx(n.ctx).ErrorDropped(context.Background())
+	ok, suppressed := drops.allow(time.Now())
+	if !ok {
+		return
+	}
+	id, subject := eventinfo.FromCtx(n.ctx).Get()
+	resourceID := ""
+	if len(n.Data) > 0 {
+		resourceID = n.Data[0].ResourceID
+	}
+	slog.Default().Error(
+		"dropped Notification error because the errors channel is full",
+		"eventID", id,
+		"subject", subject,
+		"resourceID", resourceID,
+		"items", n.DataCount(),
+		"suppressed", suppressed,
+		"error", e.Error(),
+	)
+}
+
+// dataToJSON returns the JSON representation of the data in the notification. Nothing is cached, each call
+// serializes the data as it is at the time of the call.
 func (n Notifications) dataToJSON() ([]byte, error) {
 	b, err := json.Marshal(n.Data)
 	if err != nil {
@@ -159,56 +387,390 @@ func (n Notifications) dataToJSON() ([]byte, error) {
 
 // SendEvent converts the notification to an event and sends it to the ARN service.
 // Do not call this function directly, use methods on the Client instead.
-func (n Notifications) SendEvent(hc *http.Client, store *storage.Client) (err error) {
+func (n Notifications) SendEvent(hc models.EventSender, store models.PayloadStore) (err error) {
 	started := time.Now()
 	// keep track so we can record whether the data was inlined or not (receiver or blob)
 	inline := false
 	var dataSize int64
 	defer func() {
 		elapsed := time.Since(started)
+		eventinfo.FromCtx(n.ctx).SetPayload(dataSize, inline)
 		if err != nil {
-			metrics.SendEventFailure(context.Background(), elapsed, inline, dataSize)
+			metrics.FromCtx(n.ctx).SendEventFailure(context.Background(), elapsed, inline, dataSize)
 			return
 		}
-		metrics.SendEventSuccess(context.Background(), elapsed, inline, dataSize)
+		metrics.FromCtx(n.ctx).SendEventSuccess(context.Background(), elapsed, inline, dataSize)
+		stats.Payload(n.ctx, inline, dataSize)
 	}()
 
-	if len(n.Data) == 0 {
+	if len(n.Data) == 0 && n.Stream == nil {
 		return errors.New("no data to send")
 	}
 
+	// A resend sends the event the first attempt prepared, so the receiver gets the same event ID and blob.
+	if pe, ok := prepared.Get(n.ctx).(preparedEvent); ok {
+		inline, dataSize = pe.inline, pe.size
+		return n.sendPrepared(hc, store, pe)
+	}
+
+	if b, ok := skew.FromCtx(n.ctx); ok {
+		local := nower().UTC()
+		// The receiver's clock is used as the current time when it is known, so that a drifting local
+		// clock is caught.
+		now := local
+		if c, ok := hc.(clock); ok {
+			if offset, ok := c.ClockOffset(); ok {
+				now = local.Add(offset)
+			}
+		}
+		n, err = n.checkSkew(b, local, now)
+		if err != nil {
+			return invalid(err)
+		}
+	}
+
+	// n is a copy, so the stamped Others do not reach the caller's notification.
+	if p, ok := provenance.FromCtx(n.ctx); ok {
+		n.AdditionalBatchProperties.Others = p.Stamp(n.AdditionalBatchProperties.Others)
+	}
+	if id, ok := txn.FromCtx(n.ctx); ok {
+		switch n.AdditionalBatchProperties.BatchCorrelationID {
+		case "", id:
+			n.AdditionalBatchProperties.BatchCorrelationID = id
+		default:
+			return invalid(fmt.Errorf("AdditionalBatchProperties.BatchCorrelationID(%s) is not the correlation ID of its group(%s)", n.AdditionalBatchProperties.BatchCorrelationID, id))
+		}
+	}
+
+	if n.Stream != nil {
+		dataSize = n.Stream.Size
+		return n.sendStream(hc, store)
+	}
+
+	if p, ok := classify.FromCtx(n.ctx); ok {
+		n, err = n.classify(p)
+		if err != nil {
+			return invalid(err)
+		}
+	}
+	// This is after classify, so the properties are checked as they are sent.
+	if l, ok := proplimits.FromCtx(n.ctx); ok {
+		if err = n.checkProperties(l); err != nil {
+			return invalid(err)
+		}
+	}
+
 	// Convert the notification to an event.
-	dataJSON, event, err := n.toEvent()
+	var dataJSON []byte
+	var event envelope.Event
+	n.stage("arn.marshal", func() { dataJSON, event, err = n.toEvent() })
 	if err != nil {
 		return err
 	}
+	tracing.SetEvent(n.ctx, event.EventMeta.ID, event.EventMeta.Subject)
+	eventinfo.FromCtx(n.ctx).Set(event.EventMeta.ID, event.EventMeta.Subject)
+	if max, ok := itemsize.FromCtx(n.ctx); ok {
+		if err = n.checkResourceSizes(dataJSON, max); err != nil {
+			return invalid(err)
+		}
+	}
 
 	// As a producer, we have to set the status code for all Resources to OK.
 	for i, e := range event.Data.Resources {
 		e.StatusCode = types.StatusCode
 		event.Data.Resources[i] = e
 	}
-	if err = event.Validate(); err != nil {
-		return err
+	if err = event.ValidatePreview(n.previewVersions()); err != nil {
+		return invalid(err)
 	}
 
 	dataSize = int64(len(event.Data.Data))
 
+	// A blob canary is sent through blob storage even though it fits inline. If the upload fails, it is
+	// sent inline below.
+	if event.Data.ResourcesContainer == types.RCInline && canary.Blob(n.ctx) && store != nil {
+		var sent bool
+		sent, err = n.sendBlobCanary(hc, store, event, dataJSON)
+		if sent {
+			return err
+		}
+	}
+
 	// If the data is marked inline, we can send over HTTP directly.
 	if event.Data.ResourcesContainer == types.RCInline {
 		inline = true
-		return n.sendHTTP(hc, event)
+		pe := preparedEvent{event: event, inline: true, size: dataSize}
+		prepared.Set(n.ctx, pe)
+		return n.sendPrepared(hc, store, pe)
 	}
 
-	u, err := n.sendBlob(store, dataJSON)
+	blob, err := n.seal(&event, dataJSON)
 	if err != nil {
 		return err
 	}
+	pe := preparedEvent{event: event, blob: blob, size: dataSize}
+	prepared.Set(n.ctx, pe)
+	return n.sendPrepared(hc, store, pe)
+}
+
+// preparedEvent is an event that is ready to send, kept with prepared.Set() so that a resend sends it again.
+type preparedEvent struct {
+	event envelope.Event
+	// blob is the payload to upload to blob storage before the event is sent. It is nil once the payload is
+	// uploaded, when event has the blob's URI, or if the event is inline.
+	blob   []byte
+	inline bool
+	// size is the size of the event's resources, see eventinfo.Info.SetPayload().
+	size int64
+}
+
+// sendPrepared uploads the blob of pe, if it was not uploaded yet, and sends pe's event to the ARN service.
+func (n Notifications) sendPrepared(hc models.EventSender, store models.PayloadStore, pe preparedEvent) error {
+	var err error
+	if pe.blob != nil {
+		watchdog.SetStage(n.ctx, watchdog.UploadingBlob)
+		var u *url.URL
+		n.stage("arn.uploadBlob", func() { u, err = n.sendBlob(store, pe.blob) })
+		if err != nil {
+			if n.ctx != nil && n.ctx.Err() != nil {
+				stats.BlobAborted(n.ctx)
+			}
+			return err
+		}
+
+		// Tell the service (via HTTP) where to find the blob.
+		pe.event.Data.ResourcesBlobInfo.BlobURI = u.String()
+		pe.event.Data.ResourcesBlobInfo.BlobSize = int64(len(pe.blob))
+		pe.blob = nil
+		prepared.Set(n.ctx, pe)
+	}
+	watchdog.SetStage(n.ctx, watchdog.AwaitingHTTP)
+	n.stage("arn.sendHTTP", func() { err = n.sendHTTP(hc, pe.event) })
+	return err
+}
+
+// clock is implemented by an EventSender that knows the offset of the receiver's clock, such as the SDK's
+// HTTP client.
+type clock interface {
+	ClockOffset() (time.Duration, bool)
+}
+
+// checkSkew checks the EventTime, which is local, and the ResourceEventTime of each resource against b around
+// now. It returns a copy of n with any times that were clamped, the caller's Data is not changed. For
+// skew.Clamp, the EventTime is corrected to now rather than clamped. It only returns an error for skew.Reject.
+func (n Notifications) checkSkew(b skew.Bounds, local, now time.Time) (Notifications, error) {
+	var errs []error
+	// The EventTime is set from the local clock, so it is as far off as the local clock is.
+	if _, err := b.Check(now, local); err != nil {
+		errs = append(errs, fmt.Errorf("EventTime from the local clock: %w", err))
+		if b.Action == skew.Clamp {
+			n.eventTime = now
+		}
+	}
+
+	var first error
+	outside := 0
+	cloned := false
+	for i, r := range n.Data {
+		t, err := b.Check(now, r.ResourceEventTime)
+		if err == nil {
+			continue
+		}
+		outside++
+		if first == nil {
+			first = fmt.Errorf("Data[%d].ResourceEventTime: %w", i, err)
+		}
+		if b.Action == skew.Clamp {
+			if !cloned {
+				n.Data = slices.Clone(n.Data)
+				cloned = true
+			}
+			n.Data[i].ResourceEventTime = t
+		}
+	}
+	if first != nil {
+		errs = append(errs, fmt.Errorf("%d of %d resources are outside the time skew bounds, first: %w", outside, len(n.Data), first))
+	}
+
+	if len(errs) == 0 {
+		return n, nil
+	}
+	err := errors.Join(errs...)
+	if b.Action == skew.Reject {
+		return n, err
+	}
+	slog.Default().Warn("notification times are outside the time skew bounds", "action", b.Action.String(), "error", err.Error())
+	return n, nil
+}
+
+// classify returns a copy of n with p enforced on the properties of each resource. The caller's Data is not
+// changed.
+func (n Notifications) classify(p classify.Policy) (Notifications, error) {
+	copied := false
+	for i, r := range n.Data {
+		props, redacted, err := p.Apply(r.ArmResource.Properties, n.DataBoundary)
+		if err != nil {
+			return n, fmt.Errorf("Data[%d].ArmResource.Properties: %w", i, err)
+		}
+		if !redacted {
+			continue
+		}
+		if !copied {
+			n.Data = slices.Clone(n.Data)
+			copied = true
+		}
+		n.Data[i].ArmResource.Properties = props
+	}
+	return n, nil
+}
+
+// checkProperties checks the properties of each resource against l, see types.CheckProperties().
+func (n Notifications) checkProperties(l types.PropertyLimits) error {
+	for i, r := range n.Data {
+		if err := types.CheckProperties(r.ArmResource.Properties, l); err != nil {
+			return fmt.Errorf("Data[%d](%s).ArmResource.Properties: %w", i, r.ResourceID, err)
+		}
+	}
+	return nil
+}
+
+// checkResourceSizes returns an error naming the first resource in dataJSON, the JSON of n.Data, that is larger
+// than max bytes. The sizes are read from dataJSON, so the resources are not marshaled again.
+func (n Notifications) checkResourceSizes(dataJSON []byte, max int) error {
+	dec := jsontext.NewDecoder(bytes.NewReader(dataJSON))
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("could not read the resources JSON: %w", err)
+	}
+	for i := range n.Data {
+		start := dec.InputOffset()
+		if err := dec.SkipValue(); err != nil {
+			return fmt.Errorf("could not read the resources JSON: %w", err)
+		}
+		// The offset before a value is before the comma and whitespace that come ahead of it.
+		size := len(bytes.TrimLeft(dataJSON[start:dec.InputOffset()], ", \t\r\n"))
+		if size > max {
+			return fmt.Errorf(
+				"Data[%d](%s): is %d bytes as JSON, more than the limit of %d bytes for a resource; trim its largest fields, such as tags or labels in ArmResource.Properties, or raise the limit",
+				i, n.Data[i].ResourceID, size, max,
+			)
+		}
+	}
+	return nil
+}
+
+// seal returns dataJSON encrypted for blob storage with the encrypt.KeyWrapper in n.ctx, and records the
+// encryption in the AdditionalBatchProperties of event. It returns dataJSON as is if there is no KeyWrapper.
+func (n Notifications) seal(event *envelope.Event, dataJSON []byte) ([]byte, error) {
+	w, ok := encrypt.FromCtx(n.ctx)
+	if !ok {
+		return dataJSON, nil
+	}
+	var blob []byte
+	var others map[string]any
+	var err error
+	n.stage("arn.seal", func() {
+		blob, others, err = encrypt.Seal(n.ctx, w, dataJSON, event.Data.AdditionalBatchProperties.Others)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt the blob payload: %w", err)
+	}
+	event.Data.AdditionalBatchProperties.Others = others
+	return blob, nil
+}
+
+// dataBoundary returns the DataBoundary as it is sent in Data.DataBoundary, "" if it is not set.
+func (n Notifications) dataBoundary() string {
+	return strings.Trim(n.DataBoundary.String(), `"`)
+}
+
+// sendBlobCanary sends event, which fits inline, through blob storage to verify that the blob path works.
+// It returns false if the upload failed and the event was not sent, so that the caller can send it inline.
+// The result is recorded with stats.BlobCanary() and metrics.BlobCanary().
+func (n Notifications) sendBlobCanary(hc models.EventSender, store models.PayloadStore, event envelope.Event, dataJSON []byte) (sent bool, err error) {
+	watchdog.SetStage(n.ctx, watchdog.UploadingBlob)
+	var u *url.URL
+	// A key that cannot be wrapped breaks the blob path as much as a failed upload does.
+	blob, err := n.seal(&event, dataJSON)
+	if err == nil {
+		n.stage("arn.uploadBlob", func() { u, err = n.sendBlob(store, blob) })
+	}
+	if err != nil {
+		slog.Default().Warn("blob canary could not upload to blob storage, sending inline", "error", err.Error())
+		stats.BlobCanary(n.ctx, err)
+		metrics.FromCtx(n.ctx).BlobCanary(context.Background(), false)
+		return false, nil
+	}
 
-	// Tell the service (via HTTP) where to find the blob.
+	event.Data.Data = nil
+	event.Data.ResourcesContainer = types.RCBlob
 	event.Data.ResourcesBlobInfo.BlobURI = u.String()
-	event.Data.ResourcesBlobInfo.BlobSize = int64(len(dataJSON))
-	return n.sendHTTP(hc, event)
+	event.Data.ResourcesBlobInfo.BlobSize = int64(len(blob))
+	prepared.Set(n.ctx, preparedEvent{event: event, size: int64(len(dataJSON))})
+	watchdog.SetStage(n.ctx, watchdog.AwaitingHTTP)
+	n.stage("arn.sendHTTP", func() { err = n.sendHTTP(hc, event) })
+	stats.BlobCanary(n.ctx, err)
+	metrics.FromCtx(n.ctx).BlobCanary(context.Background(), err == nil)
+	return true, err
+}
+
+// previewVersions returns the receiver preview versions the event may use, none if the client allows none.
+func (n Notifications) previewVersions() envelope.PreviewVersions {
+	p, _ := preview.FromCtx(n.ctx)
+	return p
+}
+
+// invalid returns err, the reason a notification is not valid, wrapped with models.ErrValidation.
+func invalid(err error) error {
+	return fmt.Errorf("%w: %w", models.ErrValidation, err)
+}
+
+// EventJSON returns the JSON of the event that SendEvent() would send to the ARN receiver. This is for tools
+// that need to send or inspect the event outside of a client, like cmd/arn-drift. The resources must fit
+// inline, as no blob is uploaded.
+func (n Notifications) EventJSON() ([]byte, error) {
+	if len(n.Data) == 0 {
+		return nil, errors.New("no data to send")
+	}
+	_, event, err := n.toEvent()
+	if err != nil {
+		return nil, err
+	}
+	if event.Data.ResourcesContainer != types.RCInline {
+		return nil, errors.New("resources are too large to send inline")
+	}
+	for i, e := range event.Data.Resources {
+		e.StatusCode = types.StatusCode
+		event.Data.Resources[i] = e
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(event)
+}
+
+// timingStages are the timing stages that the time spent in each stage() is recorded to.
+var timingStages = map[string]string{
+	"arn.marshal":    timing.StageMarshal,
+	"arn.seal":       timing.StageEncrypt,
+	"arn.uploadBlob": timing.StageBlob,
+	"arn.sendHTTP":   timing.StageHTTP,
+}
+
+// stage runs fn with pprof labels and inside a runtime/trace region named name. This lets
+// profiles and traces attribute time to each stage of a send. The time is also recorded to
+// the timing.Timeline in the context, if there is one, and as an OpenTelemetry span if the
+// notification is traced.
+func (n Notifications) stage(name string, fn func()) {
+	ctx := n.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	defer tracing.Stage(ctx, name)()
+	start := time.Now()
+	pprof.Do(ctx, pprof.Labels(build.PprofLabel, name), func(ctx context.Context) {
+		trace.WithRegion(ctx, name, fn)
+	})
+	timing.Record(ctx, timingStages[name], time.Since(start))
 }
 
 // toEvent converts the notification to an event. If the data is inline, the data will be included in the event.
@@ -223,6 +785,21 @@ func (n Notifications) toEvent() ([]byte, envelope.Event, error) {
 	if err != nil {
 		return dataJSON, envelope.Event{}, fmt.Errorf("problem creating an EventMeta: %w", err)
 	}
+	if n.Subject != "" {
+		if err := checkSubject(n.Subject, n.Data); err != nil {
+			return dataJSON, envelope.Event{}, invalid(err)
+		}
+		meta.Subject = n.Subject
+	}
+	if !n.eventTime.IsZero() {
+		meta.EventTime = n.eventTime
+	}
+	if n.MetadataVersion != "" {
+		meta.MetadataVersion = n.MetadataVersion
+	}
+	if n.DataVersion != "" {
+		meta.DataVersion = n.DataVersion
+	}
 
 	if len(n.Data) > math.MaxUint16 {
 		return dataJSON, envelope.Event{}, fmt.Errorf("too many resources to send in a single event: %d", len(n.Data))
@@ -241,6 +818,7 @@ func (n Notifications) toEvent() ([]byte, envelope.Event, error) {
 				ResourcesContainer:        types.RCInline,
 				ResourceLocation:          n.ResourceLocation,
 				PublisherInfo:             n.PublisherInfo,
+				DataBoundary:              n.dataBoundary(),
 				Resources:                 n.Data, // This doesn't serialize into JSON, only the "Data" field does, which actually replaces this field.
 			},
 		}, nil
@@ -254,6 +832,7 @@ func (n Notifications) toEvent() ([]byte, envelope.Event, error) {
 			ResourcesContainer:        types.RCBlob,
 			ResourceLocation:          n.ResourceLocation,
 			PublisherInfo:             n.PublisherInfo,
+			DataBoundary:              n.dataBoundary(),
 			Resources:                 n.Data, // This doesn't serialize into JSON, only the "Data" field does, which actually replaces this field.
 		},
 	}, nil
@@ -265,7 +844,7 @@ var headerPool = sync.Pool{
 	},
 }
 
-func (n Notifications) sendHTTP(hc *http.Client, event envelope.Event) error {
+func (n Notifications) sendHTTP(hc models.EventSender, event envelope.Event) error {
 	if n.testSendHTTP != nil {
 		return n.testSendHTTP(hc, event)
 	}
@@ -280,19 +859,25 @@ func (n Notifications) sendHTTP(hc *http.Client, event envelope.Event) error {
 	headers[1] = event.Data.PublisherInfo
 	defer headerPool.Put(headers)
 
+	progress.Report(n.ctx)
 	return hc.Send(n.ctx, b, headers)
 }
 
-func (n Notifications) sendBlob(store *storage.Client, dataJSON []byte) (*url.URL, error) {
+func (n Notifications) sendBlob(store models.PayloadStore, dataJSON []byte) (*url.URL, error) {
 	if n.testSendBlob != nil {
 		return n.testSendBlob(store, dataJSON)
 	}
 
 	// If store isn't set then this message is too large to send.
 	if store == nil {
-		return nil, fmt.Errorf("event exceeds max inline size and no storage client provided to store the data in a blob")
+		report, err := n.Explain(explainTop)
+		if err != nil {
+			return nil, models.ErrNoBlobClient
+		}
+		return nil, &SizeError{Report: report, Err: models.ErrNoBlobClient}
 	}
 
+	progress.Report(n.ctx)
 	return store.Upload(n.ctx, uuid.New().String(), dataJSON)
 }
 
@@ -322,7 +907,7 @@ func newEventMeta(data []types.NotificationResource) (envelope.EventMeta, error)
 		ID:              uuid.New().String(),
 		Subject:         subject(data),
 		DataVersion:     version.V3,
-		MetadataVersion: "1.0",
+		MetadataVersion: envelope.MetadataVersion,
 		EventTime:       nower().UTC(),
 		EventType:       fmt.Sprintf("%s/%s", data[0].ArmResource.Type, data[0].ArmResource.Activity().String()),
 	}, nil
@@ -3,13 +3,27 @@ package msgs
 import (
 	"context"
 	"errors"
+	"maps"
+	"math"
 	"net/url"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/internal/conn/canary"
+	"github.com/Azure/arn-sdk/internal/conn/classify"
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/internal/conn/eventinfo"
+	"github.com/Azure/arn-sdk/internal/conn/itemsize"
+	"github.com/Azure/arn-sdk/internal/conn/prepared"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/internal/conn/proplimits"
+	"github.com/Azure/arn-sdk/internal/conn/provenance"
+	"github.com/Azure/arn-sdk/internal/conn/skew"
+	"github.com/Azure/arn-sdk/internal/conn/stats"
 	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/models"
 	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
 	"github.com/Azure/arn-sdk/models/v3/schema/types"
 	"github.com/Azure/arn-sdk/models/version"
@@ -33,12 +47,21 @@ func TestPromise(t *testing.T) {
 	cancelCtx, cancel := context.WithCancel(context.Background())
 	cancel()
 
+	deadlineCtx, cancel := context.WithTimeout(context.Background(), -1)
+	defer cancel()
+
+	sendCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
 	tests := []struct {
 		name        string
 		ctx         context.Context
+		sendCtx     context.Context
 		promise     chan error
 		prommiseErr bool
+		noResult    bool
 		wantErr     bool
+		wantIs      error
 	}{
 		{
 			name: "Promise is nil",
@@ -49,6 +72,23 @@ func TestPromise(t *testing.T) {
 			ctx:     cancelCtx,
 			promise: make(chan error, 1),
 			wantErr: true,
+			wantIs:  models.ErrPromiseCanceled,
+		},
+		{
+			name:    "Error: context deadline exceeded",
+			ctx:     deadlineCtx,
+			promise: make(chan error, 1),
+			wantErr: true,
+			wantIs:  models.ErrPromiseTimeout,
+		},
+		{
+			name:     "Error: deadline inherited from the notification context",
+			ctx:      context.Background(),
+			sendCtx:  sendCtx,
+			promise:  make(chan error, 1),
+			noResult: true,
+			wantErr:  true,
+			wantIs:   models.ErrPromiseTimeout,
 		},
 		{
 			name:        "Error: promise error",
@@ -67,9 +107,10 @@ func TestPromise(t *testing.T) {
 
 	for _, test := range tests {
 		n := Notifications{
+			ctx:     test.sendCtx,
 			promise: test.promise,
 		}
-		if n.promise != nil {
+		if n.promise != nil && !test.noResult {
 			if test.prommiseErr {
 				n.promise <- errors.New("promise error")
 			} else {
@@ -86,6 +127,94 @@ func TestPromise(t *testing.T) {
 			t.Errorf("TestPromise(%s): got err == %s, want err == nil", test.name, err)
 			continue
 		}
+		if test.wantIs != nil && !errors.Is(err, test.wantIs) {
+			t.Errorf("TestPromise(%s): got err == %s, want errors.Is(err, %s)", test.name, err, test.wantIs)
+		}
+		if test.prommiseErr && (errors.Is(err, models.ErrPromiseTimeout) || errors.Is(err, models.ErrPromiseCanceled)) {
+			t.Errorf("TestPromise(%s): send error %s should not be a wait error", test.name, err)
+		}
+	}
+}
+
+func TestPromiseExtension(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		ext  progress.Extension
+		// cancel cancels the wait instead of letting its deadline pass.
+		cancel bool
+		// progressFor is how long progress is reported for.
+		progressFor time.Duration
+		// resultAfter is when the result is sent, 0 to never send it.
+		resultAfter time.Duration
+		wantIs      error
+	}{
+		{
+			name:        "Result while progressing",
+			ext:         progress.Extension{Idle: 500 * time.Millisecond, Max: 5 * time.Second},
+			progressFor: 100 * time.Millisecond,
+			resultAfter: 100 * time.Millisecond,
+		},
+		{
+			name:   "Error: no progress",
+			ext:    progress.Extension{Idle: 20 * time.Millisecond, Max: 5 * time.Second},
+			wantIs: models.ErrPromiseTimeout,
+		},
+		{
+			name:        "Error: max extension",
+			ext:         progress.Extension{Idle: 50 * time.Millisecond, Max: 50 * time.Millisecond},
+			progressFor: 5 * time.Second,
+			wantIs:      models.ErrPromiseTimeout,
+		},
+		{
+			name:        "Error: cancelled is not extended",
+			ext:         progress.Extension{Idle: 500 * time.Millisecond, Max: 5 * time.Second},
+			cancel:      true,
+			progressFor: 100 * time.Millisecond,
+			resultAfter: 50 * time.Millisecond,
+			wantIs:      models.ErrPromiseCanceled,
+		},
+	}
+
+	for _, test := range tests {
+		tr := progress.New(test.ext)
+		n := Notifications{
+			ctx:     progress.WithTracker(context.Background(), tr),
+			promise: make(chan error, 1),
+		}
+
+		done := make(chan struct{})
+		go func() {
+			start := time.Now()
+			for time.Since(start) < test.progressFor {
+				select {
+				case <-done:
+					return
+				case <-time.After(5 * time.Millisecond):
+				}
+				tr.Report()
+				if test.resultAfter > 0 && time.Since(start) >= test.resultAfter {
+					n.promise <- nil
+					return
+				}
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if test.cancel {
+			cancel()
+		}
+		err := n.Promise(ctx)
+		cancel()
+		close(done)
+
+		switch {
+		case test.wantIs == nil && err != nil:
+			t.Errorf("TestPromiseExtension(%s): got err == %s, want err == nil", test.name, err)
+		case test.wantIs != nil && !errors.Is(err, test.wantIs):
+			t.Errorf("TestPromiseExtension(%s): got err == %v, want errors.Is(err, %s)", test.name, err, test.wantIs)
+		}
 	}
 }
 
@@ -99,6 +228,81 @@ func TestDataCount(t *testing.T) {
 	}
 }
 
+func TestDataSizeHint(t *testing.T) {
+	t.Parallel()
+
+	n := Notifications{Data: []types.NotificationResource{{}}}
+	if got, want := n.DataSizeHint(), len(`[{"resourceId":""}]`); got != want {
+		t.Errorf("TestDataSizeHint: got %d, want %d", got, want)
+	}
+
+	n = Notifications{Data: []types.NotificationResource{{ArmResource: types.ArmResource{Properties: make(chan int)}}}}
+	if got := n.DataSizeHint(); got != -1 {
+		t.Errorf("TestDataSizeHint(unserializable): got %d, want -1", got)
+	}
+}
+
+func TestResources(t *testing.T) {
+	t.Parallel()
+
+	n := Notifications{
+		Data: []types.NotificationResource{
+			{ResourceID: "a", APIVersion: "2024-01-01", ResourceEventTime: expectedNow},
+			{ResourceID: "b"},
+			{ResourceID: "c"},
+		},
+	}
+
+	var got []models.Resource
+	for r := range n.Resources() {
+		got = append(got, r)
+		if r.ID == "b" {
+			break
+		}
+	}
+	want := []models.Resource{
+		{ID: "a", APIVersion: "2024-01-01", EventTime: expectedNow},
+		{ID: "b"},
+	}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestResources: -want/+got:\n%s", diff)
+	}
+}
+
+func TestPayloadHashes(t *testing.T) {
+	t.Parallel()
+
+	n := Notifications{
+		Data: []types.NotificationResource{
+			{ResourceID: "a", APIVersion: "2024-01-01", ResourceEventTime: expectedNow},
+			{ResourceID: "a", APIVersion: "2024-01-01", ResourceEventTime: expectedNow.Add(time.Hour)},
+			{ResourceID: "a", APIVersion: "2024-02-01", ResourceEventTime: expectedNow},
+		},
+	}
+
+	var ids []string
+	var hashes []models.PayloadHash
+	for id, h := range n.PayloadHashes() {
+		ids = append(ids, id)
+		hashes = append(hashes, h)
+	}
+	if diff := pretty.Compare([]string{"a", "a", "a"}, ids); diff != "" {
+		t.Errorf("TestPayloadHashes: IDs -want/+got:\n%s", diff)
+	}
+	if len(hashes) != 3 {
+		t.Fatalf("TestPayloadHashes: got %d hashes, want 3", len(hashes))
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("TestPayloadHashes: the event time changed the hash: %s != %s", hashes[0], hashes[1])
+	}
+	if hashes[0] == hashes[2] {
+		t.Errorf("TestPayloadHashes: a different resource has the same hash %s", hashes[0])
+	}
+	if !n.Data[0].ResourceEventTime.Equal(expectedNow) {
+		t.Errorf("TestPayloadHashes: PayloadHashes() changed the ResourceEventTime of the notification")
+	}
+}
+
 func TestVersion(t *testing.T) {
 	t.Parallel()
 
@@ -120,6 +324,51 @@ func TestCtx(t *testing.T) {
 	}
 }
 
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	n := Notifications{
+		ctx:     context.Background(),
+		promise: make(chan error, 1),
+		AdditionalBatchProperties: types.AdditionalBatchProperties{
+			Others: map[string]any{"tag": []any{"a"}},
+		},
+		Data: []types.NotificationResource{
+			{
+				ResourceID:                   "id",
+				AdditionalResourceProperties: map[string]string{"key": "value"},
+				ArmResource:                  types.ArmResource{ID: "id", Properties: map[string]any{"status": "ok"}},
+			},
+		},
+	}
+
+	clone, err := n.Clone()
+	if err != nil {
+		t.Fatalf("TestClone: got err == %s, want err == nil", err)
+	}
+	if clone.promise != nil {
+		t.Errorf("TestClone: got promise, want nil")
+	}
+	if clone.Ctx() != n.Ctx() {
+		t.Errorf("TestClone: got different ctx, want same ctx")
+	}
+
+	n.AdditionalBatchProperties.Others["tag"].([]any)[0] = "changed"
+	n.Data[0].AdditionalResourceProperties["key"] = "changed"
+	n.Data[0].ArmResource.Properties.(map[string]any)["status"] = "changed"
+	n.Data[0] = types.NotificationResource{}
+
+	if got := clone.AdditionalBatchProperties.Others["tag"].([]any)[0]; got != "a" {
+		t.Errorf("TestClone: got AdditionalBatchProperties.Others[tag][0] == %v, want a", got)
+	}
+	if got := clone.Data[0].AdditionalResourceProperties["key"]; got != "value" {
+		t.Errorf("TestClone: got Data[0].AdditionalResourceProperties[key] == %v, want value", got)
+	}
+	if got := clone.Data[0].ArmResource.Properties.(map[string]any)["status"]; got != "ok" {
+		t.Errorf("TestClone: got Data[0].ArmResource.Properties[status] == %v, want ok", got)
+	}
+}
+
 func TestSetPromise(t *testing.T) {
 	t.Parallel()
 
@@ -183,6 +432,94 @@ func TestSendPromise(t *testing.T) {
 	}
 }
 
+// TestSendPromiseOverflow reproduces two notifications sharing a promise, which happened when a promise was
+// returned to the pool twice. The second result used to be dropped.
+func TestSendPromiseOverflow(t *testing.T) {
+	t.Parallel()
+
+	shared := make(chan error, 1)
+	backup := make(chan error, 1)
+
+	first := Notifications{promise: shared}
+	second := Notifications{promise: shared}
+
+	firstErr := errors.New("first")
+	secondErr := errors.New("second")
+
+	first.SendPromise(firstErr, backup)
+	second.SendPromise(secondErr, backup)
+
+	if got := <-shared; got != firstErr {
+		t.Errorf("TestSendPromiseOverflow: got promise result %v, want %v", got, firstErr)
+	}
+
+	select {
+	case got := <-backup:
+		if !errors.Is(got, models.ErrPromiseOverflow) {
+			t.Errorf("TestSendPromiseOverflow: got backup error %v, want errors.Is(err, models.ErrPromiseOverflow)", got)
+		}
+		if !errors.Is(got, secondErr) {
+			t.Errorf("TestSendPromiseOverflow: got backup error %v, want errors.Is(err, %v)", got, secondErr)
+		}
+	default:
+		t.Errorf("TestSendPromiseOverflow: second result was lost")
+	}
+}
+
+// TestSendPromiseFull tests that an error is dropped, instead of blocking, when the errors channel is full.
+func TestSendPromiseFull(t *testing.T) {
+	t.Parallel()
+
+	backup := make(chan error, 1)
+	first := errors.New("first")
+	backup <- first
+
+	ctx := eventinfo.With(context.Background())
+	eventinfo.FromCtx(ctx).Set("id", "subject")
+	n := Notifications{ctx: ctx, Data: []types.NotificationResource{{ResourceID: "/subscriptions/sub"}}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.SendPromise(errors.New("second"), backup)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestSendPromiseFull: SendPromise() blocked on a full errors channel")
+	}
+	if got := <-backup; got != first {
+		t.Errorf("TestSendPromiseFull: got %v, want %v", got, first)
+	}
+}
+
+func TestDropLimiter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	d := &dropLimiter{}
+
+	steps := []struct {
+		name           string
+		at             time.Duration
+		wantOK         bool
+		wantSuppressed int
+	}{
+		{name: "first drop", wantOK: true},
+		{name: "within the interval", at: time.Second},
+		{name: "still within the interval", at: dropLogInterval - time.Second},
+		{name: "after the interval", at: dropLogInterval, wantOK: true, wantSuppressed: 2},
+		{name: "after the next interval", at: 3 * dropLogInterval, wantOK: true},
+	}
+
+	for _, step := range steps {
+		ok, suppressed := d.allow(start.Add(step.at))
+		if ok != step.wantOK || suppressed != step.wantSuppressed {
+			t.Errorf("TestDropLimiter(%s): got %v, %d, want %v, %d", step.name, ok, suppressed, step.wantOK, step.wantSuppressed)
+		}
+	}
+}
+
 func TestSendEvent(t *testing.T) {
 	t.Parallel()
 
@@ -232,7 +569,7 @@ func TestSendEvent(t *testing.T) {
 			name: "Error: inline HTTP call fails",
 			n: Notifications{
 				Data: []types.NotificationResource{goodNotifyResrc},
-				testSendHTTP: func(*http.Client, envelope.Event) error {
+				testSendHTTP: func(models.EventSender, envelope.Event) error {
 					httpCalled = true
 					return errors.New("http error")
 				},
@@ -244,7 +581,7 @@ func TestSendEvent(t *testing.T) {
 			name: "Success: Inline",
 			n: Notifications{
 				Data: []types.NotificationResource{goodNotifyResrc},
-				testSendHTTP: func(*http.Client, envelope.Event) error {
+				testSendHTTP: func(models.EventSender, envelope.Event) error {
 					httpCalled = true
 					return nil
 				},
@@ -255,11 +592,11 @@ func TestSendEvent(t *testing.T) {
 			name: "Error: Blob upload fails",
 			n: Notifications{
 				Data: blobNotificationResrcs,
-				testSendHTTP: func(*http.Client, envelope.Event) error {
+				testSendHTTP: func(models.EventSender, envelope.Event) error {
 					httpCalled = true
 					return nil
 				},
-				testSendBlob: func(*storage.Client, []byte) (*url.URL, error) {
+				testSendBlob: func(models.PayloadStore, []byte) (*url.URL, error) {
 					blobCalled = true
 					return nil, errors.New("blob error")
 				},
@@ -270,11 +607,11 @@ func TestSendEvent(t *testing.T) {
 			name: "Error: Blob succeeds but HTTP fails",
 			n: Notifications{
 				Data: blobNotificationResrcs,
-				testSendHTTP: func(*http.Client, envelope.Event) error {
+				testSendHTTP: func(models.EventSender, envelope.Event) error {
 					httpCalled = true
 					return errors.New("http error")
 				},
-				testSendBlob: func(*storage.Client, []byte) (*url.URL, error) {
+				testSendBlob: func(models.PayloadStore, []byte) (*url.URL, error) {
 					blobCalled = true
 					u, _ := url.Parse("https://blob")
 					return u, nil
@@ -286,11 +623,11 @@ func TestSendEvent(t *testing.T) {
 			name: "Success: Blob",
 			n: Notifications{
 				Data: blobNotificationResrcs,
-				testSendHTTP: func(*http.Client, envelope.Event) error {
+				testSendHTTP: func(models.EventSender, envelope.Event) error {
 					httpCalled = true
 					return nil
 				},
-				testSendBlob: func(*storage.Client, []byte) (*url.URL, error) {
+				testSendBlob: func(models.PayloadStore, []byte) (*url.URL, error) {
 					blobCalled = true
 					u, _ := url.Parse("https://blob")
 					return u, nil
@@ -328,6 +665,663 @@ func TestSendEvent(t *testing.T) {
 	}
 }
 
+func TestSendBlobCanary(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something")
+	if err != nil {
+		panic(err)
+	}
+	rsc := types.NotificationResource{
+		ResourceID: uuid.New().String(),
+		APIVersion: "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			ChangeAction: types.CADelete,
+		},
+		ArmResource: mustNewArm(types.ActDelete, rescID, "2020-05-01", nil),
+	}
+	blobURL, _ := url.Parse("https://blob")
+
+	tests := []struct {
+		name          string
+		notCanary     bool
+		noStore       bool
+		blobErr       error
+		httpErr       error
+		wantContainer types.ResourcesContainer
+		wantErr       bool
+		wantCanary    bool
+		wantCanaryErr bool
+	}{
+		{name: "Not a canary", notCanary: true, wantContainer: types.RCInline},
+		{name: "No blob storage", noStore: true, wantContainer: types.RCInline},
+		{name: "Canary", wantContainer: types.RCBlob, wantCanary: true},
+		{
+			name:          "Upload fails, sent inline",
+			blobErr:       errors.New("blob error"),
+			wantContainer: types.RCInline,
+			wantCanary:    true,
+			wantCanaryErr: true,
+		},
+		{
+			name:          "Error: HTTP fails",
+			httpErr:       errors.New("http error"),
+			wantContainer: types.RCBlob,
+			wantErr:       true,
+			wantCanary:    true,
+			wantCanaryErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		c := stats.New()
+		ctx := stats.WithCollector(context.Background(), c)
+		if !test.notCanary {
+			ctx = canary.WithBlob(ctx)
+		}
+		var store models.PayloadStore = &storage.Client{}
+		if test.noStore {
+			store = nil
+		}
+
+		var got types.ResourcesContainer
+		n := Notifications{
+			ctx:  ctx,
+			Data: []types.NotificationResource{rsc},
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				got = event.Data.ResourcesContainer
+				return test.httpErr
+			},
+			testSendBlob: func(models.PayloadStore, []byte) (*url.URL, error) {
+				if test.blobErr != nil {
+					return nil, test.blobErr
+				}
+				return blobURL, nil
+			},
+		}
+
+		err := n.SendEvent(nil, store)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSendBlobCanary(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSendBlobCanary(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if got != test.wantContainer {
+			t.Errorf("TestSendBlobCanary(%s): got ResourcesContainer %v, want %v", test.name, got, test.wantContainer)
+		}
+
+		st := c.Stats()
+		if gotCanary := st.BlobCanaries == 1; gotCanary != test.wantCanary {
+			t.Errorf("TestSendBlobCanary(%s): got BlobCanaries %d, want canary %v", test.name, st.BlobCanaries, test.wantCanary)
+		}
+		if gotErr := st.LastBlobCanaryError != nil; gotErr != test.wantCanaryErr {
+			t.Errorf("TestSendBlobCanary(%s): got LastBlobCanaryError %v, want error %v", test.name, st.LastBlobCanaryError, test.wantCanaryErr)
+		}
+	}
+}
+
+// fakeWrapper is an encrypt.KeyWrapper and encrypt.KeyUnwrapper that does not change the key.
+type fakeWrapper struct {
+	err error
+}
+
+func (f fakeWrapper) WrapKey(ctx context.Context, key []byte) (encrypt.Wrapped, error) {
+	return encrypt.Wrapped{KeyID: "key/1", Alg: "none", Key: key}, f.err
+}
+
+func (f fakeWrapper) UnwrapKey(ctx context.Context, w encrypt.Wrapped) ([]byte, error) {
+	return w.Key, nil
+}
+
+func TestSendEncryption(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something")
+	if err != nil {
+		panic(err)
+	}
+	rsc := types.NotificationResource{
+		ResourceID: uuid.New().String(),
+		APIVersion: "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			ChangeAction: types.CADelete,
+		},
+		ArmResource: mustNewArm(types.ActDelete, rescID, "2020-05-01", nil),
+	}
+	// Enough resources that they do not fit inline.
+	blobData := make([]types.NotificationResource, 0, 3000)
+	for range 3000 {
+		blobData = append(blobData, rsc)
+	}
+	blobURL, _ := url.Parse("https://blob")
+
+	tests := []struct {
+		name    string
+		w       encrypt.KeyWrapper
+		wantErr bool
+	}{
+		{name: "Not encrypted"},
+		{name: "Encrypted", w: fakeWrapper{}},
+		{name: "Error: key cannot be wrapped", w: fakeWrapper{err: errors.New("denied")}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.w != nil {
+			ctx = encrypt.WithWrapper(ctx, test.w)
+		}
+		others := map[string]any{"a": "b"}
+
+		var event envelope.Event
+		var blob []byte
+		n := Notifications{
+			ctx:                       ctx,
+			Data:                      blobData,
+			AdditionalBatchProperties: types.AdditionalBatchProperties{Others: others},
+			testSendHTTP: func(_ models.EventSender, e envelope.Event) error {
+				event = e
+				return nil
+			},
+			testSendBlob: func(_ models.PayloadStore, b []byte) (*url.URL, error) {
+				blob = b
+				return blobURL, nil
+			},
+		}
+		want, err := n.dataToJSON()
+		if err != nil {
+			panic(err)
+		}
+
+		err = n.SendEvent(nil, &storage.Client{})
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSendEncryption(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSendEncryption(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if blob != nil {
+				t.Errorf("TestSendEncryption(%s): the payload was uploaded unencrypted", test.name)
+			}
+			continue
+		}
+
+		if len(others) != 1 {
+			t.Errorf("TestSendEncryption(%s): the caller's Others were changed to %v", test.name, others)
+		}
+		if event.Data.ResourcesContainer != types.RCBlob {
+			t.Fatalf("TestSendEncryption(%s): got ResourcesContainer %v, want the payload in a blob", test.name, event.Data.ResourcesContainer)
+		}
+		if event.Data.ResourcesBlobInfo.BlobSize != int64(len(blob)) {
+			t.Errorf("TestSendEncryption(%s): got BlobSize %d, want %d", test.name, event.Data.ResourcesBlobInfo.BlobSize, len(blob))
+		}
+		sentOthers := event.Data.AdditionalBatchProperties.Others
+		if test.w == nil {
+			if encrypt.IsSealed(blob) || encrypt.Encrypted(sentOthers) {
+				t.Errorf("TestSendEncryption(%s): the payload was encrypted", test.name)
+			}
+			continue
+		}
+		if sentOthers["a"] != "b" {
+			t.Errorf("TestSendEncryption(%s): got Others %v, want the caller's values kept", test.name, sentOthers)
+		}
+		got, err := encrypt.Open(context.Background(), fakeWrapper{}, sentOthers, blob)
+		if err != nil {
+			t.Errorf("TestSendEncryption(%s): encrypt.Open(): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if string(got) != string(want) {
+			t.Errorf("TestSendEncryption(%s): got decrypted payload %s, want %s", test.name, got, want)
+		}
+	}
+}
+
+func TestSendProvenance(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something")
+	if err != nil {
+		panic(err)
+	}
+	rsc := types.NotificationResource{
+		ResourceID: uuid.New().String(),
+		APIVersion: "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			ChangeAction: types.CADelete,
+		},
+		ArmResource: mustNewArm(types.ActDelete, rescID, "2020-05-01", nil),
+	}
+
+	tests := []struct {
+		name   string
+		p      *provenance.Provenance
+		others map[string]any
+		want   map[string]any
+	}{
+		{name: "Not stamped", others: map[string]any{"a": "b"}, want: map[string]any{"a": "b"}},
+		{
+			name: "Stamped",
+			p:    &provenance.Provenance{Pod: "pod", Node: "node", Build: "abc"},
+			want: map[string]any{provenance.KeyPod: "pod", provenance.KeyNode: "node", provenance.KeyBuild: "abc"},
+		},
+		{
+			name:   "Stamped with publisher values",
+			p:      &provenance.Provenance{Pod: "pod", Build: "abc"},
+			others: map[string]any{provenance.KeyPod: "mine"},
+			want:   map[string]any{provenance.KeyPod: "mine", provenance.KeyBuild: "abc"},
+		},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.p != nil {
+			ctx = provenance.WithProvenance(ctx, *test.p)
+		}
+		orig := maps.Clone(test.others)
+
+		var got map[string]any
+		n := Notifications{
+			ctx:                       ctx,
+			Data:                      []types.NotificationResource{rsc},
+			AdditionalBatchProperties: types.AdditionalBatchProperties{Others: test.others},
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				got = event.Data.AdditionalBatchProperties.Others
+				return nil
+			},
+		}
+		if err := n.SendEvent(nil, nil); err != nil {
+			t.Errorf("TestSendProvenance(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if !maps.Equal(got, test.want) {
+			t.Errorf("TestSendProvenance(%s): got Others %v, want %v", test.name, got, test.want)
+		}
+		if !maps.Equal(n.AdditionalBatchProperties.Others, orig) {
+			t.Errorf("TestSendProvenance(%s): the caller's Others were changed to %v", test.name, n.AdditionalBatchProperties.Others)
+		}
+	}
+}
+
+type classifiedProps struct {
+	Email string `json:"email,omitzero" arnclass:"euii"`
+	Team  string `json:"team"`
+}
+
+func TestSendClassification(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something")
+	if err != nil {
+		panic(err)
+	}
+	rsc := types.NotificationResource{
+		ResourceID: uuid.New().String(),
+		APIVersion: "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			ChangeAction: types.CAUpdate,
+		},
+		ArmResource: mustNewArm(types.ActWrite, rescID, "2020-05-01", classifiedProps{Email: "a@example.com", Team: "x"}),
+	}
+
+	rule := classify.Rule{Classification: "euii", Allowed: []types.DataBoundary{types.DBEU}}
+	redact := rule
+	redact.Action = classify.Redact
+
+	tests := []struct {
+		name      string
+		p         *classify.Policy
+		boundary  types.DataBoundary
+		wantProps string
+		wantErr   bool
+	}{
+		{name: "No policy", boundary: types.DBGlobal, wantProps: `{"email":"a@example.com","team":"x"}`},
+		{name: "Allowed", p: &classify.Policy{Rules: []classify.Rule{rule}}, boundary: types.DBEU, wantProps: `{"email":"a@example.com","team":"x"}`},
+		{name: "Redacted", p: &classify.Policy{Rules: []classify.Rule{redact}}, boundary: types.DBGlobal, wantProps: `{"team":"x"}`},
+		{name: "Error: blocked", p: &classify.Policy{Rules: []classify.Rule{rule}}, boundary: types.DBGlobal, wantErr: true},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.p != nil {
+			ctx = classify.WithPolicy(ctx, *test.p)
+		}
+
+		var got envelope.Event
+		sent := false
+		n := Notifications{
+			ctx:          ctx,
+			Data:         []types.NotificationResource{rsc},
+			DataBoundary: test.boundary,
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				got = event
+				sent = true
+				return nil
+			},
+		}
+		err := n.SendEvent(nil, nil)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSendClassification(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSendClassification(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if sent {
+				t.Errorf("TestSendClassification(%s): a blocked notification was sent", test.name)
+			}
+			continue
+		}
+
+		if want := test.boundary.String(); `"`+got.Data.DataBoundary+`"` != want {
+			t.Errorf("TestSendClassification(%s): got DataBoundary %q, want %s", test.name, got.Data.DataBoundary, want)
+		}
+		props, err := json.Marshal(got.Data.Resources[0].ArmResource.Properties, json.Deterministic(true))
+		if err != nil {
+			t.Fatalf("TestSendClassification(%s): could not marshal the sent properties: %s", test.name, err)
+		}
+		if string(props) != test.wantProps {
+			t.Errorf("TestSendClassification(%s): got sent properties %s, want %s", test.name, props, test.wantProps)
+		}
+		if n.Data[0].ArmResource.Properties != rsc.ArmResource.Properties {
+			t.Errorf("TestSendClassification(%s): the caller's Data was changed", test.name)
+		}
+	}
+}
+
+func TestSendPropertyLimits(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something")
+	if err != nil {
+		panic(err)
+	}
+	rsc := func(props any) types.NotificationResource {
+		return types.NotificationResource{
+			ResourceID: rescID.String(),
+			APIVersion: "2024-01-01",
+			ResourceSystemProperties: types.ResourceSystemProperties{
+				ChangeAction: types.CAUpdate,
+			},
+			ArmResource: mustNewArm(types.ActWrite, rescID, "2020-05-01", props),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		limits  *types.PropertyLimits
+		props   any
+		wantErr bool
+	}{
+		{name: "No limits", props: map[string]any{"load": math.NaN()}},
+		{name: "Within limits", limits: &types.PropertyLimits{}, props: map[string]any{"load": 0.5}},
+		{name: "Error: NaN", limits: &types.PropertyLimits{}, props: map[string]any{"load": math.NaN()}, wantErr: true},
+		{name: "Error: too large", limits: &types.PropertyLimits{MaxBytes: 10}, props: map[string]any{"team": "platform"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.limits != nil {
+			ctx = proplimits.WithLimits(ctx, *test.limits)
+		}
+
+		sent := false
+		n := Notifications{
+			ctx:  ctx,
+			Data: []types.NotificationResource{rsc(test.props)},
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				sent = true
+				return nil
+			},
+		}
+		err := n.SendEvent(nil, nil)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSendPropertyLimits(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSendPropertyLimits(%s): got err == %s, want err == nil", test.name, err)
+		case err != nil:
+			if sent {
+				t.Errorf("TestSendPropertyLimits(%s): a notification over the limits was sent", test.name)
+			}
+			// The error names the offending resource.
+			if !strings.Contains(err.Error(), rescID.String()) {
+				t.Errorf("TestSendPropertyLimits(%s): got err == %s, want it to name the resource", test.name, err)
+			}
+			if !models.IsValidation(err) {
+				t.Errorf("TestSendPropertyLimits(%s): got err == %s, want models.IsValidation(err)", test.name, err)
+			}
+		}
+	}
+}
+
+func TestSendSubject(t *testing.T) {
+	t.Parallel()
+
+	const sub = "/subscriptions/00000000-0000-0000-0000-000000000000"
+	rsc := func(name string) types.NotificationResource {
+		rescID, err := arm.ParseResourceID(sub + "/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/" + name)
+		if err != nil {
+			panic(err)
+		}
+		return types.NotificationResource{
+			ResourceID: rescID.String(),
+			APIVersion: "2024-01-01",
+			ResourceSystemProperties: types.ResourceSystemProperties{
+				ChangeAction: types.CAUpdate,
+			},
+			ArmResource: mustNewArm(types.ActWrite, rescID, "2020-05-01", map[string]any{"a": 1}),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+		wantErr bool
+	}{
+		{name: "Computed", want: sub + "/resourceGroups/test"},
+		{name: "Subscription", subject: sub, want: sub},
+		{name: "Tenant", subject: "/", want: "/"},
+		{name: "Error: not a scope of the resources", subject: "/subscriptions/00000000-0000-0000-0000-000000000001", wantErr: true},
+	}
+
+	for _, test := range tests {
+		var got string
+		n := Notifications{
+			ctx:     context.Background(),
+			Subject: test.subject,
+			Data:    []types.NotificationResource{rsc("a"), rsc("b")},
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				got = event.EventMeta.Subject
+				return nil
+			},
+		}
+		err := n.SendEvent(nil, nil)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSendSubject(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSendSubject(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if !models.IsValidation(err) {
+				t.Errorf("TestSendSubject(%s): got err == %s, want models.IsValidation(err)", test.name, err)
+			}
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestSendSubject(%s): got subject %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSendResourceSizes(t *testing.T) {
+	t.Parallel()
+
+	rsc := func(name string, props any) types.NotificationResource {
+		rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/" + name)
+		if err != nil {
+			panic(err)
+		}
+		return types.NotificationResource{
+			ResourceID: rescID.String(),
+			APIVersion: "2024-01-01",
+			ResourceSystemProperties: types.ResourceSystemProperties{
+				ChangeAction: types.CAUpdate,
+			},
+			ArmResource: mustNewArm(types.ActWrite, rescID, "2020-05-01", props),
+		}
+	}
+	small := rsc("small", map[string]any{"team": "platform"})
+	large := rsc("large", map[string]any{"labels": strings.Repeat("x", 1000)})
+	b, err := json.Marshal(small)
+	if err != nil {
+		panic(err)
+	}
+	smallSize := len(b)
+
+	tests := []struct {
+		name    string
+		max     int
+		data    []types.NotificationResource
+		wantErr string
+	}{
+		{name: "No limit", data: []types.NotificationResource{small, large}},
+		{name: "At the limit", max: smallSize, data: []types.NotificationResource{small, small}},
+		{name: "Error: first resource", max: smallSize, data: []types.NotificationResource{large, small}, wantErr: "Data[0](" + large.ResourceID + ")"},
+		{name: "Error: later resource", max: smallSize, data: []types.NotificationResource{small, large}, wantErr: "Data[1](" + large.ResourceID + ")"},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.max > 0 {
+			ctx = itemsize.WithMax(ctx, test.max)
+		}
+
+		sent := false
+		n := Notifications{
+			ctx:  ctx,
+			Data: test.data,
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				sent = true
+				return nil
+			},
+		}
+		err := n.SendEvent(nil, nil)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("TestSendResourceSizes(%s): got err == %s, want err == nil", test.name, err)
+		case test.wantErr == "":
+		case err == nil:
+			t.Errorf("TestSendResourceSizes(%s): got err == nil, want err != nil", test.name)
+		default:
+			if sent {
+				t.Errorf("TestSendResourceSizes(%s): a notification over the limit was sent", test.name)
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("TestSendResourceSizes(%s): got err == %s, want it to contain %q", test.name, err, test.wantErr)
+			}
+			if !models.IsValidation(err) {
+				t.Errorf("TestSendResourceSizes(%s): got err == %s, want models.IsValidation(err)", test.name, err)
+			}
+		}
+	}
+}
+
+func TestCheckSkew(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bounds := skew.Bounds{MaxPast: time.Hour, MaxFuture: time.Minute}
+	withAction := func(a skew.Action) skew.Bounds {
+		b := bounds
+		b.Action = a
+		return b
+	}
+
+	tests := []struct {
+		name          string
+		b             skew.Bounds
+		local         time.Time
+		times         []time.Time
+		wantTimes     []time.Time
+		wantEventTime time.Time
+		wantErr       bool
+	}{
+		{
+			name:      "Within bounds",
+			b:         bounds,
+			local:     now,
+			times:     []time.Time{now.Add(-time.Minute), now},
+			wantTimes: []time.Time{now.Add(-time.Minute), now},
+		},
+		{
+			name:    "Error: resource too old",
+			b:       bounds,
+			local:   now,
+			times:   []time.Time{now, now.Add(-2 * time.Hour)},
+			wantErr: true,
+		},
+		{
+			name:    "Error: local clock ahead",
+			b:       bounds,
+			local:   now.Add(5 * time.Minute),
+			times:   []time.Time{now},
+			wantErr: true,
+		},
+		{
+			name:      "Warn keeps the times",
+			b:         withAction(skew.Warn),
+			local:     now.Add(5 * time.Minute),
+			times:     []time.Time{now.Add(-2 * time.Hour)},
+			wantTimes: []time.Time{now.Add(-2 * time.Hour)},
+		},
+		{
+			name:          "Clamp moves the times and corrects the EventTime",
+			b:             withAction(skew.Clamp),
+			local:         now.Add(5 * time.Minute),
+			times:         []time.Time{now.Add(-2 * time.Hour), now, now.Add(time.Hour)},
+			wantTimes:     []time.Time{now.Add(-time.Hour), now, now.Add(time.Minute)},
+			wantEventTime: now,
+		},
+	}
+
+	for _, test := range tests {
+		n := Notifications{}
+		for _, rt := range test.times {
+			n.Data = append(n.Data, types.NotificationResource{ResourceEventTime: rt})
+		}
+
+		got, err := n.checkSkew(test.b, test.local, now)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestCheckSkew(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestCheckSkew(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		for i, want := range test.wantTimes {
+			if !got.Data[i].ResourceEventTime.Equal(want) {
+				t.Errorf("TestCheckSkew(%s): Data[%d]: got ResourceEventTime %v, want %v", test.name, i, got.Data[i].ResourceEventTime, want)
+			}
+			if !n.Data[i].ResourceEventTime.Equal(test.times[i]) {
+				t.Errorf("TestCheckSkew(%s): Data[%d]: the caller's data was changed", test.name, i)
+			}
+		}
+		if !got.eventTime.Equal(test.wantEventTime) {
+			t.Errorf("TestCheckSkew(%s): got eventTime %v, want %v", test.name, got.eventTime, test.wantEventTime)
+		}
+	}
+}
+
 func TestDataToJSON(t *testing.T) {
 	t.Parallel()
 
@@ -345,6 +1339,17 @@ func TestDataToJSON(t *testing.T) {
 	if string(got) != string(want) {
 		t.Errorf("TestDataToJSON: got %s, want %s", got, want)
 	}
+
+	// Nothing is cached, so a change is seen by the next call.
+	n.Data[0].ResourceID = "id"
+	want = []byte(`[{"resourceId":"id"}]`)
+	got, err = n.dataToJSON()
+	if err != nil {
+		panic(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("TestDataToJSON(after change): got %s, want %s", got, want)
+	}
 }
 
 func TestToEvent(t *testing.T) {
@@ -526,3 +1531,95 @@ func mustNewArm(act types.Activity, id *arm.ResourceID, apiVersion string, props
 	}
 	return resc
 }
+
+func TestSendPrepared(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something")
+	if err != nil {
+		panic(err)
+	}
+	rsc := types.NotificationResource{
+		ResourceID: uuid.New().String(),
+		APIVersion: "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			ChangeAction: types.CADelete,
+		},
+		ArmResource: mustNewArm(types.ActDelete, rescID, "2020-05-01", nil),
+	}
+	// Enough resources that they do not fit inline.
+	blobData := make([]types.NotificationResource, 0, 3000)
+	for range 3000 {
+		blobData = append(blobData, rsc)
+	}
+	blobURL, _ := url.Parse("https://blob")
+
+	tests := []struct {
+		name   string
+		data   []types.NotificationResource
+		canary bool
+		// blobErrs are the errors of each upload in order, nil after the last.
+		blobErrs    []error
+		wantUploads int
+	}{
+		{name: "Inline", data: []types.NotificationResource{rsc}},
+		{name: "Blob", data: blobData, wantUploads: 1},
+		{name: "Blob upload fails", data: blobData, blobErrs: []error{errors.New("blob error")}, wantUploads: 2},
+		{name: "Blob canary", data: []types.NotificationResource{rsc}, canary: true, wantUploads: 1},
+	}
+
+	for _, test := range tests {
+		ctx := prepared.With(context.Background())
+		if test.canary {
+			ctx = canary.WithBlob(ctx)
+		}
+
+		// The first attempts fail after the event is sent, as if the receiver accepted it but the client
+		// saw a 5xx or a timeout.
+		const attempts = 3
+		var ids []string
+		var uris []string
+		uploads := 0
+		n := Notifications{
+			ctx:  ctx,
+			Data: test.data,
+			testSendHTTP: func(_ models.EventSender, event envelope.Event) error {
+				ids = append(ids, event.EventMeta.ID)
+				uris = append(uris, event.Data.ResourcesBlobInfo.BlobURI)
+				if len(ids) < attempts {
+					return errors.New("http error")
+				}
+				return nil
+			},
+			testSendBlob: func(models.PayloadStore, []byte) (*url.URL, error) {
+				uploads++
+				if uploads <= len(test.blobErrs) {
+					return nil, test.blobErrs[uploads-1]
+				}
+				return blobURL, nil
+			},
+		}
+
+		for range attempts + len(test.blobErrs) {
+			if err = n.SendEvent(nil, &storage.Client{}); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			t.Errorf("TestSendPrepared(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if len(ids) != attempts {
+			t.Errorf("TestSendPrepared(%s): got %d events sent, want %d", test.name, len(ids), attempts)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != ids[0] || uris[i] != uris[0] {
+				t.Errorf("TestSendPrepared(%s): attempt %d sent event %s with blob %q, want event %s with blob %q", test.name, i, ids[i], uris[i], ids[0], uris[0])
+			}
+		}
+		if uploads != test.wantUploads {
+			t.Errorf("TestSendPrepared(%s): got %d uploads, want %d", test.name, uploads, test.wantUploads)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package msgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+// partitionKey is what must be the same for all resources in a notification: the resource type (which
+// determines the Properties type), the API version and the activity (which determines the event type).
+type partitionKey struct {
+	rscType    string
+	apiVersion string
+	act        types.Activity
+}
+
+func keyOf(r types.NotificationResource) partitionKey {
+	apiVersion := r.APIVersion
+	if apiVersion == "" {
+		apiVersion = r.ArmResource.APIVersion
+	}
+	return partitionKey{
+		rscType:    strings.ToLower(r.ArmResource.Type),
+		apiVersion: apiVersion,
+		act:        r.ArmResource.Activity(),
+	}
+}
+
+func (k partitionKey) String() string {
+	return fmt.Sprintf("%s/%s@%s", k.rscType, k.act, k.apiVersion)
+}
+
+// Partition splits n into notifications that each hold resources of a single resource type, API version
+// and activity, as a single notification requires, with at most maxItems resources each. If maxItems <= 0,
+// client.DefaultMaxItems is used. The notifications are in the order each partition was first seen in
+// n.Data and resources keep their order within a partition. Every notification has the fields of n, but
+// not its promise.
+func (n Notifications) Partition(maxItems int) []Notifications {
+	return n.PartitionByTime(maxItems, 0)
+}
+
+// PartitionByTime is Partition(), but a notification also only holds resources whose ResourceEventTime is
+// in the same bucket, the time truncated to a multiple of bucket. This is for snapshots, where ARG queries
+// rely on all resources in an event having consistent timestamps. Within a partition, resources are stably
+// sorted by bucket, so that earlier buckets are sent first. If bucket <= 0, this is the same as Partition().
+func (n Notifications) PartitionByTime(maxItems int, bucket time.Duration) []Notifications {
+	parts, _ := n.partition(maxItems, bucket)
+	var out []Notifications
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// partition does the work of PartitionByTime(), but keeps the notifications of each partition together.
+func (n Notifications) partition(maxItems int, bucket time.Duration) ([][]Notifications, []partitionKey) {
+	if maxItems <= 0 {
+		maxItems = maxvals.NotificationItems
+	}
+
+	var keys []partitionKey
+	groups := map[partitionKey][]types.NotificationResource{}
+	for _, r := range n.Data {
+		k := keyOf(r)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], r)
+	}
+
+	tmpl := n
+	tmpl.promise = nil
+	tmpl.Data = nil
+
+	parts := make([][]Notifications, 0, len(keys))
+	for _, k := range keys {
+		data := groups[k]
+		if bucket > 0 {
+			slices.SortStableFunc(data, func(a, b types.NotificationResource) int {
+				return a.ResourceEventTime.Truncate(bucket).Compare(b.ResourceEventTime.Truncate(bucket))
+			})
+		}
+		var part []Notifications
+		for len(data) > 0 {
+			size := min(len(data), maxItems, bucketLen(data, bucket))
+			c := tmpl
+			c.Data = data[:size:size]
+			part = append(part, c)
+			data = data[size:]
+		}
+		parts = append(parts, part)
+	}
+	return parts, keys
+}
+
+// bucketLen returns the number of resources at the start of data that are in the same time bucket as the
+// first. If bucket <= 0, it returns len(data).
+func bucketLen(data []types.NotificationResource, bucket time.Duration) int {
+	if bucket <= 0 {
+		return len(data)
+	}
+	first := data[0].ResourceEventTime.Truncate(bucket)
+	for i, r := range data[1:] {
+		if !r.ResourceEventTime.Truncate(bucket).Equal(first) {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// Notifier sends a notification and waits for the result. *client.ARN implements this.
+type Notifier interface {
+	Notify(ctx context.Context, n models.Notifications) error
+}
+
+// Partitioner sends a mixed set of resources, which a single notification cannot hold, by partitioning
+// them with Notifications.Partition(). Partitions are sent concurrently. The notifications of a partition
+// are sent one at a time, so the resources of each type arrive in order. Use NewPartitioner() to create one.
+type Partitioner struct {
+	notifier    Notifier
+	tmpl        Notifications
+	maxItems    int
+	concurrency int
+}
+
+// PartitionOption is an option for NewPartitioner().
+type PartitionOption func(*Partitioner) error
+
+// WithPartitionMaxItems sets the maximum number of resources in each notification. This should match
+// client.WithMaxItems(). Defaults to client.DefaultMaxItems (1000).
+func WithPartitionMaxItems(n int) PartitionOption {
+	return func(p *Partitioner) error {
+		if n <= 0 {
+			return fmt.Errorf("max items must be greater than 0")
+		}
+		p.maxItems = n
+		return nil
+	}
+}
+
+// WithPartitionConcurrency sets the number of partitions that are sent at the same time. Defaults to 4.
+func WithPartitionConcurrency(n int) PartitionOption {
+	return func(p *Partitioner) error {
+		if n <= 0 {
+			return fmt.Errorf("concurrency must be greater than 0")
+		}
+		p.concurrency = n
+		return nil
+	}
+}
+
+// NewPartitioner creates a new Partitioner that sends with notifier. tmpl holds the fields that are set on
+// every notification, such as ResourceLocation and PublisherInfo. Its Data is ignored.
+func NewPartitioner(notifier Notifier, tmpl Notifications, options ...PartitionOption) (*Partitioner, error) {
+	if notifier == nil {
+		return nil, fmt.Errorf("notifier cannot be nil")
+	}
+	tmpl.promise = nil
+	tmpl.Data = nil
+
+	p := &Partitioner{
+		notifier:    notifier,
+		tmpl:        tmpl,
+		maxItems:    maxvals.NotificationItems,
+		concurrency: 4,
+	}
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Send partitions resources and sends them, blocking until all partitions are sent or have failed. If a
+// notification in a partition fails, the rest of that partition is not sent, so that resources of a type
+// are never sent out of order. Other partitions are not affected. The returned error joins the error of
+// each failed partition. Send keeps the order of resources of a type across calls only if the calls are
+// made one after another. Thread-safe.
+func (p *Partitioner) Send(ctx context.Context, resources []types.NotificationResource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	n := p.tmpl
+	n.Data = resources
+	parts, keys := n.partition(p.maxItems, 0)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, p.concurrency)
+	)
+	for i, part := range parts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := p.sendPart(ctx, part); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("partition(%s): %w", keys[i], err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// sendPart sends the notifications of a partition in order, stopping at the first error.
+func (p *Partitioner) sendPart(ctx context.Context, part []Notifications) error {
+	total := 0
+	for _, n := range part {
+		total += len(n.Data)
+	}
+
+	sent := 0
+	for _, n := range part {
+		if err := p.notifier.Notify(ctx, n); err != nil {
+			return fmt.Errorf("sent %d of %d resources: %w", sent, total, err)
+		}
+		sent += len(n.Data)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package msgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+func partRsc(t *testing.T, act types.Activity, rscType string, i int) types.NotificationResource {
+	t.Helper()
+
+	id, err := arm.ParseResourceID(fmt.Sprintf("/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/%s/r%d", rscType, i))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.NotificationResource{
+		ResourceID:  id.String(),
+		APIVersion:  "2024-01-01",
+		StatusCode:  types.StatusCode,
+		ArmResource: mustNewArm(act, id, "2024-01-01", map[string]any{"i": i}),
+	}
+}
+
+const (
+	clusters = "Microsoft.ContainerService/managedClusters"
+	vms      = "Microsoft.Compute/virtualMachines"
+)
+
+func TestPartition(t *testing.T) {
+	t.Parallel()
+
+	n := Notifications{
+		ResourceLocation: "eastus",
+		PublisherInfo:    "Microsoft.ContainerService",
+		Data: []types.NotificationResource{
+			partRsc(t, types.ActWrite, clusters, 0),
+			partRsc(t, types.ActWrite, vms, 1),
+			partRsc(t, types.ActWrite, clusters, 2),
+			partRsc(t, types.ActDelete, clusters, 3),
+			partRsc(t, types.ActWrite, clusters, 4),
+			partRsc(t, types.ActWrite, vms, 5),
+		},
+	}
+
+	got := n.Partition(2)
+
+	// Partitions are in first-seen order and split at 2 items.
+	want := [][]string{
+		{"r0", "r2"},
+		{"r4"},
+		{"r1", "r5"},
+		{"r3"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("TestPartition: got %d notifications, want %d", len(got), len(want))
+	}
+	for i, g := range got {
+		if g.ResourceLocation != "eastus" || g.PublisherInfo != "Microsoft.ContainerService" {
+			t.Errorf("TestPartition: notification %d did not keep the fields of the template", i)
+		}
+		var names []string
+		for _, r := range g.Data {
+			names = append(names, r.ArmResource.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(want[i]) {
+			t.Errorf("TestPartition: notification %d: got %v, want %v", i, names, want[i])
+		}
+		for _, r := range g.Data {
+			if keyOf(r) != keyOf(g.Data[0]) {
+				t.Errorf("TestPartition: notification %d has mixed resources: %s and %s", i, keyOf(r), keyOf(g.Data[0]))
+			}
+		}
+	}
+}
+
+func TestPartitionByTime(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(r types.NotificationResource, d time.Duration) types.NotificationResource {
+		r.ResourceEventTime = base.Add(d)
+		return r
+	}
+
+	n := Notifications{
+		Data: []types.NotificationResource{
+			at(partRsc(t, types.ActSnapshot, clusters, 0), 70*time.Second),
+			at(partRsc(t, types.ActSnapshot, clusters, 1), 10*time.Second),
+			at(partRsc(t, types.ActSnapshot, vms, 2), 10*time.Second),
+			at(partRsc(t, types.ActSnapshot, clusters, 3), 20*time.Second),
+			at(partRsc(t, types.ActSnapshot, clusters, 4), 80*time.Second),
+			at(partRsc(t, types.ActSnapshot, clusters, 5), 30*time.Second),
+		},
+	}
+
+	tests := []struct {
+		name   string
+		bucket time.Duration
+		max    int
+		want   [][]string
+	}{
+		{
+			name: "No bucket",
+			max:  10,
+			want: [][]string{{"r0", "r1", "r3", "r4", "r5"}, {"r2"}},
+		},
+		{
+			name:   "Minute buckets, earlier buckets first",
+			bucket: time.Minute,
+			max:    10,
+			want:   [][]string{{"r1", "r3", "r5"}, {"r0", "r4"}, {"r2"}},
+		},
+		{
+			name:   "Minute buckets split at max items",
+			bucket: time.Minute,
+			max:    2,
+			want:   [][]string{{"r1", "r3"}, {"r5"}, {"r0", "r4"}, {"r2"}},
+		},
+	}
+
+	for _, test := range tests {
+		got := n.PartitionByTime(test.max, test.bucket)
+		var names [][]string
+		for _, g := range got {
+			var ns []string
+			for _, r := range g.Data {
+				ns = append(ns, r.ArmResource.Name)
+			}
+			names = append(names, ns)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(test.want) {
+			t.Errorf("TestPartitionByTime(%s): got %v, want %v", test.name, names, test.want)
+		}
+	}
+
+	// The caller's resources must keep their order.
+	if n.Data[0].ArmResource.Name != "r0" || n.Data[1].ArmResource.Name != "r1" {
+		t.Errorf("TestPartitionByTime: the caller's Data was reordered")
+	}
+}
+
+type fakePartNotifier struct {
+	mu   sync.Mutex
+	got  map[string][]string
+	fail string
+}
+
+func (f *fakePartNotifier) Notify(ctx context.Context, n models.Notifications) error {
+	data := n.(Notifications).Data
+	rscType := data[0].ArmResource.Type
+	if rscType == f.fail {
+		return errors.New("error")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range data {
+		f.got[rscType] = append(f.got[rscType], r.ArmResource.Name)
+	}
+	return nil
+}
+
+func TestPartitionerSend(t *testing.T) {
+	t.Parallel()
+
+	var rscs []types.NotificationResource
+	for i := range 10 {
+		rscType := clusters
+		if i%2 == 1 {
+			rscType = vms
+		}
+		rscs = append(rscs, partRsc(t, types.ActWrite, rscType, i))
+	}
+
+	tests := []struct {
+		name    string
+		fail    string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			want: map[string][]string{
+				clusters: {"r0", "r2", "r4", "r6", "r8"},
+				vms:      {"r1", "r3", "r5", "r7", "r9"},
+			},
+		},
+		{
+			name: "Error: one partition fails",
+			fail: vms,
+			want: map[string][]string{
+				clusters: {"r0", "r2", "r4", "r6", "r8"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		f := &fakePartNotifier{got: map[string][]string{}, fail: test.fail}
+		p, err := NewPartitioner(f, Notifications{ResourceLocation: "eastus"}, WithPartitionMaxItems(2))
+		if err != nil {
+			t.Fatalf("TestPartitionerSend(%s): got err == %s, want err == nil", test.name, err)
+		}
+
+		err = p.Send(context.Background(), rscs)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestPartitionerSend(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestPartitionerSend(%s): got err == %s, want err == nil", test.name, err)
+		}
+
+		if fmt.Sprint(f.got) != fmt.Sprint(test.want) {
+			t.Errorf("TestPartitionerSend(%s): got %v, want %v", test.name, f.got, test.want)
+		}
+	}
+}
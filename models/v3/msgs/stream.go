@@ -0,0 +1,167 @@
+package msgs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+
+	"github.com/Azure/arn-sdk/internal/conn/classify"
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/internal/conn/eventinfo"
+	"github.com/Azure/arn-sdk/internal/conn/prepared"
+	"github.com/Azure/arn-sdk/internal/conn/progress"
+	"github.com/Azure/arn-sdk/internal/conn/stats"
+	"github.com/Azure/arn-sdk/internal/conn/tracing"
+	"github.com/Azure/arn-sdk/internal/conn/watchdog"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/arn-sdk/models/version"
+
+	"github.com/google/uuid"
+)
+
+// Stream is the resources of a notification as a stream of JSON, for payloads too large to hold in memory,
+// such as a multi-GB snapshot of a fleet. The JSON is uploaded to blob storage as it is read, without being
+// checked or held in memory, so it must be what Notifications.Data would serialize to. A notification with a
+// Stream is always sent through blob storage. See FileStream() for a Stream from a file.
+type Stream struct {
+	// Open returns a reader of the JSON array of the resources. It is called again if the upload has to
+	// start over, so it must return the same JSON each time. The reader is closed after the upload.
+	Open func() (io.ReadCloser, error)
+	// Size is the size of the JSON in bytes, which is sent to ARN as the size of the blob.
+	Size int64
+	// Count is the number of resources in the JSON.
+	Count int
+	// Sample is one of the resources, which sets the subject and type of the event. It is validated, but
+	// not sent. All the resources in the JSON must be of the same type, with the same activity.
+	Sample types.NotificationResource
+}
+
+// FileStream returns a Stream of the JSON array of count resources in the file at path. The file is opened
+// for each upload. sample is one of the resources, see Stream.Sample.
+func FileStream(path string, count int, sample types.NotificationResource) (*Stream, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	return &Stream{
+		Open:   func() (io.ReadCloser, error) { return os.Open(path) },
+		Size:   fi.Size(),
+		Count:  count,
+		Sample: sample,
+	}, nil
+}
+
+// validate validates the Stream.
+func (s *Stream) validate() error {
+	switch {
+	case s.Open == nil:
+		return errors.New("Stream.Open is required")
+	case s.Size <= 0:
+		return errors.New("Stream.Size must be greater than 0")
+	case s.Count <= 0:
+		return errors.New("Stream.Count must be greater than 0")
+	case s.Count > math.MaxUint16:
+		return fmt.Errorf("too many resources to send in a single event: %d", s.Count)
+	}
+	// The status code is set by the SDK when the resources are sent, as it is for Notifications.Data.
+	sample := s.Sample
+	sample.StatusCode = types.StatusCode
+	if err := sample.Validate(); err != nil {
+		return fmt.Errorf("Stream.Sample%w", err)
+	}
+	return nil
+}
+
+// sendStream sends a notification with a Stream. The stream is uploaded to blob storage and the event
+// that points to it is sent to the ARN service.
+func (n Notifications) sendStream(hc models.EventSender, store models.PayloadStore) error {
+	if len(n.Data) > 0 {
+		return invalid(errors.New("Data must be empty when Stream is set"))
+	}
+	if err := n.Stream.validate(); err != nil {
+		return invalid(err)
+	}
+	// A Stream is uploaded as it is read, so it cannot be encrypted before the upload.
+	if _, ok := encrypt.FromCtx(n.ctx); ok {
+		return errors.New("a Stream cannot be sent with blob encryption on")
+	}
+	// The resources of a Stream are not read before the upload, so a classification policy cannot be enforced.
+	if _, ok := classify.FromCtx(n.ctx); ok {
+		return errors.New("a Stream cannot be sent with a classification policy on")
+	}
+	if store == nil {
+		return models.ErrNoBlobClient
+	}
+	ss, ok := store.(models.StreamStore)
+	if !ok {
+		return fmt.Errorf("the blob store (%T) cannot upload a Stream", store)
+	}
+
+	meta, err := newEventMeta([]types.NotificationResource{n.Stream.Sample})
+	if err != nil {
+		return fmt.Errorf("problem creating an EventMeta: %w", err)
+	}
+	if n.Subject != "" {
+		if err := checkSubject(n.Subject, []types.NotificationResource{n.Stream.Sample}); err != nil {
+			return invalid(err)
+		}
+		meta.Subject = n.Subject
+	}
+	if !n.eventTime.IsZero() {
+		meta.EventTime = n.eventTime
+	}
+	if n.MetadataVersion != "" {
+		meta.MetadataVersion = n.MetadataVersion
+	}
+	if n.DataVersion != "" {
+		meta.DataVersion = n.DataVersion
+	}
+	n.AdditionalBatchProperties.BatchSize = uint16(n.Stream.Count)
+	n.AdditionalBatchProperties.SDKVersion = version.SDK.AsARNFormat()
+
+	event := envelope.Event{
+		EventMeta: meta,
+		Data: types.Data{
+			FrontdoorLocation:         n.FrontdoorLocation,
+			AdditionalBatchProperties: n.AdditionalBatchProperties,
+			ResourcesContainer:        types.RCBlob,
+			ResourceLocation:          n.ResourceLocation,
+			PublisherInfo:             n.PublisherInfo,
+			DataBoundary:              n.dataBoundary(),
+		},
+	}
+	if err := event.ValidatePreview(n.previewVersions()); err != nil {
+		return invalid(err)
+	}
+	tracing.SetEvent(n.ctx, event.EventMeta.ID, event.EventMeta.Subject)
+	eventinfo.FromCtx(n.ctx).Set(event.EventMeta.ID, event.EventMeta.Subject)
+
+	watchdog.SetStage(n.ctx, watchdog.UploadingBlob)
+	var u *url.URL
+	n.stage("arn.uploadBlob", func() {
+		progress.Report(n.ctx)
+		u, err = ss.UploadStream(n.ctx, uuid.New().String(), n.Stream.Open)
+	})
+	if err != nil {
+		if n.ctx != nil && n.ctx.Err() != nil {
+			stats.BlobAborted(n.ctx)
+		}
+		return err
+	}
+
+	event.Data.ResourcesBlobInfo.BlobURI = u.String()
+	event.Data.ResourcesBlobInfo.BlobSize = n.Stream.Size
+	// The Stream may not be readable again, so a resend sends this event instead of uploading it again.
+	prepared.Set(n.ctx, preparedEvent{event: event, size: n.Stream.Size})
+	watchdog.SetStage(n.ctx, watchdog.AwaitingHTTP)
+	n.stage("arn.sendHTTP", func() { err = n.sendHTTP(hc, event) })
+	return err
+}
@@ -0,0 +1,242 @@
+package msgs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/arn-sdk/internal/conn/classify"
+	"github.com/Azure/arn-sdk/models"
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/google/uuid"
+)
+
+// fakeStreamStore is a models.StreamStore that records the last stream it uploaded.
+type fakeStreamStore struct {
+	err  error
+	data string
+}
+
+func (f *fakeStreamStore) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	return nil, errors.New("Upload() should not be called for a Stream")
+}
+
+func (f *fakeStreamStore) UploadStream(ctx context.Context, id string, open func() (io.ReadCloser, error)) (*url.URL, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	f.data = string(b)
+	return url.Parse("https://blob/" + id)
+}
+
+// bufferStore is a models.PayloadStore that cannot upload a stream.
+type bufferStore struct{}
+
+func (bufferStore) Upload(ctx context.Context, id string, b []byte) (*url.URL, error) {
+	return url.Parse("https://blob/" + id)
+}
+
+func TestSendStream(t *testing.T) {
+	t.Parallel()
+
+	rescID, err := arm.ParseResourceID(path.Join(
+		`/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/test/providers/Microsoft.ContainerService/managedClusters/something/`,
+		`nodes/aks-nodepool1-12345678-vmss000000`,
+	))
+	if err != nil {
+		panic(err)
+	}
+	sample := types.NotificationResource{
+		ResourceID: uuid.New().String(),
+		APIVersion: "2024-01-01",
+		ResourceSystemProperties: types.ResourceSystemProperties{
+			ChangeAction: types.CADelete,
+		},
+		ArmResource: mustNewArm(types.ActDelete, rescID, "2020-05-01", nil),
+	}
+
+	const content = `[{"resourceId":"a"},{"resourceId":"b"}]`
+	stream := func() *Stream {
+		return &Stream{
+			Open:   func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil },
+			Size:   int64(len(content)),
+			Count:  2,
+			Sample: sample,
+		}
+	}
+
+	policy := classify.Policy{Rules: []classify.Rule{{Classification: "euii", Allowed: []types.DataBoundary{types.DBEU}}}}
+
+	tests := []struct {
+		name    string
+		n       Notifications
+		store   models.PayloadStore
+		httpErr error
+		wantErr bool
+	}{
+		{
+			name:    "Error: Data and Stream are both set",
+			n:       Notifications{Data: []types.NotificationResource{sample}, Stream: stream()},
+			store:   &fakeStreamStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: Stream without Open",
+			n:       Notifications{Stream: &Stream{Size: 1, Count: 1, Sample: sample}},
+			store:   &fakeStreamStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: Stream without Size",
+			n:       Notifications{Stream: &Stream{Open: stream().Open, Count: 1, Sample: sample}},
+			store:   &fakeStreamStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: Stream without Count",
+			n:       Notifications{Stream: &Stream{Open: stream().Open, Size: 1, Sample: sample}},
+			store:   &fakeStreamStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: Sample doesn't validate",
+			n:       Notifications{Stream: &Stream{Open: stream().Open, Size: 1, Count: 1}},
+			store:   &fakeStreamStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: no blob store",
+			n:       Notifications{Stream: stream()},
+			wantErr: true,
+		},
+		{
+			name:    "Error: blob store cannot upload a stream",
+			n:       Notifications{Stream: stream()},
+			store:   bufferStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: upload fails",
+			n:       Notifications{Stream: stream()},
+			store:   &fakeStreamStore{err: errors.New("error")},
+			wantErr: true,
+		},
+		{
+			name:    "Error: classification policy",
+			n:       Notifications{Stream: stream(), ctx: classify.WithPolicy(context.Background(), policy)},
+			store:   &fakeStreamStore{},
+			wantErr: true,
+		},
+		{
+			name:    "Error: HTTP fails",
+			n:       Notifications{Stream: stream()},
+			store:   &fakeStreamStore{},
+			httpErr: errors.New("error"),
+			wantErr: true,
+		},
+		{
+			name:  "Success",
+			n:     Notifications{Stream: stream()},
+			store: &fakeStreamStore{},
+		},
+	}
+
+	for _, test := range tests {
+		var sent envelope.Event
+		test.n.testSendHTTP = func(hc models.EventSender, event envelope.Event) error {
+			sent = event
+			return test.httpErr
+		}
+
+		err := test.n.SendEvent(nil, test.store)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestSendStream(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestSendStream(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if got := test.store.(*fakeStreamStore).data; got != content {
+			t.Errorf("TestSendStream(%s): got upload of %q, want %q", test.name, got, content)
+		}
+		d := sent.Data
+		if d.ResourcesContainer != types.RCBlob {
+			t.Errorf("TestSendStream(%s): got ResourcesContainer == %v, want %v", test.name, d.ResourcesContainer, types.RCBlob)
+		}
+		if !strings.HasPrefix(d.ResourcesBlobInfo.BlobURI, "https://blob/") {
+			t.Errorf("TestSendStream(%s): got BlobURI == %s, want the URL from the store", test.name, d.ResourcesBlobInfo.BlobURI)
+		}
+		if d.ResourcesBlobInfo.BlobSize != int64(len(content)) {
+			t.Errorf("TestSendStream(%s): got BlobSize == %d, want %d", test.name, d.ResourcesBlobInfo.BlobSize, len(content))
+		}
+		if d.AdditionalBatchProperties.BatchSize != 2 {
+			t.Errorf("TestSendStream(%s): got BatchSize == %d, want 2", test.name, d.AdditionalBatchProperties.BatchSize)
+		}
+		if sent.EventMeta.Subject != subject([]types.NotificationResource{sample}) {
+			t.Errorf("TestSendStream(%s): got Subject == %s, want the subject of the Sample", test.name, sent.EventMeta.Subject)
+		}
+	}
+}
+
+func TestFileStream(t *testing.T) {
+	t.Parallel()
+
+	const content = `[{"resourceId":"a"}]`
+	p := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		panic(err)
+	}
+
+	s, err := FileStream(p, 1, types.NotificationResource{})
+	if err != nil {
+		t.Fatalf("TestFileStream: got err == %s, want err == nil", err)
+	}
+	if s.Size != int64(len(content)) {
+		t.Errorf("TestFileStream: got Size == %d, want %d", s.Size, len(content))
+	}
+	r, err := s.Open()
+	if err != nil {
+		t.Fatalf("TestFileStream: got Open() err == %s, want err == nil", err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	if string(b) != content {
+		t.Errorf("TestFileStream: got %q, want %q", b, content)
+	}
+
+	n := Notifications{Stream: s}
+	if n.DataCount() != 1 || n.DataSizeHint() != len(content) {
+		t.Errorf("TestFileStream: got DataCount() == %d, DataSizeHint() == %d, want 1, %d", n.DataCount(), n.DataSizeHint(), len(content))
+	}
+
+	if _, err := FileStream(filepath.Dir(p), 1, types.NotificationResource{}); err == nil {
+		t.Errorf("TestFileStream(directory): got err == nil, want err != nil")
+	}
+	if _, err := FileStream(p+".missing", 1, types.NotificationResource{}); err == nil {
+		t.Errorf("TestFileStream(missing): got err == nil, want err != nil")
+	}
+}
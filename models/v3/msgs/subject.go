@@ -1,7 +1,9 @@
 package msgs
 
 import (
+	"fmt"
 	"slices"
+	"strings"
 
 	"github.com/Azure/arn-sdk/models/v3/schema/types"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
@@ -42,6 +44,27 @@ func subject(res []types.NotificationResource) string {
 	return max[0].String()
 }
 
+// checkSubject returns an error if subject, which overrides the subject of an event, is not "/" or a scope of
+// every resource in res. Scopes are compared without regard to case, as ARM resource IDs are.
+func checkSubject(subject string, res []types.NotificationResource) error {
+	if subject == "/" {
+		return nil
+	}
+	for i, r := range res {
+		found := false
+		for _, scope := range asSlice(r.ArmResource.ResourceID()) {
+			if strings.EqualFold(scope.String(), subject) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Subject(%s) is not a scope of Data[%d].ResourceID(%s)", subject, i, r.ResourceID)
+		}
+	}
+	return nil
+}
+
 // maxSharedPrefix returns in slice form the maximal arm.ResourceID which is a shared prefix of a and b.
 func maxSharedPrefix(a, b []*arm.ResourceID) (results []*arm.ResourceID) {
 	// We can find the maximal arm.ResourceID which is a shared prefix of a and b by walking the slices backwards comparing their scopes.
@@ -1,6 +1,7 @@
 package msgs
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/Azure/arn-sdk/models/v3/schema/types"
@@ -120,3 +121,56 @@ func TestSubject(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckSubject(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sub = "/subscriptions/00000000-0000-0000-0000-000000000000"
+		rg  = sub + "/resourceGroups/rg"
+	)
+	res := func(ids ...string) []types.NotificationResource {
+		var out []types.NotificationResource
+		for _, id := range ids {
+			rid, err := arm.ParseResourceID(id)
+			if err != nil {
+				t.Fatalf("TestCheckSubject: failed to parse resource ID %q: %v", id, err)
+			}
+			a, err := types.NewArmResource(types.ActDelete, rid, "2024-01-01", nil)
+			if err != nil {
+				t.Fatalf("TestCheckSubject: types.NewArmResource(): %v", err)
+			}
+			out = append(out, types.NotificationResource{ResourceID: id, ArmResource: a})
+		}
+		return out
+	}
+	a := rg + "/providers/Microsoft.FakeProvider/fakeResources/a"
+	b := rg + "/providers/Microsoft.FakeProvider/fakeResources/b"
+
+	tests := []struct {
+		name    string
+		subject string
+		res     []types.NotificationResource
+		wantErr bool
+	}{
+		{name: "tenant", subject: "/", res: res(a, b)},
+		{name: "subscription above the shared resource group", subject: sub, res: res(a, b)},
+		{name: "shared resource group", subject: rg, res: res(a, b)},
+		{name: "the resource itself", subject: a, res: res(a)},
+		{name: "different case", subject: strings.ToUpper(sub), res: res(a, b)},
+		{name: "Error: scope of only one resource", subject: a, res: res(a, b), wantErr: true},
+		{name: "Error: another subscription", subject: "/subscriptions/00000000-0000-0000-0000-000000000001", res: res(a), wantErr: true},
+		{name: "Error: string prefix that is not a scope", subject: rg + "/providers", res: res(a), wantErr: true},
+		{name: "Error: trailing slash", subject: sub + "/", res: res(a), wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := checkSubject(test.subject, test.res)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestCheckSubject(%s): got err == nil, want err != nil", test.name)
+		case !test.wantErr && err != nil:
+			t.Errorf("TestCheckSubject(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package receiver
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-json-experiment/json"
+)
+
+// ErrUnknownField is wrapped by the errors for JSON fields that the SDK's types do not have, when a Decoder
+// warns about or rejects them.
+var ErrUnknownField = errors.New("unknown field")
+
+// Unknown is what a Decoder does with JSON fields in event data and resources that the SDK's types do not
+// have. These show that ARN is sending schema fields that handlers may not account for.
+type Unknown uint8
+
+const (
+	// IgnoreUnknown drops unknown fields. This is the default.
+	IgnoreUnknown Unknown = 0
+	// WarnUnknown logs a warning and decodes as IgnoreUnknown does. Resources with unknown fields are also
+	// reported in Result.Warnings.
+	WarnUnknown Unknown = 1
+	// RejectUnknown fails an event with unknown fields in its data and reports a resource with unknown
+	// fields in Result.Errors.
+	RejectUnknown Unknown = 2
+)
+
+// String implements fmt.Stringer.
+func (u Unknown) String() string {
+	switch u {
+	case IgnoreUnknown:
+		return "ignore"
+	case WarnUnknown:
+		return "warn"
+	case RejectUnknown:
+		return "reject"
+	}
+	return fmt.Sprintf("Unknown(%d)", u)
+}
+
+// Decoder decodes events and resources. The zero value decodes as ParseEvents() and DecodeResources() do.
+// Only the data of an event is checked for unknown fields, not the Event Grid or CloudEvents wrapper, as
+// those carry attributes added by Event Grid.
+type Decoder struct {
+	// Unknown is what is done with unknown fields. Defaults to IgnoreUnknown.
+	Unknown Unknown
+	// Log is where warnings are logged. Defaults to slog.Default().
+	Log *slog.Logger
+}
+
+// Validate validates the Decoder.
+func (d Decoder) Validate() error {
+	if d.Unknown > RejectUnknown {
+		return fmt.Errorf("Decoder.Unknown(%s) is not valid", d.Unknown)
+	}
+	return nil
+}
+
+func (d Decoder) logger() *slog.Logger {
+	if d.Log == nil {
+		return slog.Default()
+	}
+	return d.Log
+}
+
+// unmarshal decodes b into a new T. If d does not ignore unknown fields, b is decoded with unknown fields
+// rejected first. unknown is set, wrapping ErrUnknownField, if b only decodes with unknown fields ignored.
+// For RejectUnknown, that is returned as err instead.
+func unmarshal[T any](d Decoder, b []byte) (v T, unknown error, err error) {
+	if d.Unknown == IgnoreUnknown {
+		err = json.Unmarshal(b, &v)
+		return v, nil, err
+	}
+
+	strictErr := json.Unmarshal(b, &v, json.RejectUnknownMembers(true))
+	if strictErr == nil {
+		return v, nil, nil
+	}
+	// The strict decode may have filled v before it failed.
+	var lax T
+	if err := json.Unmarshal(b, &lax); err != nil {
+		return lax, nil, err
+	}
+	unknown = fmt.Errorf("%w: %w", ErrUnknownField, strictErr)
+	if d.Unknown == RejectUnknown {
+		return lax, nil, unknown
+	}
+	return lax, unknown, nil
+}
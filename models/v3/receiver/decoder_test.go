@@ -0,0 +1,156 @@
+package receiver
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecoderDecodeResources(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`[
+		{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}},
+		{"resourceId": "b", "newField": 1, "resourceSystemProperties": {"changeAction": "Create"}},
+		{"resourceId": "c", "armResource": {"id": "c", "newArmField": "x", "properties": {"anything": 1}}, "resourceSystemProperties": {"changeAction": "Create"}},
+		{"resourceId": "d", "newField": "x", "resourceSystemProperties": {"changeAction": "Explode"}}
+	]`)
+
+	tests := []struct {
+		name         string
+		unknown      Unknown
+		wantIndexes  []int
+		wantErrIdx   []int
+		wantWarnIdx  []int
+		wantLog      bool
+		wantUnknownI []int // indexes of errors or warnings that wrap ErrUnknownField
+	}{
+		{
+			name:        "Ignore",
+			unknown:     IgnoreUnknown,
+			wantIndexes: []int{0, 1, 2},
+			wantErrIdx:  []int{3},
+		},
+		{
+			name:         "Warn",
+			unknown:      WarnUnknown,
+			wantIndexes:  []int{0, 1, 2},
+			wantErrIdx:   []int{3},
+			wantWarnIdx:  []int{1, 2},
+			wantLog:      true,
+			wantUnknownI: []int{1, 2},
+		},
+		{
+			name:         "Reject",
+			unknown:      RejectUnknown,
+			wantIndexes:  []int{0},
+			wantErrIdx:   []int{1, 2, 3},
+			wantUnknownI: []int{1, 2}, // 3 does not decode even with unknown fields ignored.
+		},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		d := Decoder{Unknown: test.unknown, Log: slog.New(slog.NewTextHandler(buf, nil))}
+
+		res, err := d.DecodeResources(payload)
+		if err != nil {
+			t.Errorf("TestDecoderDecodeResources(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+
+		if !slices.Equal(res.Indexes, test.wantIndexes) {
+			t.Errorf("TestDecoderDecodeResources(%s): got indexes %v, want %v", test.name, res.Indexes, test.wantIndexes)
+		}
+		var gotErrIdx, gotWarnIdx, gotUnknown []int
+		for _, ie := range res.Errors {
+			gotErrIdx = append(gotErrIdx, ie.Index)
+			if errors.Is(ie, ErrUnknownField) {
+				gotUnknown = append(gotUnknown, ie.Index)
+			}
+		}
+		for _, ie := range res.Warnings {
+			gotWarnIdx = append(gotWarnIdx, ie.Index)
+			if errors.Is(ie, ErrUnknownField) {
+				gotUnknown = append(gotUnknown, ie.Index)
+			}
+		}
+		if !slices.Equal(gotErrIdx, test.wantErrIdx) {
+			t.Errorf("TestDecoderDecodeResources(%s): got error indexes %v, want %v", test.name, gotErrIdx, test.wantErrIdx)
+		}
+		if !slices.Equal(gotWarnIdx, test.wantWarnIdx) {
+			t.Errorf("TestDecoderDecodeResources(%s): got warning indexes %v, want %v", test.name, gotWarnIdx, test.wantWarnIdx)
+		}
+		if !slices.Equal(gotUnknown, test.wantUnknownI) {
+			t.Errorf("TestDecoderDecodeResources(%s): got unknown field indexes %v, want %v", test.name, gotUnknown, test.wantUnknownI)
+		}
+		if got := strings.Contains(buf.String(), "does not know"); got != test.wantLog {
+			t.Errorf("TestDecoderDecodeResources(%s): got warning logged == %v, want %v", test.name, got, test.wantLog)
+		}
+	}
+}
+
+func TestDecoderParseEvents(t *testing.T) {
+	t.Parallel()
+
+	event := func(data string) string {
+		return `{"id": "1", "topic": "t", "eventGridExtra": true, "data": ` + data + `}`
+	}
+	known := `{"resources": [], "resourcesContainer": "inline", "publisherInfo": "Microsoft.ContainerService"}`
+	unknown := `{"resources": [], "resourcesContainer": "inline", "publisherInfo": "Microsoft.ContainerService", "newField": 1}`
+
+	tests := []struct {
+		name    string
+		unknown Unknown
+		data    string
+		wantErr bool
+		wantLog bool
+	}{
+		{name: "Ignore", unknown: IgnoreUnknown, data: unknown},
+		{name: "Warn", unknown: WarnUnknown, data: unknown, wantLog: true},
+		{name: "Warn: wrapper fields are not checked", unknown: WarnUnknown, data: known},
+		{name: "Error: Reject", unknown: RejectUnknown, data: unknown, wantErr: true},
+		{name: "Reject: wrapper fields are not checked", unknown: RejectUnknown, data: known},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		d := Decoder{Unknown: test.unknown, Log: slog.New(slog.NewTextHandler(buf, nil))}
+
+		events, err := d.ParseEvents([]byte(event(test.data)))
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestDecoderParseEvents(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestDecoderParseEvents(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if !errors.Is(err, ErrUnknownField) {
+				t.Errorf("TestDecoderParseEvents(%s): got err == %s, want errors.Is(err, ErrUnknownField)", test.name, err)
+			}
+			continue
+		}
+
+		if len(events) != 1 || events[0].Data.PublisherInfo != "Microsoft.ContainerService" {
+			t.Errorf("TestDecoderParseEvents(%s): got %+v, want one decoded event", test.name, events)
+		}
+		if got := strings.Contains(buf.String(), "does not know"); got != test.wantLog {
+			t.Errorf("TestDecoderParseEvents(%s): got warning logged == %v, want %v", test.name, got, test.wantLog)
+		}
+	}
+}
+
+func TestDecoderValidate(t *testing.T) {
+	t.Parallel()
+
+	if err := (Decoder{Unknown: RejectUnknown}).Validate(); err != nil {
+		t.Errorf("TestDecoderValidate: got err == %s, want err == nil", err)
+	}
+	if err := (Decoder{Unknown: RejectUnknown + 1}).Validate(); err == nil {
+		t.Errorf("TestDecoderValidate(out of range): got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,94 @@
+package receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// ErrEncrypted is returned when a blob payload is encrypted and there is no KeyUnwrapper to decrypt it.
+var ErrEncrypted = errors.New("blob payload is encrypted")
+
+// KeyUnwrapper unwraps the content key of a blob payload that the publisher encrypted with
+// client.WithBlobEncryption(). KeyVault is a KeyUnwrapper.
+type KeyUnwrapper = encrypt.KeyUnwrapper
+
+// KeyVault is an RSA key in Azure Key Vault or Managed HSM that unwraps the content keys of blob payloads.
+type KeyVault = encrypt.KeyVault
+
+// NewKeyVault returns a KeyVault for the key with keyID, like "https://myvault.vault.azure.net/keys/mykey".
+// Only content keys wrapped by a version of this key are unwrapped. cred needs the "unwrapKey" permission on
+// the key. opts can be nil.
+func NewKeyVault(keyID string, cred azcore.TokenCredential, opts *policy.ClientOptions) (*KeyVault, error) {
+	return encrypt.NewKeyVault(keyID, cred, opts)
+}
+
+// WithKeyUnwrapper sets the KeyUnwrapper that DownloadData() decrypts encrypted blob payloads with. Without
+// one, DownloadData() returns ErrEncrypted for an encrypted payload.
+func WithKeyUnwrapper(u KeyUnwrapper) DownloadOption {
+	return func(d *Downloader) error {
+		if u == nil {
+			return fmt.Errorf("KeyUnwrapper cannot be nil")
+		}
+		d.unwrapper = u
+		return nil
+	}
+}
+
+// DownloadData downloads the blob payload of data, decrypts it if the publisher encrypted it and decodes the
+// resources with DecodeResources(). Use this instead of DownloadResources() when payloads can be encrypted,
+// as the key to decrypt them is in data.AdditionalBatchProperties.
+func (d *Downloader) DownloadData(ctx context.Context, data types.Data) (Result, error) {
+	b, err := d.DownloadPayload(ctx, data)
+	if err != nil {
+		return Result{}, err
+	}
+	return DecodeResources(b)
+}
+
+// DownloadPayload downloads the blob payload of data and decrypts it if the publisher encrypted it. It is
+// DownloadData() without decoding the resources, for callers that decode them with a Decoder.
+func (d *Downloader) DownloadPayload(ctx context.Context, data types.Data) ([]byte, error) {
+	b, err := d.Download(ctx, data.ResourcesBlobInfo.BlobURI)
+	if err != nil {
+		return nil, err
+	}
+	return d.decrypt(ctx, data, b)
+}
+
+// decrypt returns payload, the blob payload of data, decrypted. payload is returned as is if it is not
+// encrypted.
+func (d *Downloader) decrypt(ctx context.Context, data types.Data, payload []byte) ([]byte, error) {
+	if d.unwrapper == nil {
+		if encrypt.IsSealed(payload) || encrypt.Encrypted(data.AdditionalBatchProperties.Others) {
+			return nil, fmt.Errorf("%w, use WithKeyUnwrapper() to decrypt it", ErrEncrypted)
+		}
+		return payload, nil
+	}
+	return Decrypt(ctx, d.unwrapper, data, payload)
+}
+
+// Decrypt returns payload, the blob payload of data downloaded without a Downloader, decrypted with a content
+// key unwrapped by u. payload is returned as is if it is not encrypted. An error is returned if the payload and
+// data.AdditionalBatchProperties do not agree on whether it is encrypted.
+func Decrypt(ctx context.Context, u KeyUnwrapper, data types.Data, payload []byte) ([]byte, error) {
+	others := data.AdditionalBatchProperties.Others
+	sealed := encrypt.IsSealed(payload)
+	switch {
+	case !sealed && !encrypt.Encrypted(others):
+		return payload, nil
+	case !sealed:
+		return nil, fmt.Errorf("AdditionalBatchProperties record an encrypted payload, but the payload is not encrypted")
+	}
+	b, err := encrypt.Open(ctx, u, others, payload)
+	if err != nil {
+		return nil, err
+	}
+	return gunzip(b)
+}
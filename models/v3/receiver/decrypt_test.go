@@ -0,0 +1,95 @@
+package receiver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+// fakeUnwrapper is an encrypt.KeyWrapper and KeyUnwrapper that does not change the key.
+type fakeUnwrapper struct{}
+
+func (fakeUnwrapper) WrapKey(ctx context.Context, key []byte) (encrypt.Wrapped, error) {
+	return encrypt.Wrapped{KeyID: "key/1", Alg: "none", Key: key}, nil
+}
+
+func (fakeUnwrapper) UnwrapKey(ctx context.Context, w encrypt.Wrapped) ([]byte, error) {
+	return w.Key, nil
+}
+
+func TestDownloadData(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	plain := []byte(`[{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}}]`)
+	sealed, others, err := encrypt.Seal(ctx, fakeUnwrapper{}, plain, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		others  map[string]any
+		options []DownloadOption
+		wantIs  error
+		wantErr bool
+	}{
+		{name: "Plain", content: plain},
+		{name: "Plain with an unwrapper", content: plain, options: []DownloadOption{WithKeyUnwrapper(fakeUnwrapper{})}},
+		{name: "Encrypted", content: sealed, others: others, options: []DownloadOption{WithKeyUnwrapper(fakeUnwrapper{})}},
+		{name: "Error: encrypted without an unwrapper", content: sealed, others: others, wantIs: ErrEncrypted, wantErr: true},
+		{name: "Error: encryption not recorded", content: sealed, options: []DownloadOption{WithKeyUnwrapper(fakeUnwrapper{})}, wantErr: true},
+		{name: "Error: payload not encrypted", content: plain, others: others, options: []DownloadOption{WithKeyUnwrapper(fakeUnwrapper{})}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(test.content)
+		}))
+		d, err := NewDownloader(append([]DownloadOption{WithRangeSize(0)}, test.options...)...)
+		if err != nil {
+			panic(err)
+		}
+
+		data := types.Data{
+			ResourcesBlobInfo:         types.ResourcesBlobInfo{BlobURI: srv.URL + "/c/b", BlobSize: int64(len(test.content))},
+			AdditionalBatchProperties: types.AdditionalBatchProperties{Others: test.others},
+		}
+		res, err := d.DownloadData(ctx, data)
+		srv.Close()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestDownloadData(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestDownloadData(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if test.wantIs != nil && !errors.Is(err, test.wantIs) {
+				t.Errorf("TestDownloadData(%s): got err == %s, want %s", test.name, err, test.wantIs)
+			}
+			continue
+		}
+		if len(res.Resources) != 1 || res.Resources[0].ResourceID != "a" {
+			t.Errorf("TestDownloadData(%s): got %+v, want resource a", test.name, res)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sealed)
+	}))
+	defer srv.Close()
+	d, err := NewDownloader(WithKeyUnwrapper(fakeUnwrapper{}))
+	if err != nil {
+		panic(err)
+	}
+	if _, err := d.DownloadResources(ctx, types.ResourcesBlobInfo{BlobURI: srv.URL + "/c/b"}); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("TestDownloadData: DownloadResources() of an encrypted payload: got err == %v, want %s", err, ErrEncrypted)
+	}
+}
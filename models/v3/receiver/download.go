@@ -0,0 +1,300 @@
+package receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn/encrypt"
+	"github.com/Azure/arn-sdk/internal/conn/retry"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+var (
+	// ErrSASExpired is returned when the SAS on a blob URL has expired or will expire before
+	// the download can safely complete. The notification must be fetched again from ARN.
+	ErrSASExpired = errors.New("blob SAS has expired or is about to expire")
+)
+
+const (
+	// DefaultMinValidity is the default time a SAS must still be valid for to start a download.
+	DefaultMinValidity = 30 * time.Second
+	// DefaultRangeSize is the default size of each range read.
+	DefaultRangeSize = 4 * 1024 * 1024
+)
+
+// Downloader downloads blob payloads of notifications using the SAS URL in the notification. It checks
+// the SAS expiry before each request, retries transient failures, reads large blobs in ranges and
+// decompresses gzip-encoded payloads.
+type Downloader struct {
+	client      *http.Client
+	minValidity time.Duration
+	rangeSize   int64
+	retry       retry.Policy
+	// unwrapper decrypts encrypted payloads in DownloadData(), nil if they are not decrypted.
+	unwrapper KeyUnwrapper
+
+	now func() time.Time
+	rnd func() float64
+}
+
+// DownloadOption is an option for NewDownloader().
+type DownloadOption func(*Downloader) error
+
+// WithHTTPClient sets the HTTP client used to download blobs. Defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) DownloadOption {
+	return func(d *Downloader) error {
+		if c == nil {
+			return fmt.Errorf("http client cannot be nil")
+		}
+		d.client = c
+		return nil
+	}
+}
+
+// WithMinValidity sets how long a SAS must still be valid for to start a request. Defaults to DefaultMinValidity.
+func WithMinValidity(v time.Duration) DownloadOption {
+	return func(d *Downloader) error {
+		if v < 0 {
+			return fmt.Errorf("min validity cannot be negative")
+		}
+		d.minValidity = v
+		return nil
+	}
+}
+
+// WithRangeSize sets the size of each range read. Set to 0 to download blobs in a single request.
+// Defaults to DefaultRangeSize.
+func WithRangeSize(n int64) DownloadOption {
+	return func(d *Downloader) error {
+		if n < 0 {
+			return fmt.Errorf("range size cannot be negative")
+		}
+		d.rangeSize = n
+		return nil
+	}
+}
+
+// WithRetries sets the maximum number of attempts for each request (including the first) and the
+// delay before the first retry, which doubles on each retry. Defaults to 3 attempts and 500ms.
+func WithRetries(maxAttempts int, baseDelay time.Duration) DownloadOption {
+	return func(d *Downloader) error {
+		p := retry.Policy{MaxAttempts: maxAttempts, BaseDelay: baseDelay}.Defaults()
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		d.retry = p
+		return nil
+	}
+}
+
+// NewDownloader creates a new Downloader.
+func NewDownloader(options ...DownloadOption) (*Downloader, error) {
+	d := &Downloader{
+		client:      http.DefaultClient,
+		minValidity: DefaultMinValidity,
+		rangeSize:   DefaultRangeSize,
+		retry:       retry.Policy{}.Defaults(),
+		now:         time.Now,
+		rnd:         rand.Float64,
+	}
+	for _, o := range options {
+		if err := o(d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// DownloadResources downloads the blob in info and decodes the resources with DecodeResources(). It returns
+// ErrEncrypted for an encrypted payload, use DownloadData() for those.
+func (d *Downloader) DownloadResources(ctx context.Context, info types.ResourcesBlobInfo) (Result, error) {
+	b, err := d.Download(ctx, info.BlobURI)
+	if err != nil {
+		return Result{}, err
+	}
+	if encrypt.IsSealed(b) {
+		return Result{}, fmt.Errorf("%w, use DownloadData() to decrypt it", ErrEncrypted)
+	}
+	return DecodeResources(b)
+}
+
+// Download downloads the blob at the SAS URL blobURL and returns its content, decompressed if it is gzip-encoded.
+func (d *Downloader) Download(ctx context.Context, blobURL string) ([]byte, error) {
+	u, err := url.Parse(blobURL)
+	if err != nil {
+		return nil, fmt.Errorf("blob URL is not valid: %w", err)
+	}
+	expiry, err := sasExpiry(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	if d.rangeSize == 0 {
+		b, _, err = d.get(ctx, u, expiry, "")
+	} else {
+		b, err = d.getRanges(ctx, u, expiry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gunzip(b)
+}
+
+// getRanges downloads the blob in ranges of d.rangeSize.
+func (d *Downloader) getRanges(ctx context.Context, u *url.URL, expiry time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	for start := int64(0); ; start += d.rangeSize {
+		rng := fmt.Sprintf("bytes=%d-%d", start, start+d.rangeSize-1)
+		b, total, err := d.get(ctx, u, expiry, rng)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		// total is -1 if the server sent the whole blob.
+		if total < 0 || int64(buf.Len()) >= total {
+			return buf.Bytes(), nil
+		}
+		if len(b) == 0 {
+			return nil, fmt.Errorf("blob returned an empty range at %d of %d bytes", start, total)
+		}
+	}
+}
+
+// get does a GET of u with retries. If rng is set, it is sent as the Range header and the total size of
+// the blob is returned, or -1 if the whole blob was returned.
+func (d *Downloader) get(ctx context.Context, u *url.URL, expiry time.Time, rng string) ([]byte, int64, error) {
+	for attempt := 1; ; attempt++ {
+		if !expiry.IsZero() && d.now().Add(d.minValidity).After(expiry) {
+			return nil, 0, fmt.Errorf("%w: expires at %s", ErrSASExpired, expiry.Format(time.RFC3339))
+		}
+
+		b, total, err := d.getOnce(ctx, u, rng)
+		if err == nil || attempt >= d.retry.MaxAttempts || !isTransient(ctx, err) {
+			return b, total, err
+		}
+
+		timer := time.NewTimer(d.retry.Delay(attempt, d.rnd))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// statusError is an unexpected status code from blob storage.
+type statusError struct {
+	code int
+}
+
+func (s statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", s.code)
+}
+
+// getOnce does a single GET of u. See get().
+func (d *Downloader) getOnce(ctx context.Context, u *url.URL, rng string) ([]byte, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rng != "" {
+		req.Header.Set("Range", rng)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b, err := io.ReadAll(resp.Body)
+		return b, -1, err
+	case http.StatusPartialContent:
+		total, err := contentRangeTotal(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, 0, err
+		}
+		b, err := io.ReadAll(resp.Body)
+		return b, total, err
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The blob is empty.
+		return nil, 0, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil, 0, statusError{code: resp.StatusCode}
+}
+
+// isTransient returns true if err is worth retrying.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var se statusError
+	if errors.As(err, &se) {
+		switch se.code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return se.code >= 500
+	}
+	// Network errors and truncated bodies.
+	return true
+}
+
+// sasExpiry returns the signed expiry ("se") of the SAS in u. It returns the zero time if u has no expiry.
+func sasExpiry(u *url.URL) (time.Time, error) {
+	se := u.Query().Get("se")
+	if se == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z", "2006-01-02"} {
+		if t, err := time.Parse(layout, se); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("blob URL has an invalid SAS expiry %q", se)
+}
+
+// contentRangeTotal returns the total size from a Content-Range header like "bytes 0-99/1000".
+func contentRangeTotal(cr string) (int64, error) {
+	i := strings.LastIndexByte(cr, '/')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid Content-Range header %q", cr)
+	}
+	total, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range header %q", cr)
+	}
+	return total, nil
+}
+
+// gunzip decompresses b if it is gzip-encoded, otherwise it returns b.
+func gunzip(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		return b, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress blob: %w", err)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress blob: %w", err)
+	}
+	return out, nil
+}
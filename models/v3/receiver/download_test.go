@@ -0,0 +1,150 @@
+package receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestDownload(t *testing.T) {
+	t.Parallel()
+
+	plain := []byte(`[{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}}]` + strings.Repeat(" ", 100))
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(plain)
+	w.Close()
+
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	expiring := time.Now().Add(10 * time.Second).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		failFirst int
+		failCode  int
+		se        string
+		options   []DownloadOption
+		wantErr   bool
+		wantIs    error
+		wantCalls int32
+	}{
+		{
+			name:      "Single request",
+			content:   plain,
+			se:        valid,
+			options:   []DownloadOption{WithRangeSize(0)},
+			wantCalls: 1,
+		},
+		{
+			name:      "Range reads",
+			content:   plain,
+			se:        valid,
+			options:   []DownloadOption{WithRangeSize(16)},
+			wantCalls: int32((len(plain) + 15) / 16),
+		},
+		{
+			name:      "Gzip with range reads",
+			content:   gz.Bytes(),
+			options:   []DownloadOption{WithRangeSize(16)},
+			wantCalls: int32((gz.Len() + 15) / 16),
+		},
+		{
+			name:      "Retries transient failures",
+			content:   plain,
+			failFirst: 2,
+			failCode:  http.StatusServiceUnavailable,
+			options:   []DownloadOption{WithRetries(3, time.Millisecond)},
+			wantCalls: 3,
+		},
+		{
+			name:      "Error: does not retry permanent failures",
+			content:   plain,
+			failFirst: 1,
+			failCode:  http.StatusForbidden,
+			options:   []DownloadOption{WithRetries(3, time.Millisecond)},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "Error: SAS about to expire",
+			content:   plain,
+			se:        expiring,
+			wantErr:   true,
+			wantIs:    ErrSASExpired,
+			wantCalls: 0,
+		},
+	}
+
+	for _, test := range tests {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= int32(test.failFirst) {
+				w.WriteHeader(test.failCode)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(test.content))
+		}))
+		defer srv.Close()
+
+		u := srv.URL + "/container/blob?sig=sig"
+		if test.se != "" {
+			u += "&se=" + test.se
+		}
+
+		d, err := NewDownloader(test.options...)
+		if err != nil {
+			panic(err)
+		}
+		got, err := d.Download(context.Background(), u)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestDownload(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestDownload(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if test.wantIs != nil && !errors.Is(err, test.wantIs) {
+				t.Errorf("TestDownload(%s): got err == %s, want errors.Is(err, %s)", test.name, err, test.wantIs)
+			}
+		default:
+			if !bytes.Equal(got, plain) {
+				t.Errorf("TestDownload(%s): got %q, want %q", test.name, got, plain)
+			}
+		}
+		if calls.Load() != test.wantCalls {
+			t.Errorf("TestDownload(%s): got %d calls, want %d", test.name, calls.Load(), test.wantCalls)
+		}
+	}
+}
+
+func TestDownloadResources(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}}, {}]`))
+	}))
+	defer srv.Close()
+
+	d, err := NewDownloader()
+	if err != nil {
+		panic(err)
+	}
+	res, err := d.DownloadResources(context.Background(), types.ResourcesBlobInfo{BlobURI: srv.URL + "/c/b", BlobSize: 1})
+	if err != nil {
+		t.Fatalf("TestDownloadResources: got err == %s, want err == nil", err)
+	}
+	if len(res.Resources) != 1 || len(res.Errors) != 1 {
+		t.Errorf("TestDownloadResources: got %d resources and %d errors, want 1 and 1", len(res.Resources), len(res.Errors))
+	}
+}
@@ -0,0 +1,157 @@
+package receiver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/envelope"
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/arn-sdk/models/version"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// deliveredEvent holds the fields of an event as delivered by Event Grid in either the Event Grid schema
+// or the CloudEvents schema.
+type deliveredEvent struct {
+	// Event Grid schema fields.
+	ID              string         `json:"id"`
+	Topic           string         `json:"topic"`
+	Subject         string         `json:"subject"`
+	EventType       string         `json:"eventType"`
+	EventTime       string         `json:"eventTime"`
+	DataVersion     string         `json:"dataVersion"`
+	MetadataVersion string         `json:"metadataVersion"`
+	Data            jsontext.Value `json:"data"`
+
+	// CloudEvents schema fields.
+	SpecVersion string `json:"specversion"`
+	Source      string `json:"source"`
+	Type        string `json:"type"`
+	Time        string `json:"time"`
+	DataBase64  string `json:"data_base64"`
+}
+
+// ParseEvents parses ARN events as they are delivered by an Event Grid subscription. It accepts a single
+// event or an array of events in either the Event Grid schema or the CloudEvents schema. The event data can
+// be a JSON object, a JSON string holding the object, or base64 (in "data" or CloudEvents' "data_base64"),
+// optionally gzip-encoded. Inline resources are left in Event.Data.Data and can be decoded with DecodeResources().
+// Unknown fields are ignored, use a Decoder to warn about or reject them.
+func ParseEvents(b []byte) ([]envelope.Event, error) {
+	return Decoder{}.ParseEvents(b)
+}
+
+// ParseEvents parses ARN events as the package's ParseEvents() does, handling unknown fields in the event
+// data as set in d.Unknown.
+func (d Decoder) ParseEvents(b []byte) ([]envelope.Event, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, fmt.Errorf("payload is empty")
+	}
+
+	var raws []jsontext.Value
+	switch b[0] {
+	case '[':
+		if err := json.Unmarshal(b, &raws); err != nil {
+			return nil, fmt.Errorf("payload is not a list of events: %w", err)
+		}
+	case '{':
+		raws = []jsontext.Value{b}
+	default:
+		return nil, fmt.Errorf("payload is not an event or a list of events")
+	}
+
+	events := make([]envelope.Event, 0, len(raws))
+	for i, raw := range raws {
+		e, err := d.parseEvent(raw)
+		if err != nil {
+			return nil, fmt.Errorf("events[%d]: %w", i, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// parseEvent converts a single delivered event to an envelope.Event.
+func (d Decoder) parseEvent(raw jsontext.Value) (envelope.Event, error) {
+	var de deliveredEvent
+	if err := json.Unmarshal(raw, &de); err != nil {
+		return envelope.Event{}, err
+	}
+
+	if de.SpecVersion != "" {
+		// CloudEvents schema.
+		de.Topic = de.Source
+		de.EventType = de.Type
+		de.EventTime = de.Time
+	}
+
+	e := envelope.Event{
+		EventMeta: envelope.EventMeta{
+			Topic:           de.Topic,
+			Subject:         de.Subject,
+			EventType:       de.EventType,
+			ID:              de.ID,
+			DataVersion:     version.Schema(de.DataVersion),
+			MetadataVersion: de.MetadataVersion,
+		},
+	}
+	if de.EventTime != "" {
+		t, err := time.Parse(time.RFC3339, de.EventTime)
+		if err != nil {
+			return envelope.Event{}, fmt.Errorf("invalid event time: %w", err)
+		}
+		e.EventMeta.EventTime = t
+	}
+
+	data, err := eventData(de)
+	if err != nil {
+		return envelope.Event{}, err
+	}
+	ed, unknown, err := unmarshal[types.Data](d, data)
+	if err != nil {
+		return envelope.Event{}, fmt.Errorf("invalid event data: %w", err)
+	}
+	if unknown != nil {
+		d.logger().Warn("ARN event data has fields the SDK does not know", "id", e.EventMeta.ID, "error", unknown.Error())
+	}
+	e.Data = ed
+	return e, nil
+}
+
+// eventData returns the JSON object of the event's data, undoing any string, base64 or gzip encoding.
+func eventData(de deliveredEvent) ([]byte, error) {
+	if de.DataBase64 != "" {
+		return decodeBase64(de.DataBase64)
+	}
+	if len(de.Data) == 0 {
+		return nil, fmt.Errorf("event has no data")
+	}
+
+	switch de.Data.Kind() {
+	case '{':
+		return de.Data, nil
+	case '"':
+		var s string
+		if err := json.Unmarshal(de.Data, &s); err != nil {
+			return nil, fmt.Errorf("invalid event data: %w", err)
+		}
+		if b := bytes.TrimSpace([]byte(s)); len(b) > 0 && b[0] == '{' {
+			return b, nil
+		}
+		return decodeBase64(s)
+	}
+	return nil, fmt.Errorf("event data must be an object, a string or base64, got %s", de.Data.Kind())
+}
+
+// decodeBase64 decodes base64 event data and decompresses it if it is gzip-encoded.
+func decodeBase64(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("event data is not valid base64: %w", err)
+	}
+	return gunzip(b)
+}
@@ -0,0 +1,85 @@
+package receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+	"github.com/Azure/arn-sdk/models/version"
+)
+
+func TestParseEvents(t *testing.T) {
+	t.Parallel()
+
+	data := `{"resources": [{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}}], "resourcesContainer": "inline", "resourceLocation": "eastus", "publisherInfo": "Microsoft.ContainerService", "additionalBatchProperties": {"sdkVersion": "golang@0.1.0", "batchSize": 1}}`
+	b64 := base64.StdEncoding.EncodeToString([]byte(data))
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(data))
+	w.Close()
+	gzB64 := base64.StdEncoding.EncodeToString(gz.Bytes())
+
+	egEvent := func(d string) string {
+		return `{"id": "1", "topic": "t", "subject": "/subscriptions/s", "eventType": "Microsoft.ContainerService/managedClusters/write", "eventTime": "2024-01-01T00:00:00.123Z", "dataVersion": "3.0", "metadataVersion": "1", "data": ` + d + `}`
+	}
+
+	tests := []struct {
+		name       string
+		payload    string
+		wantErr    bool
+		wantEvents int
+	}{
+		{name: "Single Event Grid event", payload: egEvent(data), wantEvents: 1},
+		{name: "Array of Event Grid events", payload: "[" + egEvent(data) + "," + egEvent(data) + "]", wantEvents: 2},
+		{name: "Data as a JSON string", payload: egEvent(strconv.Quote(data)), wantEvents: 1},
+		{name: "Data as base64", payload: egEvent(strconv.Quote(b64)), wantEvents: 1},
+		{name: "Data as gzip base64", payload: egEvent(strconv.Quote(gzB64)), wantEvents: 1},
+		{
+			name:       "CloudEvents with data_base64",
+			payload:    `[{"specversion": "1.0", "id": "1", "source": "t", "subject": "/subscriptions/s", "type": "Microsoft.ContainerService/managedClusters/write", "time": "2024-01-01T00:00:00Z", "dataVersion": "3.0", "data_base64": "` + b64 + `"}]`,
+			wantEvents: 1,
+		},
+		{name: "Error: not an event", payload: `"hello"`, wantErr: true},
+		{name: "Error: empty", payload: ``, wantErr: true},
+		{name: "Error: bad base64", payload: egEvent(`"!!!"`), wantErr: true},
+		{name: "Error: no data", payload: `{"id": "1"}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		events, err := ParseEvents([]byte(test.payload))
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseEvents(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseEvents(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if len(events) != test.wantEvents {
+			t.Errorf("TestParseEvents(%s): got %d events, want %d", test.name, len(events), test.wantEvents)
+			continue
+		}
+		for _, e := range events {
+			if e.EventMeta.EventType != "Microsoft.ContainerService/managedClusters/write" {
+				t.Errorf("TestParseEvents(%s): got EventType %q", test.name, e.EventMeta.EventType)
+			}
+			if e.EventMeta.DataVersion != version.V3 {
+				t.Errorf("TestParseEvents(%s): got DataVersion %q, want %q", test.name, e.EventMeta.DataVersion, version.V3)
+			}
+			if e.Data.ResourcesContainer != types.RCInline {
+				t.Errorf("TestParseEvents(%s): got ResourcesContainer %v, want %v", test.name, e.Data.ResourcesContainer, types.RCInline)
+			}
+			res, err := DecodeResources(e.Data.Data)
+			if err != nil || len(res.Resources) != 1 {
+				t.Errorf("TestParseEvents(%s): could not decode inline resources: %v %v", test.name, err, res.Err())
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Package receiver provides helpers for services that consume ARN v3 notifications, as opposed to publishing them.
+
+When a notification is too large to send inline, the resources are stored in a blob and the event only holds
+the blob's location. DecodeResources() decodes the resources from a downloaded blob (or from an inline event's
+"resources") and validates each resource on its own. A bad resource is reported with its index so that it can
+be quarantined, instead of failing every other resource in the blob.
+
+Events that are consumed through an Event Grid subscription are wrapped by Event Grid. ParseEvents() removes
+the delivery wrapper and returns the ARN events. Blobs are downloaded with a Downloader, which handles SAS
+expiry, retries, range reads and gzip-encoded payloads. Payloads that the publisher encrypted with
+client.WithBlobEncryption() are decrypted by Downloader.DownloadData() with a KeyUnwrapper, such as a KeyVault.
+
+Fields that the SDK's types do not have are ignored. To find out when ARN starts sending new schema fields,
+use a Decoder with WarnUnknown or RejectUnknown.
+
+Usage:
+
+	res, err := receiver.DecodeResources(blob)
+	if err != nil {
+		// The payload is not a list of resources at all.
+		return err
+	}
+	for _, ie := range res.Errors {
+		quarantine(ie.Index, ie.Raw, ie.Err)
+	}
+	for _, r := range res.Resources {
+		process(r)
+	}
+*/
+package receiver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// ItemError is a problem with a single resource in a payload.
+type ItemError struct {
+	// Index is the index of the resource in the payload.
+	Index int
+	// ResourceID is the ResourceID of the resource, if it could be decoded.
+	ResourceID string
+	// Raw is the JSON of the resource, which can be used to quarantine it.
+	Raw jsontext.Value
+	// Err is the problem with the resource.
+	Err error
+}
+
+// Error implements error.
+func (e ItemError) Error() string {
+	if e.ResourceID == "" {
+		return fmt.Sprintf("resources[%d]: %s", e.Index, e.Err)
+	}
+	return fmt.Sprintf("resources[%d](%s): %s", e.Index, e.ResourceID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+// Result is the result of decoding a payload.
+type Result struct {
+	// Resources are the resources that were decoded and are valid, in payload order.
+	Resources []types.NotificationResource
+	// Indexes is the index in the payload of each entry in Resources.
+	Indexes []int
+	// Errors are the resources that could not be decoded or are invalid.
+	Errors []ItemError
+	// Warnings are the resources that were decoded with unknown fields ignored, when the Decoder's Unknown
+	// is WarnUnknown. These resources are also in Resources.
+	Warnings []ItemError
+}
+
+// Err returns all the item errors joined together, or nil if there are none.
+func (r Result) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
+
+// DecodeResources decodes a JSON list of v3 resources, which is the content of a blob payload or the
+// "resources" field of an inline event. Each resource is decoded and validated separately and any
+// problems are reported in Result.Errors. An error is only returned if b is not a JSON list. Unknown fields
+// are ignored, use a Decoder to warn about or reject them.
+func DecodeResources(b []byte) (Result, error) {
+	return Decoder{}.DecodeResources(b)
+}
+
+// DecodeResources decodes a JSON list of v3 resources as the package's DecodeResources() does, handling
+// unknown fields as set in d.Unknown. For WarnUnknown, one warning is logged for all the resources in b.
+func (d Decoder) DecodeResources(b []byte) (Result, error) {
+	var raws []jsontext.Value
+	if err := json.Unmarshal(b, &raws); err != nil {
+		return Result{}, fmt.Errorf("payload is not a list of resources: %w", err)
+	}
+
+	res := Result{
+		Resources: make([]types.NotificationResource, 0, len(raws)),
+		Indexes:   make([]int, 0, len(raws)),
+	}
+	for i, raw := range raws {
+		r, unknown, err := unmarshal[types.NotificationResource](d, raw)
+		if err != nil {
+			res.Errors = append(res.Errors, ItemError{Index: i, ResourceID: r.ResourceID, Raw: raw, Err: err})
+			continue
+		}
+		if err := Validate(r); err != nil {
+			res.Errors = append(res.Errors, ItemError{Index: i, ResourceID: r.ResourceID, Raw: raw, Err: err})
+			continue
+		}
+		if unknown != nil {
+			res.Warnings = append(res.Warnings, ItemError{Index: i, ResourceID: r.ResourceID, Raw: raw, Err: unknown})
+		}
+		res.Resources = append(res.Resources, r)
+		res.Indexes = append(res.Indexes, i)
+	}
+	if len(res.Warnings) > 0 {
+		d.logger().Warn(
+			"ARN resources have fields the SDK does not know",
+			"count", len(res.Warnings),
+			"resources", len(raws),
+			"first", res.Warnings[0].Error(),
+		)
+	}
+	return res, nil
+}
+
+// Validate validates a resource that was received. This differs from types.NotificationResource.Validate(),
+// which validates resources before they are sent and relies on information that is not on the wire.
+func Validate(r types.NotificationResource) error {
+	if r.ResourceID == "" {
+		return errors.New(".ResourceID is required")
+	}
+	if r.StatusCode != "" && r.StatusCode != types.StatusCode {
+		return fmt.Errorf(".StatusCode(%s) is not %s", r.StatusCode, types.StatusCode)
+	}
+	if r.ArmResource != (types.ArmResource{}) && r.ArmResource.ID == "" {
+		return errors.New(".ArmResource.ID is required")
+	}
+	if err := r.ResourceSystemProperties.Validate(); err != nil {
+		return fmt.Errorf(".ResourceSystemProperties%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package receiver
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models/v3/schema/types"
+)
+
+func TestDecodeResources(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		payload     string
+		wantErr     bool
+		wantIndexes []int
+		wantErrIdx  []int
+	}{
+		{
+			name:    "Error: payload is not a list",
+			payload: `{"resourceId": "id"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Empty list",
+			payload: `[]`,
+		},
+		{
+			name: "All valid",
+			payload: `[
+				{"resourceId": "a", "statusCode": "OK", "armResource": {"id": "a", "properties": {"id": "a"}}, "resourceSystemProperties": {"changeAction": "Create"}},
+				{"resourceId": "b", "resourceSystemProperties": {"changeAction": "delete"}}
+			]`,
+			wantIndexes: []int{0, 1},
+		},
+		{
+			name: "Bad items are reported with their index",
+			payload: `[
+				{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Create"}},
+				{"resourceId": "b", "resourceSystemProperties": {"changeAction": "Explode"}},
+				{"resourceSystemProperties": {"changeAction": "Create"}},
+				{"resourceId": "d", "resourceEventTime": "yesterday", "resourceSystemProperties": {"changeAction": "Create"}},
+				{"resourceId": "e", "resourceSystemProperties": {}},
+				{"resourceId": "f", "armResource": {"name": "f"}, "resourceSystemProperties": {"changeAction": "Update"}},
+				"not an object",
+				{"resourceId": "h", "resourceSystemProperties": {"changeAction": "Move"}}
+			]`,
+			wantIndexes: []int{0, 7},
+			wantErrIdx:  []int{1, 2, 3, 4, 5, 6},
+		},
+	}
+
+	for _, test := range tests {
+		res, err := DecodeResources([]byte(test.payload))
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestDecodeResources(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestDecodeResources(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if !slices.Equal(res.Indexes, test.wantIndexes) {
+			t.Errorf("TestDecodeResources(%s): got indexes %v, want %v", test.name, res.Indexes, test.wantIndexes)
+		}
+		if len(res.Resources) != len(res.Indexes) {
+			t.Errorf("TestDecodeResources(%s): got %d resources for %d indexes", test.name, len(res.Resources), len(res.Indexes))
+		}
+		var gotErrIdx []int
+		for _, ie := range res.Errors {
+			gotErrIdx = append(gotErrIdx, ie.Index)
+			if len(ie.Raw) == 0 {
+				t.Errorf("TestDecodeResources(%s): item error %d has no raw JSON", test.name, ie.Index)
+			}
+		}
+		if !slices.Equal(gotErrIdx, test.wantErrIdx) {
+			t.Errorf("TestDecodeResources(%s): got error indexes %v, want %v", test.name, gotErrIdx, test.wantErrIdx)
+		}
+		if (res.Err() != nil) != (len(test.wantErrIdx) > 0) {
+			t.Errorf("TestDecodeResources(%s): got Err() == %v, want error: %v", test.name, res.Err(), len(test.wantErrIdx) > 0)
+		}
+	}
+}
+
+func TestDecodeResourcesEnums(t *testing.T) {
+	t.Parallel()
+
+	res, err := DecodeResources([]byte(`[{"resourceId": "a", "resourceSystemProperties": {"changeAction": "Update"}}]`))
+	if err != nil {
+		t.Fatalf("TestDecodeResourcesEnums: got err == %s, want err == nil", err)
+	}
+	if len(res.Resources) != 1 {
+		t.Fatalf("TestDecodeResourcesEnums: got %d resources, want 1: %v", len(res.Resources), res.Err())
+	}
+	if got := res.Resources[0].ResourceSystemProperties.ChangeAction; got != types.CAUpdate {
+		t.Errorf("TestDecodeResourcesEnums: got ChangeAction %v, want %v", got, types.CAUpdate)
+	}
+}
+
+func TestItemErrorUnwrap(t *testing.T) {
+	t.Parallel()
+
+	base := errors.New("base")
+	err := error(ItemError{Index: 1, ResourceID: "id", Err: base})
+	if !errors.Is(err, base) {
+		t.Errorf("TestItemErrorUnwrap: got errors.Is() == false, want true")
+	}
+}
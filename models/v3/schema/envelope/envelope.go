@@ -25,7 +25,12 @@ func (e Event) IsEvent() {}
 
 // Validate validates the event.
 func (e Event) Validate() error {
-	if err := e.EventMeta.Validate(); err != nil {
+	return e.ValidatePreview(PreviewVersions{})
+}
+
+// ValidatePreview validates the event like Validate(), but also allows the versions in p.
+func (e Event) ValidatePreview(p PreviewVersions) error {
+	if err := e.EventMeta.ValidatePreview(p); err != nil {
 		return fmt.Errorf("Event.EventMeta: %w", err)
 	}
 	if err := e.Data.Validate(); err != nil {
@@ -55,16 +60,21 @@ type EventMeta struct {
 	EventTime time.Time `json:"eventTime" format:"RFC3339"`
 	// ID is the GUID of the event. This is set automatically.
 	ID string `json:"id"`
-	// DataVersion is the schema version. In this case it should always be 3.0 .
-	// This is automatically set.
+	// DataVersion is the schema version. In this case it should always be 3.0, unless a preview
+	// is allowed, see PreviewVersions. This is automatically set.
 	DataVersion version.Schema `json:"dataVersion"`
-	// The Metadata version of this event notification. For the moment, should always be 1.0 .
-	// This is automatically set.
+	// The Metadata version of this event notification. For the moment, should always be 1.0, unless a
+	// preview is allowed, see PreviewVersions. This is automatically set.
 	MetadataVersion string `json:"metadataVersion"`
 }
 
 // Validate validates the event metadata.
 func (e EventMeta) Validate() error {
+	return e.ValidatePreview(PreviewVersions{})
+}
+
+// ValidatePreview validates the event metadata like Validate(), but also allows the versions in p.
+func (e EventMeta) ValidatePreview(p PreviewVersions) error {
 	if e.Subject == "" {
 		return errors.New("EventMeta.Subject is required")
 	}
@@ -78,11 +88,11 @@ func (e EventMeta) Validate() error {
 	if e.ID == "" {
 		return errors.New("EventMeta.ID is required")
 	}
-	if e.DataVersion != version.V3 {
-		return fmt.Errorf("EventMeta.DataVersion must be %s", version.V3)
+	if !p.allowedDataVersion(e.DataVersion) {
+		return fmt.Errorf("EventMeta.DataVersion must be %s or an allowed preview version, was %q", version.V3, e.DataVersion)
 	}
-	if e.MetadataVersion != "1.0" {
-		return errors.New("EventMeta.MetadataVersion must be 1")
+	if !p.allowedMetadataVersion(e.MetadataVersion) {
+		return fmt.Errorf("EventMeta.MetadataVersion must be %s or an allowed preview version, was %q", MetadataVersion, e.MetadataVersion)
 	}
 	return nil
 }
@@ -0,0 +1,39 @@
+package envelope
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/Azure/arn-sdk/models/version"
+)
+
+// MetadataVersion is the EventMeta.MetadataVersion that ARN accepts.
+const MetadataVersion = "1.0"
+
+// PreviewVersions are versions other than MetadataVersion and version.V3 that an event may use, which is how
+// a publisher trials a receiver preview that ARN has asked it to. Only the versions listed are allowed, any
+// other version still fails validation. See Event.ValidatePreview() and client.WithPreviewVersions().
+type PreviewVersions struct {
+	// MetadataVersions are the allowed EventMeta.MetadataVersion values, like "1.1-preview".
+	MetadataVersions []string
+	// DataVersions are the allowed EventMeta.DataVersion values.
+	DataVersions []version.Schema
+}
+
+// Validate returns an error if p lists an empty version.
+func (p PreviewVersions) Validate() error {
+	if slices.Contains(p.MetadataVersions, "") || slices.Contains(p.DataVersions, "") {
+		return errors.New("preview versions cannot be empty")
+	}
+	return nil
+}
+
+// allowedMetadataVersion returns true if v is MetadataVersion or a preview in p.
+func (p PreviewVersions) allowedMetadataVersion(v string) bool {
+	return v == MetadataVersion || slices.Contains(p.MetadataVersions, v)
+}
+
+// allowedDataVersion returns true if v is version.V3 or a preview in p.
+func (p PreviewVersions) allowedDataVersion(v version.Schema) bool {
+	return v == version.V3 || slices.Contains(p.DataVersions, v)
+}
@@ -0,0 +1,53 @@
+package envelope
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/arn-sdk/models/version"
+)
+
+func TestValidatePreview(t *testing.T) {
+	t.Parallel()
+
+	const (
+		previewMeta = "1.1-preview"
+		previewData = version.Schema("3.1-preview")
+	)
+	meta := func(m string, d version.Schema) EventMeta {
+		return EventMeta{Subject: "subject", EventType: "eventType", EventTime: time.Now(), ID: "id", DataVersion: d, MetadataVersion: m}
+	}
+	p := PreviewVersions{MetadataVersions: []string{previewMeta}, DataVersions: []version.Schema{previewData}}
+
+	tests := []struct {
+		name    string
+		meta    EventMeta
+		p       PreviewVersions
+		wantErr bool
+	}{
+		{name: "Defaults", meta: meta(MetadataVersion, version.V3)},
+		{name: "Defaults with previews", meta: meta(MetadataVersion, version.V3), p: p},
+		{name: "Preview metadata", meta: meta(previewMeta, version.V3), p: p},
+		{name: "Preview data", meta: meta(MetadataVersion, previewData), p: p},
+		{name: "Error: preview not allowed", meta: meta(previewMeta, version.V3), wantErr: true},
+		{name: "Error: metadata not in allowlist", meta: meta("1.2-preview", version.V3), p: p, wantErr: true},
+		{name: "Error: data not in allowlist", meta: meta(MetadataVersion, "3.2-preview"), p: p, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.meta.ValidatePreview(test.p)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestValidatePreview(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestValidatePreview(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+
+	if err := meta(previewMeta, version.V3).Validate(); err == nil {
+		t.Errorf("TestValidatePreview(Validate() with a preview): got err == nil, want err != nil")
+	}
+	if err := (PreviewVersions{MetadataVersions: []string{""}}).Validate(); err == nil {
+		t.Errorf("TestValidatePreview(empty version): got err == nil, want err != nil")
+	}
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// apiVersions holds the registered API versions by lowercased resource type.
+var apiVersions sync.Map // map[string][]string
+
+// RegisterAPIVersions registers the API versions that are valid for a resource type, like
+// "Microsoft.ContainerService/managedClusters". Once a resource type has versions registered, validation
+// rejects any other APIVersion for it, which catches accidentally sending an unreleased version. Resource
+// types without registered versions accept any APIVersion. Registering a resource type again replaces its
+// versions. Registering no versions removes the registration. Thread-safe.
+func RegisterAPIVersions(rscType string, versions ...string) {
+	key := strings.ToLower(rscType)
+	if len(versions) == 0 {
+		apiVersions.Delete(key)
+		return
+	}
+	v := slices.Clone(versions)
+	slices.SortFunc(v, func(a, b string) int { return CompareAPIVersions(b, a) })
+	apiVersions.Store(key, slices.Compact(v))
+}
+
+// APIVersions returns the API versions registered for a resource type, newest first. It returns nil if
+// none are registered.
+func APIVersions(rscType string) []string {
+	v, ok := apiVersions.Load(strings.ToLower(rscType))
+	if !ok {
+		return nil
+	}
+	return slices.Clone(v.([]string))
+}
+
+// checkAPIVersion returns an error if versions are registered for rscType and apiVersion is not one of them.
+func checkAPIVersion(rscType, apiVersion string) error {
+	v, ok := apiVersions.Load(strings.ToLower(rscType))
+	if !ok {
+		return nil
+	}
+	if !slices.Contains(v.([]string), apiVersion) {
+		return fmt.Errorf("APIVersion(%s) is not registered for resource type %s, registered versions are %v", apiVersion, rscType, v)
+	}
+	return nil
+}
+
+// NewestAPIVersion returns the newest of candidates that is valid for rscType. Use this to pick the version
+// to send when a resource can be rendered in several versions, such as a Data-level APIVersion and the
+// resource's own. If no versions are registered for rscType, all candidates are valid. It returns an error
+// if no candidate is valid.
+func NewestAPIVersion(rscType string, candidates ...string) (string, error) {
+	newest := ""
+	for _, c := range candidates {
+		if c == "" || checkAPIVersion(rscType, c) != nil {
+			continue
+		}
+		if newest == "" || CompareAPIVersions(c, newest) > 0 {
+			newest = c
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("none of the APIVersions %v are valid for resource type %s", candidates, rscType)
+	}
+	return newest, nil
+}
+
+// CompareAPIVersions compares ARM API versions, like "2024-01-01" and "2024-01-01-preview", returning -1 if
+// a is older than b, 0 if they are the same and 1 if a is newer. Versions are ordered by date. For the same
+// date, a stable version is newer than one with a suffix like "-preview". Versions that are not in the
+// date format are compared as strings.
+func CompareAPIVersions(a, b string) int {
+	aDate, aSuffix := splitAPIVersion(a)
+	bDate, bSuffix := splitAPIVersion(b)
+	if c := strings.Compare(aDate, bDate); c != 0 {
+		return c
+	}
+	switch {
+	case aSuffix == bSuffix:
+		return 0
+	case aSuffix == "":
+		return 1
+	case bSuffix == "":
+		return -1
+	}
+	return strings.Compare(aSuffix, bSuffix)
+}
+
+// splitAPIVersion splits an API version into its date and suffix. "2024-01-01-preview" returns
+// "2024-01-01" and "preview".
+func splitAPIVersion(v string) (date, suffix string) {
+	const dateLen = len("2006-01-02")
+	if len(v) > dateLen && v[dateLen] == '-' {
+		return v[:dateLen], v[dateLen+1:]
+	}
+	return v, ""
+}
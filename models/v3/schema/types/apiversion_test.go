@@ -0,0 +1,92 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+func TestCompareAPIVersions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2024-01-01", "2024-01-01", 0},
+		{"2024-02-01", "2024-01-01", 1},
+		{"2023-12-01", "2024-01-01", -1},
+		{"2024-01-01", "2024-01-01-preview", 1},
+		{"2024-01-01-preview", "2024-01-01", -1},
+		{"2024-02-01-preview", "2024-01-01", 1},
+		{"2024-01-01-beta", "2024-01-01-preview", -1},
+	}
+
+	for _, test := range tests {
+		if got := CompareAPIVersions(test.a, test.b); got != test.want {
+			t.Errorf("TestCompareAPIVersions(%s, %s): got %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestRegisterAPIVersions(t *testing.T) {
+	t.Parallel()
+
+	// This uses a resource type no other test uses, as the registry is global.
+	const rscType = "Microsoft.Test/apiVersionTests"
+	RegisterAPIVersions(rscType, "2023-01-01", "2024-01-01", "2024-06-01-preview", "2024-01-01")
+	defer RegisterAPIVersions(rscType)
+
+	if got, want := APIVersions("microsoft.test/APIVERSIONTESTS"), []string{"2024-06-01-preview", "2024-01-01", "2023-01-01"}; !slices.Equal(got, want) {
+		t.Errorf("TestRegisterAPIVersions: APIVersions(): got %v, want %v", got, want)
+	}
+
+	id, err := arm.ParseResourceID("/subscriptions/26fe00f8-9173-4872-9134-bb1d2e00343a/resourceGroups/rg/providers/Microsoft.Test/apiVersionTests/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		apiVersion string
+		wantErr    bool
+	}{
+		{name: "Registered", apiVersion: "2024-01-01"},
+		{name: "Registered preview", apiVersion: "2024-06-01-preview"},
+		{name: "Error: unregistered", apiVersion: "2025-01-01", wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewArmResource(ActWrite, id, test.apiVersion, map[string]any{"key": "value"})
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestRegisterAPIVersions(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestRegisterAPIVersions(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+
+	d := Data{APIVersion: "2025-01-01", ResourcesContainer: RCInline, Resources: []NotificationResource{
+		{
+			ResourceID:               id.String(),
+			StatusCode:               StatusCode,
+			ResourceSystemProperties: ResourceSystemProperties{ChangeAction: CAUpdate},
+			ArmResource:              ArmResource{ID: id.String(), Type: id.ResourceType.String(), Properties: map[string]any{}, arm: id, act: ActWrite},
+		},
+	}}
+	if err := d.Validate(); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("TestRegisterAPIVersions(Data.APIVersion unregistered): got err == %v, want a not registered error", err)
+	}
+
+	if got, err := NewestAPIVersion(rscType, "2023-01-01", "2025-01-01", "2024-01-01"); err != nil || got != "2024-01-01" {
+		t.Errorf("TestRegisterAPIVersions: NewestAPIVersion(): got %q, %v, want 2024-01-01", got, err)
+	}
+	if _, err := NewestAPIVersion(rscType, "2025-01-01"); err == nil {
+		t.Errorf("TestRegisterAPIVersions: NewestAPIVersion(no valid version): got err == nil, want err != nil")
+	}
+	if got, _ := NewestAPIVersion("Microsoft.Test/unregistered", "2023-01-01", "2025-01-01"); got != "2025-01-01" {
+		t.Errorf("TestRegisterAPIVersions: NewestAPIVersion(unregistered type): got %q, want 2025-01-01", got)
+	}
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ClassTag is the struct tag that sets the Classification of a field in an ArmResource.Properties type, such
+// as `arnclass:"euii"`. The field can be nested in structs, slices and maps of the Properties type. A struct
+// type that contains itself only has the fields of its outermost value classified.
+const ClassTag = "arnclass"
+
+// Classification is a data classification of a field in ArmResource.Properties, such as "euii" for end user
+// identifiable information. The classifications are chosen by the publisher and enforced with a policy set
+// on the client.
+type Classification string
+
+// ClassifiedField is a field of an ArmResource.Properties type that has a Classification.
+type ClassifiedField struct {
+	// Path is the JSON member names from the top of the properties to the field. "*" is every element of
+	// an array or every member of an object.
+	Path []string
+	// Classification is the classification of the field.
+	Classification Classification
+}
+
+// String implements fmt.Stringer.
+func (f ClassifiedField) String() string {
+	return fmt.Sprintf("%s(%s)", strings.Join(f.Path, "."), f.Classification)
+}
+
+// registeredClasses holds the classifications registered by the type they apply to.
+var registeredClasses sync.Map // map[reflect.Type][]ClassifiedField
+
+// taggedClasses caches the classifications found in the struct tags of a type.
+var taggedClasses sync.Map // map[reflect.Type][]ClassifiedField
+
+// RegisterClassifications registers the classifications of fields in ArmResource.Properties values of type
+// T. This is for types that cannot have ClassTag struct tags, such as map[string]any. fields is keyed by the
+// path to the field, the JSON member names joined by ".", with "*" for every element of an array or every
+// member of an object, such as "owner.email" or "contacts.*.phone". These are added to the classifications
+// in the struct tags of T. Calling this again for T replaces its registered classifications, calling it with
+// no fields removes them. Thread-safe.
+func RegisterClassifications[T any](fields map[string]Classification) error {
+	t := reflect.TypeFor[T]()
+	if len(fields) == 0 {
+		registeredClasses.Delete(t)
+		return nil
+	}
+	var out []ClassifiedField
+	for path, c := range fields {
+		if c == "" {
+			return fmt.Errorf("classification of %q cannot be empty", path)
+		}
+		p := strings.Split(path, ".")
+		if slices.Contains(p, "") {
+			return fmt.Errorf("path %q has an empty member name", path)
+		}
+		out = append(out, ClassifiedField{Path: p, Classification: c})
+	}
+	sortFields(out)
+	registeredClasses.Store(t, out)
+	return nil
+}
+
+// ClassifiedFields returns the fields of props, an ArmResource.Properties value, that have a
+// Classification, from its struct tags and RegisterClassifications().
+func ClassifiedFields(props any) []ClassifiedField {
+	if props == nil {
+		return nil
+	}
+	t := reflect.TypeOf(props)
+
+	var out []ClassifiedField
+	if v, ok := taggedClasses.Load(t); ok {
+		out = v.([]ClassifiedField)
+	} else {
+		out = tagged(t, nil, nil)
+		sortFields(out)
+		taggedClasses.Store(t, out)
+	}
+	if v, ok := registeredClasses.Load(t); ok {
+		out = append(out[:len(out):len(out)], v.([]ClassifiedField)...)
+	}
+	return out
+}
+
+// tagged returns the fields of t that have a ClassTag, with path prepended to their paths. seen are the
+// types of the structs t is in, which stops recursive types.
+func tagged(t reflect.Type, path []string, seen []reflect.Type) []ClassifiedField {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return tagged(t.Elem(), path, seen)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return tagged(t.Elem(), append(slices.Clip(path), "*"), seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if slices.Contains(seen, t) {
+		return nil
+	}
+	seen = append(slices.Clip(seen), t)
+
+	var out []ClassifiedField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, inline := jsonName(f)
+		// The fields of an unexported embedded struct are marshaled if it is inlined.
+		if name == "-" || (!f.IsExported() && !(f.Anonymous && inline)) {
+			continue
+		}
+		p := path
+		if !inline {
+			p = append(slices.Clip(path), name)
+		}
+		if c := f.Tag.Get(ClassTag); c != "" && !inline {
+			out = append(out, ClassifiedField{Path: p, Classification: Classification(c)})
+			continue
+		}
+		out = append(out, tagged(f.Type, p, seen)...)
+	}
+	return out
+}
+
+// jsonName returns the JSON member name of f and if its members are inlined into its parent.
+func jsonName(f reflect.StructField) (name string, inline bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "-", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	for _, o := range strings.Split(opts, ",") {
+		if o == "inline" {
+			return "", true
+		}
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return strings.Trim(name, "'"), false
+}
+
+// sortFields sorts fields by path, so they are in the same order each time.
+func sortFields(fields []ClassifiedField) {
+	slices.SortFunc(fields, func(a, b ClassifiedField) int {
+		return slices.Compare(a.Path, b.Path)
+	})
+}
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type classContact struct {
+	Email string `json:"email" arnclass:"euii"`
+	Phone string `arnclass:"euii"`
+	Team  string `json:"team"`
+}
+
+type classInline struct {
+	Secret string `json:"secret" arnclass:"secret"`
+}
+
+type classProps struct {
+	Owner       classContact            `json:"owner"`
+	Contacts    []*classContact         `json:"contacts"`
+	ByName      map[string]classContact `json:"byName"`
+	Ignored     string                  `json:"-" arnclass:"euii"`
+	Parent      *classProps             `json:"parent"`
+	Note        string                  `json:"note,omitzero" arnclass:"customer"`
+	classInline `json:",inline"`
+
+	hidden string `arnclass:"euii"`
+}
+
+func TestClassifiedFields(t *testing.T) {
+	t.Parallel()
+
+	paths := func(fields []ClassifiedField) []string {
+		var out []string
+		for _, f := range fields {
+			out = append(out, f.String())
+		}
+		return out
+	}
+
+	got := paths(ClassifiedFields(classProps{}))
+	want := []string{
+		"byName.*.Phone(euii)",
+		"byName.*.email(euii)",
+		"contacts.*.Phone(euii)",
+		"contacts.*.email(euii)",
+		"note(customer)",
+		"owner.Phone(euii)",
+		"owner.email(euii)",
+		"secret(secret)",
+	}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestClassifiedFields: struct tags: -want/+got:\n%s", diff)
+	}
+	if got := paths(ClassifiedFields(&classProps{})); len(got) != len(want) {
+		t.Errorf("TestClassifiedFields: pointer: got %v, want the same fields as the struct", got)
+	}
+	if got := ClassifiedFields(nil); got != nil {
+		t.Errorf("TestClassifiedFields: nil: got %v, want nil", got)
+	}
+
+	type mapProps map[string]any
+	if err := RegisterClassifications[mapProps](map[string]Classification{"owner.email": "euii", "tags.*": "customer"}); err != nil {
+		t.Fatalf("TestClassifiedFields: RegisterClassifications(): got err == %s, want err == nil", err)
+	}
+	got = paths(ClassifiedFields(mapProps{}))
+	want = []string{"owner.email(euii)", "tags.*(customer)"}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestClassifiedFields: registered: -want/+got:\n%s", diff)
+	}
+	if err := RegisterClassifications[mapProps](nil); err != nil {
+		t.Fatalf("TestClassifiedFields: RegisterClassifications(nil): got err == %s, want err == nil", err)
+	}
+	if got := ClassifiedFields(mapProps{}); len(got) != 0 {
+		t.Errorf("TestClassifiedFields: after removing the registration: got %v, want none", got)
+	}
+
+	for _, bad := range []map[string]Classification{{"a..b": "euii"}, {"a": ""}} {
+		if err := RegisterClassifications[mapProps](bad); err == nil {
+			t.Errorf("TestClassifiedFields: RegisterClassifications(%v): got err == nil, want err != nil", bad)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+)
+
+// copiers holds the registered copiers by the type they copy.
+var copiers sync.Map // map[reflect.Type]func(any) any
+
+// RegisterCopier registers a function that deep copies values of type T. This is used by Clone() methods
+// to copy ArmResource.Properties and AdditionalBatchProperties.Others values of type T. Without a
+// registered copier, values are copied by marshaling them to JSON and back, which only keeps exported
+// fields and is slower. Registering a copier for a type that already has one replaces it. Thread-safe.
+func RegisterCopier[T any](copier func(T) T) {
+	copiers.Store(reflect.TypeFor[T](), func(v any) any { return copier(v.(T)) })
+}
+
+// deepCopy returns a deep copy of v. map[string]any and []any (which is what you get from decoding JSON)
+// are copied directly. Other types use a registered copier or are copied through JSON.
+func deepCopy(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	t := reflect.TypeOf(v)
+	if c, ok := copiers.Load(t); ok {
+		return c.(func(any) any)(v), nil
+	}
+
+	switch x := v.(type) {
+	case string, bool, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return x, nil
+	case map[string]string:
+		return maps.Clone(x), nil
+	case map[string]any:
+		if x == nil {
+			return x, nil
+		}
+		m := make(map[string]any, len(x))
+		for k, val := range x {
+			c, err := deepCopy(val)
+			if err != nil {
+				return nil, fmt.Errorf("[%q]: %w", k, err)
+			}
+			m[k] = c
+		}
+		return m, nil
+	case []any:
+		if x == nil {
+			return x, nil
+		}
+		s := make([]any, len(x))
+		for i, val := range x {
+			c, err := deepCopy(val)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			s[i] = c
+		}
+		return s, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal %T to copy it: %w", v, err)
+	}
+	if t.Kind() == reflect.Pointer {
+		p := reflect.New(t.Elem())
+		if err := json.Unmarshal(b, p.Interface()); err != nil {
+			return nil, fmt.Errorf("could not unmarshal %T to copy it: %w", v, err)
+		}
+		return p.Interface(), nil
+	}
+	p := reflect.New(t)
+	if err := json.Unmarshal(b, p.Interface()); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %T to copy it: %w", v, err)
+	}
+	return p.Elem().Interface(), nil
+}
+
+// Clone returns a deep copy of the AdditionalBatchProperties. Values in Others are copied as
+// described in RegisterCopier().
+func (a AdditionalBatchProperties) Clone() (AdditionalBatchProperties, error) {
+	if a.Others == nil {
+		return a, nil
+	}
+	others, err := deepCopy(a.Others)
+	if err != nil {
+		return AdditionalBatchProperties{}, fmt.Errorf(".Others: %w", err)
+	}
+	a.Others = others.(map[string]any)
+	return a, nil
+}
+
+// Clone returns a deep copy of the ArmResource. Properties are copied as described in RegisterCopier().
+func (a ArmResource) Clone() (ArmResource, error) {
+	props, err := deepCopy(a.Properties)
+	if err != nil {
+		return ArmResource{}, fmt.Errorf(".Properties: %w", err)
+	}
+	a.Properties = props
+	if a.arm != nil {
+		id := *a.arm
+		a.arm = &id
+	}
+	return a, nil
+}
+
+// Clone returns a deep copy of the NotificationResource. This is needed when a NotificationResource must
+// be kept after the caller may change structures it shares, like maps or ArmResource.Properties.
+func (n NotificationResource) Clone() (NotificationResource, error) {
+	arm, err := n.ArmResource.Clone()
+	if err != nil {
+		return NotificationResource{}, fmt.Errorf(".ArmResource%w", err)
+	}
+	n.ArmResource = arm
+	n.AdditionalResourceProperties = maps.Clone(n.AdditionalResourceProperties)
+	return n, nil
+}
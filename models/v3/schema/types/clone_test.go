@@ -0,0 +1,118 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type cloneProps struct {
+	Labels map[string]string `json:"labels"`
+	Count  int               `json:"count"`
+}
+
+type copierProps struct {
+	Tags []string
+	// hidden is not exported, so it can only be copied by a registered copier.
+	hidden []string
+}
+
+func init() {
+	RegisterCopier(func(p *copierProps) *copierProps {
+		return &copierProps{
+			Tags:   append([]string(nil), p.Tags...),
+			hidden: append([]string(nil), p.hidden...),
+		}
+	})
+}
+
+func TestNotificationResourceClone(t *testing.T) {
+	t.Parallel()
+
+	id, err := arm.ParseResourceID("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/mc")
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		name   string
+		props  any
+		mutate func(props any)
+	}{
+		{
+			name:  "map[string]any properties",
+			props: map[string]any{"id": "id", "nested": map[string]any{"a": 1}, "list": []any{"a"}},
+			mutate: func(props any) {
+				p := props.(map[string]any)
+				p["nested"].(map[string]any)["a"] = 2
+				p["list"].([]any)[0] = "b"
+				p["id"] = "changed"
+			},
+		},
+		{
+			name:  "Struct pointer properties are copied through JSON",
+			props: &cloneProps{Labels: map[string]string{"a": "b"}, Count: 1},
+			mutate: func(props any) {
+				p := props.(*cloneProps)
+				p.Labels["a"] = "changed"
+				p.Count = 2
+			},
+		},
+		{
+			name:  "Struct properties are copied through JSON",
+			props: cloneProps{Labels: map[string]string{"a": "b"}, Count: 1},
+			mutate: func(props any) {
+				props.(cloneProps).Labels["a"] = "changed"
+			},
+		},
+		{
+			name:  "Registered copier",
+			props: &copierProps{Tags: []string{"a"}, hidden: []string{"b"}},
+			mutate: func(props any) {
+				p := props.(*copierProps)
+				p.Tags[0] = "changed"
+				p.hidden[0] = "changed"
+			},
+		},
+		{
+			name:   "Nil properties",
+			mutate: func(props any) {},
+		},
+	}
+
+	for _, test := range tests {
+		armRsc, err := NewArmResource(ActWrite, id, "2024-01-01", test.props)
+		if test.props == nil {
+			armRsc, err = NewArmResource(ActDelete, id, "2024-01-01", nil)
+		}
+		if err != nil {
+			panic(err)
+		}
+		orig := NotificationResource{
+			ResourceID:                   id.String(),
+			ArmResource:                  armRsc,
+			AdditionalResourceProperties: map[string]string{"key": "value"},
+		}
+
+		clone, err := orig.Clone()
+		if err != nil {
+			t.Errorf("TestNotificationResourceClone(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		want, err := orig.Clone()
+		if err != nil {
+			panic(err)
+		}
+
+		test.mutate(orig.ArmResource.Properties)
+		orig.AdditionalResourceProperties["key"] = "changed"
+
+		if diff := pretty.Compare(want, clone); diff != "" {
+			t.Errorf("TestNotificationResourceClone(%s): clone changed when original was mutated: -want/+got:\n%s", test.name, diff)
+		}
+		if clone.ArmResource.ResourceID() == orig.ArmResource.ResourceID() {
+			t.Errorf("TestNotificationResourceClone(%s): clone shares the arm.ResourceID with the original", test.name)
+		}
+	}
+}
@@ -2,13 +2,20 @@ package types
 
 // This file contains the enums used by the various types in schema 3.0.
 // The enums have been converted to uint8 to save space in memory and allow
-// faster validation. The enums are marshaled to JSON as strings using MarshalJSON()
-// methods. These use unsafe to prevent allocations.
+// faster validation. The enums are marshaled to JSON strings with MarshalJSONV2(), which
+// writes the string to the encoder without allocating, and unmarshaled with UnmarshalJSONV2()
+// so that consumers can read notifications. MarshalJSON() and UnmarshalJSON() are provided for
+// encoding/json. A value that is out of range fails to marshal rather than producing invalid JSON.
+//
+// Some enums have quotes in their line comments, so String() returns the quoted name. The name on
+// the wire never has the quotes doubled.
 
 import (
-	"unsafe"
+	"fmt"
+	"strings"
 
 	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
 )
 
 //go:generate stringer -type=ResourcesContainer -linecomment
@@ -25,11 +32,34 @@ const (
 	RCBlob ResourcesContainer = 2 // "blob"
 )
 
-// MarshalJSON marshals the value to its JSON string. This uses an
-// unsafe conversion to avoid allocations. Do not change the []byte that is returned.
+// MarshalJSON marshals the value to its JSON string.
 func (r ResourcesContainer) MarshalJSON() ([]byte, error) {
-	s := r.String()
-	return unsafe.Slice(unsafe.StringData(s), len(s)), nil
+	return marshalEnum(r, len(_ResourcesContainer_index)-1, "ResourcesContainer")
+}
+
+// MarshalJSONV2 implements json.MarshalerV2. This does not allocate.
+func (r ResourcesContainer) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	return marshalEnumV2(enc, r, len(_ResourcesContainer_index)-1, "ResourcesContainer")
+}
+
+// UnmarshalJSON unmarshals the value from its JSON string. The match is case-insensitive.
+func (r *ResourcesContainer) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum[ResourcesContainer](b, len(_ResourcesContainer_index)-1, "ResourcesContainer")
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// UnmarshalJSONV2 implements json.UnmarshalerV2. The match is case-insensitive.
+func (r *ResourcesContainer) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	v, err := unmarshalEnumV2[ResourcesContainer](dec, len(_ResourcesContainer_index)-1, "ResourcesContainer")
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
 }
 
 // Runtime check on startup to ensure that the enums can be marshaled to JSON.
@@ -64,15 +94,34 @@ const (
 	ActSnapshot Activity = 3 // snapshot
 )
 
-// MarshalJSON marshals the value to its JSON string. This uses an
-// unsafe conversion to avoid allocations. Do not change the []byte that is returned.
+// MarshalJSON marshals the value to its JSON string.
 func (a Activity) MarshalJSON() ([]byte, error) {
-	s := a.String()
-	b := make([]byte, len(s)+2)
-	b[0] = '"'
-	copy(b[1:], s)
-	b[len(b)-1] = '"'
-	return b, nil
+	return marshalEnum(a, len(_Activity_index)-1, "Activity")
+}
+
+// MarshalJSONV2 implements json.MarshalerV2. This does not allocate.
+func (a Activity) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	return marshalEnumV2(enc, a, len(_Activity_index)-1, "Activity")
+}
+
+// UnmarshalJSON unmarshals the value from its JSON string. The match is case-insensitive.
+func (a *Activity) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum[Activity](b, len(_Activity_index)-1, "Activity")
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// UnmarshalJSONV2 implements json.UnmarshalerV2. The match is case-insensitive.
+func (a *Activity) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	v, err := unmarshalEnumV2[Activity](dec, len(_Activity_index)-1, "Activity")
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
 }
 
 // Runtime check on startup to ensure that the enums can be marshaled to JSON.
@@ -107,11 +156,34 @@ const (
 	CAUpdate ChangeAction = 4 // "Update"
 )
 
-// MarshalJSON marshals the value to its JSON string. This uses an
-// unsafe conversion to avoid allocations. Do not change the []byte that is returned.
+// MarshalJSON marshals the value to its JSON string.
 func (c ChangeAction) MarshalJSON() ([]byte, error) {
-	s := c.String()
-	return unsafe.Slice(unsafe.StringData(s), len(s)), nil
+	return marshalEnum(c, len(_ChangeAction_index)-1, "ChangeAction")
+}
+
+// MarshalJSONV2 implements json.MarshalerV2. This does not allocate.
+func (c ChangeAction) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	return marshalEnumV2(enc, c, len(_ChangeAction_index)-1, "ChangeAction")
+}
+
+// UnmarshalJSON unmarshals the value from its JSON string. The match is case-insensitive.
+func (c *ChangeAction) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum[ChangeAction](b, len(_ChangeAction_index)-1, "ChangeAction")
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// UnmarshalJSONV2 implements json.UnmarshalerV2. The match is case-insensitive.
+func (c *ChangeAction) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	v, err := unmarshalEnumV2[ChangeAction](dec, len(_ChangeAction_index)-1, "ChangeAction")
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
 }
 
 // Runtime check on startup to ensure that the enums can be marshaled to JSON.
@@ -139,11 +211,34 @@ const (
 	DBEU DataBoundary = 2 // "eu"
 )
 
-// MarshalJSON marshals the value to its JSON string. This uses an
-// unsafe conversion to avoid allocations. Do not change the []byte that is returned.
+// MarshalJSON marshals the value to its JSON string.
 func (d DataBoundary) MarshalJSON() ([]byte, error) {
-	s := d.String()
-	return unsafe.Slice(unsafe.StringData(s), len(s)), nil
+	return marshalEnum(d, len(_DataBoundary_index)-1, "DataBoundary")
+}
+
+// MarshalJSONV2 implements json.MarshalerV2. This does not allocate.
+func (d DataBoundary) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	return marshalEnumV2(enc, d, len(_DataBoundary_index)-1, "DataBoundary")
+}
+
+// UnmarshalJSON unmarshals the value from its JSON string. The match is case-insensitive.
+func (d *DataBoundary) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum[DataBoundary](b, len(_DataBoundary_index)-1, "DataBoundary")
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// UnmarshalJSONV2 implements json.UnmarshalerV2. The match is case-insensitive.
+func (d *DataBoundary) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	v, err := unmarshalEnumV2[DataBoundary](dec, len(_DataBoundary_index)-1, "DataBoundary")
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
 }
 
 // Runtime check on startup to ensure that the enums can be marshaled to JSON.
@@ -157,3 +252,75 @@ func init() {
 		}
 	}
 }
+
+// enum is implemented by the enums in this file.
+type enum interface {
+	~uint8
+	fmt.Stringer
+}
+
+// wireName returns the name of v as it is on the wire, without quotes. n is the number of values of T.
+func wireName[T enum](v T, n int, name string) (string, error) {
+	if int(v) >= n {
+		return "", fmt.Errorf("invalid %s %d", name, uint8(v))
+	}
+	return strings.Trim(v.String(), `"`), nil
+}
+
+// marshalEnum returns the JSON string for v.
+func marshalEnum[T enum](v T, n int, name string) ([]byte, error) {
+	s, err := wireName(v, n, name)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	b = append(b, s...)
+	return append(b, '"'), nil
+}
+
+// marshalEnumV2 writes the JSON string for v to enc.
+func marshalEnumV2[T enum](enc *jsontext.Encoder, v T, n int, name string) error {
+	s, err := wireName(v, n, name)
+	if err != nil {
+		return err
+	}
+	return enc.WriteToken(jsontext.String(s))
+}
+
+// unmarshalEnum returns the value of type T with n values whose name matches the JSON string b.
+// JSON null is the zero value.
+func unmarshalEnum[T enum](b []byte, n int, name string) (T, error) {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return 0, fmt.Errorf("%s must be a JSON string: %w", name, err)
+	}
+	return lookupEnum[T](s, n, name)
+}
+
+// unmarshalEnumV2 reads a JSON string from dec and returns the value of type T with n values whose name
+// matches it. JSON null is the zero value.
+func unmarshalEnumV2[T enum](dec *jsontext.Decoder, n int, name string) (T, error) {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return 0, err
+	}
+	switch tok.Kind() {
+	case 'n':
+		return 0, nil
+	case '"':
+		return lookupEnum[T](tok.String(), n, name)
+	}
+	return 0, fmt.Errorf("%s must be a JSON string, got %s", name, tok.Kind())
+}
+
+// lookupEnum returns the value of type T with n values whose name matches s, ignoring case.
+func lookupEnum[T enum](s string, n int, name string) (T, error) {
+	for i := 0; i < n; i++ {
+		v := T(i)
+		if strings.EqualFold(s, strings.Trim(v.String(), `"`)) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q", name, s)
+}
@@ -0,0 +1,160 @@
+package types
+
+import (
+	jsonv1 "encoding/json"
+	"io"
+	"testing"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// enumWire is every enum value with the JSON ARN expects for it.
+var enumWire = []struct {
+	v    any
+	want string
+}{
+	{RCUnknown, `""`},
+	{RCInline, `"inline"`},
+	{RCBlob, `"blob"`},
+	{ActUnknown, `""`},
+	{ActWrite, `"write"`},
+	{ActDelete, `"delete"`},
+	{ActSnapshot, `"snapshot"`},
+	{CAUnknown, `""`},
+	{CACreate, `"Create"`},
+	{CADelete, `"Delete"`},
+	{CAMove, `"Move"`},
+	{CAUpdate, `"Update"`},
+	{DBUnknown, `""`},
+	{DBGlobal, `"global"`},
+	{DBEU, `"eu"`},
+}
+
+// newEnum returns a pointer to a zero value of the enum type of v.
+func newEnum(v any) any {
+	switch v.(type) {
+	case ResourcesContainer:
+		return new(ResourcesContainer)
+	case Activity:
+		return new(Activity)
+	case ChangeAction:
+		return new(ChangeAction)
+	case DataBoundary:
+		return new(DataBoundary)
+	}
+	panic("not an enum")
+}
+
+func deref(p any) any {
+	switch p := p.(type) {
+	case *ResourcesContainer:
+		return *p
+	case *Activity:
+		return *p
+	case *ChangeAction:
+		return *p
+	case *DataBoundary:
+		return *p
+	}
+	panic("not an enum")
+}
+
+func TestEnumRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range enumWire {
+		b, err := json.Marshal(test.v)
+		if err != nil {
+			t.Errorf("TestEnumRoundTrip(%#v): json.Marshal() error: %v", test.v, err)
+			continue
+		}
+		if string(b) != test.want {
+			t.Errorf("TestEnumRoundTrip(%#v): json.Marshal(): got %s, want %s", test.v, b, test.want)
+		}
+		b1, err := jsonv1.Marshal(test.v)
+		if err != nil {
+			t.Errorf("TestEnumRoundTrip(%#v): encoding/json Marshal() error: %v", test.v, err)
+			continue
+		}
+		if string(b1) != test.want {
+			t.Errorf("TestEnumRoundTrip(%#v): encoding/json Marshal(): got %s, want %s", test.v, b1, test.want)
+		}
+
+		p := newEnum(test.v)
+		if err := json.Unmarshal(b, p); err != nil {
+			t.Errorf("TestEnumRoundTrip(%#v): json.Unmarshal() error: %v", test.v, err)
+		} else if got := deref(p); got != test.v {
+			t.Errorf("TestEnumRoundTrip(%#v): json.Unmarshal(): got %#v", test.v, got)
+		}
+		p = newEnum(test.v)
+		if err := jsonv1.Unmarshal(b, p); err != nil {
+			t.Errorf("TestEnumRoundTrip(%#v): encoding/json Unmarshal() error: %v", test.v, err)
+		} else if got := deref(p); got != test.v {
+			t.Errorf("TestEnumRoundTrip(%#v): encoding/json Unmarshal(): got %#v", test.v, got)
+		}
+	}
+}
+
+func TestEnumUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		in      string
+		want    ChangeAction
+		wantErr bool
+	}{
+		{name: "Case-insensitive", in: `"update"`, want: CAUpdate},
+		{name: "Escaped", in: `"\u0044elete"`, want: CADelete},
+		{name: "Null", in: `null`, want: CAUnknown},
+		{name: "Error: unknown", in: `"Patch"`, wantErr: true},
+		{name: "Error: quotes in the name", in: `"\"Update\""`, wantErr: true},
+		{name: "Error: not a string", in: `4`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		for _, v1 := range []bool{false, true} {
+			var got ChangeAction
+			var err error
+			if v1 {
+				err = jsonv1.Unmarshal([]byte(test.in), &got)
+			} else {
+				err = json.Unmarshal([]byte(test.in), &got)
+			}
+			switch {
+			case err == nil && test.wantErr:
+				t.Errorf("TestEnumUnmarshal(%s, v1 %v): got err == nil, want err != nil", test.name, v1)
+			case err != nil && !test.wantErr:
+				t.Errorf("TestEnumUnmarshal(%s, v1 %v): got err == %s, want err == nil", test.name, v1, err)
+			case err == nil && got != test.want:
+				t.Errorf("TestEnumUnmarshal(%s, v1 %v): got %v, want %v", test.name, v1, got, test.want)
+			}
+		}
+	}
+}
+
+func TestEnumMarshalOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []any{ResourcesContainer(3), Activity(4), ChangeAction(5), DataBoundary(3)} {
+		if b, err := json.Marshal(v); err == nil {
+			t.Errorf("TestEnumMarshalOutOfRange(%#v): json.Marshal(): got %s, want error", v, b)
+		}
+		if b, err := jsonv1.Marshal(v); err == nil {
+			t.Errorf("TestEnumMarshalOutOfRange(%#v): encoding/json Marshal(): got %s, want error", v, b)
+		}
+	}
+}
+
+func TestEnumMarshalAllocs(t *testing.T) {
+	enc := jsontext.NewEncoder(io.Discard)
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := CAUpdate.MarshalJSONV2(enc, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("TestEnumMarshalAllocs: got %v allocations, want 0", allocs)
+	}
+}
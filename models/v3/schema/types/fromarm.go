@@ -0,0 +1,128 @@
+package types
+
+// This file converts resources from the Azure Resource Manager SDKs, such as armresources.GenericResource,
+// into a NotificationResource. The SDK types are not imported, a resource is read from the ARM JSON it
+// marshals to, so this works with any resource model and does not add the SDKs as dependencies.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/uuid"
+)
+
+// armJSON is the part of the ARM JSON of a resource that NewFromARM() reads.
+type armJSON struct {
+	ID         string         `json:"id"`
+	Location   string         `json:"location"`
+	Properties jsontext.Value `json:"properties"`
+	SystemData *armSystemData `json:"systemData"`
+}
+
+// armSystemData is the ARM systemData of a resource.
+type armSystemData struct {
+	CreatedAt          time.Time `json:"createdAt"`
+	CreatedBy          string    `json:"createdBy"`
+	CreatedByType      string    `json:"createdByType"`
+	LastModifiedAt     time.Time `json:"lastModifiedAt"`
+	LastModifiedBy     string    `json:"lastModifiedBy"`
+	LastModifiedByType string    `json:"lastModifiedByType"`
+}
+
+// NewFromARM returns a NotificationResource for resource, a resource from an Azure Resource Manager SDK such
+// as an armresources.GenericResource or *armresources.GenericResource, or its ARM JSON as a jsontext.Value.
+// act is the activity on the resource and apiVersion is the API version the resource was read with, which
+// the ARM SDK types do not record.
+//
+// The ID, name, type, location and properties of resource go to ArmResource, with the properties as the ARM
+// JSON. systemData goes to ResourceSystemProperties and ResourceEventTime: a createdBy or lastModifiedBy that
+// is an object ID with a known PrincipalType, such as an Application, is written as an Identity and anything
+// else, such as a user's email address, as it is. ChangeAction is
+// Delete for ActDelete, Create if the resource has not been modified since it was created and Update
+// otherwise. Tags, SKU and other top level fields are not copied.
+func NewFromARM(act Activity, apiVersion string, resource any) (NotificationResource, error) {
+	if resource == nil {
+		return NotificationResource{}, errors.New("resource is required")
+	}
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return NotificationResource{}, fmt.Errorf("could not marshal the resource: %w", err)
+	}
+	var r armJSON
+	if err := json.Unmarshal(b, &r); err != nil {
+		return NotificationResource{}, fmt.Errorf("resource is not an ARM resource: %w", err)
+	}
+	if r.ID == "" {
+		return NotificationResource{}, errors.New("resource has no id")
+	}
+	id, err := arm.ParseResourceID(r.ID)
+	if err != nil {
+		return NotificationResource{}, fmt.Errorf("resource id %q: %w", r.ID, err)
+	}
+
+	var props any
+	if len(r.Properties) > 0 && string(r.Properties) != "null" && act != ActDelete {
+		props = r.Properties
+	}
+	armRsc, err := NewArmResource(act, id, apiVersion, props)
+	if err != nil {
+		return NotificationResource{}, err
+	}
+	if r.Location != "" {
+		armRsc.Location = r.Location
+	}
+
+	n := NotificationResource{
+		ResourceID:  id.String(),
+		APIVersion:  apiVersion,
+		ArmResource: armRsc,
+	}
+	sd := r.SystemData
+	if sd == nil {
+		sd = &armSystemData{}
+	}
+	n.ResourceSystemProperties = ResourceSystemProperties{
+		CreatedTime:  sd.CreatedAt,
+		ModifiedTime: sd.LastModifiedAt,
+		CreatedBy:    armPrincipal(sd.CreatedBy, sd.CreatedByType),
+		ModifiedBy:   armPrincipal(sd.LastModifiedBy, sd.LastModifiedByType),
+		ChangeAction: armChangeAction(act, sd),
+	}
+	n.ResourceEventTime = sd.LastModifiedAt
+	if n.ResourceEventTime.IsZero() {
+		n.ResourceEventTime = sd.CreatedAt
+	}
+
+	if err := n.ResourceSystemProperties.Validate(); err != nil {
+		return NotificationResource{}, fmt.Errorf(".ResourceSystemProperties: %w", err)
+	}
+	return n, nil
+}
+
+// armPrincipal returns by, an ARM systemData createdBy or lastModifiedBy of byType, as it is written to
+// CreatedBy or ModifiedBy.
+func armPrincipal(by, byType string) string {
+	if _, err := uuid.Parse(by); err != nil {
+		return by
+	}
+	p, ok := parsePrincipalType(byType)
+	if !ok {
+		return by
+	}
+	return Identity{PrincipalType: p, ObjectID: by}.String()
+}
+
+// armChangeAction returns the ChangeAction of act on a resource with systemData sd.
+func armChangeAction(act Activity, sd *armSystemData) ChangeAction {
+	switch {
+	case act == ActDelete:
+		return CADelete
+	case !sd.CreatedAt.IsZero() && (sd.LastModifiedAt.IsZero() || sd.LastModifiedAt.Equal(sd.CreatedAt)):
+		return CACreate
+	}
+	return CAUpdate
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// genericResource is shaped like armresources.GenericResource, which has pointer fields.
+type genericResource struct {
+	ID         *string            `json:"id,omitempty"`
+	Name       *string            `json:"name,omitempty"`
+	Type       *string            `json:"type,omitempty"`
+	Location   *string            `json:"location,omitempty"`
+	Properties any                `json:"properties,omitempty"`
+	Tags       map[string]*string `json:"tags,omitempty"`
+	SystemData *systemData        `json:"systemData,omitempty"`
+}
+
+type systemData struct {
+	CreatedAt          *time.Time `json:"createdAt,omitempty"`
+	CreatedBy          *string    `json:"createdBy,omitempty"`
+	CreatedByType      *string    `json:"createdByType,omitempty"`
+	LastModifiedAt     *time.Time `json:"lastModifiedAt,omitempty"`
+	LastModifiedBy     *string    `json:"lastModifiedBy,omitempty"`
+	LastModifiedByType *string    `json:"lastModifiedByType,omitempty"`
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestNewFromARM(t *testing.T) {
+	t.Parallel()
+
+	const (
+		id  = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm1"
+		oid = "6f1c2a8e-3b7d-4c55-9e0f-1a2b3c4d5e6f"
+	)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	modified := created.Add(time.Hour)
+
+	vm := genericResource{
+		ID:         ptr(id),
+		Name:       ptr("vm1"),
+		Type:       ptr("Microsoft.Compute/virtualMachines"),
+		Location:   ptr("eastus"),
+		Properties: map[string]any{"vmId": "abc"},
+		Tags:       map[string]*string{"team": ptr("x")},
+		SystemData: &systemData{
+			CreatedAt:          &created,
+			CreatedBy:          ptr("user@contoso.com"),
+			CreatedByType:      ptr("User"),
+			LastModifiedAt:     &modified,
+			LastModifiedBy:     ptr(oid),
+			LastModifiedByType: ptr("Application"),
+		},
+	}
+	newVM := vm
+	newVM.SystemData = &systemData{CreatedAt: &created, LastModifiedAt: &created}
+
+	tests := []struct {
+		name      string
+		act       Activity
+		resource  any
+		want      NotificationResource
+		wantProps string
+		wantErr   bool
+	}{
+		{
+			name:     "Updated resource",
+			act:      ActWrite,
+			resource: &vm,
+			want: NotificationResource{
+				ResourceID:        id,
+				APIVersion:        "2024-03-01",
+				ResourceEventTime: modified,
+				ResourceSystemProperties: ResourceSystemProperties{
+					CreatedTime:  created,
+					ModifiedTime: modified,
+					CreatedBy:    "user@contoso.com",
+					ModifiedBy:   "Application:" + oid,
+					ChangeAction: CAUpdate,
+				},
+			},
+			wantProps: `{"vmId":"abc"}`,
+		},
+		{
+			name:     "Created resource",
+			act:      ActWrite,
+			resource: newVM,
+			want: NotificationResource{
+				ResourceID:               id,
+				APIVersion:               "2024-03-01",
+				ResourceEventTime:        created,
+				ResourceSystemProperties: ResourceSystemProperties{CreatedTime: created, ModifiedTime: created, ChangeAction: CACreate},
+			},
+			wantProps: `{"vmId":"abc"}`,
+		},
+		{
+			name:     "Deleted resource from JSON",
+			act:      ActDelete,
+			resource: jsontext.Value(`{"id": "` + id + `", "location": "eastus", "properties": {"vmId": "abc"}}`),
+			want: NotificationResource{
+				ResourceID:               id,
+				APIVersion:               "2024-03-01",
+				ResourceSystemProperties: ResourceSystemProperties{ChangeAction: CADelete},
+			},
+		},
+		{name: "Error: nil", act: ActWrite, resource: nil, wantErr: true},
+		{name: "Error: no id", act: ActWrite, resource: genericResource{Name: ptr("vm1")}, wantErr: true},
+		{name: "Error: bad id", act: ActWrite, resource: genericResource{ID: ptr("not an id")}, wantErr: true},
+		{name: "Error: not an object", act: ActWrite, resource: jsontext.Value(`[1]`), wantErr: true},
+		{name: "Error: write with no properties", act: ActWrite, resource: genericResource{ID: ptr(id)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := NewFromARM(test.act, "2024-03-01", test.resource)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestNewFromARM(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestNewFromARM(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if got.ArmResource.ID != id || got.ArmResource.Name != "vm1" || got.ArmResource.Type != "Microsoft.Compute/virtualMachines" ||
+			got.ArmResource.Location != "eastus" || got.ArmResource.APIVersion != "2024-03-01" || got.ArmResource.Activity() != test.act {
+			t.Errorf("TestNewFromARM(%s): got ArmResource %+v", test.name, got.ArmResource)
+		}
+		var props string
+		if got.ArmResource.Properties != nil {
+			b, err := json.Marshal(got.ArmResource.Properties)
+			if err != nil {
+				t.Fatalf("TestNewFromARM(%s): could not marshal properties: %s", test.name, err)
+			}
+			props = strings.ReplaceAll(string(b), " ", "")
+		}
+		if props != test.wantProps {
+			t.Errorf("TestNewFromARM(%s): got properties %s, want %s", test.name, props, test.wantProps)
+		}
+		got.ArmResource = ArmResource{}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestNewFromARM(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package types
+
+// This file contains helpers for ResourceSystemProperties.CreatedBy and .ModifiedBy. The schema has these
+// as free-form strings, so an Identity is written to them as "PrincipalType:ObjectID", such as
+// "User:6f1c2a8e-3b7d-4c55-9e0f-1a2b3c4d5e6f". Consumers that do not know the format still get a readable
+// string, and any other string is passed through as before.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// PrincipalType is the type of the Microsoft Entra principal in an Identity.
+type PrincipalType string
+
+const (
+	// PTUser is a user.
+	PTUser PrincipalType = "User"
+	// PTGroup is a group.
+	PTGroup PrincipalType = "Group"
+	// PTServicePrincipal is a service principal of an application.
+	PTServicePrincipal PrincipalType = "ServicePrincipal"
+	// PTManagedIdentity is a system or user assigned managed identity.
+	PTManagedIdentity PrincipalType = "ManagedIdentity"
+	// PTApplication is an application registration.
+	PTApplication PrincipalType = "Application"
+)
+
+// principalTypes are the known PrincipalTypes.
+var principalTypes = []PrincipalType{PTUser, PTGroup, PTServicePrincipal, PTManagedIdentity, PTApplication}
+
+// parsePrincipalType returns the known PrincipalType that matches s case-insensitively.
+func parsePrincipalType(s string) (PrincipalType, bool) {
+	for _, p := range principalTypes {
+		if strings.EqualFold(s, string(p)) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Identity is the principal that created or modified a resource.
+type Identity struct {
+	// PrincipalType is the type of the principal.
+	PrincipalType PrincipalType
+	// ObjectID is the Microsoft Entra object ID of the principal, a GUID.
+	ObjectID string
+}
+
+// Validate validates the Identity.
+func (i Identity) Validate() error {
+	if _, ok := parsePrincipalType(string(i.PrincipalType)); !ok {
+		return fmt.Errorf("unknown principal type %q", i.PrincipalType)
+	}
+	if _, err := uuid.Parse(i.ObjectID); err != nil {
+		return fmt.Errorf("object ID %q must be a GUID", i.ObjectID)
+	}
+	return nil
+}
+
+// String returns the Identity as it is written to CreatedBy or ModifiedBy, "PrincipalType:ObjectID".
+func (i Identity) String() string {
+	return string(i.PrincipalType) + ":" + strings.ToLower(i.ObjectID)
+}
+
+// ParseIdentity parses an Identity from the format written by Identity.String(). The principal type is
+// matched case-insensitively.
+func ParseIdentity(s string) (Identity, error) {
+	pt, id, ok := strings.Cut(s, ":")
+	if !ok {
+		return Identity{}, fmt.Errorf("identity %q is not in the format PrincipalType:ObjectID", s)
+	}
+	p, ok := parsePrincipalType(pt)
+	if !ok {
+		return Identity{}, fmt.Errorf("unknown principal type %q", pt)
+	}
+	i := Identity{PrincipalType: p, ObjectID: id}
+	if err := i.Validate(); err != nil {
+		return Identity{}, err
+	}
+	i.ObjectID = strings.ToLower(id)
+	return i, nil
+}
+
+// SetCreatedBy validates id and sets CreatedBy to it.
+func (r *ResourceSystemProperties) SetCreatedBy(id Identity) error {
+	if err := id.Validate(); err != nil {
+		return fmt.Errorf(".CreatedBy: %w", err)
+	}
+	r.CreatedBy = id.String()
+	return nil
+}
+
+// SetModifiedBy validates id and sets ModifiedBy to it.
+func (r *ResourceSystemProperties) SetModifiedBy(id Identity) error {
+	if err := id.Validate(); err != nil {
+		return fmt.Errorf(".ModifiedBy: %w", err)
+	}
+	r.ModifiedBy = id.String()
+	return nil
+}
+
+// CreatedByIdentity returns CreatedBy as an Identity. It returns false if CreatedBy is a free-form string.
+func (r ResourceSystemProperties) CreatedByIdentity() (Identity, bool) {
+	i, err := ParseIdentity(r.CreatedBy)
+	return i, err == nil
+}
+
+// ModifiedByIdentity returns ModifiedBy as an Identity. It returns false if ModifiedBy is a free-form string.
+func (r ResourceSystemProperties) ModifiedByIdentity() (Identity, bool) {
+	i, err := ParseIdentity(r.ModifiedBy)
+	return i, err == nil
+}
+
+// validateBy returns an error if s starts with a known principal type, so is meant to be an Identity, but
+// is not a valid one. Any other string is free-form and valid.
+func validateBy(s string) error {
+	pt, _, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil
+	}
+	if _, ok := parsePrincipalType(pt); !ok {
+		return nil
+	}
+	_, err := ParseIdentity(s)
+	return err
+}
@@ -0,0 +1,101 @@
+package types
+
+import (
+	"testing"
+)
+
+const testObjectID = "6f1c2a8e-3b7d-4c55-9e0f-1a2b3c4d5e6f"
+
+func TestParseIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		in      string
+		want    Identity
+		wantErr bool
+	}{
+		{name: "User", in: "User:" + testObjectID, want: Identity{PrincipalType: PTUser, ObjectID: testObjectID}},
+		{
+			name: "Case-insensitive type and upper case GUID",
+			in:   "managedidentity:6F1C2A8E-3B7D-4C55-9E0F-1A2B3C4D5E6F",
+			want: Identity{PrincipalType: PTManagedIdentity, ObjectID: testObjectID},
+		},
+		{name: "Error: no type", in: testObjectID, wantErr: true},
+		{name: "Error: unknown type", in: "Robot:" + testObjectID, wantErr: true},
+		{name: "Error: object ID not a GUID", in: "User:someone@example.com", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseIdentity(test.in)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("TestParseIdentity(%s): got err == nil, want err != nil", test.name)
+			continue
+		case !test.wantErr && err != nil:
+			t.Errorf("TestParseIdentity(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestParseIdentity(%s): got %+v, want %+v", test.name, got, test.want)
+		}
+		if back, err := ParseIdentity(got.String()); err != nil || back != got {
+			t.Errorf("TestParseIdentity(%s): String() did not round trip: got %+v, %v", test.name, back, err)
+		}
+	}
+}
+
+func TestResourceSystemPropertiesIdentity(t *testing.T) {
+	t.Parallel()
+
+	id := Identity{PrincipalType: PTServicePrincipal, ObjectID: testObjectID}
+
+	r := ResourceSystemProperties{ChangeAction: CAUpdate, ModifiedBy: "someone@example.com"}
+	if err := r.SetCreatedBy(id); err != nil {
+		t.Fatalf("TestResourceSystemPropertiesIdentity: SetCreatedBy(): got err == %s, want err == nil", err)
+	}
+	if want := "ServicePrincipal:" + testObjectID; r.CreatedBy != want {
+		t.Errorf("TestResourceSystemPropertiesIdentity: got CreatedBy %q, want %q", r.CreatedBy, want)
+	}
+	if got, ok := r.CreatedByIdentity(); !ok || got != id {
+		t.Errorf("TestResourceSystemPropertiesIdentity: CreatedByIdentity(): got %+v, %v, want %+v, true", got, ok, id)
+	}
+	if _, ok := r.ModifiedByIdentity(); ok {
+		t.Errorf("TestResourceSystemPropertiesIdentity: ModifiedByIdentity() of a free-form string: got true, want false")
+	}
+	if err := r.Validate(); err != nil {
+		t.Errorf("TestResourceSystemPropertiesIdentity: Validate(): got err == %s, want err == nil", err)
+	}
+
+	if err := r.SetModifiedBy(Identity{PrincipalType: PTUser, ObjectID: "not-a-guid"}); err == nil {
+		t.Errorf("TestResourceSystemPropertiesIdentity: SetModifiedBy() with an invalid Identity: got err == nil, want err != nil")
+	}
+	if r.ModifiedBy != "someone@example.com" {
+		t.Errorf("TestResourceSystemPropertiesIdentity: a failed SetModifiedBy() changed ModifiedBy to %q", r.ModifiedBy)
+	}
+
+	tests := []struct {
+		name    string
+		by      string
+		wantErr bool
+	}{
+		{name: "Empty", by: ""},
+		{name: "Free-form", by: "someone@example.com"},
+		{name: "Free-form with a colon", by: "svc:deployer"},
+		{name: "Identity", by: "Group:" + testObjectID},
+		{name: "Error: identity with a bad object ID", by: "User:someone", wantErr: true},
+	}
+	for _, test := range tests {
+		for _, r := range []ResourceSystemProperties{
+			{ChangeAction: CAUpdate, CreatedBy: test.by},
+			{ChangeAction: CAUpdate, ModifiedBy: test.by},
+		} {
+			err := r.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("TestResourceSystemPropertiesIdentity(%s): Validate(): got err == %v, want error: %v", test.name, err, test.wantErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package types
+
+// This file contains the guardrails on ArmResource.Properties. The JSON encoder turns a NaN or infinite float
+// into a string, and a registered marshal option can replace invalid UTF-8 with U+FFFD or let it through, so
+// properties that would be rejected or misread by ARN can otherwise be sent without an error.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+const (
+	// DefaultMaxPropertyDepth is the default maximum nesting of objects and arrays in the JSON of
+	// ArmResource.Properties, counting the properties object itself.
+	DefaultMaxPropertyDepth = 64
+	// DefaultMaxPropertyBytes is the default maximum size of the JSON of ArmResource.Properties.
+	DefaultMaxPropertyBytes = 1 << 20
+)
+
+// PropertyLimits are the limits that the JSON of ArmResource.Properties must be within. Zero values are
+// replaced by the defaults.
+type PropertyLimits struct {
+	// MaxDepth is the maximum nesting of objects and arrays, counting the properties object itself.
+	// Defaults to DefaultMaxPropertyDepth.
+	MaxDepth int
+	// MaxBytes is the maximum size of the JSON in bytes. Defaults to DefaultMaxPropertyBytes.
+	MaxBytes int
+}
+
+// Defaults returns a copy of l with the zero values replaced by the defaults.
+func (l PropertyLimits) Defaults() PropertyLimits {
+	if l.MaxDepth == 0 {
+		l.MaxDepth = DefaultMaxPropertyDepth
+	}
+	if l.MaxBytes == 0 {
+		l.MaxBytes = DefaultMaxPropertyBytes
+	}
+	return l
+}
+
+// Validate validates the limits. This should be called after Defaults().
+func (l PropertyLimits) Validate() error {
+	switch {
+	case l.MaxDepth < 1:
+		return errors.New("MaxDepth must be at least 1")
+	case l.MaxBytes < 1:
+		return errors.New("MaxBytes must be at least 1")
+	}
+	return nil
+}
+
+// CheckProperties checks that props, an ArmResource.Properties value, does not hold a NaN or infinite float or
+// a string that is not valid UTF-8, and marshals to valid UTF-8 JSON within l. Errors name the JSON path of the offending value where it is
+// known. props is marshaled as it is in an event, see MarshalProperties(). A nil props is valid.
+func CheckProperties(props any, l PropertyLimits) error {
+	if props == nil {
+		return nil
+	}
+	l = l.Defaults()
+	if err := l.Validate(); err != nil {
+		return err
+	}
+
+	if err := checkValue(reflect.ValueOf(props), nil, l.MaxDepth); err != nil {
+		return err
+	}
+
+	b, err := MarshalProperties(props)
+	if err != nil {
+		return err
+	}
+	if len(b) > l.MaxBytes {
+		return fmt.Errorf("marshals to %d bytes, more than the limit of %d", len(b), l.MaxBytes)
+	}
+	if !utf8.Valid(b) {
+		return errors.New("marshals to JSON that is not valid UTF-8")
+	}
+
+	dec := jsontext.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			// The JSON came from the encoder, so the only error left is the end of it.
+			return nil
+		}
+		if k := tok.Kind(); (k == '{' || k == '[') && dec.StackDepth() > l.MaxDepth {
+			return fmt.Errorf("%s: nested more than %d deep", dec.StackPointer(), l.MaxDepth)
+		}
+	}
+}
+
+// checkValue returns an error if v holds a NaN or infinite float or a string that is not valid UTF-8. path is
+// the JSON path of v. Values nested deeper than depth are not checked, as the JSON depth check rejects them.
+func checkValue(v reflect.Value, path []string, depth int) error {
+	if depth < 0 {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%s: %v is not a finite number, which JSON cannot hold", jsonPath(path), f)
+		}
+	case reflect.String:
+		if !utf8.ValidString(v.String()) {
+			return fmt.Errorf("%s: string %q is not valid UTF-8", jsonPath(path), v.String())
+		}
+	case reflect.Interface, reflect.Pointer:
+		if !v.IsNil() {
+			return checkValue(v.Elem(), path, depth)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Bytes are marshaled as base64 or are raw JSON, which is checked once it is marshaled.
+			return nil
+		}
+		for i := range v.Len() {
+			if err := checkValue(v.Index(i), append(slices.Clip(path), strconv.Itoa(i)), depth-1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if k := iter.Key(); k.Kind() == reflect.String && !utf8.ValidString(k.String()) {
+				return fmt.Errorf("%s: key %q is not valid UTF-8", jsonPath(path), k.String())
+			}
+			if err := checkValue(iter.Value(), append(slices.Clip(path), fmt.Sprint(iter.Key())), depth-1); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, inline := jsonName(f)
+			if name == "-" || (!f.IsExported() && !(f.Anonymous && inline)) {
+				continue
+			}
+			p, d := path, depth
+			if !inline {
+				p, d = append(slices.Clip(path), name), depth-1
+			}
+			if err := checkValue(v.Field(i), p, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPath returns path as a JSON Pointer, like "/a/0/b".
+func jsonPath(path []string) string {
+	if len(path) == 0 {
+		return "(root)"
+	}
+	var sb strings.Builder
+	for _, p := range path {
+		sb.WriteByte('/')
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(p))
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+type limitsProps struct {
+	Name    string             `json:"name"`
+	Scores  []float64          `json:"scores"`
+	Ratios  map[string]float32 `json:"ratios,omitempty"`
+	Ignored float64            `json:"-"`
+	hidden  float64
+}
+
+// rawUTF8Props is marshaled with jsontext.AllowInvalidUTF8(), which replaces invalid UTF-8 with U+FFFD.
+type rawUTF8Props struct {
+	Name string `json:"name"`
+}
+
+func nested(depth int) any {
+	var v any = "leaf"
+	for range depth {
+		v = map[string]any{"a": v}
+	}
+	return v
+}
+
+func TestCheckProperties(t *testing.T) {
+	t.Parallel()
+
+	RegisterMarshalOptions[rawUTF8Props](jsontext.AllowInvalidUTF8(true))
+
+	tests := []struct {
+		name    string
+		props   any
+		limits  PropertyLimits
+		wantErr string
+	}{
+		{name: "Nil", props: nil},
+		{name: "Valid", props: limitsProps{Name: "vm", Scores: []float64{1, 2.5}, Ratios: map[string]float32{"cpu": 0.5}}},
+		{name: "NaN in an ignored field", props: limitsProps{Ignored: math.NaN(), hidden: math.Inf(1)}},
+		{name: "Depth at the limit", props: nested(3), limits: PropertyLimits{MaxDepth: 3}},
+		{name: "Error: NaN in a slice", props: limitsProps{Scores: []float64{1, math.NaN()}}, wantErr: "/scores/1: NaN is not a finite number"},
+		{name: "Error: Inf in a map", props: &limitsProps{Ratios: map[string]float32{"cpu": float32(math.Inf(-1))}}, wantErr: "/ratios/cpu: -Inf"},
+		{name: "Error: NaN in a map[string]any", props: map[string]any{"a": []any{math.NaN()}}, wantErr: "/a/0: NaN"},
+		{name: "Error: invalid UTF-8", props: map[string]any{"name": "\xff"}, wantErr: `/name: string "\xff" is not valid UTF-8`},
+		{name: "Error: invalid UTF-8 in a key", props: map[string]any{"\xff": 1}, wantErr: `key "\xff" is not valid UTF-8`},
+		{name: "Error: invalid UTF-8 replaced by registered options", props: rawUTF8Props{Name: "\xff"}, wantErr: "/name: string"},
+		{name: "Error: invalid UTF-8 in raw JSON", props: map[string]any{"a": jsontext.Value("\"\xff\"")}, wantErr: "invalid UTF-8"},
+		{name: "Error: too deep", props: nested(4), limits: PropertyLimits{MaxDepth: 3}, wantErr: "nested more than 3 deep"},
+		{name: "Error: too large", props: map[string]any{"a": strings.Repeat("x", 100)}, limits: PropertyLimits{MaxBytes: 50}, wantErr: "more than the limit of 50"},
+		{name: "Error: invalid limits", props: limitsProps{}, limits: PropertyLimits{MaxDepth: -1}, wantErr: "MaxDepth"},
+	}
+
+	for _, test := range tests {
+		err := CheckProperties(test.props, test.limits)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("TestCheckProperties(%s): got err == %s, want err == nil", test.name, err)
+		case test.wantErr != "" && err == nil:
+			t.Errorf("TestCheckProperties(%s): got err == nil, want err containing %q", test.name, test.wantErr)
+		case test.wantErr != "" && !strings.Contains(err.Error(), test.wantErr):
+			t.Errorf("TestCheckProperties(%s): got err == %s, want err containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// marshalOpts holds the registered marshal options by the type they apply to.
+var marshalOpts sync.Map // map[reflect.Type]json.Options
+
+// RegisterMarshalOptions registers options used to marshal ArmResource.Properties values of type T. This is
+// for property schemas negotiated with ARN that need different handling than the defaults, such as
+// json.FormatNilMapAsNull(true), json.DiscardUnknownMembers(true) or json.WithMarshalers() to override the
+// format of a type. The options apply to the Properties value and everything in it and override the options
+// the event is marshaled with. Registering options for a type that already has them replaces them. Calling
+// this with no options removes them. Thread-safe.
+func RegisterMarshalOptions[T any](opts ...json.Options) {
+	t := reflect.TypeFor[T]()
+	if len(opts) == 0 {
+		marshalOpts.Delete(t)
+		return
+	}
+	marshalOpts.Store(t, json.JoinOptions(opts...))
+}
+
+// MarshalProperties marshals props, an ArmResource.Properties value, to JSON as it is marshaled in an
+// event. opts are used unless overridden by the options registered for the type of props with
+// RegisterMarshalOptions().
+func MarshalProperties(props any, opts ...json.Options) ([]byte, error) {
+	if o, ok := optionsFor(props); ok {
+		opts = append(opts[:len(opts):len(opts)], o)
+	}
+	return json.Marshal(props, opts...)
+}
+
+// optionsFor returns the options registered for the type of v.
+func optionsFor(v any) (json.Options, bool) {
+	if v == nil {
+		return nil, false
+	}
+	o, ok := marshalOpts.Load(reflect.TypeOf(v))
+	if !ok {
+		return nil, false
+	}
+	return o.(json.Options), true
+}
+
+// plainArmResource is an ArmResource without its methods, so that it is marshaled as a plain struct.
+type plainArmResource ArmResource
+
+// MarshalJSONV2 implements json.MarshalerV2. Properties are marshaled with the options registered for their
+// type with RegisterMarshalOptions().
+func (a ArmResource) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	p := plainArmResource(a)
+	if o, ok := optionsFor(a.Properties); ok {
+		p.Properties = propsWithOptions{v: a.Properties, opts: o}
+	}
+	return json.MarshalEncode(enc, p, opts)
+}
+
+// propsWithOptions marshals v with opts joined to the options it is marshaled with.
+type propsWithOptions struct {
+	v    any
+	opts json.Options
+}
+
+// MarshalJSONV2 implements json.MarshalerV2.
+func (p propsWithOptions) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	return json.MarshalEncode(enc, p.v, json.JoinOptions(opts, p.opts))
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/go-json-experiment/json"
+)
+
+type defaultProps struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type nullMapProps struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type formatProps struct {
+	Count int `json:"count"`
+	Zero  int `json:"zero"`
+}
+
+type removedProps struct {
+	Labels map[string]string `json:"labels"`
+}
+
+func init() {
+	RegisterMarshalOptions[nullMapProps](json.FormatNilMapAsNull(true))
+	RegisterMarshalOptions[formatProps](json.WithMarshalers(json.MarshalFuncV1(func(i int) ([]byte, error) {
+		return []byte(strconv.Quote(strconv.Itoa(i))), nil
+	})))
+	RegisterMarshalOptions[removedProps](json.FormatNilMapAsNull(true))
+	RegisterMarshalOptions[removedProps]()
+}
+
+func TestRegisterMarshalOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		props any
+		want  string
+	}{
+		{
+			name:  "No registered options",
+			props: defaultProps{},
+			want:  `{"properties":{"labels":{}},"id":"id"}`,
+		},
+		{
+			name:  "Nil maps as null",
+			props: nullMapProps{},
+			want:  `{"properties":{"labels":null},"id":"id"}`,
+		},
+		{
+			name:  "Options are by type, a pointer is a different type",
+			props: &nullMapProps{},
+			want:  `{"properties":{"labels":{}},"id":"id"}`,
+		},
+		{
+			name:  "Format override",
+			props: formatProps{Count: 1},
+			want:  `{"properties":{"count":"1","zero":"0"},"id":"id"}`,
+		},
+		{
+			name:  "Options removed",
+			props: removedProps{},
+			want:  `{"properties":{"labels":{}},"id":"id"}`,
+		},
+	}
+
+	for _, test := range tests {
+		a := ArmResource{ID: "id", Properties: test.props}
+
+		b, err := json.Marshal(a)
+		if err != nil {
+			t.Errorf("TestRegisterMarshalOptions(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if string(b) != test.want {
+			t.Errorf("TestRegisterMarshalOptions(%s): got %s, want %s", test.name, b, test.want)
+		}
+
+		// The ArmResource is marshaled the same way when it is part of a NotificationResource.
+		b, err = json.Marshal(NotificationResource{ResourceID: "rid", ArmResource: a})
+		if err != nil {
+			t.Errorf("TestRegisterMarshalOptions(%s): NotificationResource: got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if want := `{"armResource":` + test.want + `,"resourceId":"rid"}`; string(b) != want {
+			t.Errorf("TestRegisterMarshalOptions(%s): NotificationResource: got %s, want %s", test.name, b, want)
+		}
+	}
+}
+
+func TestMarshalProperties(t *testing.T) {
+	t.Parallel()
+
+	b, err := MarshalProperties(nullMapProps{})
+	if err != nil {
+		t.Fatalf("TestMarshalProperties: got err == %s, want err == nil", err)
+	}
+	if got, want := string(b), `{"labels":null}`; got != want {
+		t.Errorf("TestMarshalProperties: got %s, want %s", got, want)
+	}
+
+	// Registered options override the options passed in.
+	b, err = MarshalProperties(nullMapProps{}, json.FormatNilMapAsNull(false))
+	if err != nil {
+		t.Fatalf("TestMarshalProperties(override): got err == %s, want err == nil", err)
+	}
+	if got, want := string(b), `{"labels":null}`; got != want {
+		t.Errorf("TestMarshalProperties(override): got %s, want %s", got, want)
+	}
+
+	b, err = MarshalProperties(nil)
+	if err != nil {
+		t.Fatalf("TestMarshalProperties(nil): got err == %s, want err == nil", err)
+	}
+	if got, want := string(b), `null`; got != want {
+		t.Errorf("TestMarshalProperties(nil): got %s, want %s", got, want)
+	}
+}
@@ -11,6 +11,8 @@ EventMeta is the metadata of the event. This is inlined during Marshaling.
 ArmResource is where you store the resource data from your service. You may need to have an
 agreed on schema with the ARN service. This object must serialize out a field called "id" that
 is the resource ID. During delete events, all object properties other than id will be missing.
+If the agreed on schema needs different JSON handling than the defaults, such as nil maps as null, use
+RegisterMarshalOptions() for the type of your properties.
 */
 package types
 
@@ -136,6 +138,17 @@ func (d Data) Validate() error {
 				return errors.New("all resources must have the same APIVersion")
 			}
 
+			// Only the first resource needs checking, as all resources have the same type and version.
+			if i == 0 {
+				v := d.APIVersion
+				if v == "" {
+					v = r.APIVersion
+				}
+				if err := checkAPIVersion(r.ArmResource.Type, v); err != nil {
+					return fmt.Errorf(".Resources[%d]: %w", i, err)
+				}
+			}
+
 			if rscAPIVersion != "" {
 				if r.ArmResource.APIVersion != rscAPIVersion {
 					return errors.New("all resources must have the same APIVersion and ArmResource.APIVersion must match")
@@ -318,6 +331,11 @@ func (a ArmResource) Validate() error {
 	if a.ID == "" {
 		return errors.New(".ID is required")
 	}
+	if a.APIVersion != "" {
+		if err := checkAPIVersion(a.Type, a.APIVersion); err != nil {
+			return fmt.Errorf(".APIVersion: %w", err)
+		}
+	}
 
 	switch a.act {
 	case ActWrite, ActSnapshot:
@@ -341,8 +359,12 @@ type ResourceSystemProperties struct {
 	// Modified time of the resource.
 	ModifiedTime time.Time `json:"modifiedTime,omitzero" format:"RFC3339"`
 	// CreatedBy is the entity that created this resource, can be object id, alias, display name etc.
+	// Use SetCreatedBy() to set it to an Identity, which consumers can read with CreatedByIdentity().
+	// A value that starts with a PrincipalType and ":" must be a valid Identity.
 	CreatedBy string `json:"createdBy"`
 	// ModifiedBy is the entity that last modified this resource, can be object id, alias, display name etc.
+	// Use SetModifiedBy() to set it to an Identity, which consumers can read with ModifiedByIdentity().
+	// A value that starts with a PrincipalType and ":" must be a valid Identity.
 	ModifiedBy string `json:"modifiedBy"`
 	// ChangeAction is the type of event action for this resource event, currently supported ones are Create, Update, Delete, Move.
 	ChangeAction ChangeAction `json:"changeAction"`
@@ -353,6 +375,12 @@ func (r ResourceSystemProperties) Validate() error {
 	if r.ChangeAction == 0 || r.ChangeAction >= ChangeAction(len(_ChangeAction_index)-1) {
 		return fmt.Errorf(".ChangedAction(%d) is invalid", r.ChangeAction)
 	}
+	if err := validateBy(r.CreatedBy); err != nil {
+		return fmt.Errorf(".CreatedBy: %w", err)
+	}
+	if err := validateBy(r.ModifiedBy); err != nil {
+		return fmt.Errorf(".ModifiedBy: %w", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,263 @@
+/*
+Package transport provides the pipeline the client uses to send notifications to ARN, for services that need to
+embed it inside their own multiplexer instead of using the client package.
+
+A Transport owns the send loop: it checks the item limit, queues notifications, sends each one with the model's
+SendEvent() (inline or through blob storage) and delivers the result to the notification's promise. It does not
+own credentials or endpoints. Those are provided with a models.EventSender, for the ARN receiver, and an optional
+models.PayloadStore, for blob storage. NewSender() and NewStore() return the SDK's implementations of these.
+
+The types and functions in this package are stable. The internal packages they are built on are not, so
+extensions should use this package rather than forking them.
+
+Example - sending a notification and waiting for the result:
+
+	sender, err := transport.NewSender(*arnEndpoint, cred, nil)
+	if err != nil {
+		panic(err)
+	}
+	store, err := transport.NewStore(*storageAccount, cred)
+	if err != nil {
+		panic(err)
+	}
+
+	t, err := transport.New(sender, store)
+	if err != nil {
+		panic(err)
+	}
+	defer t.Close()
+
+	if err := t.Notify(ctx, notification); err != nil {
+		// Handle the error.
+	}
+*/
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Azure/arn-sdk/internal/conn"
+	"github.com/Azure/arn-sdk/internal/conn/http"
+	"github.com/Azure/arn-sdk/internal/conn/maxvals"
+	"github.com/Azure/arn-sdk/internal/conn/stats"
+	"github.com/Azure/arn-sdk/internal/conn/storage"
+	"github.com/Azure/arn-sdk/models"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// DefaultMaxItems is the number of items a notification may hold if WithMaxItems() is not used.
+const DefaultMaxItems = maxvals.NotificationItems
+
+// Stats are statistics about the notifications sent by a Transport. This is the same type as client.Stats.
+type Stats = stats.Stats
+
+// Failure is the category of a failed notification in Stats.Failures.
+type Failure = stats.Failure
+
+// Sender sends an event to the ARN receiver. It is the seam for a transport the SDK does not provide, such as
+// one that routes through a sidecar, adds its own authentication or records traffic. Give it to New() here, or
+// set it as client.HTTPArgs.Sender to use it in the client. Send() should return an *azcore.ResponseError for
+// a response that is not 200 OK, so retries, the circuit breaker and Stats classify it as they do for the
+// SDK's sender.
+type Sender = models.EventSender
+
+// NewSender returns the SDK's models.EventSender for the ARN receiver at endpoint. opts may be nil.
+func NewSender(endpoint string, cred azcore.TokenCredential, opts *policy.ClientOptions) (models.EventSender, error) {
+	if cred == nil {
+		return nil, errors.New("cred is required")
+	}
+	c, err := http.New(endpoint, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewStore returns the SDK's models.PayloadStore for the blob storage account at endpoint, which holds
+// notifications too large to send inline.
+func NewStore(endpoint string, cred azcore.TokenCredential) (models.PayloadStore, error) {
+	if cred == nil {
+		return nil, errors.New("cred is required")
+	}
+	c, err := storage.New(endpoint, cred)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Transport sends notifications to ARN. It is safe for concurrent use, however notifications sent from
+// different goroutines are not ordered with respect to each other.
+type Transport struct {
+	svc  *conn.Service
+	errs chan error
+
+	// mu serializes calls to svc.Send(), which is not thread safe.
+	mu sync.Mutex
+
+	log        *slog.Logger
+	maxItems   int
+	budget     time.Duration
+	blobCanary int
+}
+
+// Option is an option for New().
+type Option func(*Transport) error
+
+// WithLogger sets the logger. By default it uses slog.Default().
+func WithLogger(log *slog.Logger) Option {
+	return func(t *Transport) error {
+		if log == nil {
+			return errors.New("logger cannot be nil")
+		}
+		t.log = log
+		return nil
+	}
+}
+
+// WithMaxItems sets the maximum number of items in a notification. Defaults to DefaultMaxItems.
+func WithMaxItems(n int) Option {
+	return func(t *Transport) error {
+		if n <= 0 {
+			return fmt.Errorf("max items must be greater than 0")
+		}
+		t.maxItems = n
+		return nil
+	}
+}
+
+// WithSendBudget sets the total time that can be spent sending a notification, including the blob upload,
+// the send to ARN and all of their retries. By default there is no limit other than the notification's context.
+func WithSendBudget(d time.Duration) Option {
+	return func(t *Transport) error {
+		if d < 0 {
+			return fmt.Errorf("send budget cannot be negative")
+		}
+		t.budget = d
+		return nil
+	}
+}
+
+// WithBlobCanary sends every nth notification through blob storage, even if it is small enough to send
+// inline, to verify that the blob path works. New() returns an error if there is no PayloadStore.
+func WithBlobCanary(n int) Option {
+	return func(t *Transport) error {
+		if n <= 0 {
+			return fmt.Errorf("blob canary interval must be greater than 0")
+		}
+		t.blobCanary = n
+		return nil
+	}
+}
+
+// New creates a new Transport that sends notifications with sender. store may be nil, in which case the
+// Transport is inline-only and notifications too large to send inline fail with models.ErrNoBlobClient.
+func New(sender models.EventSender, store models.PayloadStore, options ...Option) (*Transport, error) {
+	if sender == nil {
+		return nil, errors.New("sender is required")
+	}
+
+	t := &Transport{
+		errs: make(chan error, 1),
+		log:  slog.Default(),
+	}
+	for _, o := range options {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+
+	connOpts := []conn.Option{conn.WithLogger(t.log)}
+	if t.maxItems > 0 {
+		connOpts = append(connOpts, conn.WithMaxItems(t.maxItems))
+	}
+	if t.budget > 0 {
+		connOpts = append(connOpts, conn.WithSendBudget(t.budget))
+	}
+	if t.blobCanary > 0 {
+		if store == nil {
+			return nil, errors.New("WithBlobCanary() requires a PayloadStore")
+		}
+		connOpts = append(connOpts, conn.WithBlobCanary(t.blobCanary))
+	}
+
+	var err error
+	t.svc, err = conn.New(sender, store, t.errs, connOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Close stops the send loop. Nothing may be sent after Close() is called.
+func (t *Transport) Close() error {
+	return t.svc.Close()
+}
+
+// Errors returns a channel that receives the errors of notifications sent without a promise. Errors are
+// dropped when the channel is full, so listening on it is optional.
+func (t *Transport) Errors() <-chan error {
+	return t.errs
+}
+
+// Stats returns statistics about the notifications sent by the Transport.
+func (t *Transport) Stats() Stats {
+	return t.svc.Stats()
+}
+
+// Send queues n to be sent with ctx, blocking until there is room in the queue or ctx is done. If promise is
+// true, a promise is set on the returned notification and the result is delivered to it, read it with
+// Promise(). Otherwise errors are sent to Errors().
+// NOTE: If you don't use the returned Notification for a Promise instead of the one you passed, you
+// will not get the results.
+func (t *Transport) Send(ctx context.Context, n models.Notifications, promise bool) models.Notifications {
+	n = n.SetCtx(ctx)
+	if promise {
+		n = n.SetPromise(make(chan error, 1))
+	}
+	if n.DataCount() == 0 {
+		n.SendPromise(nil, t.errs)
+		return n
+	}
+
+	t.send(n)
+	return n
+}
+
+// send queues n on the send loop.
+func (t *Transport) send(n models.Notifications) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.svc.Send(n)
+}
+
+// Notify sends n with ctx and blocks until the result is available. ctx is used both for sending and for
+// waiting on the result. If ctx ends before the result is available, this returns an error wrapping
+// models.ErrPromiseTimeout or models.ErrPromiseCanceled.
+func (t *Transport) Notify(ctx context.Context, n models.Notifications) error {
+	if n.DataCount() == 0 {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return models.WaitError(ctx)
+	}
+
+	// The promise never leaves Notify(), so unlike the promises from Send() it can be pooled.
+	p := conn.NewPromise()
+	n = n.SetCtx(ctx).SetPromise(p)
+	t.send(n)
+	err := n.Promise(ctx)
+	if errors.Is(err, models.ErrPromiseTimeout) || errors.Is(err, models.ErrPromiseCanceled) {
+		// The send loop still owns the promise and will send the result on it later.
+		return err
+	}
+	conn.RecyclePromise(p)
+	return err
+}
@@ -0,0 +1,194 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/arn-sdk/models"
+)
+
+type fakeSender struct{}
+
+func (fakeSender) Send(ctx context.Context, event []byte, headers []string) error { return nil }
+
+// fakeNotify is a notification with count items that calls the EventSender it is given, unless eventErr is set.
+type fakeNotify struct {
+	models.Notifications
+	ctx      context.Context
+	promise  chan error
+	count    int
+	eventErr bool
+}
+
+func (f fakeNotify) Ctx() context.Context { return f.ctx }
+
+func (f fakeNotify) SetCtx(ctx context.Context) models.Notifications {
+	f.ctx = ctx
+	return f
+}
+
+func (f fakeNotify) SetPromise(p chan error) models.Notifications {
+	f.promise = p
+	return f
+}
+
+func (f fakeNotify) SendPromise(e error, backupCh chan error) {
+	if f.promise == nil {
+		if e != nil {
+			backupCh <- e
+		}
+		return
+	}
+	f.promise <- e
+}
+
+func (f fakeNotify) Promise(ctx context.Context) error {
+	if f.promise == nil {
+		return nil
+	}
+	return <-f.promise
+}
+
+func (f fakeNotify) DataCount() int { return f.count }
+
+func (f fakeNotify) SendEvent(h models.EventSender, s models.PayloadStore) error {
+	if f.eventErr {
+		return errors.New("event error")
+	}
+	return h.Send(f.ctx, nil, nil)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sender  models.EventSender
+		options []Option
+		wantErr bool
+	}{
+		{
+			name:    "Error: no sender",
+			wantErr: true,
+		},
+		{
+			name:    "Error: blob canary without a store",
+			sender:  fakeSender{},
+			options: []Option{WithBlobCanary(10)},
+			wantErr: true,
+		},
+		{
+			name:    "Error: bad max items",
+			sender:  fakeSender{},
+			options: []Option{WithMaxItems(0)},
+			wantErr: true,
+		},
+		{
+			name:    "Success",
+			sender:  fakeSender{},
+			options: []Option{WithMaxItems(10)},
+		},
+	}
+
+	for _, test := range tests {
+		tr, err := New(test.sender, nil, test.options...)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNew(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNew(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		tr.Close()
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Parallel()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		n       fakeNotify
+		wantErr error
+	}{
+		{
+			name: "Empty notification",
+			ctx:  context.Background(),
+			n:    fakeNotify{},
+		},
+		{
+			name:    "Error: too many items",
+			ctx:     context.Background(),
+			n:       fakeNotify{count: 11},
+			wantErr: models.ErrBatchSize,
+		},
+		{
+			name:    "Error: context canceled",
+			ctx:     canceled,
+			n:       fakeNotify{count: 1},
+			wantErr: models.ErrPromiseCanceled,
+		},
+		{
+			name:    "Error: send failed",
+			ctx:     context.Background(),
+			n:       fakeNotify{count: 1, eventErr: true},
+			wantErr: errors.New("any"),
+		},
+		{
+			name: "Success",
+			ctx:  context.Background(),
+			n:    fakeNotify{count: 10},
+		},
+	}
+
+	tr, err := New(fakeSender{}, nil, WithMaxItems(10))
+	if err != nil {
+		t.Fatalf("TestNotify: New() error: %v", err)
+	}
+	defer tr.Close()
+
+	for _, test := range tests {
+		err := tr.Notify(test.ctx, test.n)
+		switch {
+		case err == nil && test.wantErr != nil:
+			t.Errorf("TestNotify(%s): got err == nil, want err != nil", test.name)
+		case err != nil && test.wantErr == nil:
+			t.Errorf("TestNotify(%s): got err == %s, want err == nil", test.name, err)
+		case err != nil && test.wantErr.Error() != "any" && !errors.Is(err, test.wantErr):
+			t.Errorf("TestNotify(%s): got err == %s, want err wrapping %s", test.name, err, test.wantErr)
+		}
+	}
+
+	st := tr.Stats()
+	if st.Sent != 1 || st.Items != 10 || st.Failed != 2 {
+		t.Errorf("TestNotify: got Stats Sent %d, Items %d, Failed %d, want 1, 10, 2", st.Sent, st.Items, st.Failed)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	t.Parallel()
+
+	tr, err := New(fakeSender{}, nil)
+	if err != nil {
+		t.Fatalf("TestSendErrors: New() error: %v", err)
+	}
+	defer tr.Close()
+
+	tr.Send(context.Background(), fakeNotify{count: 1, eventErr: true}, false)
+	if err := <-tr.Errors(); err == nil {
+		t.Errorf("TestSendErrors: got nil on Errors(), want an error")
+	}
+
+	n := tr.Send(context.Background(), fakeNotify{count: 1}, true)
+	if err := n.Promise(context.Background()); err != nil {
+		t.Errorf("TestSendErrors: got Promise() == %s, want nil", err)
+	}
+}